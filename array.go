@@ -0,0 +1,112 @@
+package mongoboiler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// arrayElemIdentifier is the identifier UpdateArrayElement filters array elements with.
+const arrayElemIdentifier = "elem"
+
+// UpdateArrayElement applies update to the elements of arrayField matching elemFilter in the
+// first document matching filter, using the filtered positional operator $[elem] and
+// arrayFilters. Both elemFilter and update are written relative to the element:
+//
+//	// Set qty of the items with sku "a" to 3.
+//	coll.UpdateArrayElement(ctx, filter, "items",
+//		bson.D{{Key: "sku", Value: "a"}},
+//		bson.D{{Key: "$set", Value: bson.D{{Key: "qty", Value: 3}}}})
+//
+// For arrays of scalars, elemFilter holds operators on the element ({$gte: 5}) and the update
+// refers to the element itself with the empty key: {$set: {"": 0}}. Array filters given in opts
+// replace the generated ones.
+func (c Collection) UpdateArrayElement(ctx context.Context, filter bson.D, arrayField string, elemFilter, update bson.D, opts ...*options.UpdateOptions) (UpdateResult, error) {
+	rewritten, arrayFilter, err := arrayElementUpdate(arrayField, elemFilter, update)
+	if err != nil {
+		return UpdateResult{}, err
+	}
+	opts = append([]*options.UpdateOptions{
+		options.Update().SetArrayFilters(options.ArrayFilters{Filters: []any{arrayFilter}}),
+	}, opts...)
+	return c.UpdateOne(ctx, filter, rewritten, opts...)
+}
+
+// arrayElementUpdate returns the update and array filter of UpdateArrayElement.
+func arrayElementUpdate(arrayField string, elemFilter, update bson.D) (bson.D, bson.D, error) {
+	if arrayField == "" || len(elemFilter) == 0 {
+		return nil, nil, errors.New("mongoboiler: UpdateArrayElement needs an array field and element filter")
+	}
+	positional := arrayField + ".$[" + arrayElemIdentifier + "]"
+
+	rewritten := make(bson.D, len(update))
+	for i, op := range update {
+		fields, err := toDocument(op.Value)
+		if err != nil {
+			return nil, nil, fmt.Errorf("mongoboiler: update %s: %w", op.Key, err)
+		}
+		prefixed := make(bson.D, len(fields))
+		for j, f := range fields {
+			prefixed[j] = bson.E{Key: positional, Value: f.Value}
+			if f.Key != "" {
+				prefixed[j].Key += "." + f.Key
+			}
+		}
+		rewritten[i] = bson.E{Key: op.Key, Value: prefixed}
+	}
+
+	var arrayFilter bson.D
+	if strings.HasPrefix(elemFilter[0].Key, "$") {
+		arrayFilter = bson.D{{Key: arrayElemIdentifier, Value: elemFilter}}
+	} else {
+		arrayFilter = make(bson.D, len(elemFilter))
+		for i, f := range elemFilter {
+			arrayFilter[i] = bson.E{Key: arrayElemIdentifier + "." + f.Key, Value: f.Value}
+		}
+	}
+	return rewritten, arrayFilter, nil
+}
+
+// AddToSet adds values to the array field of the first document matching filter unless it
+// already holds an equal element. Documents are only equal with the same fields in the same
+// order; see PushUnique for comparing them by a key.
+func (c Collection) AddToSet(ctx context.Context, filter bson.D, field string, values ...any) (UpdateResult, error) {
+	return c.UpdateOne(ctx, filter, bson.D{{Key: "$addToSet", Value: bson.D{
+		{Key: field, Value: bson.D{{Key: "$each", Value: bson.A(values)}}},
+	}}})
+}
+
+// PushUnique appends doc to the array field of the first document matching filter unless an
+// element has the same value at key as doc, e.g. an item with the same sku. ModifiedCount is
+// zero when there was one.
+func (c Collection) PushUnique(ctx context.Context, filter bson.D, field, key string, doc any) (UpdateResult, error) {
+	d, err := toDocument(doc)
+	if err != nil {
+		return UpdateResult{}, err
+	}
+	var value any
+	found := false
+	for _, e := range d {
+		if e.Key == key {
+			value, found = e.Value, true
+			break
+		}
+	}
+	if !found {
+		return UpdateResult{}, fmt.Errorf("mongoboiler: PushUnique document has no %s", key)
+	}
+
+	// An element matching the key makes the filter miss, so the push is skipped atomically.
+	guarded := append(append(bson.D{}, filter...), bson.E{Key: field + "." + key, Value: bson.D{{Key: "$ne", Value: value}}})
+	return c.UpdateOne(ctx, guarded, bson.D{{Key: "$push", Value: bson.D{{Key: field, Value: d}}}})
+}
+
+// Pull removes the elements of the array field matching cond from the first document matching
+// filter. cond is a value to remove, or a filter on the elements such as {qty: {$lte: 0}}.
+func (c Collection) Pull(ctx context.Context, filter bson.D, field string, cond any) (UpdateResult, error) {
+	return c.UpdateOne(ctx, filter, bson.D{{Key: "$pull", Value: bson.D{{Key: field, Value: cond}}}})
+}
@@ -0,0 +1,85 @@
+package mongoboiler
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// recordingCollection returns a collection whose operations are recorded instead of executed.
+func recordingCollection(t *testing.T, ops *[]*Operation) *Collection {
+	return newTestCollection(t, "orders", WithMiddleware(func(next Handler) Handler {
+		return func(ctx context.Context, op *Operation) error {
+			*ops = append(*ops, op)
+			return nil
+		}
+	}))
+}
+
+func TestArrayElementUpdate(t *testing.T) {
+	update, arrayFilter, err := arrayElementUpdate("items",
+		bson.D{{Key: "sku", Value: "a"}, {Key: "qty", Value: bson.D{{Key: "$gt", Value: 0}}}},
+		bson.D{{Key: "$set", Value: bson.D{{Key: "qty", Value: 3}}}, {Key: "$inc", Value: bson.M{"": 1}}})
+	if err != nil {
+		t.Fatalf("arrayElementUpdate failed: %v", err)
+	}
+	wantUpdate := bson.D{
+		{Key: "$set", Value: bson.D{{Key: "items.$[elem].qty", Value: 3}}},
+		{Key: "$inc", Value: bson.D{{Key: "items.$[elem]", Value: int32(1)}}},
+	}
+	if !reflect.DeepEqual(update, wantUpdate) {
+		t.Fatalf("got update %v, want %v", update, wantUpdate)
+	}
+	wantFilter := bson.D{{Key: "elem.sku", Value: "a"}, {Key: "elem.qty", Value: bson.D{{Key: "$gt", Value: 0}}}}
+	if !reflect.DeepEqual(arrayFilter, wantFilter) {
+		t.Fatalf("got array filter %v, want %v", arrayFilter, wantFilter)
+	}
+
+	_, arrayFilter, _ = arrayElementUpdate("scores", bson.D{{Key: "$gte", Value: 5}}, bson.D{})
+	if want := (bson.D{{Key: "elem", Value: bson.D{{Key: "$gte", Value: 5}}}}); !reflect.DeepEqual(arrayFilter, want) {
+		t.Fatalf("got array filter %v, want %v", arrayFilter, want)
+	}
+	if _, _, err := arrayElementUpdate("items", nil, bson.D{}); err == nil {
+		t.Fatalf("expected an error without element filter")
+	}
+}
+
+func TestPushUnique(t *testing.T) {
+	var ops []*Operation
+	coll := recordingCollection(t, &ops)
+	filter := bson.D{{Key: "_id", Value: 1}}
+
+	item := decodeItem{Name: "pen", Qty: 1}
+	if _, err := coll.PushUnique(context.Background(), filter, "items", "name", item); err != nil {
+		t.Fatalf("PushUnique failed: %v", err)
+	}
+	wantFilter := bson.D{{Key: "_id", Value: 1}, {Key: "items.name", Value: bson.D{{Key: "$ne", Value: "pen"}}}}
+	if !reflect.DeepEqual(ops[0].Filter, wantFilter) || len(filter) != 1 {
+		t.Fatalf("got filter %v, want %v", ops[0].Filter, wantFilter)
+	}
+	if ops[0].Update[0].Key != "$push" {
+		t.Fatalf("expected a $push, got %v", ops[0].Update)
+	}
+	if _, err := coll.PushUnique(context.Background(), filter, "items", "sku", item); err == nil {
+		t.Fatalf("expected an error for a document without the key")
+	}
+}
+
+func TestAddToSetAndPull(t *testing.T) {
+	var ops []*Operation
+	coll := recordingCollection(t, &ops)
+
+	coll.AddToSet(context.Background(), nil, "tags", "a", "b")
+	coll.Pull(context.Background(), nil, "tags", bson.D{{Key: "$in", Value: bson.A{"c"}}})
+
+	wantAdd := bson.D{{Key: "$addToSet", Value: bson.D{{Key: "tags", Value: bson.D{{Key: "$each", Value: bson.A{"a", "b"}}}}}}}
+	if !reflect.DeepEqual(ops[0].Update, wantAdd) {
+		t.Fatalf("got %v, want %v", ops[0].Update, wantAdd)
+	}
+	wantPull := bson.D{{Key: "$pull", Value: bson.D{{Key: "tags", Value: bson.D{{Key: "$in", Value: bson.A{"c"}}}}}}}
+	if !reflect.DeepEqual(ops[1].Update, wantPull) {
+		t.Fatalf("got %v, want %v", ops[1].Update, wantPull)
+	}
+}
@@ -0,0 +1,301 @@
+package mongoboiler
+
+import (
+	"context"
+	"time"
+
+	"github.com/anurag925/mongoboiler/internal/bsonutil"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DefaultAuditCollection is the collection WithAudit records entries in.
+const DefaultAuditCollection = "audit_log"
+
+// AuditConfig configures WithAudit.
+type AuditConfig struct {
+	// Collection receives the entries, in the database of the audited collection.
+	// DefaultAuditCollection if empty.
+	Collection string
+	// Actor returns who performs an operation, ActorFromContext if nil.
+	Actor func(ctx context.Context) string
+	// Diff records the documents before and after updates, replacements and deletes along
+	// with the changed fields. It costs a read before and after every such write and is best
+	// effort outside transactions, as concurrent writes may interleave with those reads.
+	Diff bool
+	// MaxDiffDocuments limits the documents diffed per multi-document write, 100 by default.
+	MaxDiffDocuments int
+	// Logger receives failures to write entries, the standard logger if nil. Such failures do
+	// not fail the audited operation, which already happened.
+	Logger Logger
+}
+
+// AuditEntry is the record of one write operation.
+type AuditEntry struct {
+	ID          primitive.ObjectID `bson:"_id"`
+	At          time.Time          `bson:"at"`
+	Actor       string             `bson:"actor,omitempty"`
+	OperationID string             `bson:"operationId"`
+	Database    string             `bson:"database"`
+	Collection  string             `bson:"collection"`
+	Operation   OpKind             `bson:"operation"`
+	Filter      bson.D             `bson:"filter,omitempty"`
+	Update      bson.D             `bson:"update,omitempty"`
+	// DocumentIDs are the IDs of inserted and upserted documents, and with Diff those of the
+	// updated or deleted ones.
+	DocumentIDs []any           `bson:"documentIds,omitempty"`
+	Documents   []AuditDocument `bson:"documents,omitempty"`
+	Matched     int64           `bson:"matched,omitempty"`
+	Modified    int64           `bson:"modified,omitempty"`
+	Deleted     int64           `bson:"deleted,omitempty"`
+	Error       string          `bson:"error,omitempty"`
+	// DryRun is set for writes WithDryRun did not execute.
+	DryRun bool `bson:"dryRun,omitempty"`
+}
+
+// AuditDocument is the before and after image of a document changed by an audited write.
+type AuditDocument struct {
+	ID      any           `bson:"id"`
+	Before  bson.Raw      `bson:"before,omitempty"`
+	After   bson.Raw      `bson:"after,omitempty"`
+	Changes []FieldChange `bson:"changes,omitempty"`
+}
+
+// FieldChange is a field whose value differs between two versions of a document. Before or
+// After is nil when the field was added or removed.
+type FieldChange struct {
+	Path   string `bson:"path"`
+	Before any    `bson:"before,omitempty"`
+	After  any    `bson:"after,omitempty"`
+}
+
+type actorKey struct{}
+
+// ContextWithActor returns a context carrying the user or service performing operations, for
+// audit entries.
+func ContextWithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext returns the actor stored by ContextWithActor.
+func ActorFromContext(ctx context.Context) (string, bool) {
+	actor, ok := ctx.Value(actorKey{}).(string)
+	return actor, ok
+}
+
+// WithAudit records every insert, update, replace and delete in an audit collection, including
+// failed ones. Entries are written straight to the driver so they are not audited themselves.
+// Writes made in a session, such as those of WithTransaction, have their entries written in it,
+// so the entries of aborted transactions are discarded with their writes.
+// Register it after middleware that rewrites operations, such as WithTenancy, to record what
+// was sent to the server.
+func WithAudit(cfg AuditConfig) Option {
+	a := &auditor{cfg: cfg}
+	if a.cfg.Collection == "" {
+		a.cfg.Collection = DefaultAuditCollection
+	}
+	if a.cfg.MaxDiffDocuments <= 0 {
+		a.cfg.MaxDiffDocuments = 100
+	}
+	a.write = func(ctx context.Context, op *Operation, entry *AuditEntry) error {
+		_, err := op.Target.Database().Collection(a.cfg.Collection).InsertOne(ctx, entry)
+		return err
+	}
+	return WithMiddleware(a.middleware)
+}
+
+type auditor struct {
+	cfg   AuditConfig
+	write func(ctx context.Context, op *Operation, entry *AuditEntry) error
+}
+
+func (a *auditor) middleware(next Handler) Handler {
+	return func(ctx context.Context, op *Operation) error {
+		if !op.Kind.IsWrite() {
+			return next(ctx, op)
+		}
+		var before []bson.Raw
+		if a.cfg.Diff && op.Filter != nil && !op.dryRun {
+			before = a.snapshot(ctx, op)
+		}
+
+		err := next(ctx, op)
+
+		entry := a.entry(ctx, op, err)
+		if a.cfg.Diff && !op.dryRun {
+			a.diff(ctx, op, entry, before)
+		}
+		// The audit record must be written even when the caller gives up right after the write,
+		// but in its session, so it commits or aborts with the transaction of the write.
+		wctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if sess := mongo.SessionFromContext(ctx); sess != nil {
+			wctx = mongo.NewSessionContext(wctx, sess)
+		}
+		if werr := a.write(wctx, op, entry); werr != nil {
+			loggerOrDefault(a.cfg.Logger).Printf("mongoboiler: audit %s %s.%s (op %s): %v", op.Kind, op.Database, op.Collection, op.ID, werr)
+		}
+		return err
+	}
+}
+
+func (a *auditor) entry(ctx context.Context, op *Operation, err error) *AuditEntry {
+	entry := &AuditEntry{
+		ID:          primitive.NewObjectID(),
+		At:          time.Now().UTC(),
+		OperationID: op.ID,
+		Database:    op.Database,
+		Collection:  op.Collection,
+		Operation:   op.Kind,
+		Filter:      op.Filter,
+		Update:      op.Update,
+		DryRun:      op.dryRun,
+	}
+	if a.cfg.Actor != nil {
+		entry.Actor = a.cfg.Actor(ctx)
+	} else {
+		entry.Actor, _ = ActorFromContext(ctx)
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	switch res := op.Result.(type) {
+	case InsertResult:
+		entry.DocumentIDs = res.InsertedIDs
+	case UpdateResult:
+		entry.Matched, entry.Modified = res.MatchedCount, res.ModifiedCount
+		if res.UpsertedID != nil {
+			entry.DocumentIDs = []any{res.UpsertedID}
+		}
+	case DeleteResult:
+		entry.Deleted = res.DeletedCount
+	}
+	return entry
+}
+
+// snapshot reads the documents op is about to change.
+func (a *auditor) snapshot(ctx context.Context, op *Operation) []bson.Raw {
+	limit := int64(a.cfg.MaxDiffDocuments)
+	switch op.Kind {
+	case OpUpdateOne, OpReplaceOne, OpDeleteOne, OpFindOrCreate:
+		limit = 1
+	case OpUpdateMany, OpDeleteMany:
+	default:
+		return nil
+	}
+	return a.find(ctx, op, op.Filter, limit)
+}
+
+func (a *auditor) find(ctx context.Context, op *Operation, filter bson.D, limit int64) []bson.Raw {
+	docs, err := snapshotDocuments(ctx, op.Target, filter, limit)
+	if err != nil {
+		loggerOrDefault(a.cfg.Logger).Printf("mongoboiler: audit snapshot of %s.%s: %v", op.Database, op.Collection, err)
+	}
+	return docs
+}
+
+// snapshotDocuments reads up to limit documents matching filter straight from the driver,
+// zero reading all of them.
+func snapshotDocuments(ctx context.Context, target *mongo.Collection, filter bson.D, limit int64) ([]bson.Raw, error) {
+	cursor, err := target.Find(ctx, filter, options.Find().SetLimit(limit))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+	var docs []bson.Raw
+	for cursor.Next(ctx) {
+		docs = append(docs, append(bson.Raw(nil), cursor.Current...))
+	}
+	return docs, cursor.Err()
+}
+
+// diff adds the before and after images of the documents op changed to entry.
+func (a *auditor) diff(ctx context.Context, op *Operation, entry *AuditEntry, before []bson.Raw) {
+	if op.Kind == OpInsertOne || op.Kind == OpInsertMany {
+		for i, doc := range op.Documents {
+			raw, err := bson.Marshal(doc)
+			if err != nil || i >= len(entry.DocumentIDs) {
+				continue
+			}
+			entry.Documents = append(entry.Documents, AuditDocument{ID: entry.DocumentIDs[i], After: raw})
+		}
+		return
+	}
+
+	docs := make([]AuditDocument, 0, len(before))
+	ids := bson.A{}
+	for _, doc := range before {
+		id := doc.Lookup("_id")
+		docs = append(docs, AuditDocument{ID: id, Before: doc})
+		ids = append(ids, id)
+	}
+	if op.Kind != OpDeleteOne && op.Kind != OpDeleteMany {
+		// Upserted documents have no before image.
+		ids = append(ids, entry.DocumentIDs...)
+		if len(ids) > 0 {
+			filter := bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: ids}}}}
+			for _, doc := range a.find(ctx, op, filter, int64(len(ids))) {
+				i := indexOfAuditDocument(docs, doc.Lookup("_id"))
+				if i < 0 {
+					docs = append(docs, AuditDocument{ID: doc.Lookup("_id")})
+					i = len(docs) - 1
+				}
+				docs[i].After = doc
+			}
+		}
+	}
+
+	entry.DocumentIDs = nil
+	for i := range docs {
+		docs[i].Changes = diffDocuments(docs[i].Before, docs[i].After)
+		entry.DocumentIDs = append(entry.DocumentIDs, docs[i].ID)
+	}
+	entry.Documents = docs
+}
+
+func indexOfAuditDocument(docs []AuditDocument, id bson.RawValue) int {
+	for i, d := range docs {
+		if v, ok := d.ID.(bson.RawValue); ok && v.Type == id.Type && bsonutil.Equal(v, id) {
+			return i
+		}
+	}
+	return -1
+}
+
+// diffDocuments returns the fields that differ between two documents, descending into
+// embedded documents. Either may be nil.
+func diffDocuments(before, after bson.Raw) []FieldChange {
+	var changes []FieldChange
+	diffInto(&changes, "", before, after)
+	return changes
+}
+
+func diffInto(changes *[]FieldChange, prefix string, before, after bson.Raw) {
+	seen := map[string]bool{}
+	elems, _ := before.Elements()
+	for _, e := range elems {
+		key := e.Key()
+		seen[key] = true
+		av, err := after.LookupErr(key)
+		if err != nil {
+			*changes = append(*changes, FieldChange{Path: prefix + key, Before: e.Value()})
+			continue
+		}
+		bv := e.Value()
+		if bv.Type == bson.TypeEmbeddedDocument && av.Type == bson.TypeEmbeddedDocument {
+			diffInto(changes, prefix+key+".", bv.Document(), av.Document())
+			continue
+		}
+		if bv.Type != av.Type || !bsonutil.Equal(bv, av) {
+			*changes = append(*changes, FieldChange{Path: prefix + key, Before: bv, After: av})
+		}
+	}
+	elems, _ = after.Elements()
+	for _, e := range elems {
+		if !seen[e.Key()] {
+			*changes = append(*changes, FieldChange{Path: prefix + e.Key(), After: e.Value()})
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package mongoboiler
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestAudit_RecordsWrites(t *testing.T) {
+	var entries []*AuditEntry
+	a := &auditor{cfg: AuditConfig{Collection: DefaultAuditCollection}}
+	a.write = func(ctx context.Context, op *Operation, entry *AuditEntry) error {
+		entries = append(entries, entry)
+		return nil
+	}
+	coll := newTestCollection(t, "accounts", WithMiddleware(a.middleware))
+	ctx := ContextWithActor(context.Background(), "alice")
+
+	op := coll.newOp(OpUpdateOne)
+	op.Filter = bson.D{{Key: "_id", Value: 1}}
+	op.Update = bson.D{{Key: "$set", Value: bson.D{{Key: "balance", Value: 10}}}}
+	err := coll.run(ctx, op, func(ctx context.Context, op *Operation) error {
+		op.Result = UpdateResult{MatchedCount: 1, ModifiedCount: 1}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	failed := errors.New("boom")
+	_ = coll.run(ctx, coll.newOp(OpDeleteMany), func(ctx context.Context, op *Operation) error { return failed })
+	_ = coll.run(ctx, coll.newOp(OpFind), func(ctx context.Context, op *Operation) error { return nil })
+
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 audit entries for 2 writes, got %d", len(entries))
+	}
+	e := entries[0]
+	if e.Actor != "alice" || e.Collection != "accounts" || e.Operation != OpUpdateOne || e.Matched != 1 || e.OperationID != op.ID {
+		t.Fatalf("unexpected entry %+v", e)
+	}
+	if entries[1].Error != failed.Error() {
+		t.Fatalf("expected the failure to be recorded, got %+v", entries[1])
+	}
+}
+
+func TestAudit_WritesInSession(t *testing.T) {
+	var sessions []mongo.Session
+	a := &auditor{cfg: AuditConfig{Collection: DefaultAuditCollection}}
+	a.write = func(ctx context.Context, op *Operation, entry *AuditEntry) error {
+		sessions = append(sessions, mongo.SessionFromContext(ctx))
+		return nil
+	}
+	coll := newTestCollection(t, "accounts", WithMiddleware(a.middleware))
+	write := func(ctx context.Context, op *Operation) error { return nil }
+
+	sess := fakeSession{}
+	ctx, cancel := context.WithCancel(mongo.NewSessionContext(context.Background(), sess))
+	_ = coll.run(ctx, coll.newOp(OpInsertOne), func(ctx context.Context, op *Operation) error {
+		cancel()
+		return nil
+	})
+	_ = coll.run(context.Background(), coll.newOp(OpInsertOne), write)
+
+	if len(sessions) != 2 {
+		t.Fatalf("expected 2 audit entries, got %d", len(sessions))
+	}
+	if sessions[0] != sess {
+		t.Fatalf("expected the entry to be written in the session of the write, got %v", sessions[0])
+	}
+	if sessions[1] != nil {
+		t.Fatalf("expected no session outside one, got %v", sessions[1])
+	}
+}
+
+func TestDiffDocuments(t *testing.T) {
+	before := mustRaw(t, bson.D{
+		{Key: "_id", Value: 1},
+		{Key: "name", Value: "ann"},
+		{Key: "address", Value: bson.D{{Key: "city", Value: "Oslo"}, {Key: "zip", Value: "0150"}}},
+		{Key: "nickname", Value: "a"},
+	})
+	after := mustRaw(t, bson.D{
+		{Key: "_id", Value: 1},
+		{Key: "name", Value: "ann"},
+		{Key: "address", Value: bson.D{{Key: "city", Value: "Bergen"}, {Key: "zip", Value: "0150"}}},
+		{Key: "email", Value: "ann@example.com"},
+	})
+
+	changes := diffDocuments(before, after)
+	var paths []string
+	for _, c := range changes {
+		paths = append(paths, c.Path)
+	}
+	want := []string{"address.city", "nickname", "email"}
+	if len(paths) != len(want) {
+		t.Fatalf("changed paths %v, want %v", paths, want)
+	}
+	for i := range want {
+		if paths[i] != want[i] {
+			t.Fatalf("changed paths %v, want %v", paths, want)
+		}
+	}
+	if changes[1].After != nil || changes[2].Before != nil {
+		t.Fatalf("removed and added fields must have one side only: %+v", changes)
+	}
+}
@@ -0,0 +1,111 @@
+package mongoboiler
+
+import (
+	"fmt"
+	"strconv"
+
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// AuthMechanism is a MongoDB authentication mechanism.
+type AuthMechanism string
+
+// Supported authentication mechanisms.
+const (
+	AuthSCRAMSHA256 AuthMechanism = "SCRAM-SHA-256"
+	AuthSCRAMSHA1   AuthMechanism = "SCRAM-SHA-1"
+	// AuthAWS authenticates with AWS IAM credentials. Without AWSAccessKeyID the driver looks up
+	// credentials itself, in order: the AWS_ACCESS_KEY_ID/AWS_SECRET_ACCESS_KEY/AWS_SESSION_TOKEN
+	// environment variables, web identity role assumption (AWS_ROLE_ARN and
+	// AWS_WEB_IDENTITY_TOKEN_FILE, as set up by EKS IRSA), the ECS task role and the EC2 instance
+	// role. Such temporary credentials are cached and refreshed by the driver before they expire.
+	AuthAWS AuthMechanism = "MONGODB-AWS"
+	// AuthX509 authenticates with the TLS client certificate, which must be set in TLSConfig.
+	AuthX509 AuthMechanism = "MONGODB-X509"
+	// AuthGSSAPI authenticates with Kerberos. The binary must be built with the gssapi build tag
+	// for the driver to support it.
+	AuthGSSAPI AuthMechanism = "GSSAPI"
+	// AuthPLAIN authenticates against LDAP.
+	AuthPLAIN AuthMechanism = "PLAIN"
+)
+
+// AuthConfig describes how a connection authenticates. The zero value uses the credentials of the URI.
+type AuthConfig struct {
+	Mechanism AuthMechanism
+	// Source is the authentication database; the driver picks the mechanism's default if empty.
+	Source   string
+	Username string
+	Password string
+
+	// AWSAccessKeyID, AWSSecretAccessKey and AWSSessionToken are static AWS credentials.
+	// Static credentials are never refreshed, prefer leaving them empty (see AuthAWS).
+	AWSAccessKeyID     string
+	AWSSecretAccessKey string
+	AWSSessionToken    string
+
+	// Kerberos settings for AuthGSSAPI.
+	ServiceName          string
+	ServiceRealm         string
+	CanonicalizeHostName bool
+}
+
+// Enabled reports whether any authentication setting is configured.
+func (a AuthConfig) Enabled() bool {
+	return a != AuthConfig{}
+}
+
+// Credential validates a and converts it into driver credentials.
+func (a AuthConfig) Credential() (options.Credential, error) {
+	cred := options.Credential{
+		AuthMechanism: string(a.Mechanism),
+		AuthSource:    a.Source,
+		Username:      a.Username,
+		Password:      a.Password,
+		PasswordSet:   a.Password != "",
+	}
+
+	switch a.Mechanism {
+	case "", AuthSCRAMSHA256, AuthSCRAMSHA1, AuthPLAIN:
+		if a.Username == "" {
+			return cred, fmt.Errorf("mongoboiler: %s authentication needs a username", mechanismName(a.Mechanism))
+		}
+	case AuthAWS:
+		if (a.AWSAccessKeyID == "") != (a.AWSSecretAccessKey == "") {
+			return cred, fmt.Errorf("mongoboiler: AWS access key ID and secret access key must be set together")
+		}
+		cred.Username, cred.Password = a.AWSAccessKeyID, a.AWSSecretAccessKey
+		cred.PasswordSet = a.AWSSecretAccessKey != ""
+		if a.AWSSessionToken != "" {
+			cred.AuthMechanismProperties = map[string]string{"AWS_SESSION_TOKEN": a.AWSSessionToken}
+		}
+	case AuthX509:
+		if a.Password != "" {
+			return cred, fmt.Errorf("mongoboiler: X.509 authentication does not take a password")
+		}
+	case AuthGSSAPI:
+		if a.Username == "" {
+			return cred, fmt.Errorf("mongoboiler: GSSAPI authentication needs the Kerberos principal as username")
+		}
+		props := map[string]string{}
+		if a.ServiceName != "" {
+			props["SERVICE_NAME"] = a.ServiceName
+		}
+		if a.ServiceRealm != "" {
+			props["SERVICE_REALM"] = a.ServiceRealm
+		}
+		if a.CanonicalizeHostName {
+			props["CANONICALIZE_HOST_NAME"] = strconv.FormatBool(true)
+		}
+		cred.AuthMechanismProperties = props
+	default:
+		return cred, fmt.Errorf("mongoboiler: unsupported authentication mechanism %q", a.Mechanism)
+	}
+	return cred, nil
+}
+
+func mechanismName(m AuthMechanism) string {
+	if m == "" {
+		return "default"
+	}
+	return string(m)
+}
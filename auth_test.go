@@ -0,0 +1,37 @@
+package mongoboiler
+
+import "testing"
+
+func TestAuthConfig_Credential(t *testing.T) {
+	cred, err := AuthConfig{Mechanism: AuthAWS, AWSAccessKeyID: "AKIA", AWSSecretAccessKey: "secret", AWSSessionToken: "token"}.Credential()
+	if err != nil {
+		t.Fatalf("Credential failed: %v", err)
+	}
+	if cred.Username != "AKIA" || cred.Password != "secret" || cred.AuthMechanismProperties["AWS_SESSION_TOKEN"] != "token" {
+		t.Fatalf("unexpected AWS credential: %+v", cred)
+	}
+
+	if _, err := (AuthConfig{Mechanism: AuthAWS}).Credential(); err != nil {
+		t.Fatalf("AWS without static keys should use the driver's credential chain: %v", err)
+	}
+
+	invalid := []AuthConfig{
+		{Mechanism: AuthAWS, AWSAccessKeyID: "AKIA"},
+		{Mechanism: AuthX509, Password: "secret"},
+		{Mechanism: AuthGSSAPI},
+		{Password: "secret"},
+		{Mechanism: "MONGODB-CR"},
+	}
+	for _, a := range invalid {
+		if _, err := a.Credential(); err == nil {
+			t.Errorf("expected error for %+v", a)
+		}
+	}
+}
+
+func TestConfig_ValidateX509NeedsCertificate(t *testing.T) {
+	cfg := Config{URI: "mongodb://localhost/db", Auth: AuthConfig{Mechanism: AuthX509}}
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("expected X.509 without client certificate to fail validation")
+	}
+}
@@ -0,0 +1,336 @@
+package mongoboiler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DefaultInsertBatchBytes bounds the size of the batches of InsertManyBatched by default,
+// MongoDB's limit of a single document.
+const DefaultInsertBatchBytes = 16 << 20
+
+var (
+	// ErrPartialInsert is returned by InsertManyBatched when some documents were not inserted.
+	ErrPartialInsert = errors.New("mongoboiler: some documents were not inserted")
+	// ErrNotAttempted is the error of documents InsertManyBatched did not try to insert because
+	// an earlier one failed in ordered mode.
+	ErrNotAttempted = errors.New("mongoboiler: not attempted after an earlier failure")
+)
+
+// InsertBatchOptions configures InsertManyBatched.
+type InsertBatchOptions struct {
+	// BatchSize is the number of documents inserted at once, DefaultImportBatchSize if zero.
+	// The server accepts at most 100,000.
+	BatchSize int
+	// MaxBatchBytes bounds the encoded size of a batch, DefaultInsertBatchBytes if zero. A
+	// document bigger than that is inserted on its own.
+	MaxBatchBytes int
+	// Unordered keeps inserting after a document failed, so every document is attempted.
+	Unordered bool
+	// Adaptive tunes the batch size while inserting, starting at BatchSize, when not nil.
+	Adaptive *AdaptiveBatching
+}
+
+// AdaptiveBatching makes InsertManyBatched adjust the batch size to the server's response: it
+// halves the size when a batch takes longer than TargetLatency and grows it by half when
+// batches take less than half of it. A batch failing as a whole with a timeout or network error
+// is retried at half the size, when all its documents have an _id so none can be inserted
+// twice; documents found inserted by the failed attempt are reported inserted.
+type AdaptiveBatching struct {
+	// MinBatchSize and MaxBatchSize bound the batch size, 10 and 10,000 by default.
+	MinBatchSize int
+	MaxBatchSize int
+	// TargetLatency is the time a batch should take, one second by default.
+	TargetLatency time.Duration
+	// MaxRetries is the most times a batch is retried, 3 by default.
+	MaxRetries int
+}
+
+// InsertFailure is a document InsertManyBatched did not insert.
+type InsertFailure struct {
+	// Index is the position of the document in the slice given to InsertManyBatched.
+	Index    int
+	Document any
+	// Err is the error of the document, translated like those of InsertOne, or ErrNotAttempted.
+	Err error
+}
+
+// BatchInsertResult is returned by InsertManyBatched.
+type BatchInsertResult struct {
+	// InsertedIDs holds the IDs of the documents inserted, in the order given.
+	InsertedIDs []any
+	// Failures holds the documents not inserted, in the order given.
+	Failures []InsertFailure
+	// BatchSizes holds the number of documents of each batch inserted, in order, showing how
+	// adaptive batching tuned them.
+	BatchSizes []int
+}
+
+// FailedDocuments returns the documents not inserted, e.g. to retry them.
+func (r BatchInsertResult) FailedDocuments() []any {
+	docs := make([]any, len(r.Failures))
+	for i, f := range r.Failures {
+		docs[i] = f.Document
+	}
+	return docs
+}
+
+// InsertManyBatched inserts docs in batches of bounded count and size, reporting the error of
+// every document that was not inserted instead of only the first. When some were not,
+// ErrPartialInsert is returned along with the result; other errors, such as a canceled ctx,
+// stop inserting and are returned as they are, the remaining documents being failures with
+// ErrNotAttempted.
+func (c Collection) InsertManyBatched(ctx context.Context, docs []any, opts InsertBatchOptions) (BatchInsertResult, error) {
+	var res BatchInsertResult
+	encoded, err := encodeDocuments(docs)
+	if err != nil {
+		return res, err
+	}
+	progress := TrackProgress(ctx, "insert "+c.name, int64(len(docs)))
+	defer progress.Finish()
+
+	tuner := newBatchTuner(opts)
+	// Documents before retriedUntil were sent in a batch that failed, and may have been inserted.
+	retriedUntil := 0
+	for start := 0; start < len(docs); {
+		batch := encoded.next(start, tuner.size, opts.MaxBatchBytes)
+		began := time.Now()
+		ids, failed, err := c.insertBatch(ctx, docs[batch.start:batch.end], !opts.Unordered)
+		if err != nil && ctx.Err() == nil && encoded.haveIDs(batch) && tuner.retry(err) {
+			if batch.end > retriedUntil {
+				retriedUntil = batch.end
+			}
+			continue
+		}
+		if err == nil && batch.start < retriedUntil {
+			batch, ids = encoded.acceptRetriedDuplicates(batch, retriedUntil, ids, failed, !opts.Unordered)
+		}
+		tuner.observe(time.Since(began), batch.end-batch.start)
+		start = batch.end
+		if err != nil {
+			for i := batch.start; i < len(docs); i++ {
+				failure := InsertFailure{Index: i, Document: docs[i], Err: ErrNotAttempted}
+				if i < batch.end {
+					failure.Err = err
+				}
+				res.Failures = append(res.Failures, failure)
+			}
+			return res, err
+		}
+		for i := batch.start; i < batch.end; i++ {
+			if ferr, ok := failed[i-batch.start]; ok {
+				res.Failures = append(res.Failures, InsertFailure{Index: i, Document: docs[i], Err: ferr})
+			} else if i-batch.start < len(ids) {
+				res.InsertedIDs = append(res.InsertedIDs, ids[i-batch.start])
+			}
+		}
+		res.BatchSizes = append(res.BatchSizes, batch.end-batch.start)
+		progress.Add(int64(batch.end - batch.start))
+		if len(failed) > 0 && !opts.Unordered {
+			for i := batch.end; i < len(docs); i++ {
+				res.Failures = append(res.Failures, InsertFailure{Index: i, Document: docs[i], Err: ErrNotAttempted})
+			}
+			break
+		}
+	}
+	if len(res.Failures) > 0 {
+		return res, fmt.Errorf("%w: %d of %d", ErrPartialInsert, len(res.Failures), len(docs))
+	}
+	return res, nil
+}
+
+type insertBatch struct {
+	start, end int
+}
+
+// encodedDocuments holds the encoded sizes and _ids of the documents to insert.
+type encodedDocuments struct {
+	sizes []int
+	// ids holds the _id of each document, nil for those without.
+	ids []any
+}
+
+func encodeDocuments(docs []any) (encodedDocuments, error) {
+	e := encodedDocuments{sizes: make([]int, len(docs)), ids: make([]any, len(docs))}
+	for i, doc := range docs {
+		raw, ok := doc.(bson.Raw)
+		if !ok {
+			var err error
+			if raw, err = bson.Marshal(doc); err != nil {
+				return e, fmt.Errorf("mongoboiler: document %d: %w", i, err)
+			}
+		}
+		e.sizes[i] = len(raw)
+		if id, err := raw.LookupErr("_id"); err == nil {
+			e.ids[i] = id
+		}
+	}
+	return e, nil
+}
+
+// next returns the batch starting at start with at most size documents and maxBytes bytes, or
+// DefaultImportBatchSize and DefaultInsertBatchBytes if zero. A document bigger than maxBytes
+// makes a batch on its own.
+func (e encodedDocuments) next(start, size, maxBytes int) insertBatch {
+	if size <= 0 {
+		size = DefaultImportBatchSize
+	}
+	if maxBytes <= 0 {
+		maxBytes = DefaultInsertBatchBytes
+	}
+	end, bytes := start, 0
+	for end < len(e.sizes) && (end == start || end-start < size && bytes+e.sizes[end] <= maxBytes) {
+		bytes += e.sizes[end]
+		end++
+	}
+	return insertBatch{start, end}
+}
+
+// haveIDs reports whether every document of batch has an _id.
+func (e encodedDocuments) haveIDs(batch insertBatch) bool {
+	for _, id := range e.ids[batch.start:batch.end] {
+		if id == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// acceptRetriedDuplicates removes the failures of the documents before retriedUntil that failed
+// on a duplicate _id, as the failed attempt before inserted them, and returns the batch's IDs with
+// theirs. In ordered mode the batch is cut after such a document, the server did not attempt
+// those following it.
+func (e encodedDocuments) acceptRetriedDuplicates(batch insertBatch, retriedUntil int, ids []any, failed map[int]error, ordered bool) (insertBatch, []any) {
+	if n := batch.end - batch.start; len(ids) < n {
+		ids = append(ids, make([]any, n-len(ids))...)
+	}
+	for i, err := range failed {
+		var dup *DuplicateKeyError
+		if batch.start+i >= retriedUntil || !errors.As(err, &dup) || dup.Index != "_id_" {
+			continue
+		}
+		delete(failed, i)
+		ids[i] = e.ids[batch.start+i]
+		if ordered {
+			for j := range failed {
+				if j > i {
+					delete(failed, j)
+				}
+			}
+			batch.end = batch.start + i + 1
+			ids = ids[:i+1]
+		}
+	}
+	return batch, ids
+}
+
+// batchTuner chooses the size of the next batch of InsertManyBatched.
+type batchTuner struct {
+	adaptive *AdaptiveBatching
+	size     int
+	retries  int
+}
+
+func newBatchTuner(opts InsertBatchOptions) *batchTuner {
+	t := &batchTuner{size: opts.BatchSize}
+	if t.size <= 0 {
+		t.size = DefaultImportBatchSize
+	}
+	if opts.Adaptive == nil {
+		return t
+	}
+	a := *opts.Adaptive
+	if a.MinBatchSize <= 0 {
+		a.MinBatchSize = 10
+	}
+	if a.MaxBatchSize <= 0 {
+		a.MaxBatchSize = 10000
+	}
+	if a.TargetLatency <= 0 {
+		a.TargetLatency = time.Second
+	}
+	if a.MaxRetries <= 0 {
+		a.MaxRetries = 3
+	}
+	t.adaptive = &a
+	t.clamp()
+	return t
+}
+
+// observe adjusts the size after a batch of n documents took latency.
+func (t *batchTuner) observe(latency time.Duration, n int) {
+	t.retries = 0
+	if t.adaptive == nil {
+		return
+	}
+	switch {
+	case latency > t.adaptive.TargetLatency:
+		t.size /= 2
+	case latency < t.adaptive.TargetLatency/2 && n == t.size:
+		t.size += t.size/2 + 1
+	}
+	t.clamp()
+}
+
+// retry reports whether a batch that failed with err is retried, halving the size if so.
+func (t *batchTuner) retry(err error) bool {
+	if t.adaptive == nil || t.retries >= t.adaptive.MaxRetries ||
+		!errors.Is(err, ErrTimeout) && !mongo.IsNetworkError(err) {
+		return false
+	}
+	t.retries++
+	t.size /= 2
+	t.clamp()
+	return true
+}
+
+func (t *batchTuner) clamp() {
+	if t.size < t.adaptive.MinBatchSize {
+		t.size = t.adaptive.MinBatchSize
+	}
+	if t.size > t.adaptive.MaxBatchSize {
+		t.size = t.adaptive.MaxBatchSize
+	}
+}
+
+// insertBatch inserts docs with one InsertMany, returning the IDs of the documents and the
+// errors of those that failed by index. err is set when the batch failed as a whole.
+func (c Collection) insertBatch(ctx context.Context, docs []any, ordered bool) (ids []any, failed map[int]error, err error) {
+	op := c.newOp(OpInsertMany)
+	op.Documents = docs
+	err = c.run(ctx, op, func(ctx context.Context, op *Operation) error {
+		op.unordered = !ordered
+		opts := []*options.InsertManyOptions{options.InsertMany().SetOrdered(ordered)}
+		if op.Comment != "" {
+			opts[0].SetComment(op.Comment)
+		}
+		insertRes, err := op.Target.InsertMany(ctx, op.Documents, opts...)
+		if insertRes != nil {
+			ids = insertRes.InsertedIDs
+			op.Result = newInsertManyResult(ids)
+		}
+		return err
+	})
+
+	var bulk mongo.BulkWriteException
+	if err == nil || !errors.As(err, &bulk) || bulk.WriteConcernError != nil || len(bulk.WriteErrors) == 0 {
+		return ids, nil, err
+	}
+	failed = make(map[int]error, len(bulk.WriteErrors))
+	for _, we := range bulk.WriteErrors {
+		failed[we.Index] = TranslateError(mongo.WriteException{WriteErrors: mongo.WriteErrors{we.WriteError}})
+	}
+	if ordered {
+		// The documents after the failed one were not attempted.
+		for i := bulk.WriteErrors[0].Index + 1; i < len(docs); i++ {
+			failed[i] = ErrNotAttempted
+		}
+	}
+	return ids, failed, nil
+}
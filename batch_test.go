@@ -0,0 +1,177 @@
+package mongoboiler
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestInsertBatches(t *testing.T) {
+	small := bson.D{{Key: "a", Value: 1}}
+	big := bson.D{{Key: "a", Value: strings.Repeat("x", 100)}}
+	docs := []any{small, small, small, big, small, big, big}
+
+	encoded, err := encodeDocuments(docs)
+	if err != nil {
+		t.Fatalf("encodeDocuments failed: %v", err)
+	}
+	var batches []insertBatch
+	for start := 0; start < len(docs); start = batches[len(batches)-1].end {
+		batches = append(batches, encoded.next(start, 2, 120))
+	}
+	want := []insertBatch{{0, 2}, {2, 3}, {3, 4}, {4, 5}, {5, 6}, {6, 7}}
+	if !reflect.DeepEqual(batches, want) {
+		t.Fatalf("got batches %v, want %v", batches, want)
+	}
+	if _, err := encodeDocuments([]any{make(chan int)}); err == nil {
+		t.Fatalf("expected an error for an unencodable document")
+	}
+}
+
+func TestInsertManyBatched_Failures(t *testing.T) {
+	dup := func(index int) mongo.BulkWriteError {
+		return mongo.BulkWriteError{WriteError: mongo.WriteError{Index: index, Code: 11000,
+			Message: `E11000 duplicate key error collection: testdb.orders index: _id_ dup key: { _id: 1 }`}}
+	}
+	for _, tc := range []struct {
+		name      string
+		unordered bool
+		errs      map[int][]mongo.BulkWriteError
+		want      []InsertFailure
+	}{
+		{"ordered", false, map[int][]mongo.BulkWriteError{0: {dup(1)}}, []InsertFailure{
+			{Index: 1, Err: ErrDuplicateKey}, {Index: 2, Err: ErrNotAttempted}, {Index: 3, Err: ErrNotAttempted},
+		}},
+		{"unordered", true, map[int][]mongo.BulkWriteError{0: {dup(0), dup(2)}, 1: {dup(0)}}, []InsertFailure{
+			{Index: 0, Err: ErrDuplicateKey}, {Index: 2, Err: ErrDuplicateKey}, {Index: 3, Err: ErrDuplicateKey},
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var batch int
+			coll := newTestCollection(t, "orders", WithMiddleware(func(next Handler) Handler {
+				return func(ctx context.Context, op *Operation) error {
+					defer func() { batch++ }()
+					if errs := tc.errs[batch]; errs != nil {
+						return mongo.BulkWriteException{WriteErrors: errs}
+					}
+					return nil
+				}
+			}))
+			docs := []any{bson.D{{Key: "n", Value: 0}}, bson.D{{Key: "n", Value: 1}}, bson.D{{Key: "n", Value: 2}}, bson.D{{Key: "n", Value: 3}}}
+
+			res, err := coll.InsertManyBatched(context.Background(), docs, InsertBatchOptions{BatchSize: 3, Unordered: tc.unordered})
+			if !errors.Is(err, ErrPartialInsert) {
+				t.Fatalf("expected ErrPartialInsert, got %v", err)
+			}
+			if len(res.Failures) != len(tc.want) {
+				t.Fatalf("got failures %+v, want %+v", res.Failures, tc.want)
+			}
+			for i, f := range res.Failures {
+				if f.Index != tc.want[i].Index || !errors.Is(f.Err, tc.want[i].Err) || !reflect.DeepEqual(f.Document, docs[f.Index]) {
+					t.Fatalf("failure %d is %+v, want %+v", i, f, tc.want[i])
+				}
+			}
+			var dupErr *DuplicateKeyError
+			if !errors.As(res.Failures[0].Err, &dupErr) || dupErr.Index != "_id_" {
+				t.Fatalf("expected a DuplicateKeyError, got %v", res.Failures[0].Err)
+			}
+			if got := res.FailedDocuments(); len(got) != len(tc.want) {
+				t.Fatalf("got %d failed documents", len(got))
+			}
+		})
+	}
+}
+
+func TestInsertManyBatched_StopsOnError(t *testing.T) {
+	var batch int
+	coll := newTestCollection(t, "orders", WithMiddleware(func(next Handler) Handler {
+		return func(ctx context.Context, op *Operation) error {
+			batch++
+			if batch == 2 {
+				return context.Canceled
+			}
+			return nil
+		}
+	}))
+	docs := []any{bson.D{}, bson.D{}, bson.D{}, bson.D{}, bson.D{}}
+
+	res, err := coll.InsertManyBatched(context.Background(), docs, InsertBatchOptions{BatchSize: 2, Unordered: true})
+	if !errors.Is(err, context.Canceled) || batch != 2 {
+		t.Fatalf("expected to stop at the canceled batch, got %v after %d batches", err, batch)
+	}
+	if len(res.Failures) != 3 || !errors.Is(res.Failures[0].Err, context.Canceled) || !errors.Is(res.Failures[2].Err, ErrNotAttempted) {
+		t.Fatalf("unexpected failures %+v", res.Failures)
+	}
+}
+
+func TestBatchTuner(t *testing.T) {
+	tuner := newBatchTuner(InsertBatchOptions{BatchSize: 100, Adaptive: &AdaptiveBatching{MaxBatchSize: 200, TargetLatency: time.Second}})
+	tuner.observe(100*time.Millisecond, 100)
+	if tuner.size != 151 {
+		t.Fatalf("healthy batch grew the size to %d, want 151", tuner.size)
+	}
+	tuner.observe(100*time.Millisecond, 151)
+	if tuner.size != 200 {
+		t.Fatalf("size %d, want it capped at 200", tuner.size)
+	}
+	tuner.observe(2*time.Second, 200)
+	if tuner.size != 100 {
+		t.Fatalf("slow batch shrank the size to %d, want 100", tuner.size)
+	}
+	if !tuner.retry(&classifiedError{ErrTimeout, context.DeadlineExceeded}) || tuner.size != 50 {
+		t.Fatalf("timeout not retried at half the size, size %d", tuner.size)
+	}
+	if tuner.retry(ErrNotFound) {
+		t.Fatalf("expected other errors not to be retried")
+	}
+
+	fixed := newBatchTuner(InsertBatchOptions{BatchSize: 100})
+	fixed.observe(time.Hour, 100)
+	if fixed.size != 100 || fixed.retry(ErrTimeout) {
+		t.Fatalf("expected a fixed size without Adaptive")
+	}
+}
+
+func TestInsertManyBatched_AdaptiveRetry(t *testing.T) {
+	var sizes []int
+	coll := newTestCollection(t, "orders", WithMiddleware(func(next Handler) Handler {
+		return func(ctx context.Context, op *Operation) error {
+			sizes = append(sizes, len(op.Documents))
+			switch len(sizes) {
+			case 1:
+				// The first two documents were inserted before the batch timed out.
+				return context.DeadlineExceeded
+			case 2:
+				return mongo.BulkWriteException{WriteErrors: []mongo.BulkWriteError{{WriteError: mongo.WriteError{Index: 0, Code: 11000,
+					Message: `E11000 duplicate key error collection: testdb.orders index: _id_ dup key: { _id: 0 }`}}}}
+			}
+			return nil
+		}
+	}))
+	docs := make([]any, 4)
+	for i := range docs {
+		docs[i] = bson.D{{Key: "_id", Value: i}}
+	}
+
+	res, err := coll.InsertManyBatched(context.Background(), docs, InsertBatchOptions{
+		BatchSize: 4,
+		Adaptive:  &AdaptiveBatching{MinBatchSize: 1, TargetLatency: time.Hour},
+	})
+	if err != nil || len(res.Failures) != 0 {
+		t.Fatalf("InsertManyBatched = %+v, %v; want every document inserted", res.Failures, err)
+	}
+	// Retried at half the size; ordered inserts stop at the duplicate, so the batch after it
+	// resumes with the second document.
+	if want := []int{4, 2, 2, 1}; !reflect.DeepEqual(sizes, want) {
+		t.Fatalf("sent batches of %v, want %v", sizes, want)
+	}
+	if len(res.InsertedIDs) == 0 || res.InsertedIDs[0].(bson.RawValue).Int32() != 0 {
+		t.Fatalf("inserted IDs %v, want the duplicate's _id first", res.InsertedIDs)
+	}
+}
@@ -0,0 +1,262 @@
+package mongoboiler
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/anurag925/mongoboiler/internal/bsonutil"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Cache stores query results for the read-through cache enabled with WithCache.
+// Implementations must be safe for concurrent use; NewLRUCache and NewRedisCache are provided.
+type Cache interface {
+	// Get returns the value stored under key, ok is false when there is none.
+	Get(ctx context.Context, key string) (value []byte, ok bool, err error)
+	// Set stores value under key for ttl, or without expiry if ttl is zero.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+}
+
+// DefaultCacheMaxEntryBytes is the largest result cached, bigger results are always read from
+// the server.
+const DefaultCacheMaxEntryBytes = 1 << 20
+
+// WithCache caches the results of FindOne, FindMany and FindEach in cache for ttl. Entries are
+// keyed by the database, collection, filter and options after the middleware chain ran, so
+// tenant scoping is respected. Any write through the wrapper to a collection invalidates all
+// its cached results, also for other processes sharing the cache; writes bypassing the wrapper
+// are only picked up once entries expire. Reads in a session, such as in transactions, bypass
+// the cache.
+func WithCache(cache Cache, ttl time.Duration) Option {
+	return func(s *settings) {
+		s.cache = &queryCache{cache: cache, ttl: ttl, maxEntryBytes: DefaultCacheMaxEntryBytes}
+	}
+}
+
+// WithDocumentCaching keys the cached results of reads selecting a single _id by that document
+// instead of by the collection, so writes to other documents leave them valid. Writes through the
+// wrapper whose filter does not select a single _id, like most UpdateMany calls, still invalidate
+// all of them. Combined with a CacheWatcher this suits large collections whose documents are read
+// by ID far more often than they change.
+func WithDocumentCaching() Option {
+	return func(s *settings) {
+		s.cacheDocuments = true
+	}
+}
+
+// queryCache implements generation based invalidation: every collection has a generation token
+// in the cache that is part of the keys of its entries and replaced on writes, orphaning them.
+// With documents set, reads of a single document are keyed by a generation of that document and
+// one of all documents of the collection instead.
+type queryCache struct {
+	cache         Cache
+	ttl           time.Duration
+	maxEntryBytes int
+	documents     bool
+}
+
+func collectionGenerationKey(database, collection string) string {
+	return "mongoboiler:gen:" + database + "." + collection
+}
+
+// documentsGenerationKey is the generation of all documents of a collection, replaced by writes
+// that may have changed any of them.
+func documentsGenerationKey(database, collection string) string {
+	return "mongoboiler:dgen:" + database + "." + collection
+}
+
+// documentGenerationKey is the generation of the document with _id id.
+func documentGenerationKey(database, collection string, id any) (string, bool) {
+	raw, err := bson.Marshal(bson.D{{Key: "id", Value: id}})
+	if err != nil {
+		return "", false
+	}
+	v := bson.Raw(raw).Lookup("id")
+	var part string
+	if bsonutil.IsNumber(v) {
+		// Numbers equal to the server match whatever their type.
+		part = "n" + strconv.FormatFloat(bsonutil.Float(v), 'g', -1, 64)
+	} else {
+		sum := sha256.Sum256(append([]byte{byte(v.Type)}, v.Value...))
+		part = hex.EncodeToString(sum[:])
+	}
+	return documentsGenerationKey(database, collection) + ":" + part, true
+}
+
+// generation returns the generation token stored under key, starting one if there is none.
+func (q *queryCache) generation(ctx context.Context, key string) ([]byte, bool) {
+	gen, ok, err := q.cache.Get(ctx, key)
+	if err != nil {
+		return nil, false
+	}
+	if !ok {
+		// Never key entries by a missing generation: if it was evicted after a write, entries
+		// made before that write would be valid again.
+		gen = []byte(newOperationID())
+		if err := q.cache.Set(ctx, key, gen, 0); err != nil {
+			return nil, false
+		}
+	}
+	return gen, true
+}
+
+// key returns the cache key of a read, ok is false if it cannot be cached. Reads in a session
+// are not: inside a transaction they must see its own writes, and what they read may be
+// uncommitted and must not be served to others.
+func (q *queryCache) key(ctx context.Context, op *Operation, opts any) (string, bool) {
+	if mongo.SessionFromContext(ctx) != nil {
+		return "", false
+	}
+	generations := []string{collectionGenerationKey(op.Database, op.Collection)}
+	if q.documents {
+		if id := filterID(op.Filter); id != nil {
+			if doc, ok := documentGenerationKey(op.Database, op.Collection, id); ok {
+				generations = []string{documentsGenerationKey(op.Database, op.Collection), doc}
+			}
+		}
+	}
+	var gen []byte
+	for _, key := range generations {
+		g, ok := q.generation(ctx, key)
+		if !ok {
+			return "", false
+		}
+		gen = append(append(gen, g...), '/')
+	}
+	filter, err := bson.MarshalExtJSON(bson.D{{Key: "f", Value: op.Filter}}, true, false)
+	if err != nil {
+		return "", false
+	}
+	options, err := json.Marshal(opts)
+	if err != nil {
+		return "", false
+	}
+
+	h := sha256.New()
+	for _, part := range [][]byte{[]byte(op.Database), []byte(op.Collection), gen, []byte(op.Kind), filter, options} {
+		h.Write(part)
+		h.Write([]byte{0})
+	}
+	return "mongoboiler:q:" + hex.EncodeToString(h.Sum(nil)), true
+}
+
+type cachedResult struct {
+	Docs []bson.Raw `bson:"docs"`
+}
+
+func (q *queryCache) get(ctx context.Context, key string) ([]bson.Raw, bool) {
+	data, ok, err := q.cache.Get(ctx, key)
+	if err != nil || !ok {
+		return nil, false
+	}
+	var res cachedResult
+	if err := bson.Unmarshal(data, &res); err != nil {
+		return nil, false
+	}
+	return res.Docs, true
+}
+
+// set stores docs, errors are ignored as the result was read from the server anyway.
+func (q *queryCache) set(ctx context.Context, key string, docs []bson.Raw) {
+	data, err := bson.Marshal(cachedResult{Docs: docs})
+	if err != nil || len(data) > q.maxEntryBytes {
+		return
+	}
+	_ = q.cache.Set(ctx, key, data, q.ttl)
+}
+
+// invalidate starts a new generation for the collection written by op, and with documents for
+// the documents it wrote.
+func (q *queryCache) invalidate(op *Operation) {
+	keys := []string{collectionGenerationKey(op.Database, op.Collection)}
+	if q.documents {
+		keys = append(keys, writtenDocumentKeys(op)...)
+	}
+	q.replaceGenerations(keys)
+}
+
+// invalidateChange starts a new generation for the collection a change was made to by another
+// process, and for the document with _id id unless nil. Document generations are replaced also
+// when documents is not set, as other processes sharing the cache may have it set.
+func (q *queryCache) invalidateChange(database, collection string, id any) {
+	keys := []string{collectionGenerationKey(database, collection), documentsGenerationKey(database, collection)}
+	if id != nil {
+		if doc, ok := documentGenerationKey(database, collection, id); ok {
+			keys[1] = doc
+		}
+	}
+	q.replaceGenerations(keys)
+}
+
+func (q *queryCache) replaceGenerations(keys []string) {
+	// An invalidation must not be skipped because the write's context just expired.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for _, key := range keys {
+		_ = q.cache.Set(ctx, key, []byte(newOperationID()), 0)
+	}
+}
+
+// writtenDocumentKeys returns the generation keys of the documents op wrote, that of all
+// documents of the collection when they are not known.
+func writtenDocumentKeys(op *Operation) []string {
+	var ids []any
+	switch op.Kind {
+	case OpUpdateOne, OpReplaceOne, OpDeleteOne, OpFindOrCreate:
+		if res, ok := op.Result.(UpdateResult); ok && res.DocumentID != nil {
+			ids = []any{res.DocumentID}
+		} else if id := filterID(op.Filter); id != nil {
+			ids = []any{id}
+		}
+	case OpInsertOne, OpInsertMany:
+		// Inserting an _id read before invalidates the miss cached for it.
+		if res, ok := op.Result.(InsertResult); ok && len(res.InsertedIDs) == len(op.Documents) {
+			ids = res.InsertedIDs
+		}
+	}
+	keys := make([]string, 0, len(ids))
+	for _, id := range ids {
+		key, ok := documentGenerationKey(op.Database, op.Collection, id)
+		if !ok {
+			return []string{documentsGenerationKey(op.Database, op.Collection)}
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return []string{documentsGenerationKey(op.Database, op.Collection)}
+	}
+	return keys
+}
+
+// readCache returns the cache of the collection, nil if it has none.
+func (c Collection) readCache() *queryCache {
+	if c.settings == nil || c.settings.cache == nil {
+		return nil
+	}
+	if c.settings.cacheDocuments && !c.settings.cache.documents {
+		q := *c.settings.cache
+		q.documents = true
+		return &q
+	}
+	return c.settings.cache
+}
+
+// rawDocDecoder decodes a cached document with the collection's decode mode.
+type rawDocDecoder struct {
+	ctx  context.Context
+	raw  bson.Raw
+	coll Collection
+}
+
+func (d rawDocDecoder) Decode(v any) error {
+	return d.coll.decode(d.ctx, d.raw, v)
+}
+
+func (d rawDocDecoder) Raw() bson.Raw {
+	return d.raw
+}
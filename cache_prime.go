@@ -0,0 +1,129 @@
+package mongoboiler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrNoCache is returned by PrimeCache for collections without WithCache.
+var ErrNoCache = errors.New("mongoboiler: collection has no cache, use WithCache")
+
+// CacheQuery is a hot query of a CacheManifest. Entries are only hit by reads issued the same way:
+// FindOne with an _id filter and no options for IDs, FindOne or FindMany with the filter and, when
+// Sort or Limit are set, exactly one options.Find().SetSort(sort).SetLimit(limit) for Filter.
+type CacheQuery struct {
+	Collection string `bson:"collection"`
+	// IDs are documents read with FindOne by _id.
+	IDs []any `bson:"ids,omitempty"`
+	// Filter is a query read with FindOne, or with FindMany if Many is set.
+	Filter bson.D `bson:"filter,omitempty"`
+	Many   bool   `bson:"many,omitempty"`
+	Sort   bson.D `bson:"sort,omitempty"`
+	Limit  int64  `bson:"limit,omitempty"`
+}
+
+// CacheManifest lists the queries PrimeCache loads into the cache.
+type CacheManifest []CacheQuery
+
+// LoadCacheManifest reads a manifest from an Extended JSON file:
+//
+//	{"queries": [
+//	  {"collection": "products", "filter": {"featured": true}, "many": true, "sort": {"rank": 1}, "limit": 20},
+//	  {"collection": "settings", "ids": [{"$oid": "64b7f0c2e4b0a1a2b3c4d5e6"}]}
+//	]}
+func LoadCacheManifest(path string) (CacheManifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var holder struct {
+		Queries CacheManifest `bson:"queries"`
+	}
+	if err := bson.UnmarshalExtJSON(data, false, &holder); err != nil {
+		return nil, fmt.Errorf("mongoboiler: cache manifest %s: %w", path, err)
+	}
+	return holder.Queries, nil
+}
+
+// PrimeCache runs the queries of manifest so their results are cached before traffic arrives,
+// e.g. right after a deploy. opts apply to every collection on top of the DB options and must
+// include WithCache unless it is set on the DB. Tenant scoping and other middleware apply as for
+// any read, so ctx must carry what they need. Failing queries do not stop the others; the error
+// reports how many failed.
+func (db *DB) PrimeCache(ctx context.Context, manifest CacheManifest, opts ...Option) error {
+	colls := map[string]*Collection{}
+	failed := 0
+	var first error
+	for _, q := range manifest {
+		coll, ok := colls[q.Collection]
+		if !ok {
+			coll = db.NewCollection(q.Collection, opts...)
+			colls[q.Collection] = coll
+		}
+		if err := coll.PrimeCache(ctx, q); err != nil {
+			failed++
+			if first == nil {
+				first = fmt.Errorf("%s: %w", q.Collection, err)
+			}
+		}
+	}
+	if first != nil {
+		return fmt.Errorf("mongoboiler: priming %d of %d cache queries failed, first: %w", failed, len(manifest), first)
+	}
+	return nil
+}
+
+// PrimeCache runs queries against the collection to load their results into its cache. The
+// Collection field of the queries is ignored.
+func (c Collection) PrimeCache(ctx context.Context, queries ...CacheQuery) error {
+	if c.readCache() == nil {
+		return ErrNoCache
+	}
+	for _, q := range queries {
+		if err := c.prime(ctx, q); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c Collection) prime(ctx context.Context, q CacheQuery) error {
+	var doc bson.Raw
+	for _, id := range q.IDs {
+		if err := c.FindOne(ctx, bson.D{{Key: "_id", Value: id}}, &doc); err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+	}
+	if q.Filter == nil && !q.Many {
+		return nil
+	}
+	filter := q.Filter
+	if filter == nil {
+		filter = bson.D{}
+	}
+	if !q.Many {
+		if err := c.FindOne(ctx, filter, &doc); err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+		return nil
+	}
+
+	var opts []*options.FindOptions
+	if q.Sort != nil || q.Limit != 0 {
+		opt := options.Find()
+		if q.Sort != nil {
+			opt.SetSort(q.Sort)
+		}
+		if q.Limit != 0 {
+			opt.SetLimit(q.Limit)
+		}
+		opts = append(opts, opt)
+	}
+	// Documents are cached as they are read, nothing needs decoding.
+	return c.FindEach(ctx, filter, func(Decoder) error { return nil }, opts...)
+}
@@ -0,0 +1,63 @@
+package mongoboiler
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestLoadCacheManifest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "manifest.json")
+	err := os.WriteFile(path, []byte(`{"queries": [
+		{"collection": "products", "filter": {"featured": true}, "many": true, "sort": {"rank": 1}, "limit": 20},
+		{"collection": "settings", "ids": [{"$oid": "64b7f0c2e4b0a1a2b3c4d5e6"}]}
+	]}`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manifest, err := LoadCacheManifest(path)
+	if err != nil {
+		t.Fatalf("LoadCacheManifest failed: %v", err)
+	}
+	if len(manifest) != 2 {
+		t.Fatalf("expected 2 queries, got %d", len(manifest))
+	}
+	if q := manifest[0]; q.Collection != "products" || !q.Many || q.Limit != 20 || q.Sort[0].Key != "rank" {
+		t.Fatalf("unexpected first query %+v", q)
+	}
+	if id, ok := manifest[1].IDs[0].(primitive.ObjectID); !ok || id.Hex() != "64b7f0c2e4b0a1a2b3c4d5e6" {
+		t.Fatalf("unexpected ids %v", manifest[1].IDs)
+	}
+}
+
+func TestPrimeCache_NoCache(t *testing.T) {
+	coll := newTestCollection(t, "products")
+	if err := coll.PrimeCache(context.Background(), CacheQuery{Filter: bson.D{}}); !errors.Is(err, ErrNoCache) {
+		t.Fatalf("expected ErrNoCache, got %v", err)
+	}
+}
+
+func TestPrimeCache_SameKeysAsReads(t *testing.T) {
+	ctx := context.Background()
+	coll := newTestCollection(t, "products", WithCache(NewLRUCache(100), 0))
+	filter := bson.D{{Key: "featured", Value: true}}
+
+	// An entry cached under the key of the read the application issues is hit by priming, which
+	// would otherwise need a server.
+	op := coll.newOp(OpFind)
+	op.Filter = filter
+	key, _ := coll.readCache().key(ctx, op, []*options.FindOptions{options.Find().SetSort(bson.D{{Key: "rank", Value: 1}}).SetLimit(20)})
+	coll.readCache().set(ctx, key, nil)
+
+	q := CacheQuery{Filter: filter, Many: true, Sort: bson.D{{Key: "rank", Value: 1}}, Limit: 20}
+	if err := coll.PrimeCache(ctx, q); err != nil {
+		t.Fatalf("PrimeCache failed: %v", err)
+	}
+}
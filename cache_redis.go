@@ -0,0 +1,53 @@
+package mongoboiler
+
+import (
+	"context"
+	"time"
+)
+
+// RedisClient is the part of a Redis client RedisCache needs, so this package does not depend
+// on a particular client library. Get returns a nil slice and no error for missing keys.
+// With github.com/redis/go-redis:
+//
+//	type goRedis struct{ *redis.Client }
+//
+//	func (r goRedis) Get(ctx context.Context, key string) ([]byte, error) {
+//		b, err := r.Client.Get(ctx, key).Bytes()
+//		if err == redis.Nil {
+//			return nil, nil
+//		}
+//		return b, err
+//	}
+//
+//	func (r goRedis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+//		return r.Client.Set(ctx, key, value, ttl).Err()
+//	}
+type RedisClient interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+}
+
+// RedisCache is a Cache stored in Redis, shared by every process using the same server.
+type RedisCache struct {
+	client RedisClient
+	prefix string
+}
+
+// NewRedisCache returns a cache storing its entries in client under keys starting with prefix.
+func NewRedisCache(client RedisClient, prefix string) *RedisCache {
+	return &RedisCache{client: client, prefix: prefix}
+}
+
+// Get implements Cache.
+func (c *RedisCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, err := c.client.Get(ctx, c.prefix+key)
+	if err != nil || value == nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+// Set implements Cache.
+func (c *RedisCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return c.client.Set(ctx, c.prefix+key, value, ttl)
+}
@@ -0,0 +1,80 @@
+package mongoboiler
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestLRUCache_Evicts(t *testing.T) {
+	ctx := context.Background()
+	c := NewLRUCache(2)
+	c.Set(ctx, "a", []byte("1"), 0)
+	c.Set(ctx, "b", []byte("2"), 0)
+	c.Get(ctx, "a")
+	c.Set(ctx, "c", []byte("3"), 0)
+
+	if _, ok, _ := c.Get(ctx, "b"); ok {
+		t.Fatalf("expected the least recently used entry to be evicted")
+	}
+	if v, ok, _ := c.Get(ctx, "a"); !ok || string(v) != "1" {
+		t.Fatalf("expected a to be kept, got %q", v)
+	}
+}
+
+// fakeSession is enough of a mongo.Session to be carried by a context.
+type fakeSession struct{ mongo.Session }
+
+func TestWithCache_BypassedInSessions(t *testing.T) {
+	coll := newTestCollection(t, "users", WithCache(NewLRUCache(100), 0))
+	op := coll.newOp(OpFindOne)
+	op.Filter = bson.D{{Key: "name", Value: "ann"}}
+
+	if _, ok := coll.readCache().key(context.Background(), op, []*options.FindOneOptions(nil)); !ok {
+		t.Fatalf("expected reads without a session to be cacheable")
+	}
+	ctx := mongo.NewSessionContext(context.Background(), fakeSession{})
+	if _, ok := coll.readCache().key(ctx, op, []*options.FindOneOptions(nil)); ok {
+		t.Fatalf("expected reads in a session to bypass the cache")
+	}
+}
+
+func TestWithCache_HitAndInvalidate(t *testing.T) {
+	ctx := context.Background()
+	offline := errors.New("offline")
+	coll := newTestCollection(t, "users",
+		WithCache(NewLRUCache(100), 0),
+		WithMiddleware(func(next Handler) Handler {
+			return func(ctx context.Context, op *Operation) error {
+				if op.Kind.IsWrite() {
+					return offline
+				}
+				return next(ctx, op)
+			}
+		}))
+	filter := bson.D{{Key: "name", Value: "ann"}}
+
+	op := coll.newOp(OpFindOne)
+	op.Filter = filter
+	key, ok := coll.readCache().key(ctx, op, []*options.FindOneOptions(nil))
+	if !ok {
+		t.Fatalf("expected the read to be cacheable")
+	}
+	coll.readCache().set(ctx, key, []bson.Raw{mustRaw(t, bson.D{{Key: "name", Value: "ann"}, {Key: "qty", Value: 3}})})
+
+	var item decodeItem
+	if err := coll.FindOne(ctx, filter, &item); err != nil || item.Qty != 3 {
+		t.Fatalf("expected a cache hit, got %+v, %v", item, err)
+	}
+
+	if _, err := coll.DeleteOne(ctx, filter); !errors.Is(err, offline) {
+		t.Fatalf("unexpected delete error %v", err)
+	}
+	if newKey, _ := coll.readCache().key(ctx, op, []*options.FindOneOptions(nil)); newKey == key {
+		t.Fatalf("expected the write to invalidate the cached entries")
+	}
+}
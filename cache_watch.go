@@ -0,0 +1,177 @@
+package mongoboiler
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrCacheDisabled is returned by CacheWatcher.Run for a DB without WithCache.
+var ErrCacheDisabled = errors.New("mongoboiler: no cache configured")
+
+// changeStreamHistoryLost is the server error code for resume tokens that left the oplog.
+const changeStreamHistoryLost = 286
+
+// CacheWatcher invalidates the cache of WithCache on writes made by other processes, or directly
+// through the driver, by following a change stream of the database. This makes the cache safe to
+// use when several services write to the same collections. It needs a replica set or sharded
+// cluster.
+//
+// Cached results are only stale for the time between a remote write and the watcher seeing it,
+// usually milliseconds. When the stream cannot be resumed where it stopped, all cached results
+// of the watched collections are invalidated, as the changes missed are unknown.
+type CacheWatcher struct {
+	// RetryInterval is the wait before reopening a failed change stream, one second by default.
+	RetryInterval time.Duration
+	// Logger receives change stream failures, the standard logger if nil.
+	Logger Logger
+
+	db          *DB
+	collections []string
+}
+
+// NewCacheWatcher returns a watcher for the named collections of db, all of them if none are
+// given.
+func (db *DB) NewCacheWatcher(collections ...string) *CacheWatcher {
+	return &CacheWatcher{db: db, collections: collections}
+}
+
+// changeEvent holds the fields of a change event the watcher needs.
+type changeEvent struct {
+	OperationType string `bson:"operationType"`
+	NS            struct {
+		DB   string `bson:"db"`
+		Coll string `bson:"coll"`
+	} `bson:"ns"`
+	DocumentKey bson.Raw `bson:"documentKey"`
+}
+
+// Run invalidates cached results on changes until ctx is canceled or the DB is closed, returning
+// nil then. Errors of the change stream are logged and it is reopened after the retry interval.
+func (w *CacheWatcher) Run(ctx context.Context) error {
+	if w.db.settings == nil || w.db.settings.cache == nil {
+		return ErrCacheDisabled
+	}
+	ctx, done, err := w.db.conn.workers.start(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+	cache := w.db.settings.cache
+	var token bson.Raw
+	for {
+		err := w.follow(ctx, cache, &token)
+		if ctx.Err() != nil {
+			return nil
+		}
+		var server mongo.ServerError
+		if errors.As(err, &server) && server.HasErrorCode(changeStreamHistoryLost) {
+			token = nil
+		}
+		loggerOrDefault(w.Logger).Printf("mongoboiler: cache watcher: %v", err)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(w.retryInterval()):
+		}
+	}
+}
+
+// follow opens the change stream after token and invalidates cached results for its events,
+// keeping token at the last one seen. Without a token all cached results are invalidated once
+// the stream is open: they may miss changes made before, those made after are in the stream.
+func (w *CacheWatcher) follow(ctx context.Context, cache *queryCache, token *bson.Raw) error {
+	opts := options.ChangeStream()
+	if *token != nil {
+		opts.SetStartAfter(*token)
+	}
+	stream, err := w.db.database().Watch(ctx, w.pipeline(), opts)
+	if err != nil {
+		return err
+	}
+	defer stream.Close(context.Background())
+	if *token == nil {
+		if err := w.invalidateAll(ctx, cache); err != nil {
+			return err
+		}
+	}
+	for stream.Next(ctx) {
+		var event changeEvent
+		if err := stream.Decode(&event); err != nil {
+			return err
+		}
+		w.apply(cache, event)
+		*token = append(bson.Raw(nil), stream.ResumeToken()...)
+	}
+	if err := stream.Err(); err != nil {
+		return err
+	}
+	return errors.New("change stream closed")
+}
+
+// pipeline returns the pipeline of the change stream, selecting the watched collections and
+// only the fields apply reads.
+func (w *CacheWatcher) pipeline() mongo.Pipeline {
+	var pipeline mongo.Pipeline
+	if len(w.collections) > 0 {
+		names := bson.A{}
+		for _, name := range w.names() {
+			names = append(names, name)
+		}
+		pipeline = append(pipeline, bson.D{{Key: "$match", Value: bson.D{{Key: "ns.coll", Value: bson.D{{Key: "$in", Value: names}}}}}})
+	}
+	return append(pipeline, bson.D{{Key: "$project", Value: bson.D{
+		{Key: "operationType", Value: 1},
+		{Key: "ns", Value: 1},
+		{Key: "documentKey", Value: 1},
+	}}})
+}
+
+// apply invalidates the cached results event may have made stale.
+func (w *CacheWatcher) apply(cache *queryCache, event changeEvent) {
+	switch event.OperationType {
+	case "insert", "update", "replace", "delete":
+		var id any
+		if v, err := event.DocumentKey.LookupErr("_id"); err == nil {
+			id = v
+		}
+		cache.invalidateChange(event.NS.DB, event.NS.Coll, id)
+	case "drop", "rename":
+		cache.invalidateChange(event.NS.DB, event.NS.Coll, nil)
+	}
+}
+
+// invalidateAll invalidates the cached results of every watched collection.
+func (w *CacheWatcher) invalidateAll(ctx context.Context, cache *queryCache) error {
+	names := w.names()
+	if len(names) == 0 {
+		var err error
+		if names, err = w.db.database().ListCollectionNames(ctx, bson.D{}); err != nil {
+			return err
+		}
+	}
+	for _, name := range names {
+		cache.invalidateChange(w.db.databaseName(), name, nil)
+	}
+	return nil
+}
+
+// names returns the server side names of the watched collections.
+func (w *CacheWatcher) names() []string {
+	names := make([]string, len(w.collections))
+	for i, name := range w.collections {
+		names[i] = w.db.NewCollection(name).collectionName()
+	}
+	return names
+}
+
+func (w *CacheWatcher) retryInterval() time.Duration {
+	if w.RetryInterval > 0 {
+		return w.RetryInterval
+	}
+	return time.Second
+}
@@ -0,0 +1,92 @@
+package mongoboiler
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// cacheKeyOf returns the current cache key of a FindOne with filter.
+func cacheKeyOf(t *testing.T, coll *Collection, filter bson.D) string {
+	t.Helper()
+	op := coll.newOp(OpFindOne)
+	op.Filter = filter
+	key, ok := coll.readCache().key(context.Background(), op, []*options.FindOneOptions(nil))
+	if !ok {
+		t.Fatalf("expected the read to be cacheable")
+	}
+	return key
+}
+
+func TestWithDocumentCaching(t *testing.T) {
+	ctx := context.Background()
+	coll := newTestCollection(t, "users", WithCache(NewLRUCache(100), 0), WithDocumentCaching(),
+		WithMiddleware(func(next Handler) Handler {
+			return func(ctx context.Context, op *Operation) error {
+				return nil
+			}
+		}))
+	ann, bob := bson.D{{Key: "_id", Value: 1}}, bson.D{{Key: "_id", Value: 2}}
+	byName := bson.D{{Key: "name", Value: "ann"}}
+	annKey, bobKey, nameKey := cacheKeyOf(t, coll, ann), cacheKeyOf(t, coll, bob), cacheKeyOf(t, coll, byName)
+
+	coll.UpdateOne(ctx, bob, bson.D{{Key: "$set", Value: bson.D{{Key: "name", Value: "bobby"}}}})
+	if cacheKeyOf(t, coll, ann) != annKey {
+		t.Fatalf("a write to another document invalidated the entry of ann")
+	}
+	if cacheKeyOf(t, coll, bob) == bobKey || cacheKeyOf(t, coll, byName) == nameKey {
+		t.Fatalf("expected the write to invalidate bob and the queries")
+	}
+
+	annKey = cacheKeyOf(t, coll, ann)
+	coll.UpdateMany(ctx, byName, bson.D{{Key: "$set", Value: bson.D{{Key: "vip", Value: true}}}})
+	if cacheKeyOf(t, coll, ann) == annKey {
+		t.Fatalf("expected a write to unknown documents to invalidate all of them")
+	}
+}
+
+func TestCacheWatcher_Apply(t *testing.T) {
+	coll := newTestCollection(t, "users", WithCache(NewLRUCache(100), 0), WithDocumentCaching())
+	w := coll.db.NewCacheWatcher("users")
+	ann := bson.D{{Key: "_id", Value: 1}}
+	annKey, queryKey := cacheKeyOf(t, coll, ann), cacheKeyOf(t, coll, bson.D{})
+
+	event := changeEvent{OperationType: "update", DocumentKey: mustRaw(t, bson.D{{Key: "_id", Value: int64(2)}})}
+	event.NS.DB, event.NS.Coll = "testdb", "users"
+	w.apply(coll.db.settings.cache, event)
+	if cacheKeyOf(t, coll, ann) != annKey || cacheKeyOf(t, coll, bson.D{}) == queryKey {
+		t.Fatalf("a remote update of another document must only invalidate the queries")
+	}
+
+	// The server matches numbers of any type, so must the invalidation.
+	event.DocumentKey = mustRaw(t, bson.D{{Key: "_id", Value: 1.0}})
+	w.apply(coll.db.settings.cache, event)
+	if cacheKeyOf(t, coll, ann) == annKey {
+		t.Fatalf("expected the remote update of ann to invalidate its entry")
+	}
+
+	annKey = cacheKeyOf(t, coll, ann)
+	w.apply(coll.db.settings.cache, changeEvent{OperationType: "drop", NS: event.NS})
+	if cacheKeyOf(t, coll, ann) == annKey {
+		t.Fatalf("expected a drop to invalidate every document")
+	}
+}
+
+func TestCacheWatcher_Pipeline(t *testing.T) {
+	db := newTestCollection(t, "users", WithCollectionNaming(SnakeCase)).db
+	pipeline := db.NewCacheWatcher("userAccounts").pipeline()
+	match := mustRaw(t, pipeline[0]).Lookup("$match", "ns.coll", "$in").Array().Index(0).Value().StringValue()
+	if match != "user_accounts" {
+		t.Fatalf("watching %s, want the server side name", match)
+	}
+	if len(db.NewCacheWatcher().pipeline()) != 1 {
+		t.Fatalf("expected no $match without collections")
+	}
+
+	if err := newTestCollection(t, "users").db.NewCacheWatcher().Run(context.Background()); !errors.Is(err, ErrCacheDisabled) {
+		t.Fatalf("got %v, want ErrCacheDisabled", err)
+	}
+}
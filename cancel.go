@@ -0,0 +1,174 @@
+package mongoboiler
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// WithServerCancellation kills the server side operations and cursors of calls whose context is
+// canceled or times out while they run. On its own the driver only stops waiting and closes the
+// connection, leaving the server to run an expensive query or aggregation to its end; with it the
+// operation is found in $currentOp by its comment, and killed with killOp, or killCursors once it
+// returned a cursor.
+//
+// Every operation is sent with a comment carrying its ID, appended to the comment of the call or
+// of WithContextComment if any, which needs servers 4.4 or later for all commands; calls setting
+// a comment other than a string fail. The kills are sent in the background after the call
+// returned, so it returns as promptly as without them, are waited for by Close and are counted in
+// DB.ServerCancellations. Listing and killing the operations of other users needs
+// the inprog and killop privileges; without them only those of the connection's user are seen.
+func WithServerCancellation() Option {
+	return func(s *settings) {
+		s.serverCancellation = true
+	}
+}
+
+// ServerCancellationStats counts the server side cancellations of WithServerCancellation since
+// the connection was created.
+type ServerCancellationStats struct {
+	// Canceled counts the calls whose context ended while they ran.
+	Canceled int64
+	// KilledOps and KilledCursors count the operations and cursors killed for them; calls that
+	// the server had finished already need neither.
+	KilledOps     int64
+	KilledCursors int64
+	// Failed counts the calls whose operations could not be looked up or killed.
+	Failed int64
+}
+
+// ServerCancellations returns the server side cancellations of WithServerCancellation, e.g. to
+// export them as metrics.
+func (db *DB) ServerCancellations() ServerCancellationStats {
+	return db.conn.cancellations.stats()
+}
+
+// serverCancellations kills the operations of canceled calls of a connection.
+type serverCancellations struct {
+	mu     sync.Mutex
+	counts ServerCancellationStats
+	// pending tracks the kills in the background.
+	pending sync.WaitGroup
+
+	// Seams for tests, the server when nil.
+	find func(ctx context.Context, client *mongo.Client, comment string) ([]currentOp, error)
+	kill func(ctx context.Context, client *mongo.Client, op currentOp) error
+}
+
+// currentOp is an entry of $currentOp.
+type currentOp struct {
+	Type string `bson:"type"`
+	// OpID is a number, or a "shard:number" string through mongos.
+	OpID   any    `bson:"opid"`
+	NS     string `bson:"ns"`
+	Cursor struct {
+		CursorID int64 `bson:"cursorId"`
+	} `bson:"cursor"`
+}
+
+// handler tags the operations of next with their ID and kills them when ctx ends first.
+func (s *serverCancellations) handler(next Handler) Handler {
+	return func(ctx context.Context, op *Operation) error {
+		marker := "mongoboiler op " + op.ID
+		switch {
+		case op.Comment == "":
+			op.Comment = marker
+		case !strings.Contains(op.Comment, marker):
+			op.Comment += " (" + marker + ")"
+		}
+		err := next(ctx, op)
+		if ctx.Err() == nil || op.Target == nil {
+			return err
+		}
+		s.add(func(c *ServerCancellationStats) { c.Canceled++ })
+		client, comment := op.Target.Database().Client(), op.Comment
+		s.pending.Add(1)
+		go func() {
+			defer s.pending.Done()
+			// ctx ended, the kills get their own deadline.
+			kctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			s.cancel(kctx, client, comment)
+		}()
+		return err
+	}
+}
+
+// cancel kills the operations and cursors sent with comment.
+func (s *serverCancellations) cancel(ctx context.Context, client *mongo.Client, comment string) {
+	find, kill := s.find, s.kill
+	if find == nil {
+		find = findCurrentOps
+	}
+	if kill == nil {
+		kill = killCurrentOp
+	}
+	ops, err := find(ctx, client, comment)
+	if err != nil {
+		s.add(func(c *ServerCancellationStats) { c.Failed++ })
+		return
+	}
+	failed := false
+	for _, op := range ops {
+		if err := kill(ctx, client, op); err != nil {
+			failed = true
+			continue
+		}
+		s.add(func(c *ServerCancellationStats) {
+			if op.Type == "idleCursor" {
+				c.KilledCursors++
+			} else {
+				c.KilledOps++
+			}
+		})
+	}
+	if failed {
+		s.add(func(c *ServerCancellationStats) { c.Failed++ })
+	}
+}
+
+func (s *serverCancellations) add(fn func(c *ServerCancellationStats)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(&s.counts)
+}
+
+func (s *serverCancellations) stats() ServerCancellationStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.counts
+}
+
+// findCurrentOps returns the running operations and idle cursors sent with comment.
+func findCurrentOps(ctx context.Context, client *mongo.Client, comment string) ([]currentOp, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$currentOp", Value: bson.D{{Key: "idleCursors", Value: true}}}},
+		{{Key: "$match", Value: bson.D{{Key: "$or", Value: bson.A{
+			bson.D{{Key: "command.comment", Value: comment}},
+			// getMores and idle cursors show the comment of the command that opened the cursor.
+			bson.D{{Key: "cursor.originatingCommand.comment", Value: comment}},
+		}}}}},
+	}
+	cursor, err := client.Database("admin").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, TranslateError(err)
+	}
+	var ops []currentOp
+	return ops, TranslateError(cursor.All(ctx, &ops))
+}
+
+// killCurrentOp kills op, or its cursor when idle.
+func killCurrentOp(ctx context.Context, client *mongo.Client, op currentOp) error {
+	if op.Type != "idleCursor" {
+		return TranslateError(client.Database("admin").RunCommand(ctx, bson.D{{Key: "killOp", Value: 1}, {Key: "op", Value: op.OpID}}).Err())
+	}
+	database, collection, _ := strings.Cut(op.NS, ".")
+	return TranslateError(client.Database(database).RunCommand(ctx, bson.D{
+		{Key: "killCursors", Value: collection},
+		{Key: "cursors", Value: bson.A{op.Cursor.CursorID}},
+	}).Err())
+}
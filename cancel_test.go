@@ -0,0 +1,135 @@
+package mongoboiler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestServerCancellation_KillsCanceledOperations(t *testing.T) {
+	coll := newTestCollection(t, "orders", WithServerCancellation())
+	s := &coll.db.conn.cancellations
+	var looked []string
+	s.find = func(ctx context.Context, client *mongo.Client, comment string) ([]currentOp, error) {
+		looked = append(looked, comment)
+		return []currentOp{{Type: "op", OpID: int32(12)}, {Type: "idleCursor", NS: "testdb.orders"}}, nil
+	}
+	var killed []currentOp
+	s.kill = func(ctx context.Context, client *mongo.Client, op currentOp) error {
+		killed = append(killed, op)
+		return nil
+	}
+
+	op := coll.newOp(OpFind)
+	err := coll.run(context.Background(), op, func(ctx context.Context, op *Operation) error { return nil })
+	if err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	if want := "mongoboiler op " + op.ID; op.Comment != want {
+		t.Fatalf("expected comment %q, got %q", want, op.Comment)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	op = coll.newOp(OpAggregate)
+	op.Comment = "request 7"
+	_ = coll.run(ctx, op, func(ctx context.Context, op *Operation) error {
+		cancel()
+		return ctx.Err()
+	})
+	s.pending.Wait()
+
+	if want := "request 7 (mongoboiler op " + op.ID + ")"; len(looked) != 1 || looked[0] != want {
+		t.Fatalf("expected the operation to be looked up by %q, got %v", want, looked)
+	}
+	if len(killed) != 2 {
+		t.Fatalf("expected the operation and the cursor to be killed, got %+v", killed)
+	}
+	got := coll.db.ServerCancellations()
+	if got != (ServerCancellationStats{Canceled: 1, KilledOps: 1, KilledCursors: 1}) {
+		t.Fatalf("unexpected stats %+v", got)
+	}
+
+	s.find = func(ctx context.Context, client *mongo.Client, comment string) ([]currentOp, error) {
+		return nil, errors.New("not authorized")
+	}
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	_ = coll.run(ctx, coll.newOp(OpCount), func(ctx context.Context, op *Operation) error { return ctx.Err() })
+	s.pending.Wait()
+	if got := coll.db.ServerCancellations(); got.Canceled != 2 || got.Failed != 1 {
+		t.Fatalf("expected the failed lookup to be counted, got %+v", got)
+	}
+}
+
+func TestServerCancellation_KeepsCallComment(t *testing.T) {
+	coll := newTestCollection(t, "orders", WithServerCancellation())
+	opts := []*options.FindOneOptions{options.FindOne().SetComment("ignored"), nil, options.FindOne().SetComment("request 7")}
+	op := coll.newOp(OpFindOne)
+	foldCallComment(op, opts)
+	var sent *options.FindOneOptions
+	err := coll.run(context.Background(), op, func(ctx context.Context, op *Operation) error {
+		opts, err := sendComment(op, opts, options.FindOne().SetComment(op.Comment))
+		sent = options.MergeFindOneOptions(opts...)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	if want := "request 7 (mongoboiler op " + op.ID + ")"; sent.Comment == nil || *sent.Comment != want {
+		t.Fatalf("expected comment %q to be sent, got %v", want, sent.Comment)
+	}
+	if len(opts) != 3 {
+		t.Fatalf("expected the options of the call to be left alone, got %d", len(opts))
+	}
+
+	op = coll.newOp(OpCount)
+	foldCallComment(op, []*options.CountOptions{options.Count().SetComment("request 8")})
+	if op.Comment != "request 8" {
+		t.Fatalf("expected a *string comment to be folded, got %q", op.Comment)
+	}
+
+	op = coll.newOp(OpDeleteOne)
+	deletes := []*options.DeleteOptions{options.Delete().SetComment(bson.D{{Key: "request", Value: 7}})}
+	foldCallComment(op, deletes)
+	err = coll.run(context.Background(), op, func(ctx context.Context, op *Operation) error {
+		_, err := sendComment(op, deletes, options.Delete().SetComment(op.Comment))
+		return err
+	})
+	if ErrorCodeOf(err) != CodeInvalidArgument {
+		t.Fatalf("expected a document comment to be rejected, got %v", err)
+	}
+	if _, err := sendComment(coll.newOp(OpDeleteOne), deletes, nil); err != nil {
+		t.Fatalf("expected a document comment to be kept without a comment of the operation, got %v", err)
+	}
+}
+
+func TestClose_WaitsForKills(t *testing.T) {
+	coll := newTestCollection(t, "orders", WithServerCancellation())
+	s := &coll.db.conn.cancellations
+	release := make(chan struct{})
+	s.find = func(ctx context.Context, client *mongo.Client, comment string) ([]currentOp, error) {
+		<-release
+		return nil, nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_ = coll.run(ctx, coll.newOp(OpFind), func(ctx context.Context, op *Operation) error { return ctx.Err() })
+
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		_, _ = coll.db.Close(context.Background())
+	}()
+	select {
+	case <-closed:
+		t.Fatal("Close returned before the kill in the background")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	<-closed
+}
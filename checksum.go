@@ -0,0 +1,118 @@
+package mongoboiler
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"hash"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Checksum summarizes the contents of a collection, see Collection.Checksum.
+type Checksum struct {
+	Count int64
+	// Sum is the hex encoded sum of the documents' hashes.
+	Sum string
+}
+
+// Checksum computes a hash over the documents matching filter that does not depend on their
+// order, so the checksums of a collection and its copy are equal if and only if they hold the
+// same data (barring hash collisions). With fields only those dotted paths are compared, e.g.
+// to ignore fields a migration is expected to change; otherwise whole documents are. Field
+// order within documents does not matter, but BSON types do: an int32 and an int64 of the same
+// value differ. Documents are streamed, so memory use does not depend on the collection size.
+func (c Collection) Checksum(ctx context.Context, filter bson.D, fields ...string) (Checksum, error) {
+	var opts []*options.FindOptions
+	if len(fields) > 0 {
+		projection := bson.D{{Key: "_id", Value: 0}}
+		for _, f := range fields {
+			if f == "_id" {
+				projection[0].Value = 1
+				continue
+			}
+			projection = append(projection, bson.E{Key: f, Value: 1})
+		}
+		opts = append(opts, options.Find().SetProjection(projection))
+	}
+
+	var sum checksumSum
+	h := sha256.New()
+	err := c.FindEach(ctx, filter, func(dec Decoder) error {
+		var doc bson.Raw
+		if err := dec.Decode(&doc); err != nil {
+			return err
+		}
+		h.Reset()
+		if len(fields) == 0 {
+			hashValue(h, bson.RawValue{Type: bson.TypeEmbeddedDocument, Value: doc})
+		} else {
+			for _, f := range fields {
+				// Each field is prefixed by its presence so a missing field differs from null.
+				if v, err := doc.LookupErr(strings.Split(f, ".")...); err == nil {
+					h.Write([]byte{1})
+					hashValue(h, v)
+				} else {
+					h.Write([]byte{0})
+				}
+			}
+		}
+		sum.add(h.Sum(nil))
+		return nil
+	}, opts...)
+	if err != nil {
+		return Checksum{}, err
+	}
+	return Checksum{Count: sum.count, Sum: hex.EncodeToString(sum.total[:])}, nil
+}
+
+// checksumSum adds document hashes modulo 2^256, which unlike XOR does not cancel out
+// duplicate documents.
+type checksumSum struct {
+	count int64
+	total [sha256.Size]byte
+}
+
+func (s *checksumSum) add(digest []byte) {
+	s.count++
+	carry := 0
+	for i := len(s.total) - 1; i >= 0; i-- {
+		n := int(s.total[i]) + int(digest[i]) + carry
+		s.total[i], carry = byte(n), n>>8
+	}
+}
+
+// hashValue writes a canonical encoding of v to h, with the keys of documents sorted.
+func hashValue(h hash.Hash, v bson.RawValue) {
+	h.Write([]byte{byte(v.Type)})
+	switch v.Type {
+	case bson.TypeEmbeddedDocument:
+		elems, _ := bson.Raw(v.Value).Elements()
+		sort.Slice(elems, func(i, j int) bool { return elems[i].Key() < elems[j].Key() })
+		writeLength(h, len(elems))
+		for _, e := range elems {
+			writeLength(h, len(e.Key()))
+			h.Write([]byte(e.Key()))
+			hashValue(h, e.Value())
+		}
+	case bson.TypeArray:
+		values, _ := bson.Raw(v.Value).Values()
+		writeLength(h, len(values))
+		for _, value := range values {
+			hashValue(h, value)
+		}
+	default:
+		writeLength(h, len(v.Value))
+		h.Write(v.Value)
+	}
+}
+
+func writeLength(h hash.Hash, n int) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], uint64(n))
+	h.Write(b[:])
+}
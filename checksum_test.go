@@ -0,0 +1,50 @@
+package mongoboiler
+
+import (
+	"crypto/sha256"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func checksumOf(t *testing.T, docs ...bson.D) [sha256.Size]byte {
+	var sum checksumSum
+	for _, d := range docs {
+		h := sha256.New()
+		hashValue(h, bson.RawValue{Type: bson.TypeEmbeddedDocument, Value: mustRaw(t, d)})
+		sum.add(h.Sum(nil))
+	}
+	return sum.total
+}
+
+func TestChecksum_OrderIndependent(t *testing.T) {
+	a := bson.D{{Key: "_id", Value: 1}, {Key: "name", Value: "ann"}, {Key: "tags", Value: bson.A{"x", "y"}}}
+	b := bson.D{{Key: "_id", Value: 2}, {Key: "name", Value: "bob"}}
+	aReordered := bson.D{{Key: "tags", Value: bson.A{"x", "y"}}, {Key: "name", Value: "ann"}, {Key: "_id", Value: 1}}
+
+	if checksumOf(t, a, b) != checksumOf(t, b, aReordered) {
+		t.Fatalf("expected equal checksums regardless of document and field order")
+	}
+	if checksumOf(t, a, a) == checksumOf(t, b, b) {
+		t.Fatalf("duplicates must not cancel out")
+	}
+	changed := bson.D{{Key: "_id", Value: 1}, {Key: "name", Value: "ann"}, {Key: "tags", Value: bson.A{"y", "x"}}}
+	if checksumOf(t, a, b) == checksumOf(t, changed, b) {
+		t.Fatalf("expected array order to matter")
+	}
+	retyped := bson.D{{Key: "_id", Value: int64(2)}, {Key: "name", Value: "bob"}}
+	if checksumOf(t, a, b) == checksumOf(t, a, retyped) {
+		t.Fatalf("expected BSON types to matter")
+	}
+}
+
+func TestChecksumSum_Carries(t *testing.T) {
+	var sum checksumSum
+	max := make([]byte, sha256.Size)
+	max[len(max)-1] = 0xff
+	sum.add(max)
+	sum.add(max)
+	if sum.total[len(sum.total)-1] != 0xfe || sum.total[len(sum.total)-2] != 1 || sum.count != 2 {
+		t.Fatalf("unexpected sum %x", sum.total)
+	}
+}
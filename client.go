@@ -3,126 +3,390 @@ package mongoboiler
 
 import (
 	"context"
+	"errors"
 	"reflect"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// ErrNotSlicePointer is returned when a result argument is not a pointer to a slice.
+var ErrNotSlicePointer = errors.New("mongoboiler: result must be a pointer to a slice")
+
 type DB struct {
-	db     *mongo.Database
-	client *mongo.Client
+	name     string
+	conn     *connection
+	settings *settings
+	models   *modelRegistry
 }
 
-func New(client *mongo.Client, name string) *DB {
-	return &DB{client.Database(name), client}
+func New(client *mongo.Client, name string, opts ...Option) *DB {
+	return &DB{name, newConnection(client), newSettings(nil, SourceDB, opts), newModelRegistry()}
 }
 
+// Disconnect closes the connection right away, failing operations in flight. See Close.
 func (db DB) Disconnect(ctx context.Context) error {
-	return db.client.Disconnect(ctx)
+	return db.conn.disconnect(ctx)
+}
+
+// client returns the current client, it changes when credentials are rotated.
+func (db *DB) client() *mongo.Client {
+	return db.conn.get()
+}
+
+func (db *DB) database() *mongo.Database {
+	return db.client().Database(db.databaseName())
 }
 
 // Collection is the wrapper for Mongo Collection
 type Collection struct {
-	collection *mongo.Collection
+	name     string
+	db       *DB
+	settings *settings
+}
+
+// NewCollection returns the named collection, opts apply on top of the DB options and those
+// set for it with Configure.
+func (wrapper *DB) NewCollection(collectionName string, opts ...Option) *Collection {
+	s := newSettings(wrapper.settings, SourceConfigure, wrapper.settings.collectionOptions[wrapper.name+"."+collectionName])
+	return &Collection{collectionName, wrapper, newSettings(s, SourceCollection, opts)}
 }
 
-func (wrapper *DB) NewCollection(collectionName string) *Collection {
-	return &Collection{wrapper.db.Collection(collectionName)}
+// With returns the collection with opts applied on top of its options, for the calls made
+// through it:
+//
+//	coll.With(WithDecodeMode(DecodeStrict)).FindOne(ctx, filter, &res)
+func (c Collection) With(opts ...Option) *Collection {
+	return &Collection{c.name, c.db, newSettings(c.settings, SourceCall, opts)}
 }
 
-// Drop drops the current Collection (collection)
-func (c Collection) Drop(ctx context.Context) error {
-	return c.collection.Drop(ctx)
+// collection returns the driver collection on the current client.
+func (c Collection) collection() *mongo.Collection {
+	return c.db.database().Collection(c.collectionName(), c.driverOptions()...)
+}
+
+// Drop drops the current Collection (collection).
+// With WithDropProtection it needs ConfirmDrop with the collection's name.
+func (c Collection) Drop(ctx context.Context, confirm ...DropConfirmation) error {
+	if !c.dropConfirmed(confirm) {
+		return ErrDropNotConfirmed
+	}
+	return c.run(ctx, c.newOp(OpDrop), func(ctx context.Context, op *Operation) error {
+		return op.Target.Drop(ctx)
+	})
 }
 
 // FindOne finds first document that satisfies filter and fills res with the un marshaled document.
-func (c Collection) FindOne(ctx context.Context, filter bson.D, res any) error {
-	err := c.collection.FindOne(ctx, filter).Decode(res)
+func (c Collection) FindOne(ctx context.Context, filter bson.D, res any, opts ...*options.FindOneOptions) error {
+	err := c.findOne(ctx, filter, opts, func(ctx context.Context, _ *Operation, raw bson.Raw) error {
+		return c.decode(ctx, raw, res)
+	})
 	if err != nil {
 		return err
 	}
-	return nil
+	return c.populate(ctx, reflect.ValueOf(res))
 }
 
-// FindMany iterates cursor of all docs matching filter and fills res with un marshalled documents.
-func (c Collection) FindMany(ctx context.Context, filter bson.D, res *[]any) error {
-	arrType := reflect.TypeOf(res).Elem()
-	cursor, err := c.collection.Find(ctx, filter)
+// findOne runs a FindOne of filter and passes the document, from the server or the read cache,
+// to decode along with the operation as the middleware left it.
+func (c Collection) findOne(ctx context.Context, filter bson.D, opts []*options.FindOneOptions, decode func(ctx context.Context, op *Operation, raw bson.Raw) error) error {
+	op := c.newOp(OpFindOne)
+	op.Filter = filter
+	foldCallComment(op, opts)
+	return c.run(ctx, op, func(ctx context.Context, op *Operation) error {
+		cache := c.readCache()
+		var key string
+		if cache != nil {
+			var ok bool
+			if key, ok = cache.key(ctx, op, opts); !ok {
+				cache = nil
+			} else if docs, hit := cache.get(ctx, key); hit {
+				if len(docs) == 0 {
+					return mongo.ErrNoDocuments
+				}
+				return decode(ctx, op, docs[0])
+			}
+		}
 
-	for cursor.Next(ctx) {
-		doc := reflect.New(arrType).Interface()
-		err := cursor.Decode(&doc)
+		opts, err := sendComment(op, opts, options.FindOne().SetComment(op.Comment))
 		if err != nil {
 			return err
 		}
-		*res = append(*res, doc)
+		raw, err := op.Target.FindOne(ctx, op.Filter, opts...).DecodeBytes()
+		if cache != nil && (err == nil || errors.Is(err, mongo.ErrNoDocuments)) {
+			var docs []bson.Raw
+			if err == nil {
+				docs = []bson.Raw{raw}
+			}
+			cache.set(ctx, key, docs)
+		}
+		if err != nil {
+			return err
+		}
+		return decode(ctx, op, raw)
+	})
+}
+
+// FindMany iterates cursor of all docs matching filter and fills res with un marshalled documents.
+// res must be a pointer to a slice, e.g. *[]MyStruct; each document is decoded into the slice's element type.
+// Like cursor.All the slice is reset before decoding. See WithQuarantine for skipping
+// documents that fail to decode and WithResultLimits for bounding results.
+func (c Collection) FindMany(ctx context.Context, filter bson.D, res any, opts ...*options.FindOptions) error {
+	sliceVal := reflect.ValueOf(res)
+	if sliceVal.Kind() != reflect.Ptr || sliceVal.Elem().Kind() != reflect.Slice {
+		return ErrNotSlicePointer
 	}
+	sliceVal = sliceVal.Elem()
+	elemType := sliceVal.Type().Elem()
+	sliceVal.Set(sliceVal.Slice(0, 0))
 
-	// un marshall fail
-	if cursor.Err() != nil {
+	guard, opts := c.newResultGuard(opts)
+	err := c.FindEach(ctx, filter, func(dec Decoder) error {
+		if guard != nil {
+			if err := guard.add(dec); err != nil {
+				return err
+			}
+		}
+		elem := reflect.New(elemType)
+		if err := dec.Decode(elem.Interface()); err != nil {
+			if c.settings != nil && c.quarantine(ctx, dec, err) {
+				return nil
+			}
+			return err
+		}
+		sliceVal.Set(reflect.Append(sliceVal, elem.Elem()))
+		return nil
+	}, opts...)
+	if err != nil {
 		return err
 	}
-
-	// Close cursor after we're done with it
-	cursor.Close(ctx)
-	return nil
+	return c.populate(ctx, sliceVal)
 }
 
 // UpdateOne updates single document matching filter and applies update to it.
-// Returns number of documents matched and modified. Should always be either 0 or 1.
-func (c Collection) UpdateOne(ctx context.Context, filter, update bson.D) (int64, int64, error) {
-	updateRes, err := c.collection.UpdateOne(ctx, filter, update)
-	if err != nil {
-		return 0, 0, err
-	}
-	return updateRes.MatchedCount, updateRes.ModifiedCount, nil
+// MatchedCount and ModifiedCount should always be either 0 or 1.
+func (c Collection) UpdateOne(ctx context.Context, filter, update bson.D, opts ...*options.UpdateOptions) (UpdateResult, error) {
+	return c.update(ctx, OpUpdateOne, filter, update, opts)
 }
 
 // UpdateMany updates all documents matching the filter by applying the update query on it.
-// Returns number of documents matched and modified.
-func (c Collection) UpdateMany(ctx context.Context, filter, update bson.D) (int64, int64, error) {
-	updateRes, err := c.collection.UpdateMany(ctx, filter, update)
-	if err != nil {
-		return 0, 0, err
+func (c Collection) UpdateMany(ctx context.Context, filter, update bson.D, opts ...*options.UpdateOptions) (UpdateResult, error) {
+	return c.update(ctx, OpUpdateMany, filter, update, opts)
+}
+
+func (c Collection) update(ctx context.Context, kind OpKind, filter, update bson.D, opts []*options.UpdateOptions) (UpdateResult, error) {
+	if field := c.contentHashField(); field != "" {
+		update = mergeUpdate(update, "$unset", bson.E{Key: field, Value: ""})
 	}
-	return updateRes.MatchedCount, updateRes.ModifiedCount, nil
+	op := c.newOp(kind)
+	op.Filter, op.Update = filter, update
+	foldCallComment(op, opts)
+	for _, o := range opts {
+		if o != nil && o.Upsert != nil {
+			op.Upsert = *o.Upsert
+		}
+	}
+	err := c.run(ctx, op, func(ctx context.Context, op *Operation) error {
+		var updateRes *mongo.UpdateResult
+		opts, err := sendComment(op, opts, options.Update().SetComment(op.Comment))
+		if err != nil {
+			return err
+		}
+		if op.Kind == OpUpdateOne {
+			updateRes, err = op.Target.UpdateOne(ctx, op.Filter, op.Update, opts...)
+		} else {
+			updateRes, err = op.Target.UpdateMany(ctx, op.Filter, op.Update, opts...)
+		}
+		if err != nil {
+			return err
+		}
+		op.Result = newUpdateResult(updateRes, op.Filter)
+		return nil
+	})
+	res, _ := op.Result.(UpdateResult)
+	return res, err
 }
 
-// InsertOne inserts a single struct as a document into the database and returns its ID.
-// Returns inserted ID
-func (c Collection) InsertOne(ctx context.Context, new any) (any, error) {
-	insertRes, err := c.collection.InsertOne(ctx, new)
+// UpdateFields updates single document matching filter with the update built from struct v (see BuildUpdate).
+// When v embeds Versioned the update only applies to the version v was read at, which is then
+// incremented in both the document and v; ErrStaleDocument is returned if no such document matched.
+func (c Collection) UpdateFields(ctx context.Context, filter bson.D, v any, opts ...*options.UpdateOptions) (UpdateResult, error) {
+	update, err := BuildUpdate(v)
 	if err != nil {
-		return "", err
+		return UpdateResult{}, err
 	}
-	return insertRes.InsertedID, nil
-}
 
-// InsertMany takes a slice of structs, inserts them into the database.
-// Returns list of inserted IDs
-func (c Collection) InsertMany(ctx context.Context, new []any) (any, error) {
-	insertRes, err := c.collection.InsertMany(ctx, new)
+	vm, ok := v.(versionedModel)
+	if c.contentHashField() != "" {
+		current := filter
+		if ok {
+			current = versionFilter(filter, vm.versioned())
+		}
+		if res, unchanged, err := c.unchangedUpdate(ctx, current, update); err != nil || unchanged {
+			return res, err
+		}
+	}
+	if !ok {
+		return c.UpdateOne(ctx, filter, update, opts...)
+	}
+	ver := vm.versioned()
+	res, err := c.UpdateOne(ctx, versionFilter(filter, ver), mergeUpdate(update, "$inc", bson.E{Key: versionField, Value: 1}), opts...)
 	if err != nil {
-		return "", err
+		return res, err
+	}
+	if res.MatchedCount == 0 {
+		return res, ErrStaleDocument
 	}
-	return insertRes.InsertedIDs, nil
+	ver.Version++
+	return res, nil
 }
 
-// DeleteOne deletes single document that match the bson.D filter
-func (c Collection) DeleteOne(ctx context.Context, filter bson.D) error {
-	_, err := c.collection.DeleteOne(ctx, filter)
-	if err != nil {
-		return err
+// ReplaceOne replaces single document matching filter with doc.
+// When doc embeds Versioned the replacement only applies to the version doc was read at and the
+// version is incremented; ErrStaleDocument is returned if no such document matched.
+func (c Collection) ReplaceOne(ctx context.Context, filter bson.D, doc any, opts ...*options.ReplaceOptions) (UpdateResult, error) {
+	var ver *Versioned
+	if vm, ok := doc.(versionedModel); ok {
+		ver = vm.versioned()
+		filter = versionFilter(filter, ver)
+	}
+	var sum string
+	if c.contentHashField() != "" {
+		hash, res, unchanged, err := c.unchangedReplace(ctx, filter, doc)
+		if err != nil || unchanged {
+			return res, err
+		}
+		sum = hash
+	}
+	if ver != nil {
+		ver.Version++
 	}
-	return nil
+
+	op := c.newOp(OpReplaceOne)
+	op.Filter, op.Documents = filter, []any{doc}
+	foldCallComment(op, opts)
+	for _, o := range opts {
+		if o != nil && o.Upsert != nil {
+			op.Upsert = *o.Upsert
+		}
+	}
+	err := c.run(ctx, op, func(ctx context.Context, op *Operation) error {
+		opts, err := sendComment(op, opts, options.Replace().SetComment(op.Comment))
+		if err != nil {
+			return err
+		}
+		replacement := op.Documents[0]
+		if sum != "" {
+			var err error
+			if replacement, err = withContentHash(replacement, c.contentHashField(), sum); err != nil {
+				return err
+			}
+		}
+		replaceRes, err := op.Target.ReplaceOne(ctx, op.Filter, replacement, opts...)
+		if err != nil {
+			return err
+		}
+		op.Result = newUpdateResult(replaceRes, op.Filter)
+		return nil
+	})
+	res, _ := op.Result.(UpdateResult)
+
+	if ver != nil && (err != nil || res.MatchedCount == 0) {
+		ver.Version--
+		if err == nil {
+			err = ErrStaleDocument
+		}
+	}
+	return res, err
 }
 
-// DeleteMany deletes all documents that match the bson.D filter
-func (c Collection) DeleteMany(ctx context.Context, filter bson.D) error {
-	_, err := c.collection.DeleteMany(ctx, filter)
-	if err != nil {
+// InsertOne inserts a single struct as a document into the database and returns its ID.
+func (c Collection) InsertOne(ctx context.Context, new any, opts ...*options.InsertOneOptions) (InsertResult, error) {
+	op := c.newOp(OpInsertOne)
+	op.Documents = []any{new}
+	foldCallComment(op, opts)
+	err := c.run(ctx, op, func(ctx context.Context, op *Operation) error {
+		opts, err := sendComment(op, opts, options.InsertOne().SetComment(op.Comment))
+		if err != nil {
+			return err
+		}
+		insertRes, err := op.Target.InsertOne(ctx, op.Documents[0], opts...)
+		if err != nil {
+			return err
+		}
+		op.Result = InsertResult{InsertedID: insertRes.InsertedID, InsertedIDs: []any{insertRes.InsertedID}}
+		return nil
+	})
+	res, _ := op.Result.(InsertResult)
+	return res, err
+}
+
+// InsertMany takes a slice of structs, inserts them into the database.
+// Returns list of inserted IDs in the order of new, when some documents failed to insert the IDs
+// of all of them like the driver.
+func (c Collection) InsertMany(ctx context.Context, new []any, opts ...*options.InsertManyOptions) (InsertResult, error) {
+	op := c.newOp(OpInsertMany)
+	op.Documents = new
+	foldCallComment(op, opts)
+	err := c.run(ctx, op, func(ctx context.Context, op *Operation) error {
+		opts, err := sendComment(op, opts, options.InsertMany().SetComment(op.Comment))
+		if err != nil {
+			return err
+		}
+		for _, o := range opts {
+			if o != nil && o.Ordered != nil {
+				op.unordered = !*o.Ordered
+			}
+		}
+		insertRes, err := op.Target.InsertMany(ctx, op.Documents, opts...)
+		if insertRes != nil {
+			// Also on failures, with the IDs of the documents that failed as well.
+			op.Result = newInsertManyResult(insertRes.InsertedIDs)
+		}
 		return err
-	}
-	return nil
+	})
+	res, _ := op.Result.(InsertResult)
+	return res, err
+}
+
+// DeleteOne deletes single document that match the bson.D filter
+func (c Collection) DeleteOne(ctx context.Context, filter bson.D, opts ...*options.DeleteOptions) (DeleteResult, error) {
+	return c.delete(ctx, OpDeleteOne, filter, opts)
+}
+
+// DeleteMany deletes all documents that match the bson.D filter
+func (c Collection) DeleteMany(ctx context.Context, filter bson.D, opts ...*options.DeleteOptions) (DeleteResult, error) {
+	return c.delete(ctx, OpDeleteMany, filter, opts)
+}
+
+func (c Collection) delete(ctx context.Context, kind OpKind, filter bson.D, opts []*options.DeleteOptions) (DeleteResult, error) {
+	op := c.newOp(kind)
+	op.Filter = filter
+	foldCallComment(op, opts)
+	err := c.run(ctx, op, func(ctx context.Context, op *Operation) error {
+		var deleteRes *mongo.DeleteResult
+		opts, err := sendComment(op, opts, options.Delete().SetComment(op.Comment))
+		if err != nil {
+			return err
+		}
+		if op.Kind == OpDeleteOne {
+			deleteRes, err = op.Target.DeleteOne(ctx, op.Filter, opts...)
+		} else {
+			deleteRes, err = op.Target.DeleteMany(ctx, op.Filter, opts...)
+		}
+		if err != nil {
+			return err
+		}
+		op.Result = DeleteResult{DeletedCount: deleteRes.DeletedCount}
+		return nil
+	})
+	res, _ := op.Result.(DeleteResult)
+	return res, err
+}
+
+// findOneAndUpdate applies the update of op to the document opts pick among those matching its
+// filter and decodes the document into res, for the leases of queues and outboxes.
+func findOneAndUpdate(ctx context.Context, op *Operation, opts *options.FindOneAndUpdateOptions, res any) error {
+	return op.Target.FindOneAndUpdate(ctx, op.Filter, op.Update, opts).Decode(res)
 }
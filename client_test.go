@@ -82,6 +82,15 @@ func TestCollection_FindMany(t *testing.T) {
 	t.Logf("Found document: %+v", result)
 }
 
+func TestCollection_FindManyRejectsNonSlice(t *testing.T) {
+	var result map[string]any
+
+	err := Collection{}.FindMany(context.Background(), bson.D{}, &result)
+	if err != ErrNotSlicePointer {
+		t.Fatalf("expected ErrNotSlicePointer, got %v", err)
+	}
+}
+
 func TestMain(m *testing.M) {
 	// Setup code, if any
 	retCode := m.Run()
@@ -0,0 +1,64 @@
+// Command mongoboilerbench runs a synthetic workload against a collection and prints throughput
+// and latency percentiles. The connection is configured from the environment like
+// mongoboiler.ConnectFromEnv: MONGOBOILER_URI and MONGOBOILER_DATABASE, unless the URI names the
+// database. The collection is dropped afterwards unless -keep is given.
+//
+//	MONGOBOILER_URI=mongodb://localhost:27017 MONGOBOILER_DATABASE=bench \
+//	    mongoboilerbench -duration 1m -concurrency 32 -read-ratio 0.9 -doc-size 2048
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/anurag925/mongoboiler"
+	"github.com/anurag925/mongoboiler/mongoboilerbench"
+)
+
+func main() {
+	var (
+		collection string
+		keep       bool
+		w          mongoboilerbench.Workload
+	)
+	flag.StringVar(&collection, "collection", "mongoboilerbench", "collection to run against")
+	flag.BoolVar(&keep, "keep", false, "keep the collection instead of dropping it afterwards")
+	flag.DurationVar(&w.Duration, "duration", 30*time.Second, "how long to run")
+	flag.Int64Var(&w.Operations, "operations", 0, "stop after this many operations")
+	flag.IntVar(&w.Concurrency, "concurrency", 8, "number of concurrent workers")
+	flag.Float64Var(&w.ReadRatio, "read-ratio", 0.8, "fraction of operations that are reads")
+	flag.IntVar(&w.DocumentSize, "doc-size", 512, "approximate size of inserted documents in bytes")
+	flag.IntVar(&w.Seed, "seed", 1000, "documents inserted before measuring")
+	flag.Parse()
+	if err := w.Validate(); err != nil {
+		fmt.Fprintln(flag.CommandLine.Output(), err)
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	db, err := mongoboiler.ConnectFromEnv(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Disconnect(context.Background())
+
+	coll := db.NewCollection(collection)
+	report, err := mongoboilerbench.Run(ctx, coll, w)
+	if !keep {
+		if err := coll.Drop(context.Background(), mongoboiler.ConfirmDrop(collection)); err != nil {
+			log.Printf("dropping %s: %v", collection, err)
+		}
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(report)
+}
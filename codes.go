@@ -0,0 +1,149 @@
+package mongoboiler
+
+import (
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrorCode is the class of a failure, for HTTP and gRPC layers to map errors to status codes
+// with a single switch:
+//
+//	switch mongoboiler.ErrorCodeOf(err) {
+//	case mongoboiler.CodeNotFound:
+//		return http.StatusNotFound
+//	case mongoboiler.CodeConflict:
+//		return http.StatusConflict
+//	...
+//	}
+//
+// The values are stable and may be exposed to clients or stored.
+type ErrorCode string
+
+// Error codes.
+const (
+	// CodeUnknown is the code of errors of no other class, such as unexpected server errors.
+	CodeUnknown ErrorCode = "unknown"
+	// CodeNotFound is for documents, previews, revisions or jobs that do not exist.
+	CodeNotFound ErrorCode = "not_found"
+	// CodeConflict is for writes colliding with existing data or concurrent writers, such as
+	// duplicate keys, taken values, held locks or write conflicts.
+	CodeConflict ErrorCode = "conflict"
+	// CodePreconditionFailed is for operations refused because the data, the configuration or
+	// the server is not in the state they require, such as stale versions, failed migration
+	// conditions, policies or unsupported features.
+	CodePreconditionFailed ErrorCode = "precondition_failed"
+	// CodeTimeout is for operations that ran out of time.
+	CodeTimeout ErrorCode = "timeout"
+	// CodeCanceled is for operations whose context was canceled.
+	CodeCanceled ErrorCode = "canceled"
+	// CodeUnavailable is for failures to reach the database, such as network errors, elections
+	// or a closed DB; retrying later may succeed.
+	CodeUnavailable ErrorCode = "unavailable"
+	// CodeInvalidArgument is for calls with arguments the wrapper or server rejects, such as
+	// documents failing validation or filters missing the shard key.
+	CodeInvalidArgument ErrorCode = "invalid_argument"
+)
+
+// sentinelCodes are the codes of the sentinel errors, checked in order with errors.Is.
+var sentinelCodes = []struct {
+	err  error
+	code ErrorCode
+}{
+	{ErrNotFound, CodeNotFound},
+	{ErrDeletePreviewNotFound, CodeNotFound},
+	{ErrNoRevision, CodeNotFound},
+	{ErrQueueEmpty, CodeNotFound},
+	{ErrTwoPhaseNoMatch, CodeNotFound},
+
+	{ErrDuplicateKey, CodeConflict},
+	{ErrWriteConflict, CodeConflict},
+	{ErrValueTaken, CodeConflict},
+	{ErrLocked, CodeConflict},
+	{ErrLockLost, CodeConflict},
+	{ErrLeaseLost, CodeConflict},
+	{ErrModelRegistered, CodeConflict},
+
+	{ErrStaleDocument, CodePreconditionFailed},
+	{ErrDeletePreviewChanged, CodePreconditionFailed},
+	{ErrConditionFailed, CodePreconditionFailed},
+	{ErrDropNotConfirmed, CodePreconditionFailed},
+	{ErrOperationDenied, CodePreconditionFailed},
+	{ErrDryRun, CodePreconditionFailed},
+	{ErrUnsupportedFeature, CodePreconditionFailed},
+	{ErrNoCache, CodePreconditionFailed},
+	{ErrCacheDisabled, CodePreconditionFailed},
+	{ErrNoSchema, CodePreconditionFailed},
+	{errTwoPhaseState, CodePreconditionFailed},
+
+	{ErrTimeout, CodeTimeout},
+	{context.DeadlineExceeded, CodeTimeout},
+	{context.Canceled, CodeCanceled},
+
+	{ErrClosed, CodeUnavailable},
+	{ErrEncryptionUnavailable, CodeUnavailable},
+
+	{ErrNotSlicePointer, CodeInvalidArgument},
+	{ErrNotStruct, CodeInvalidArgument},
+	{ErrInvalidTag, CodeInvalidArgument},
+	{ErrInvalidView, CodeInvalidArgument},
+	{ErrShardKeyMissing, CodeInvalidArgument},
+	{ErrEncryptedField, CodeInvalidArgument},
+	{ErrResultTooLarge, CodeInvalidArgument},
+	{ErrNoTenant, CodeInvalidArgument},
+	{ErrTenantSharedCollection, CodeInvalidArgument},
+	{ErrModelNotRegistered, CodeInvalidArgument},
+	{errOpaqueComment, CodeInvalidArgument},
+}
+
+// serverErrorCodes are the server error codes with a class of their own.
+var serverErrorCodes = []struct {
+	server int
+	code   ErrorCode
+}{
+	{121, CodeInvalidArgument}, // DocumentValidationFailure
+	{2, CodeInvalidArgument},   // BadValue
+	{9, CodeInvalidArgument},   // FailedToParse
+	{91, CodeUnavailable},      // ShutdownInProgress
+	{189, CodeUnavailable},     // PrimarySteppedDown
+	{10107, CodeUnavailable},   // NotWritablePrimary
+	{11600, CodeUnavailable},   // InterruptedAtShutdown
+	{11602, CodeUnavailable},   // InterruptedDueToReplStateChange
+	{13435, CodeUnavailable},   // NotPrimaryNoSecondaryOk
+	{13436, CodeUnavailable},   // NotPrimaryOrSecondary
+}
+
+// ErrorCodeOf returns the code of err, CodeUnknown for errors of no known class and the empty
+// code for nil. Errors with a Code method, such as *DuplicateKeyError, report their own; the
+// sentinel errors of the package and the errors of the driver are classified as translated by
+// TranslateError, so it applies to errors returned by the wrapper and the driver alike.
+func ErrorCodeOf(err error) ErrorCode {
+	if err == nil {
+		return ""
+	}
+	var coded interface{ Code() ErrorCode }
+	if errors.As(err, &coded) {
+		if code := coded.Code(); code != CodeUnknown {
+			return code
+		}
+	}
+	err = TranslateError(err)
+	for _, s := range sentinelCodes {
+		if errors.Is(err, s.err) {
+			return s.code
+		}
+	}
+	var server mongo.ServerError
+	if errors.As(err, &server) {
+		for _, s := range serverErrorCodes {
+			if server.HasErrorCode(s.server) {
+				return s.code
+			}
+		}
+	}
+	if mongo.IsNetworkError(err) {
+		return CodeUnavailable
+	}
+	return CodeUnknown
+}
@@ -0,0 +1,43 @@
+package mongoboiler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestErrorCodeOf(t *testing.T) {
+	dupErr := mongo.WriteException{WriteErrors: []mongo.WriteError{{Code: 11000, Message: "E11000 duplicate key error"}}}
+	tests := []struct {
+		err  error
+		want ErrorCode
+	}{
+		{nil, ""},
+		{errors.New("boom"), CodeUnknown},
+		{mongo.ErrNoDocuments, CodeNotFound},
+		{TranslateError(mongo.ErrNoDocuments), CodeNotFound},
+		{fmt.Errorf("loading user: %w", ErrNotFound), CodeNotFound},
+		{dupErr, CodeConflict},
+		{TranslateError(dupErr), CodeConflict},
+		{mongo.CommandError{Code: writeConflict}, CodeConflict},
+		{ErrStaleDocument, CodePreconditionFailed},
+		{&UnsupportedFeatureError{Feature: FeatureRankFusion, ServerVersion: "7.0.2"}, CodePreconditionFailed},
+		{&MigrationError{Migration: "m1", Phase: "pre", Condition: "empty", Err: ErrConditionFailed}, CodePreconditionFailed},
+		{fmt.Errorf("find: %w", context.DeadlineExceeded), CodeTimeout},
+		{context.Canceled, CodeCanceled},
+		{ErrClosed, CodeUnavailable},
+		{mongo.CommandError{Code: 10107, Message: "not primary"}, CodeUnavailable},
+		{&ShardKeyError{Missing: []string{"region"}}, CodeInvalidArgument},
+		{mongo.WriteException{WriteErrors: []mongo.WriteError{{Code: 121, Message: "Document failed validation"}}}, CodeInvalidArgument},
+		{&OperationError{ID: "1", Err: TranslateError(dupErr)}, CodeConflict},
+		{&ParallelError{Errors: []error{errors.New("boom"), ErrTimeout}}, CodeTimeout},
+	}
+	for _, tt := range tests {
+		if got := ErrorCodeOf(tt.err); got != tt.want {
+			t.Errorf("ErrorCodeOf(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,291 @@
+package mongoboiler
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ColdSuffix is appended to a collection's name to get the collection its cold fields are stored
+// in, see WithColdFields.
+const ColdSuffix = "_cold"
+
+// WithColdFields splits rarely read, large top level fields, such as descriptions, attachments
+// or audit trails, off the documents of the collection into the collection named after it with
+// ColdSuffix, under the same _id. The documents read by queries stay small, FindOneFull joins
+// the cold fields back in. Writes are routed by field:
+//
+//   - inserts store the cold fields first, so an insert failing halfway leaves at most cold
+//     fields without a document, which are never read; an _id is generated for documents without
+//     one
+//   - updates apply their operators on cold fields, e.g. $set of "attachments.0.name", to the
+//     cold fields of the documents the filter matched, creating them when missing
+//   - replacements replace the cold fields, deletes delete them
+//
+// Filters only see the hot fields; FindOrCreate, Aggregate and writes through the driver are not
+// split. The documents matched by multi-document updates and deletes are held in memory until
+// the write succeeded; documents starting to match during the write miss their cold changes.
+// Register it after middleware that rewrites operations, such as WithTenancy.
+func WithColdFields(fields ...string) Option {
+	s := &coldSplitter{fields: append([]string(nil), fields...), ids: matchingIDs, write: writeCold}
+	return WithMiddleware(s.middleware)
+}
+
+type coldSplitter struct {
+	fields []string
+	ids    func(ctx context.Context, target *mongo.Collection, filter bson.D, limit int64) ([]any, error)
+	write  func(ctx context.Context, cold *mongo.Collection, models []mongo.WriteModel) error
+}
+
+func (s *coldSplitter) middleware(next Handler) Handler {
+	return func(ctx context.Context, op *Operation) error {
+		if op.dryRun {
+			return next(ctx, op)
+		}
+		cold := coldOf(op)
+		switch op.Kind {
+		case OpInsertOne, OpInsertMany:
+			models, err := s.splitInserts(op)
+			if err != nil {
+				return err
+			}
+			if err := s.store(ctx, cold, models); err != nil {
+				return err
+			}
+			return next(ctx, op)
+		case OpReplaceOne:
+			doc, err := toDocument(op.Documents[0])
+			if err != nil {
+				return err
+			}
+			hot, fields := s.split(doc)
+			op.Documents = []any{hot}
+			return s.afterWrite(ctx, op, next, cold, 1, func(id any) mongo.WriteModel {
+				if len(fields) == 0 {
+					return mongo.NewDeleteOneModel().SetFilter(bson.D{{Key: "_id", Value: id}})
+				}
+				return mongo.NewReplaceOneModel().SetFilter(bson.D{{Key: "_id", Value: id}}).
+					SetReplacement(fields).SetUpsert(true)
+			})
+		case OpUpdateOne, OpUpdateMany:
+			hot, update := s.splitUpdate(op.Update)
+			if len(update) == 0 {
+				return next(ctx, op)
+			}
+			op.Update = hot
+			var limit int64
+			if op.Kind == OpUpdateOne {
+				limit = 1
+			}
+			return s.afterWrite(ctx, op, next, cold, limit, func(id any) mongo.WriteModel {
+				return mongo.NewUpdateOneModel().SetFilter(bson.D{{Key: "_id", Value: id}}).SetUpdate(update).SetUpsert(true)
+			})
+		case OpDeleteOne, OpDeleteMany:
+			var limit int64
+			if op.Kind == OpDeleteOne {
+				limit = 1
+			}
+			return s.afterWrite(ctx, op, next, cold, limit, func(id any) mongo.WriteModel {
+				return mongo.NewDeleteOneModel().SetFilter(bson.D{{Key: "_id", Value: id}})
+			})
+		}
+		return next(ctx, op)
+	}
+}
+
+// splitInserts moves the cold fields off the documents of op, returning the writes storing them.
+func (s *coldSplitter) splitInserts(op *Operation) ([]mongo.WriteModel, error) {
+	var models []mongo.WriteModel
+	docs := append([]any(nil), op.Documents...)
+	for i, doc := range docs {
+		d, err := toDocument(doc)
+		if err != nil {
+			return nil, err
+		}
+		hot, fields := s.split(d)
+		if len(fields) == 0 {
+			continue
+		}
+		id, ok := documentID(hot)
+		if !ok {
+			id = primitive.NewObjectID()
+			hot = append(bson.D{{Key: "_id", Value: id}}, hot...)
+		}
+		docs[i] = hot
+		models = append(models, mongo.NewReplaceOneModel().SetFilter(bson.D{{Key: "_id", Value: id}}).
+			SetReplacement(fields).SetUpsert(true))
+	}
+	op.Documents = docs
+	return models, nil
+}
+
+// afterWrite runs next and then the writes model returns for the documents op matched, at most
+// limit unless zero, and the one it upserted. The _id are read before the write, so writes of a
+// single document are pinned to the one read, another could start matching in between.
+func (s *coldSplitter) afterWrite(ctx context.Context, op *Operation, next Handler, cold *mongo.Collection, limit int64, model func(id any) mongo.WriteModel) error {
+	ids, err := s.ids(ctx, op.Target, nonNilFilter(op.Filter), limit)
+	if err != nil {
+		return err
+	}
+	if limit == 1 && len(ids) == 1 {
+		op.Filter = bson.D{{Key: "$and", Value: bson.A{nonNilFilter(op.Filter), bson.D{{Key: "_id", Value: ids[0]}}}}}
+	}
+	if err := next(ctx, op); err != nil {
+		return err
+	}
+	switch res := op.Result.(type) {
+	case UpdateResult:
+		if res.MatchedCount == 0 {
+			// The document read stopped matching before the write.
+			ids = nil
+		}
+		if res.UpsertedID != nil {
+			ids = append(ids, res.UpsertedID)
+		}
+	case DeleteResult:
+		if res.DeletedCount == 0 {
+			ids = nil
+		}
+	}
+	models := make([]mongo.WriteModel, len(ids))
+	for i, id := range ids {
+		models[i] = model(id)
+	}
+	return s.store(ctx, cold, models)
+}
+
+func (s *coldSplitter) store(ctx context.Context, cold *mongo.Collection, models []mongo.WriteModel) error {
+	if len(models) == 0 {
+		return nil
+	}
+	if err := s.write(ctx, cold, models); err != nil {
+		return fmt.Errorf("mongoboiler: writing cold fields: %w", err)
+	}
+	return nil
+}
+
+// split returns the hot fields of doc and its cold ones.
+func (s *coldSplitter) split(doc bson.D) (hot, cold bson.D) {
+	for _, e := range doc {
+		if s.isCold(e.Key) {
+			cold = append(cold, e)
+		} else {
+			hot = append(hot, e)
+		}
+	}
+	return hot, cold
+}
+
+// splitUpdate returns the operators of update on hot fields and those on cold ones. An update
+// changing only cold fields gets an $unset of one of them for the hot document, which matches
+// the same documents without changing them.
+func (s *coldSplitter) splitUpdate(update bson.D) (hot, cold bson.D) {
+	for _, e := range update {
+		fields, err := toDocument(e.Value)
+		if !strings.HasPrefix(e.Key, "$") || err != nil {
+			hot = append(hot, e)
+			continue
+		}
+		h, c := s.split(fields)
+		if len(h) > 0 {
+			hot = append(hot, bson.E{Key: e.Key, Value: h})
+		}
+		if len(c) > 0 {
+			cold = append(cold, bson.E{Key: e.Key, Value: c})
+		}
+	}
+	if len(hot) == 0 && len(cold) > 0 {
+		hot = bson.D{{Key: "$unset", Value: bson.D{{Key: s.fields[0], Value: ""}}}}
+	}
+	return hot, cold
+}
+
+// isCold reports whether key is, or is a path into, a cold field.
+func (s *coldSplitter) isCold(key string) bool {
+	field, _, _ := strings.Cut(key, ".")
+	return field != "_id" && containsString(s.fields, field)
+}
+
+func documentID(doc bson.D) (any, bool) {
+	for _, e := range doc {
+		if e.Key == "_id" {
+			return e.Value, true
+		}
+	}
+	return nil, false
+}
+
+// matchingIDs returns the _id of the documents of target matching filter, at most limit
+// unless zero.
+func matchingIDs(ctx context.Context, target *mongo.Collection, filter bson.D, limit int64) ([]any, error) {
+	cursor, err := target.Find(ctx, filter, options.Find().SetLimit(limit).SetProjection(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+	var ids []any
+	for cursor.Next(ctx) {
+		ids = append(ids, cursor.Current.Lookup("_id"))
+	}
+	return ids, cursor.Err()
+}
+
+func writeCold(ctx context.Context, cold *mongo.Collection, models []mongo.WriteModel) error {
+	_, err := cold.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	return err
+}
+
+// coldOf returns the collection the cold fields of the documents op targets are stored in.
+func coldOf(op *Operation) *mongo.Collection {
+	return op.Target.Database().Collection(op.Collection + ColdSuffix)
+}
+
+// FindOneFull is FindOne with the cold fields of the document, see WithColdFields, joined in.
+func (c Collection) FindOneFull(ctx context.Context, filter bson.D, res any, opts ...*options.FindOneOptions) error {
+	// The hot document is joined as read, so it is decoded, and its hooks run, once. The cold
+	// fields are read next to the collection it was read from, which middleware such as
+	// WithTenancy may have changed.
+	err := c.findOne(ctx, filter, opts, func(ctx context.Context, op *Operation, hot bson.Raw) error {
+		cold, err := coldOf(op).FindOne(ctx, bson.D{{Key: "_id", Value: hot.Lookup("_id")}}).DecodeBytes()
+		if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+			return err
+		}
+		full, err := joinCold(hot, cold)
+		if err != nil {
+			return err
+		}
+		return c.decode(ctx, full, res)
+	})
+	if err != nil {
+		return err
+	}
+	return c.populate(ctx, reflect.ValueOf(res))
+}
+
+// joinCold returns hot with the fields of cold but its _id appended, hot if cold is nil.
+func joinCold(hot, cold bson.Raw) (bson.Raw, error) {
+	if cold == nil {
+		return hot, nil
+	}
+	fields, err := cold.Elements()
+	if err != nil {
+		return nil, err
+	}
+	full := append(bson.Raw(nil), hot[:len(hot)-1]...)
+	for _, e := range fields {
+		if e.Key() != "_id" {
+			full = append(full, e...)
+		}
+	}
+	full = append(full, 0)
+	binary.LittleEndian.PutUint32(full, uint32(len(full)))
+	return full, nil
+}
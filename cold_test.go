@@ -0,0 +1,170 @@
+package mongoboiler
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestColdFields_RoutesWrites(t *testing.T) {
+	var written []mongo.WriteModel
+	var writtenTo string
+	s := &coldSplitter{
+		fields: []string{"description"},
+		ids: func(ctx context.Context, target *mongo.Collection, filter bson.D, limit int64) ([]any, error) {
+			return []any{7}, nil
+		},
+		write: func(ctx context.Context, cold *mongo.Collection, models []mongo.WriteModel) error {
+			written, writtenTo = append(written, models...), cold.Name()
+			return nil
+		},
+	}
+	coll := newTestCollection(t, "products", WithMiddleware(s.middleware))
+	var sent *Operation
+	record := func(ctx context.Context, op *Operation) error {
+		sent = op
+		return nil
+	}
+
+	op := coll.newOp(OpInsertOne)
+	op.Documents = []any{bson.D{{Key: "name", Value: "lamp"}, {Key: "description", Value: "a long text"}}}
+	if err := coll.run(context.Background(), op, record); err != nil {
+		t.Fatalf("insert failed: %v", err)
+	}
+	hot := sent.Documents[0].(bson.D)
+	if len(hot) != 2 || hot[0].Key != "_id" || hot[1].Key != "name" {
+		t.Fatalf("inserted %v, want the hot fields with a generated _id", hot)
+	}
+	if len(written) != 1 || writtenTo != "products_cold" {
+		t.Fatalf("expected the cold fields written to products_cold, got %d writes to %s", len(written), writtenTo)
+	}
+	cold := written[0].(*mongo.ReplaceOneModel)
+	if cold.Filter.(bson.D)[0].Value != hot[0].Value || cold.Replacement.(bson.D)[0].Key != "description" {
+		t.Fatalf("unexpected cold write %+v", cold)
+	}
+
+	written = nil
+	op = coll.newOp(OpUpdateOne)
+	op.Update = bson.D{{Key: "$set", Value: bson.D{{Key: "name", Value: "desk lamp"}, {Key: "description.en", Value: "text"}}}}
+	if err := coll.run(context.Background(), op, record); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	if got := sent.Update[0].Value.(bson.D); len(got) != 1 || got[0].Key != "name" {
+		t.Fatalf("hot update %v, want only the name", sent.Update)
+	}
+	update := written[0].(*mongo.UpdateOneModel)
+	if update.Filter.(bson.D)[0].Value != 7 || update.Update.(bson.D)[0].Value.(bson.D)[0].Key != "description.en" || !*update.Upsert {
+		t.Fatalf("unexpected cold update %+v", update)
+	}
+
+	// An update of only cold fields still matches the hot documents.
+	op = coll.newOp(OpUpdateMany)
+	op.Update = bson.D{{Key: "$unset", Value: bson.D{{Key: "description", Value: ""}}}}
+	if err := coll.run(context.Background(), op, record); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	if len(sent.Update) != 1 || sent.Update[0].Key != "$unset" {
+		t.Fatalf("hot update %v, want a no-op $unset", sent.Update)
+	}
+
+	written = nil
+	op = coll.newOp(OpDeleteOne)
+	if err := coll.run(context.Background(), op, record); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if _, ok := written[0].(*mongo.DeleteOneModel); !ok || len(written) != 1 {
+		t.Fatalf("expected the cold fields of the deleted document deleted, got %v", written)
+	}
+}
+
+func TestColdFields_PinsSingleWrites(t *testing.T) {
+	written := 0
+	s := &coldSplitter{
+		fields: []string{"description"},
+		ids: func(ctx context.Context, target *mongo.Collection, filter bson.D, limit int64) ([]any, error) {
+			return []any{7}, nil
+		},
+		write: func(ctx context.Context, cold *mongo.Collection, models []mongo.WriteModel) error {
+			written += len(models)
+			return nil
+		},
+	}
+	coll := newTestCollection(t, "products", WithMiddleware(s.middleware))
+	filter := bson.D{{Key: "sku", Value: "lamp"}}
+	var sent bson.D
+	matched := int64(1)
+	record := func(ctx context.Context, op *Operation) error {
+		sent = op.Filter
+		op.Result = UpdateResult{MatchedCount: matched}
+		return nil
+	}
+
+	op := coll.newOp(OpUpdateOne)
+	op.Filter, op.Update = filter, bson.D{{Key: "$set", Value: bson.D{{Key: "description", Value: "text"}}}}
+	if err := coll.run(context.Background(), op, record); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	want := bson.D{{Key: "$and", Value: bson.A{filter, bson.D{{Key: "_id", Value: 7}}}}}
+	if !reflect.DeepEqual(sent, want) || written != 1 {
+		t.Fatalf("expected the update pinned to the document read, got %v and %d cold writes", sent, written)
+	}
+
+	// The document stopped matching before the update, its cold fields stay.
+	matched = 0
+	op = coll.newOp(OpUpdateOne)
+	op.Filter, op.Update = filter, bson.D{{Key: "$set", Value: bson.D{{Key: "description", Value: "text"}}}}
+	if err := coll.run(context.Background(), op, record); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	if written != 1 {
+		t.Fatalf("expected no cold write for an update matching nothing, got %d", written)
+	}
+}
+
+func TestJoinCold(t *testing.T) {
+	hot := mustRaw(t, bson.D{{Key: "_id", Value: 7}, {Key: "name", Value: "lamp"}})
+	full, err := joinCold(hot, mustRaw(t, bson.D{{Key: "_id", Value: 7}, {Key: "description", Value: "text"}}))
+	if err != nil || full.Validate() != nil {
+		t.Fatalf("joinCold = %v, %v", full, err)
+	}
+	var doc bson.D
+	if err := bson.Unmarshal(full, &doc); err != nil || len(doc) != 3 || doc[2].Key != "description" {
+		t.Fatalf("joined %v, %v", doc, err)
+	}
+	if got, _ := joinCold(hot, nil); !bytes.Equal(got, hot) {
+		t.Fatalf("expected the hot document without cold fields")
+	}
+}
+
+func TestColdFields_JoinFollowsTarget(t *testing.T) {
+	coll := newTestCollection(t, "posts", WithCache(NewLRUCache(10), 0),
+		WithTenancy(Tenancy{Strategy: TenantByCollectionPrefix}))
+	ctx := ContextWithTenant(context.Background(), "acme")
+	filter := bson.D{{Key: "_id", Value: 1}}
+
+	// Serve the hot document from the cache, keyed on the collection tenancy resolves.
+	op := coll.newOp(OpFindOne)
+	op.Collection, op.Filter = "acme_posts", filter
+	key, ok := coll.readCache().key(ctx, op, []*options.FindOneOptions(nil))
+	if !ok {
+		t.Fatalf("expected the read to be cacheable")
+	}
+	coll.readCache().set(ctx, key, []bson.Raw{mustRaw(t, bson.D{{Key: "_id", Value: 1}})})
+
+	var cold string
+	err := coll.findOne(ctx, filter, nil, func(ctx context.Context, op *Operation, hot bson.Raw) error {
+		cold = coldOf(op).Name()
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("findOne failed: %v", err)
+	}
+	if cold != "acme_posts_cold" {
+		t.Fatalf("expected the cold fields in acme_posts_cold, got %s", cold)
+	}
+}
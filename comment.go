@@ -0,0 +1,160 @@
+package mongoboiler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type commentKey struct{}
+
+// ContextWithComment makes the wrapper calls made with ctx send comment to the server when the
+// collection has WithContextComment, e.g. the ID of the request being served.
+func ContextWithComment(ctx context.Context, comment string) context.Context {
+	return context.WithValue(ctx, commentKey{}, comment)
+}
+
+// CommentFromContext returns the comment set with ContextWithComment.
+func CommentFromContext(ctx context.Context) (string, bool) {
+	comment, ok := ctx.Value(commentKey{}).(string)
+	return comment, ok && comment != ""
+}
+
+// ContextCommentOption configures WithContextComment.
+type ContextCommentOption func(*contextComment)
+
+// CommentKey also takes the comment from the context value stored under key, when
+// ContextWithComment was not used, so request IDs set by other libraries are picked up without
+// copying them. Values must be strings or fmt.Stringers. Keys are tried in the order given.
+func CommentKey(key any) ContextCommentOption {
+	return func(c *contextComment) {
+		c.keys = append(c.keys, key)
+	}
+}
+
+// CommentInFilter also adds the comment to filters as $comment, so it shows in the logs of
+// servers and tools that drop the comment option of some commands. Reads are then cached per
+// comment, leave it off with WithCache when comments differ by request.
+func CommentInFilter() ContextCommentOption {
+	return func(c *contextComment) {
+		c.inFilter = true
+	}
+}
+
+type contextComment struct {
+	keys     []any
+	inFilter bool
+}
+
+// WithContextComment sends the comment carried by the context of a call to the server with each
+// of its operations, so they are attributable in the profiler, the server log, currentOp and Atlas
+// query insights:
+//
+//	db := mongoboiler.New(client, "shop", mongoboiler.WithContextComment(
+//		mongoboiler.CommentKey(middleware.RequestIDKey)))
+//
+// A comment set by the call itself takes precedence. With WithCorrelation the operation ID is
+// appended to it.
+func WithContextComment(opts ...ContextCommentOption) Option {
+	c := &contextComment{}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return func(s *settings) {
+		s.contextComment = c
+	}
+}
+
+// comment returns the comment ctx carries.
+func (c *contextComment) comment(ctx context.Context) (string, bool) {
+	if comment, ok := CommentFromContext(ctx); ok {
+		return comment, true
+	}
+	for _, key := range c.keys {
+		switch v := ctx.Value(key).(type) {
+		case string:
+			if v != "" {
+				return v, true
+			}
+		case fmt.Stringer:
+			if s := v.String(); s != "" {
+				return s, true
+			}
+		}
+	}
+	return "", false
+}
+
+// applyContextComment sets the comment of op from ctx, unless the call set its own.
+func (c Collection) applyContextComment(ctx context.Context, op *Operation) {
+	if c.settings == nil || c.settings.contextComment == nil || op.Comment != "" {
+		return
+	}
+	if comment, ok := c.settings.contextComment.comment(ctx); ok {
+		op.Comment = comment
+	}
+}
+
+// errOpaqueComment is returned for calls setting a comment other than a string while the
+// operation is sent with a comment of its own, which could not carry it.
+var errOpaqueComment = errors.New("mongoboiler: the comment of the call is not a string")
+
+// foldCallComment sets the comment of op to the one set by opts, the options of the call, the
+// last one winning as when the driver merges them. WithCorrelation and WithServerCancellation
+// then extend it instead of being overridden by it.
+func foldCallComment[T any](op *Operation, opts []*T) {
+	for i := len(opts) - 1; i >= 0; i-- {
+		if opts[i] == nil {
+			continue
+		}
+		f := reflect.ValueOf(opts[i]).Elem().FieldByName("Comment")
+		if !f.IsValid() || f.IsNil() {
+			continue
+		}
+		switch v := f.Interface().(type) {
+		case *string:
+			op.Comment = *v
+		case string:
+			op.Comment = v
+		default:
+			op.opaqueComment = true
+		}
+		return
+	}
+}
+
+// sendComment returns opts followed by comment, the options setting the comment of op, so it
+// overrides the one of the call it was folded from. It returns opts when op has no comment.
+func sendComment[T any](op *Operation, opts []*T, comment *T) ([]*T, error) {
+	if op.Comment == "" {
+		return opts, nil
+	}
+	if op.opaqueComment {
+		return nil, errOpaqueComment
+	}
+	// Copied, opts belong to the caller and fn may be retried.
+	return append(opts[:len(opts):len(opts)], comment), nil
+}
+
+// filterCommentHandler adds the comment of op to its filter while next executes it, after
+// middleware rewriting the filter, so $comment stays at its top level.
+func filterCommentHandler(next Handler) Handler {
+	return func(ctx context.Context, op *Operation) error {
+		switch op.Kind {
+		case OpFindOne, OpFind, OpCount, OpUpdateOne, OpUpdateMany, OpReplaceOne, OpFindOrCreate, OpDeleteOne, OpDeleteMany:
+		default:
+			return next(ctx, op)
+		}
+		if op.Comment == "" {
+			return next(ctx, op)
+		}
+		filter := op.Filter
+		op.Filter = append(append(bson.D(nil), filter...), bson.E{Key: "$comment", Value: op.Comment})
+		err := next(ctx, op)
+		op.Filter = filter
+		return err
+	}
+}
@@ -0,0 +1,74 @@
+package mongoboiler
+
+import (
+	"context"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type requestIDKey struct{}
+
+type requestID string
+
+func (id requestID) String() string { return "req-" + string(id) }
+
+func TestContextComment(t *testing.T) {
+	var comments []string
+	coll := newTestCollection(t, "orders", WithContextComment(CommentKey(requestIDKey{})),
+		WithMiddleware(func(next Handler) Handler {
+			return func(ctx context.Context, op *Operation) error {
+				comments = append(comments, op.Comment)
+				return nil
+			}
+		}))
+
+	ctx := context.Background()
+	coll.DeleteOne(ctx, bson.D{})
+	coll.DeleteOne(context.WithValue(ctx, requestIDKey{}, requestID("42")), bson.D{})
+	coll.DeleteOne(ContextWithComment(context.WithValue(ctx, requestIDKey{}, "ignored"), "nightly report"), bson.D{})
+	want := []string{"", "req-42", "nightly report"}
+	for i := range want {
+		if comments[i] != want[i] {
+			t.Fatalf("comments %q, want %q", comments, want)
+		}
+	}
+}
+
+func TestContextComment_Correlation(t *testing.T) {
+	var op *Operation
+	coll := newTestCollection(t, "orders", WithContextComment(), WithCorrelation(nil),
+		WithMiddleware(func(next Handler) Handler {
+			return func(ctx context.Context, o *Operation) error {
+				op = o
+				return nil
+			}
+		}))
+	coll.DeleteOne(ContextWithComment(context.Background(), "checkout"), bson.D{})
+	if want := "checkout (mongoboiler op " + op.ID + ")"; op.Comment != want {
+		t.Fatalf("comment %q, want %q", op.Comment, want)
+	}
+}
+
+func TestContextComment_InFilter(t *testing.T) {
+	coll := newTestCollection(t, "orders", WithContextComment(CommentInFilter()))
+	ctx := ContextWithComment(context.Background(), "checkout")
+	filter := bson.D{{Key: "status", Value: "open"}}
+
+	for _, kind := range []OpKind{OpUpdateOne, OpInsertOne} {
+		op := coll.newOp(kind)
+		op.Filter = filter
+		var executed bson.D
+		coll.run(ctx, op, func(ctx context.Context, op *Operation) error {
+			executed = op.Filter
+			return nil
+		})
+		got := mustRaw(t, bson.D{{Key: "f", Value: executed}}).Lookup("f", "$comment")
+		if comment, _ := got.StringValueOK(); (kind == OpUpdateOne) != (comment == "checkout") {
+			t.Fatalf("%s executed with filter %v", kind, executed)
+		}
+		if len(op.Filter) != 1 {
+			t.Fatalf("%s: filter %v not restored", kind, op.Filter)
+		}
+	}
+}
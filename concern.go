@@ -0,0 +1,118 @@
+package mongoboiler
+
+import (
+	"fmt"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+	"go.mongodb.org/mongo-driver/tag"
+)
+
+// WithReadConcern sets the read concern of the collections' operations, instead of the client's.
+func WithReadConcern(rc *readconcern.ReadConcern) Option {
+	return func(s *settings) {
+		s.readConcern = rc
+	}
+}
+
+// WithWriteConcern sets the write concern of the collections' operations, instead of the client's.
+func WithWriteConcern(wc *writeconcern.WriteConcern) Option {
+	return func(s *settings) {
+		s.writeConcern = wc
+	}
+}
+
+// WithReadPreference sets the read preference of the collections' operations, instead of the
+// client's.
+func WithReadPreference(rp *readpref.ReadPref) Option {
+	return func(s *settings) {
+		s.readPreference = rp
+	}
+}
+
+// WithReadTags restricts reads to members whose replica set tags match a tag set, trying the
+// sets in order, e.g. WithReadTags(map[string]string{"region": "eu-west"}, nil) prefers members
+// in eu-west and falls back to any member. The tags apply with the mode of WithReadPreference,
+// nearest if it is not set; the primary mode takes no tags, so they are ignored with it.
+func WithReadTags(tagSets ...map[string]string) Option {
+	sets := make([]tag.Set, len(tagSets))
+	for i, tags := range tagSets {
+		sets[i] = tagSet(tags)
+	}
+	return func(s *settings) {
+		s.readTags = sets
+	}
+}
+
+// tagSet returns tags as a tag.Set sorted by name, so it is stable.
+func tagSet(tags map[string]string) tag.Set {
+	set := make(tag.Set, 0, len(tags))
+	for name, value := range tags {
+		set = append(set, tag.Tag{Name: name, Value: value})
+	}
+	sort.Slice(set, func(i, j int) bool { return set[i].Name < set[j].Name })
+	return set
+}
+
+// readPreferenceWith returns the read preference of s with tagSets, nil when the client's applies.
+func (s *settings) readPreferenceWith(tagSets []tag.Set) *readpref.ReadPref {
+	if len(tagSets) == 0 {
+		return s.readPreference
+	}
+	mode := readpref.NearestMode
+	if s.readPreference != nil {
+		mode = s.readPreference.Mode()
+	}
+	if mode == readpref.PrimaryMode {
+		return s.readPreference
+	}
+	rp, err := readpref.New(mode, readpref.WithTagSets(tagSets...))
+	if err != nil {
+		return s.readPreference
+	}
+	return rp
+}
+
+// driverOptions returns the options of the driver collection, nil when the client's apply.
+func (c Collection) driverOptions() []*options.CollectionOptions {
+	if c.settings == nil {
+		return nil
+	}
+	rp := c.settings.readPreferenceWith(c.settings.readTags)
+	if c.settings.readConcern == nil && c.settings.writeConcern == nil && rp == nil {
+		return nil
+	}
+	opts := options.Collection()
+	if c.settings.readConcern != nil {
+		opts.SetReadConcern(c.settings.readConcern)
+	}
+	if c.settings.writeConcern != nil {
+		opts.SetWriteConcern(c.settings.writeConcern)
+	}
+	if rp != nil {
+		opts.SetReadPreference(rp)
+	}
+	return []*options.CollectionOptions{opts}
+}
+
+// describeConcerns returns the read concern, write concern and read preference of s for
+// ResolveOptions.
+func describeConcerns(s *settings) (rc, wc, rp string) {
+	rc, wc, rp = "client", "client", "client"
+	if s.readConcern != nil {
+		rc = s.readConcern.GetLevel()
+	}
+	if s.writeConcern != nil {
+		wc = fmt.Sprintf("w=%v j=%t", s.writeConcern.GetW(), s.writeConcern.GetJ())
+	}
+	if pref := s.readPreferenceWith(s.readTags); pref != nil {
+		rp = pref.Mode().String()
+		for _, set := range pref.TagSets() {
+			rp += " [" + set.String() + "]"
+		}
+	}
+	return rc, wc, rp
+}
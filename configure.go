@@ -0,0 +1,303 @@
+package mongoboiler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"gopkg.in/yaml.v3"
+)
+
+// DBConfig is the content of a file loaded by Configure.
+type DBConfig struct {
+	Collections []CollectionConfig `bson:"collections"`
+}
+
+// CollectionConfig declares the indexes, validator, TTL and wrapper options of a collection.
+type CollectionConfig struct {
+	Name      string            `bson:"name"`
+	Indexes   []IndexConfig     `bson:"indexes,omitempty"`
+	Validator *ValidatorConfig  `bson:"validator,omitempty"`
+	TTL       *TTLConfig        `bson:"ttl,omitempty"`
+	Options   CollectionOptions `bson:"options,omitempty"`
+}
+
+// IndexConfig declares an index. Keys keep their order, for compound indexes.
+type IndexConfig struct {
+	Keys          bson.D `bson:"keys"`
+	Name          string `bson:"name,omitempty"`
+	Unique        bool   `bson:"unique,omitempty"`
+	Sparse        bool   `bson:"sparse,omitempty"`
+	PartialFilter bson.D `bson:"partialFilter,omitempty"`
+}
+
+// ValidatorConfig declares the $jsonSchema validator, see ApplyValidator.
+type ValidatorConfig struct {
+	Schema bson.D `bson:"schema"`
+	// Level and Action default to strict and error.
+	Level  ValidationLevel  `bson:"level,omitempty"`
+	Action ValidationAction `bson:"action,omitempty"`
+}
+
+// TTLConfig declares a TTL index, see EnableTTL. After uses time.ParseDuration syntax.
+type TTLConfig struct {
+	Field string `bson:"field"`
+	After string `bson:"after"`
+}
+
+// CollectionOptions are the wrapper options set for a collection by Configure. Only these are
+// configurable: dropProtection, decodeMode, readOnly, revisions, audit and auditDiff. Other
+// behavior, such as encryption, tenancy or middleware, is set with options in code.
+type CollectionOptions struct {
+	DropProtection bool `bson:"dropProtection,omitempty"`
+	// DecodeMode is default, strict or lenient.
+	DecodeMode string `bson:"decodeMode,omitempty"`
+	// ReadOnly denies every write with a Policy named after the collection.
+	ReadOnly  bool `bson:"readOnly,omitempty"`
+	Revisions bool `bson:"revisions,omitempty"`
+	Audit     bool `bson:"audit,omitempty"`
+	AuditDiff bool `bson:"auditDiff,omitempty"`
+}
+
+// Configure applies the collection configuration in file, YAML or JSON by its extension, and
+// makes NewCollection apply the configured wrapper options of each collection before the ones
+// passed to it. It should run at startup, before the collections are used:
+//
+//	collections:
+//	  - name: users
+//	    indexes:
+//	      - keys: {email: 1}
+//	        unique: true
+//	      - keys: {tenant: 1, createdAt: -1}
+//	    validator:
+//	      schema: {bsonType: object, required: [email]}
+//	    options: {dropProtection: true, audit: true}
+//	  - name: sessions
+//	    ttl: {field: lastSeen, after: 24h}
+//
+// Extended JSON such as {$date: ...} is supported in both formats. Unknown fields are an error,
+// so typos do not silently disable a setting.
+func (db *DB) Configure(ctx context.Context, file string) error {
+	cfg, err := LoadDBConfig(file)
+	if err != nil {
+		return err
+	}
+	return db.ApplyConfig(ctx, cfg)
+}
+
+// LoadDBConfig reads and validates a configuration file for Configure.
+func LoadDBConfig(file string) (DBConfig, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return DBConfig{}, err
+	}
+	if ext := strings.ToLower(filepath.Ext(file)); ext == ".yaml" || ext == ".yml" {
+		var doc yaml.Node
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return DBConfig{}, fmt.Errorf("mongoboiler: config %s: %w", file, err)
+		}
+		var buf bytes.Buffer
+		if err := writeYAMLAsJSON(&buf, &doc); err != nil {
+			return DBConfig{}, fmt.Errorf("mongoboiler: config %s: %w", file, err)
+		}
+		data = buf.Bytes()
+	}
+
+	var raw bson.Raw
+	if err := bson.UnmarshalExtJSON(data, false, &raw); err != nil {
+		return DBConfig{}, fmt.Errorf("mongoboiler: config %s: %w", file, err)
+	}
+	var cfg DBConfig
+	if err := checkKnownFields(raw, reflect.TypeOf(cfg), ""); err != nil {
+		return DBConfig{}, fmt.Errorf("mongoboiler: config %s: %w", file, err)
+	}
+	if err := bson.Unmarshal(raw, &cfg); err != nil {
+		return DBConfig{}, fmt.Errorf("mongoboiler: config %s: %w", file, err)
+	}
+	if err := cfg.validate(); err != nil {
+		return DBConfig{}, fmt.Errorf("mongoboiler: config %s: %w", file, err)
+	}
+	return cfg, nil
+}
+
+func (cfg DBConfig) validate() error {
+	seen := map[string]bool{}
+	for _, c := range cfg.Collections {
+		if c.Name == "" {
+			return fmt.Errorf("collection without name")
+		}
+		if seen[c.Name] {
+			return fmt.Errorf("collection %s configured twice", c.Name)
+		}
+		seen[c.Name] = true
+		if _, err := c.Options.options(c.Name); err != nil {
+			return fmt.Errorf("collection %s: %w", c.Name, err)
+		}
+		if c.TTL != nil {
+			if _, err := time.ParseDuration(c.TTL.After); err != nil || c.TTL.Field == "" {
+				return fmt.Errorf("collection %s: ttl needs a field and a duration", c.Name)
+			}
+		}
+		for i, idx := range c.Indexes {
+			if len(idx.Keys) == 0 {
+				return fmt.Errorf("collection %s: index %d has no keys", c.Name, i)
+			}
+		}
+	}
+	return nil
+}
+
+// ApplyConfig applies cfg like Configure. Only the options of CollectionOptions can be
+// configured, LoadDBConfig fails with ErrUnknownField for files setting others.
+func (db *DB) ApplyConfig(ctx context.Context, cfg DBConfig) error {
+	if err := cfg.validate(); err != nil {
+		return fmt.Errorf("mongoboiler: config: %w", err)
+	}
+	configured := map[string][]Option{}
+	for key, opts := range db.settings.collectionOptions {
+		configured[key] = opts
+	}
+	for _, c := range cfg.Collections {
+		opts, _ := c.Options.options(c.Name)
+		configured[db.name+"."+c.Name] = opts
+	}
+	db.settings.collectionOptions = configured
+
+	for _, c := range cfg.Collections {
+		if err := db.applyCollectionConfig(ctx, c); err != nil {
+			return fmt.Errorf("mongoboiler: configuring %s: %w", c.Name, err)
+		}
+	}
+	return nil
+}
+
+func (db *DB) applyCollectionConfig(ctx context.Context, c CollectionConfig) error {
+	coll := db.NewCollection(c.Name)
+	if v := c.Validator; v != nil {
+		level, action := v.Level, v.Action
+		if level == "" {
+			level = ValidationStrict
+		}
+		if action == "" {
+			action = ValidationError
+		}
+		if err := db.NewCollection(c.Name, WithSchema(v.Schema)).ApplyValidator(ctx, level, action); err != nil {
+			return err
+		}
+	}
+	if len(c.Indexes) > 0 {
+		models := make([]mongo.IndexModel, len(c.Indexes))
+		for i, idx := range c.Indexes {
+			opts := options.Index()
+			if idx.Name != "" {
+				opts.SetName(idx.Name)
+			}
+			if idx.Unique {
+				opts.SetUnique(true)
+			}
+			if idx.Sparse {
+				opts.SetSparse(true)
+			}
+			if idx.PartialFilter != nil {
+				opts.SetPartialFilterExpression(idx.PartialFilter)
+			}
+			models[i] = mongo.IndexModel{Keys: idx.Keys, Options: opts}
+		}
+		if _, err := coll.collection().Indexes().CreateMany(ctx, models); err != nil {
+			return err
+		}
+	}
+	if c.TTL != nil {
+		after, _ := time.ParseDuration(c.TTL.After)
+		if err := coll.EnableTTL(ctx, c.TTL.Field, after); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// options returns the wrapper options o stands for.
+func (o CollectionOptions) options(collection string) ([]Option, error) {
+	var opts []Option
+	if o.DropProtection {
+		opts = append(opts, WithDropProtection())
+	}
+	switch o.DecodeMode {
+	case "", "default":
+	case "strict":
+		opts = append(opts, WithDecodeMode(DecodeStrict))
+	case "lenient":
+		opts = append(opts, WithDecodeMode(DecodeLenient))
+	default:
+		return nil, fmt.Errorf("unknown decodeMode %q", o.DecodeMode)
+	}
+	if o.ReadOnly {
+		opts = append(opts, WithPolicy(Policy{Name: collection + " read-only", ReadOnly: true}))
+	}
+	if o.Audit || o.AuditDiff {
+		opts = append(opts, WithAudit(AuditConfig{Diff: o.AuditDiff}))
+	}
+	if o.Revisions {
+		opts = append(opts, WithRevisions())
+	}
+	return opts, nil
+}
+
+// writeYAMLAsJSON converts a YAML document to JSON, keeping the order of mapping keys.
+func writeYAMLAsJSON(buf *bytes.Buffer, n *yaml.Node) error {
+	switch n.Kind {
+	case yaml.DocumentNode:
+		if len(n.Content) == 0 {
+			buf.WriteString("{}")
+			return nil
+		}
+		return writeYAMLAsJSON(buf, n.Content[0])
+	case yaml.AliasNode:
+		return writeYAMLAsJSON(buf, n.Alias)
+	case yaml.MappingNode:
+		buf.WriteByte('{')
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			key, _ := json.Marshal(n.Content[i].Value)
+			buf.Write(key)
+			buf.WriteByte(':')
+			if err := writeYAMLAsJSON(buf, n.Content[i+1]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	case yaml.SequenceNode:
+		buf.WriteByte('[')
+		for i, item := range n.Content {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeYAMLAsJSON(buf, item); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case yaml.ScalarNode:
+		var v any
+		if err := n.Decode(&v); err != nil {
+			return err
+		}
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", n.Line, err)
+		}
+		buf.Write(data)
+	}
+	return nil
+}
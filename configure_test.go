@@ -0,0 +1,114 @@
+package mongoboiler
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func writeConfig(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	return path
+}
+
+func TestLoadDBConfig_YAML(t *testing.T) {
+	path := writeConfig(t, "db.yaml", `
+collections:
+  - name: users
+    indexes:
+      - keys: {tenant: 1, createdAt: -1}
+        unique: true
+        partialFilter: {deletedAt: {$exists: false}}
+    validator:
+      schema: {bsonType: object, required: [email]}
+      level: moderate
+    options: {dropProtection: true, decodeMode: strict}
+  - name: sessions
+    ttl: {field: lastSeen, after: 24h}
+`)
+	cfg, err := LoadDBConfig(path)
+	if err != nil {
+		t.Fatalf("LoadDBConfig failed: %v", err)
+	}
+	if len(cfg.Collections) != 2 {
+		t.Fatalf("got %d collections, want 2", len(cfg.Collections))
+	}
+	users := cfg.Collections[0]
+	keys := users.Indexes[0].Keys
+	if len(keys) != 2 || keys[0].Key != "tenant" || keys[1].Key != "createdAt" {
+		t.Fatalf("index keys = %v, want tenant then createdAt", keys)
+	}
+	if !users.Indexes[0].Unique || users.Indexes[0].PartialFilter == nil {
+		t.Fatalf("index options not loaded: %+v", users.Indexes[0])
+	}
+	if users.Validator == nil || users.Validator.Level != ValidationModerate {
+		t.Fatalf("validator = %+v", users.Validator)
+	}
+	if !users.Options.DropProtection || users.Options.DecodeMode != "strict" {
+		t.Fatalf("options = %+v", users.Options)
+	}
+	if ttl := cfg.Collections[1].TTL; ttl == nil || ttl.Field != "lastSeen" || ttl.After != "24h" {
+		t.Fatalf("ttl = %+v", ttl)
+	}
+}
+
+func TestLoadDBConfig_JSON(t *testing.T) {
+	path := writeConfig(t, "db.json", `{"collections": [{"name": "events", "ttl": {"field": "at", "after": "1h"}}]}`)
+	cfg, err := LoadDBConfig(path)
+	if err != nil {
+		t.Fatalf("LoadDBConfig failed: %v", err)
+	}
+	if len(cfg.Collections) != 1 || cfg.Collections[0].TTL.After != "1h" {
+		t.Fatalf("cfg = %+v", cfg)
+	}
+}
+
+func TestLoadDBConfig_Invalid(t *testing.T) {
+	for name, content := range map[string]string{
+		"unknown option":     "collections: [{name: users, options: {softDelete: true}}]",
+		"unknown field":      "collections: [{name: users, indexs: []}]",
+		"decode mode":        "collections: [{name: users, options: {decodeMode: loose}}]",
+		"ttl duration":       "collections: [{name: users, ttl: {field: at, after: soon}}]",
+		"missing name":       "collections: [{options: {audit: true}}]",
+		"duplicate":          "collections: [{name: users}, {name: users}]",
+		"index without keys": "collections: [{name: users, indexes: [{unique: true}]}]",
+	} {
+		if _, err := LoadDBConfig(writeConfig(t, "db.yml", content)); err == nil {
+			t.Fatalf("%s: LoadDBConfig succeeded, want error", name)
+		}
+	}
+}
+
+func TestApplyConfig_CollectionOptions(t *testing.T) {
+	db := newTestCollection(t, "unused").db
+	err := db.ApplyConfig(context.Background(), DBConfig{Collections: []CollectionConfig{
+		{Name: "users", Options: CollectionOptions{DropProtection: true, DecodeMode: "lenient"}},
+		{Name: "archive", Options: CollectionOptions{ReadOnly: true}},
+	}})
+	if err != nil {
+		t.Fatalf("ApplyConfig failed: %v", err)
+	}
+
+	users := db.NewCollection("users")
+	if !users.settings.dropProtection || users.settings.decodeMode != DecodeLenient {
+		t.Fatalf("users settings not configured: %+v", users.settings)
+	}
+	if explicit := db.NewCollection("users", WithDecodeMode(DecodeStrict)); explicit.settings.decodeMode != DecodeStrict {
+		t.Fatalf("explicit option did not override the configured one")
+	}
+	if other := db.NewCollection("orders"); other.settings.dropProtection {
+		t.Fatalf("configured options applied to another collection")
+	}
+
+	_, err = db.NewCollection("archive").InsertOne(context.Background(), bson.D{{Key: "a", Value: 1}})
+	if err == nil || !strings.Contains(err.Error(), "archive") {
+		t.Fatalf("InsertOne on read-only collection returned %v, want policy error", err)
+	}
+}
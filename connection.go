@@ -0,0 +1,57 @@
+package mongoboiler
+
+import (
+	"context"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// connection holds the client shared by a DB, the DBs returned by its Database method and all
+// their collections. The client is only replaced when credentials are rotated.
+type connection struct {
+	mu     sync.RWMutex
+	client *mongo.Client
+	// stop cancels background work tied to the connection, such as credential polling.
+	stop context.CancelFunc
+	// ops tracks the operations in flight for Close.
+	ops inflightOps
+	// workers tracks the watchers and workers running for Close.
+	workers workerGroup
+	// version remembers the server version for feature checks.
+	version serverVersion
+	// unacknowledged counts the writes sent with w:0.
+	unacknowledged unacknowledgedWrites
+	// cancellations kills the server operations of canceled calls.
+	cancellations serverCancellations
+}
+
+func newConnection(client *mongo.Client) *connection {
+	return &connection{client: client}
+}
+
+func (c *connection) get() *mongo.Client {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.client
+}
+
+// swap installs client and returns the previous one.
+func (c *connection) swap(client *mongo.Client) *mongo.Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	old := c.client
+	c.client = client
+	return old
+}
+
+func (c *connection) disconnect(ctx context.Context) error {
+	c.mu.Lock()
+	stop := c.stop
+	c.stop = nil
+	c.mu.Unlock()
+	if stop != nil {
+		stop()
+	}
+	return c.get().Disconnect(ctx)
+}
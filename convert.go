@@ -0,0 +1,20 @@
+package mongoboiler
+
+import "go.mongodb.org/mongo-driver/bson"
+
+// toDocument converts any marshalable document (struct, map, bson.D, ...) into a bson.D.
+// bson.D values are returned as is.
+func toDocument(v any) (bson.D, error) {
+	if d, ok := v.(bson.D); ok {
+		return d, nil
+	}
+	data, err := bson.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var d bson.D
+	if err := bson.Unmarshal(data, &d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
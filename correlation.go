@@ -0,0 +1,79 @@
+package mongoboiler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// OperationError wraps the error of a failed operation with the operation's ID, see
+// WithCorrelation. errors.Is and errors.As see through it.
+type OperationError struct {
+	ID         string
+	Kind       OpKind
+	Database   string
+	Collection string
+	Err        error
+}
+
+func (e *OperationError) Error() string {
+	return fmt.Sprintf("mongoboiler: %s on %s.%s (op %s): %v", e.Kind, e.Database, e.Collection, e.ID, e.Err)
+}
+
+func (e *OperationError) Unwrap() error {
+	return e.Err
+}
+
+// Code returns the code of the operation's error.
+func (e *OperationError) Code() ErrorCode {
+	return ErrorCodeOf(e.Err)
+}
+
+type operationIDKey struct{}
+
+// ContextWithOperationID makes the next wrapper call run with id as its operation ID instead of
+// a generated one, e.g. to reuse the ID of the incoming request.
+func ContextWithOperationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, operationIDKey{}, id)
+}
+
+// OperationIDFromContext returns the ID of the operation ctx belongs to. Middleware and code
+// called from handlers, like tracing and logging, use it to tag their output.
+func OperationIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(operationIDKey{}).(string)
+	return id, ok && id != ""
+}
+
+func newOperationID() string {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// WithCorrelation makes the operation ID follow every operation. It is sent as the server side
+// comment, so it shows in the server log, the profiler and currentOp, appended to the comment of
+// the call or of WithContextComment if there is one. Failed operations are logged with it to
+// logger unless nil. Their errors are returned as *OperationError carrying it.
+func WithCorrelation(logger Logger) Option {
+	return WithMiddleware(func(next Handler) Handler {
+		return func(ctx context.Context, op *Operation) error {
+			if op.Comment == "" {
+				op.Comment = "mongoboiler op " + op.ID
+			} else {
+				op.Comment += " (mongoboiler op " + op.ID + ")"
+			}
+			err := next(ctx, op)
+			if err == nil {
+				return nil
+			}
+			if logger != nil {
+				logger.Printf("mongoboiler: op %s %s on %s.%s failed: %v", op.ID, op.Kind, op.Database, op.Collection, err)
+			}
+			return &OperationError{ID: op.ID, Kind: op.Kind, Database: op.Database, Collection: op.Collection, Err: err}
+		}
+	})
+}
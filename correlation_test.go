@@ -0,0 +1,51 @@
+package mongoboiler
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type recordingLogger struct{ lines []string }
+
+func (l *recordingLogger) Printf(format string, v ...any) {
+	l.lines = append(l.lines, format)
+}
+
+func TestWithCorrelation(t *testing.T) {
+	failure := errors.New("boom")
+	var seenID, seenComment string
+	logger := &recordingLogger{}
+	coll := newTestCollection(t, "orders",
+		WithCorrelation(logger),
+		WithMiddleware(func(next Handler) Handler {
+			return func(ctx context.Context, op *Operation) error {
+				seenID, _ = OperationIDFromContext(ctx)
+				seenComment = op.Comment
+				return failure
+			}
+		}))
+
+	ctx := ContextWithOperationID(context.Background(), "req-42")
+	_, err := coll.DeleteOne(ctx, bson.D{})
+	var opErr *OperationError
+	if !errors.As(err, &opErr) || !errors.Is(err, failure) {
+		t.Fatalf("expected an OperationError wrapping the failure, got %v", err)
+	}
+	if opErr.ID != "req-42" || seenID != "req-42" || !strings.HasSuffix(seenComment, "req-42") {
+		t.Fatalf("operation ID not propagated: err %q, ctx %q, comment %q", opErr.ID, seenID, seenComment)
+	}
+	if len(logger.lines) != 1 {
+		t.Fatalf("expected the failure to be logged once, got %d lines", len(logger.lines))
+	}
+}
+
+func TestNewOp_GeneratesID(t *testing.T) {
+	coll := newTestCollection(t, "orders")
+	if a, b := coll.newOp(OpFind).ID, coll.newOp(OpFind).ID; a == "" || a == b {
+		t.Fatalf("expected distinct operation IDs, got %q and %q", a, b)
+	}
+}
@@ -0,0 +1,50 @@
+package mongoboiler
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DefaultCountersCollection is the collection NextSequence keeps its counters in.
+const DefaultCountersCollection = "counters"
+
+// WithCountersCollection changes the collection NextSequence keeps its counters in.
+func WithCountersCollection(name string) Option {
+	return func(s *settings) {
+		s.countersCollection = name
+	}
+}
+
+// NextSequence atomically increments the named sequence and returns its new value, starting
+// at 1. Sequences give human friendly IDs such as order numbers; they have no gaps unless the
+// caller fails to use a value it obtained.
+func (db *DB) NextSequence(ctx context.Context, name string) (int64, error) {
+	counters := DefaultCountersCollection
+	if db.settings != nil && db.settings.countersCollection != "" {
+		counters = db.settings.countersCollection
+	}
+	coll := db.NewCollection(counters)
+
+	var counter struct {
+		Seq int64 `bson:"seq"`
+	}
+	op := coll.newOp(OpUpdateOne)
+	op.needsEffect, op.Upsert = true, true
+	op.Filter = bson.D{{Key: "_id", Value: name}}
+	op.Update = bson.D{{Key: "$inc", Value: bson.D{{Key: "seq", Value: int64(1)}}}}
+	err := coll.run(ctx, op, func(ctx context.Context, op *Operation) error {
+		opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+		if op.Comment != "" {
+			opts.SetComment(op.Comment)
+		}
+		return op.Target.FindOneAndUpdate(ctx, op.Filter, op.Update, opts).Decode(&counter)
+	})
+	return counter.Seq, err
+}
+
+// Increment atomically adds by (which may be negative) to field of the document matching filter.
+func (c Collection) Increment(ctx context.Context, filter bson.D, field string, by int64) (UpdateResult, error) {
+	return c.UpdateOne(ctx, filter, bson.D{{Key: "$inc", Value: bson.D{{Key: field, Value: by}}}})
+}
@@ -0,0 +1,61 @@
+package mongoboiler
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestNextSequence(t *testing.T) {
+	var ops []*Operation
+	coll := recordingCollection(t, &ops)
+	ctx := context.Background()
+
+	if _, err := coll.db.NextSequence(ctx, "orders"); err != nil {
+		t.Fatalf("NextSequence failed: %v", err)
+	}
+	renamed := newTestCollection(t, "orders", WithCountersCollection("sequences"), WithMiddleware(func(next Handler) Handler {
+		return func(ctx context.Context, op *Operation) error {
+			ops = append(ops, op)
+			return nil
+		}
+	}))
+	if _, err := renamed.db.NextSequence(ctx, "invoices"); err != nil {
+		t.Fatalf("NextSequence failed: %v", err)
+	}
+
+	if len(ops) != 2 {
+		t.Fatalf("expected 2 operations, got %d", len(ops))
+	}
+	op := ops[0]
+	if op.Kind != OpUpdateOne || op.Collection != DefaultCountersCollection || !op.Upsert || !op.needsEffect {
+		t.Fatalf("unexpected operation %+v", op)
+	}
+	if want := (bson.D{{Key: "_id", Value: "orders"}}); !reflect.DeepEqual(op.Filter, want) {
+		t.Fatalf("got filter %v, want %v", op.Filter, want)
+	}
+	if want := (bson.D{{Key: "$inc", Value: bson.D{{Key: "seq", Value: int64(1)}}}}); !reflect.DeepEqual(op.Update, want) {
+		t.Fatalf("got update %v, want %v", op.Update, want)
+	}
+	if ops[1].Collection != "sequences" || ops[1].Filter[0].Value != "invoices" {
+		t.Fatalf("expected the counter in the configured collection, got %+v", ops[1])
+	}
+}
+
+func TestIncrement(t *testing.T) {
+	var ops []*Operation
+	coll := recordingCollection(t, &ops)
+	filter := bson.D{{Key: "_id", Value: "sku-1"}}
+
+	if _, err := coll.Increment(context.Background(), filter, "stock", -3); err != nil {
+		t.Fatalf("Increment failed: %v", err)
+	}
+	if len(ops) != 1 || ops[0].Kind != OpUpdateOne || !reflect.DeepEqual(ops[0].Filter, filter) {
+		t.Fatalf("unexpected operations %+v", ops)
+	}
+	if want := (bson.D{{Key: "$inc", Value: bson.D{{Key: "stock", Value: int64(-3)}}}}); !reflect.DeepEqual(ops[0].Update, want) {
+		t.Fatalf("got update %v, want %v", ops[0].Update, want)
+	}
+}
@@ -0,0 +1,81 @@
+package mongoboiler
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CollectionOption configures a collection created with CreateCollection.
+type CollectionOption func(*options.CreateCollectionOptions)
+
+// Capped makes the collection capped at sizeBytes, and maxDocuments when greater than zero.
+func Capped(sizeBytes, maxDocuments int64) CollectionOption {
+	return func(o *options.CreateCollectionOptions) {
+		o.SetCapped(true).SetSizeInBytes(sizeBytes)
+		if maxDocuments > 0 {
+			o.SetMaxDocuments(maxDocuments)
+		}
+	}
+}
+
+// Time series granularities.
+const (
+	GranularitySeconds = "seconds"
+	GranularityMinutes = "minutes"
+	GranularityHours   = "hours"
+)
+
+// TimeSeries makes the collection a time series collection. metaField and granularity are
+// optional and left out when empty.
+func TimeSeries(timeField, metaField, granularity string) CollectionOption {
+	return func(o *options.CreateCollectionOptions) {
+		ts := options.TimeSeries().SetTimeField(timeField)
+		if metaField != "" {
+			ts.SetMetaField(metaField)
+		}
+		if granularity != "" {
+			ts.SetGranularity(granularity)
+		}
+		o.SetTimeSeriesOptions(ts)
+	}
+}
+
+// DefaultCollation sets the collation used by operations on the collection that specify none.
+func DefaultCollation(collation *options.Collation) CollectionOption {
+	return func(o *options.CreateCollectionOptions) {
+		o.SetCollation(collation)
+	}
+}
+
+// ExpireAfter removes documents of a time series collection once they are older than d.
+func ExpireAfter(d time.Duration) CollectionOption {
+	return func(o *options.CreateCollectionOptions) {
+		o.SetExpireAfterSeconds(int64(d / time.Second))
+	}
+}
+
+// Validated creates the collection with schema as its $jsonSchema validator (see SchemaOf).
+func Validated(schema bson.D, level ValidationLevel, action ValidationAction) CollectionOption {
+	return func(o *options.CreateCollectionOptions) {
+		o.SetValidator(bson.D{{Key: "$jsonSchema", Value: schema}}).
+			SetValidationLevel(string(level)).
+			SetValidationAction(string(action))
+	}
+}
+
+// CreateCollection explicitly creates the named collection and returns it. It fails if the
+// collection already exists.
+func (db *DB) CreateCollection(ctx context.Context, name string, opts ...CollectionOption) (*Collection, error) {
+	createOpts := options.CreateCollection()
+	for _, opt := range opts {
+		opt(createOpts)
+	}
+	coll := db.NewCollection(name)
+	if err := db.database().CreateCollection(ctx, coll.collectionName(), createOpts); err != nil {
+		return nil, err
+	}
+	return coll, nil
+}
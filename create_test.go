@@ -0,0 +1,29 @@
+package mongoboiler
+
+import (
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestCollectionOptions(t *testing.T) {
+	o := options.CreateCollection()
+	for _, opt := range []CollectionOption{
+		Capped(1<<20, 0),
+		TimeSeries("ts", "", GranularityMinutes),
+		ExpireAfter(36 * time.Hour),
+	} {
+		opt(o)
+	}
+	if o.Capped == nil || !*o.Capped || *o.SizeInBytes != 1<<20 || o.MaxDocuments != nil {
+		t.Fatalf("unexpected capped options: %+v", o)
+	}
+	ts := o.TimeSeriesOptions
+	if ts == nil || ts.TimeField != "ts" || ts.MetaField != nil || *ts.Granularity != GranularityMinutes {
+		t.Fatalf("unexpected time series options: %+v", ts)
+	}
+	if *o.ExpireAfterSeconds != 36*3600 {
+		t.Fatalf("unexpected expireAfterSeconds: %d", *o.ExpireAfterSeconds)
+	}
+}
@@ -0,0 +1,130 @@
+package mongoboiler
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// CredentialProvider returns the current database credentials, for example read from Vault or
+// AWS Secrets Manager. Set it on Config to rotate passwords without restarting the process.
+type CredentialProvider func(ctx context.Context) (username, password string, err error)
+
+// Defaults for credential rotation.
+const (
+	DefaultCredentialRefreshInterval = 5 * time.Minute
+	DefaultCredentialGracePeriod     = time.Minute
+)
+
+// credentialRotator polls a CredentialProvider and swaps in a freshly authenticated client
+// whenever the credentials change. The old client keeps serving in-flight operations for the
+// grace period before it is disconnected.
+type credentialRotator struct {
+	cfg  Config
+	conn *connection
+
+	mu             sync.Mutex
+	username, pass string
+}
+
+// connectRotating connects using the provider's current credentials and starts polling it.
+func connectRotating(ctx context.Context, cfg Config) (*connection, error) {
+	r := &credentialRotator{cfg: cfg}
+	client, err := r.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	r.conn = newConnection(client)
+
+	interval := cfg.CredentialRefreshInterval
+	if interval <= 0 {
+		interval = DefaultCredentialRefreshInterval
+	}
+	pollCtx, stop := context.WithCancel(context.Background())
+	r.conn.stop = stop
+	go r.poll(pollCtx, interval)
+	return r.conn, nil
+}
+
+func (r *credentialRotator) poll(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.refresh(ctx); err != nil && ctx.Err() == nil {
+				loggerOrDefault(r.cfg.Logger).Printf("mongoboiler: credential refresh failed, keeping current client: %v", err)
+			}
+		}
+	}
+}
+
+// refresh swaps the client if the provider returns new credentials.
+func (r *credentialRotator) refresh(ctx context.Context) error {
+	username, pass, err := r.cfg.Credentials(ctx)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	unchanged := username == r.username && pass == r.pass
+	r.mu.Unlock()
+	if unchanged {
+		return nil
+	}
+
+	client, err := r.dial(ctx, username, pass)
+	if err != nil {
+		return err
+	}
+	old := r.conn.swap(client)
+
+	grace := r.cfg.CredentialGracePeriod
+	if grace <= 0 {
+		grace = DefaultCredentialGracePeriod
+	}
+	go func() {
+		// Disconnect waits for connections in use by in-flight operations up to the deadline.
+		time.Sleep(grace)
+		dctx, cancel := context.WithTimeout(context.Background(), grace)
+		defer cancel()
+		old.Disconnect(dctx)
+	}()
+	return nil
+}
+
+// connect fetches the current credentials and returns a client verified to authenticate.
+func (r *credentialRotator) connect(ctx context.Context) (*mongo.Client, error) {
+	username, pass, err := r.cfg.Credentials(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.dial(ctx, username, pass)
+}
+
+// dial returns a client verified to authenticate with the credentials.
+func (r *credentialRotator) dial(ctx context.Context, username, pass string) (*mongo.Client, error) {
+	cfg := r.cfg
+	cfg.Auth.Username, cfg.Auth.Password = username, pass
+	opts, err := cfg.ClientOptions()
+	if err != nil {
+		return nil, err
+	}
+	client, err := mongo.Connect(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := client.Ping(ctx, readpref.PrimaryPreferred()); err != nil {
+		client.Disconnect(context.Background())
+		return nil, err
+	}
+
+	r.mu.Lock()
+	r.username, r.pass = username, pass
+	r.mu.Unlock()
+	return client, nil
+}
@@ -0,0 +1,80 @@
+package mongoboiler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestConnect_CredentialProviderError(t *testing.T) {
+	wantErr := errors.New("vault sealed")
+	cfg := Config{
+		URI:      "mongodb://localhost:27017",
+		Database: "app",
+		Credentials: func(ctx context.Context) (string, string, error) {
+			return "", "", wantErr
+		},
+	}
+	if _, err := Connect(context.Background(), cfg); !errors.Is(err, wantErr) {
+		t.Fatalf("expected provider error, got %v", err)
+	}
+}
+
+func TestCredentialRotator_RefreshKeepsClientWhenUnchanged(t *testing.T) {
+	calls := 0
+	r := &credentialRotator{
+		cfg: Config{Credentials: func(ctx context.Context) (string, string, error) {
+			calls++
+			return "app", "s3cret", nil
+		}},
+		conn:     newConnection(nil),
+		username: "app",
+		pass:     "s3cret",
+	}
+	if err := r.refresh(context.Background()); err != nil {
+		t.Fatalf("refresh failed: %v", err)
+	}
+	if calls != 1 || r.conn.get() != nil {
+		t.Fatalf("expected the client to be kept, calls=%d", calls)
+	}
+}
+
+func TestCredentialRotator_RefreshCallsProviderOnce(t *testing.T) {
+	calls := 0
+	r := &credentialRotator{
+		cfg: Config{
+			URI:                    "mongodb://localhost:1",
+			ServerSelectionTimeout: 10 * time.Millisecond,
+			Credentials: func(ctx context.Context) (string, string, error) {
+				calls++
+				return "app", "rotated", nil
+			},
+		},
+		conn:     newConnection(nil),
+		username: "app",
+		pass:     "s3cret",
+	}
+	if err := r.refresh(context.Background()); err == nil {
+		t.Fatalf("expected the unreachable server to fail the refresh")
+	}
+	if calls != 1 || r.pass != "s3cret" {
+		t.Fatalf("expected one provider call and the current credentials kept, calls=%d", calls)
+	}
+}
+
+func TestConnect_WarnsOnceAboutInsecureTLS(t *testing.T) {
+	logger := &recordingLogger{}
+	cfg := Config{URI: "mongodb://localhost:27017", Database: "app", Logger: logger, TLS: TLSConfig{InsecureSkipVerify: true}}
+	db, err := Connect(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer db.Disconnect(context.Background())
+	if _, err := cfg.ClientOptions(); err != nil {
+		t.Fatalf("ClientOptions failed: %v", err)
+	}
+	if len(logger.lines) != 1 {
+		t.Fatalf("expected a single warning, got %q", logger.lines)
+	}
+}
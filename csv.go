@@ -0,0 +1,391 @@
+package mongoboiler
+
+import (
+	"context"
+	"encoding"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsoncodec"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CSVOptions configures ImportCSV and ExportCSV.
+//
+// Columns map to the fields of the struct type by their `csv:"name"` tag, or else by their BSON
+// field name, joined with dots for fields of nested structs (address.city). Fields tagged
+// `csv:"-"` and fields of types without a CSV form (slices, maps, interfaces) are not mapped.
+type CSVOptions struct {
+	// Columns lists the columns in order. Export defaults to all mapped fields, import to the
+	// header, and requires Columns with NoHeader.
+	Columns []string
+	// NoHeader means the input has no header row, or the output should not get one.
+	NoHeader bool
+	// SkipUnknownColumns ignores header columns without a field instead of failing.
+	SkipUnknownColumns bool
+	// Comma is the field delimiter, ',' if zero.
+	Comma rune
+	// TimeLayout formats and parses time.Time fields, time.RFC3339 if empty.
+	TimeLayout string
+	// Parsers convert the cells of the named columns on import, overriding the built-in
+	// conversion; the result must be assignable or convertible to the field type.
+	Parsers map[string]func(cell string) (any, error)
+	// BatchSize is the number of documents inserted at once, DefaultImportBatchSize if zero.
+	BatchSize int
+}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// csvField is a mapped struct field.
+type csvField struct {
+	index []int
+	typ   reflect.Type
+}
+
+// csvFields returns the mapped fields of struct type t by column name, and the names in field
+// order.
+func csvFields(t reflect.Type) (map[string]csvField, []string, error) {
+	if t.Kind() != reflect.Struct {
+		return nil, nil, fmt.Errorf("mongoboiler: csv needs a struct type, not %s", t)
+	}
+	fields := map[string]csvField{}
+	var names []string
+	var walk func(t reflect.Type, index []int, prefix string) error
+	walk = func(t reflect.Type, index []int, prefix string) error {
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			if !sf.IsExported() {
+				continue
+			}
+			tags, err := bsoncodec.DefaultStructTagParser(sf)
+			if err != nil {
+				return err
+			}
+			csvTag := sf.Tag.Get("csv")
+			if tags.Skip || csvTag == "-" {
+				continue
+			}
+			idx := append(append([]int(nil), index...), i)
+			if sf.Type.Kind() == reflect.Struct && !csvScalar(sf.Type) {
+				nested := prefix + tags.Name + "."
+				if tags.Inline {
+					nested = prefix
+				}
+				if err := walk(sf.Type, idx, nested); err != nil {
+					return err
+				}
+				continue
+			}
+			if !csvScalar(sf.Type) {
+				continue
+			}
+			name := prefix + tags.Name
+			if csvTag != "" {
+				name = csvTag
+			}
+			if _, dup := fields[name]; dup {
+				return fmt.Errorf("mongoboiler: csv column %s maps to two fields", name)
+			}
+			fields[name] = csvField{index: idx, typ: sf.Type}
+			names = append(names, name)
+		}
+		return nil
+	}
+	if err := walk(t, nil, ""); err != nil {
+		return nil, nil, err
+	}
+	return fields, names, nil
+}
+
+// csvScalar reports whether values of t are a single CSV cell.
+func csvScalar(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == timeType || t == objectIDType || reflect.PtrTo(t).Implements(textUnmarshalerType) {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.String, reflect.Bool, reflect.Float32, reflect.Float64,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+// ImportCSV reads the rows of r into values of struct type T and inserts them into c in batches.
+// Empty cells leave fields zero, and pointer fields nil. On error the rows of earlier batches
+// stay imported.
+func ImportCSV[T any](ctx context.Context, c *Collection, r io.Reader, opts CSVOptions) (ImportResult, error) {
+	fields, _, err := csvFields(reflect.TypeOf((*T)(nil)).Elem())
+	if err != nil {
+		return ImportResult{}, err
+	}
+	cr := csv.NewReader(r)
+	if opts.Comma != 0 {
+		cr.Comma = opts.Comma
+	}
+	cr.ReuseRecord = true
+
+	columns := append([]string(nil), opts.Columns...)
+	if !opts.NoHeader {
+		header, err := cr.Read()
+		if err != nil {
+			return ImportResult{}, fmt.Errorf("mongoboiler: csv header: %w", err)
+		}
+		if len(columns) == 0 {
+			columns = append(columns, header...)
+		}
+	} else if len(columns) == 0 {
+		return ImportResult{}, errors.New("mongoboiler: csv without header needs Columns")
+	}
+	mapped := make([]*csvField, len(columns))
+	for i, name := range columns {
+		name = strings.TrimSpace(name)
+		f, ok := fields[name]
+		if !ok && !opts.SkipUnknownColumns {
+			return ImportResult{}, fmt.Errorf("mongoboiler: csv column %s has no field", name)
+		}
+		if ok {
+			f := f
+			mapped[i] = &f
+		}
+		columns[i] = name
+	}
+
+	size := opts.BatchSize
+	if size <= 0 {
+		size = DefaultImportBatchSize
+	}
+	progress := TrackProgress(ctx, "import csv "+c.name, 0)
+	defer progress.Finish()
+	var res ImportResult
+	batch := make([]any, 0, size)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		inserted, err := c.InsertMany(ctx, batch)
+		res.Inserted += int64(len(inserted.InsertedIDs))
+		progress.Add(int64(len(inserted.InsertedIDs)))
+		batch = make([]any, 0, size)
+		return err
+	}
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return res, fmt.Errorf("mongoboiler: csv: %w", err)
+		}
+		line, _ := cr.FieldPos(0)
+		var v T
+		rv := reflect.ValueOf(&v).Elem()
+		for i, cell := range record {
+			if i >= len(mapped) || mapped[i] == nil {
+				continue
+			}
+			if err := opts.setCell(rv.FieldByIndex(mapped[i].index), columns[i], cell); err != nil {
+				return res, fmt.Errorf("mongoboiler: csv line %d column %s: %w", line, columns[i], err)
+			}
+		}
+		if batch = append(batch, v); len(batch) == size {
+			if err := flush(); err != nil {
+				return res, err
+			}
+		}
+	}
+	return res, flush()
+}
+
+// setCell sets field to the value of cell.
+func (opts CSVOptions) setCell(field reflect.Value, column, cell string) error {
+	if parse := opts.Parsers[column]; parse != nil {
+		v, err := parse(cell)
+		if err != nil || v == nil {
+			return err
+		}
+		val := reflect.ValueOf(v)
+		switch {
+		case val.Type().AssignableTo(field.Type()):
+			field.Set(val)
+		case val.Type().ConvertibleTo(field.Type()):
+			field.Set(val.Convert(field.Type()))
+		default:
+			return fmt.Errorf("parser returned %T for a %s field", v, field.Type())
+		}
+		return nil
+	}
+	if cell == "" {
+		return nil
+	}
+	if field.Kind() == reflect.Ptr {
+		field.Set(reflect.New(field.Type().Elem()))
+		field = field.Elem()
+	}
+
+	switch {
+	case field.Type() == timeType:
+		t, err := time.Parse(opts.timeLayout(), cell)
+		if err != nil {
+			return err
+		}
+		field.Set(reflect.ValueOf(t))
+		return nil
+	case field.Type() == objectIDType:
+		id, err := primitive.ObjectIDFromHex(cell)
+		if err != nil {
+			return err
+		}
+		field.Set(reflect.ValueOf(id))
+		return nil
+	case field.Addr().Type().Implements(textUnmarshalerType):
+		return field.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(cell))
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(cell)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(cell)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(cell, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(cell, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(cell, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	}
+	return nil
+}
+
+func (opts CSVOptions) timeLayout() string {
+	if opts.TimeLayout == "" {
+		return time.RFC3339
+	}
+	return opts.TimeLayout
+}
+
+// ExportCSV writes the documents of c matching filter to w as rows of the fields of struct type
+// T, streaming them like FindEach, and returns how many there were.
+func ExportCSV[T any](ctx context.Context, c *Collection, w io.Writer, filter bson.D, opts CSVOptions, findOpts ...*options.FindOptions) (int64, error) {
+	fields, names, err := csvFields(reflect.TypeOf((*T)(nil)).Elem())
+	if err != nil {
+		return 0, err
+	}
+	columns := opts.Columns
+	if len(columns) == 0 {
+		columns = names
+	}
+	mapped := make([]csvField, len(columns))
+	for i, name := range columns {
+		f, ok := fields[name]
+		if !ok {
+			return 0, fmt.Errorf("mongoboiler: csv column %s has no field", name)
+		}
+		mapped[i] = f
+	}
+
+	progress, err := c.trackCount(ctx, "export csv", filter)
+	if err != nil {
+		return 0, err
+	}
+	defer progress.Finish()
+	cw := csv.NewWriter(w)
+	if opts.Comma != 0 {
+		cw.Comma = opts.Comma
+	}
+	if !opts.NoHeader {
+		if err := cw.Write(columns); err != nil {
+			return 0, err
+		}
+	}
+	var n int64
+	record := make([]string, len(columns))
+	err = c.FindEach(ctx, nonNilFilter(filter), func(dec Decoder) error {
+		var v T
+		if err := dec.Decode(&v); err != nil {
+			return err
+		}
+		rv := reflect.ValueOf(v)
+		for i, f := range mapped {
+			cell, err := opts.formatCell(rv.FieldByIndex(f.index))
+			if err != nil {
+				return fmt.Errorf("mongoboiler: csv column %s: %w", columns[i], err)
+			}
+			record[i] = cell
+		}
+		n++
+		progress.Add(1)
+		return cw.Write(record)
+	}, findOpts...)
+	if err != nil {
+		return n, err
+	}
+	cw.Flush()
+	return n, cw.Error()
+}
+
+// formatCell returns the cell of field, the inverse of setCell.
+func (opts CSVOptions) formatCell(field reflect.Value) (string, error) {
+	if field.Kind() == reflect.Ptr {
+		if field.IsNil() {
+			return "", nil
+		}
+		field = field.Elem()
+	}
+	switch v := field.Interface().(type) {
+	case time.Time:
+		if v.IsZero() {
+			return "", nil
+		}
+		return v.Format(opts.timeLayout()), nil
+	case primitive.ObjectID:
+		if v.IsZero() {
+			return "", nil
+		}
+		return v.Hex(), nil
+	case encoding.TextMarshaler:
+		text, err := v.MarshalText()
+		return string(text), err
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		return field.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(field.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(field.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(field.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(field.Float(), 'g', -1, field.Type().Bits()), nil
+	}
+	return "", fmt.Errorf("unsupported type %s", field.Type())
+}
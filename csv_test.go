@@ -0,0 +1,105 @@
+package mongoboiler
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type csvAddress struct {
+	City string `bson:"city"`
+}
+
+type csvCustomer struct {
+	ID      primitive.ObjectID `bson:"_id"`
+	Name    string             `bson:"name" csv:"Full Name"`
+	Age     int                `bson:"age"`
+	Score   *float64           `bson:"score"`
+	Active  bool               `bson:"active"`
+	Joined  time.Time          `bson:"joined"`
+	Address csvAddress         `bson:"address"`
+	Tags    []string           `bson:"tags"`
+	Secret  string             `bson:"secret" csv:"-"`
+}
+
+func TestImportCSV(t *testing.T) {
+	var ops []*Operation
+	coll := exportTestCollection(t, nil, &ops)
+	id := primitive.NewObjectID()
+	in := "_id,Full Name,age,score,active,joined,address.city\n" +
+		id.Hex() + ",Ann,41,9.5,true,2020-01-02T03:04:05Z,Oslo\n" +
+		",\"Bob, Jr.\",7,,false,,\n"
+
+	res, err := ImportCSV[csvCustomer](context.Background(), coll, strings.NewReader(in), CSVOptions{BatchSize: 1})
+	if err != nil {
+		t.Fatalf("ImportCSV failed: %v", err)
+	}
+	if len(ops) != 2 || res.Inserted != 0 {
+		t.Fatalf("expected two batches, got %d (%+v)", len(ops), res)
+	}
+	ann := ops[0].Documents[0].(csvCustomer)
+	if ann.ID != id || ann.Name != "Ann" || ann.Age != 41 || ann.Score == nil || *ann.Score != 9.5 ||
+		!ann.Active || ann.Joined.Year() != 2020 || ann.Address.City != "Oslo" {
+		t.Fatalf("unexpected first row: %+v", ann)
+	}
+	bob := ops[1].Documents[0].(csvCustomer)
+	if bob.Name != "Bob, Jr." || bob.Score != nil || !bob.ID.IsZero() {
+		t.Fatalf("unexpected second row: %+v", bob)
+	}
+}
+
+func TestImportCSV_Options(t *testing.T) {
+	var ops []*Operation
+	coll := exportTestCollection(t, nil, &ops)
+	in := "Ann;forty-one;x\n"
+	opts := CSVOptions{
+		NoHeader: true,
+		Comma:    ';',
+		Columns:  []string{"Full Name", "age", "unknown"},
+		Parsers: map[string]func(string) (any, error){
+			"age": func(cell string) (any, error) { return len(cell), nil },
+		},
+		SkipUnknownColumns: true,
+	}
+	if _, err := ImportCSV[csvCustomer](context.Background(), coll, strings.NewReader(in), opts); err != nil {
+		t.Fatalf("ImportCSV failed: %v", err)
+	}
+	if c := ops[0].Documents[0].(csvCustomer); c.Name != "Ann" || c.Age != 9 {
+		t.Fatalf("unexpected row: %+v", c)
+	}
+
+	_, err := ImportCSV[csvCustomer](context.Background(), coll, strings.NewReader("age\nold\n"), CSVOptions{})
+	if err == nil || !strings.Contains(err.Error(), "line 2 column age") {
+		t.Fatalf("expected a line and column in the error, got %v", err)
+	}
+	_, err = ImportCSV[csvCustomer](context.Background(), coll, strings.NewReader("tags\n\n"), CSVOptions{})
+	if err == nil || !strings.Contains(err.Error(), "column tags has no field") {
+		t.Fatalf("expected an unknown column error, got %v", err)
+	}
+}
+
+func TestExportCSV(t *testing.T) {
+	score := 9.5
+	ann := csvCustomer{Name: "Ann", Age: 41, Score: &score, Joined: time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC), Address: csvAddress{City: "Oslo"}}
+	data, err := bson.Marshal(ann)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	var ops []*Operation
+	coll := exportTestCollection(t, []bson.Raw{data}, &ops)
+
+	var buf bytes.Buffer
+	n, err := ExportCSV[csvCustomer](context.Background(), coll, &buf, nil, CSVOptions{})
+	if err != nil || n != 1 {
+		t.Fatalf("ExportCSV = %d, %v", n, err)
+	}
+	want := "_id,Full Name,age,score,active,joined,address.city\n,Ann,41,9.5,false,2020-01-02T00:00:00Z,Oslo\n"
+	if buf.String() != want {
+		t.Fatalf("got %q, want %q", buf.String(), want)
+	}
+}
@@ -0,0 +1,388 @@
+// Package dataapi implements mongoboiler.CollectionAPI on the Atlas Data API, for runtimes
+// that cannot open driver connections, such as edge functions or networks only allowing HTTPS.
+//
+// Operations are sent one HTTPS request each, so they are slower than through the driver and
+// the wrapper's middleware, caching and encryption do not apply. Requests and responses use
+// canonical Extended JSON, so BSON types round trip.
+package dataapi
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/anurag925/mongoboiler"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrUnsupported is returned for operations and options the Data API does not offer.
+var ErrUnsupported = errors.New("dataapi: not supported by the Data API")
+
+// Config configures a Client.
+type Config struct {
+	// URL is the base URL of the Data API endpoint, e.g.
+	// https://data.mongodb-api.com/app/<app id>/endpoint/data/v1.
+	URL string
+	// APIKey authenticates with a Data API key. Set either it or AccessToken.
+	APIKey string
+	// AccessToken authenticates with a bearer token, e.g. from Atlas App Services auth.
+	AccessToken string
+	// DataSource is the name of the linked cluster.
+	DataSource string
+	// Database is the database collections are in.
+	Database string
+	// HTTPClient sends the requests, http.DefaultClient if nil.
+	HTTPClient *http.Client
+}
+
+// Validate reports missing or conflicting settings.
+func (cfg Config) Validate() error {
+	switch {
+	case cfg.URL == "":
+		return errors.New("dataapi: URL is required")
+	case cfg.DataSource == "" || cfg.Database == "":
+		return errors.New("dataapi: DataSource and Database are required")
+	case (cfg.APIKey == "") == (cfg.AccessToken == ""):
+		return errors.New("dataapi: exactly one of APIKey and AccessToken must be set")
+	}
+	return nil
+}
+
+// Client sends operations to a Data API endpoint. It is safe for concurrent use.
+type Client struct {
+	cfg Config
+}
+
+// New validates cfg and returns a Client for it.
+func New(cfg Config) (*Client, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = http.DefaultClient
+	}
+	cfg.URL = strings.TrimSuffix(cfg.URL, "/")
+	return &Client{cfg}, nil
+}
+
+// Collection is a collection accessed through the Data API.
+type Collection struct {
+	client *Client
+	name   string
+}
+
+var _ mongoboiler.CollectionAPI = (*Collection)(nil)
+
+// Collection returns the named collection of the configured database.
+func (c *Client) Collection(name string) *Collection {
+	return &Collection{client: c, name: name}
+}
+
+// APIError is returned for requests the Data API rejected, other than for duplicate keys, which
+// are returned as *mongoboiler.DuplicateKeyError.
+type APIError struct {
+	StatusCode int
+	// Code and Message are the error_code and error of the response, if any.
+	Code    string
+	Message string
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("dataapi: request failed with status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("dataapi: %s (status %d)", e.Message, e.StatusCode)
+}
+
+// do runs action with body, decoding the response into res.
+func (c *Collection) do(ctx context.Context, action string, body bson.D, res any) error {
+	req := append(bson.D{
+		{Key: "dataSource", Value: c.client.cfg.DataSource},
+		{Key: "database", Value: c.client.cfg.Database},
+		{Key: "collection", Value: c.name},
+	}, body...)
+	data, err := bson.MarshalExtJSON(req, true, false)
+	if err != nil {
+		return err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.client.cfg.URL+"/action/"+action, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/ejson")
+	httpReq.Header.Set("Accept", "application/ejson")
+	if c.client.cfg.APIKey != "" {
+		httpReq.Header.Set("api-key", c.client.cfg.APIKey)
+	} else {
+		httpReq.Header.Set("Authorization", "Bearer "+c.client.cfg.AccessToken)
+	}
+
+	resp, err := c.client.cfg.HTTPClient.Do(httpReq)
+	if err != nil {
+		return mongoboiler.TranslateError(err)
+	}
+	defer resp.Body.Close()
+	payload, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode/100 != 2 {
+		return responseError(resp.StatusCode, payload)
+	}
+	if res == nil {
+		return nil
+	}
+	return bson.UnmarshalExtJSON(payload, true, res)
+}
+
+// responseError converts a failed response to an error.
+func responseError(status int, payload []byte) error {
+	var body struct {
+		Error     string `bson:"error"`
+		ErrorCode string `bson:"error_code"`
+	}
+	_ = bson.UnmarshalExtJSON(payload, false, &body)
+	if strings.Contains(body.Error, "E11000") {
+		return mongoboiler.TranslateError(mongo.WriteException{WriteErrors: mongo.WriteErrors{{Code: 11000, Message: body.Error}}})
+	}
+	return &APIError{StatusCode: status, Code: body.ErrorCode, Message: body.Error}
+}
+
+// Drop is not offered by the Data API, it returns ErrUnsupported.
+func (c *Collection) Drop(ctx context.Context, confirm ...mongoboiler.DropConfirmation) error {
+	return ErrUnsupported
+}
+
+// FindOne decodes the first document matching filter into res, mongoboiler.ErrNotFound if none
+// does. The Projection and Sort options are supported.
+func (c *Collection) FindOne(ctx context.Context, filter bson.D, res any, opts ...*options.FindOneOptions) error {
+	o := options.MergeFindOneOptions(opts...)
+	if o.Skip != nil || o.Collation != nil || o.Hint != nil {
+		return fmt.Errorf("%w: FindOne options other than Projection and Sort", ErrUnsupported)
+	}
+	body := bson.D{{Key: "filter", Value: nonNil(filter)}}
+	body = appendOption(body, "projection", o.Projection)
+	body = appendOption(body, "sort", o.Sort)
+	if o.Sort != nil {
+		// findOne takes no sort, a find limited to one does.
+		var reply struct {
+			Documents []bson.Raw `bson:"documents"`
+		}
+		if err := c.do(ctx, "find", append(body, bson.E{Key: "limit", Value: 1}), &reply); err != nil {
+			return err
+		}
+		if len(reply.Documents) == 0 {
+			return mongoboiler.TranslateError(mongo.ErrNoDocuments)
+		}
+		return bson.Unmarshal(reply.Documents[0], res)
+	}
+
+	var reply struct {
+		// Document is null when nothing matched.
+		Document bson.RawValue `bson:"document"`
+	}
+	if err := c.do(ctx, "findOne", body, &reply); err != nil {
+		return err
+	}
+	doc, ok := reply.Document.DocumentOK()
+	if !ok {
+		return mongoboiler.TranslateError(mongo.ErrNoDocuments)
+	}
+	return bson.Unmarshal(doc, res)
+}
+
+// FindMany decodes all documents matching filter into the slice res points to.
+func (c *Collection) FindMany(ctx context.Context, filter bson.D, res any, opts ...*options.FindOptions) error {
+	sliceVal := reflect.ValueOf(res)
+	if sliceVal.Kind() != reflect.Ptr || sliceVal.Elem().Kind() != reflect.Slice {
+		return mongoboiler.ErrNotSlicePointer
+	}
+	sliceVal = sliceVal.Elem()
+	sliceVal.Set(sliceVal.Slice(0, 0))
+
+	return c.FindEach(ctx, filter, func(dec mongoboiler.Decoder) error {
+		elem := reflect.New(sliceVal.Type().Elem())
+		if err := dec.Decode(elem.Interface()); err != nil {
+			return err
+		}
+		sliceVal.Set(reflect.Append(sliceVal, elem.Elem()))
+		return nil
+	}, opts...)
+}
+
+// FindEach calls fn for every document matching filter. The Projection, Sort, Skip and Limit
+// options are supported. All documents are fetched with one request, the Data API caps how many
+// it returns, see its documentation.
+func (c *Collection) FindEach(ctx context.Context, filter bson.D, fn func(dec mongoboiler.Decoder) error, opts ...*options.FindOptions) error {
+	o := options.MergeFindOptions(opts...)
+	if o.Collation != nil || o.Hint != nil {
+		return fmt.Errorf("%w: Find options other than Projection, Sort, Skip and Limit", ErrUnsupported)
+	}
+	body := bson.D{{Key: "filter", Value: nonNil(filter)}}
+	body = appendOption(body, "projection", o.Projection)
+	body = appendOption(body, "sort", o.Sort)
+	if o.Skip != nil {
+		body = append(body, bson.E{Key: "skip", Value: *o.Skip})
+	}
+	if o.Limit != nil && *o.Limit > 0 {
+		body = append(body, bson.E{Key: "limit", Value: *o.Limit})
+	}
+
+	var reply struct {
+		Documents []bson.Raw `bson:"documents"`
+	}
+	if err := c.do(ctx, "find", body, &reply); err != nil {
+		return err
+	}
+	for _, doc := range reply.Documents {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(rawDecoder(doc)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// InsertOne inserts new. No options are supported.
+func (c *Collection) InsertOne(ctx context.Context, new any, opts ...*options.InsertOneOptions) (mongoboiler.InsertResult, error) {
+	o := options.MergeInsertOneOptions(opts...)
+	if o.BypassDocumentValidation != nil || o.Comment != nil {
+		return mongoboiler.InsertResult{}, fmt.Errorf("%w: InsertOne options", ErrUnsupported)
+	}
+	var reply struct {
+		InsertedID any `bson:"insertedId"`
+	}
+	if err := c.do(ctx, "insertOne", bson.D{{Key: "document", Value: new}}, &reply); err != nil {
+		return mongoboiler.InsertResult{}, err
+	}
+	return mongoboiler.InsertResult{InsertedID: reply.InsertedID, InsertedIDs: []any{reply.InsertedID}}, nil
+}
+
+// InsertMany inserts all documents in order. No options are supported, except Ordered set to
+// true.
+func (c *Collection) InsertMany(ctx context.Context, new []any, opts ...*options.InsertManyOptions) (mongoboiler.InsertResult, error) {
+	o := options.MergeInsertManyOptions(opts...)
+	if o.BypassDocumentValidation != nil || o.Comment != nil || (o.Ordered != nil && !*o.Ordered) {
+		return mongoboiler.InsertResult{}, fmt.Errorf("%w: InsertMany options other than Ordered", ErrUnsupported)
+	}
+	var reply struct {
+		InsertedIDs []any `bson:"insertedIds"`
+	}
+	if err := c.do(ctx, "insertMany", bson.D{{Key: "documents", Value: new}}, &reply); err != nil {
+		return mongoboiler.InsertResult{}, err
+	}
+	res := mongoboiler.InsertResult{InsertedIDs: reply.InsertedIDs}
+	if len(reply.InsertedIDs) > 0 {
+		res.InsertedID = reply.InsertedIDs[0]
+	}
+	return res, nil
+}
+
+// UpdateOne applies update to the first document matching filter. The Upsert option is
+// supported.
+func (c *Collection) UpdateOne(ctx context.Context, filter, update bson.D, opts ...*options.UpdateOptions) (mongoboiler.UpdateResult, error) {
+	return c.update(ctx, "updateOne", filter, update, opts)
+}
+
+// UpdateMany applies update to all documents matching filter. The Upsert option is supported.
+func (c *Collection) UpdateMany(ctx context.Context, filter, update bson.D, opts ...*options.UpdateOptions) (mongoboiler.UpdateResult, error) {
+	return c.update(ctx, "updateMany", filter, update, opts)
+}
+
+func (c *Collection) update(ctx context.Context, action string, filter, update bson.D, opts []*options.UpdateOptions) (mongoboiler.UpdateResult, error) {
+	o := options.MergeUpdateOptions(opts...)
+	if o.ArrayFilters != nil || o.Collation != nil || o.Hint != nil {
+		return mongoboiler.UpdateResult{}, fmt.Errorf("%w: update options other than Upsert", ErrUnsupported)
+	}
+	return c.write(ctx, action, filter, bson.E{Key: "update", Value: update}, o.Upsert)
+}
+
+// ReplaceOne replaces the first document matching filter with doc. The Upsert option is
+// supported.
+func (c *Collection) ReplaceOne(ctx context.Context, filter bson.D, doc any, opts ...*options.ReplaceOptions) (mongoboiler.UpdateResult, error) {
+	o := options.MergeReplaceOptions(opts...)
+	if o.Collation != nil || o.Hint != nil {
+		return mongoboiler.UpdateResult{}, fmt.Errorf("%w: replace options other than Upsert", ErrUnsupported)
+	}
+	return c.write(ctx, "replaceOne", filter, bson.E{Key: "replacement", Value: doc}, o.Upsert)
+}
+
+func (c *Collection) write(ctx context.Context, action string, filter bson.D, change bson.E, upsert *bool) (mongoboiler.UpdateResult, error) {
+	body := bson.D{{Key: "filter", Value: nonNil(filter)}, change}
+	if upsert != nil {
+		body = append(body, bson.E{Key: "upsert", Value: *upsert})
+	}
+	var reply struct {
+		MatchedCount  int64 `bson:"matchedCount"`
+		ModifiedCount int64 `bson:"modifiedCount"`
+		UpsertedID    any   `bson:"upsertedId"`
+	}
+	if err := c.do(ctx, action, body, &reply); err != nil {
+		return mongoboiler.UpdateResult{}, err
+	}
+	res := mongoboiler.UpdateResult{
+		MatchedCount:  reply.MatchedCount,
+		ModifiedCount: reply.ModifiedCount,
+		UpsertedID:    reply.UpsertedID,
+		DocumentID:    reply.UpsertedID,
+	}
+	if reply.UpsertedID != nil {
+		res.UpsertedCount = 1
+	}
+	return res, nil
+}
+
+// DeleteOne deletes the first document matching filter. No options are supported.
+func (c *Collection) DeleteOne(ctx context.Context, filter bson.D, opts ...*options.DeleteOptions) (mongoboiler.DeleteResult, error) {
+	return c.delete(ctx, "deleteOne", filter, opts)
+}
+
+// DeleteMany deletes all documents matching filter. No options are supported.
+func (c *Collection) DeleteMany(ctx context.Context, filter bson.D, opts ...*options.DeleteOptions) (mongoboiler.DeleteResult, error) {
+	return c.delete(ctx, "deleteMany", filter, opts)
+}
+
+func (c *Collection) delete(ctx context.Context, action string, filter bson.D, opts []*options.DeleteOptions) (mongoboiler.DeleteResult, error) {
+	o := options.MergeDeleteOptions(opts...)
+	if o.Collation != nil || o.Comment != nil || o.Hint != nil || o.Let != nil {
+		return mongoboiler.DeleteResult{}, fmt.Errorf("%w: delete options", ErrUnsupported)
+	}
+	var reply struct {
+		DeletedCount int64 `bson:"deletedCount"`
+	}
+	if err := c.do(ctx, action, bson.D{{Key: "filter", Value: nonNil(filter)}}, &reply); err != nil {
+		return mongoboiler.DeleteResult{}, err
+	}
+	return mongoboiler.DeleteResult{DeletedCount: reply.DeletedCount}, nil
+}
+
+func nonNil(filter bson.D) bson.D {
+	if filter == nil {
+		return bson.D{}
+	}
+	return filter
+}
+
+func appendOption(body bson.D, key string, v any) bson.D {
+	if v == nil {
+		return body
+	}
+	return append(body, bson.E{Key: key, Value: v})
+}
+
+// rawDecoder decodes a document of a response.
+type rawDecoder bson.Raw
+
+func (d rawDecoder) Decode(v any) error {
+	return bson.Unmarshal(bson.Raw(d), v)
+}
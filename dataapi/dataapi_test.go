@@ -0,0 +1,189 @@
+package dataapi
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/anurag925/mongoboiler"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type request struct {
+	Action string
+	Body   bson.M
+}
+
+// testServer answers every request with status and response, recording the requests.
+func testServer(t *testing.T, requests *[]request, status int, response string) *Collection {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("api-key") != "secret" || r.Header.Get("Content-Type") != "application/ejson" {
+			t.Errorf("unexpected headers %v", r.Header)
+		}
+		data, _ := io.ReadAll(r.Body)
+		var body bson.M
+		if err := bson.UnmarshalExtJSON(data, true, &body); err != nil {
+			t.Errorf("request body is not Extended JSON: %v", err)
+		}
+		*requests = append(*requests, request{Action: r.URL.Path, Body: body})
+		w.WriteHeader(status)
+		_, _ = io.WriteString(w, response)
+	}))
+	t.Cleanup(srv.Close)
+
+	client, err := New(Config{URL: srv.URL + "/", APIKey: "secret", DataSource: "Cluster0", Database: "shop"})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	return client.Collection("orders")
+}
+
+func TestConfig_Validate(t *testing.T) {
+	base := Config{URL: "https://example.com", APIKey: "k", DataSource: "c", Database: "d"}
+	if err := base.Validate(); err != nil {
+		t.Fatalf("expected a valid config, got %v", err)
+	}
+	both := base
+	both.AccessToken = "t"
+	for _, cfg := range []Config{{}, {URL: "https://example.com", APIKey: "k"}, both} {
+		if err := cfg.Validate(); err == nil {
+			t.Fatalf("expected %+v to be invalid", cfg)
+		}
+	}
+}
+
+func TestDataAPI_FindOne(t *testing.T) {
+	var requests []request
+	id := primitive.NewObjectID()
+	coll := testServer(t, &requests, http.StatusOK, `{"document": {"_id": {"$oid": "`+id.Hex()+`"}, "qty": {"$numberInt": "2"}}}`)
+
+	var doc struct {
+		ID  primitive.ObjectID `bson:"_id"`
+		Qty int                `bson:"qty"`
+	}
+	err := coll.FindOne(context.Background(), bson.D{{Key: "_id", Value: id}}, &doc,
+		options.FindOne().SetProjection(bson.D{{Key: "qty", Value: 1}}))
+	if err != nil {
+		t.Fatalf("FindOne failed: %v", err)
+	}
+	if doc.ID != id || doc.Qty != 2 {
+		t.Fatalf("unexpected document %+v", doc)
+	}
+	req := requests[0]
+	if req.Action != "/action/findOne" || req.Body["dataSource"] != "Cluster0" || req.Body["database"] != "shop" ||
+		req.Body["collection"] != "orders" || req.Body["filter"].(bson.M)["_id"] != id || req.Body["projection"] == nil {
+		t.Fatalf("unexpected request %+v", req)
+	}
+}
+
+func TestDataAPI_FindOneNotFound(t *testing.T) {
+	var requests []request
+	coll := testServer(t, &requests, http.StatusOK, `{"document": null}`)
+	var doc bson.M
+	if err := coll.FindOne(context.Background(), nil, &doc); !errors.Is(err, mongoboiler.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestDataAPI_FindMany(t *testing.T) {
+	var requests []request
+	coll := testServer(t, &requests, http.StatusOK, `{"documents": [{"n": 1}, {"n": 2}]}`)
+
+	var docs []struct {
+		N int `bson:"n"`
+	}
+	err := coll.FindMany(context.Background(), bson.D{}, &docs, options.Find().SetSort(bson.D{{Key: "n", Value: 1}}).SetLimit(5))
+	if err != nil {
+		t.Fatalf("FindMany failed: %v", err)
+	}
+	if len(docs) != 2 || docs[1].N != 2 {
+		t.Fatalf("unexpected documents %+v", docs)
+	}
+	if req := requests[0]; req.Action != "/action/find" || req.Body["limit"] != int64(5) || req.Body["sort"] == nil {
+		t.Fatalf("unexpected request %+v", req)
+	}
+}
+
+func TestDataAPI_UpdateOne(t *testing.T) {
+	var requests []request
+	coll := testServer(t, &requests, http.StatusOK, `{"matchedCount": 0, "modifiedCount": 0, "upsertedId": "a1"}`)
+
+	res, err := coll.UpdateOne(context.Background(), bson.D{{Key: "_id", Value: "a1"}},
+		bson.D{{Key: "$set", Value: bson.D{{Key: "n", Value: 1}}}}, options.Update().SetUpsert(true))
+	if err != nil {
+		t.Fatalf("UpdateOne failed: %v", err)
+	}
+	if res.Outcome() != mongoboiler.OutcomeCreated || res.DocumentID != "a1" {
+		t.Fatalf("unexpected result %+v", res)
+	}
+	if req := requests[0]; req.Action != "/action/updateOne" || req.Body["upsert"] != true {
+		t.Fatalf("unexpected request %+v", req)
+	}
+
+	_, err = coll.UpdateOne(context.Background(), nil, nil, options.Update().SetArrayFilters(options.ArrayFilters{}))
+	if !errors.Is(err, ErrUnsupported) || len(requests) != 1 {
+		t.Fatalf("expected ErrUnsupported without a request, got %v", err)
+	}
+}
+
+func TestDataAPI_UnsupportedWriteOptions(t *testing.T) {
+	var requests []request
+	coll := testServer(t, &requests, http.StatusOK, `{"insertedIds": ["a1"], "deletedCount": 1}`)
+	ctx := context.Background()
+
+	if _, err := coll.InsertMany(ctx, []any{bson.D{}}, options.InsertMany().SetOrdered(true)); err != nil {
+		t.Fatalf("InsertMany with the default ordering failed: %v", err)
+	}
+	for name, call := range map[string]func() error{
+		"InsertOne": func() error {
+			_, err := coll.InsertOne(ctx, bson.D{}, options.InsertOne().SetBypassDocumentValidation(true))
+			return err
+		},
+		"InsertMany": func() error {
+			_, err := coll.InsertMany(ctx, []any{bson.D{}}, options.InsertMany().SetOrdered(false))
+			return err
+		},
+		"DeleteOne": func() error {
+			_, err := coll.DeleteOne(ctx, bson.D{}, options.Delete().SetHint("status_1"))
+			return err
+		},
+		"DeleteMany": func() error {
+			_, err := coll.DeleteMany(ctx, bson.D{}, options.Delete().SetComment("cleanup"))
+			return err
+		},
+	} {
+		if err := call(); !errors.Is(err, ErrUnsupported) {
+			t.Errorf("%s: expected ErrUnsupported, got %v", name, err)
+		}
+	}
+	if len(requests) != 1 {
+		t.Fatalf("expected no requests for unsupported options, got %d", len(requests)-1)
+	}
+}
+
+func TestDataAPI_Errors(t *testing.T) {
+	var requests []request
+	coll := testServer(t, &requests, http.StatusBadRequest,
+		`{"error": "Duplicate key error: E11000 duplicate key error collection: shop.orders index: email_1 dup key: { email: \"a@b.c\" }", "error_code": "DuplicateKey"}`)
+	_, err := coll.InsertOne(context.Background(), bson.D{{Key: "email", Value: "a@b.c"}})
+	var dup *mongoboiler.DuplicateKeyError
+	if !errors.As(err, &dup) || dup.Index != "email_1" {
+		t.Fatalf("expected a DuplicateKeyError, got %v", err)
+	}
+
+	coll = testServer(t, &requests, http.StatusUnauthorized, `{"error": "invalid session", "error_code": "InvalidSession"}`)
+	_, err = coll.DeleteMany(context.Background(), bson.D{})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized || apiErr.Code != "InvalidSession" {
+		t.Fatalf("expected an APIError, got %v", err)
+	}
+	if err := coll.Drop(context.Background()); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("expected ErrUnsupported, got %v", err)
+	}
+}
@@ -0,0 +1,199 @@
+package mongoboiler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsoncodec"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// DecodeMode controls how documents that do not match the result type are decoded. The zero
+// value is DecodeDefault rather than DecodeLenient: collections without WithDecodeMode keep
+// failing on type mismatches, as they did before decode modes existed and as WithQuarantine
+// relies on. Production code wanting leniency sets DecodeLenient explicitly.
+type DecodeMode int
+
+const (
+	// DecodeDefault is the driver's behavior: unknown fields are ignored and type mismatches fail.
+	DecodeDefault DecodeMode = iota
+	// DecodeStrict also fails on fields the result struct has no field for, catching schema
+	// drift in tests.
+	DecodeStrict
+	// DecodeLenient skips fields whose type does not match the struct field, leaving it zero,
+	// so one bad field does not fail a whole read.
+	DecodeLenient
+)
+
+// ErrUnknownField is returned in strict mode for document fields missing from the result struct.
+var ErrUnknownField = errors.New("mongoboiler: document has a field the result type does not")
+
+// WithDecodeMode sets how read results are decoded.
+func WithDecodeMode(mode DecodeMode) Option {
+	return func(s *settings) {
+		s.decodeMode = mode
+	}
+}
+
+// decode decodes raw into v according to the collection's decode mode, decrypting encrypted
+// fields, unescaping keys and running decode hooks first. Every read method decodes through it.
+func (c Collection) decode(ctx context.Context, raw bson.Raw, v any) error {
+	mode := DecodeDefault
+	if c.settings != nil {
+		mode = c.settings.decodeMode
+		var err error
+		if enc := c.settings.encryption; enc != nil {
+			if raw, err = enc.decryptDocument(ctx, raw); err != nil {
+				return err
+			}
+		}
+		if c.settings.escapeKeys {
+			if raw, err = unescapeKeys(raw); err != nil {
+				return err
+			}
+		}
+		if hooks := c.settings.decodeHooks; len(hooks) > 0 {
+			if raw, _, err = applyDecodeHooks(raw, reflect.TypeOf(v), hooks, ""); err != nil {
+				return err
+			}
+		}
+	}
+	switch mode {
+	case DecodeStrict:
+		if err := checkKnownFields(raw, reflect.TypeOf(v), ""); err != nil {
+			return err
+		}
+	case DecodeLenient:
+		err := bson.Unmarshal(raw, v)
+		if err == nil || !isStructPointer(v) {
+			return err
+		}
+		return decodeFieldByField(raw, v)
+	}
+	return bson.Unmarshal(raw, v)
+}
+
+func isStructPointer(v any) bool {
+	t := reflect.TypeOf(v)
+	return t != nil && t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Struct
+}
+
+// decodeFieldByField decodes the elements of raw into struct pointer v one at a time,
+// skipping those that fail.
+func decodeFieldByField(raw bson.Raw, v any) error {
+	reflect.ValueOf(v).Elem().Set(reflect.Zero(reflect.TypeOf(v).Elem()))
+	elems, err := raw.Elements()
+	if err != nil {
+		return err
+	}
+	for _, e := range elems {
+		single, err := bson.Marshal(bson.D{{Key: e.Key(), Value: e.Value()}})
+		if err != nil {
+			return err
+		}
+		// The struct codec leaves fields missing from single untouched.
+		_ = bson.Unmarshal(single, v)
+	}
+	return nil
+}
+
+var (
+	unmarshalerType      = reflect.TypeOf((*bson.Unmarshaler)(nil)).Elem()
+	valueUnmarshalerType = reflect.TypeOf((*bson.ValueUnmarshaler)(nil)).Elem()
+)
+
+// checkKnownFields returns ErrUnknownField for the first field of raw that struct type t (or a
+// nested struct) has no field for.
+func checkKnownFields(raw bson.Raw, t reflect.Type, prefix string) error {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct || t == timeType ||
+		reflect.PtrTo(t).Implements(unmarshalerType) || reflect.PtrTo(t).Implements(valueUnmarshalerType) {
+		return nil
+	}
+
+	fields := map[string]reflect.Type{}
+	if catchAll, err := structFieldTypes(t, fields); err != nil || catchAll {
+		return err
+	}
+	elems, err := raw.Elements()
+	if err != nil {
+		return err
+	}
+	for _, e := range elems {
+		ft, ok := fields[e.Key()]
+		if !ok {
+			return fmt.Errorf("%w: %s%s", ErrUnknownField, prefix, e.Key())
+		}
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		switch val := e.Value(); {
+		case val.Type == bson.TypeEmbeddedDocument:
+			err = checkKnownFields(val.Document(), ft, prefix+e.Key()+".")
+		case val.Type == bson.TypeArray && (ft.Kind() == reflect.Slice || ft.Kind() == reflect.Array):
+			values, _ := val.Array().Values()
+			for i, item := range values {
+				if doc, ok := item.DocumentOK(); ok && err == nil {
+					err = checkKnownFields(doc, ft.Elem(), fmt.Sprintf("%s%s.%d.", prefix, e.Key(), i))
+				}
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// structFieldTypes collects the document field names of t with their types. It reports whether
+// t has an inline map, which takes any field.
+func structFieldTypes(t reflect.Type, fields map[string]reflect.Type) (bool, error) {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		tags, err := bsoncodec.DefaultStructTagParser(sf)
+		if err != nil {
+			return false, err
+		}
+		if tags.Skip {
+			continue
+		}
+		if tags.Inline {
+			ft := sf.Type
+			for ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Map {
+				return true, nil
+			}
+			if catchAll, err := structFieldTypes(ft, fields); err != nil || catchAll {
+				return catchAll, err
+			}
+			continue
+		}
+		fields[tags.Name] = sf.Type
+	}
+	return false, nil
+}
+
+// cursorDecoder decodes the documents of a cursor with the collection's decode mode.
+type cursorDecoder struct {
+	ctx    context.Context
+	cursor *mongo.Cursor
+	coll   Collection
+}
+
+func (d cursorDecoder) Decode(v any) error {
+	return d.coll.decode(d.ctx, d.cursor.Current, v)
+}
+
+func (d cursorDecoder) Raw() bson.Raw {
+	return d.cursor.Current
+}
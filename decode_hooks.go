@@ -0,0 +1,187 @@
+package mongoboiler
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// DecodeHook converts a stored value before it is decoded into a field of type target, for
+// documents written with older types. It returns the replacement and true, or false to leave the
+// value to the next hook and the driver. target never is a pointer type.
+type DecodeHook func(value bson.RawValue, target reflect.Type) (any, bool, error)
+
+// WithDecodeHooks runs hooks on every field of a read document that has a struct field, also in
+// nested documents and arrays, before it is decoded. The first hook returning true wins; a hook
+// error fails the read, also with DecodeLenient.
+// Collection options append to the hooks of the DB.
+func WithDecodeHooks(hooks ...DecodeHook) Option {
+	return func(s *settings) {
+		s.decodeHooks = append(append([]DecodeHook(nil), s.decodeHooks...), hooks...)
+	}
+}
+
+// CoerceStringNumbers decodes strings such as "42" or "4.5" into integer and float fields.
+func CoerceStringNumbers() DecodeHook {
+	return func(value bson.RawValue, target reflect.Type) (any, bool, error) {
+		s, ok := value.StringValueOK()
+		if !ok {
+			return nil, false, nil
+		}
+		s = strings.TrimSpace(s)
+		switch target.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			n, err := strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				return nil, false, fmt.Errorf("coercing %q to %s: %w", s, target, err)
+			}
+			return n, true, nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			// The driver decodes unsigned fields from int64.
+			n, err := strconv.ParseInt(s, 10, 64)
+			if err != nil || n < 0 {
+				return nil, false, fmt.Errorf("coercing %q to %s: not an unsigned integer", s, target)
+			}
+			return n, true, nil
+		case reflect.Float32, reflect.Float64:
+			f, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return nil, false, fmt.Errorf("coercing %q to %s: %w", s, target, err)
+			}
+			return f, true, nil
+		}
+		return nil, false, nil
+	}
+}
+
+// CoerceDates decodes strings in one of layouts, RFC 3339 if none are given, and integer Unix
+// timestamps in seconds into time.Time and primitive.DateTime fields.
+func CoerceDates(layouts ...string) DecodeHook {
+	if len(layouts) == 0 {
+		layouts = []string{time.RFC3339Nano}
+	}
+	return func(value bson.RawValue, target reflect.Type) (any, bool, error) {
+		if target != timeType && target != dateTimeType {
+			return nil, false, nil
+		}
+		switch value.Type {
+		case bson.TypeString:
+			s := value.StringValue()
+			for _, layout := range layouts {
+				if t, err := time.Parse(layout, s); err == nil {
+					return t, true, nil
+				}
+			}
+			return nil, false, fmt.Errorf("coercing %q to a date: no layout matches", s)
+		case bson.TypeInt32, bson.TypeInt64:
+			n, _ := value.AsInt64OK()
+			return time.Unix(n, 0), true, nil
+		}
+		return nil, false, nil
+	}
+}
+
+// CoerceStringObjectIDs decodes hex strings into primitive.ObjectID fields.
+func CoerceStringObjectIDs() DecodeHook {
+	return func(value bson.RawValue, target reflect.Type) (any, bool, error) {
+		s, ok := value.StringValueOK()
+		if !ok || target != objectIDType {
+			return nil, false, nil
+		}
+		id, err := primitive.ObjectIDFromHex(s)
+		if err != nil {
+			return nil, false, fmt.Errorf("coercing %q to an ObjectID: %w", s, err)
+		}
+		return id, true, nil
+	}
+}
+
+// applyDecodeHooks returns raw with the values replaced that hooks convert for struct type t,
+// and whether there were any.
+func applyDecodeHooks(raw bson.Raw, t reflect.Type, hooks []DecodeHook, prefix string) (bson.Raw, bool, error) {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct || t == timeType ||
+		reflect.PtrTo(t).Implements(unmarshalerType) || reflect.PtrTo(t).Implements(valueUnmarshalerType) {
+		return raw, false, nil
+	}
+	fields := map[string]reflect.Type{}
+	if _, err := structFieldTypes(t, fields); err != nil {
+		return nil, false, err
+	}
+	elems, err := raw.Elements()
+	if err != nil {
+		return nil, false, err
+	}
+
+	out := make(bson.D, len(elems))
+	changed := false
+	for i, e := range elems {
+		out[i] = bson.E{Key: e.Key(), Value: e.Value()}
+		ft, ok := fields[e.Key()]
+		if !ok {
+			continue
+		}
+		replaced, ok, err := hookValue(e.Value(), ft, hooks, prefix+e.Key())
+		if err != nil {
+			return nil, false, err
+		}
+		if ok {
+			out[i].Value = replaced
+			changed = true
+		}
+	}
+	if !changed {
+		return raw, false, nil
+	}
+	data, err := bson.Marshal(out)
+	return data, true, err
+}
+
+// hookValue applies hooks to the value at path decoded into type t, descending into documents
+// and arrays.
+func hookValue(val bson.RawValue, t reflect.Type, hooks []DecodeHook, path string) (any, bool, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	for _, hook := range hooks {
+		replaced, ok, err := hook(val, t)
+		if err != nil {
+			return nil, false, fmt.Errorf("mongoboiler: decoding %s: %w", path, err)
+		}
+		if ok {
+			return replaced, true, nil
+		}
+	}
+
+	switch {
+	case val.Type == bson.TypeEmbeddedDocument:
+		return applyDecodeHooks(val.Document(), t, hooks, path+".")
+	case val.Type == bson.TypeArray && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array):
+		values, err := val.Array().Values()
+		if err != nil {
+			return nil, false, err
+		}
+		items := make(bson.A, len(values))
+		changed := false
+		for i, item := range values {
+			items[i] = item
+			replaced, ok, err := hookValue(item, t.Elem(), hooks, fmt.Sprintf("%s.%d", path, i))
+			if err != nil {
+				return nil, false, err
+			}
+			if ok {
+				items[i] = replaced
+				changed = true
+			}
+		}
+		return items, changed, nil
+	}
+	return nil, false, nil
+}
@@ -0,0 +1,89 @@
+package mongoboiler
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type legacyOrder struct {
+	ID       primitive.ObjectID `bson:"_id"`
+	Total    float64            `bson:"total"`
+	PlacedAt time.Time          `bson:"placedAt"`
+	Items    []decodeItem       `bson:"items"`
+	Codes    []int              `bson:"codes"`
+	Note     string             `bson:"note"`
+}
+
+func TestDecodeHooks_Coerce(t *testing.T) {
+	coll := newTestCollection(t, "orders", WithDecodeHooks(
+		CoerceStringNumbers(), CoerceDates("2006-01-02"), CoerceStringObjectIDs(),
+	))
+	id := primitive.NewObjectID()
+	raw := mustRaw(t, bson.D{
+		{Key: "_id", Value: id.Hex()},
+		{Key: "total", Value: "12.5"},
+		{Key: "placedAt", Value: "2021-03-04"},
+		{Key: "items", Value: bson.A{
+			bson.D{{Key: "name", Value: "pen"}, {Key: "qty", Value: "3"}},
+			bson.D{{Key: "name", Value: "ink"}, {Key: "qty", Value: 1}},
+		}},
+		{Key: "codes", Value: bson.A{"7", 8}},
+		{Key: "note", Value: "42"},
+		{Key: "extra", Value: "1"},
+	})
+
+	var order legacyOrder
+	if err := coll.decode(context.Background(), raw, &order); err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if order.ID != id || order.Total != 12.5 || !order.PlacedAt.Equal(time.Date(2021, 3, 4, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("scalars not coerced: %+v", order)
+	}
+	if len(order.Items) != 2 || order.Items[0].Qty != 3 || order.Items[1].Qty != 1 {
+		t.Fatalf("nested documents not coerced: %+v", order.Items)
+	}
+	if len(order.Codes) != 2 || order.Codes[0] != 7 || order.Codes[1] != 8 {
+		t.Fatalf("array items not coerced: %v", order.Codes)
+	}
+	if order.Note != "42" {
+		t.Fatalf("string field changed to %q", order.Note)
+	}
+}
+
+func TestDecodeHooks_UnixDates(t *testing.T) {
+	coll := newTestCollection(t, "orders", WithDecodeHooks(CoerceDates()))
+	raw := mustRaw(t, bson.D{{Key: "placedAt", Value: int64(1600000000)}})
+
+	var order legacyOrder
+	if err := coll.decode(context.Background(), raw, &order); err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if order.PlacedAt.Unix() != 1600000000 {
+		t.Fatalf("placedAt = %v", order.PlacedAt)
+	}
+}
+
+func TestDecodeHooks_Error(t *testing.T) {
+	coll := newTestCollection(t, "orders", WithDecodeHooks(CoerceStringNumbers()))
+	raw := mustRaw(t, bson.D{{Key: "items", Value: bson.A{bson.D{{Key: "qty", Value: "many"}}}}})
+
+	var order legacyOrder
+	err := coll.decode(context.Background(), raw, &order)
+	if err == nil || !strings.Contains(err.Error(), "items.0.qty") {
+		t.Fatalf("expected error naming items.0.qty, got %v", err)
+	}
+}
+
+func TestDecodeHooks_Unchanged(t *testing.T) {
+	raw := mustRaw(t, bson.D{{Key: "name", Value: "pen"}, {Key: "qty", Value: 2}})
+	out, changed, err := applyDecodeHooks(raw, reflect.TypeOf(decodeItem{}), []DecodeHook{CoerceStringNumbers()}, "")
+	if err != nil || changed || &out[0] != &raw[0] {
+		t.Fatalf("document without legacy values was rewritten: changed=%v err=%v", changed, err)
+	}
+}
@@ -0,0 +1,59 @@
+package mongoboiler
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type decodeItem struct {
+	Name string `bson:"name"`
+	Qty  int    `bson:"qty"`
+}
+
+type decodeOrder struct {
+	Number string       `bson:"number"`
+	Items  []decodeItem `bson:"items"`
+}
+
+func mustRaw(t *testing.T, doc bson.D) bson.Raw {
+	t.Helper()
+	data, err := bson.Marshal(doc)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	return data
+}
+
+func TestDecode_Strict(t *testing.T) {
+	coll := newTestCollection(t, "orders", WithDecodeMode(DecodeStrict))
+	raw := mustRaw(t, bson.D{
+		{Key: "number", Value: "A1"},
+		{Key: "items", Value: bson.A{bson.D{{Key: "name", Value: "pen"}, {Key: "colour", Value: "red"}}}},
+	})
+
+	var order decodeOrder
+	err := coll.decode(context.Background(), raw, &order)
+	if !errors.Is(err, ErrUnknownField) || err.Error() != ErrUnknownField.Error()+": items.0.colour" {
+		t.Fatalf("expected unknown field items.0.colour, got %v", err)
+	}
+}
+
+func TestDecode_Lenient(t *testing.T) {
+	coll := newTestCollection(t, "orders", WithDecodeMode(DecodeLenient))
+	raw := mustRaw(t, bson.D{{Key: "name", Value: "pen"}, {Key: "qty", Value: "three"}})
+
+	var item decodeItem
+	if err := coll.decode(context.Background(), raw, &item); err != nil {
+		t.Fatalf("lenient decode failed: %v", err)
+	}
+	if item != (decodeItem{Name: "pen"}) {
+		t.Fatalf("unexpected item %+v", item)
+	}
+
+	if err := newTestCollection(t, "orders").decode(context.Background(), raw, &item); err == nil {
+		t.Fatalf("expected the default mode to fail on the type mismatch")
+	}
+}
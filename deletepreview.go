@@ -0,0 +1,164 @@
+package mongoboiler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// DefaultDeletePreviewsCollection is the collection PreviewDelete keeps its previews in until
+// they are confirmed.
+const DefaultDeletePreviewsCollection = "deletePreviews"
+
+// DeletePreviewExpiry is how long ConfirmDelete accepts the token of a preview.
+const DeletePreviewExpiry = 15 * time.Minute
+
+var (
+	// ErrDeletePreviewNotFound is returned by ConfirmDelete for tokens that are unknown, expired,
+	// already confirmed or of another collection.
+	ErrDeletePreviewNotFound = errors.New("mongoboiler: no such delete preview")
+	// ErrDeletePreviewChanged is returned by ConfirmDelete when the filter no longer matches as
+	// many documents as previewed. The preview is discarded, so a new one must be reviewed.
+	ErrDeletePreviewChanged = errors.New("mongoboiler: documents matching the delete changed since the preview")
+)
+
+// WithDeletePreviewsCollection changes the collection PreviewDelete keeps its previews in.
+func WithDeletePreviewsCollection(name string) Option {
+	return func(s *settings) {
+		s.deletePreviewsCollection = name
+	}
+}
+
+// DeletePreview is what a DeleteMany with the previewed filter would delete, see PreviewDelete.
+type DeletePreview struct {
+	// Token confirms the delete with ConfirmDelete until ExpiresAt.
+	Token     string
+	ExpiresAt time.Time
+	// Count is the number of matching documents, Sample a random selection of them.
+	Count  int64
+	Sample []bson.Raw
+}
+
+// deletePreview is a stored preview.
+type deletePreview struct {
+	Token      string    `bson:"_id"`
+	Database   string    `bson:"database"`
+	Collection string    `bson:"collection"`
+	Filter     bson.Raw  `bson:"filter"`
+	Count      int64     `bson:"count"`
+	CreatedAt  time.Time `bson:"createdAt"`
+	ExpiresAt  time.Time `bson:"expiresAt"`
+}
+
+func (db *DB) deletePreviews() *Collection {
+	name := DefaultDeletePreviewsCollection
+	if db.settings != nil && db.settings.deletePreviewsCollection != "" {
+		name = db.settings.deletePreviewsCollection
+	}
+	return db.NewCollection(name)
+}
+
+// EnsureDeletePreviewIndexes creates the TTL index removing expired previews from the previews
+// collection. Previews work without it, it only keeps the collection small.
+func (db *DB) EnsureDeletePreviewIndexes(ctx context.Context) error {
+	return db.deletePreviews().EnableTTL(ctx, "expiresAt", 0)
+}
+
+// PreviewDelete counts the documents matching filter and samples up to sample of them, for an
+// operator to review before ConfirmDelete deletes them with the returned token. Admin tools use
+// the two steps to show what a delete would do and run it only once approved; the preview is
+// stored in the database, so both steps may run in different processes.
+func (c Collection) PreviewDelete(ctx context.Context, filter bson.D, sample int) (DeletePreview, error) {
+	filter = nonNilFilter(filter)
+	count, err := c.CountDocuments(ctx, filter)
+	if err != nil {
+		return DeletePreview{}, err
+	}
+	var docs []bson.Raw
+	if sample > 0 && count > 0 {
+		pipeline := mongo.Pipeline{
+			{{Key: "$match", Value: filter}},
+			{{Key: "$sample", Value: bson.D{{Key: "size", Value: sample}}}},
+		}
+		if err := c.Aggregate(ctx, pipeline, &docs); err != nil {
+			return DeletePreview{}, err
+		}
+	}
+
+	raw, err := bson.Marshal(filter)
+	if err != nil {
+		return DeletePreview{}, err
+	}
+	token, err := newDeletePreviewToken()
+	if err != nil {
+		return DeletePreview{}, err
+	}
+	now := time.Now().UTC()
+	preview := deletePreview{
+		Token:      token,
+		Database:   c.db.databaseName(),
+		Collection: c.collectionName(),
+		Filter:     raw,
+		Count:      count,
+		CreatedAt:  now,
+		ExpiresAt:  now.Add(DeletePreviewExpiry),
+	}
+	if _, err := c.db.deletePreviews().InsertOne(ctx, preview); err != nil {
+		return DeletePreview{}, fmt.Errorf("mongoboiler: storing delete preview: %w", err)
+	}
+	return DeletePreview{Token: token, ExpiresAt: preview.ExpiresAt, Count: count, Sample: docs}, nil
+}
+
+// ConfirmDelete deletes the documents previewed with PreviewDelete. The token can be used once;
+// it fails with ErrDeletePreviewNotFound once expired or used, and with ErrDeletePreviewChanged
+// when the filter matches a different number of documents now, e.g. because documents were
+// inserted since.
+func (c Collection) ConfirmDelete(ctx context.Context, token string) (DeleteResult, error) {
+	previews := c.db.deletePreviews()
+	var preview deletePreview
+	err := previews.FindOne(ctx, bson.D{{Key: "_id", Value: token}}, &preview)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return DeleteResult{}, ErrDeletePreviewNotFound
+	}
+	if err != nil {
+		return DeleteResult{}, err
+	}
+	if preview.Database != c.db.databaseName() || preview.Collection != c.collectionName() || time.Now().After(preview.ExpiresAt) {
+		return DeleteResult{}, ErrDeletePreviewNotFound
+	}
+	// Deleting the preview claims it, so concurrent confirmations delete once.
+	claimed, err := previews.DeleteOne(ctx, bson.D{{Key: "_id", Value: token}})
+	if err != nil {
+		return DeleteResult{}, err
+	}
+	if claimed.DeletedCount == 0 {
+		return DeleteResult{}, ErrDeletePreviewNotFound
+	}
+
+	var filter bson.D
+	if err := bson.Unmarshal(preview.Filter, &filter); err != nil {
+		return DeleteResult{}, err
+	}
+	count, err := c.CountDocuments(ctx, filter)
+	if err != nil {
+		return DeleteResult{}, err
+	}
+	if count != preview.Count {
+		return DeleteResult{}, fmt.Errorf("%w: %d documents match, %d were previewed", ErrDeletePreviewChanged, count, preview.Count)
+	}
+	return c.DeleteMany(ctx, filter)
+}
+
+func newDeletePreviewToken() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b[:]), nil
+}
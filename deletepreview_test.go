@@ -0,0 +1,58 @@
+package mongoboiler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestConfirmDelete_Rejects(t *testing.T) {
+	var deletes int
+	coll := newTestCollection(t, "orders", WithCache(NewLRUCache(100), 0), WithMiddleware(func(next Handler) Handler {
+		return func(ctx context.Context, op *Operation) error {
+			if op.Kind == OpDeleteOne || op.Kind == OpDeleteMany {
+				deletes++
+				return nil
+			}
+			return next(ctx, op)
+		}
+	}))
+	previews := coll.db.deletePreviews()
+	byToken := func(token string) bson.D { return bson.D{{Key: "_id", Value: token}} }
+	preview := func(token, collection string, expiresAt time.Time) {
+		primeFindOne(t, previews, byToken(token), bson.D{
+			{Key: "_id", Value: token},
+			{Key: "database", Value: "testdb"},
+			{Key: "collection", Value: collection},
+			{Key: "filter", Value: bson.D{{Key: "status", Value: "void"}}},
+			{Key: "count", Value: int64(3)},
+			{Key: "expiresAt", Value: expiresAt},
+		})
+	}
+	preview("other", "invoices", time.Now().Add(time.Minute))
+	preview("expired", "orders", time.Now().Add(-time.Second))
+	// Confirmed concurrently: the preview is gone by the time it is claimed.
+	preview("claimed", "orders", time.Now().Add(time.Minute))
+	setCachedMiss(t, previews, byToken("unknown"))
+
+	// The claim invalidates the cached previews, so it is tried last.
+	for _, token := range []string{"unknown", "other", "expired", "claimed"} {
+		if _, err := coll.ConfirmDelete(context.Background(), token); !errors.Is(err, ErrDeletePreviewNotFound) {
+			t.Errorf("token %s: got %v, want ErrDeletePreviewNotFound", token, err)
+		}
+	}
+	if deletes != 1 {
+		t.Fatalf("got %d deletes, want only the claim of the unexpired preview", deletes)
+	}
+}
+
+func TestNewDeletePreviewToken(t *testing.T) {
+	a, err := newDeletePreviewToken()
+	b, _ := newDeletePreviewToken()
+	if err != nil || len(a) != 32 || a == b {
+		t.Fatalf("tokens %q and %q, %v", a, b, err)
+	}
+}
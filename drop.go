@@ -0,0 +1,38 @@
+package mongoboiler
+
+import "errors"
+
+// ErrDropNotConfirmed is returned by Drop on protected collections without a matching
+// ConfirmDrop token.
+var ErrDropNotConfirmed = errors.New("mongoboiler: drop of a protected collection needs ConfirmDrop with its name")
+
+// DropConfirmation authorizes dropping one collection, see ConfirmDrop.
+type DropConfirmation struct {
+	collection string
+}
+
+// ConfirmDrop returns the token allowing Drop of the named collection when drop protection is
+// enabled. Spelling out the name makes dropping an explicit decision at the call site.
+func ConfirmDrop(collectionName string) DropConfirmation {
+	return DropConfirmation{collection: collectionName}
+}
+
+// WithDropProtection makes Drop fail with ErrDropNotConfirmed unless it is given
+// ConfirmDrop(name) for the collection being dropped.
+func WithDropProtection() Option {
+	return func(s *settings) {
+		s.dropProtection = true
+	}
+}
+
+func (c Collection) dropConfirmed(confirm []DropConfirmation) bool {
+	if c.settings == nil || !c.settings.dropProtection {
+		return true
+	}
+	for _, token := range confirm {
+		if token.collection == c.name {
+			return true
+		}
+	}
+	return false
+}
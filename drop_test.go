@@ -0,0 +1,21 @@
+package mongoboiler
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestDrop_Protection(t *testing.T) {
+	coll := newTestCollection(t, "orders", WithDropProtection())
+
+	if err := coll.Drop(context.Background()); !errors.Is(err, ErrDropNotConfirmed) {
+		t.Fatalf("expected ErrDropNotConfirmed, got %v", err)
+	}
+	if err := coll.Drop(context.Background(), ConfirmDrop("users")); !errors.Is(err, ErrDropNotConfirmed) {
+		t.Fatalf("expected a token for another collection to be rejected, got %v", err)
+	}
+	if !coll.dropConfirmed([]DropConfirmation{ConfirmDrop("orders")}) {
+		t.Fatalf("expected the matching token to confirm the drop")
+	}
+}
@@ -0,0 +1,135 @@
+package mongoboiler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrDryRun is matched by the DryRunError returned for writes skipped by WithDryRun.
+var ErrDryRun = errors.New("mongoboiler: dry run, write not executed")
+
+// DryRunReport describes a write WithDryRun did not execute.
+type DryRunReport struct {
+	OperationID string
+	Database    string
+	Collection  string
+	Operation   OpKind
+	Filter      bson.D
+	Update      bson.D
+	// Affected is the number of documents the write would have changed: those matching the
+	// filter, at most one for single document operations, or the documents to insert. Upserts
+	// matching nothing would insert one instead.
+	Affected int64
+}
+
+func (r DryRunReport) String() string {
+	return fmt.Sprintf("%s %s.%s would affect %d documents (filter %v)", r.Operation, r.Database, r.Collection, r.Affected, r.Filter)
+}
+
+// DryRunError is returned for writes skipped by WithDryRun, with what they would have affected.
+type DryRunError struct {
+	Report DryRunReport
+}
+
+func (e *DryRunError) Error() string {
+	return "mongoboiler: dry run: " + e.Report.String()
+}
+
+// Is reports whether target is ErrDryRun.
+func (e *DryRunError) Is(target error) bool {
+	return target == ErrDryRun
+}
+
+// Code returns CodePreconditionFailed.
+func (e *DryRunError) Code() ErrorCode {
+	return CodePreconditionFailed
+}
+
+// DryRunOption configures WithDryRun.
+type DryRunOption func(*dryRun)
+
+// DryRunLog makes dry-run writes log their report to logger, the standard logger if nil, and
+// succeed with a zero result instead of failing with a DryRunError, so maintenance code runs to
+// completion. Writes whose result the wrapper depends on, see WithDryRun, still fail.
+func DryRunLog(logger Logger) DryRunOption {
+	return func(d *dryRun) {
+		d.log, d.logger = true, loggerOrDefault(logger)
+	}
+}
+
+type dryRun struct {
+	log    bool
+	logger Logger
+	// count counts the documents matching the filter of op, replaced in tests.
+	count func(ctx context.Context, op *Operation, opts *options.CountOptions) (int64, error)
+}
+
+func countTarget(ctx context.Context, op *Operation, opts *options.CountOptions) (int64, error) {
+	return op.Target.CountDocuments(ctx, nonNilFilter(op.Filter), opts)
+}
+
+// WithDryRun does not execute writes but counts the documents they would affect, with the filter
+// after the middleware chain ran, and returns that in a DryRunError:
+//
+//	_, err := coll.With(mongoboiler.WithDryRun()).DeleteMany(ctx, filter)
+//	var dry *mongoboiler.DryRunError
+//	if errors.As(err, &dry) {
+//		fmt.Println(dry.Report)
+//	}
+//
+// Reads are executed as usual. Writes not made through the wrapper's operations, such as those
+// of RunCommand, are not covered. The find-and-modify writes of NextSequence, TryLock, queue
+// leases, outbox and view refresh claims and FindOrCreate are skipped as well, but always fail
+// with a DryRunError, with DryRunLog too: their callers would otherwise go on with a counter
+// value, lock or job that does not exist. WithColdFields, WithJournal and WithRevisions make no
+// writes of their own for skipped writes, WithAudit records them with DryRun set.
+func WithDryRun(opts ...DryRunOption) Option {
+	d := &dryRun{count: countTarget}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return func(s *settings) {
+		s.dryRun = d
+	}
+}
+
+// handler returns next skipping writes.
+func (d *dryRun) handler(next Handler) Handler {
+	return func(ctx context.Context, op *Operation) error {
+		if !op.Kind.IsWrite() {
+			return next(ctx, op)
+		}
+		report := DryRunReport{
+			OperationID: op.ID,
+			Database:    op.Database,
+			Collection:  op.Collection,
+			Operation:   op.Kind,
+			Filter:      op.Filter,
+			Update:      op.Update,
+		}
+		switch op.Kind {
+		case OpInsertOne, OpInsertMany:
+			report.Affected = int64(len(op.Documents))
+		default:
+			opts := options.Count()
+			switch op.Kind {
+			case OpUpdateOne, OpReplaceOne, OpDeleteOne, OpFindOrCreate:
+				opts.SetLimit(1)
+			}
+			n, err := d.count(ctx, op, opts)
+			if err != nil {
+				return err
+			}
+			report.Affected = n
+		}
+		if d.log && !op.needsEffect {
+			d.logger.Printf("mongoboiler: dry run: %s", report)
+			return nil
+		}
+		return &DryRunError{Report: report}
+	}
+}
@@ -0,0 +1,153 @@
+package mongoboiler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func dryRunCollection(t *testing.T, counted *[]*options.CountOptions, opts ...DryRunOption) *Collection {
+	coll := newTestCollection(t, "orders", WithTenancy(Tenancy{}), WithDryRun(opts...))
+	coll.settings.dryRun.count = func(ctx context.Context, op *Operation, opts *options.CountOptions) (int64, error) {
+		*counted = append(*counted, opts)
+		if opts.Limit != nil {
+			return 1, nil
+		}
+		return 3, nil
+	}
+	return coll
+}
+
+func TestDryRun_ReturnsReport(t *testing.T) {
+	var counted []*options.CountOptions
+	coll := dryRunCollection(t, &counted)
+	ctx := ContextWithTenant(context.Background(), "acme")
+
+	_, err := coll.DeleteMany(ctx, bson.D{{Key: "status", Value: "stale"}})
+	var dry *DryRunError
+	if !errors.As(err, &dry) || !errors.Is(err, ErrDryRun) {
+		t.Fatalf("expected a DryRunError, got %v", err)
+	}
+	if dry.Report.Operation != OpDeleteMany || dry.Report.Affected != 3 || len(dry.Report.Filter) != 2 {
+		t.Fatalf("unexpected report %+v", dry.Report)
+	}
+
+	_, err = coll.UpdateOne(ctx, bson.D{{Key: "_id", Value: 1}}, bson.D{{Key: "$set", Value: bson.D{{Key: "a", Value: 1}}}})
+	if !errors.As(err, &dry) || dry.Report.Affected != 1 || dry.Report.Update == nil {
+		t.Fatalf("expected a single document report, got %v", err)
+	}
+	if len(counted) != 2 || counted[0].Limit != nil || *counted[1].Limit != 1 {
+		t.Fatalf("unexpected counts %+v", counted)
+	}
+
+	_, err = coll.InsertMany(ctx, []any{bson.D{}, bson.D{}})
+	if !errors.As(err, &dry) || dry.Report.Affected != 2 || len(counted) != 2 {
+		t.Fatalf("expected inserts to be reported without counting, got %v", err)
+	}
+}
+
+func TestDryRun_Log(t *testing.T) {
+	var counted []*options.CountOptions
+	var buf bytes.Buffer
+	coll := dryRunCollection(t, &counted, DryRunLog(log.New(&buf, "", 0)))
+
+	res, err := coll.DeleteMany(ContextWithTenant(context.Background(), "acme"), bson.D{{Key: "status", Value: "stale"}})
+	if err != nil || res.DeletedCount != 0 {
+		t.Fatalf("expected the delete to succeed without deleting, got %+v, %v", res, err)
+	}
+	if !strings.Contains(buf.String(), "deleteMany testdb.orders would affect 3 documents") {
+		t.Fatalf("unexpected log %q", buf.String())
+	}
+	if o := resolvedOption(t, coll.ResolveOptions(), "dryRun"); o.Value != "log" {
+		t.Fatalf("dryRun resolved to %s", o)
+	}
+}
+
+func TestDryRun_LogFailsWritesNeedingTheirEffect(t *testing.T) {
+	var buf bytes.Buffer
+	coll := newTestCollection(t, "orders", WithDryRun(DryRunLog(log.New(&buf, "", 0))))
+	coll.settings.dryRun.count = func(ctx context.Context, op *Operation, opts *options.CountOptions) (int64, error) {
+		return 0, nil
+	}
+	ctx := context.Background()
+
+	if n, err := coll.db.NextSequence(ctx, "orders"); !errors.Is(err, ErrDryRun) || n != 0 {
+		t.Fatalf("expected NextSequence to fail in a dry run, got %d, %v", n, err)
+	}
+	if _, err := coll.db.TryLock(ctx, "nightly", time.Minute); !errors.Is(err, ErrDryRun) {
+		t.Fatalf("expected TryLock to fail in a dry run, got %v", err)
+	}
+	var res bson.M
+	if _, err := coll.FindOrCreate(ctx, bson.D{{Key: "sku", Value: "a"}}, bson.D{}, &res); !errors.Is(err, ErrDryRun) {
+		t.Fatalf("expected FindOrCreate to fail in a dry run, got %v", err)
+	}
+	if _, err := coll.DeleteOne(ctx, bson.D{{Key: "_id", Value: 1}}); err != nil {
+		t.Fatalf("expected plain writes to be logged, got %v", err)
+	}
+}
+
+func TestDryRun_SkipsSidecarWrites(t *testing.T) {
+	var sidecar []string
+	cold := &coldSplitter{
+		fields: []string{"description"},
+		ids: func(ctx context.Context, target *mongo.Collection, filter bson.D, limit int64) ([]any, error) {
+			sidecar = append(sidecar, "cold ids")
+			return []any{1}, nil
+		},
+		write: func(ctx context.Context, cold *mongo.Collection, models []mongo.WriteModel) error {
+			sidecar = append(sidecar, "cold write")
+			return nil
+		},
+	}
+	revisions := &revisioner{
+		snapshot: func(ctx context.Context, target *mongo.Collection, filter bson.D, limit int64) ([]bson.Raw, error) {
+			sidecar = append(sidecar, "revision snapshot")
+			return []bson.Raw{mustRaw(t, bson.D{{Key: "_id", Value: 1}})}, nil
+		},
+		store: func(ctx context.Context, revisions *mongo.Collection, docs []any) error {
+			sidecar = append(sidecar, "revision store")
+			return nil
+		},
+		nextVersion: func(ctx context.Context, revisions *mongo.Collection, id any) (int64, error) { return 1, nil },
+	}
+	var audited []*AuditEntry
+	audit := &auditor{cfg: AuditConfig{Diff: true, MaxDiffDocuments: 100}, write: func(ctx context.Context, op *Operation, entry *AuditEntry) error {
+		audited = append(audited, entry)
+		return nil
+	}}
+	journal := WithJournal(JournalConfig{Sink: journalSinkFunc(func(ctx context.Context, entry JournalEntry) error {
+		sidecar = append(sidecar, "journal")
+		return nil
+	})})
+	coll := newTestCollection(t, "products", WithDryRun(DryRunLog(log.New(io.Discard, "", 0))),
+		WithMiddleware(cold.middleware, revisions.middleware, audit.middleware), journal)
+	coll.settings.dryRun.count = func(ctx context.Context, op *Operation, opts *options.CountOptions) (int64, error) {
+		return 1, nil
+	}
+	ctx := context.Background()
+
+	if _, err := coll.InsertOne(ctx, bson.D{{Key: "name", Value: "lamp"}, {Key: "description", Value: "text"}}); err != nil {
+		t.Fatalf("InsertOne failed: %v", err)
+	}
+	if _, err := coll.UpdateOne(ctx, bson.D{{Key: "_id", Value: 1}}, bson.D{{Key: "$set", Value: bson.D{{Key: "description", Value: "new"}}}}); err != nil {
+		t.Fatalf("UpdateOne failed: %v", err)
+	}
+	if _, err := coll.DeleteOne(ctx, bson.D{{Key: "_id", Value: 1}}); err != nil {
+		t.Fatalf("DeleteOne failed: %v", err)
+	}
+	if len(sidecar) != 0 {
+		t.Fatalf("expected no sidecar reads or writes for dry-run writes, got %v", sidecar)
+	}
+	if len(audited) != 3 || !audited[0].DryRun || !audited[2].DryRun || audited[1].Documents != nil {
+		t.Fatalf("expected the dry-run writes audited as such, got %+v", audited)
+	}
+}
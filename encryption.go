@@ -0,0 +1,648 @@
+package mongoboiler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/mongocrypt"
+)
+
+// Encryption algorithms selected with the encrypt tag.
+const (
+	// EncryptDeterministic encrypts equal values to equal ciphertexts, so the field can be
+	// queried for equality.
+	EncryptDeterministic = "AEAD_AES_256_CBC_HMAC_SHA_512-Deterministic"
+	// EncryptRandom encrypts every value differently. Such fields cannot be queried.
+	EncryptRandom = "AEAD_AES_256_CBC_HMAC_SHA_512-Random"
+)
+
+var (
+	// ErrEncryptionUnavailable is returned by NewEncryption when the binary was built without
+	// libmongocrypt support.
+	ErrEncryptionUnavailable = errors.New("mongoboiler: client-side encryption needs libmongocrypt and the cse build tag")
+	// ErrEncryptedField is returned for filters and updates that cannot work on an encrypted
+	// field, e.g. range queries or $inc.
+	ErrEncryptedField = errors.New("mongoboiler: operation not supported on encrypted field")
+)
+
+// EncryptionConfig configures the key vault and KMS provider of client-side field level
+// encryption.
+type EncryptionConfig struct {
+	// KeyVaultNamespace is the "database.collection" data keys are stored in, by default
+	// "encryption.__keyVault".
+	KeyVaultNamespace string
+	// KMSProviders are passed to the driver, e.g. {"local": {"key": masterKey96Bytes}} or
+	// {"aws": {"accessKeyId": ..., "secretAccessKey": ...}}.
+	KMSProviders map[string]map[string]any
+	// KMSProvider names the provider new data keys are created with, required when more than one
+	// is configured.
+	KMSProvider string
+	// MasterKey identifies the customer master key for cloud KMS providers, e.g.
+	// {"region": ..., "key": arn} for AWS. Unused for local keys.
+	MasterKey any
+	// KeyAltName is the alternate name of the data key fields are encrypted with, created when it
+	// does not exist yet. Defaults to "mongoboiler".
+	KeyAltName string
+}
+
+// Encryption encrypts and decrypts document fields with explicit client-side field level
+// encryption, which works with every server edition. Install it with WithEncryption.
+type Encryption struct {
+	cipher     fieldCipher
+	keyAltName string
+	close      func(ctx context.Context) error
+}
+
+// fieldCipher is the subset of mongo.ClientEncryption used, replaced in tests.
+type fieldCipher interface {
+	Encrypt(ctx context.Context, val bson.RawValue, opts ...*options.EncryptOptions) (primitive.Binary, error)
+	Decrypt(ctx context.Context, val primitive.Binary) (bson.RawValue, error)
+}
+
+// NewEncryption connects to the key vault on keyVault and makes sure the data key exists.
+func NewEncryption(ctx context.Context, keyVault *mongo.Client, cfg EncryptionConfig) (*Encryption, error) {
+	if mongocrypt.Version() == "" {
+		return nil, ErrEncryptionUnavailable
+	}
+	if cfg.KeyVaultNamespace == "" {
+		cfg.KeyVaultNamespace = "encryption.__keyVault"
+	}
+	if cfg.KeyAltName == "" {
+		cfg.KeyAltName = "mongoboiler"
+	}
+	provider := cfg.KMSProvider
+	if provider == "" {
+		if len(cfg.KMSProviders) != 1 {
+			return nil, errors.New("mongoboiler: EncryptionConfig.KMSProvider is required with several KMS providers")
+		}
+		for name := range cfg.KMSProviders {
+			provider = name
+		}
+	}
+	vaultDB, vaultColl, ok := strings.Cut(cfg.KeyVaultNamespace, ".")
+	if !ok {
+		return nil, fmt.Errorf("mongoboiler: key vault namespace %q is not database.collection", cfg.KeyVaultNamespace)
+	}
+
+	// Alternate names must be unique for lookups by name to be unambiguous.
+	_, err := keyVault.Database(vaultDB).Collection(vaultColl).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "keyAltNames", Value: 1}},
+		Options: options.Index().SetUnique(true).
+			SetPartialFilterExpression(bson.D{{Key: "keyAltNames", Value: bson.D{{Key: "$exists", Value: true}}}}),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ce, err := mongo.NewClientEncryption(keyVault, options.ClientEncryption().
+		SetKeyVaultNamespace(cfg.KeyVaultNamespace).
+		SetKmsProviders(cfg.KMSProviders))
+	if err != nil {
+		return nil, err
+	}
+	err = ce.GetKeyByAltName(ctx, cfg.KeyAltName).Err()
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		opts := options.DataKey().SetKeyAltNames([]string{cfg.KeyAltName})
+		if cfg.MasterKey != nil {
+			opts.SetMasterKey(cfg.MasterKey)
+		}
+		_, err = ce.CreateDataKey(ctx, provider, opts)
+	}
+	if err != nil {
+		_ = ce.Close(ctx)
+		return nil, err
+	}
+	return &Encryption{cipher: ce, keyAltName: cfg.KeyAltName, close: ce.Close}, nil
+}
+
+// Close releases the resources of the encryption.
+func (e *Encryption) Close(ctx context.Context) error {
+	if e.close == nil {
+		return nil
+	}
+	return e.close(ctx)
+}
+
+// WithEncryption encrypts the fields tagged with encrypt in inserted and replaced documents and
+// decrypts encrypted fields of read results:
+//
+//	SSN   string `bson:"ssn" encrypt:"deterministic"`
+//	Notes string `bson:"notes" encrypt:"random"`
+//
+// Encryption happens before the middleware chain, so middleware, logs and caches only see
+// ciphertext. Filters and updates given as bson.D carry no tags: set WithEncryptedModel for
+// equality filters and $set on encrypted fields, or on documents and arrays holding them, to be
+// encrypted too; other operators on them fail with ErrEncryptedField. Tagged fields of slice
+// elements are encrypted in every element. Aggregation pipelines are sent as they are.
+func WithEncryption(e *Encryption) Option {
+	return func(s *settings) {
+		s.encryption = e
+	}
+}
+
+// WithEncryptedModel declares the struct stored in the collection, whose encrypt tags determine
+// which fields of filters, updates and untyped documents are encrypted.
+func WithEncryptedModel(model any) Option {
+	fields := encryptedFieldsOf(reflect.TypeOf(model))
+	return func(s *settings) {
+		s.encryptedFields = fields
+	}
+}
+
+// encryptedFieldsCache holds the encrypted fields of struct types by reflect.Type.
+var encryptedFieldsCache sync.Map
+
+// encryptedFieldsOf returns the encryption algorithm by dotted field path of the encrypt tags
+// of struct type rt, nil if it has none.
+func encryptedFieldsOf(rt reflect.Type) map[string]string {
+	for rt != nil && rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+	if rt == nil || rt.Kind() != reflect.Struct {
+		return nil
+	}
+	if fields, ok := encryptedFieldsCache.Load(rt); ok {
+		return fields.(map[string]string)
+	}
+	fields := map[string]string{}
+	collectEncryptedFields(rt, "", fields, map[reflect.Type]bool{})
+	if len(fields) == 0 {
+		fields = nil
+	}
+	encryptedFieldsCache.Store(rt, fields)
+	return fields
+}
+
+func collectEncryptedFields(rt reflect.Type, prefix string, fields map[string]string, seen map[reflect.Type]bool) {
+	if seen[rt] {
+		return
+	}
+	seen[rt] = true
+	defer delete(seen, rt)
+
+	_ = walkIndexFields(rt, func(field string, sf reflect.StructField) error {
+		path := prefix + field
+		switch sf.Tag.Get("encrypt") {
+		case "deterministic":
+			fields[path] = EncryptDeterministic
+			return nil
+		case "random":
+			fields[path] = EncryptRandom
+			return nil
+		}
+		// The fields of array elements have the path of the array, as in queries.
+		ft := sf.Type
+		for ft.Kind() == reflect.Ptr || (ft.Kind() == reflect.Slice || ft.Kind() == reflect.Array) && ft.Elem().Kind() != reflect.Uint8 {
+			ft = ft.Elem()
+		}
+		if ft.Kind() == reflect.Struct && ft != timeType {
+			collectEncryptedFields(ft, path+".", fields, seen)
+		}
+		return nil
+	})
+}
+
+// middleware encrypts the documents, filter and update of an operation. fields are those of
+// the collection's model.
+func (e *Encryption) middleware(fields map[string]string) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, op *Operation) error {
+			if len(op.Documents) > 0 {
+				// Never modify the caller's slice.
+				docs := make([]any, len(op.Documents))
+				for i, doc := range op.Documents {
+					docFields := encryptedFieldsOf(reflect.TypeOf(doc))
+					if docFields == nil {
+						docFields = fields
+					}
+					docs[i] = doc
+					if docFields == nil {
+						continue
+					}
+					var err error
+					if docs[i], err = e.encryptDocument(ctx, doc, docFields); err != nil {
+						return err
+					}
+				}
+				op.Documents = docs
+			}
+			if fields != nil {
+				var err error
+				if op.Filter, err = e.encryptFilter(ctx, op.Filter, fields); err != nil {
+					return err
+				}
+				if op.Update, err = e.encryptUpdate(ctx, op.Update, fields); err != nil {
+					return err
+				}
+			}
+			return next(ctx, op)
+		}
+	}
+}
+
+func (e *Encryption) encryptDocument(ctx context.Context, doc any, fields map[string]string) (bson.D, error) {
+	d, err := toDocument(doc)
+	if err != nil {
+		return nil, err
+	}
+	// Copy before replacing values in place, doc may be the caller's bson.D.
+	d = copyDocument(d)
+	for path, algorithm := range fields {
+		if err := e.encryptPath(ctx, d, strings.Split(path, "."), algorithm); err != nil {
+			return nil, fmt.Errorf("mongoboiler: encrypting %s: %w", path, err)
+		}
+	}
+	return d, nil
+}
+
+func (e *Encryption) encryptPath(ctx context.Context, d bson.D, path []string, algorithm string) error {
+	for i := range d {
+		if d[i].Key != path[0] {
+			continue
+		}
+		if len(path) > 1 {
+			switch d[i].Value.(type) {
+			case bson.D, bson.A, nil:
+			default:
+				// Structs, maps and slices set in a bson.D by the caller.
+				v, err := canonicalValue(d[i].Value)
+				if err != nil {
+					return err
+				}
+				d[i].Value = v
+			}
+			return e.encryptWithin(ctx, d[i].Value, path[1:], algorithm)
+		}
+		v, err := e.encrypt(ctx, d[i].Value, algorithm)
+		if err != nil {
+			return err
+		}
+		d[i].Value = v
+	}
+	return nil
+}
+
+// encryptWithin encrypts the field at path in v, a document or an array of documents.
+func (e *Encryption) encryptWithin(ctx context.Context, v any, path []string, algorithm string) error {
+	switch v := v.(type) {
+	case bson.D:
+		return e.encryptPath(ctx, v, path, algorithm)
+	case bson.A:
+		for _, elem := range v {
+			if err := e.encryptWithin(ctx, elem, path, algorithm); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// encryptAssigned returns v, the value assigned to or compared with the field at path, with
+// the encrypted fields inside it encrypted.
+func (e *Encryption) encryptAssigned(ctx context.Context, path string, v any, under map[string]string) (any, error) {
+	v, err := canonicalValue(v)
+	if err != nil {
+		return nil, err
+	}
+	for field, algorithm := range under {
+		if err := e.encryptWithin(ctx, v, strings.Split(field, "."), algorithm); err != nil {
+			return nil, fmt.Errorf("mongoboiler: encrypting %s.%s: %w", path, field, err)
+		}
+	}
+	return v, nil
+}
+
+// canonicalValue returns a copy of v as the driver decodes it into a bson.D, with documents as
+// bson.D and arrays as bson.A.
+func canonicalValue(v any) (any, error) {
+	data, err := bson.Marshal(bson.D{{Key: "v", Value: v}})
+	if err != nil {
+		return nil, err
+	}
+	var d bson.D
+	if err := bson.Unmarshal(data, &d); err != nil {
+		return nil, err
+	}
+	return d[0].Value, nil
+}
+
+// encrypt encrypts v, null values are left as they are.
+func (e *Encryption) encrypt(ctx context.Context, v any, algorithm string) (any, error) {
+	if v == nil {
+		return nil, nil
+	}
+	if b, ok := v.(primitive.Binary); ok && b.Subtype == bsontype.BinaryEncrypted {
+		return v, nil
+	}
+	t, data, err := bson.MarshalValue(v)
+	if err != nil {
+		return nil, err
+	}
+	if t == bson.TypeNull {
+		return v, nil
+	}
+	return e.cipher.Encrypt(ctx, bson.RawValue{Type: t, Value: data},
+		options.Encrypt().SetAlgorithm(algorithm).SetKeyAltName(e.keyAltName))
+}
+
+// encryptFilter encrypts the values of equality conditions on deterministically encrypted fields.
+func (e *Encryption) encryptFilter(ctx context.Context, filter bson.D, fields map[string]string) (bson.D, error) {
+	if filter == nil {
+		return nil, nil
+	}
+	out := make(bson.D, 0, len(filter))
+	for _, elem := range filter {
+		switch elem.Key {
+		case "$and", "$or", "$nor":
+			clauses, err := e.encryptClauses(ctx, elem.Value, fields)
+			if err != nil {
+				return nil, err
+			}
+			elem.Value = clauses
+		default:
+			algorithm, err := encryptedFieldFor(elem.Key, fields)
+			if err != nil {
+				return nil, err
+			}
+			if under := encryptedFieldsUnder(elem.Key, fields); algorithm == "" && len(under) > 0 {
+				if elem.Value, err = e.encryptSubdocumentCondition(ctx, elem.Key, elem.Value, under); err != nil {
+					return nil, err
+				}
+			}
+			switch algorithm {
+			case EncryptRandom:
+				return nil, fmt.Errorf("%w: %s is randomly encrypted and cannot be queried", ErrEncryptedField, elem.Key)
+			case EncryptDeterministic:
+				if elem.Value, err = e.encryptCondition(ctx, elem.Key, elem.Value); err != nil {
+					return nil, err
+				}
+			}
+		}
+		out = append(out, elem)
+	}
+	return out, nil
+}
+
+func (e *Encryption) encryptClauses(ctx context.Context, v any, fields map[string]string) (bson.A, error) {
+	var clauses []bson.D
+	switch v := v.(type) {
+	case bson.A:
+		for _, c := range v {
+			d, ok := c.(bson.D)
+			if !ok {
+				return nil, fmt.Errorf("%w: unsupported clause type %T", ErrEncryptedField, c)
+			}
+			clauses = append(clauses, d)
+		}
+	case []bson.D:
+		clauses = v
+	default:
+		return nil, fmt.Errorf("%w: unsupported clauses type %T", ErrEncryptedField, v)
+	}
+	out := make(bson.A, len(clauses))
+	for i, c := range clauses {
+		enc, err := e.encryptFilter(ctx, c, fields)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = enc
+	}
+	return out, nil
+}
+
+// encryptSubdocumentCondition encrypts the document a field holding the encrypted fields under
+// is compared with. Only equality and $exists can be encrypted.
+func (e *Encryption) encryptSubdocumentCondition(ctx context.Context, field string, v any, under map[string]string) (any, error) {
+	if d, ok := v.(bson.D); ok && len(d) > 0 && strings.HasPrefix(d[0].Key, "$") {
+		if len(d) == 1 && d[0].Key == "$exists" {
+			return v, nil
+		}
+		return nil, fmt.Errorf("%w: %s on %s, which holds encrypted fields", ErrEncryptedField, d[0].Key, field)
+	}
+	for inner, algorithm := range under {
+		if algorithm == EncryptRandom {
+			return nil, fmt.Errorf("%w: %s.%s is randomly encrypted and cannot be queried", ErrEncryptedField, field, inner)
+		}
+	}
+	return e.encryptAssigned(ctx, field, v, under)
+}
+
+// encryptCondition encrypts the value a deterministically encrypted field is compared with.
+func (e *Encryption) encryptCondition(ctx context.Context, field string, v any) (any, error) {
+	d, ok := v.(bson.D)
+	if !ok || len(d) == 0 || !strings.HasPrefix(d[0].Key, "$") {
+		return e.encrypt(ctx, v, EncryptDeterministic)
+	}
+	out := copyDocument(d)
+	for i, cond := range out {
+		var err error
+		switch cond.Key {
+		case "$eq", "$ne":
+			out[i].Value, err = e.encrypt(ctx, cond.Value, EncryptDeterministic)
+		case "$in", "$nin":
+			values, ok := cond.Value.(bson.A)
+			if !ok {
+				return nil, fmt.Errorf("%w: %s of %s must be a bson.A", ErrEncryptedField, cond.Key, field)
+			}
+			enc := make(bson.A, len(values))
+			for j, value := range values {
+				if enc[j], err = e.encrypt(ctx, value, EncryptDeterministic); err != nil {
+					return nil, err
+				}
+			}
+			out[i].Value = enc
+		case "$exists":
+		default:
+			return nil, fmt.Errorf("%w: %s on %s", ErrEncryptedField, cond.Key, field)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// encryptUpdate encrypts the values $set and $setOnInsert assign to encrypted fields, and the
+// encrypted fields inside the documents they assign.
+func (e *Encryption) encryptUpdate(ctx context.Context, update bson.D, fields map[string]string) (bson.D, error) {
+	if update == nil {
+		return nil, nil
+	}
+	out := copyDocument(update)
+	for i, elem := range out {
+		assignments, ok := elem.Value.(bson.D)
+		if !ok {
+			continue
+		}
+		assignments = copyDocument(assignments)
+		for j, a := range assignments {
+			algorithm, err := encryptedFieldFor(a.Key, fields)
+			if err != nil {
+				return nil, err
+			}
+			under := encryptedFieldsUnder(a.Key, fields)
+			if algorithm == "" && len(under) == 0 {
+				continue
+			}
+			switch elem.Key {
+			case "$set", "$setOnInsert":
+				if algorithm == "" {
+					assignments[j].Value, err = e.encryptAssigned(ctx, a.Key, a.Value, under)
+				} else {
+					assignments[j].Value, err = e.encrypt(ctx, a.Value, algorithm)
+				}
+				if err != nil {
+					return nil, err
+				}
+			case "$unset":
+			default:
+				return nil, fmt.Errorf("%w: %s on %s", ErrEncryptedField, elem.Key, a.Key)
+			}
+		}
+		out[i].Value = assignments
+	}
+	return out, nil
+}
+
+// encryptedFieldFor returns the algorithm path is encrypted with, empty if it is not encrypted.
+// Paths into an encrypted field are an error as its content is opaque to the server.
+func encryptedFieldFor(path string, fields map[string]string) (string, error) {
+	path = fieldPath(path)
+	if algorithm, ok := fields[path]; ok {
+		return algorithm, nil
+	}
+	for field := range fields {
+		if strings.HasPrefix(path, field+".") {
+			return "", fmt.Errorf("%w: %s is inside encrypted field %s", ErrEncryptedField, path, field)
+		}
+	}
+	return "", nil
+}
+
+// encryptedFieldsUnder returns the encrypted fields inside the one at path, by their path
+// relative to it.
+func encryptedFieldsUnder(path string, fields map[string]string) map[string]string {
+	path = fieldPath(path)
+	var under map[string]string
+	for field, algorithm := range fields {
+		if !strings.HasPrefix(field, path+".") {
+			continue
+		}
+		if under == nil {
+			under = map[string]string{}
+		}
+		under[strings.TrimPrefix(field, path+".")] = algorithm
+	}
+	return under
+}
+
+// fieldPath returns path without its array indexes and positional operators, such as
+// "addresses.0.ssn" or "addresses.$[].ssn" for "addresses.ssn".
+func fieldPath(path string) string {
+	parts := strings.Split(path, ".")
+	out := parts[:0]
+	for _, part := range parts {
+		if strings.HasPrefix(part, "$") || strings.Trim(part, "0123456789") == "" {
+			continue
+		}
+		out = append(out, part)
+	}
+	return strings.Join(out, ".")
+}
+
+// decryptDocument decrypts the encrypted values anywhere in raw.
+func (e *Encryption) decryptDocument(ctx context.Context, raw bson.Raw) (bson.Raw, error) {
+	if !containsEncrypted(bson.RawValue{Type: bson.TypeEmbeddedDocument, Value: raw}) {
+		return raw, nil
+	}
+	var d bson.D
+	if err := bson.Unmarshal(raw, &d); err != nil {
+		return nil, err
+	}
+	v, err := e.decryptValue(ctx, d)
+	if err != nil {
+		return nil, err
+	}
+	return bson.Marshal(v)
+}
+
+func (e *Encryption) decryptValue(ctx context.Context, v any) (any, error) {
+	switch v := v.(type) {
+	case primitive.Binary:
+		if v.Subtype != bsontype.BinaryEncrypted {
+			return v, nil
+		}
+		plain, err := e.cipher.Decrypt(ctx, v)
+		if err != nil {
+			return nil, fmt.Errorf("mongoboiler: decrypting: %w", err)
+		}
+		return plain, nil
+	case bson.D:
+		for i := range v {
+			var err error
+			if v[i].Value, err = e.decryptValue(ctx, v[i].Value); err != nil {
+				return nil, err
+			}
+		}
+	case bson.A:
+		for i := range v {
+			var err error
+			if v[i], err = e.decryptValue(ctx, v[i]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return v, nil
+}
+
+func containsEncrypted(v bson.RawValue) bool {
+	switch v.Type {
+	case bson.TypeBinary:
+		subtype, _ := v.Binary()
+		return subtype == bsontype.BinaryEncrypted
+	case bson.TypeEmbeddedDocument, bson.TypeArray:
+		values, err := bson.Raw(v.Value).Values()
+		if err != nil {
+			return false
+		}
+		for _, value := range values {
+			if containsEncrypted(value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// copyDocument returns a copy of d with nested documents copied as well.
+func copyDocument(d bson.D) bson.D {
+	out := make(bson.D, len(d))
+	for i, e := range d {
+		e.Value = copyValue(e.Value)
+		out[i] = e
+	}
+	return out
+}
+
+func copyValue(v any) any {
+	switch v := v.(type) {
+	case bson.D:
+		return copyDocument(v)
+	case bson.A:
+		out := make(bson.A, len(v))
+		for i, elem := range v {
+			out[i] = copyValue(elem)
+		}
+		return out
+	}
+	return v
+}
@@ -0,0 +1,216 @@
+package mongoboiler
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// reversibleCipher "encrypts" by prefixing the value with its type, deterministically.
+type reversibleCipher struct{}
+
+func (reversibleCipher) Encrypt(ctx context.Context, val bson.RawValue, opts ...*options.EncryptOptions) (primitive.Binary, error) {
+	return primitive.Binary{Subtype: bsontype.BinaryEncrypted, Data: append([]byte{byte(val.Type)}, val.Value...)}, nil
+}
+
+func (reversibleCipher) Decrypt(ctx context.Context, val primitive.Binary) (bson.RawValue, error) {
+	return bson.RawValue{Type: bsontype.Type(val.Data[0]), Value: val.Data[1:]}, nil
+}
+
+type patient struct {
+	Name    string `bson:"name"`
+	SSN     string `bson:"ssn" encrypt:"deterministic"`
+	Contact struct {
+		Phone string `bson:"phone" encrypt:"random"`
+	} `bson:"contact"`
+}
+
+func isEncrypted(v any) bool {
+	b, ok := v.(primitive.Binary)
+	return ok && b.Subtype == bsontype.BinaryEncrypted
+}
+
+func TestEncryption_Roundtrip(t *testing.T) {
+	enc := &Encryption{cipher: reversibleCipher{}, keyAltName: "test"}
+	coll := newTestCollection(t, "patients", WithEncryption(enc), WithEncryptedModel(patient{}))
+
+	p := patient{Name: "Ada", SSN: "123-45-6789"}
+	p.Contact.Phone = "555-0100"
+	var sent bson.D
+	op := coll.newOp(OpInsertOne)
+	op.Documents = []any{p}
+	err := coll.run(context.Background(), op, func(ctx context.Context, op *Operation) error {
+		sent = op.Documents[0].(bson.D)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	doc := sent.Map()
+	if doc["name"] != "Ada" || !isEncrypted(doc["ssn"]) || !isEncrypted(doc["contact"].(bson.D).Map()["phone"]) {
+		t.Fatalf("unexpected document sent: %v", sent)
+	}
+
+	var got patient
+	if err := coll.decode(context.Background(), mustRaw(t, sent), &got); err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if got != p {
+		t.Fatalf("decrypted %+v, want %+v", got, p)
+	}
+}
+
+func TestEncryption_Filter(t *testing.T) {
+	enc := &Encryption{cipher: reversibleCipher{}}
+	fields := encryptedFieldsOf(reflect.TypeOf(patient{}))
+
+	filter, err := enc.encryptFilter(context.Background(), bson.D{
+		{Key: "name", Value: "Ada"},
+		{Key: "$or", Value: bson.A{
+			bson.D{{Key: "ssn", Value: "1"}},
+			bson.D{{Key: "ssn", Value: bson.D{{Key: "$in", Value: bson.A{"2", "3"}}}}},
+		}},
+	}, fields)
+	if err != nil {
+		t.Fatalf("encryptFilter failed: %v", err)
+	}
+	if filter[0].Value != "Ada" {
+		t.Fatalf("plain field was changed: %v", filter)
+	}
+	or := filter[1].Value.(bson.A)
+	if !isEncrypted(or[0].(bson.D)[0].Value) {
+		t.Fatalf("equality not encrypted: %v", or[0])
+	}
+	in := or[1].(bson.D)[0].Value.(bson.D)[0].Value.(bson.A)
+	if !isEncrypted(in[0]) || !isEncrypted(in[1]) {
+		t.Fatalf("$in not encrypted: %v", in)
+	}
+
+	for _, bad := range []bson.D{
+		{{Key: "contact.phone", Value: "555"}},
+		{{Key: "ssn", Value: bson.D{{Key: "$gt", Value: "1"}}}},
+	} {
+		if _, err := enc.encryptFilter(context.Background(), bad, fields); !errors.Is(err, ErrEncryptedField) {
+			t.Fatalf("filter %v: expected ErrEncryptedField, got %v", bad, err)
+		}
+	}
+}
+
+func TestEncryption_Update(t *testing.T) {
+	enc := &Encryption{cipher: reversibleCipher{}}
+	fields := encryptedFieldsOf(reflect.TypeOf(patient{}))
+
+	update, err := enc.encryptUpdate(context.Background(), bson.D{
+		{Key: "$set", Value: bson.D{{Key: "ssn", Value: "1"}, {Key: "name", Value: "Bob"}}},
+	}, fields)
+	if err != nil {
+		t.Fatalf("encryptUpdate failed: %v", err)
+	}
+	set := update[0].Value.(bson.D)
+	if !isEncrypted(set[0].Value) || set[1].Value != "Bob" {
+		t.Fatalf("unexpected update: %v", update)
+	}
+
+	_, err = enc.encryptUpdate(context.Background(), bson.D{{Key: "$inc", Value: bson.D{{Key: "ssn", Value: 1}}}}, fields)
+	if !errors.Is(err, ErrEncryptedField) {
+		t.Fatalf("expected ErrEncryptedField for $inc, got %v", err)
+	}
+}
+
+type customerAddress struct {
+	City string `bson:"city"`
+	SSN  string `bson:"ssn" encrypt:"deterministic"`
+}
+
+type customer struct {
+	Name      string            `bson:"name"`
+	Addresses []customerAddress `bson:"addresses"`
+	Profile   struct {
+		SSN   string `bson:"ssn" encrypt:"deterministic"`
+		Notes string `bson:"notes" encrypt:"random"`
+	} `bson:"profile"`
+}
+
+func TestEncryption_ArrayElements(t *testing.T) {
+	enc := &Encryption{cipher: reversibleCipher{}}
+	fields := encryptedFieldsOf(reflect.TypeOf(customer{}))
+	if fields["addresses.ssn"] != EncryptDeterministic {
+		t.Fatalf("expected the fields of array elements to be collected, got %v", fields)
+	}
+
+	c := customer{Addresses: []customerAddress{{"Paris", "1"}, {"Lagos", "2"}}}
+	doc, err := enc.encryptDocument(context.Background(), c, fields)
+	if err != nil {
+		t.Fatalf("encryptDocument failed: %v", err)
+	}
+	for _, a := range doc.Map()["addresses"].(bson.A) {
+		if a := a.(bson.D).Map(); !isEncrypted(a["ssn"]) || a["city"] == nil || isEncrypted(a["city"]) {
+			t.Fatalf("expected the ssn of every address encrypted, got %v", doc)
+		}
+	}
+
+	// A caller's bson.D holding the elements as a slice of documents, left unchanged.
+	addresses := []bson.D{{{Key: "ssn", Value: "3"}}}
+	doc, err = enc.encryptDocument(context.Background(), bson.D{{Key: "addresses", Value: addresses}}, fields)
+	if err != nil || !isEncrypted(doc[0].Value.(bson.A)[0].(bson.D)[0].Value) || addresses[0][0].Value != "3" {
+		t.Fatalf("expected a copy with the element encrypted, got %v, %v", doc, err)
+	}
+
+	update, err := enc.encryptUpdate(context.Background(), bson.D{{Key: "$set", Value: bson.D{
+		{Key: "addresses.0.ssn", Value: "4"},
+		{Key: "addresses", Value: bson.A{bson.D{{Key: "ssn", Value: "5"}}}},
+	}}}, fields)
+	if err != nil {
+		t.Fatalf("encryptUpdate failed: %v", err)
+	}
+	set := update[0].Value.(bson.D)
+	if !isEncrypted(set[0].Value) || !isEncrypted(set[1].Value.(bson.A)[0].(bson.D)[0].Value) {
+		t.Fatalf("expected positional and whole array assignments encrypted, got %v", update)
+	}
+}
+
+func TestEncryption_ParentDocument(t *testing.T) {
+	enc := &Encryption{cipher: reversibleCipher{}}
+	fields := encryptedFieldsOf(reflect.TypeOf(customer{}))
+
+	update, err := enc.encryptUpdate(context.Background(), bson.D{{Key: "$set", Value: bson.D{
+		{Key: "profile", Value: bson.D{{Key: "ssn", Value: "999"}, {Key: "notes", Value: "vip"}}},
+	}}}, fields)
+	if err != nil {
+		t.Fatalf("encryptUpdate failed: %v", err)
+	}
+	profile := update[0].Value.(bson.D)[0].Value.(bson.D).Map()
+	if !isEncrypted(profile["ssn"]) || !isEncrypted(profile["notes"]) {
+		t.Fatalf("expected the fields inside the assigned document encrypted, got %v", update)
+	}
+
+	_, err = enc.encryptUpdate(context.Background(), bson.D{{Key: "$push", Value: bson.D{
+		{Key: "addresses", Value: bson.D{{Key: "ssn", Value: "1"}}},
+	}}}, fields)
+	if !errors.Is(err, ErrEncryptedField) {
+		t.Fatalf("expected ErrEncryptedField for $push of documents with encrypted fields, got %v", err)
+	}
+
+	for _, bad := range []bson.D{
+		{{Key: "profile", Value: bson.D{{Key: "ssn", Value: "999"}}}},
+		{{Key: "profile", Value: bson.D{{Key: "$ne", Value: nil}}}},
+	} {
+		if _, err := enc.encryptFilter(context.Background(), bad, fields); !errors.Is(err, ErrEncryptedField) {
+			t.Fatalf("filter %v: expected ErrEncryptedField, got %v", bad, err)
+		}
+	}
+	filter, err := enc.encryptFilter(context.Background(), bson.D{{Key: "profile", Value: bson.D{{Key: "$exists", Value: true}}}}, fields)
+	if err != nil || filter[0].Value.(bson.D)[0].Key != "$exists" {
+		t.Fatalf("expected $exists on the parent to pass, got %v, %v", filter, err)
+	}
+	filter, err = enc.encryptFilter(context.Background(), bson.D{{Key: "addresses", Value: customerAddress{"Paris", "1"}}}, fields)
+	if err != nil || !isEncrypted(filter[0].Value.(bson.D).Map()["ssn"]) {
+		t.Fatalf("expected the equality on the parent encrypted inside, got %v, %v", filter, err)
+	}
+}
@@ -0,0 +1,101 @@
+package mongoboiler
+
+import (
+	"context"
+	"errors"
+	"regexp"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Errors returned by every Collection method in place of the driver's, see TranslateError.
+// The driver error stays available through errors.Is and errors.As.
+var (
+	ErrNotFound      = errors.New("mongoboiler: no document matched")
+	ErrDuplicateKey  = errors.New("mongoboiler: duplicate key")
+	ErrWriteConflict = errors.New("mongoboiler: write conflict")
+	ErrTimeout       = errors.New("mongoboiler: operation timed out")
+)
+
+// DuplicateKeyError is returned for writes violating a unique index. It matches ErrDuplicateKey.
+type DuplicateKeyError struct {
+	// Index is the violated index, e.g. email_1, and Key the duplicate value as printed by the
+	// server. Either is empty when the server message could not be parsed.
+	Index string
+	Key   string
+	Err   error
+}
+
+func (e *DuplicateKeyError) Error() string {
+	if e.Index == "" {
+		return ErrDuplicateKey.Error() + ": " + e.Err.Error()
+	}
+	return ErrDuplicateKey.Error() + " on index " + e.Index + ": " + e.Key
+}
+
+// Is makes errors.Is(err, ErrDuplicateKey) true.
+func (e *DuplicateKeyError) Is(target error) bool {
+	return target == ErrDuplicateKey
+}
+
+func (e *DuplicateKeyError) Unwrap() error {
+	return e.Err
+}
+
+// Code returns CodeConflict.
+func (e *DuplicateKeyError) Code() ErrorCode {
+	return CodeConflict
+}
+
+// classifiedError ties a driver error to one of the sentinel errors.
+type classifiedError struct {
+	class error
+	err   error
+}
+
+func (e *classifiedError) Error() string {
+	return e.class.Error() + ": " + e.err.Error()
+}
+
+func (e *classifiedError) Is(target error) bool {
+	return target == e.class
+}
+
+func (e *classifiedError) Unwrap() error {
+	return e.err
+}
+
+func (e *classifiedError) Code() ErrorCode {
+	return ErrorCodeOf(e.class)
+}
+
+// writeConflict is the server error code for conflicting concurrent writes.
+const writeConflict = 112
+
+var dupKeyPattern = regexp.MustCompile(`index: (\S+) dup key: (\{.*\})`)
+
+// TranslateError maps driver errors to ErrNotFound, *DuplicateKeyError, ErrWriteConflict and
+// ErrTimeout. Other errors, and errors already translated, are returned as is. The wrapper applies
+// it to every operation; implementations of CollectionAPI should too.
+func TranslateError(err error) error {
+	var dup *DuplicateKeyError
+	var classified *classifiedError
+	var server mongo.ServerError
+	switch {
+	case err == nil, errors.As(err, &dup), errors.As(err, &classified):
+		return err
+	case errors.Is(err, mongo.ErrNoDocuments):
+		return &classifiedError{ErrNotFound, err}
+	case mongo.IsDuplicateKeyError(err):
+		dup := &DuplicateKeyError{Err: err}
+		if m := dupKeyPattern.FindStringSubmatch(err.Error()); m != nil {
+			dup.Index, dup.Key = m[1], m[2]
+		}
+		return dup
+	case errors.As(err, &server) && server.HasErrorCode(writeConflict):
+		return &classifiedError{ErrWriteConflict, err}
+	case mongo.IsTimeout(err), errors.Is(err, context.DeadlineExceeded):
+		return &classifiedError{ErrTimeout, err}
+	}
+	return err
+}
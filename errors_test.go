@@ -0,0 +1,45 @@
+package mongoboiler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestTranslateError(t *testing.T) {
+	dupErr := mongo.WriteException{WriteErrors: []mongo.WriteError{{
+		Code:    11000,
+		Message: `E11000 duplicate key error collection: app.users index: email_1 dup key: { email: "a@b.c" }`,
+	}}}
+	conflict := mongo.CommandError{Code: writeConflict, Message: "WriteConflict"}
+
+	tests := []struct {
+		err  error
+		want error
+	}{
+		{mongo.ErrNoDocuments, ErrNotFound},
+		{dupErr, ErrDuplicateKey},
+		{conflict, ErrWriteConflict},
+		{fmt.Errorf("find: %w", context.DeadlineExceeded), ErrTimeout},
+	}
+	for _, tt := range tests {
+		got := TranslateError(tt.err)
+		if !errors.Is(got, tt.want) || errors.Unwrap(got) == nil {
+			t.Fatalf("TranslateError(%v) = %v, want %v wrapping the original", tt.err, got, tt.want)
+		}
+		if again := TranslateError(got); again != got {
+			t.Fatalf("expected translating twice to be a no-op, got %v", again)
+		}
+	}
+
+	var dup *DuplicateKeyError
+	if !errors.As(TranslateError(dupErr), &dup) || dup.Index != "email_1" || dup.Key != `{ email: "a@b.c" }` {
+		t.Fatalf("unexpected duplicate key error %+v", dup)
+	}
+	if other := errors.New("other"); TranslateError(other) != other {
+		t.Fatalf("expected unrelated errors to pass through")
+	}
+}
@@ -0,0 +1,184 @@
+package mongoboiler
+
+import (
+	"bytes"
+	"context"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// WithKeyEscaping escapes the keys of written documents with EscapeMapKey and unescapes them on
+// read, so documents whose keys contain dots or start with $, as external JSON payloads often
+// do, can be stored and read back unchanged. It applies to the inserted and replacing documents
+// at every level and to the values assigned by $set and $setOnInsert or added by $push and
+// $addToSet; other update operators, such as the conditions of $pull, and field paths of
+// updates and filters are left as they are, use MapKeyPath for escaped keys in them.
+// Aggregation pipelines are not escaped and their results are not unescaped.
+//
+// DynamicMap fields escape their own keys and must not be combined with it: they would be
+// escaped twice, and their paths would no longer match SetMapKey's.
+func WithKeyEscaping() Option {
+	return func(s *settings) {
+		s.escapeKeys = true
+	}
+}
+
+// keyEscapeMiddleware escapes the keys of the documents written by op.
+func keyEscapeMiddleware(next Handler) Handler {
+	return func(ctx context.Context, op *Operation) error {
+		if !op.Kind.IsWrite() {
+			return next(ctx, op)
+		}
+		if len(op.Documents) > 0 {
+			// Never modify the caller's slice.
+			docs := make([]any, len(op.Documents))
+			for i, doc := range op.Documents {
+				d, err := toDocument(doc)
+				if err != nil {
+					return err
+				}
+				docs[i] = escapeDocument(d)
+			}
+			op.Documents = docs
+		}
+		if op.Update != nil {
+			update, err := escapeUpdate(op.Update)
+			if err != nil {
+				return err
+			}
+			op.Update = update
+		}
+		return next(ctx, op)
+	}
+}
+
+// escapeUpdate escapes the keys of the values update writes. A replacement document is escaped
+// as a whole. Operators other than those assigning values, such as the conditions of $pull or
+// the type specifications of $currentDate, are left as they are.
+func escapeUpdate(update bson.D) (bson.D, error) {
+	if len(update) > 0 && !strings.HasPrefix(update[0].Key, "$") {
+		return escapeDocument(update), nil
+	}
+	out := make(bson.D, len(update))
+	for i, op := range update {
+		out[i] = op
+		switch op.Key {
+		case "$set", "$setOnInsert", "$push", "$addToSet":
+		default:
+			continue
+		}
+		fields, err := toDocument(op.Value)
+		if err != nil {
+			return nil, err
+		}
+		escaped := make(bson.D, len(fields))
+		for j, field := range fields {
+			escaped[j] = bson.E{Key: field.Key, Value: escapeOperand(op.Key, field.Value)}
+		}
+		out[i].Value = escaped
+	}
+	return out, nil
+}
+
+// escapeOperand escapes the keys of value assigned by the update operator op, leaving the
+// modifiers of $push and $addToSet, like $each and $position, intact.
+func escapeOperand(op string, value any) any {
+	if op == "$push" || op == "$addToSet" {
+		if modifiers, ok := convertibleDocument(value); ok && isModifierDocument(modifiers) {
+			modifiers = copyDocument(modifiers)
+			for i := range modifiers {
+				if modifiers[i].Key == "$each" {
+					modifiers[i].Value = escapeValue(modifiers[i].Value)
+				}
+			}
+			return modifiers
+		}
+	}
+	return escapeValue(value)
+}
+
+// isModifierDocument reports whether d holds the modifiers of $push or $addToSet, whose keys
+// all start with $, rather than a document to add.
+func isModifierDocument(d bson.D) bool {
+	each := false
+	for _, e := range d {
+		if !strings.HasPrefix(e.Key, "$") {
+			return false
+		}
+		each = each || e.Key == "$each"
+	}
+	return each
+}
+
+// escapeDocument returns d with its keys and those of nested documents escaped.
+func escapeDocument(d bson.D) bson.D {
+	out := make(bson.D, len(d))
+	for i, e := range d {
+		out[i] = bson.E{Key: EscapeMapKey(e.Key), Value: escapeValue(e.Value)}
+	}
+	return out
+}
+
+// escapeValue returns v with the keys of the documents it holds escaped. Values that are neither
+// documents nor arrays are returned as they are.
+func escapeValue(v any) any {
+	switch v := v.(type) {
+	case bson.D:
+		return escapeDocument(v)
+	case bson.A:
+		out := make(bson.A, len(v))
+		for i, item := range v {
+			out[i] = escapeValue(item)
+		}
+		return out
+	}
+	t, data, err := bson.MarshalValue(v)
+	if err != nil {
+		return v
+	}
+	switch t {
+	case bson.TypeEmbeddedDocument:
+		var d bson.D
+		if bson.Unmarshal(data, &d) == nil {
+			return escapeDocument(d)
+		}
+	case bson.TypeArray:
+		var a bson.A
+		if (bson.RawValue{Type: t, Value: data}).Unmarshal(&a) == nil {
+			return escapeValue(a)
+		}
+	}
+	return v
+}
+
+// unescapeKeys returns raw with the keys escaped by WithKeyEscaping unescaped.
+func unescapeKeys(raw bson.Raw) (bson.Raw, error) {
+	// Escaped keys contain a %, documents without any need no decoding.
+	if !bytes.ContainsRune(raw, '%') {
+		return raw, nil
+	}
+	var d bson.D
+	if err := bson.Unmarshal(raw, &d); err != nil {
+		return nil, err
+	}
+	return bson.Marshal(unescapeValue(d))
+}
+
+func unescapeValue(v any) any {
+	switch v := v.(type) {
+	case bson.D:
+		out := make(bson.D, len(v))
+		for i, e := range v {
+			out[i] = bson.E{Key: UnescapeMapKey(e.Key), Value: unescapeValue(e.Value)}
+		}
+		return out
+	case bson.A:
+		out := make(bson.A, len(v))
+		for i, item := range v {
+			out[i] = unescapeValue(item)
+		}
+		return out
+	}
+	return v
+}
@@ -0,0 +1,103 @@
+package mongoboiler
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestWithKeyEscaping_Writes(t *testing.T) {
+	var ops []*Operation
+	coll := recordingCollection(t, &ops).With(WithKeyEscaping())
+	payload := bson.M{"a.b": 1, "$ref": bson.A{bson.D{{Key: "x.y", Value: true}}}}
+
+	if _, err := coll.InsertOne(context.Background(), bson.D{{Key: "_id", Value: 1}, {Key: "payload", Value: payload}}); err != nil {
+		t.Fatalf("InsertOne failed: %v", err)
+	}
+	doc := ops[0].Documents[0].(bson.D)
+	stored := doc[1].Value.(bson.D)
+	if len(stored) != 2 || !containsKey(stored, "a%2Eb") || !containsKey(stored, "%24ref") {
+		t.Fatalf("stored %v, want escaped keys", stored)
+	}
+	if nested := mustRaw(t, doc).Lookup("payload", "%24ref", "0", "x%2Ey"); !nested.Boolean() {
+		t.Fatalf("expected keys in arrays escaped, got %v", doc)
+	}
+
+	update := bson.D{
+		{Key: "$set", Value: bson.D{{Key: "payload.a", Value: bson.D{{Key: "c.d", Value: 2}}}}},
+		{Key: "$push", Value: bson.D{{Key: "events", Value: bson.D{{Key: "$each", Value: bson.A{bson.D{{Key: "$k", Value: 1}}}}}}}},
+	}
+	if _, err := coll.UpdateOne(context.Background(), bson.D{}, update); err != nil {
+		t.Fatalf("UpdateOne failed: %v", err)
+	}
+	want := bson.D{
+		{Key: "$set", Value: bson.D{{Key: "payload.a", Value: bson.D{{Key: "c%2Ed", Value: 2}}}}},
+		{Key: "$push", Value: bson.D{{Key: "events", Value: bson.D{{Key: "$each", Value: bson.A{bson.D{{Key: "%24k", Value: 1}}}}}}}},
+	}
+	if !reflect.DeepEqual(ops[1].Update, want) {
+		t.Fatalf("got update %v, want %v", ops[1].Update, want)
+	}
+}
+
+func TestWithKeyEscaping_LeavesOperatorsIntact(t *testing.T) {
+	var ops []*Operation
+	coll := recordingCollection(t, &ops).With(WithKeyEscaping())
+	ctx := context.Background()
+
+	update := bson.D{
+		{Key: "$pull", Value: bson.D{{Key: "items", Value: bson.D{{Key: "qty", Value: bson.D{{Key: "$lte", Value: 0}}}}}}},
+		{Key: "$currentDate", Value: bson.D{{Key: "at", Value: bson.D{{Key: "$type", Value: "date"}}}}},
+		{Key: "$push", Value: bson.D{{Key: "events", Value: bson.D{
+			{Key: "$position", Value: 0},
+			{Key: "$each", Value: bson.A{bson.D{{Key: "a.b", Value: 1}}}},
+			{Key: "$slice", Value: 10},
+		}}}},
+	}
+	if _, err := coll.UpdateOne(ctx, bson.D{}, update); err != nil {
+		t.Fatalf("UpdateOne failed: %v", err)
+	}
+	want := bson.D{
+		update[0],
+		update[1],
+		{Key: "$push", Value: bson.D{{Key: "events", Value: bson.D{
+			{Key: "$position", Value: 0},
+			{Key: "$each", Value: bson.A{bson.D{{Key: "a%2Eb", Value: 1}}}},
+			{Key: "$slice", Value: 10},
+		}}}},
+	}
+	if !reflect.DeepEqual(ops[0].Update, want) {
+		t.Fatalf("got update %v, want %v", ops[0].Update, want)
+	}
+
+	// Pull with a condition goes through the same path.
+	if _, err := coll.Pull(ctx, bson.D{}, "items", bson.D{{Key: "qty", Value: bson.D{{Key: "$lte", Value: 0}}}}); err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+	if got := mustRaw(t, ops[1].Update).Lookup("$pull", "items", "qty", "$lte"); got.Type == 0 {
+		t.Fatalf("expected the $pull condition kept, got %v", ops[1].Update)
+	}
+}
+
+func containsKey(d bson.D, key string) bool {
+	for _, e := range d {
+		if e.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+func TestWithKeyEscaping_Reads(t *testing.T) {
+	coll := newTestCollection(t, "events", WithKeyEscaping())
+	raw := mustRaw(t, bson.D{{Key: "payload", Value: bson.D{{Key: "a%2Eb", Value: bson.A{bson.D{{Key: "%24c", Value: "100%25"}}}}}}})
+	var got bson.M
+	if err := coll.decode(context.Background(), raw, &got); err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	want := bson.M{"payload": bson.M{"a.b": bson.A{bson.M{"$c": "100%25"}}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("decoded %v, want %v", got, want)
+	}
+}
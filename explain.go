@@ -0,0 +1,144 @@
+package mongoboiler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Explain verbosities.
+const (
+	ExplainQueryPlanner      = "queryPlanner"
+	ExplainExecutionStats    = "executionStats"
+	ExplainAllPlansExecution = "allPlansExecution"
+)
+
+// ExplainResult summarizes the query plan of a find. The execution numbers are only set for
+// the executionStats and allPlansExecution verbosities.
+type ExplainResult struct {
+	// WinningStage is the root stage of the winning plan, e.g. FETCH or COLLSCAN.
+	WinningStage string
+	// Stages lists the winning plan's stages from the root down.
+	Stages []string
+	// Index is the index used, empty for collection scans.
+	Index         string
+	KeysExamined  int64
+	DocsExamined  int64
+	Returned      int64
+	ExecutionTime time.Duration
+	// Raw is the complete server response.
+	Raw bson.Raw
+}
+
+// CollectionScan reports whether the plan reads the whole collection.
+func (r ExplainResult) CollectionScan() bool {
+	for _, s := range r.Stages {
+		if s == "COLLSCAN" {
+			return true
+		}
+	}
+	return false
+}
+
+func (r ExplainResult) String() string {
+	index := r.Index
+	if index == "" {
+		index = "none"
+	}
+	return fmt.Sprintf("plan %s, index %s, keys examined %d, docs examined %d, returned %d, took %s",
+		r.WinningStage, index, r.KeysExamined, r.DocsExamined, r.Returned, r.ExecutionTime)
+}
+
+// Explain returns the plan the server picks for a find with filter.
+func (c Collection) Explain(ctx context.Context, filter bson.D, verbosity string) (ExplainResult, error) {
+	var res ExplainResult
+	op := c.newOp(OpExplain)
+	op.Filter = filter
+	err := c.run(ctx, op, func(ctx context.Context, op *Operation) error {
+		var err error
+		res, err = explainFind(ctx, op.Target, op.Filter, verbosity)
+		return err
+	})
+	return res, err
+}
+
+func explainFind(ctx context.Context, target *mongo.Collection, filter bson.D, verbosity string) (ExplainResult, error) {
+	if filter == nil {
+		filter = bson.D{}
+	}
+	raw, err := target.Database().RunCommand(ctx, bson.D{
+		{Key: "explain", Value: bson.D{{Key: "find", Value: target.Name()}, {Key: "filter", Value: filter}}},
+		{Key: "verbosity", Value: verbosity},
+	}).DecodeBytes()
+	if err != nil {
+		return ExplainResult{}, err
+	}
+	return parseExplain(raw), nil
+}
+
+func parseExplain(raw bson.Raw) ExplainResult {
+	res := ExplainResult{Raw: raw}
+	plan, ok := raw.Lookup("queryPlanner", "winningPlan").DocumentOK()
+	if inner, isSBE := plan.Lookup("queryPlan").DocumentOK(); ok && isSBE {
+		// Plans run by the slot based engine nest the classic plan.
+		plan = inner
+	}
+	for ok {
+		stage := plan.Lookup("stage").StringValue()
+		res.Stages = append(res.Stages, stage)
+		if name, isIndex := plan.Lookup("indexName").StringValueOK(); isIndex && res.Index == "" {
+			res.Index = name
+		}
+		plan, ok = plan.Lookup("inputStage").DocumentOK()
+	}
+	if len(res.Stages) > 0 {
+		res.WinningStage = res.Stages[0]
+	}
+
+	if stats, ok := raw.Lookup("executionStats").DocumentOK(); ok {
+		res.Returned = asInt64(stats.Lookup("nReturned"))
+		res.KeysExamined = asInt64(stats.Lookup("totalKeysExamined"))
+		res.DocsExamined = asInt64(stats.Lookup("totalDocsExamined"))
+		res.ExecutionTime = time.Duration(asInt64(stats.Lookup("executionTimeMillis"))) * time.Millisecond
+	}
+	return res
+}
+
+func asInt64(v bson.RawValue) int64 {
+	n, _ := v.AsInt64OK()
+	return n
+}
+
+// WithSlowQueryLog logs operations taking longer than threshold to logger (the standard logger
+// if nil). With explain set, slow finds are explained with executionStats and the plan summary
+// is added to the log entry; this runs the query a second time.
+func WithSlowQueryLog(threshold time.Duration, logger Logger, explain bool) Option {
+	logger = loggerOrDefault(logger)
+	return WithMiddleware(func(next Handler) Handler {
+		return func(ctx context.Context, op *Operation) error {
+			start := time.Now()
+			err := next(ctx, op)
+			took := time.Since(start)
+			if took < threshold {
+				return err
+			}
+
+			entry := fmt.Sprintf("mongoboiler: slow %s on %s.%s took %s (op %s)", op.Kind, op.Database, op.Collection, took, op.ID)
+			if explain && (op.Kind == OpFind || op.Kind == OpFindOne) {
+				ectx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+				plan, explainErr := explainFind(ectx, op.Target, op.Filter, ExplainExecutionStats)
+				cancel()
+				if explainErr != nil {
+					entry += fmt.Sprintf(", explain failed: %v", explainErr)
+				} else {
+					entry += ", " + plan.String()
+				}
+			}
+			logger.Printf("%s", entry)
+			return err
+		}
+	})
+}
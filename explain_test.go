@@ -0,0 +1,40 @@
+package mongoboiler
+
+import (
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestParseExplain(t *testing.T) {
+	raw := mustRaw(t, bson.D{
+		{Key: "queryPlanner", Value: bson.D{{Key: "winningPlan", Value: bson.D{
+			{Key: "stage", Value: "FETCH"},
+			{Key: "inputStage", Value: bson.D{{Key: "stage", Value: "IXSCAN"}, {Key: "indexName", Value: "email_1"}}},
+		}}}},
+		{Key: "executionStats", Value: bson.D{
+			{Key: "nReturned", Value: int32(1)},
+			{Key: "totalKeysExamined", Value: int32(1)},
+			{Key: "totalDocsExamined", Value: int32(1)},
+			{Key: "executionTimeMillis", Value: int32(3)},
+		}},
+	})
+
+	res := parseExplain(raw)
+	if res.WinningStage != "FETCH" || res.Index != "email_1" || res.CollectionScan() {
+		t.Fatalf("unexpected plan %+v", res)
+	}
+	if res.Returned != 1 || res.DocsExamined != 1 || res.ExecutionTime != 3*time.Millisecond {
+		t.Fatalf("unexpected execution stats %+v", res)
+	}
+}
+
+func TestParseExplain_SlotBasedEngine(t *testing.T) {
+	raw := mustRaw(t, bson.D{{Key: "queryPlanner", Value: bson.D{{Key: "winningPlan", Value: bson.D{
+		{Key: "queryPlan", Value: bson.D{{Key: "stage", Value: "COLLSCAN"}}},
+	}}}}})
+	if res := parseExplain(raw); !res.CollectionScan() || res.Index != "" {
+		t.Fatalf("unexpected plan %+v", res)
+	}
+}
@@ -0,0 +1,208 @@
+package mongoboiler
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Format is the file format of Export and Import.
+type Format int
+
+const (
+	// FormatExtJSON is newline-delimited canonical Extended JSON, one document per line.
+	FormatExtJSON Format = iota
+	// FormatBSON is concatenated BSON documents, as written by mongodump.
+	FormatBSON
+)
+
+// DefaultImportBatchSize is the number of documents Import inserts at once by default.
+const DefaultImportBatchSize = 1000
+
+// maxBSONDocumentSize bounds the documents Import reads, MongoDB stores at most 16 MiB.
+const maxBSONDocumentSize = 16 << 20
+
+// ImportOptions configures Import.
+type ImportOptions struct {
+	// BatchSize is the number of documents inserted at once, DefaultImportBatchSize if zero.
+	BatchSize int
+	// Upsert replaces the documents with the same _id instead of failing on them, inserting the
+	// others. Documents are then written one at a time.
+	Upsert bool
+}
+
+// ImportResult reports what Import wrote.
+type ImportResult struct {
+	// Inserted counts the documents inserted, in upsert mode those that did not exist.
+	Inserted int64
+	// Replaced counts the documents replaced in upsert mode.
+	Replaced int64
+}
+
+// Export writes the documents matching filter to w in format and returns how many there were.
+// Documents are read like by FindEach, so encrypted fields are written decrypted and encrypted
+// again by Import.
+func (c Collection) Export(ctx context.Context, w io.Writer, filter bson.D, format Format, opts ...*options.FindOptions) (int64, error) {
+	if format != FormatExtJSON && format != FormatBSON {
+		return 0, fmt.Errorf("mongoboiler: unknown export format %d", format)
+	}
+	progress, err := c.trackCount(ctx, "export", filter)
+	if err != nil {
+		return 0, err
+	}
+	defer progress.Finish()
+	bw := bufio.NewWriter(w)
+	var n int64
+	err = c.FindEach(ctx, nonNilFilter(filter), func(dec Decoder) error {
+		var raw bson.Raw
+		if err := dec.Decode(&raw); err != nil {
+			return err
+		}
+		if format == FormatBSON {
+			if _, err := bw.Write(raw); err != nil {
+				return err
+			}
+		} else {
+			line, err := bson.MarshalExtJSON(raw, true, false)
+			if err != nil {
+				return err
+			}
+			if _, err := bw.Write(append(line, '\n')); err != nil {
+				return err
+			}
+		}
+		n++
+		progress.Add(1)
+		return nil
+	}, opts...)
+	if err != nil {
+		return n, err
+	}
+	return n, bw.Flush()
+}
+
+// Import reads the documents in r, as written by Export in format, into the collection.
+// On error the documents of earlier batches stay imported.
+func (c Collection) Import(ctx context.Context, r io.Reader, format Format, opts ImportOptions) (ImportResult, error) {
+	var next func() (bson.Raw, error)
+	switch format {
+	case FormatExtJSON:
+		next = extJSONReader(r)
+	case FormatBSON:
+		next = bsonReader(r)
+	default:
+		return ImportResult{}, fmt.Errorf("mongoboiler: unknown import format %d", format)
+	}
+	size := opts.BatchSize
+	if size <= 0 {
+		size = DefaultImportBatchSize
+	}
+
+	progress := TrackProgress(ctx, "import "+c.name, 0)
+	defer progress.Finish()
+	var res ImportResult
+	batch := make([]any, 0, size)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		inserted, err := c.InsertMany(ctx, batch)
+		res.Inserted += int64(len(inserted.InsertedIDs))
+		progress.Add(int64(len(inserted.InsertedIDs)))
+		// A new slice, as middleware may keep the documents of an operation.
+		batch = make([]any, 0, size)
+		return err
+	}
+	for i := 0; ; i++ {
+		doc, err := next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return res, fmt.Errorf("mongoboiler: import document %d: %w", i, err)
+		}
+		if !opts.Upsert {
+			if batch = append(batch, doc); len(batch) == size {
+				if err := flush(); err != nil {
+					return res, err
+				}
+			}
+			continue
+		}
+
+		id, err := doc.LookupErr("_id")
+		if err != nil {
+			return res, fmt.Errorf("mongoboiler: import document %d: upsert needs an _id", i)
+		}
+		replaced, err := c.ReplaceOne(ctx, bson.D{{Key: "_id", Value: id}}, doc, options.Replace().SetUpsert(true))
+		if err != nil {
+			return res, err
+		}
+		if replaced.UpsertedCount > 0 {
+			res.Inserted++
+		} else {
+			res.Replaced++
+		}
+		progress.Add(1)
+	}
+	return res, flush()
+}
+
+// extJSONReader returns a function reading the next document of newline-delimited Extended JSON,
+// skipping blank lines.
+func extJSONReader(r io.Reader) func() (bson.Raw, error) {
+	br := bufio.NewReader(r)
+	return func() (bson.Raw, error) {
+		for {
+			line, err := br.ReadBytes('\n')
+			if len(line) == 0 && err != nil {
+				return nil, err
+			}
+			if len(bytes.TrimSpace(line)) == 0 {
+				if err != nil {
+					return nil, err
+				}
+				continue
+			}
+			var doc bson.Raw
+			if err := bson.UnmarshalExtJSON(line, false, &doc); err != nil {
+				return nil, err
+			}
+			return doc, nil
+		}
+	}
+}
+
+// bsonReader returns a function reading the next of concatenated BSON documents.
+func bsonReader(r io.Reader) func() (bson.Raw, error) {
+	br := bufio.NewReader(r)
+	return func() (bson.Raw, error) {
+		var header [4]byte
+		if _, err := io.ReadFull(br, header[:]); err != nil {
+			if errors.Is(err, io.ErrUnexpectedEOF) {
+				return nil, fmt.Errorf("truncated document: %w", err)
+			}
+			return nil, err
+		}
+		size := binary.LittleEndian.Uint32(header[:])
+		if size < 5 || size > maxBSONDocumentSize {
+			return nil, fmt.Errorf("invalid document size %d", size)
+		}
+		doc := make(bson.Raw, size)
+		copy(doc, header[:])
+		if _, err := io.ReadFull(br, doc[4:]); err != nil {
+			return nil, fmt.Errorf("truncated document: %w", err)
+		}
+		if err := doc.Validate(); err != nil {
+			return nil, err
+		}
+		return doc, nil
+	}
+}
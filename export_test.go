@@ -0,0 +1,102 @@
+package mongoboiler
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func exportTestCollection(t *testing.T, docs []bson.Raw, ops *[]*Operation) *Collection {
+	coll := newTestCollection(t, "items",
+		WithCache(NewLRUCache(100), 0),
+		WithMiddleware(func(next Handler) Handler {
+			return func(ctx context.Context, op *Operation) error {
+				if op.Kind.IsWrite() {
+					*ops = append(*ops, op)
+					return nil
+				}
+				return next(ctx, op)
+			}
+		}))
+	op := coll.newOp(OpFind)
+	op.Filter = bson.D{}
+	key, ok := coll.readCache().key(context.Background(), op, []*options.FindOptions(nil))
+	if !ok {
+		t.Fatalf("expected the read to be cacheable")
+	}
+	coll.readCache().set(context.Background(), key, docs)
+	return coll
+}
+
+func TestExportImport_RoundTrip(t *testing.T) {
+	ctx := context.Background()
+	docs := []bson.Raw{
+		mustRaw(t, bson.D{{Key: "_id", Value: primitive.NewObjectID()}, {Key: "qty", Value: int32(3)}}),
+		mustRaw(t, bson.D{{Key: "_id", Value: primitive.NewObjectID()}, {Key: "qty", Value: int64(4)}}),
+		mustRaw(t, bson.D{{Key: "_id", Value: "c"}, {Key: "qty", Value: 2.5}}),
+	}
+
+	for _, format := range []Format{FormatExtJSON, FormatBSON} {
+		var ops []*Operation
+		coll := exportTestCollection(t, docs, &ops)
+		var buf bytes.Buffer
+		n, err := coll.Export(ctx, &buf, nil, format)
+		if err != nil || n != 3 {
+			t.Fatalf("format %d: Export = %d, %v", format, n, err)
+		}
+		if format == FormatExtJSON && strings.Count(buf.String(), "\n") != 3 {
+			t.Fatalf("expected one line per document, got %q", buf.String())
+		}
+
+		if _, err := coll.Import(ctx, &buf, format, ImportOptions{BatchSize: 2}); err != nil {
+			t.Fatalf("format %d: Import failed: %v", format, err)
+		}
+		if len(ops) != 2 || len(ops[0].Documents) != 2 || len(ops[1].Documents) != 1 {
+			t.Fatalf("format %d: expected batches of 2 and 1, got %d inserts", format, len(ops))
+		}
+		for i, want := range docs {
+			got := ops[i/2].Documents[i%2].(bson.Raw)
+			if !bytes.Equal(got, want) {
+				t.Fatalf("format %d: document %d = %v, want %v", format, i, got, want)
+			}
+		}
+	}
+}
+
+func TestImport_Upsert(t *testing.T) {
+	var ops []*Operation
+	coll := exportTestCollection(t, nil, &ops)
+	in := "{\"_id\": 1, \"a\": 1}\n\n{\"_id\": 2, \"a\": 2}\n"
+
+	res, err := coll.Import(context.Background(), strings.NewReader(in), FormatExtJSON, ImportOptions{Upsert: true})
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if len(ops) != 2 || ops[0].Kind != OpReplaceOne || res.Replaced+res.Inserted != 2 {
+		t.Fatalf("expected two replaces, got %d ops and %+v", len(ops), res)
+	}
+	if id := ops[1].Filter[0]; id.Key != "_id" {
+		t.Fatalf("expected an _id filter, got %v", ops[1].Filter)
+	}
+
+	_, err = coll.Import(context.Background(), strings.NewReader(`{"a": 1}`), FormatExtJSON, ImportOptions{Upsert: true})
+	if err == nil {
+		t.Fatalf("expected an error for a document without _id")
+	}
+}
+
+func TestImport_TruncatedBSON(t *testing.T) {
+	var ops []*Operation
+	coll := exportTestCollection(t, nil, &ops)
+	doc := mustRaw(t, bson.D{{Key: "a", Value: 1}})
+
+	_, err := coll.Import(context.Background(), bytes.NewReader(doc[:len(doc)-2]), FormatBSON, ImportOptions{})
+	if err == nil || !strings.Contains(err.Error(), "truncated") {
+		t.Fatalf("expected a truncated document error, got %v", err)
+	}
+}
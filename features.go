@@ -0,0 +1,211 @@
+package mongoboiler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrUnsupportedFeature matches every UnsupportedFeatureError with errors.Is.
+var ErrUnsupportedFeature = errors.New("mongoboiler: server does not support the feature")
+
+// Feature is a server feature newer than the oldest supported server version. The builders for
+// such features check the connected server supports them with DB.RequireFeature before sending
+// anything, so an old server fails with a clear error instead of an unknown stage or command.
+type Feature struct {
+	Name       string
+	MinVersion string
+}
+
+func (f Feature) String() string {
+	return f.Name + " (" + f.MinVersion + ")"
+}
+
+var (
+	// FeatureQueryStats is the $queryStats stage, see DB.QueryStats.
+	FeatureQueryStats = Feature{Name: "$queryStats", MinVersion: "7.1"}
+	// FeatureRankFusion is the $rankFusion stage, see RankFusionBuilder.
+	FeatureRankFusion = Feature{Name: "$rankFusion", MinVersion: "8.1"}
+)
+
+// UnsupportedFeatureError is returned when the server is older than a feature needs.
+type UnsupportedFeatureError struct {
+	Feature       Feature
+	ServerVersion string
+}
+
+func (e *UnsupportedFeatureError) Error() string {
+	return fmt.Sprintf("mongoboiler: %s needs server %s or later, server is %s", e.Feature.Name, e.Feature.MinVersion, e.ServerVersion)
+}
+
+// Is makes errors.Is(err, ErrUnsupportedFeature) true.
+func (e *UnsupportedFeatureError) Is(target error) bool {
+	return target == ErrUnsupportedFeature
+}
+
+// Code returns CodePreconditionFailed.
+func (e *UnsupportedFeatureError) Code() ErrorCode {
+	return CodePreconditionFailed
+}
+
+// Supports reports whether the connected server supports f. The server version is read once and
+// then remembered for the connection.
+func (db *DB) Supports(ctx context.Context, f Feature) (bool, error) {
+	version, err := db.conn.version.get(ctx, db)
+	if err != nil {
+		return false, err
+	}
+	return compareVersions(version, f.MinVersion) >= 0, nil
+}
+
+// RequireFeature returns an *UnsupportedFeatureError unless the connected server supports f.
+func (db *DB) RequireFeature(ctx context.Context, f Feature) error {
+	ok, err := db.Supports(ctx, f)
+	if err != nil || ok {
+		return err
+	}
+	version, _ := db.conn.version.get(ctx, db)
+	return &UnsupportedFeatureError{Feature: f, ServerVersion: version}
+}
+
+// serverVersion remembers the version of the server of a connection.
+type serverVersion struct {
+	mu      sync.Mutex
+	version string
+}
+
+// get returns the server version, asking the server the first time. Failures are not
+// remembered, so the next call asks again.
+func (v *serverVersion) get(ctx context.Context, db *DB) (string, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.version != "" {
+		return v.version, nil
+	}
+	version, err := db.ServerVersion(ctx)
+	if err != nil {
+		return "", err
+	}
+	v.version = version
+	return version, nil
+}
+
+// RankFusionBuilder builds a $rankFusion stage, combining the rankings of several pipelines
+// with reciprocal rank fusion, e.g. a $vectorSearch and a $search for hybrid search:
+//
+//	fusion := mongoboiler.RankFusion().
+//		Input("vector", vectorPipeline, 2).
+//		Input("text", mongo.Pipeline{mongoboiler.AtlasSearch("default").Text(query, "title").Stage()}, 1)
+//	err := coll.RankFusion(ctx, fusion, &results)
+type RankFusionBuilder struct {
+	pipelines    bson.D
+	weights      bson.D
+	scoreDetails bool
+}
+
+// RankFusion starts a $rankFusion stage.
+func RankFusion() *RankFusionBuilder {
+	return &RankFusionBuilder{}
+}
+
+// Input adds the ranked pipeline under name, weighting its ranks by weight; weights of zero are
+// left to the server's default of 1.
+func (b *RankFusionBuilder) Input(name string, pipeline mongo.Pipeline, weight float64) *RankFusionBuilder {
+	b.pipelines = append(b.pipelines, bson.E{Key: name, Value: pipeline})
+	if weight != 0 {
+		b.weights = append(b.weights, bson.E{Key: name, Value: weight})
+	}
+	return b
+}
+
+// ScoreDetails adds how each input contributed to the score, in a scoreDetails field.
+func (b *RankFusionBuilder) ScoreDetails() *RankFusionBuilder {
+	b.scoreDetails = true
+	return b
+}
+
+// Stage returns the $rankFusion stage.
+func (b *RankFusionBuilder) Stage() bson.D {
+	fusion := bson.D{{Key: "input", Value: bson.D{{Key: "pipelines", Value: b.pipelines}}}}
+	if len(b.weights) > 0 {
+		fusion = append(fusion, bson.E{Key: "combination", Value: bson.D{{Key: "weights", Value: b.weights}}})
+	}
+	if b.scoreDetails {
+		fusion = append(fusion, bson.E{Key: "scoreDetails", Value: true})
+	}
+	return bson.D{{Key: "$rankFusion", Value: fusion}}
+}
+
+// Pipeline returns the $rankFusion stage followed by one adding the fused score, and the score
+// details if requested, to the documents.
+func (b *RankFusionBuilder) Pipeline() mongo.Pipeline {
+	fields := bson.D{{Key: TextScoreField, Value: bson.D{{Key: "$meta", Value: "score"}}}}
+	if b.scoreDetails {
+		fields = append(fields, bson.E{Key: "scoreDetails", Value: bson.D{{Key: "$meta", Value: "scoreDetails"}}})
+	}
+	return mongo.Pipeline{b.Stage(), {{Key: "$addFields", Value: fields}}}
+}
+
+// RankFusion runs the pipeline of fusion followed by stages, such as a $limit, and fills res, a
+// pointer to a slice, with the results best first. It fails with an *UnsupportedFeatureError on
+// servers before 8.1.
+func (c Collection) RankFusion(ctx context.Context, fusion *RankFusionBuilder, res any, stages ...bson.D) error {
+	if err := c.db.RequireFeature(ctx, FeatureRankFusion); err != nil {
+		return err
+	}
+	return c.Aggregate(ctx, append(fusion.Pipeline(), stages...), res)
+}
+
+// QueryStats configures DB.QueryStats.
+type QueryStats struct {
+	// HMACKey, when set, makes the server hash the field names and namespaces of the query shapes
+	// with HMAC-SHA-256, so the statistics can be exported without revealing them. The
+	// namespaces being hashed, the statistics of all databases are returned.
+	HMACKey []byte
+	// AllDatabases returns the statistics of every database instead of only those of the DB.
+	AllDatabases bool
+	// Pipeline holds stages applied to the statistics, such as a $sort on
+	// "metrics.totalExecMicros.sum".
+	Pipeline mongo.Pipeline
+}
+
+// Stage returns the $queryStats stage.
+func (q QueryStats) Stage() bson.D {
+	stats := bson.D{}
+	if len(q.HMACKey) > 0 {
+		stats = append(stats, bson.E{Key: "transformIdentifiers", Value: bson.D{
+			{Key: "algorithm", Value: "hmac-sha-256"},
+			{Key: "hmacKey", Value: primitive.Binary{Subtype: 8, Data: q.HMACKey}},
+		}})
+	}
+	return bson.D{{Key: "$queryStats", Value: stats}}
+}
+
+// QueryStats fills res, a pointer to a slice, with the runtime statistics the server collected
+// per query shape, e.g. to find the most expensive queries of the DB. It needs the queryStatsRead
+// privilege and fails with an *UnsupportedFeatureError on servers before 7.1.
+func (db *DB) QueryStats(ctx context.Context, q QueryStats, res any) error {
+	if v := reflect.ValueOf(res); v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Slice {
+		return ErrNotSlicePointer
+	}
+	if err := db.RequireFeature(ctx, FeatureQueryStats); err != nil {
+		return err
+	}
+	pipeline := mongo.Pipeline{q.Stage()}
+	if !q.AllDatabases && len(q.HMACKey) == 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$match", Value: bson.D{{Key: "key.queryShape.cmdNs.db", Value: db.databaseName()}}}})
+	}
+	pipeline = append(pipeline, q.Pipeline...)
+	// $queryStats runs on the admin database, without a collection.
+	cursor, err := db.client().Database("admin").Aggregate(ctx, pipeline)
+	if err != nil {
+		return TranslateError(err)
+	}
+	return TranslateError(cursor.All(ctx, res))
+}
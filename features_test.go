@@ -0,0 +1,59 @@
+package mongoboiler
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestRequireFeature(t *testing.T) {
+	db := newTestCollection(t, "products").db
+	db.conn.version.version = "8.0.4"
+	if ok, err := db.Supports(context.Background(), FeatureQueryStats); !ok || err != nil {
+		t.Fatalf("Supports($queryStats) = %v, %v on 8.0", ok, err)
+	}
+	err := db.RequireFeature(context.Background(), FeatureRankFusion)
+	var unsupported *UnsupportedFeatureError
+	if !errors.Is(err, ErrUnsupportedFeature) || !errors.As(err, &unsupported) || unsupported.ServerVersion != "8.0.4" {
+		t.Fatalf("got %v, want an UnsupportedFeatureError for 8.0.4", err)
+	}
+
+	var res []bson.M
+	if err := db.NewCollection("products").RankFusion(context.Background(), RankFusion(), &res); !errors.Is(err, ErrUnsupportedFeature) {
+		t.Fatalf("RankFusion on 8.0: got %v", err)
+	}
+}
+
+func TestRankFusionBuilder(t *testing.T) {
+	vector := mongo.Pipeline{{{Key: "$vectorSearch", Value: bson.D{}}}}
+	text := mongo.Pipeline{AtlasSearch("default").Text("pizza", "name").Stage()}
+	got := RankFusion().Input("vector", vector, 2).Input("text", text, 0).ScoreDetails().Pipeline()
+	want := mongo.Pipeline{
+		{{Key: "$rankFusion", Value: bson.D{
+			{Key: "input", Value: bson.D{{Key: "pipelines", Value: bson.D{{Key: "vector", Value: vector}, {Key: "text", Value: text}}}}},
+			{Key: "combination", Value: bson.D{{Key: "weights", Value: bson.D{{Key: "vector", Value: 2.0}}}}},
+			{Key: "scoreDetails", Value: true},
+		}}},
+		{{Key: "$addFields", Value: bson.D{
+			{Key: TextScoreField, Value: bson.D{{Key: "$meta", Value: "score"}}},
+			{Key: "scoreDetails", Value: bson.D{{Key: "$meta", Value: "scoreDetails"}}},
+		}}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestQueryStatsStage(t *testing.T) {
+	if got := (QueryStats{}).Stage(); !reflect.DeepEqual(got, bson.D{{Key: "$queryStats", Value: bson.D{}}}) {
+		t.Fatalf("got %v", got)
+	}
+	stage := mustRaw(t, QueryStats{HMACKey: []byte("secret")}.Stage())
+	if alg := stage.Lookup("$queryStats", "transformIdentifiers", "algorithm").StringValue(); alg != "hmac-sha-256" {
+		t.Fatalf("got algorithm %q", alg)
+	}
+}
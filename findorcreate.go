@@ -0,0 +1,82 @@
+package mongoboiler
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// FindOrCreate atomically returns the document matching filter in res, inserting defaults
+// (combined with the equality fields of filter) when there is none. created reports whether
+// the document was inserted by this call. Concurrent calls for the same filter only insert once
+// when a unique index covers the filter fields.
+func (c Collection) FindOrCreate(ctx context.Context, filter bson.D, defaults any, res any) (created bool, err error) {
+	op := c.newOp(OpFindOrCreate)
+	op.needsEffect = true
+	op.Filter, op.Documents = filter, []any{defaults}
+	err = c.run(ctx, op, func(ctx context.Context, op *Operation) error {
+		cmd, err := findOrCreateCommand(op)
+		if err != nil {
+			return err
+		}
+		var reply findOrCreateReply
+		if err := op.Target.Database().RunCommand(ctx, cmd).Decode(&reply); err != nil {
+			return err
+		}
+		var result UpdateResult
+		created, result = reply.result()
+		op.Result = result
+		return c.decode(ctx, reply.Value, res)
+	})
+	return created, err
+}
+
+// findOrCreateCommand returns the findAndModify command of the FindOrCreate op.
+func findOrCreateCommand(op *Operation) (bson.D, error) {
+	insert, err := toDocument(op.Documents[0])
+	if err != nil {
+		return nil, err
+	}
+	cmd := bson.D{
+		{Key: "findAndModify", Value: op.Collection},
+		{Key: "query", Value: op.Filter},
+		{Key: "update", Value: bson.D{{Key: "$setOnInsert", Value: insert}}},
+		{Key: "upsert", Value: true},
+		{Key: "new", Value: true},
+	}
+	if op.Comment != "" {
+		cmd = append(cmd, bson.E{Key: "comment", Value: op.Comment})
+	}
+	return cmd, nil
+}
+
+// findOrCreateReply is the reply of the findAndModify command of FindOrCreate.
+type findOrCreateReply struct {
+	LastErrorObject struct {
+		UpdatedExisting bool `bson:"updatedExisting"`
+		Upserted        any  `bson:"upserted"`
+	} `bson:"lastErrorObject"`
+	Value bson.Raw `bson:"value"`
+}
+
+// result reports whether the command inserted the document, and the result of the operation.
+func (r findOrCreateReply) result() (created bool, result UpdateResult) {
+	created = !r.LastErrorObject.UpdatedExisting
+	result = UpdateResult{
+		MatchedCount:  boolCount(!created),
+		UpsertedCount: boolCount(created),
+		UpsertedID:    r.LastErrorObject.Upserted,
+		DocumentID:    r.LastErrorObject.Upserted,
+	}
+	if id, err := r.Value.LookupErr("_id"); err == nil && result.DocumentID == nil {
+		_ = id.Unmarshal(&result.DocumentID)
+	}
+	return created, result
+}
+
+func boolCount(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
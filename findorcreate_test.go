@@ -0,0 +1,79 @@
+package mongoboiler
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type findOrCreateUser struct {
+	ID    primitive.ObjectID `bson:"_id"`
+	Email string             `bson:"email"`
+	Plan  string             `bson:"plan"`
+}
+
+func TestFindOrCreateCommand(t *testing.T) {
+	coll := newTestCollection(t, "users")
+	op := coll.newOp(OpFindOrCreate)
+	op.Filter = bson.D{{Key: "email", Value: "ann@example.com"}}
+	op.Documents = []any{struct {
+		Plan string `bson:"plan"`
+	}{"free"}}
+	op.Comment = "request 7"
+
+	cmd, err := findOrCreateCommand(op)
+	if err != nil {
+		t.Fatalf("findOrCreateCommand failed: %v", err)
+	}
+	want := bson.D{
+		{Key: "findAndModify", Value: "users"},
+		{Key: "query", Value: op.Filter},
+		{Key: "update", Value: bson.D{{Key: "$setOnInsert", Value: bson.D{{Key: "plan", Value: "free"}}}}},
+		{Key: "upsert", Value: true},
+		{Key: "new", Value: true},
+		{Key: "comment", Value: "request 7"},
+	}
+	if !reflect.DeepEqual(cmd, want) {
+		t.Fatalf("got command %v, want %v", cmd, want)
+	}
+
+	op.Documents = []any{"not a document"}
+	if _, err := findOrCreateCommand(op); err == nil {
+		t.Fatalf("expected defaults that are no document to fail")
+	}
+}
+
+func TestFindOrCreateReply(t *testing.T) {
+	coll := newTestCollection(t, "users")
+	id := primitive.NewObjectID()
+	value := bson.D{{Key: "_id", Value: id}, {Key: "email", Value: "ann@example.com"}, {Key: "plan", Value: "free"}}
+	tests := []struct {
+		name        string
+		lastError   bson.D
+		wantCreated bool
+		want        UpdateResult
+	}{
+		{"created", bson.D{{Key: "n", Value: 1}, {Key: "updatedExisting", Value: false}, {Key: "upserted", Value: id}},
+			true, UpdateResult{UpsertedCount: 1, UpsertedID: id, DocumentID: id}},
+		{"found", bson.D{{Key: "n", Value: 1}, {Key: "updatedExisting", Value: true}},
+			false, UpdateResult{MatchedCount: 1, DocumentID: id}},
+	}
+	for _, tt := range tests {
+		raw := mustRaw(t, bson.D{{Key: "lastErrorObject", Value: tt.lastError}, {Key: "value", Value: value}, {Key: "ok", Value: 1.0}})
+		var reply findOrCreateReply
+		if err := bson.Unmarshal(raw, &reply); err != nil {
+			t.Fatalf("%s: decoding the reply failed: %v", tt.name, err)
+		}
+		created, result := reply.result()
+		if created != tt.wantCreated || !reflect.DeepEqual(result, tt.want) {
+			t.Errorf("%s: got %v, %+v, want %v, %+v", tt.name, created, result, tt.wantCreated, tt.want)
+		}
+		var user findOrCreateUser
+		if err := coll.decode(context.Background(), reply.Value, &user); err != nil || user.ID != id || user.Plan != "free" {
+			t.Errorf("%s: got user %+v, %v", tt.name, user, err)
+		}
+	}
+}
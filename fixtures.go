@@ -0,0 +1,101 @@
+package mongoboiler
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"gopkg.in/yaml.v3"
+)
+
+// Truncate deletes all documents of the collection while keeping it and its indexes, unlike Drop.
+func (c Collection) Truncate(ctx context.Context) (DeleteResult, error) {
+	return c.DeleteMany(ctx, bson.D{})
+}
+
+// LoadFixtures loads the fixture files of dir into the database. Every file holds the documents
+// of the collection it is named after (users.json → users) as a JSON or YAML array; Extended JSON
+// such as {"$oid": "..."} and {"$date": "..."} is supported in both. Each target collection is
+// truncated before its documents are inserted. Files with other extensions are ignored.
+func (db *DB) LoadFixtures(ctx context.Context, dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	var files []string
+	for _, e := range entries {
+		switch strings.ToLower(filepath.Ext(e.Name())) {
+		case ".json", ".yaml", ".yml":
+			if !e.IsDir() {
+				files = append(files, e.Name())
+			}
+		}
+	}
+	sort.Strings(files)
+
+	for _, name := range files {
+		docs, err := readFixture(filepath.Join(dir, name))
+		if err != nil {
+			return fmt.Errorf("mongoboiler: fixture %s: %w", name, err)
+		}
+		coll := db.NewCollection(strings.TrimSuffix(name, filepath.Ext(name)))
+		if _, err := coll.Truncate(ctx); err != nil {
+			return fmt.Errorf("mongoboiler: fixture %s: %w", name, err)
+		}
+		if len(docs) == 0 {
+			continue
+		}
+		if _, err := coll.InsertMany(ctx, docs); err != nil {
+			return fmt.Errorf("mongoboiler: fixture %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// readFixture parses a JSON or YAML fixture file into documents.
+func readFixture(path string) ([]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if ext := strings.ToLower(filepath.Ext(path)); ext == ".yaml" || ext == ".yml" {
+		// Through the node, as decoding into maps would sort the keys of the documents.
+		var root yaml.Node
+		if err := yaml.Unmarshal(data, &root); err != nil {
+			return nil, err
+		}
+		if len(root.Content) == 0 {
+			return nil, nil
+		}
+		var buf bytes.Buffer
+		if err := writeYAMLAsJSON(&buf, &root); err != nil {
+			return nil, err
+		}
+		data = buf.Bytes()
+	}
+	return parseExtJSONArray(data)
+}
+
+// parseExtJSONArray parses an Extended JSON array of documents.
+func parseExtJSONArray(data []byte) ([]any, error) {
+	// Extended JSON can only be unmarshaled into documents, so wrap the array in one.
+	wrapped := append(append([]byte(`{"docs":`), data...), '}')
+	var holder struct {
+		Docs []bson.D `bson:"docs"`
+	}
+	if err := bson.UnmarshalExtJSON(wrapped, false, &holder); err != nil {
+		return nil, err
+	}
+	docs := make([]any, len(holder.Docs))
+	for i, d := range holder.Docs {
+		docs[i] = d
+	}
+	return docs, nil
+}
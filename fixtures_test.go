@@ -0,0 +1,51 @@
+package mongoboiler
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestReadFixture(t *testing.T) {
+	dir := t.TempDir()
+	jsonFile := filepath.Join(dir, "users.json")
+	yamlFile := filepath.Join(dir, "orders.yaml")
+	os.WriteFile(jsonFile, []byte(`[{"_id": {"$oid": "64b7f1f1f1f1f1f1f1f1f1f1"}, "name": "ann"}]`), 0o600)
+	os.WriteFile(yamlFile, []byte("- _id: 1\n  status: open\n  at:\n    $date: \"2023-07-01T00:00:00Z\"\n"), 0o600)
+
+	docs, err := readFixture(jsonFile)
+	if err != nil {
+		t.Fatalf("readFixture json failed: %v", err)
+	}
+	id, _ := primitive.ObjectIDFromHex("64b7f1f1f1f1f1f1f1f1f1f1")
+	if len(docs) != 1 || docs[0].(bson.D)[0].Value != id {
+		t.Fatalf("unexpected json fixture: %v", docs)
+	}
+
+	docs, err = readFixture(yamlFile)
+	if err != nil {
+		t.Fatalf("readFixture yaml failed: %v", err)
+	}
+	at, ok := docs[0].(bson.D).Map()["at"].(primitive.DateTime)
+	if !ok || !at.Time().Equal(time.Date(2023, 7, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected yaml fixture: %v", docs)
+	}
+	var keys []string
+	for _, e := range docs[0].(bson.D) {
+		keys = append(keys, e.Key)
+	}
+	if strings.Join(keys, ",") != "_id,status,at" {
+		t.Fatalf("expected the keys in file order, got %v", keys)
+	}
+
+	empty := filepath.Join(dir, "empty.yaml")
+	os.WriteFile(empty, nil, 0o600)
+	if docs, err := readFixture(empty); err != nil || len(docs) != 0 {
+		t.Fatalf("expected no documents from an empty file, got %v, %v", docs, err)
+	}
+}
@@ -0,0 +1,75 @@
+package mongoboiler
+
+import (
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// GeoPoint is a location stored as a GeoJSON point, as needed by 2dsphere indexes.
+type GeoPoint struct {
+	Lng float64
+	Lat float64
+}
+
+type geoJSONPoint struct {
+	Type        string     `bson:"type"`
+	Coordinates [2]float64 `bson:"coordinates"`
+}
+
+// MarshalBSON marshals p as {type: "Point", coordinates: [lng, lat]}.
+func (p GeoPoint) MarshalBSON() ([]byte, error) {
+	return bson.Marshal(p.geoJSON())
+}
+
+// UnmarshalBSON decodes a GeoJSON point.
+func (p *GeoPoint) UnmarshalBSON(data []byte) error {
+	var doc geoJSONPoint
+	if err := bson.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	if doc.Type != "Point" {
+		return errors.New("mongoboiler: GeoJSON type " + doc.Type + " is not a Point")
+	}
+	p.Lng, p.Lat = doc.Coordinates[0], doc.Coordinates[1]
+	return nil
+}
+
+func (p GeoPoint) geoJSON() geoJSONPoint {
+	return geoJSONPoint{Type: "Point", Coordinates: [2]float64{p.Lng, p.Lat}}
+}
+
+// Near matches documents with field near the point, sorted nearest first. maxMeters limits the
+// distance unless zero. The field needs a 2dsphere index.
+func Near(field string, lng, lat, maxMeters float64) bson.E {
+	near := bson.D{{Key: "$geometry", Value: GeoPoint{lng, lat}.geoJSON()}}
+	if maxMeters > 0 {
+		near = append(near, bson.E{Key: "$maxDistance", Value: maxMeters})
+	}
+	return bson.E{Key: field, Value: bson.D{{Key: "$near", Value: near}}}
+}
+
+// Within matches documents with field inside the polygon given as [lng, lat] vertices.
+// The ring is closed automatically.
+func Within(field string, polygon [][2]float64) bson.E {
+	ring := append([][2]float64(nil), polygon...)
+	if len(ring) > 0 && ring[0] != ring[len(ring)-1] {
+		ring = append(ring, ring[0])
+	}
+	geometry := bson.D{{Key: "type", Value: "Polygon"}, {Key: "coordinates", Value: [][][2]float64{ring}}}
+	return bson.E{Key: field, Value: bson.D{{Key: "$geoWithin", Value: bson.D{{Key: "$geometry", Value: geometry}}}}}
+}
+
+// GeoIntersects matches documents with field intersecting geometry, a GeoPoint or any GeoJSON
+// document.
+func GeoIntersects(field string, geometry any) bson.E {
+	return bson.E{Key: field, Value: bson.D{{Key: "$geoIntersects", Value: bson.D{{Key: "$geometry", Value: geometry}}}}}
+}
+
+// EnsureGeoIndex creates a 2dsphere index on field, as needed by Near.
+func (c Collection) EnsureGeoIndex(ctx context.Context, field string) error {
+	_, err := c.collection().Indexes().CreateOne(ctx, mongo.IndexModel{Keys: bson.D{{Key: field, Value: "2dsphere"}}})
+	return err
+}
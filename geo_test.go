@@ -0,0 +1,37 @@
+package mongoboiler
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestGeoPoint_RoundTrip(t *testing.T) {
+	type place struct {
+		Location GeoPoint `bson:"location"`
+	}
+	data, err := bson.Marshal(place{GeoPoint{Lng: 13.4, Lat: 52.5}})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if typ := bson.Raw(data).Lookup("location", "type").StringValue(); typ != "Point" {
+		t.Fatalf("expected a GeoJSON point, got type %q", typ)
+	}
+	var got place
+	if err := bson.Unmarshal(data, &got); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if got.Location != (GeoPoint{Lng: 13.4, Lat: 52.5}) {
+		t.Fatalf("unexpected point %+v", got.Location)
+	}
+}
+
+func TestWithin_ClosesRing(t *testing.T) {
+	e := Within("loc", [][2]float64{{0, 0}, {1, 0}, {1, 1}})
+	geometry := e.Value.(bson.D)[0].Value.(bson.D)[0].Value.(bson.D)
+	ring := geometry[1].Value.([][][2]float64)[0]
+	if !reflect.DeepEqual(ring, [][2]float64{{0, 0}, {1, 0}, {1, 1}, {0, 0}}) {
+		t.Fatalf("unexpected ring %v", ring)
+	}
+}
@@ -2,7 +2,10 @@ module github.com/anurag925/mongoboiler
 
 go 1.19
 
-require go.mongodb.org/mongo-driver v1.12.1
+require (
+	go.mongodb.org/mongo-driver v1.12.1
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require (
 	github.com/golang/snappy v0.0.1 // indirect
@@ -0,0 +1,26 @@
+package mongoboiler
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CollectionAPI is the set of Collection methods services usually depend on. Accept it instead
+// of *Collection to swap in the in-memory fake of package mongoboilertest in unit tests.
+type CollectionAPI interface {
+	Drop(ctx context.Context, confirm ...DropConfirmation) error
+	FindOne(ctx context.Context, filter bson.D, res any, opts ...*options.FindOneOptions) error
+	FindMany(ctx context.Context, filter bson.D, res any, opts ...*options.FindOptions) error
+	FindEach(ctx context.Context, filter bson.D, fn func(dec Decoder) error, opts ...*options.FindOptions) error
+	InsertOne(ctx context.Context, new any, opts ...*options.InsertOneOptions) (InsertResult, error)
+	InsertMany(ctx context.Context, new []any, opts ...*options.InsertManyOptions) (InsertResult, error)
+	UpdateOne(ctx context.Context, filter, update bson.D, opts ...*options.UpdateOptions) (UpdateResult, error)
+	UpdateMany(ctx context.Context, filter, update bson.D, opts ...*options.UpdateOptions) (UpdateResult, error)
+	ReplaceOne(ctx context.Context, filter bson.D, doc any, opts ...*options.ReplaceOptions) (UpdateResult, error)
+	DeleteOne(ctx context.Context, filter bson.D, opts ...*options.DeleteOptions) (DeleteResult, error)
+	DeleteMany(ctx context.Context, filter bson.D, opts ...*options.DeleteOptions) (DeleteResult, error)
+}
+
+var _ CollectionAPI = (*Collection)(nil)
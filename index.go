@@ -0,0 +1,195 @@
+package mongoboiler
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsoncodec"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// EnableTTL makes the server delete documents once the date in field is older than after.
+// The TTL index is created, or its expiry updated when it already exists.
+func (c Collection) EnableTTL(ctx context.Context, field string, after time.Duration) error {
+	keys := bson.D{{Key: field, Value: 1}}
+	seconds := int32(after / time.Second)
+
+	specs, err := c.collection().Indexes().ListSpecifications(ctx)
+	if err != nil {
+		return err
+	}
+	for _, spec := range specs {
+		var specKeys bson.D
+		if err := bson.Unmarshal(spec.KeysDocument, &specKeys); err != nil {
+			return err
+		}
+		if len(specKeys) != 1 || specKeys[0].Key != field || !isAscending(specKeys[0].Value) {
+			continue
+		}
+		if spec.ExpireAfterSeconds != nil && *spec.ExpireAfterSeconds == seconds {
+			return nil
+		}
+		return c.db.database().RunCommand(ctx, bson.D{
+			{Key: "collMod", Value: c.collectionName()},
+			{Key: "index", Value: bson.D{{Key: "keyPattern", Value: keys}, {Key: "expireAfterSeconds", Value: seconds}}},
+		}).Err()
+	}
+
+	_, err = c.collection().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    keys,
+		Options: options.Index().SetExpireAfterSeconds(seconds),
+	})
+	return err
+}
+
+func isAscending(v any) bool {
+	switch n := v.(type) {
+	case int32:
+		return n == 1
+	case int64:
+		return n == 1
+	case float64:
+		return n == 1
+	}
+	return false
+}
+
+// AutoIndex creates the indexes declared on the fields of struct model:
+//
+//	Email     string    `bson:"email" index:"unique"`
+//	CreatedAt time.Time `bson:"createdAt" index:"desc"`
+//	Customer  string    `bson:"customer" index:"name=customer_status"`
+//	Status    string    `bson:"status" index:"name=customer_status"`
+//	ExpiresAt time.Time `bson:"expiresAt" ttl:"24h"`
+//
+// The index tag takes desc, 2dsphere, unique, sparse and name=<index>; fields sharing a name
+// form one compound index in field order. The ttl tag takes a time.ParseDuration value and is applied
+// with EnableTTL.
+func (c Collection) AutoIndex(ctx context.Context, model any) error {
+	indexes, ttls, err := indexesOf(model)
+	if err != nil {
+		return err
+	}
+	if len(indexes) > 0 {
+		if _, err := c.collection().Indexes().CreateMany(ctx, indexes); err != nil {
+			return err
+		}
+	}
+	for _, ttl := range ttls {
+		if err := c.EnableTTL(ctx, ttl.field, ttl.after); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type ttlIndex struct {
+	field string
+	after time.Duration
+}
+
+func indexesOf(model any) ([]mongo.IndexModel, []ttlIndex, error) {
+	rt := reflect.TypeOf(model)
+	for rt != nil && rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+	if rt == nil || rt.Kind() != reflect.Struct {
+		return nil, nil, ErrNotStruct
+	}
+
+	var indexes []mongo.IndexModel
+	var ttls []ttlIndex
+	named := map[string]int{}
+	err := walkIndexFields(rt, func(field string, sf reflect.StructField) error {
+		if v, ok := sf.Tag.Lookup("ttl"); ok {
+			after, err := time.ParseDuration(v)
+			if err != nil {
+				return fmt.Errorf("mongoboiler: ttl tag of %s: %w", sf.Name, err)
+			}
+			ttls = append(ttls, ttlIndex{field, after})
+		}
+		tag, ok := sf.Tag.Lookup("index")
+		if !ok {
+			return nil
+		}
+
+		var order any = 1
+		opts, name := options.Index(), ""
+		for _, part := range strings.Split(tag, ",") {
+			key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+			switch key {
+			case "":
+			case "desc":
+				order = -1
+			case "2dsphere":
+				order = "2dsphere"
+			case "unique":
+				opts.SetUnique(true)
+			case "sparse":
+				opts.SetSparse(true)
+			case "name":
+				name = value
+			default:
+				return fmt.Errorf("mongoboiler: unknown index tag %q on %s", key, sf.Name)
+			}
+		}
+		key := bson.E{Key: field, Value: order}
+
+		if i, ok := named[name]; ok && name != "" {
+			idx := &indexes[i]
+			idx.Keys = append(idx.Keys.(bson.D), key)
+			if opts.Unique != nil {
+				idx.Options.SetUnique(true)
+			}
+			if opts.Sparse != nil {
+				idx.Options.SetSparse(true)
+			}
+			return nil
+		}
+		if name != "" {
+			opts.SetName(name)
+			named[name] = len(indexes)
+		}
+		indexes = append(indexes, mongo.IndexModel{Keys: bson.D{key}, Options: opts})
+		return nil
+	})
+	return indexes, ttls, err
+}
+
+// walkIndexFields calls fn with the document field name of every field of rt, descending into
+// inline structs.
+func walkIndexFields(rt reflect.Type, fn func(field string, sf reflect.StructField) error) error {
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		tags, err := bsoncodec.DefaultStructTagParser(sf)
+		if err != nil {
+			return err
+		}
+		if tags.Skip {
+			continue
+		}
+		if ft := sf.Type; tags.Inline {
+			for ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				if err := walkIndexFields(ft, fn); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if err := fn(tags.Name, sf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
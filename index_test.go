@@ -0,0 +1,45 @@
+package mongoboiler
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type indexedSession struct {
+	Token     string    `bson:"token" index:"unique"`
+	Customer  string    `bson:"customer" index:"name=customer_status"`
+	Status    string    `bson:"status" index:"name=customer_status,desc"`
+	UpdatedAt time.Time `bson:"updatedAt" index:"desc" ttl:"24h"`
+}
+
+func TestIndexesOf(t *testing.T) {
+	indexes, ttls, err := indexesOf(&indexedSession{})
+	if err != nil {
+		t.Fatalf("indexesOf failed: %v", err)
+	}
+	if len(indexes) != 3 {
+		t.Fatalf("expected 3 indexes, got %d", len(indexes))
+	}
+	if !*indexes[0].Options.Unique {
+		t.Fatalf("expected the token index to be unique")
+	}
+	wantCompound := bson.D{{Key: "customer", Value: 1}, {Key: "status", Value: -1}}
+	if !reflect.DeepEqual(indexes[1].Keys, wantCompound) || *indexes[1].Options.Name != "customer_status" {
+		t.Fatalf("unexpected compound index: %v", indexes[1].Keys)
+	}
+	if !reflect.DeepEqual(ttls, []ttlIndex{{"updatedAt", 24 * time.Hour}}) {
+		t.Fatalf("unexpected ttl indexes: %v", ttls)
+	}
+}
+
+func TestIndexesOf_InvalidTTL(t *testing.T) {
+	type bad struct {
+		At time.Time `bson:"at" ttl:"tomorrow"`
+	}
+	if _, _, err := indexesOf(bad{}); err == nil {
+		t.Fatalf("expected an error for a malformed ttl tag")
+	}
+}
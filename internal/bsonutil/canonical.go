@@ -0,0 +1,206 @@
+package bsonutil
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+// CanonicalFilter returns filter with the order of everything whose order does not change its
+// meaning normalized: conditions, query operators, $and/$or/$nor clauses and $in/$nin/$all
+// values. Documents matched for equality keep their order, as it matters there.
+// filter must consist of bson.D and bson.A values, as produced by bson.Unmarshal into a bson.D.
+func CanonicalFilter(filter bson.D) bson.D {
+	out := make(bson.D, 0, len(filter))
+	for _, e := range filter {
+		switch e.Key {
+		case "$and", "$or", "$nor":
+			if clauses, ok := e.Value.(bson.A); ok {
+				canonical := make(bson.A, len(clauses))
+				for i, c := range clauses {
+					if d, ok := c.(bson.D); ok {
+						canonical[i] = CanonicalFilter(d)
+					} else {
+						canonical[i] = c
+					}
+				}
+				e.Value = sortValues(canonical)
+			}
+		case "$expr", "$where", "$text", "$jsonSchema", "$comment":
+		default:
+			e.Value = canonicalCondition(e.Value)
+		}
+		out = append(out, e)
+	}
+	return sortKeys(out)
+}
+
+func canonicalCondition(v any) any {
+	d, ok := v.(bson.D)
+	if !ok || len(d) == 0 || !strings.HasPrefix(d[0].Key, "$") {
+		return v
+	}
+	out := make(bson.D, 0, len(d))
+	for _, e := range d {
+		switch e.Key {
+		case "$in", "$nin", "$all":
+			if values, ok := e.Value.(bson.A); ok {
+				e.Value = sortValues(append(bson.A(nil), values...))
+			}
+		case "$not":
+			e.Value = canonicalCondition(e.Value)
+		case "$elemMatch":
+			if sub, ok := e.Value.(bson.D); ok {
+				if len(sub) > 0 && strings.HasPrefix(sub[0].Key, "$") {
+					e.Value = canonicalCondition(sub)
+				} else {
+					e.Value = CanonicalFilter(sub)
+				}
+			}
+		}
+		out = append(out, e)
+	}
+	return sortKeys(out)
+}
+
+// orderlessStages are the pipeline stages whose options can be given in any order.
+var orderlessStages = map[string]bool{
+	"$group": true, "$project": true, "$lookup": true, "$unwind": true, "$bucket": true,
+	"$bucketAuto": true, "$graphLookup": true, "$geoNear": true, "$sample": true, "$unionWith": true,
+}
+
+// CanonicalPipeline returns pipeline with $match filters canonicalized like CanonicalFilter and
+// the options of stages such as $group, $project and $lookup sorted. Stage order, $sort and
+// stages that define fields in order, like $addFields, are kept.
+func CanonicalPipeline(pipeline []bson.D) []bson.D {
+	out := make([]bson.D, len(pipeline))
+	for i, stage := range pipeline {
+		out[i] = canonicalStage(stage)
+	}
+	return out
+}
+
+func canonicalStage(stage bson.D) bson.D {
+	if len(stage) != 1 {
+		return stage
+	}
+	name, body := stage[0].Key, stage[0].Value
+	d, isDoc := body.(bson.D)
+	switch {
+	case !isDoc:
+	case name == "$match":
+		body = CanonicalFilter(d)
+	case name == "$facet":
+		facets := make(bson.D, len(d))
+		for i, f := range d {
+			facets[i] = bson.E{Key: f.Key, Value: canonicalSubPipeline(f.Value)}
+		}
+		body = sortKeys(facets)
+	case orderlessStages[name]:
+		opts := append(bson.D(nil), d...)
+		for i, o := range opts {
+			if o.Key == "pipeline" {
+				opts[i].Value = canonicalSubPipeline(o.Value)
+			}
+		}
+		body = sortKeys(opts)
+	}
+	return bson.D{{Key: name, Value: body}}
+}
+
+func canonicalSubPipeline(v any) any {
+	stages, ok := v.(bson.A)
+	if !ok {
+		return v
+	}
+	out := make(bson.A, len(stages))
+	for i, s := range stages {
+		if d, ok := s.(bson.D); ok {
+			out[i] = canonicalStage(d)
+		} else {
+			out[i] = s
+		}
+	}
+	return out
+}
+
+func sortKeys(d bson.D) bson.D {
+	sort.SliceStable(d, func(i, j int) bool { return d[i].Key < d[j].Key })
+	return d
+}
+
+// sortValues sorts values by their Extended JSON form, giving a stable order for comparison.
+func sortValues(values bson.A) bson.A {
+	keys := make([]string, len(values))
+	for i, v := range values {
+		keys[i] = fmt.Sprint(v)
+		if data, err := bson.MarshalExtJSON(bson.D{{Key: "v", Value: v}}, false, false); err == nil {
+			keys[i] = string(data)
+		}
+	}
+	sort.Sort(byKey{values, keys})
+	return values
+}
+
+type byKey struct {
+	values bson.A
+	keys   []string
+}
+
+func (b byKey) Len() int           { return len(b.values) }
+func (b byKey) Less(i, j int) bool { return b.keys[i] < b.keys[j] }
+func (b byKey) Swap(i, j int) {
+	b.values[i], b.values[j] = b.values[j], b.values[i]
+	b.keys[i], b.keys[j] = b.keys[j], b.keys[i]
+}
+
+// Diff returns the dotted path of the first difference between the documents a and b, with
+// numbers of different types comparing by value. ok is false when they are equal.
+func Diff(a, b bson.Raw) (path string, ok bool) {
+	return diffValues(bson.RawValue{Type: bson.TypeEmbeddedDocument, Value: a}, bson.RawValue{Type: bson.TypeEmbeddedDocument, Value: b}, "")
+}
+
+func diffValues(a, b bson.RawValue, path string) (string, bool) {
+	container := func(t bsontype.Type) bool { return t == bson.TypeEmbeddedDocument || t == bson.TypeArray }
+	if !container(a.Type) || a.Type != b.Type {
+		if !container(a.Type) && !container(b.Type) && Equal(a, b) {
+			return "", false
+		}
+		return orRoot(path), true
+	}
+
+	ea, _ := bson.Raw(a.Value).Elements()
+	eb, _ := bson.Raw(b.Value).Elements()
+	for i := 0; i < len(ea) && i < len(eb); i++ {
+		if ea[i].Key() != eb[i].Key() {
+			return join(path, ea[i].Key()), true
+		}
+		if p, ok := diffValues(ea[i].Value(), eb[i].Value(), join(path, ea[i].Key())); ok {
+			return p, true
+		}
+	}
+	switch {
+	case len(ea) > len(eb):
+		return join(path, ea[len(eb)].Key()), true
+	case len(eb) > len(ea):
+		return join(path, eb[len(ea)].Key()), true
+	}
+	return "", false
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func orRoot(path string) string {
+	if path == "" {
+		return "(root)"
+	}
+	return path
+}
@@ -0,0 +1,149 @@
+// Package bsonutil holds BSON helpers shared by the mongoboiler packages.
+package bsonutil
+
+import (
+	"bytes"
+	"math"
+	"strconv"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+// typeOrder ranks BSON types in the order MongoDB sorts values of different types.
+func typeOrder(t bsontype.Type) int {
+	switch t {
+	case bson.TypeMinKey:
+		return 0
+	case bson.TypeUndefined, bson.TypeNull:
+		return 1
+	case bson.TypeInt32, bson.TypeInt64, bson.TypeDouble, bson.TypeDecimal128:
+		return 2
+	case bson.TypeString, bson.TypeSymbol:
+		return 3
+	case bson.TypeEmbeddedDocument:
+		return 4
+	case bson.TypeArray:
+		return 5
+	case bson.TypeBinary:
+		return 6
+	case bson.TypeObjectID:
+		return 7
+	case bson.TypeBoolean:
+		return 8
+	case bson.TypeDateTime:
+		return 9
+	case bson.TypeTimestamp:
+		return 10
+	case bson.TypeRegex:
+		return 11
+	case bson.TypeMaxKey:
+		return 13
+	}
+	return 12
+}
+
+// IsNumber reports whether v holds a numeric BSON type.
+func IsNumber(v bson.RawValue) bool {
+	return typeOrder(v.Type) == 2
+}
+
+// Float returns the value of a numeric BSON value as float64.
+func Float(v bson.RawValue) float64 {
+	switch v.Type {
+	case bson.TypeInt32:
+		return float64(v.Int32())
+	case bson.TypeInt64:
+		return float64(v.Int64())
+	case bson.TypeDouble:
+		return v.Double()
+	case bson.TypeDecimal128:
+		f, _ := strconv.ParseFloat(v.Decimal128().String(), 64)
+		return f
+	}
+	return math.NaN()
+}
+
+// Compare compares two BSON values following the MongoDB sort order, returning -1, 0 or 1.
+// Numbers of different types compare by value; documents and arrays compare element by element.
+func Compare(a, b bson.RawValue) int {
+	oa, ob := typeOrder(a.Type), typeOrder(b.Type)
+	if oa != ob {
+		return cmp(oa, ob)
+	}
+
+	switch {
+	case oa == 2:
+		if a.Type != bson.TypeDouble && a.Type != bson.TypeDecimal128 && b.Type != bson.TypeDouble && b.Type != bson.TypeDecimal128 {
+			ia, _ := a.AsInt64OK()
+			ib, _ := b.AsInt64OK()
+			return cmp(ia, ib)
+		}
+		return cmp(Float(a), Float(b))
+	case oa == 3:
+		return cmp(stringOf(a), stringOf(b))
+	case a.Type == bson.TypeEmbeddedDocument || a.Type == bson.TypeArray:
+		return compareDocuments(a.Value, b.Value)
+	case a.Type == bson.TypeBoolean:
+		return cmp(boolInt(a.Boolean()), boolInt(b.Boolean()))
+	case a.Type == bson.TypeDateTime:
+		return cmp(a.DateTime(), b.DateTime())
+	case a.Type == bson.TypeTimestamp:
+		ta, ia := a.Timestamp()
+		tb, ib := b.Timestamp()
+		if ta != tb {
+			return cmp(ta, tb)
+		}
+		return cmp(ia, ib)
+	case oa == 1 || oa == 0 || oa == 13:
+		return 0
+	}
+	return bytes.Compare(a.Value, b.Value)
+}
+
+// Equal reports whether a and b are equal BSON values, see Compare.
+func Equal(a, b bson.RawValue) bool {
+	return Compare(a, b) == 0
+}
+
+func compareDocuments(a, b []byte) int {
+	ea, _ := bson.Raw(a).Elements()
+	eb, _ := bson.Raw(b).Elements()
+	for i := 0; i < len(ea) && i < len(eb); i++ {
+		if c := Compare(ea[i].Value(), eb[i].Value()); c != 0 {
+			return c
+		}
+		if c := cmp(ea[i].Key(), eb[i].Key()); c != 0 {
+			return c
+		}
+	}
+	return cmp(len(ea), len(eb))
+}
+
+func stringOf(v bson.RawValue) string {
+	if v.Type == bson.TypeSymbol {
+		return v.Symbol()
+	}
+	return v.StringValue()
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+type ordered interface {
+	~int | ~int64 | ~uint32 | ~float64 | ~string
+}
+
+func cmp[T ordered](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,173 @@
+package mongoboiler
+
+import (
+	"context"
+	"strings"
+
+	"github.com/anurag925/mongoboiler/internal/bsonutil"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// JoinSide is one input of MergeJoin.
+type JoinSide struct {
+	Collection *Collection
+	Filter     bson.D
+	// Key is the dotted path of the field joined on, _id if empty. It must hold scalar values;
+	// strings are compared bytewise, so the collection must not sort them with a collation.
+	Key string
+}
+
+// JoinPair is an element of a full outer join: Left or Right is nil when the other side has no
+// document with the key.
+type JoinPair struct {
+	Key         bson.RawValue
+	Left, Right bson.Raw
+}
+
+// MergeJoin streams the documents of both sides sorted by their key and calls fn for every
+// matching pair and every unmatched document, in key order, without loading either side into
+// memory; only documents sharing one key on the right side are buffered. Each side should have
+// an index on its key. It suits reconciling collections too large for $lookup:
+//
+//	err := mongoboiler.MergeJoin(ctx,
+//		mongoboiler.JoinSide{Collection: orders, Key: "invoiceId"},
+//		mongoboiler.JoinSide{Collection: invoices},
+//		func(p mongoboiler.JoinPair) error {
+//			if p.Left == nil || p.Right == nil {
+//				log.Printf("unmatched %v", p.Key)
+//			}
+//			return nil
+//		})
+//
+// Iteration stops at the first error returned by fn or a side, and that error is returned.
+func MergeJoin(ctx context.Context, left, right JoinSide, fn func(JoinPair) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	return mergeJoin(startJoinStream(ctx, left), startJoinStream(ctx, right), fn)
+}
+
+// joinSource yields documents with their join key, ok is false at the end.
+type joinSource interface {
+	next() (doc bson.Raw, key bson.RawValue, ok bool, err error)
+}
+
+func mergeJoin(left, right joinSource, fn func(JoinPair) error) error {
+	l, lk, lok, err := left.next()
+	if err != nil {
+		return err
+	}
+	r, rk, rok, err := right.next()
+	if err != nil {
+		return err
+	}
+	for lok || rok {
+		c := 0
+		switch {
+		case !rok:
+			c = -1
+		case !lok:
+			c = 1
+		default:
+			c = bsonutil.Compare(lk, rk)
+		}
+		switch {
+		case c < 0:
+			if err := fn(JoinPair{Key: lk, Left: l}); err != nil {
+				return err
+			}
+			if l, lk, lok, err = left.next(); err != nil {
+				return err
+			}
+		case c > 0:
+			if err := fn(JoinPair{Key: rk, Right: r}); err != nil {
+				return err
+			}
+			if r, rk, rok, err = right.next(); err != nil {
+				return err
+			}
+		default:
+			key := rk
+			run := []bson.Raw{r}
+			for {
+				if r, rk, rok, err = right.next(); err != nil {
+					return err
+				}
+				if !rok || !bsonutil.Equal(rk, key) {
+					break
+				}
+				run = append(run, r)
+			}
+			for lok && bsonutil.Equal(lk, key) {
+				for _, match := range run {
+					if err := fn(JoinPair{Key: key, Left: l, Right: match}); err != nil {
+						return err
+					}
+				}
+				if l, lk, lok, err = left.next(); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// joinStream reads one side of a join in a goroutine.
+type joinStream struct {
+	key  []string
+	docs chan bson.Raw
+	errc chan error
+
+	done bool
+	err  error
+}
+
+func startJoinStream(ctx context.Context, side JoinSide) *joinStream {
+	key := side.Key
+	if key == "" {
+		key = "_id"
+	}
+	filter := side.Filter
+	if filter == nil {
+		filter = bson.D{}
+	}
+	s := &joinStream{key: strings.Split(key, "."), docs: make(chan bson.Raw, 64), errc: make(chan error, 1)}
+	go func() {
+		defer close(s.docs)
+		s.errc <- side.Collection.FindEach(ctx, filter, func(dec Decoder) error {
+			var raw bson.Raw
+			if err := dec.Decode(&raw); err != nil {
+				return err
+			}
+			select {
+			case s.docs <- append(bson.Raw(nil), raw...):
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}, options.Find().SetSort(bson.D{{Key: key, Value: 1}}))
+	}()
+	return s
+}
+
+func (s *joinStream) next() (bson.Raw, bson.RawValue, bool, error) {
+	if s.done {
+		return nil, bson.RawValue{}, false, s.err
+	}
+	doc, ok := <-s.docs
+	if !ok {
+		s.done, s.err = true, <-s.errc
+		return nil, bson.RawValue{}, false, s.err
+	}
+	return doc, joinKey(doc, s.key), true, nil
+}
+
+// joinKey returns the value at path in doc, null when missing as the server sorts it.
+func joinKey(doc bson.Raw, path []string) bson.RawValue {
+	v, err := doc.LookupErr(path...)
+	if err != nil {
+		return bson.RawValue{Type: bson.TypeNull}
+	}
+	return v
+}
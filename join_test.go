@@ -0,0 +1,80 @@
+package mongoboiler
+
+import (
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type sliceSource struct {
+	docs []bson.Raw
+	key  string
+}
+
+func (s *sliceSource) next() (bson.Raw, bson.RawValue, bool, error) {
+	if len(s.docs) == 0 {
+		return nil, bson.RawValue{}, false, nil
+	}
+	doc := s.docs[0]
+	s.docs = s.docs[1:]
+	return doc, joinKey(doc, []string{s.key}), true, nil
+}
+
+func joinDocs(t *testing.T, key string, values ...any) *sliceSource {
+	s := &sliceSource{key: key}
+	for _, v := range values {
+		s.docs = append(s.docs, mustRaw(t, bson.D{{Key: key, Value: v}}))
+	}
+	return s
+}
+
+func TestMergeJoin(t *testing.T) {
+	left := joinDocs(t, "invoiceId", 1, 2, 2, 4, int64(5))
+	right := joinDocs(t, "_id", 2, 3, 4, 4, 5.0, 6)
+
+	var got []string
+	err := mergeJoin(left, right, func(p JoinPair) error {
+		side := "both"
+		switch {
+		case p.Right == nil:
+			side = "left"
+		case p.Left == nil:
+			side = "right"
+		}
+		got = append(got, p.Key.String()+":"+side)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("mergeJoin failed: %v", err)
+	}
+
+	want := []string{
+		`{"$numberInt":"1"}:left`,
+		`{"$numberInt":"2"}:both`, `{"$numberInt":"2"}:both`,
+		`{"$numberInt":"3"}:right`,
+		`{"$numberInt":"4"}:both`, `{"$numberInt":"4"}:both`,
+		`{"$numberDouble":"5.0"}:both`,
+		`{"$numberInt":"6"}:right`,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("pair %d: got %s, want %s", i, got[i], want[i])
+		}
+	}
+}
+
+func TestMergeJoin_StopsOnError(t *testing.T) {
+	stop := errors.New("stop")
+	calls := 0
+	err := mergeJoin(joinDocs(t, "k", 1, 2, 3), joinDocs(t, "k"), func(JoinPair) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Fatalf("expected to stop after the first pair, got %v after %d calls", err, calls)
+	}
+}
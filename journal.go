@@ -0,0 +1,471 @@
+package mongoboiler
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// JournalConfig configures WithJournal.
+type JournalConfig struct {
+	// Sink receives the entries, such as a CollectionJournal or a FileJournal.
+	Sink JournalSink
+	// Logger receives failures to append entries, the standard logger if nil. Such failures do
+	// not fail the journaled operation, which already happened.
+	Logger Logger
+}
+
+// JournalEntry is a write operation recorded by WithJournal, with what JournalReplayer needs to
+// apply it again.
+type JournalEntry struct {
+	ID          primitive.ObjectID `bson:"_id"`
+	At          time.Time          `bson:"at"`
+	OperationID string             `bson:"operationId"`
+	Database    string             `bson:"database"`
+	Collection  string             `bson:"collection"`
+	Operation   OpKind             `bson:"operation"`
+	Filter      bson.D             `bson:"filter,omitempty"`
+	Update      bson.D             `bson:"update,omitempty"`
+	// Documents holds the inserted documents with their _id, the replacement or the defaults of
+	// FindOrCreate.
+	Documents []bson.Raw `bson:"documents,omitempty"`
+	// Upsert is set for updates and replacements sent as upserts, which the replayer applies as
+	// upserts too.
+	Upsert bool `bson:"upsert,omitempty"`
+	// UpsertedID is the _id of the document an upsert or FindOrCreate inserted; the replayer
+	// inserts it with the same _id.
+	UpsertedID any `bson:"upsertedId,omitempty"`
+	// Uncertain is set for multi-document writes that failed after changing an unknown part of
+	// the documents; the replayer applies them as a whole.
+	Uncertain bool `bson:"uncertain,omitempty"`
+}
+
+// JournalSink stores journal entries in the order they are appended.
+type JournalSink interface {
+	Append(ctx context.Context, entry JournalEntry) error
+}
+
+// JournalReader returns journal entries in order, io.EOF after the last one.
+type JournalReader interface {
+	Next(ctx context.Context) (JournalEntry, error)
+}
+
+// WithJournal records every successful insert, update, replace, delete and drop of the
+// collection in cfg.Sink, for JournalReplayer to apply them again to another database, e.g. to
+// check in recovery drills that a restored backup plus the journal since matches production.
+// Writes in transactions are recorded when they succeed, even if the transaction aborts later.
+//
+// Failed writes of a single document changed nothing and are not recorded. Multi-document
+// writes may fail after changing some documents: an InsertMany failing for some of its documents
+// is recorded with those inserted, the documents before the first failing one when ordered. An
+// InsertMany, UpdateMany or DeleteMany failing otherwise on the server or the network is logged
+// and recorded with Uncertain set, as which documents it changed is unknown.
+//
+// Only the filter, update and documents of a write and whether it is an upsert are recorded,
+// options such as array filters, collations or hints are not. Register it after middleware
+// that rewrites operations, such as WithTenancy, to record what was sent to the server.
+func WithJournal(cfg JournalConfig) Option {
+	j := &journaler{cfg: cfg}
+	return WithMiddleware(j.middleware)
+}
+
+type journaler struct {
+	cfg JournalConfig
+}
+
+func (j *journaler) middleware(next Handler) Handler {
+	return func(ctx context.Context, op *Operation) error {
+		if !op.Kind.IsWrite() || op.dryRun {
+			return next(ctx, op)
+		}
+		writeErr := next(ctx, op)
+		written := allIndexes(len(op.Documents))
+		var uncertain bool
+		if writeErr != nil {
+			var ok bool
+			if written, ok = insertedIndexes(op, writeErr); !ok {
+				if !op.Kind.isMulti() || !mayHaveWritten(writeErr) {
+					return writeErr
+				}
+				written, uncertain = allIndexes(len(op.Documents)), true
+			} else if len(written) == 0 {
+				return writeErr
+			}
+		}
+		entry, ok, err := journalEntry(op, written)
+		if err == nil && ok {
+			entry.Uncertain = uncertain
+			// The entry must be appended even when the caller gives up right after the write.
+			actx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			err = j.cfg.Sink.Append(actx, entry)
+		}
+		logger := loggerOrDefault(j.cfg.Logger)
+		if err != nil {
+			logger.Printf("mongoboiler: journal %s %s.%s (op %s): %v", op.Kind, op.Database, op.Collection, op.ID, err)
+		} else if uncertain {
+			logger.Printf("mongoboiler: journal %s %s.%s (op %s): recorded as uncertain, it failed after possibly writing: %v",
+				op.Kind, op.Database, op.Collection, op.ID, writeErr)
+		}
+		return writeErr
+	}
+}
+
+// insertedIndexes returns the indexes of the documents an InsertMany failing with err for some
+// of them inserted. It reports false for other writes and failures.
+func insertedIndexes(op *Operation, err error) ([]int, bool) {
+	var bulk mongo.BulkWriteException
+	if op.Kind != OpInsertMany || !errors.As(err, &bulk) || bulk.WriteConcernError != nil || len(bulk.WriteErrors) == 0 {
+		return nil, false
+	}
+	failed := make(map[int]bool, len(bulk.WriteErrors))
+	for _, we := range bulk.WriteErrors {
+		failed[we.Index] = true
+	}
+	var inserted []int
+	for i := range op.Documents {
+		if failed[i] {
+			if !op.unordered {
+				// The documents after the failed one were not attempted.
+				break
+			}
+			continue
+		}
+		inserted = append(inserted, i)
+	}
+	return inserted, true
+}
+
+func allIndexes(n int) []int {
+	indexes := make([]int, n)
+	for i := range indexes {
+		indexes[i] = i
+	}
+	return indexes
+}
+
+// mayHaveWritten reports whether a write failing with err may have changed documents, i.e. it
+// failed on the server or the connection rather than before being sent.
+func mayHaveWritten(err error) bool {
+	var server mongo.ServerError
+	return errors.As(err, &server) || mongo.IsNetworkError(err) || mongo.IsTimeout(err)
+}
+
+// journalEntry returns the entry of the write op with the documents of op at the indexes
+// written, all of them unless a partially failed InsertMany inserted fewer. It reports false for
+// writes that changed nothing, such as a FindOrCreate finding its document.
+func journalEntry(op *Operation, written []int) (JournalEntry, bool, error) {
+	entry := JournalEntry{
+		ID:          primitive.NewObjectID(),
+		At:          time.Now().UTC(),
+		OperationID: op.ID,
+		Database:    op.Database,
+		Collection:  op.Collection,
+		Operation:   op.Kind,
+		Filter:      op.Filter,
+		Update:      op.Update,
+		Upsert:      op.Upsert,
+	}
+	if res, ok := op.Result.(UpdateResult); ok {
+		entry.UpsertedID = res.UpsertedID
+	}
+	if op.Kind == OpFindOrCreate && entry.UpsertedID == nil {
+		return entry, false, nil
+	}
+	inserted, _ := op.Result.(InsertResult)
+	for _, i := range written {
+		d, err := toDocument(op.Documents[i])
+		if err != nil {
+			return entry, false, err
+		}
+		// The driver adds generated IDs to its own copy of the documents only.
+		if _, ok := documentID(d); !ok && op.Kind.isInsert() && i < len(inserted.InsertedIDs) {
+			d = append(bson.D{{Key: "_id", Value: inserted.InsertedIDs[i]}}, d...)
+		}
+		raw, err := bson.Marshal(d)
+		if err != nil {
+			return entry, false, err
+		}
+		entry.Documents = append(entry.Documents, raw)
+	}
+	return entry, true, nil
+}
+
+func (k OpKind) isInsert() bool {
+	return k == OpInsertOne || k == OpInsertMany
+}
+
+// isMulti reports whether operations of kind may change several documents.
+func (k OpKind) isMulti() bool {
+	return k == OpInsertMany || k == OpUpdateMany || k == OpDeleteMany
+}
+
+// CollectionJournal keeps journal entries in a collection, which should live in another database
+// or cluster than the journaled collections so it survives their loss.
+type CollectionJournal struct {
+	coll *Collection
+}
+
+// NewCollectionJournal returns the journal kept in coll. Entries are written straight to the
+// driver, so coll's middleware does not apply to them.
+func NewCollectionJournal(coll *Collection) *CollectionJournal {
+	return &CollectionJournal{coll: coll}
+}
+
+// Append inserts entry.
+func (j *CollectionJournal) Append(ctx context.Context, entry JournalEntry) error {
+	_, err := j.coll.collection().InsertOne(ctx, entry)
+	return TranslateError(err)
+}
+
+// EnsureIndexes creates the index Reader sorts by.
+func (j *CollectionJournal) EnsureIndexes(ctx context.Context) error {
+	_, err := j.coll.collection().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "at", Value: 1}, {Key: "_id", Value: 1}},
+	})
+	return TranslateError(err)
+}
+
+// Reader returns the entries recorded at or after since, oldest first; the zero time returns all
+// of them. Close the reader once done.
+func (j *CollectionJournal) Reader(ctx context.Context, since time.Time) (*CollectionJournalReader, error) {
+	filter := bson.D{}
+	if !since.IsZero() {
+		filter = bson.D{{Key: "at", Value: bson.D{{Key: "$gte", Value: since}}}}
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "at", Value: 1}, {Key: "_id", Value: 1}})
+	cursor, err := j.coll.collection().Find(ctx, filter, opts)
+	if err != nil {
+		return nil, TranslateError(err)
+	}
+	return &CollectionJournalReader{cursor: cursor}, nil
+}
+
+// CollectionJournalReader reads the entries of a CollectionJournal.
+type CollectionJournalReader struct {
+	cursor *mongo.Cursor
+}
+
+// Next returns the next entry, io.EOF after the last one.
+func (r *CollectionJournalReader) Next(ctx context.Context) (JournalEntry, error) {
+	var entry JournalEntry
+	if !r.cursor.Next(ctx) {
+		if err := r.cursor.Err(); err != nil {
+			return entry, TranslateError(err)
+		}
+		return entry, io.EOF
+	}
+	return entry, r.cursor.Decode(&entry)
+}
+
+// Close closes the cursor of the reader.
+func (r *CollectionJournalReader) Close(ctx context.Context) error {
+	return r.cursor.Close(ctx)
+}
+
+// FileJournal writes journal entries to w as canonical Extended JSON, one entry per line, so
+// the journal keeps every BSON type and can be read with NewFileJournalReader. Entries are not
+// synced; use an *os.File opened with O_SYNC where every entry must reach the disk.
+type FileJournal struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewFileJournal returns the journal written to w.
+func NewFileJournal(w io.Writer) *FileJournal {
+	return &FileJournal{w: w}
+}
+
+// Append writes entry as a line. Concurrent appends are serialized.
+func (j *FileJournal) Append(ctx context.Context, entry JournalEntry) error {
+	line, err := bson.MarshalExtJSON(entry, true, false)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	_, err = j.w.Write(line)
+	return err
+}
+
+// maxJournalLine is the longest line a FileJournal writes: an entry holds at most a few
+// documents of up to 16MB, and Extended JSON is larger than BSON.
+const maxJournalLine = 64 << 20
+
+// FileJournalReader reads the entries a FileJournal wrote.
+type FileJournalReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+// NewFileJournalReader returns the reader of the journal in r.
+func NewFileJournalReader(r io.Reader) *FileJournalReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxJournalLine)
+	return &FileJournalReader{scanner: scanner}
+}
+
+// Next returns the next entry, io.EOF after the last one. Empty lines are skipped.
+func (r *FileJournalReader) Next(ctx context.Context) (JournalEntry, error) {
+	var entry JournalEntry
+	for r.scanner.Scan() {
+		r.line++
+		if len(r.scanner.Bytes()) == 0 {
+			continue
+		}
+		if err := bson.UnmarshalExtJSON(r.scanner.Bytes(), true, &entry); err != nil {
+			return entry, fmt.Errorf("mongoboiler: journal line %d: %w", r.line, err)
+		}
+		return entry, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return entry, err
+	}
+	return entry, io.EOF
+}
+
+// JournalReplayer applies journal entries to a database, see DB.NewJournalReplayer.
+type JournalReplayer struct {
+	// ContinueOnError logs failed entries and goes on with the next one instead of stopping.
+	ContinueOnError bool
+	// Logger receives the failed entries with ContinueOnError, the standard logger if nil.
+	Logger Logger
+
+	db    *DB
+	apply func(ctx context.Context, entry JournalEntry) error
+}
+
+// ReplayReport is the outcome of JournalReplayer.Replay.
+type ReplayReport struct {
+	Applied  int
+	Failed   int
+	Duration time.Duration
+}
+
+func (r ReplayReport) String() string {
+	return fmt.Sprintf("%d applied, %d failed in %s", r.Applied, r.Failed, r.Duration)
+}
+
+// NewJournalReplayer returns the replayer applying journal entries to the collections of db with
+// the names recorded in the entries, whatever database they were recorded in. The writes go
+// straight to the driver, without the middleware of db's collections, so they are applied as
+// recorded:
+//
+//	reader, err := journal.Reader(ctx, backupTakenAt)
+//	...
+//	report, err := restored.NewJournalReplayer().Replay(ctx, reader)
+func (db *DB) NewJournalReplayer() *JournalReplayer {
+	r := &JournalReplayer{db: db}
+	r.apply = r.applyEntry
+	return r
+}
+
+// Replay applies the entries of src in order until io.EOF. It stops at the first entry failing,
+// or reading src failing, unless ContinueOnError is set; the report counts the entries applied
+// so far.
+func (r *JournalReplayer) Replay(ctx context.Context, src JournalReader) (ReplayReport, error) {
+	var report ReplayReport
+	start := time.Now()
+	for {
+		entry, err := src.Next(ctx)
+		if errors.Is(err, io.EOF) {
+			report.Duration = time.Since(start)
+			return report, nil
+		}
+		if err != nil {
+			report.Duration = time.Since(start)
+			return report, err
+		}
+		if err := r.apply(ctx, entry); err != nil {
+			err = fmt.Errorf("mongoboiler: replaying %s on %s (op %s): %w", entry.Operation, entry.Collection, entry.OperationID, err)
+			report.Failed++
+			if !r.ContinueOnError || ctx.Err() != nil {
+				report.Duration = time.Since(start)
+				return report, err
+			}
+			loggerOrDefault(r.Logger).Printf("%v", err)
+			continue
+		}
+		report.Applied++
+	}
+}
+
+// applyEntry applies entry to the collection of the same name.
+func (r *JournalReplayer) applyEntry(ctx context.Context, entry JournalEntry) error {
+	target := r.db.database().Collection(entry.Collection)
+	filter := nonNilFilter(entry.Filter)
+	upsert := entry.Upsert || entry.UpsertedID != nil
+	var err error
+	switch entry.Operation {
+	case OpInsertOne, OpInsertMany:
+		docs := make([]any, len(entry.Documents))
+		for i, doc := range entry.Documents {
+			docs[i] = doc
+		}
+		_, err = target.InsertMany(ctx, docs)
+	case OpUpdateOne, OpUpdateMany:
+		update := entry.Update
+		if upsert {
+			update = withUpsertedID(update, filter, entry.UpsertedID)
+		}
+		if entry.Operation == OpUpdateOne {
+			_, err = target.UpdateOne(ctx, filter, update, options.Update().SetUpsert(upsert))
+		} else {
+			_, err = target.UpdateMany(ctx, filter, update, options.Update().SetUpsert(upsert))
+		}
+	case OpReplaceOne:
+		var doc bson.D
+		if doc, err = replayDocument(entry); err != nil {
+			break
+		}
+		if _, ok := documentID(doc); !ok && upsert {
+			doc = append(bson.D{{Key: "_id", Value: entry.UpsertedID}}, doc...)
+		}
+		_, err = target.ReplaceOne(ctx, filter, doc, options.Replace().SetUpsert(upsert))
+	case OpFindOrCreate:
+		var defaults bson.D
+		if defaults, err = replayDocument(entry); err != nil {
+			break
+		}
+		update := withUpsertedID(bson.D{{Key: "$setOnInsert", Value: defaults}}, filter, entry.UpsertedID)
+		_, err = target.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	case OpDeleteOne:
+		_, err = target.DeleteOne(ctx, filter)
+	case OpDeleteMany:
+		_, err = target.DeleteMany(ctx, filter)
+	case OpDrop:
+		err = target.Drop(ctx)
+	default:
+		return fmt.Errorf("mongoboiler: cannot replay %s", entry.Operation)
+	}
+	return TranslateError(err)
+}
+
+// replayDocument returns the single document of entry.
+func replayDocument(entry JournalEntry) (bson.D, error) {
+	if len(entry.Documents) != 1 {
+		return nil, fmt.Errorf("mongoboiler: %s entry has %d documents, want 1", entry.Operation, len(entry.Documents))
+	}
+	var doc bson.D
+	err := bson.Unmarshal(entry.Documents[0], &doc)
+	return doc, err
+}
+
+// withUpsertedID makes update insert its document with id, unless filter sets the _id already,
+// so the replayed upsert inserts the same document as the recorded one.
+func withUpsertedID(update, filter bson.D, id any) bson.D {
+	if _, ok := documentID(filter); ok || id == nil {
+		return update
+	}
+	return mergeUpdate(update, "$setOnInsert", bson.E{Key: "_id", Value: id})
+}
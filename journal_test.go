@@ -0,0 +1,250 @@
+package mongoboiler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type journalSinkFunc func(ctx context.Context, entry JournalEntry) error
+
+func (f journalSinkFunc) Append(ctx context.Context, entry JournalEntry) error { return f(ctx, entry) }
+
+func TestJournal_RecordsSuccessfulWrites(t *testing.T) {
+	var entries []JournalEntry
+	sink := journalSinkFunc(func(ctx context.Context, entry JournalEntry) error {
+		entries = append(entries, entry)
+		return nil
+	})
+	coll := newTestCollection(t, "orders", WithJournal(JournalConfig{Sink: sink}))
+	ctx := context.Background()
+
+	id := primitive.NewObjectID()
+	insert := coll.newOp(OpInsertMany)
+	insert.Documents = []any{bson.D{{Key: "n", Value: 1}}, bson.D{{Key: "_id", Value: 7}, {Key: "n", Value: 2}}}
+	err := coll.run(ctx, insert, func(ctx context.Context, op *Operation) error {
+		op.Result = InsertResult{InsertedID: id, InsertedIDs: []any{id, 7}}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	_ = coll.run(ctx, coll.newOp(OpDeleteMany), func(ctx context.Context, op *Operation) error { return errors.New("boom") })
+	_ = coll.run(ctx, coll.newOp(OpFind), func(ctx context.Context, op *Operation) error { return nil })
+	found := coll.newOp(OpFindOrCreate)
+	found.Documents = []any{bson.D{{Key: "n", Value: 3}}}
+	_ = coll.run(ctx, found, func(ctx context.Context, op *Operation) error {
+		op.Result = UpdateResult{MatchedCount: 1}
+		return nil
+	})
+	upsert := coll.newOp(OpUpdateOne)
+	upsert.Filter = bson.D{{Key: "sku", Value: "a"}}
+	upsert.Update = bson.D{{Key: "$inc", Value: bson.D{{Key: "qty", Value: 1}}}}
+	_ = coll.run(ctx, upsert, func(ctx context.Context, op *Operation) error {
+		op.Result = UpdateResult{UpsertedCount: 1, UpsertedID: id}
+		return nil
+	})
+
+	if len(entries) != 2 {
+		t.Fatalf("expected entries for the insert and the upsert only, got %d", len(entries))
+	}
+	e := entries[0]
+	if e.Operation != OpInsertMany || e.OperationID != insert.ID || e.Collection != "orders" || len(e.Documents) != 2 {
+		t.Fatalf("unexpected insert entry %+v", e)
+	}
+	if got := e.Documents[0].Lookup("_id").ObjectID(); got != id {
+		t.Fatalf("expected the generated _id to be recorded, got %v", got)
+	}
+	if got := e.Documents[1].Lookup("_id").Int32(); got != 7 {
+		t.Fatalf("expected the given _id to be kept, got %v", got)
+	}
+	if entries[1].UpsertedID != id {
+		t.Fatalf("expected the upserted _id to be recorded, got %+v", entries[1])
+	}
+}
+
+func TestJournal_RecordsPartialWrites(t *testing.T) {
+	var entries []JournalEntry
+	sink := journalSinkFunc(func(ctx context.Context, entry JournalEntry) error {
+		entries = append(entries, entry)
+		return nil
+	})
+	logger := &recordingLogger{}
+	coll := newTestCollection(t, "orders", WithJournal(JournalConfig{Sink: sink, Logger: logger}))
+	ctx := context.Background()
+	docs := []any{bson.D{{Key: "_id", Value: 1}}, bson.D{{Key: "_id", Value: 2}}, bson.D{{Key: "_id", Value: 3}}}
+	duplicate := mongo.BulkWriteException{WriteErrors: []mongo.BulkWriteError{{WriteError: mongo.WriteError{Index: 1, Code: 11000}}}}
+
+	for _, unordered := range []bool{false, true} {
+		insert := coll.newOp(OpInsertMany)
+		insert.Documents = docs
+		err := coll.run(ctx, insert, func(ctx context.Context, op *Operation) error {
+			op.unordered = unordered
+			return duplicate
+		})
+		if err == nil {
+			t.Fatalf("expected the insert to fail")
+		}
+	}
+	update := coll.newOp(OpUpdateMany)
+	update.Update = bson.D{{Key: "$set", Value: bson.D{{Key: "archived", Value: true}}}}
+	_ = coll.run(ctx, update, func(ctx context.Context, op *Operation) error {
+		return mongo.CommandError{Code: 50, Name: "MaxTimeMSExpired"}
+	})
+	_ = coll.run(ctx, coll.newOp(OpDeleteMany), func(ctx context.Context, op *Operation) error { return errors.New("boom") })
+	_ = coll.run(ctx, coll.newOp(OpUpdateOne), func(ctx context.Context, op *Operation) error {
+		return mongo.CommandError{Code: 50, Name: "MaxTimeMSExpired"}
+	})
+
+	if len(entries) != 3 {
+		t.Fatalf("expected entries for both inserts and the update many, got %d", len(entries))
+	}
+	if ordered := entries[0].Documents; len(ordered) != 1 || ordered[0].Lookup("_id").Int32() != 1 {
+		t.Fatalf("expected the ordered insert to record the documents before the failed one, got %v", ordered)
+	}
+	if unordered := entries[1].Documents; len(unordered) != 2 || unordered[1].Lookup("_id").Int32() != 3 {
+		t.Fatalf("expected the unordered insert to record all but the failed document, got %v", unordered)
+	}
+	if entries[0].Uncertain || entries[1].Uncertain || !entries[2].Uncertain || entries[2].Operation != OpUpdateMany {
+		t.Fatalf("expected only the update many to be uncertain, got %+v", entries)
+	}
+	if len(logger.lines) != 1 {
+		t.Fatalf("expected the uncertain entry to be logged, got %v", logger.lines)
+	}
+}
+
+func TestJournal_RecordsUpserts(t *testing.T) {
+	var entries []JournalEntry
+	sink := journalSinkFunc(func(ctx context.Context, entry JournalEntry) error {
+		entries = append(entries, entry)
+		return nil
+	})
+	// The matching upserts insert nothing, so only the flag tells them from plain updates.
+	coll := newTestCollection(t, "orders", WithJournal(JournalConfig{Sink: sink}), WithMiddleware(func(next Handler) Handler {
+		return func(ctx context.Context, op *Operation) error {
+			op.Result = UpdateResult{MatchedCount: 1}
+			return nil
+		}
+	}))
+	ctx := context.Background()
+	filter := bson.D{{Key: "sku", Value: "a"}}
+
+	_, _ = coll.UpdateOne(ctx, filter, bson.D{{Key: "$inc", Value: bson.D{{Key: "qty", Value: 1}}}}, options.Update().SetUpsert(true))
+	_, _ = coll.ReplaceOne(ctx, filter, bson.D{{Key: "sku", Value: "a"}}, options.Replace().SetUpsert(true))
+	_, _ = coll.UpdateOne(ctx, filter, bson.D{{Key: "$inc", Value: bson.D{{Key: "qty", Value: 1}}}}, options.Update().SetUpsert(true), options.Update().SetUpsert(false))
+
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 entries, got %d", len(entries))
+	}
+	if !entries[0].Upsert || !entries[1].Upsert {
+		t.Fatalf("expected the upserts to be recorded as such, got %+v", entries[:2])
+	}
+	if entries[2].Upsert {
+		t.Fatalf("expected the last upsert option to win, got %+v", entries[2])
+	}
+}
+
+func TestFileJournal_RoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	journal := NewFileJournal(&buf)
+	ctx := context.Background()
+	want := []JournalEntry{
+		{
+			ID:         primitive.NewObjectID(),
+			Operation:  OpInsertOne,
+			Collection: "orders",
+			Documents:  []bson.Raw{mustRaw(t, bson.D{{Key: "_id", Value: int64(1)}, {Key: "total", Value: primitive.NewDecimal128(0, 995)}})},
+		},
+		{
+			ID:         primitive.NewObjectID(),
+			Operation:  OpUpdateMany,
+			Collection: "orders",
+			Filter:     bson.D{{Key: "status", Value: "open"}},
+			Update:     bson.D{{Key: "$set", Value: bson.D{{Key: "status", Value: "closed"}}}},
+		},
+	}
+	for _, e := range want {
+		if err := journal.Append(ctx, e); err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+	}
+
+	r := NewFileJournalReader(&buf)
+	var first JournalEntry
+	for i := range want {
+		got, err := r.Next(ctx)
+		if err != nil {
+			t.Fatalf("Next failed: %v", err)
+		}
+		if got.ID != want[i].ID || got.Operation != want[i].Operation || len(got.Documents) != len(want[i].Documents) {
+			t.Fatalf("entry %d: got %+v, want %+v", i, got, want[i])
+		}
+		if i == 0 {
+			first = got
+		}
+	}
+	if v := first.Documents[0].Lookup("total"); v.Type != bson.TypeDecimal128 {
+		t.Fatalf("expected the decimal to survive, got %s", v.Type)
+	}
+	if _, err := r.Next(ctx); err != io.EOF {
+		t.Fatalf("expected io.EOF after the last entry, got %v", err)
+	}
+}
+
+type sliceJournalReader []JournalEntry
+
+func (r *sliceJournalReader) Next(ctx context.Context) (JournalEntry, error) {
+	if len(*r) == 0 {
+		return JournalEntry{}, io.EOF
+	}
+	e := (*r)[0]
+	*r = (*r)[1:]
+	return e, nil
+}
+
+func TestJournalReplayer_Replay(t *testing.T) {
+	coll := newTestCollection(t, "orders")
+	entries := func() *sliceJournalReader {
+		return &sliceJournalReader{{Operation: OpInsertOne}, {Operation: OpDeleteOne}, {Operation: OpDrop}}
+	}
+	var applied []OpKind
+	r := coll.db.NewJournalReplayer()
+	r.apply = func(ctx context.Context, entry JournalEntry) error {
+		applied = append(applied, entry.Operation)
+		if entry.Operation == OpDeleteOne {
+			return errors.New("boom")
+		}
+		return nil
+	}
+
+	report, err := r.Replay(context.Background(), entries())
+	if err == nil || report.Applied != 1 || report.Failed != 1 || len(applied) != 2 {
+		t.Fatalf("expected the replay to stop at the failure, got %v, %+v, %v", err, report, applied)
+	}
+
+	applied = nil
+	r.ContinueOnError = true
+	r.Logger = &recordingLogger{}
+	report, err = r.Replay(context.Background(), entries())
+	if err != nil || report.Applied != 2 || report.Failed != 1 || len(applied) != 3 {
+		t.Fatalf("expected the replay to go on after the failure, got %v, %+v, %v", err, report, applied)
+	}
+}
+
+func TestWithUpsertedID(t *testing.T) {
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "qty", Value: 1}}}}
+	got := withUpsertedID(update, bson.D{{Key: "sku", Value: "a"}}, 7)
+	if len(got) != 2 || got[1].Key != "$setOnInsert" {
+		t.Fatalf("expected the _id to be set on insert, got %v", got)
+	}
+	if got := withUpsertedID(update, bson.D{{Key: "_id", Value: 7}}, 7); len(got) != 1 {
+		t.Fatalf("expected the update unchanged when the filter sets the _id, got %v", got)
+	}
+}
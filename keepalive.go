@@ -0,0 +1,89 @@
+package mongoboiler
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DefaultCursorKeepalive is the interval of WithCursorKeepalive when none is given. The server
+// expires sessions idle for 30 minutes by default.
+const DefaultCursorKeepalive = 5 * time.Minute
+
+// WithCursorKeepalive keeps the cursors of FindEach, and so FindMany, FindChan and Export, alive
+// for scans longer than the server's timeouts: the cursor is opened with noCursorTimeout on an
+// explicit session, which is refreshed with refreshSessions every interval until the scan
+// ends. Since MongoDB 4.4.8 noCursorTimeout cursors still die with their session, so both are
+// needed for a slow consumer between batches. Meant for exports and migrations, typically per
+// call with Collection.With:
+//
+//	coll.With(WithCursorKeepalive(0)).Export(ctx, w, nil, FormatBSON)
+//
+// A non-positive interval uses DefaultCursorKeepalive.
+func WithCursorKeepalive(interval time.Duration) Option {
+	return func(s *settings) {
+		if interval <= 0 {
+			interval = DefaultCursorKeepalive
+		}
+		s.cursorKeepalive = interval
+	}
+}
+
+// keepCursorAlive prepares a find on target for WithCursorKeepalive. It returns the context and
+// options to run it with and a function to call once the cursor is closed.
+func (c Collection) keepCursorAlive(ctx context.Context, target *mongo.Collection, opts []*options.FindOptions) (context.Context, []*options.FindOptions, func(), error) {
+	if c.settings == nil || c.settings.cursorKeepalive <= 0 {
+		return ctx, opts, func() {}, nil
+	}
+	opts = append([]*options.FindOptions{options.Find().SetNoCursorTimeout(true)}, opts...)
+
+	session := mongo.SessionFromContext(ctx)
+	end := func() {}
+	if session == nil {
+		var err error
+		if session, err = target.Database().Client().StartSession(); err != nil {
+			return nil, nil, nil, err
+		}
+		ctx = mongo.NewSessionContext(ctx, session)
+		end = func() { session.EndSession(context.Background()) }
+	}
+	admin := target.Database().Client().Database("admin")
+	lsid := session.ID()
+	stop := keepAlive(c.settings.cursorKeepalive, func(ctx context.Context) error {
+		// Not on the session itself, which must not be used concurrently with the cursor.
+		return admin.RunCommand(ctx, bson.D{{Key: "refreshSessions", Value: bson.A{lsid}}}).Err()
+	})
+	return ctx, opts, func() {
+		stop()
+		end()
+	}, nil
+}
+
+// keepAlive calls refresh every interval until the returned function is called. Failed
+// refreshes are retried on the next tick; each gets at most an interval to complete.
+func keepAlive(interval time.Duration, refresh func(ctx context.Context) error) (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				refreshCtx, cancelRefresh := context.WithTimeout(ctx, interval)
+				_ = refresh(refreshCtx)
+				cancelRefresh()
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
@@ -0,0 +1,50 @@
+package mongoboiler
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestKeepAlive_RefreshesUntilStopped(t *testing.T) {
+	var calls int32
+	stop := keepAlive(5*time.Millisecond, func(ctx context.Context) error {
+		atomic.AddInt32(&calls, 1)
+		return nil
+	})
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(&calls) < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	stop()
+	n := atomic.LoadInt32(&calls)
+	if n < 2 {
+		t.Fatalf("expected at least 2 refreshes, got %d", n)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if atomic.LoadInt32(&calls) != n {
+		t.Fatalf("refreshes continued after stop")
+	}
+}
+
+func TestKeepCursorAlive_Disabled(t *testing.T) {
+	coll := newTestCollection(t, "events")
+	ctx := context.Background()
+	opts := []*options.FindOptions{options.Find().SetBatchSize(10)}
+
+	gotCtx, gotOpts, release, err := coll.keepCursorAlive(ctx, coll.collection(), opts)
+	if err != nil || gotCtx != ctx || len(gotOpts) != 1 {
+		t.Fatalf("expected no changes without WithCursorKeepalive, got %d opts, %v", len(gotOpts), err)
+	}
+	release()
+}
+
+func TestWithCursorKeepalive_Default(t *testing.T) {
+	coll := newTestCollection(t, "events").With(WithCursorKeepalive(0))
+	if coll.settings.cursorKeepalive != DefaultCursorKeepalive {
+		t.Fatalf("got interval %v, want %v", coll.settings.cursorKeepalive, DefaultCursorKeepalive)
+	}
+}
@@ -0,0 +1,122 @@
+package mongoboiler
+
+import (
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrResultTooLarge matches every ResultTooLargeError with errors.Is.
+var ErrResultTooLarge = errors.New("mongoboiler: result too large")
+
+// ResultTooLargeError is returned by FindMany for results exceeding the limits set with
+// WithResultLimits.
+type ResultTooLargeError struct {
+	Database   string
+	Collection string
+	// Documents and Bytes are what was read when the limit was exceeded, Limits the limits.
+	Documents int
+	Bytes     int
+	Limits    ResultLimits
+}
+
+func (e *ResultTooLargeError) Error() string {
+	return fmt.Sprintf("mongoboiler: result of %s.%s exceeds %s after %d documents of %d bytes",
+		e.Database, e.Collection, e.Limits, e.Documents, e.Bytes)
+}
+
+// Is makes errors.Is(err, ErrResultTooLarge) true.
+func (e *ResultTooLargeError) Is(target error) bool {
+	return target == ErrResultTooLarge
+}
+
+// Code returns CodeInvalidArgument: the query must be narrowed or paginated.
+func (e *ResultTooLargeError) Code() ErrorCode {
+	return CodeInvalidArgument
+}
+
+// ResultLimits bounds the results of FindMany, see WithResultLimits.
+type ResultLimits struct {
+	// MaxDocuments and MaxBytes are the most documents and BSON bytes a result may have, zero
+	// leaves either unlimited.
+	MaxDocuments int
+	MaxBytes     int
+	// LogOnly logs results exceeding the limits to Logger, the standard logger if nil, and
+	// returns them in full instead of failing. Use it to find offending queries before
+	// enforcing limits.
+	LogOnly bool
+	Logger  Logger
+}
+
+func (l ResultLimits) String() string {
+	switch {
+	case l.MaxDocuments > 0 && l.MaxBytes > 0:
+		return fmt.Sprintf("%d documents or %d bytes", l.MaxDocuments, l.MaxBytes)
+	case l.MaxDocuments > 0:
+		return fmt.Sprintf("%d documents", l.MaxDocuments)
+	case l.MaxBytes > 0:
+		return fmt.Sprintf("%d bytes", l.MaxBytes)
+	}
+	return "unlimited"
+}
+
+// WithResultLimits makes FindMany fail with a *ResultTooLargeError, once it read more documents
+// or bytes than limits allow, instead of materializing unbounded results. When MaxDocuments is
+// set and the call sets no limit of its own, the query is limited to one document more than
+// allowed, so the server stops early. FindEach and Aggregate are not limited.
+func WithResultLimits(limits ResultLimits) Option {
+	return func(s *settings) {
+		s.resultLimits = &limits
+	}
+}
+
+// resultGuard counts the documents of a FindMany result against the collection's limits.
+type resultGuard struct {
+	limits    ResultLimits
+	coll      Collection
+	documents int
+	bytes     int
+	logged    bool
+}
+
+// newResultGuard returns the guard for a FindMany with opts and the options to run it with, the
+// guard is nil without limits.
+func (c Collection) newResultGuard(opts []*options.FindOptions) (*resultGuard, []*options.FindOptions) {
+	if c.settings == nil || c.settings.resultLimits == nil {
+		return nil, opts
+	}
+	limits := *c.settings.resultLimits
+	if limits.MaxDocuments > 0 && !limits.LogOnly {
+		opts = append([]*options.FindOptions{options.Find().SetLimit(int64(limits.MaxDocuments) + 1)}, opts...)
+	}
+	return &resultGuard{limits: limits, coll: c}, opts
+}
+
+// add counts the document of dec, failing once the limits are exceeded.
+func (g *resultGuard) add(dec Decoder) error {
+	g.documents++
+	if raw, ok := dec.(rawDecoder); ok {
+		g.bytes += len(raw.Raw())
+	}
+	if (g.limits.MaxDocuments <= 0 || g.documents <= g.limits.MaxDocuments) &&
+		(g.limits.MaxBytes <= 0 || g.bytes <= g.limits.MaxBytes) {
+		return nil
+	}
+	err := &ResultTooLargeError{
+		Database:   g.coll.db.databaseName(),
+		Collection: g.coll.collectionName(),
+		Documents:  g.documents,
+		Bytes:      g.bytes,
+		Limits:     g.limits,
+	}
+	if !g.limits.LogOnly {
+		return err
+	}
+	if !g.logged {
+		g.logged = true
+		loggerOrDefault(g.limits.Logger).Printf("mongoboiler: result of %s.%s exceeds the limit of %s, returning it in full",
+			err.Database, err.Collection, err.Limits)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package mongoboiler
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// primeGuardedFind caches docs as the result of FindMany on coll with filter, with the options
+// its result limits add.
+func primeGuardedFind(t *testing.T, coll *Collection, filter bson.D, docs ...bson.D) {
+	t.Helper()
+	op := coll.newOp(OpFind)
+	op.Filter = filter
+	_, opts := coll.newResultGuard(nil)
+	key, ok := coll.readCache().key(context.Background(), op, opts)
+	if !ok {
+		t.Fatalf("expected the read to be cacheable")
+	}
+	raws := make([]bson.Raw, len(docs))
+	for i, doc := range docs {
+		raws[i] = mustRaw(t, doc)
+	}
+	coll.readCache().set(context.Background(), key, raws)
+}
+
+func TestWithResultLimits(t *testing.T) {
+	docs := []bson.D{
+		{{Key: "name", Value: "a"}, {Key: "qty", Value: 1}},
+		{{Key: "name", Value: "b"}, {Key: "qty", Value: 2}},
+		{{Key: "name", Value: "c"}, {Key: "qty", Value: 3}},
+	}
+	size := len(mustRaw(t, docs[0]))
+	for _, tc := range []struct {
+		name   string
+		limits ResultLimits
+		err    bool
+	}{
+		{"within", ResultLimits{MaxDocuments: 3, MaxBytes: 3 * size}, false},
+		{"documents", ResultLimits{MaxDocuments: 2}, true},
+		{"bytes", ResultLimits{MaxBytes: 2*size + 1}, true},
+	} {
+		coll := newTestCollection(t, "items", WithCache(NewLRUCache(10), 0), WithResultLimits(tc.limits))
+		primeGuardedFind(t, coll, bson.D{}, docs...)
+		var items []decodeItem
+		err := coll.FindMany(context.Background(), bson.D{}, &items)
+		var tooLarge *ResultTooLargeError
+		if !tc.err {
+			if err != nil || len(items) != 3 {
+				t.Fatalf("%s: got %d items, %v", tc.name, len(items), err)
+			}
+			continue
+		}
+		if !errors.Is(err, ErrResultTooLarge) || !errors.As(err, &tooLarge) || tooLarge.Documents != 3 {
+			t.Fatalf("%s: got %v, want a ResultTooLargeError after 3 documents", tc.name, err)
+		}
+	}
+}
+
+func TestWithResultLimits_LogOnly(t *testing.T) {
+	logger := &recordingLogger{}
+	coll := newTestCollection(t, "items", WithCache(NewLRUCache(10), 0),
+		WithResultLimits(ResultLimits{MaxDocuments: 1, LogOnly: true, Logger: logger}))
+	primeFind(t, coll, bson.D{}, bson.D{{Key: "name", Value: "a"}}, bson.D{{Key: "name", Value: "b"}}, bson.D{{Key: "name", Value: "c"}})
+
+	var items []decodeItem
+	if err := coll.FindMany(context.Background(), bson.D{}, &items); err != nil || len(items) != 3 {
+		t.Fatalf("got %d items, %v; want all of them", len(items), err)
+	}
+	if len(logger.lines) != 1 || !strings.Contains(logger.lines[0], "exceeds the limit") {
+		t.Fatalf("logged %q", logger.lines)
+	}
+}
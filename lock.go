@@ -0,0 +1,166 @@
+package mongoboiler
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DefaultLocksCollection is the collection Lock keeps its leases in.
+const DefaultLocksCollection = "locks"
+
+var (
+	// ErrLocked is returned by TryLock when another holder has the lock.
+	ErrLocked = errors.New("mongoboiler: lock is held")
+	// ErrLockLost is returned by Refresh and Unlock when the lease expired, after which another
+	// holder may have acquired the lock.
+	ErrLockLost = errors.New("mongoboiler: lock lease expired")
+)
+
+// lockRetryInterval is the wait between attempts of Lock.
+const lockRetryInterval = 250 * time.Millisecond
+
+// WithLocksCollection changes the collection Lock keeps its leases in.
+func WithLocksCollection(name string) Option {
+	return func(s *settings) {
+		s.locksCollection = name
+	}
+}
+
+// Lock is a lease on a named distributed lock, see DB.Lock.
+type Lock interface {
+	Name() string
+	// Token is the fencing token of this lease: tokens of later leases of the lock are
+	// greater, so a resource can reject writes of a holder whose lease expired unnoticed.
+	Token() int64
+	// ExpiresAt is when the lease expires unless refreshed.
+	ExpiresAt() time.Time
+	// Refresh extends the lease by the lock's ttl.
+	Refresh(ctx context.Context) error
+	// Unlock releases the lock.
+	Unlock(ctx context.Context) error
+}
+
+type leaseLock struct {
+	coll      *Collection
+	name      string
+	token     int64
+	ttl       time.Duration
+	expiresAt time.Time
+}
+
+func (db *DB) locks() *Collection {
+	name := DefaultLocksCollection
+	if db.settings != nil && db.settings.locksCollection != "" {
+		name = db.settings.locksCollection
+	}
+	return db.NewCollection(name)
+}
+
+// EnsureLockIndexes creates the TTL index removing expired leases from the locks collection.
+// Locks work without it, it only keeps the collection small.
+func (db *DB) EnsureLockIndexes(ctx context.Context) error {
+	return db.locks().EnableTTL(ctx, "expiresAt", 0)
+}
+
+// Lock acquires the named lock for ttl, waiting while another holder has it until ctx is done.
+// The lease must be renewed with Refresh before it expires. Expiry is judged by the clocks of
+// the clients, so ttl should be well above their skew.
+func (db *DB) Lock(ctx context.Context, name string, ttl time.Duration) (Lock, error) {
+	for {
+		l, err := db.TryLock(ctx, name, ttl)
+		if !errors.Is(err, ErrLocked) {
+			return l, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(lockRetryInterval):
+		}
+	}
+}
+
+// TryLock acquires the named lock for ttl like Lock, but returns ErrLocked at once when another
+// holder has it.
+func (db *DB) TryLock(ctx context.Context, name string, ttl time.Duration) (Lock, error) {
+	// Tokens come from a sequence rather than the lock document, so they stay monotonic when
+	// the TTL index removes it.
+	token, err := db.NextSequence(ctx, "lock:"+name)
+	if err != nil {
+		return nil, err
+	}
+	return db.acquire(ctx, name, ttl, token)
+}
+
+// acquire takes the named lock for ttl with the fencing token unless another lease holds it.
+func (db *DB) acquire(ctx context.Context, name string, ttl time.Duration, token int64) (Lock, error) {
+	coll := db.locks()
+	now := time.Now().UTC()
+	expiresAt := now.Add(ttl).Truncate(time.Millisecond)
+
+	op := coll.newOp(OpUpdateOne)
+	op.needsEffect, op.Upsert = true, true
+	op.Filter = bson.D{{Key: "_id", Value: name}, {Key: "expiresAt", Value: bson.D{{Key: "$lte", Value: now}}}}
+	op.Update = bson.D{{Key: "$set", Value: bson.D{
+		{Key: "token", Value: token},
+		{Key: "expiresAt", Value: expiresAt},
+		{Key: "acquiredAt", Value: now},
+	}}}
+	err := coll.run(ctx, op, func(ctx context.Context, op *Operation) error {
+		opts := options.Update().SetUpsert(true)
+		if op.Comment != "" {
+			opts.SetComment(op.Comment)
+		}
+		_, err := op.Target.UpdateOne(ctx, op.Filter, op.Update, opts)
+		return err
+	})
+	if errors.Is(err, ErrDuplicateKey) {
+		// The document exists and its lease has not expired, so the upsert tried to insert.
+		return nil, ErrLocked
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &leaseLock{coll: coll, name: name, token: token, ttl: ttl, expiresAt: expiresAt}, nil
+}
+
+func (l *leaseLock) Name() string         { return l.name }
+func (l *leaseLock) Token() int64         { return l.token }
+func (l *leaseLock) ExpiresAt() time.Time { return l.expiresAt }
+
+func (l *leaseLock) Refresh(ctx context.Context) error {
+	now := time.Now().UTC()
+	expiresAt := now.Add(l.ttl).Truncate(time.Millisecond)
+	res, err := l.coll.UpdateOne(ctx, l.filter(now), bson.D{{Key: "$set", Value: bson.D{{Key: "expiresAt", Value: expiresAt}}}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrLockLost
+	}
+	l.expiresAt = expiresAt
+	return nil
+}
+
+func (l *leaseLock) Unlock(ctx context.Context) error {
+	res, err := l.coll.DeleteOne(ctx, l.filter(time.Now().UTC()))
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrLockLost
+	}
+	return nil
+}
+
+// filter matches the lock document while this lease holds it.
+func (l *leaseLock) filter(now time.Time) bson.D {
+	return bson.D{
+		{Key: "_id", Value: l.name},
+		{Key: "token", Value: l.token},
+		{Key: "expiresAt", Value: bson.D{{Key: "$gt", Value: now}}},
+	}
+}
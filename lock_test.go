@@ -0,0 +1,150 @@
+package mongoboiler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestLock_Collection(t *testing.T) {
+	db := newTestCollection(t, "unused").db
+	if got := db.locks().name; got != DefaultLocksCollection {
+		t.Fatalf("locks collection = %s, want %s", got, DefaultLocksCollection)
+	}
+	if got := db.Database("other").locks().name; got != DefaultLocksCollection {
+		t.Fatalf("locks collection = %s", got)
+	}
+	custom := newTestCollection(t, "unused", WithLocksCollection("mutexes")).db
+	if got := custom.locks().name; got != "mutexes" {
+		t.Fatalf("locks collection = %s, want mutexes", got)
+	}
+}
+
+func TestLeaseLock_Filter(t *testing.T) {
+	now := time.Now()
+	l := &leaseLock{name: "nightly-report", token: 7}
+	filter := l.filter(now).Map()
+	if filter["_id"] != "nightly-report" || filter["token"] != int64(7) {
+		t.Fatalf("lease operations must be fenced by name and token, got %v", filter)
+	}
+}
+
+// lockDoc is a lock document of fakeLocks.
+type lockDoc struct {
+	Token     int64     `bson:"token"`
+	ExpiresAt time.Time `bson:"expiresAt"`
+}
+
+// fakeLocks keeps lock documents in memory, by name.
+type fakeLocks struct {
+	t    *testing.T
+	docs map[any]*lockDoc
+}
+
+// held returns the document of the lease filter matches, nil if none does.
+func (f *fakeLocks) held(filter bson.D) *lockDoc {
+	m := filter.Map()
+	doc, ok := f.docs[m["_id"]]
+	if !ok || doc.Token != m["token"] || !doc.ExpiresAt.After(m["expiresAt"].(bson.D).Map()["$gt"].(time.Time)) {
+		return nil
+	}
+	return doc
+}
+
+func (f *fakeLocks) middleware(next Handler) Handler {
+	return func(ctx context.Context, op *Operation) error {
+		switch {
+		case op.needsEffect:
+			// The upsert matches expired leases, and fails on the _id of held ones.
+			m := op.Filter.Map()
+			doc, ok := f.docs[m["_id"]]
+			if ok && doc.ExpiresAt.After(m["expiresAt"].(bson.D).Map()["$lte"].(time.Time)) {
+				return mongo.WriteException{WriteErrors: []mongo.WriteError{{Code: 11000, Message: "E11000 duplicate key error"}}}
+			}
+			doc = &lockDoc{}
+			setFields(f.t, doc, op.Update)
+			f.docs[m["_id"]] = doc
+		case op.Kind == OpUpdateOne:
+			var res UpdateResult
+			if doc := f.held(op.Filter); doc != nil {
+				setFields(f.t, doc, op.Update)
+				res.MatchedCount = 1
+			}
+			op.Result = res
+		case op.Kind == OpDeleteOne:
+			var res DeleteResult
+			if doc := f.held(op.Filter); doc != nil {
+				delete(f.docs, op.Filter.Map()["_id"])
+				res.DeletedCount = 1
+			}
+			op.Result = res
+		default:
+			f.t.Fatalf("unexpected %s", op.Kind)
+		}
+		return nil
+	}
+}
+
+func TestTryLock_FencingToken(t *testing.T) {
+	var ops []*Operation
+	coll := recordingCollection(t, &ops)
+	l, err := coll.db.TryLock(context.Background(), "nightly-report", time.Minute)
+	if err != nil {
+		t.Fatalf("TryLock failed: %v", err)
+	}
+	if len(ops) != 2 || ops[0].Collection != DefaultCountersCollection || ops[0].Filter[0].Value != "lock:nightly-report" {
+		t.Fatalf("expected the token to come from the sequence of the lock, got %+v", ops)
+	}
+	set := ops[1].Update.Map()["$set"].(bson.D).Map()
+	if ops[1].Collection != DefaultLocksCollection || !ops[1].Upsert || set["token"] != l.Token() || set["expiresAt"] != l.ExpiresAt() {
+		t.Fatalf("expected the lease to store the token and expiry, got %+v", ops[1])
+	}
+}
+
+func TestLock_Lease(t *testing.T) {
+	fake := &fakeLocks{t: t, docs: map[any]*lockDoc{}}
+	db := newTestCollection(t, "unused", WithMiddleware(fake.middleware)).db
+	ctx := context.Background()
+
+	first, err := db.acquire(ctx, "nightly-report", time.Minute, 1)
+	if err != nil {
+		t.Fatalf("acquire failed: %v", err)
+	}
+	if _, err := db.acquire(ctx, "nightly-report", time.Minute, 2); !errors.Is(err, ErrLocked) {
+		t.Fatalf("expected the held lock to be refused, got %v", err)
+	}
+	fake.docs["nightly-report"].ExpiresAt = time.Now().Add(time.Second)
+	if err := first.Refresh(ctx); err != nil || !first.ExpiresAt().After(time.Now().Add(50*time.Second)) {
+		t.Fatalf("expected the lease to be extended, got %s, %v", first.ExpiresAt(), err)
+	}
+	if got := fake.docs["nightly-report"].ExpiresAt; !got.Equal(first.ExpiresAt()) {
+		t.Fatalf("expected the stored expiry to be extended, got %s", got)
+	}
+
+	// The lease expires unnoticed, another holder takes the lock with a greater token.
+	fake.docs["nightly-report"].ExpiresAt = time.Now().Add(-time.Second)
+	second, err := db.acquire(ctx, "nightly-report", time.Minute, 3)
+	if err != nil || second.Token() <= first.Token() {
+		t.Fatalf("expected the expired lock to be taken with a greater token, got %v, %v", second, err)
+	}
+	if err := first.Refresh(ctx); !errors.Is(err, ErrLockLost) {
+		t.Fatalf("expected Refresh of the expired lease to fail, got %v", err)
+	}
+	if err := first.Unlock(ctx); !errors.Is(err, ErrLockLost) {
+		t.Fatalf("expected Unlock of the expired lease to fail, got %v", err)
+	}
+	if fake.docs["nightly-report"].Token != 3 {
+		t.Fatalf("expected the expired lease to leave the new one alone, got %+v", fake.docs["nightly-report"])
+	}
+
+	if err := second.Unlock(ctx); err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+	if _, err := db.acquire(ctx, "nightly-report", time.Minute, 4); err != nil {
+		t.Fatalf("expected the released lock to be free, got %v", err)
+	}
+}
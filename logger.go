@@ -0,0 +1,15 @@
+package mongoboiler
+
+import "log"
+
+// Logger is the logging interface used by the wrapper. *log.Logger satisfies it.
+type Logger interface {
+	Printf(format string, v ...any)
+}
+
+func loggerOrDefault(l Logger) Logger {
+	if l == nil {
+		return log.Default()
+	}
+	return l
+}
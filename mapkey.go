@@ -0,0 +1,114 @@
+package mongoboiler
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+// mapKeyEscaper escapes the characters of map keys that have a meaning in field paths.
+var mapKeyEscaper = strings.NewReplacer("%", "%25", ".", "%2E")
+
+// mapKeyUnescaper reverses mapKeyEscaper and the escaping of a leading $.
+var mapKeyUnescaper = strings.NewReplacer("%2E", ".", "%24", "$", "%25", "%")
+
+// EscapeMapKey escapes key for use as a field name of a dynamic subdocument, such as the locale
+// of a translations map or a user supplied attribute name. Dots, which separate the fields of a
+// path, a leading $, which starts an operator, and the escape character % are percent-encoded:
+// "en.US" is stored as "en%2EUS". UnescapeMapKey reverses it.
+func EscapeMapKey(key string) string {
+	escaped := mapKeyEscaper.Replace(key)
+	if strings.HasPrefix(escaped, "$") {
+		escaped = "%24" + escaped[1:]
+	}
+	return escaped
+}
+
+// UnescapeMapKey returns the key EscapeMapKey escaped.
+func UnescapeMapKey(escaped string) string {
+	return mapKeyUnescaper.Replace(escaped)
+}
+
+// MapKeyPath returns the dotted path of key in the dynamic subdocument field:
+//
+//	mongoboiler.MapKeyPath("translations", "en.US") // "translations.en%2EUS"
+func MapKeyPath(field, key string) string {
+	return field + "." + EscapeMapKey(key)
+}
+
+// MapKeyFilter returns the filter condition of key in the dynamic subdocument field, a value to
+// match or an operator document:
+//
+//	filter := bson.D{mongoboiler.MapKeyFilter("translations", locale, bson.D{{Key: "$exists", Value: true}})}
+func MapKeyFilter(field, key string, cond any) bson.E {
+	return bson.E{Key: MapKeyPath(field, key), Value: cond}
+}
+
+// SetMapKey returns the update setting key of the dynamic subdocument field to value, leaving
+// its other keys as they are:
+//
+//	coll.UpdateOne(ctx, filter, mongoboiler.SetMapKey("translations", "de", "Hallo"))
+func SetMapKey(field, key string, value any) bson.D {
+	return bson.D{{Key: "$set", Value: bson.D{{Key: MapKeyPath(field, key), Value: value}}}}
+}
+
+// UnsetMapKey returns the update removing key from the dynamic subdocument field.
+func UnsetMapKey(field, key string) bson.D {
+	return bson.D{{Key: "$unset", Value: bson.D{{Key: MapKeyPath(field, key), Value: ""}}}}
+}
+
+// DynamicMap is a map field stored as a subdocument with keys escaped by EscapeMapKey, so any
+// key can be stored and updated with SetMapKey and UnsetMapKey:
+//
+//	type Product struct {
+//		Translations mongoboiler.DynamicMap[string] `bson:"translations"`
+//	}
+//
+// Keys are stored sorted. A nil map is stored as null and null decodes to a nil map.
+type DynamicMap[T any] map[string]T
+
+// MarshalBSONValue marshals the map as a subdocument with escaped keys.
+func (m DynamicMap[T]) MarshalBSONValue() (bsontype.Type, []byte, error) {
+	if m == nil {
+		return bson.TypeNull, nil, nil
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	doc := make(bson.D, len(keys))
+	for i, k := range keys {
+		doc[i] = bson.E{Key: EscapeMapKey(k), Value: m[k]}
+	}
+	return bson.MarshalValue(doc)
+}
+
+// UnmarshalBSONValue decodes a subdocument, unescaping its keys.
+func (m *DynamicMap[T]) UnmarshalBSONValue(t bsontype.Type, data []byte) error {
+	switch t {
+	case bson.TypeNull:
+		*m = nil
+		return nil
+	case bson.TypeEmbeddedDocument:
+	default:
+		return fmt.Errorf("mongoboiler: cannot decode %s into a DynamicMap", t)
+	}
+	elems, err := bson.Raw(data).Elements()
+	if err != nil {
+		return err
+	}
+	decoded := make(DynamicMap[T], len(elems))
+	for _, e := range elems {
+		var v T
+		if err := e.Value().Unmarshal(&v); err != nil {
+			return fmt.Errorf("mongoboiler: decoding map key %s: %w", UnescapeMapKey(e.Key()), err)
+		}
+		decoded[UnescapeMapKey(e.Key())] = v
+	}
+	*m = decoded
+	return nil
+}
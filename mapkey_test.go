@@ -0,0 +1,66 @@
+package mongoboiler
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestEscapeMapKey(t *testing.T) {
+	for key, want := range map[string]string{
+		"de":     "de",
+		"en.US":  "en%2EUS",
+		"$where": "%24where",
+		"a$b":    "a$b",
+		"100%":   "100%25",
+		"%24":    "%2524",
+		"%2E.":   "%252E%2E",
+	} {
+		if got := EscapeMapKey(key); got != want {
+			t.Errorf("EscapeMapKey(%q) = %q, want %q", key, got, want)
+		}
+		if got := UnescapeMapKey(want); got != key {
+			t.Errorf("UnescapeMapKey(%q) = %q, want %q", want, got, key)
+		}
+	}
+}
+
+func TestSetMapKey(t *testing.T) {
+	want := bson.D{{Key: "$set", Value: bson.D{{Key: "translations.en%2EUS", Value: "Hi"}}}}
+	if got := SetMapKey("translations", "en.US", "Hi"); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	want = bson.D{{Key: "$unset", Value: bson.D{{Key: "translations.%24x", Value: ""}}}}
+	if got := UnsetMapKey("translations", "$x"); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestDynamicMap(t *testing.T) {
+	type product struct {
+		Translations DynamicMap[string] `bson:"translations"`
+	}
+	in := product{Translations: DynamicMap[string]{"en.US": "Hi", "de": "Hallo"}}
+	raw, err := bson.Marshal(in)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	keys, _ := bson.Raw(raw).Lookup("translations").Document().Elements()
+	if len(keys) != 2 || keys[0].Key() != "de" || keys[1].Key() != "en%2EUS" {
+		t.Fatalf("stored %v, want sorted escaped keys", raw)
+	}
+	var out product
+	if err := bson.Unmarshal(raw, &out); err != nil || !reflect.DeepEqual(out, in) {
+		t.Fatalf("decoded %v, %v; want %v", out, err, in)
+	}
+
+	raw, _ = bson.Marshal(product{})
+	out.Translations = DynamicMap[string]{"x": "y"}
+	if err := bson.Unmarshal(raw, &out); err != nil || out.Translations != nil {
+		t.Fatalf("decoded %v, %v; want a nil map from null", out, err)
+	}
+	if err := bson.Unmarshal(mustRaw(t, bson.D{{Key: "translations", Value: "de"}}), &out); err == nil {
+		t.Fatalf("expected an error decoding a string")
+	}
+}
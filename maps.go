@@ -0,0 +1,150 @@
+package mongoboiler
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MapDecoding configures how FindOneMap and FindManyMaps convert BSON values, see
+// WithMapDecoding. Embedded documents always become map[string]any, arrays []any and dates
+// time.Time in UTC; the zero value leaves other values as the driver decodes them into
+// interfaces.
+type MapDecoding struct {
+	// ObjectIDAsHex converts ObjectIDs to their hex strings.
+	ObjectIDAsHex bool
+	// Decimal128AsString converts Decimal128 values to their decimal strings, which JSON
+	// encodes without losing precision.
+	Decimal128AsString bool
+	// Int64AsString converts 64-bit integers to decimal strings, for JavaScript clients that
+	// lose precision above 2^53.
+	Int64AsString bool
+	// BinaryAsBytes converts binary values of any subtype to []byte.
+	BinaryAsBytes bool
+}
+
+func (m MapDecoding) String() string {
+	var on []string
+	for _, o := range []struct {
+		name string
+		set  bool
+	}{
+		{"objectIDHex", m.ObjectIDAsHex},
+		{"decimal128String", m.Decimal128AsString},
+		{"int64String", m.Int64AsString},
+		{"binaryBytes", m.BinaryAsBytes},
+	} {
+		if o.set {
+			on = append(on, o.name)
+		}
+	}
+	return "[" + strings.Join(on, " ") + "]"
+}
+
+// WithMapDecoding sets how FindOneMap and FindManyMaps convert values, e.g. for API gateways
+// returning documents as JSON without intermediate structs:
+//
+//	db := mongoboiler.New(client, "shop", mongoboiler.WithMapDecoding(mongoboiler.MapDecoding{
+//		ObjectIDAsHex: true, Decimal128AsString: true,
+//	}))
+func WithMapDecoding(m MapDecoding) Option {
+	return func(s *settings) {
+		s.mapDecoding = m
+	}
+}
+
+// FindOneMap is FindOne returning the document as a map converted as set with WithMapDecoding.
+func (c Collection) FindOneMap(ctx context.Context, filter bson.D, opts ...*options.FindOneOptions) (map[string]any, error) {
+	var raw bson.Raw
+	if err := c.FindOne(ctx, filter, &raw, opts...); err != nil {
+		return nil, err
+	}
+	return c.mapDecoding().document(raw)
+}
+
+// FindManyMaps is FindMany returning the documents as maps converted as set with
+// WithMapDecoding.
+func (c Collection) FindManyMaps(ctx context.Context, filter bson.D, opts ...*options.FindOptions) ([]map[string]any, error) {
+	var raws []bson.Raw
+	if err := c.FindMany(ctx, filter, &raws, opts...); err != nil {
+		return nil, err
+	}
+	m := c.mapDecoding()
+	docs := make([]map[string]any, len(raws))
+	for i, raw := range raws {
+		var err error
+		if docs[i], err = m.document(raw); err != nil {
+			return nil, err
+		}
+	}
+	return docs, nil
+}
+
+func (c Collection) mapDecoding() MapDecoding {
+	if c.settings == nil {
+		return MapDecoding{}
+	}
+	return c.settings.mapDecoding
+}
+
+// document converts raw to a map.
+func (m MapDecoding) document(raw bson.Raw) (map[string]any, error) {
+	elems, err := raw.Elements()
+	if err != nil {
+		return nil, err
+	}
+	doc := make(map[string]any, len(elems))
+	for _, e := range elems {
+		if doc[e.Key()], err = m.value(e.Value()); err != nil {
+			return nil, err
+		}
+	}
+	return doc, nil
+}
+
+// value converts v to the Go value it is returned as.
+func (m MapDecoding) value(v bson.RawValue) (any, error) {
+	switch v.Type {
+	case bson.TypeEmbeddedDocument:
+		return m.document(v.Document())
+	case bson.TypeArray:
+		values, err := v.Array().Values()
+		if err != nil {
+			return nil, err
+		}
+		arr := make([]any, len(values))
+		for i, item := range values {
+			if arr[i], err = m.value(item); err != nil {
+				return nil, err
+			}
+		}
+		return arr, nil
+	case bson.TypeDateTime:
+		return v.Time().UTC(), nil
+	case bson.TypeObjectID:
+		if m.ObjectIDAsHex {
+			return v.ObjectID().Hex(), nil
+		}
+	case bson.TypeDecimal128:
+		if m.Decimal128AsString {
+			return v.Decimal128().String(), nil
+		}
+	case bson.TypeInt64:
+		if m.Int64AsString {
+			return strconv.FormatInt(v.Int64(), 10), nil
+		}
+	case bson.TypeBinary:
+		if m.BinaryAsBytes {
+			_, data := v.Binary()
+			return data, nil
+		}
+	case bson.TypeNull, bson.TypeUndefined:
+		return nil, nil
+	}
+	var out any
+	err := v.Unmarshal(&out)
+	return out, err
+}
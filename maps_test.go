@@ -0,0 +1,64 @@
+package mongoboiler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestFindOneMap(t *testing.T) {
+	id := primitive.NewObjectID()
+	price, _ := primitive.ParseDecimal128("19.99")
+	at := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	doc := bson.D{
+		{Key: "_id", Value: id},
+		{Key: "price", Value: price},
+		{Key: "views", Value: int64(1 << 60)},
+		{Key: "at", Value: at},
+		{Key: "tags", Value: bson.A{"a", bson.D{{Key: "at", Value: at}}}},
+		{Key: "owner", Value: bson.D{{Key: "id", Value: id}}},
+		{Key: "note", Value: nil},
+	}
+	filter := bson.D{{Key: "_id", Value: id}}
+
+	coll := newTestCollection(t, "items", WithCache(NewLRUCache(10), 0))
+	primeFindOne(t, coll, filter, doc)
+	m, err := coll.FindOneMap(context.Background(), filter)
+	if err != nil {
+		t.Fatalf("FindOneMap failed: %v", err)
+	}
+	if m["_id"] != id || m["price"] != price || m["views"] != int64(1<<60) || m["note"] != nil {
+		t.Fatalf("default conversion gave %v", m)
+	}
+	if got, ok := m["at"].(time.Time); !ok || !got.Equal(at) {
+		t.Fatalf("date converted to %T %v", m["at"], m["at"])
+	}
+	nested, ok := m["tags"].([]any)[1].(map[string]any)
+	if !ok || !nested["at"].(time.Time).Equal(at) {
+		t.Fatalf("nested document converted to %T", m["tags"].([]any)[1])
+	}
+
+	coll = newTestCollection(t, "items", WithCache(NewLRUCache(10), 0),
+		WithMapDecoding(MapDecoding{ObjectIDAsHex: true, Decimal128AsString: true, Int64AsString: true}))
+	primeFindOne(t, coll, filter, doc)
+	m, err = coll.FindOneMap(context.Background(), filter)
+	if err != nil {
+		t.Fatalf("FindOneMap failed: %v", err)
+	}
+	if m["_id"] != id.Hex() || m["price"] != "19.99" || m["views"] != "1152921504606846976" ||
+		m["owner"].(map[string]any)["id"] != id.Hex() {
+		t.Fatalf("configured conversion gave %v", m)
+	}
+}
+
+func TestFindManyMaps(t *testing.T) {
+	coll := newTestCollection(t, "items", WithCache(NewLRUCache(10), 0))
+	primeFind(t, coll, bson.D{}, bson.D{{Key: "name", Value: "a"}}, bson.D{{Key: "name", Value: "b"}})
+	docs, err := coll.FindManyMaps(context.Background(), bson.D{})
+	if err != nil || len(docs) != 2 || docs[1]["name"] != "b" {
+		t.Fatalf("FindManyMaps = %v, %v", docs, err)
+	}
+}
@@ -0,0 +1,257 @@
+package mongoboiler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Fields of the measurements stored by Metrics.
+const (
+	metricsTimeField  = "ts"
+	metricsMetaField  = "meta"
+	metricsValueField = "value"
+)
+
+// TimeUnit is the unit of the intervals of a RollupQuery, as taken by $dateTrunc.
+type TimeUnit string
+
+// Time units.
+const (
+	UnitMillisecond TimeUnit = "millisecond"
+	UnitSecond      TimeUnit = "second"
+	UnitMinute      TimeUnit = "minute"
+	UnitHour        TimeUnit = "hour"
+	UnitDay         TimeUnit = "day"
+	UnitWeek        TimeUnit = "week"
+	UnitMonth       TimeUnit = "month"
+	UnitQuarter     TimeUnit = "quarter"
+	UnitYear        TimeUnit = "year"
+)
+
+// ErrInvalidTag is returned for tag names that cannot be stored as field names.
+var ErrInvalidTag = errors.New("mongoboiler: tag names must be non-empty and contain no '.' or '$'")
+
+// Metrics records measurements in a time series collection and rolls them up per interval,
+// see DB.Metrics. Measurements are stored as
+//
+//	{ts: <time>, meta: {measurement: <name>, tags: {<tag>: <value>}}, value: <float>}
+//
+// so the measurement and tags form the metaField, which the server buckets by.
+type Metrics struct {
+	coll *Collection
+}
+
+// Metric is a measurement recorded with Metrics.
+type Metric struct {
+	Measurement string
+	Tags        map[string]string
+	Value       float64
+	// Time is when the value was measured, now if zero.
+	Time time.Time
+}
+
+type metricDocument struct {
+	Time  time.Time  `bson:"ts"`
+	Meta  metricMeta `bson:"meta"`
+	Value float64    `bson:"value"`
+}
+
+type metricMeta struct {
+	Measurement string            `bson:"measurement"`
+	Tags        map[string]string `bson:"tags,omitempty"`
+}
+
+// Metrics returns the measurements stored in the named collection. Create it with Create, or
+// with CreateCollection and TimeSeries("ts", "meta", granularity).
+func (db *DB) Metrics(name string) *Metrics {
+	return &Metrics{db.NewCollection(name)}
+}
+
+// Collection returns the collection the measurements are stored in.
+func (m *Metrics) Collection() *Collection {
+	return m.coll
+}
+
+// Create creates the time series collection, granularity is one of the Granularity constants
+// or empty for the server's default. Measurements expire after expireAfter unless zero.
+func (m *Metrics) Create(ctx context.Context, granularity string, expireAfter time.Duration) error {
+	opts := []CollectionOption{TimeSeries(metricsTimeField, metricsMetaField, granularity)}
+	if expireAfter > 0 {
+		opts = append(opts, ExpireAfter(expireAfter))
+	}
+	_, err := m.coll.db.CreateCollection(ctx, m.coll.name, opts...)
+	return err
+}
+
+// Record records value for measurement with tags, measured at ts or now if ts is zero.
+func (m *Metrics) Record(ctx context.Context, measurement string, tags map[string]string, value float64, ts time.Time) error {
+	return m.RecordMany(ctx, []Metric{{Measurement: measurement, Tags: tags, Value: value, Time: ts}})
+}
+
+// RecordMany records metrics with one insert.
+func (m *Metrics) RecordMany(ctx context.Context, metrics []Metric) error {
+	if len(metrics) == 0 {
+		return nil
+	}
+	now := time.Now().UTC()
+	docs := make([]any, len(metrics))
+	for i, metric := range metrics {
+		if err := validTags(metric.Tags); err != nil {
+			return err
+		}
+		ts := metric.Time
+		if ts.IsZero() {
+			ts = now
+		}
+		docs[i] = metricDocument{
+			Time:  ts,
+			Meta:  metricMeta{Measurement: metric.Measurement, Tags: metric.Tags},
+			Value: metric.Value,
+		}
+	}
+	_, err := m.coll.InsertMany(ctx, docs)
+	return err
+}
+
+func validTags(tags map[string]string) error {
+	for name := range tags {
+		if err := validTag(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validTag(name string) error {
+	if name == "" || strings.ContainsAny(name, ".$") {
+		return fmt.Errorf("%w: %q", ErrInvalidTag, name)
+	}
+	return nil
+}
+
+// RollupQuery selects the measurements a rollup aggregates and how they are bucketed.
+type RollupQuery struct {
+	Measurement string
+	// Tags restricts the rollup to measurements with these tag values.
+	Tags map[string]string
+	// From and To bound the measured times to [From, To), unbounded when zero.
+	From, To time.Time
+	// Unit and BinSize give the length of the intervals, e.g. UnitMinute and 5 for five
+	// minutes. BinSize defaults to 1. Intervals are aligned as by $dateTrunc.
+	Unit    TimeUnit
+	BinSize int
+	// Timezone is the Olson timezone or UTC offset intervals of days and longer are aligned in,
+	// UTC if empty.
+	Timezone string
+	// GroupBy splits the buckets of an interval by the values of these tags.
+	GroupBy []string
+}
+
+// Bucket is the rollup of the measurements of one interval, and with GroupBy of one combination
+// of tag values.
+type Bucket struct {
+	Start time.Time `bson:"start"`
+	// Tags holds the values of the GroupBy tags, missing ones are left out.
+	Tags  map[string]string `bson:"tags,omitempty"`
+	Count int64             `bson:"count"`
+	Sum   float64           `bson:"sum"`
+	Avg   float64           `bson:"avg"`
+	Min   float64           `bson:"min"`
+	Max   float64           `bson:"max"`
+}
+
+// Rollup returns the count, sum, average, minimum and maximum of the measurements selected by q
+// per interval, ordered by start; intervals without measurements are left out.
+func (m *Metrics) Rollup(ctx context.Context, q RollupQuery) ([]Bucket, error) {
+	pipeline, err := RollupPipeline(q)
+	if err != nil {
+		return nil, err
+	}
+	var buckets []Bucket
+	err = m.coll.Aggregate(ctx, pipeline, &buckets)
+	return buckets, err
+}
+
+// RollupPipeline returns the aggregation pipeline Rollup runs, to extend it with own stages.
+// Its output documents decode into Bucket.
+func RollupPipeline(q RollupQuery) (mongo.Pipeline, error) {
+	if q.Measurement == "" || q.Unit == "" {
+		return nil, errors.New("mongoboiler: RollupQuery needs a Measurement and a Unit")
+	}
+	binSize := q.BinSize
+	if binSize <= 0 {
+		binSize = 1
+	}
+
+	match := bson.D{{Key: metricsMetaField + ".measurement", Value: q.Measurement}}
+	names := make([]string, 0, len(q.Tags))
+	for name := range q.Tags {
+		if err := validTag(name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		match = append(match, bson.E{Key: metricsMetaField + ".tags." + name, Value: q.Tags[name]})
+	}
+	if !q.From.IsZero() || !q.To.IsZero() {
+		between := bson.D{}
+		if !q.From.IsZero() {
+			between = append(between, bson.E{Key: "$gte", Value: q.From})
+		}
+		if !q.To.IsZero() {
+			between = append(between, bson.E{Key: "$lt", Value: q.To})
+		}
+		match = append(match, bson.E{Key: metricsTimeField, Value: between})
+	}
+
+	trunc := bson.D{
+		{Key: "date", Value: "$" + metricsTimeField},
+		{Key: "unit", Value: string(q.Unit)},
+		{Key: "binSize", Value: binSize},
+	}
+	if q.Timezone != "" {
+		trunc = append(trunc, bson.E{Key: "timezone", Value: q.Timezone})
+	}
+	id := bson.D{{Key: "start", Value: bson.D{{Key: "$dateTrunc", Value: trunc}}}}
+	order := bson.D{{Key: "_id.start", Value: 1}}
+	project := bson.D{{Key: "_id", Value: 0}, {Key: "start", Value: "$_id.start"}}
+	if len(q.GroupBy) > 0 {
+		tags := bson.D{}
+		for _, name := range q.GroupBy {
+			if err := validTag(name); err != nil {
+				return nil, err
+			}
+			tags = append(tags, bson.E{Key: name, Value: "$" + metricsMetaField + ".tags." + name})
+		}
+		id = append(id, bson.E{Key: "tags", Value: tags})
+		order = append(order, bson.E{Key: "_id.tags", Value: 1})
+		project = append(project, bson.E{Key: "tags", Value: "$_id.tags"})
+	}
+	value := "$" + metricsValueField
+	for _, f := range []string{"count", "sum", "avg", "min", "max"} {
+		project = append(project, bson.E{Key: f, Value: 1})
+	}
+
+	return mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: id},
+			{Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}},
+			{Key: "sum", Value: bson.D{{Key: "$sum", Value: value}}},
+			{Key: "avg", Value: bson.D{{Key: "$avg", Value: value}}},
+			{Key: "min", Value: bson.D{{Key: "$min", Value: value}}},
+			{Key: "max", Value: bson.D{{Key: "$max", Value: value}}},
+		}}},
+		{{Key: "$sort", Value: order}},
+		{{Key: "$project", Value: project}},
+	}, nil
+}
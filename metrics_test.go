@@ -0,0 +1,101 @@
+package mongoboiler
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestMetrics_Record(t *testing.T) {
+	var ops []*Operation
+	coll := recordingCollection(t, &ops)
+	m := &Metrics{coll}
+	at := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	if err := m.Record(context.Background(), "load", map[string]string{"host": "a"}, 0.5, at); err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+	if len(ops) != 1 || ops[0].Kind != OpInsertMany {
+		t.Fatalf("expected one insert, got %+v", ops)
+	}
+	want := metricDocument{Time: at, Meta: metricMeta{Measurement: "load", Tags: map[string]string{"host": "a"}}, Value: 0.5}
+	if !reflect.DeepEqual(ops[0].Documents[0], want) {
+		t.Fatalf("got %+v, want %+v", ops[0].Documents[0], want)
+	}
+
+	if err := m.Record(context.Background(), "load", map[string]string{"host.name": "a"}, 1, time.Time{}); !errors.Is(err, ErrInvalidTag) {
+		t.Fatalf("expected ErrInvalidTag, got %v", err)
+	}
+}
+
+func TestRollupPipeline(t *testing.T) {
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	pipeline, err := RollupPipeline(RollupQuery{
+		Measurement: "load",
+		Tags:        map[string]string{"region": "eu", "env": "prod"},
+		From:        from,
+		Unit:        UnitMinute,
+		BinSize:     5,
+		GroupBy:     []string{"host"},
+	})
+	if err != nil {
+		t.Fatalf("RollupPipeline failed: %v", err)
+	}
+	value := "$value"
+	want := mongo.Pipeline{
+		{{Key: "$match", Value: bson.D{
+			{Key: "meta.measurement", Value: "load"},
+			{Key: "meta.tags.env", Value: "prod"},
+			{Key: "meta.tags.region", Value: "eu"},
+			{Key: "ts", Value: bson.D{{Key: "$gte", Value: from}}},
+		}}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: bson.D{
+				{Key: "start", Value: bson.D{{Key: "$dateTrunc", Value: bson.D{
+					{Key: "date", Value: "$ts"}, {Key: "unit", Value: "minute"}, {Key: "binSize", Value: 5},
+				}}}},
+				{Key: "tags", Value: bson.D{{Key: "host", Value: "$meta.tags.host"}}},
+			}},
+			{Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}},
+			{Key: "sum", Value: bson.D{{Key: "$sum", Value: value}}},
+			{Key: "avg", Value: bson.D{{Key: "$avg", Value: value}}},
+			{Key: "min", Value: bson.D{{Key: "$min", Value: value}}},
+			{Key: "max", Value: bson.D{{Key: "$max", Value: value}}},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "_id.start", Value: 1}, {Key: "_id.tags", Value: 1}}}},
+		{{Key: "$project", Value: bson.D{
+			{Key: "_id", Value: 0}, {Key: "start", Value: "$_id.start"}, {Key: "tags", Value: "$_id.tags"},
+			{Key: "count", Value: 1}, {Key: "sum", Value: 1}, {Key: "avg", Value: 1}, {Key: "min", Value: 1}, {Key: "max", Value: 1},
+		}}},
+	}
+	if !reflect.DeepEqual(pipeline, want) {
+		t.Fatalf("got pipeline\n%v\nwant\n%v", pipeline, want)
+	}
+
+	if _, err := RollupPipeline(RollupQuery{Measurement: "load"}); err == nil {
+		t.Fatalf("expected an error without a unit")
+	}
+	if _, err := RollupPipeline(RollupQuery{Measurement: "load", Unit: UnitHour, GroupBy: []string{"$host"}}); !errors.Is(err, ErrInvalidTag) {
+		t.Fatalf("expected ErrInvalidTag, got %v", err)
+	}
+}
+
+func TestBucket_Decode(t *testing.T) {
+	start := time.Date(2024, 3, 1, 12, 5, 0, 0, time.UTC)
+	raw := mustRaw(t, bson.D{
+		{Key: "start", Value: start}, {Key: "tags", Value: bson.D{{Key: "host", Value: "a"}}},
+		{Key: "count", Value: int32(2)}, {Key: "sum", Value: 1.5}, {Key: "avg", Value: 0.75}, {Key: "min", Value: 0.5}, {Key: "max", Value: 1.0},
+	})
+	var b Bucket
+	if err := bson.Unmarshal(raw, &b); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if !b.Start.Equal(start) || b.Tags["host"] != "a" || b.Count != 2 || b.Avg != 0.75 || b.Max != 1 {
+		t.Fatalf("unexpected bucket %+v", b)
+	}
+}
@@ -0,0 +1,171 @@
+package mongoboiler
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// OpKind identifies the wrapper method an Operation was started from.
+type OpKind string
+
+// Operation kinds.
+const (
+	OpFindOne      OpKind = "findOne"
+	OpFind         OpKind = "find"
+	OpAggregate    OpKind = "aggregate"
+	OpCount        OpKind = "count"
+	OpExplain      OpKind = "explain"
+	OpInsertOne    OpKind = "insertOne"
+	OpInsertMany   OpKind = "insertMany"
+	OpUpdateOne    OpKind = "updateOne"
+	OpUpdateMany   OpKind = "updateMany"
+	OpReplaceOne   OpKind = "replaceOne"
+	OpFindOrCreate OpKind = "findOrCreate"
+	OpDeleteOne    OpKind = "deleteOne"
+	OpDeleteMany   OpKind = "deleteMany"
+	OpDrop         OpKind = "drop"
+)
+
+// IsWrite reports whether the operation modifies data.
+func (k OpKind) IsWrite() bool {
+	switch k {
+	case OpFindOne, OpFind, OpAggregate, OpCount, OpExplain:
+		// Pipelines ending in $out or $merge write, but are still reported as reads.
+		return false
+	}
+	return true
+}
+
+// Operation describes a single wrapper call as it travels through the middleware chain.
+// Middleware may change Filter, Update, Documents and Target before calling the next handler and
+// inspect Result once it returned.
+type Operation struct {
+	// ID identifies this call in logs, traces and errors, see WithCorrelation.
+	ID         string
+	Kind       OpKind
+	Database   string
+	Collection string
+
+	Filter bson.D
+	Update bson.D
+	// Upsert is set for updates and replacements inserting a document when none matches.
+	Upsert bool
+	// Documents holds the documents being inserted, the replacement for OpReplaceOne or the
+	// defaults for OpFindOrCreate.
+	Documents []any
+	// Pipeline holds the stages of OpAggregate.
+	Pipeline mongo.Pipeline
+
+	// Result is set by write operations to the InsertResult, UpdateResult or DeleteResult the
+	// call returns, so middleware answering a write without the server sets it as well.
+	Result any
+
+	// Comment is sent to the server with the operation unless empty or the call sets its own.
+	Comment string
+
+	// Target is the driver collection the operation is executed against.
+	Target *mongo.Collection
+
+	// targetOptions holds the options Target was built with, so middleware pointing it at
+	// another collection, as tenancy does, keeps the concerns and read preference.
+	targetOptions []*options.CollectionOptions
+
+	// needsEffect is set for writes whose caller depends on them taking place, such as the
+	// find-and-modify of counters and queue leases or the upsert taking a lock; WithDryRun fails
+	// them also when logging.
+	needsEffect bool
+	// unordered is set for an OpInsertMany going on past failing documents, so middleware can
+	// tell which documents a partial failure inserted.
+	unordered bool
+	// dryRun is set for writes WithDryRun skips, so middleware skips its own writes for them.
+	dryRun bool
+	// opaqueComment is set for calls setting a comment other than a string, see foldCallComment.
+	opaqueComment bool
+}
+
+// Handler executes an Operation.
+type Handler func(ctx context.Context, op *Operation) error
+
+// Middleware wraps a Handler with cross-cutting behavior.
+type Middleware func(next Handler) Handler
+
+func (c Collection) newOp(kind OpKind) *Operation {
+	return &Operation{
+		ID:            newOperationID(),
+		Kind:          kind,
+		Database:      c.db.databaseName(),
+		Collection:    c.collectionName(),
+		Target:        c.collection(),
+		targetOptions: c.driverOptions(),
+	}
+}
+
+// run executes fn for op through the collection's middleware chain.
+// The operation ID is taken from ctx when set there and made available through it, like the
+// pinned session unless ctx carries one.
+// Errors are translated with TranslateError. Once the DB is closed it fails with ErrClosed.
+func (c Collection) run(ctx context.Context, op *Operation, fn Handler) error {
+	ctx, done, err := c.db.conn.ops.start(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+	ctx = c.sessionContext(ctx)
+	if id, ok := OperationIDFromContext(ctx); ok {
+		op.ID = id
+	} else {
+		ctx = ContextWithOperationID(ctx, op.ID)
+	}
+	c.applyContextComment(ctx, op)
+	c.routeRead(ctx, op)
+	h := fn
+	if c.settings != nil {
+		op.dryRun = c.settings.dryRun != nil && op.Kind.IsWrite()
+		if c.settings.unacknowledged {
+			h = c.db.conn.unacknowledged.handler(h)
+		}
+		if cc := c.settings.contextComment; cc != nil && cc.inFilter {
+			h = filterCommentHandler(h)
+		}
+		// Tag the comment before it is copied to the filter.
+		if c.settings.serverCancellation {
+			h = c.db.conn.cancellations.handler(h)
+		}
+		// Retry only the operation, not the middleware around it.
+		if c.settings.retry != nil {
+			h = c.settings.retry.handler(h)
+		}
+		// Inside the middleware so the filter counted is the one it would have executed.
+		if c.settings.dryRun != nil {
+			h = c.settings.dryRun.handler(h)
+		}
+		// Check the filter after the middleware, which may add the shard key as tenancy does.
+		if len(c.settings.shardKey) > 0 {
+			h = shardKeyHandler(c.settings.shardKey)(h)
+		}
+		for i := len(c.settings.middleware) - 1; i >= 0; i-- {
+			h = c.settings.middleware[i](h)
+		}
+		// Encrypt outside the middleware so it only sees ciphertext.
+		if enc := c.settings.encryption; enc != nil {
+			h = enc.middleware(c.settings.encryptedFields)(h)
+		}
+		// Normalize before encrypting, ciphertext has no strings left to normalize.
+		if len(c.settings.normalizers) > 0 {
+			h = normalizeMiddleware(c.settings.normalizers)(h)
+		}
+		// Escape before encrypting, reads decrypt before unescaping.
+		if c.settings.escapeKeys {
+			h = keyEscapeMiddleware(h)
+		}
+	}
+	err = h(ctx, op)
+	if cache := c.readCache(); cache != nil && op.Kind.IsWrite() {
+		// Also after failures, as they may have written partially.
+		cache.invalidate(op)
+	}
+	return TranslateError(err)
+}
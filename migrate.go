@@ -0,0 +1,344 @@
+package mongoboiler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DefaultMigrationsCollection is the collection a Migrator records applied migrations in.
+const DefaultMigrationsCollection = "migrations"
+
+// migrationLockTTL is the lease of the lock held while migrating, refreshed in the background.
+const migrationLockTTL = time.Minute
+
+// ErrConditionFailed is wrapped by MigrationError when a pre or post condition does not hold.
+var ErrConditionFailed = errors.New("mongoboiler: migration condition failed")
+
+// Migration is one step of a schema or data migration.
+type Migration struct {
+	// ID identifies the migration in the migrations collection, e.g. "2024-05-01-split-names".
+	ID          string
+	Description string
+	Up          func(ctx context.Context, db *DB) error
+	// Down reverts Up. It is invoked when Up or a post condition fails, and by RollbackLast.
+	Down func(ctx context.Context, db *DB) error
+	// Pre must hold before Up runs, Post after it did.
+	Pre, Post []Condition
+}
+
+// Condition is a check run before or after a migration.
+type Condition struct {
+	Name  string
+	Check func(ctx context.Context, db *DB) error
+}
+
+// MigrationError describes a failed migration.
+type MigrationError struct {
+	Migration string
+	// Phase is "pre", "up" or "post".
+	Phase string
+	// Condition names the failed condition, if any.
+	Condition string
+	Err       error
+	// RolledBack reports whether Down ran successfully; RollbackErr holds its error otherwise.
+	RolledBack  bool
+	RollbackErr error
+}
+
+func (e *MigrationError) Error() string {
+	msg := fmt.Sprintf("mongoboiler: migration %s failed in %s", e.Migration, e.Phase)
+	if e.Condition != "" {
+		msg += " condition " + e.Condition
+	}
+	msg += ": " + e.Err.Error()
+	switch {
+	case e.RolledBack:
+		msg += " (rolled back)"
+	case e.RollbackErr != nil:
+		msg += " (rollback failed: " + e.RollbackErr.Error() + ")"
+	}
+	return msg
+}
+
+func (e *MigrationError) Unwrap() error {
+	return e.Err
+}
+
+// Code returns CodePreconditionFailed for failed conditions, the code of Err otherwise.
+func (e *MigrationError) Code() ErrorCode {
+	if e.Condition != "" {
+		return CodePreconditionFailed
+	}
+	return ErrorCodeOf(e.Err)
+}
+
+// MigrationRecord is the entry of an applied migration.
+type MigrationRecord struct {
+	ID          string    `bson:"_id"`
+	Description string    `bson:"description,omitempty"`
+	AppliedAt   time.Time `bson:"appliedAt"`
+	Duration    int64     `bson:"durationMs"`
+}
+
+// Migrator applies migrations in order, each at most once. Concurrent runs, e.g. by several
+// instances starting at once, are serialized with a Lock.
+type Migrator struct {
+	db         *DB
+	history    *Collection
+	migrations []Migration
+}
+
+// NewMigrator returns a migrator for migrations, recording them in DefaultMigrationsCollection.
+func (db *DB) NewMigrator(migrations ...Migration) *Migrator {
+	return &Migrator{db: db, history: db.NewCollection(DefaultMigrationsCollection), migrations: migrations}
+}
+
+// Applied returns the applied migrations, oldest first.
+func (m *Migrator) Applied(ctx context.Context) ([]MigrationRecord, error) {
+	var records []MigrationRecord
+	err := m.history.FindMany(ctx, bson.D{}, &records, options.Find().SetSort(bson.D{{Key: "appliedAt", Value: 1}, {Key: "_id", Value: 1}}))
+	return records, err
+}
+
+// Up applies the migrations not applied yet and returns their IDs. It stops at the first
+// failure, which is returned as a *MigrationError after attempting its rollback.
+func (m *Migrator) Up(ctx context.Context) ([]string, error) {
+	var ran []string
+	err := m.locked(ctx, func(ctx context.Context) error {
+		applied, err := m.Applied(ctx)
+		if err != nil {
+			return err
+		}
+		done := map[string]bool{}
+		for _, r := range applied {
+			done[r.ID] = true
+		}
+		for _, mig := range m.migrations {
+			if done[mig.ID] {
+				continue
+			}
+			start := time.Now()
+			if err := m.apply(ctx, mig); err != nil {
+				return err
+			}
+			_, err := m.history.InsertOne(ctx, MigrationRecord{
+				ID:          mig.ID,
+				Description: mig.Description,
+				AppliedAt:   time.Now().UTC(),
+				Duration:    time.Since(start).Milliseconds(),
+			})
+			if err != nil {
+				return fmt.Errorf("mongoboiler: recording migration %s: %w", mig.ID, err)
+			}
+			ran = append(ran, mig.ID)
+		}
+		return nil
+	})
+	return ran, err
+}
+
+// RollbackLast reverts the most recently applied migration with its Down and returns its ID,
+// empty if none was applied.
+func (m *Migrator) RollbackLast(ctx context.Context) (string, error) {
+	var id string
+	err := m.locked(ctx, func(ctx context.Context) error {
+		applied, err := m.Applied(ctx)
+		if err != nil || len(applied) == 0 {
+			return err
+		}
+		last := applied[len(applied)-1].ID
+		var mig *Migration
+		for i := range m.migrations {
+			if m.migrations[i].ID == last {
+				mig = &m.migrations[i]
+			}
+		}
+		if mig == nil || mig.Down == nil {
+			return fmt.Errorf("mongoboiler: migration %s cannot be rolled back, it has no Down", last)
+		}
+		if err := mig.Down(ctx, m.db); err != nil {
+			return fmt.Errorf("mongoboiler: rolling back migration %s: %w", last, err)
+		}
+		if _, err := m.history.DeleteOne(ctx, bson.D{{Key: "_id", Value: last}}); err != nil {
+			return err
+		}
+		id = last
+		return nil
+	})
+	return id, err
+}
+
+// apply runs one migration with its conditions, rolling it back when Up or a post condition
+// fails.
+func (m *Migrator) apply(ctx context.Context, mig Migration) error {
+	if name, err := m.check(ctx, mig.Pre); err != nil {
+		// Nothing ran yet, there is nothing to roll back.
+		return &MigrationError{Migration: mig.ID, Phase: "pre", Condition: name, Err: err}
+	}
+	var failure *MigrationError
+	if err := mig.Up(ctx, m.db); err != nil {
+		failure = &MigrationError{Migration: mig.ID, Phase: "up", Err: err}
+	} else if name, err := m.check(ctx, mig.Post); err != nil {
+		failure = &MigrationError{Migration: mig.ID, Phase: "post", Condition: name, Err: err}
+	} else {
+		return nil
+	}
+	if mig.Down != nil {
+		failure.RollbackErr = mig.Down(ctx, m.db)
+		failure.RolledBack = failure.RollbackErr == nil
+	}
+	return failure
+}
+
+// check runs conditions and returns the name and error of the first failing one.
+func (m *Migrator) check(ctx context.Context, conditions []Condition) (string, error) {
+	for _, c := range conditions {
+		if err := c.Check(ctx, m.db); err != nil {
+			if !errors.Is(err, ErrConditionFailed) {
+				err = fmt.Errorf("%w: %v", ErrConditionFailed, err)
+			}
+			return c.Name, err
+		}
+	}
+	return "", nil
+}
+
+// locked runs fn holding the migrations lock, refreshing it until fn returns.
+func (m *Migrator) locked(ctx context.Context, fn func(ctx context.Context) error) error {
+	lock, err := m.db.Lock(ctx, "mongoboiler:migrations", migrationLockTTL)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock(context.Background())
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		ticker := time.NewTicker(migrationLockTTL / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := lock.Refresh(ctx); err != nil && ctx.Err() == nil {
+					// Another runner may take over, stop as soon as possible.
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+	return fn(ctx)
+}
+
+// CountIs is a condition that holds when want documents of collection match filter.
+func CountIs(collection string, filter bson.D, want int64) Condition {
+	return Condition{
+		Name: fmt.Sprintf("count of %s is %d", collection, want),
+		Check: func(ctx context.Context, db *DB) error {
+			n, err := db.NewCollection(collection).collection().CountDocuments(ctx, nonNilFilter(filter))
+			if err != nil {
+				return err
+			}
+			if n != want {
+				return fmt.Errorf("%w: %s has %d matching documents, want %d", ErrConditionFailed, collection, n, want)
+			}
+			return nil
+		},
+	}
+}
+
+// CountsEqual is a condition that holds when as many documents of both collections match
+// filter, e.g. after copying one into the other.
+func CountsEqual(a, b string, filter bson.D) Condition {
+	return Condition{
+		Name: fmt.Sprintf("counts of %s and %s are equal", a, b),
+		Check: func(ctx context.Context, db *DB) error {
+			na, err := db.NewCollection(a).collection().CountDocuments(ctx, nonNilFilter(filter))
+			if err != nil {
+				return err
+			}
+			nb, err := db.NewCollection(b).collection().CountDocuments(ctx, nonNilFilter(filter))
+			if err != nil {
+				return err
+			}
+			if na != nb {
+				return fmt.Errorf("%w: %s has %d matching documents, %s has %d", ErrConditionFailed, a, na, b, nb)
+			}
+			return nil
+		},
+	}
+}
+
+// ChecksumsEqual is a condition that holds when the documents of both collections matching
+// filter have the same Checksum over fields.
+func ChecksumsEqual(a, b string, filter bson.D, fields ...string) Condition {
+	return Condition{
+		Name: fmt.Sprintf("checksums of %s and %s are equal", a, b),
+		Check: func(ctx context.Context, db *DB) error {
+			ca, err := db.NewCollection(a).Checksum(ctx, nonNilFilter(filter), fields...)
+			if err != nil {
+				return err
+			}
+			cb, err := db.NewCollection(b).Checksum(ctx, nonNilFilter(filter), fields...)
+			if err != nil {
+				return err
+			}
+			if ca != cb {
+				return fmt.Errorf("%w: %s and %s differ (%d and %d documents)", ErrConditionFailed, a, b, ca.Count, cb.Count)
+			}
+			return nil
+		},
+	}
+}
+
+// IndexExists is a condition that holds when collection has the named index.
+func IndexExists(collection, name string) Condition {
+	return indexCondition(collection, name, true)
+}
+
+// IndexMissing is a condition that holds when collection has no index of that name.
+func IndexMissing(collection, name string) Condition {
+	return indexCondition(collection, name, false)
+}
+
+func indexCondition(collection, name string, want bool) Condition {
+	verb := "exists"
+	if !want {
+		verb = "is missing"
+	}
+	return Condition{
+		Name: fmt.Sprintf("index %s on %s %s", name, collection, verb),
+		Check: func(ctx context.Context, db *DB) error {
+			specs, err := db.NewCollection(collection).collection().Indexes().ListSpecifications(ctx)
+			if err != nil {
+				return err
+			}
+			found := false
+			for _, s := range specs {
+				found = found || s.Name == name
+			}
+			if found != want {
+				state := "is missing"
+				if found {
+					state = "exists"
+				}
+				return fmt.Errorf("%w: index %s on %s %s", ErrConditionFailed, name, collection, state)
+			}
+			return nil
+		},
+	}
+}
+
+func nonNilFilter(filter bson.D) bson.D {
+	if filter == nil {
+		return bson.D{}
+	}
+	return filter
+}
@@ -0,0 +1,48 @@
+package mongoboiler
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestMigrator_Apply(t *testing.T) {
+	db := newTestCollection(t, "unused").db
+	ok := Condition{Name: "ok", Check: func(context.Context, *DB) error { return nil }}
+	bad := Condition{Name: "copied", Check: func(context.Context, *DB) error { return errors.New("3 documents missing") }}
+
+	var steps []string
+	up := func(context.Context, *DB) error { steps = append(steps, "up"); return nil }
+	down := func(context.Context, *DB) error { steps = append(steps, "down"); return nil }
+	m := db.NewMigrator()
+
+	if err := m.apply(context.Background(), Migration{ID: "m1", Up: up, Down: down, Pre: []Condition{ok}, Post: []Condition{ok}}); err != nil {
+		t.Fatalf("apply failed: %v", err)
+	}
+
+	steps = nil
+	err := m.apply(context.Background(), Migration{ID: "m2", Up: up, Down: down, Post: []Condition{ok, bad}})
+	var merr *MigrationError
+	if !errors.As(err, &merr) || merr.Phase != "post" || merr.Condition != "copied" || !merr.RolledBack {
+		t.Fatalf("expected a rolled back post condition failure, got %v", err)
+	}
+	if !errors.Is(err, ErrConditionFailed) {
+		t.Fatalf("expected ErrConditionFailed, got %v", err)
+	}
+	if len(steps) != 2 || steps[1] != "down" {
+		t.Fatalf("expected up then down, got %v", steps)
+	}
+
+	steps = nil
+	err = m.apply(context.Background(), Migration{ID: "m3", Up: up, Down: down, Pre: []Condition{bad}})
+	if !errors.As(err, &merr) || merr.Phase != "pre" || merr.RolledBack || len(steps) != 0 {
+		t.Fatalf("expected nothing to run on a failed pre condition, got %v after %v", err, steps)
+	}
+
+	failing := errors.New("rollback failed")
+	err = m.apply(context.Background(), Migration{ID: "m4", Up: func(context.Context, *DB) error { return errors.New("boom") },
+		Down: func(context.Context, *DB) error { return failing }})
+	if !errors.As(err, &merr) || merr.Phase != "up" || merr.RolledBack || merr.RollbackErr != failing {
+		t.Fatalf("expected the rollback failure to be reported, got %v", err)
+	}
+}
@@ -0,0 +1,176 @@
+package mongoboiler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	// ErrModelRegistered is returned by Register when the type was registered before.
+	ErrModelRegistered = errors.New("mongoboiler: model already registered")
+	// ErrModelNotRegistered is returned, or panicked with by Model, for types not registered.
+	ErrModelNotRegistered = errors.New("mongoboiler: model not registered")
+)
+
+// ModelOptions declares where and how a model registered with Register is stored.
+type ModelOptions struct {
+	// Collection is the collection name, derived from the type name with Naming when empty.
+	Collection string
+	// Naming derives the collection name, by default the one set with WithModelNaming or
+	// SnakeCasePlural.
+	Naming NameFunc
+	// Indexes are created by EnsureModelIndexes, in addition to those declared by the index and
+	// ttl tags of the model (see AutoIndex).
+	Indexes []mongo.IndexModel
+	// Options apply to the collection on top of the DB and Configure options, e.g.
+	// WithWriteConcern or WithMiddleware for hooks.
+	Options []Option
+}
+
+// modelRegistry holds the models registered for a DB.
+type modelRegistry struct {
+	mu     sync.RWMutex
+	models map[reflect.Type]*registeredModel
+	order  []reflect.Type
+}
+
+type registeredModel struct {
+	collection string
+	opts       ModelOptions
+}
+
+func newModelRegistry() *modelRegistry {
+	return &modelRegistry{models: map[reflect.Type]*registeredModel{}}
+}
+
+func (r *modelRegistry) lookup(rt reflect.Type) (*registeredModel, bool) {
+	if r == nil {
+		return nil, false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	m, ok := r.models[rt]
+	return m, ok
+}
+
+// Register declares once where the struct type T is stored, so services get its collection with
+// Model instead of each deriving it:
+//
+//	mongoboiler.Register[User](db, mongoboiler.ModelOptions{
+//		Options: []mongoboiler.Option{mongoboiler.WithWriteConcern(writeconcern.Majority())},
+//	})
+//	users := mongoboiler.Model[User](db) // the users collection
+//
+// It is meant to run at startup; registering a type twice returns ErrModelRegistered.
+func Register[T any](db *DB, opts ModelOptions) error {
+	rt := reflect.TypeOf((*T)(nil)).Elem()
+	if rt.Kind() != reflect.Struct {
+		return ErrNotStruct
+	}
+	name := opts.Collection
+	if name == "" {
+		naming := opts.Naming
+		if naming == nil && db.settings != nil {
+			naming = db.settings.modelNaming
+		}
+		if naming == nil {
+			naming = SnakeCasePlural
+		}
+		name = naming(rt.Name())
+	}
+	if name == "" {
+		return fmt.Errorf("mongoboiler: no collection name for model %s", rt)
+	}
+
+	db.models.mu.Lock()
+	defer db.models.mu.Unlock()
+	if _, ok := db.models.models[rt]; ok {
+		return fmt.Errorf("%w: %s", ErrModelRegistered, rt)
+	}
+	db.models.models[rt] = &registeredModel{collection: name, opts: opts}
+	db.models.order = append(db.models.order, rt)
+	return nil
+}
+
+// TypedCollection is the collection of a registered model T. Next to the methods of Collection
+// it has ones taking and returning T.
+type TypedCollection[T any] struct {
+	*Collection
+}
+
+// Model returns the collection of the model T registered with Register, with the options given
+// there. It panics when T was not registered, like using an unknown collection it is a
+// programming error; see LookupModel.
+func Model[T any](db *DB) *TypedCollection[T] {
+	c, ok := LookupModel[T](db)
+	if !ok {
+		panic(fmt.Errorf("%w: %s", ErrModelNotRegistered, reflect.TypeOf((*T)(nil)).Elem()))
+	}
+	return c
+}
+
+// LookupModel is like Model but reports with ok whether T was registered.
+func LookupModel[T any](db *DB) (c *TypedCollection[T], ok bool) {
+	m, ok := db.models.lookup(reflect.TypeOf((*T)(nil)).Elem())
+	if !ok {
+		return nil, false
+	}
+	return &TypedCollection[T]{db.NewCollection(m.collection, m.opts.Options...)}, true
+}
+
+// Get returns the first document matching filter.
+func (c TypedCollection[T]) Get(ctx context.Context, filter bson.D, opts ...*options.FindOneOptions) (T, error) {
+	var doc T
+	err := c.FindOne(ctx, filter, &doc, opts...)
+	return doc, err
+}
+
+// All returns all documents matching filter.
+func (c TypedCollection[T]) All(ctx context.Context, filter bson.D, opts ...*options.FindOptions) ([]T, error) {
+	var docs []T
+	err := c.FindMany(ctx, filter, &docs, opts...)
+	return docs, err
+}
+
+// Insert inserts doc.
+func (c TypedCollection[T]) Insert(ctx context.Context, doc T, opts ...*options.InsertOneOptions) (InsertResult, error) {
+	return c.InsertOne(ctx, doc, opts...)
+}
+
+// InsertAll inserts docs in one InsertMany.
+func (c TypedCollection[T]) InsertAll(ctx context.Context, docs []T, opts ...*options.InsertManyOptions) (InsertResult, error) {
+	all := make([]any, len(docs))
+	for i, doc := range docs {
+		all[i] = doc
+	}
+	return c.InsertMany(ctx, all, opts...)
+}
+
+// EnsureModelIndexes creates the indexes of every registered model, those declared by its tags
+// and its ModelOptions.Indexes, in registration order.
+func (db *DB) EnsureModelIndexes(ctx context.Context) error {
+	db.models.mu.RLock()
+	order := append([]reflect.Type(nil), db.models.order...)
+	db.models.mu.RUnlock()
+
+	for _, rt := range order {
+		m, _ := db.models.lookup(rt)
+		c := db.NewCollection(m.collection, m.opts.Options...)
+		if err := c.AutoIndex(ctx, reflect.New(rt).Interface()); err != nil {
+			return fmt.Errorf("mongoboiler: indexes of model %s: %w", rt, err)
+		}
+		if len(m.opts.Indexes) > 0 {
+			if _, err := c.collection().Indexes().CreateMany(ctx, m.opts.Indexes); err != nil {
+				return fmt.Errorf("mongoboiler: indexes of model %s: %w", rt, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package mongoboiler
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+)
+
+type OrderItem struct {
+	ID  string `bson:"_id"`
+	SKU string `bson:"sku"`
+}
+
+type auditEntry struct {
+	ID string `bson:"_id"`
+}
+
+func TestRegister_Model(t *testing.T) {
+	db := newTestCollection(t, "unused", WithModelNaming(strings.ToUpper)).db
+	var ops []*Operation
+	hook := WithMiddleware(func(next Handler) Handler {
+		return func(ctx context.Context, op *Operation) error {
+			ops = append(ops, op)
+			return nil
+		}
+	})
+
+	if err := Register[OrderItem](db, ModelOptions{Naming: SnakeCasePlural, Options: []Option{
+		hook, WithWriteConcern(writeconcern.Majority()), WithReadPreference(readpref.Secondary()),
+	}}); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if err := Register[auditEntry](db, ModelOptions{}); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if err := Register[OrderItem](db, ModelOptions{}); !errors.Is(err, ErrModelRegistered) {
+		t.Fatalf("expected ErrModelRegistered, got %v", err)
+	}
+	if err := Register[*OrderItem](db, ModelOptions{}); !errors.Is(err, ErrNotStruct) {
+		t.Fatalf("expected ErrNotStruct, got %v", err)
+	}
+
+	items := Model[OrderItem](db)
+	if _, err := items.InsertAll(context.Background(), []OrderItem{{ID: "1"}, {ID: "2"}}); err != nil {
+		t.Fatalf("InsertAll failed: %v", err)
+	}
+	if len(ops) != 1 || ops[0].Collection != "order_items" || ops[0].Kind != OpInsertMany || len(ops[0].Documents) != 2 {
+		t.Fatalf("unexpected operations %+v", ops)
+	}
+	if opts := items.driverOptions(); len(opts) != 1 || opts[0].WriteConcern.GetW() != "majority" ||
+		opts[0].ReadPreference.Mode() != readpref.SecondaryMode {
+		t.Fatalf("concerns not applied: %+v", opts)
+	}
+	if o := resolvedOption(t, items.ResolveOptions(), "readPreference"); o.Value != "secondary" {
+		t.Fatalf("readPreference resolved to %s", o)
+	}
+
+	if entries := Model[auditEntry](db); entries.name != "AUDITENTRY" {
+		t.Fatalf("expected the DB's model naming, got %s", entries.name)
+	}
+	if _, ok := LookupModel[bson.D](db); ok {
+		t.Fatalf("expected bson.D not to be registered")
+	}
+	if _, ok := LookupModel[OrderItem](db.Database("other")); ok {
+		t.Fatalf("expected models to be registered by database")
+	}
+}
+
+func TestModel_PanicsWhenNotRegistered(t *testing.T) {
+	db := newTestCollection(t, "unused").db
+	defer func() {
+		err, _ := recover().(error)
+		if !errors.Is(err, ErrModelNotRegistered) {
+			t.Fatalf("expected a panic with ErrModelNotRegistered, got %v", err)
+		}
+	}()
+	Model[OrderItem](db)
+}
+
+func TestTypedCollection_Get(t *testing.T) {
+	db := newTestCollection(t, "unused", WithCache(NewLRUCache(10), 0)).db
+	if err := Register[OrderItem](db, ModelOptions{Collection: "items"}); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	items := Model[OrderItem](db)
+	primeFind(t, items.Collection, bson.D{}, bson.D{{Key: "_id", Value: "1"}, {Key: "sku", Value: "a"}})
+
+	all, err := items.All(context.Background(), bson.D{})
+	if err != nil {
+		t.Fatalf("All failed: %v", err)
+	}
+	if len(all) != 1 || all[0] != (OrderItem{ID: "1", SKU: "a"}) {
+		t.Fatalf("unexpected items %+v", all)
+	}
+}
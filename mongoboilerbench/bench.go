@@ -0,0 +1,233 @@
+// Package mongoboilerbench runs synthetic workloads against a collection through the
+// mongoboiler wrapper and reports throughput and latency percentiles, for capacity testing.
+// The mongoboilerbench command wraps it for use from the shell.
+package mongoboilerbench
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/anurag925/mongoboiler"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Workload describes the load to generate.
+type Workload struct {
+	// Duration bounds the run, unless Operations is reached first. One of them must be set.
+	Duration   time.Duration
+	Operations int64
+	// Concurrency is the number of workers issuing operations back to back, default 1.
+	Concurrency int
+	// ReadRatio is the fraction of operations that are reads (FindOne by _id), the others insert
+	// new documents.
+	ReadRatio float64
+	// DocumentSize is the approximate size in bytes of inserted documents, default 512.
+	DocumentSize int
+	// Seed documents are inserted before measuring so reads find something, default 1000.
+	Seed int
+}
+
+// Latency holds latency percentiles of one kind of operation.
+type Latency struct {
+	Count, Errors  int64
+	Mean, P50, P90 time.Duration
+	P99, P999, Max time.Duration
+	latencies      []time.Duration
+}
+
+// Report is the outcome of a run.
+type Report struct {
+	Duration   time.Duration
+	Operations int64
+	// Throughput is in operations per second.
+	Throughput    float64
+	Reads, Writes Latency
+}
+
+func (r Report) String() string {
+	return fmt.Sprintf("%d ops in %s (%.0f ops/s)\nreads:  %s\nwrites: %s",
+		r.Operations, r.Duration.Round(time.Millisecond), r.Throughput, r.Reads, r.Writes)
+}
+
+func (l Latency) String() string {
+	return fmt.Sprintf("n=%d errors=%d mean=%s p50=%s p90=%s p99=%s p99.9=%s max=%s",
+		l.Count, l.Errors, l.Mean, l.P50, l.P90, l.P99, l.P999, l.Max)
+}
+
+// Validate reports settings of w Run cannot use, zero values stand for the defaults.
+func (w Workload) Validate() error {
+	switch {
+	case w.Duration <= 0 && w.Operations <= 0:
+		return errors.New("mongoboilerbench: workload needs a duration or an operation count")
+	case w.Duration < 0 || w.Operations < 0:
+		return errors.New("mongoboilerbench: duration and operation count cannot be negative")
+	case w.Concurrency < 0:
+		return fmt.Errorf("mongoboilerbench: concurrency %d is negative", w.Concurrency)
+	case w.ReadRatio < 0 || w.ReadRatio > 1:
+		return fmt.Errorf("mongoboilerbench: read ratio %g is not between 0 and 1", w.ReadRatio)
+	case w.DocumentSize < 0:
+		return fmt.Errorf("mongoboilerbench: document size %d is negative", w.DocumentSize)
+	case w.Seed < 0:
+		return fmt.Errorf("mongoboilerbench: seed %d is negative", w.Seed)
+	}
+	return nil
+}
+
+type benchDoc struct {
+	ID      primitive.ObjectID `bson:"_id"`
+	Payload string             `bson:"payload"`
+	At      time.Time          `bson:"at"`
+}
+
+// Run seeds coll and then runs w against it until w.Duration passed, w.Operations were issued
+// or ctx is canceled. Missing documents on reads are not counted as errors.
+func Run(ctx context.Context, coll mongoboiler.CollectionAPI, w Workload) (Report, error) {
+	if err := w.Validate(); err != nil {
+		return Report{}, err
+	}
+	if w.Concurrency <= 0 {
+		w.Concurrency = 1
+	}
+	if w.DocumentSize <= 0 {
+		w.DocumentSize = 512
+	}
+	if w.Seed == 0 {
+		w.Seed = 1000
+	}
+
+	ids := &idPool{}
+	seed := make([]any, w.Seed)
+	for i := range seed {
+		doc := newDoc(rand.New(rand.NewSource(int64(i))), w.DocumentSize)
+		seed[i] = doc
+		ids.add(doc.ID)
+	}
+	if _, err := coll.InsertMany(ctx, seed); err != nil {
+		return Report{}, fmt.Errorf("mongoboilerbench: seeding: %w", err)
+	}
+
+	if w.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, w.Duration)
+		defer cancel()
+	}
+
+	var (
+		mu     sync.Mutex
+		reads  Latency
+		writes Latency
+		issued int64
+		wg     sync.WaitGroup
+		start  = time.Now()
+	)
+	next := func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		if w.Operations > 0 && issued >= w.Operations {
+			return false
+		}
+		issued++
+		return true
+	}
+	for i := 0; i < w.Concurrency; i++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			rnd := rand.New(rand.NewSource(time.Now().UnixNano() + int64(worker)))
+			for ctx.Err() == nil && next() {
+				isRead := rnd.Float64() < w.ReadRatio
+				opStart := time.Now()
+				var err error
+				if isRead {
+					var doc benchDoc
+					err = coll.FindOne(ctx, bson.D{{Key: "_id", Value: ids.random(rnd)}}, &doc)
+					if errors.Is(err, mongoboiler.ErrNotFound) {
+						err = nil
+					}
+				} else {
+					doc := newDoc(rnd, w.DocumentSize)
+					if _, err = coll.InsertOne(ctx, doc); err == nil {
+						ids.add(doc.ID)
+					}
+				}
+				took := time.Since(opStart)
+				if ctx.Err() != nil {
+					// Operations cut short by the end of the run are not measured.
+					return
+				}
+
+				mu.Lock()
+				l := &writes
+				if isRead {
+					l = &reads
+				}
+				l.latencies = append(l.latencies, took)
+				if err != nil {
+					l.Errors++
+				}
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	report := Report{Duration: time.Since(start), Reads: reads.summarize(), Writes: writes.summarize()}
+	report.Operations = report.Reads.Count + report.Writes.Count
+	if secs := report.Duration.Seconds(); secs > 0 {
+		report.Throughput = float64(report.Operations) / secs
+	}
+	return report, nil
+}
+
+func (l Latency) summarize() Latency {
+	out := Latency{Count: int64(len(l.latencies)), Errors: l.Errors}
+	if out.Count == 0 {
+		return out
+	}
+	sorted := append([]time.Duration(nil), l.latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	var total time.Duration
+	for _, d := range sorted {
+		total += d
+	}
+	pct := func(p float64) time.Duration {
+		return sorted[int(p*float64(len(sorted)-1))]
+	}
+	out.Mean = total / time.Duration(len(sorted))
+	out.P50, out.P90, out.P99, out.P999 = pct(0.5), pct(0.9), pct(0.99), pct(0.999)
+	out.Max = sorted[len(sorted)-1]
+	return out
+}
+
+func newDoc(rnd *rand.Rand, size int) benchDoc {
+	const letters = "abcdefghijklmnopqrstuvwxyz0123456789"
+	payload := make([]byte, size)
+	for i := range payload {
+		payload[i] = letters[rnd.Intn(len(letters))]
+	}
+	return benchDoc{ID: primitive.NewObjectID(), Payload: string(payload), At: time.Now()}
+}
+
+// idPool holds the IDs of documents known to exist, for reads to pick from.
+type idPool struct {
+	mu  sync.RWMutex
+	ids []primitive.ObjectID
+}
+
+func (p *idPool) add(id primitive.ObjectID) {
+	p.mu.Lock()
+	p.ids = append(p.ids, id)
+	p.mu.Unlock()
+}
+
+func (p *idPool) random(rnd *rand.Rand) primitive.ObjectID {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.ids[rnd.Intn(len(p.ids))]
+}
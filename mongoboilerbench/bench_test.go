@@ -0,0 +1,78 @@
+package mongoboilerbench
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/anurag925/mongoboiler/mongoboilertest"
+)
+
+func TestRun(t *testing.T) {
+	coll := mongoboilertest.NewFakeCollection()
+	report, err := Run(context.Background(), coll, Workload{
+		Operations:   400,
+		Concurrency:  4,
+		ReadRatio:    0.5,
+		DocumentSize: 64,
+		Seed:         50,
+	})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if report.Operations != 400 {
+		t.Fatalf("operations = %d, want 400", report.Operations)
+	}
+	if report.Reads.Count == 0 || report.Writes.Count == 0 {
+		t.Fatalf("reads = %d, writes = %d, want both", report.Reads.Count, report.Writes.Count)
+	}
+	if report.Reads.Errors+report.Writes.Errors != 0 {
+		t.Fatalf("unexpected errors: %s", report)
+	}
+	if got, want := coll.Len(), 50+int(report.Writes.Count); got != want {
+		t.Fatalf("collection has %d documents, want %d", got, want)
+	}
+	for _, l := range []Latency{report.Reads, report.Writes} {
+		if l.P50 > l.P99 || l.P99 > l.Max {
+			t.Fatalf("percentiles out of order: %s", l)
+		}
+	}
+}
+
+func TestRunNeedsBound(t *testing.T) {
+	if _, err := Run(context.Background(), mongoboilertest.NewFakeCollection(), Workload{}); err == nil {
+		t.Fatalf("Run without duration or operations succeeded")
+	}
+}
+
+func TestWorkloadValidate(t *testing.T) {
+	for _, w := range []Workload{
+		{Duration: -time.Second},
+		{Operations: 10, Duration: -time.Second},
+		{Operations: 10, Concurrency: -1},
+		{Operations: 10, ReadRatio: 1.5},
+		{Operations: 10, DocumentSize: -1},
+		{Operations: 10, Seed: -1},
+	} {
+		if err := w.Validate(); err == nil {
+			t.Errorf("Validate(%+v) succeeded", w)
+		}
+	}
+	if err := (Workload{Operations: 10}).Validate(); err != nil {
+		t.Fatalf("Validate of the defaults: %v", err)
+	}
+	if _, err := Run(context.Background(), mongoboilertest.NewFakeCollection(), Workload{Operations: 10, Seed: -1}); err == nil {
+		t.Fatalf("Run with a negative seed succeeded")
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	var l Latency
+	for i := 1; i <= 100; i++ {
+		l.latencies = append(l.latencies, time.Duration(i)*time.Millisecond)
+	}
+	s := l.summarize()
+	if s.P50 != 50*time.Millisecond || s.P99 != 99*time.Millisecond || s.Max != 100*time.Millisecond {
+		t.Fatalf("summary = %s", s)
+	}
+}
@@ -0,0 +1,100 @@
+package mongoboilertest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/anurag925/mongoboiler/internal/bsonutil"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// AssertFilterEqual fails t unless the query filters want and got are equivalent. Unlike
+// reflect.DeepEqual it ignores the order of conditions, operators, $and/$or clauses and $in
+// values, and compares numbers by value, so int and int64 literals match. Filters may be
+// bson.D, bson.M or structs.
+func AssertFilterEqual(t testing.TB, want, got any) {
+	t.Helper()
+	w, err := toD(want)
+	if err != nil {
+		t.Fatalf("AssertFilterEqual: want: %v", err)
+	}
+	g, err := toD(got)
+	if err != nil {
+		t.Fatalf("AssertFilterEqual: got: %v", err)
+	}
+	assertEqual(t, "filter", bsonutil.CanonicalFilter(w), bsonutil.CanonicalFilter(g))
+}
+
+// AssertPipelineEqual fails t unless the aggregation pipelines want and got are equivalent:
+// stages must come in the same order, but $match filters are compared like AssertFilterEqual
+// and the options of stages like $group, $project and $lookup may be in any order.
+// Pipelines may be mongo.Pipeline, []bson.D or bson.A.
+func AssertPipelineEqual(t testing.TB, want, got any) {
+	t.Helper()
+	w, err := toPipeline(want)
+	if err != nil {
+		t.Fatalf("AssertPipelineEqual: want: %v", err)
+	}
+	g, err := toPipeline(got)
+	if err != nil {
+		t.Fatalf("AssertPipelineEqual: got: %v", err)
+	}
+	wrap := func(p []bson.D) bson.D {
+		stages := make(bson.A, len(p))
+		for i, s := range p {
+			stages[i] = s
+		}
+		return bson.D{{Key: "pipeline", Value: stages}}
+	}
+	assertEqual(t, "pipeline", wrap(bsonutil.CanonicalPipeline(w)), wrap(bsonutil.CanonicalPipeline(g)))
+}
+
+func assertEqual(t testing.TB, what string, want, got bson.D) {
+	t.Helper()
+	wantRaw, err := bson.Marshal(want)
+	if err != nil {
+		t.Fatalf("marshal want: %v", err)
+	}
+	gotRaw, err := bson.Marshal(got)
+	if err != nil {
+		t.Fatalf("marshal got: %v", err)
+	}
+	if path, differ := bsonutil.Diff(wantRaw, gotRaw); differ {
+		t.Errorf("%s mismatch at %s\nwant: %s\n got: %s", what, path, extJSON(wantRaw), extJSON(gotRaw))
+	}
+}
+
+func toD(v any) (bson.D, error) {
+	data, err := bson.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var d bson.D
+	return d, bson.Unmarshal(data, &d)
+}
+
+func toPipeline(v any) ([]bson.D, error) {
+	d, err := toD(bson.D{{Key: "p", Value: v}})
+	if err != nil {
+		return nil, err
+	}
+	stages, ok := d[0].Value.(bson.A)
+	if !ok {
+		return nil, fmt.Errorf("pipeline must be an array of stages, got %T", v)
+	}
+	out := make([]bson.D, len(stages))
+	for i, s := range stages {
+		if out[i], ok = s.(bson.D); !ok {
+			return nil, fmt.Errorf("stage %d is not a document", i)
+		}
+	}
+	return out, nil
+}
+
+func extJSON(raw bson.Raw) string {
+	data, err := bson.MarshalExtJSON(raw, false, false)
+	if err != nil {
+		return raw.String()
+	}
+	return string(data)
+}
@@ -0,0 +1,66 @@
+package mongoboilertest
+
+import (
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// recordingTB captures failures instead of failing the test.
+type recordingTB struct {
+	testing.TB
+	failures []string
+}
+
+func (r *recordingTB) Helper() {}
+
+func (r *recordingTB) Errorf(format string, args ...any) {
+	r.failures = append(r.failures, format)
+}
+
+func TestAssertFilterEqual(t *testing.T) {
+	want := bson.D{
+		{Key: "status", Value: bson.D{{Key: "$in", Value: bson.A{"a", "b"}}}},
+		{Key: "age", Value: bson.D{{Key: "$gte", Value: 18}, {Key: "$lt", Value: int64(65)}}},
+	}
+	got := bson.M{
+		"age":    bson.D{{Key: "$lt", Value: 65}, {Key: "$gte", Value: 18.0}},
+		"status": bson.D{{Key: "$in", Value: bson.A{"b", "a"}}},
+	}
+	AssertFilterEqual(t, want, got)
+
+	rec := &recordingTB{TB: t}
+	AssertFilterEqual(rec, want, bson.D{{Key: "age", Value: bson.D{{Key: "$gte", Value: 21}}}})
+	if len(rec.failures) != 1 {
+		t.Fatalf("expected a mismatch to be reported")
+	}
+
+	// Equality on embedded documents is order sensitive in MongoDB.
+	rec = &recordingTB{TB: t}
+	AssertFilterEqual(rec,
+		bson.D{{Key: "addr", Value: bson.D{{Key: "city", Value: "x"}, {Key: "zip", Value: "1"}}}},
+		bson.D{{Key: "addr", Value: bson.D{{Key: "zip", Value: "1"}, {Key: "city", Value: "x"}}}})
+	if len(rec.failures) != 1 {
+		t.Fatalf("expected embedded document order to matter")
+	}
+}
+
+func TestAssertPipelineEqual(t *testing.T) {
+	want := mongo.Pipeline{
+		{{Key: "$match", Value: bson.D{{Key: "a", Value: 1}, {Key: "b", Value: 2}}}},
+		{{Key: "$group", Value: bson.D{{Key: "_id", Value: "$a"}, {Key: "n", Value: bson.D{{Key: "$sum", Value: 1}}}}}},
+	}
+	got := []bson.D{
+		{{Key: "$match", Value: bson.D{{Key: "b", Value: 2}, {Key: "a", Value: 1}}}},
+		{{Key: "$group", Value: bson.D{{Key: "n", Value: bson.D{{Key: "$sum", Value: 1}}}, {Key: "_id", Value: "$a"}}}},
+	}
+	AssertPipelineEqual(t, want, got)
+
+	rec := &recordingTB{TB: t}
+	AssertPipelineEqual(rec, want, []bson.D{got[1], got[0]})
+	if len(rec.failures) != 1 || !strings.Contains(rec.failures[0], "mismatch") {
+		t.Fatalf("expected stage order to matter, got %v", rec.failures)
+	}
+}
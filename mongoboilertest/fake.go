@@ -0,0 +1,445 @@
+// Package mongoboilertest provides test helpers for code built on mongoboiler, most notably an
+// in-memory implementation of mongoboiler.CollectionAPI that needs no running MongoDB.
+package mongoboilertest
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/anurag925/mongoboiler"
+	"github.com/anurag925/mongoboiler/internal/bsonutil"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// FakeCollection is an in-memory mongoboiler.CollectionAPI for unit tests. It supports the
+// filters and update operators listed on matches and applyUpdate, the Sort, Skip and Limit find
+// options and upserts; other options are ignored. It is safe for concurrent use.
+type FakeCollection struct {
+	mu   sync.Mutex
+	docs []bson.Raw
+}
+
+var _ mongoboiler.CollectionAPI = (*FakeCollection)(nil)
+
+// NewFakeCollection returns an empty FakeCollection.
+func NewFakeCollection() *FakeCollection {
+	return &FakeCollection{}
+}
+
+// Drop removes all documents. Drop protection is not simulated, confirm is ignored.
+func (f *FakeCollection) Drop(ctx context.Context, confirm ...mongoboiler.DropConfirmation) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.docs = nil
+	return nil
+}
+
+// FindOne decodes the first document matching filter into res, mongoboiler.ErrNotFound if none does.
+func (f *FakeCollection) FindOne(ctx context.Context, filter bson.D, res any, opts ...*options.FindOneOptions) error {
+	o := options.MergeFindOneOptions(opts...)
+	found, err := f.find(filter, o.Sort, o.Skip, nil)
+	if err != nil {
+		return err
+	}
+	if len(found) == 0 {
+		return mongoboiler.TranslateError(mongo.ErrNoDocuments)
+	}
+	return bson.Unmarshal(found[0], res)
+}
+
+// FindMany decodes all documents matching filter into the slice res points to.
+func (f *FakeCollection) FindMany(ctx context.Context, filter bson.D, res any, opts ...*options.FindOptions) error {
+	sliceVal := reflect.ValueOf(res)
+	if sliceVal.Kind() != reflect.Ptr || sliceVal.Elem().Kind() != reflect.Slice {
+		return mongoboiler.ErrNotSlicePointer
+	}
+	sliceVal = sliceVal.Elem()
+	sliceVal.Set(sliceVal.Slice(0, 0))
+
+	return f.FindEach(ctx, filter, func(dec mongoboiler.Decoder) error {
+		elem := reflect.New(sliceVal.Type().Elem())
+		if err := dec.Decode(elem.Interface()); err != nil {
+			return err
+		}
+		sliceVal.Set(reflect.Append(sliceVal, elem.Elem()))
+		return nil
+	}, opts...)
+}
+
+// FindEach calls fn for every document matching filter.
+func (f *FakeCollection) FindEach(ctx context.Context, filter bson.D, fn func(dec mongoboiler.Decoder) error, opts ...*options.FindOptions) error {
+	o := options.MergeFindOptions(opts...)
+	found, err := f.find(filter, o.Sort, o.Skip, o.Limit)
+	if err != nil {
+		return err
+	}
+	for _, doc := range found {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(rawDecoder(doc)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// InsertOne inserts new, generating an ObjectID _id when it has none.
+func (f *FakeCollection) InsertOne(ctx context.Context, new any, opts ...*options.InsertOneOptions) (mongoboiler.InsertResult, error) {
+	res, err := f.InsertMany(ctx, []any{new})
+	return res, err
+}
+
+// InsertMany inserts all documents, failing on the first duplicate _id.
+func (f *FakeCollection) InsertMany(ctx context.Context, new []any, opts ...*options.InsertManyOptions) (mongoboiler.InsertResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var res mongoboiler.InsertResult
+	for _, doc := range new {
+		raw, id, err := prepareInsert(doc)
+		if err != nil {
+			return res, err
+		}
+		if f.indexOfID(id) >= 0 {
+			return res, duplicateKeyError(id)
+		}
+		f.docs = append(f.docs, raw)
+		if res.InsertedIDs == nil {
+			res.InsertedID = id
+		}
+		res.InsertedIDs = append(res.InsertedIDs, id)
+	}
+	return res, nil
+}
+
+// UpdateOne applies update to the first document matching filter.
+func (f *FakeCollection) UpdateOne(ctx context.Context, filter, update bson.D, opts ...*options.UpdateOptions) (mongoboiler.UpdateResult, error) {
+	return f.update(filter, update, false, options.MergeUpdateOptions(opts...).Upsert)
+}
+
+// UpdateMany applies update to all documents matching filter.
+func (f *FakeCollection) UpdateMany(ctx context.Context, filter, update bson.D, opts ...*options.UpdateOptions) (mongoboiler.UpdateResult, error) {
+	return f.update(filter, update, true, options.MergeUpdateOptions(opts...).Upsert)
+}
+
+// ReplaceOne replaces the first document matching filter with doc, keeping its _id.
+func (f *FakeCollection) ReplaceOne(ctx context.Context, filter bson.D, doc any, opts ...*options.ReplaceOptions) (mongoboiler.UpdateResult, error) {
+	replacement, err := normalize(doc)
+	if err != nil {
+		return mongoboiler.UpdateResult{}, err
+	}
+	replacement = withoutKey(replacement, "_id")
+	upsert := options.MergeReplaceOptions(opts...).Upsert
+
+	return f.modify(filter, false, upsert != nil && *upsert, func(cur bson.D, inserting bool) (bson.D, error) {
+		id, _ := getPath(cur, []string{"_id"})
+		if id == nil {
+			return replacement, nil
+		}
+		return append(bson.D{{Key: "_id", Value: id}}, replacement...), nil
+	})
+}
+
+// DeleteOne deletes the first document matching filter.
+func (f *FakeCollection) DeleteOne(ctx context.Context, filter bson.D, opts ...*options.DeleteOptions) (mongoboiler.DeleteResult, error) {
+	return f.delete(filter, false)
+}
+
+// DeleteMany deletes all documents matching filter.
+func (f *FakeCollection) DeleteMany(ctx context.Context, filter bson.D, opts ...*options.DeleteOptions) (mongoboiler.DeleteResult, error) {
+	return f.delete(filter, true)
+}
+
+// Len returns the number of stored documents.
+func (f *FakeCollection) Len() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.docs)
+}
+
+func (f *FakeCollection) find(filter bson.D, sortSpec any, skip, limit *int64) ([]bson.Raw, error) {
+	rawFilter, err := marshalFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	var found []bson.Raw
+	for _, doc := range f.docs {
+		ok, err := matches(doc, rawFilter)
+		if err != nil {
+			f.mu.Unlock()
+			return nil, err
+		}
+		if ok {
+			found = append(found, doc)
+		}
+	}
+	f.mu.Unlock()
+
+	if sortSpec != nil {
+		if err := sortDocs(found, sortSpec); err != nil {
+			return nil, err
+		}
+	}
+	if skip != nil {
+		if int(*skip) >= len(found) {
+			return nil, nil
+		}
+		found = found[*skip:]
+	}
+	if limit != nil && *limit > 0 && int(*limit) < len(found) {
+		found = found[:*limit]
+	}
+	return found, nil
+}
+
+func (f *FakeCollection) update(filter, update bson.D, many bool, upsert *bool) (mongoboiler.UpdateResult, error) {
+	normalized, err := normalize(update)
+	if err != nil {
+		return mongoboiler.UpdateResult{}, err
+	}
+	return f.modify(filter, many, upsert != nil && *upsert, func(cur bson.D, inserting bool) (bson.D, error) {
+		return applyUpdate(cur, normalized, inserting)
+	})
+}
+
+// modify replaces matching documents with the output of change, upserting when nothing matched.
+func (f *FakeCollection) modify(filter bson.D, many, upsert bool, change func(cur bson.D, inserting bool) (bson.D, error)) (mongoboiler.UpdateResult, error) {
+	var res mongoboiler.UpdateResult
+	rawFilter, err := marshalFilter(filter)
+	if err != nil {
+		return res, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for i, doc := range f.docs {
+		ok, err := matches(doc, rawFilter)
+		if err != nil {
+			return res, err
+		}
+		if !ok {
+			continue
+		}
+		res.MatchedCount++
+
+		var cur bson.D
+		if err := bson.Unmarshal(doc, &cur); err != nil {
+			return res, err
+		}
+		if res.MatchedCount == 1 {
+			res.DocumentID, _ = getPath(cur, []string{"_id"})
+		} else {
+			res.DocumentID = nil
+		}
+		next, err := change(cur, false)
+		if err != nil {
+			return res, err
+		}
+		raw, err := bson.Marshal(next)
+		if err != nil {
+			return res, err
+		}
+		if !bsonutil.Equal(rawDoc(doc), rawDoc(raw)) {
+			res.ModifiedCount++
+		}
+		f.docs[i] = raw
+		if !many {
+			break
+		}
+	}
+	if res.MatchedCount > 0 || !upsert {
+		return res, nil
+	}
+
+	seed, err := upsertSeed(filter)
+	if err != nil {
+		return res, err
+	}
+	next, err := change(seed, true)
+	if err != nil {
+		return res, err
+	}
+	raw, id, err := prepareInsert(next)
+	if err != nil {
+		return res, err
+	}
+	f.docs = append(f.docs, raw)
+	res.UpsertedCount, res.UpsertedID, res.DocumentID = 1, id, id
+	return res, nil
+}
+
+func (f *FakeCollection) delete(filter bson.D, many bool) (mongoboiler.DeleteResult, error) {
+	var res mongoboiler.DeleteResult
+	rawFilter, err := marshalFilter(filter)
+	if err != nil {
+		return res, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// A new slice, so a failing match leaves the documents as they were.
+	kept := make([]bson.Raw, 0, len(f.docs))
+	for _, doc := range f.docs {
+		if many || res.DeletedCount == 0 {
+			ok, err := matches(doc, rawFilter)
+			if err != nil {
+				return res, err
+			}
+			if ok {
+				res.DeletedCount++
+				continue
+			}
+		}
+		kept = append(kept, doc)
+	}
+	f.docs = kept
+	return res, nil
+}
+
+func (f *FakeCollection) indexOfID(id any) int {
+	want, err := marshalValue(id)
+	if err != nil {
+		return -1
+	}
+	for i, doc := range f.docs {
+		if bsonutil.Equal(doc.Lookup("_id"), want) {
+			return i
+		}
+	}
+	return -1
+}
+
+// prepareInsert marshals doc, adding an ObjectID _id when missing.
+func prepareInsert(doc any) (bson.Raw, any, error) {
+	d, err := normalize(doc)
+	if err != nil {
+		return nil, nil, err
+	}
+	id, ok := getPath(d, []string{"_id"})
+	if !ok {
+		id = primitive.NewObjectID()
+		d = append(bson.D{{Key: "_id", Value: id}}, d...)
+	}
+	raw, err := bson.Marshal(d)
+	return raw, id, err
+}
+
+// upsertSeed returns the equality fields of filter, the starting point of an upserted document.
+func upsertSeed(filter bson.D) (bson.D, error) {
+	normalized, err := normalize(filter)
+	if err != nil {
+		return nil, err
+	}
+	var seed bson.D
+	for _, e := range normalized {
+		if len(e.Key) > 0 && e.Key[0] == '$' {
+			continue
+		}
+		if d, ok := e.Value.(bson.D); ok && len(d) > 0 && len(d[0].Key) > 0 && d[0].Key[0] == '$' {
+			if d[0].Key != "$eq" {
+				continue
+			}
+			seed = append(seed, bson.E{Key: e.Key, Value: d[0].Value})
+			continue
+		}
+		seed = append(seed, e)
+	}
+	return seed, nil
+}
+
+func sortDocs(docs []bson.Raw, spec any) error {
+	keys, err := normalize(spec)
+	if err != nil {
+		return err
+	}
+	sort.SliceStable(docs, func(i, j int) bool {
+		for _, k := range keys {
+			a, _ := lookup(docs[i], k.Key)
+			b, _ := lookup(docs[j], k.Key)
+			c := bsonutil.Compare(a, b)
+			if dir, _ := toInt(k.Value); dir < 0 {
+				c = -c
+			}
+			if c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+	return nil
+}
+
+// normalize round trips v through BSON so values have the types decoding produces.
+func normalize(v any) (bson.D, error) {
+	if v == nil {
+		return bson.D{}, nil
+	}
+	data, err := bson.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var d bson.D
+	err = bson.Unmarshal(data, &d)
+	return d, err
+}
+
+func marshalFilter(filter bson.D) (bson.Raw, error) {
+	if filter == nil {
+		filter = bson.D{}
+	}
+	return bson.Marshal(filter)
+}
+
+func marshalValue(v any) (bson.RawValue, error) {
+	t, data, err := bson.MarshalValue(v)
+	return bson.RawValue{Type: t, Value: data}, err
+}
+
+func rawDoc(doc bson.Raw) bson.RawValue {
+	return bson.RawValue{Type: bson.TypeEmbeddedDocument, Value: doc}
+}
+
+func withoutKey(d bson.D, key string) bson.D {
+	out := make(bson.D, 0, len(d))
+	for _, e := range d {
+		if e.Key != key {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+func duplicateKeyError(id any) error {
+	return mongoboiler.TranslateError(mongo.WriteException{WriteErrors: []mongo.WriteError{{
+		Code:    11000,
+		Message: "E11000 duplicate key error index: _id_ dup key: { _id: " + errorValue(id) + " }",
+	}}})
+}
+
+func errorValue(v any) string {
+	raw, err := marshalValue(v)
+	if err != nil {
+		return "?"
+	}
+	return raw.String()
+}
+
+type rawDecoder bson.Raw
+
+func (r rawDecoder) Decode(v any) error {
+	if v == nil {
+		return errors.New("mongoboilertest: Decode into nil")
+	}
+	return bson.Unmarshal(bson.Raw(r), v)
+}
@@ -0,0 +1,181 @@
+package mongoboilertest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/anurag925/mongoboiler"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type user struct {
+	ID    string   `bson:"_id"`
+	Name  string   `bson:"name"`
+	Age   int      `bson:"age"`
+	Tags  []string `bson:"tags,omitempty"`
+	Score float64  `bson:"score,omitempty"`
+}
+
+func seed(t *testing.T) *FakeCollection {
+	coll := NewFakeCollection()
+	_, err := coll.InsertMany(context.Background(), []any{
+		user{ID: "1", Name: "ann", Age: 31, Tags: []string{"admin"}},
+		user{ID: "2", Name: "bob", Age: 25},
+		user{ID: "3", Name: "cid", Age: 42, Tags: []string{"ops", "admin"}},
+	})
+	if err != nil {
+		t.Fatalf("InsertMany failed: %v", err)
+	}
+	return coll
+}
+
+func TestFakeCollection_Filters(t *testing.T) {
+	coll := seed(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name   string
+		filter bson.D
+		want   int
+	}{
+		{"eq", bson.D{{Key: "name", Value: "bob"}}, 1},
+		{"gt", bson.D{{Key: "age", Value: bson.D{{Key: "$gt", Value: 30}}}}, 2},
+		{"lt", bson.D{{Key: "age", Value: bson.D{{Key: "$lt", Value: int64(30)}}}}, 1},
+		{"in", bson.D{{Key: "name", Value: bson.D{{Key: "$in", Value: bson.A{"ann", "cid", "zed"}}}}}, 2},
+		{"array element", bson.D{{Key: "tags", Value: "admin"}}, 2},
+		{"range", bson.D{{Key: "age", Value: bson.D{{Key: "$gt", Value: 20}, {Key: "$lt", Value: 40}}}}, 2},
+		{"or", bson.D{{Key: "$or", Value: bson.A{bson.D{{Key: "name", Value: "ann"}}, bson.D{{Key: "age", Value: 25}}}}}, 2},
+		{"exists", bson.D{{Key: "tags", Value: bson.D{{Key: "$exists", Value: true}}}}, 2},
+		{"exists 1", bson.D{{Key: "tags", Value: bson.D{{Key: "$exists", Value: 1}}}}, 2},
+		{"exists 0", bson.D{{Key: "tags", Value: bson.D{{Key: "$exists", Value: int64(0)}}}}, 1},
+	}
+	for _, tt := range tests {
+		var res []user
+		if err := coll.FindMany(ctx, tt.filter, &res); err != nil {
+			t.Fatalf("%s: FindMany failed: %v", tt.name, err)
+		}
+		if len(res) != tt.want {
+			t.Errorf("%s: expected %d documents, got %d", tt.name, tt.want, len(res))
+		}
+	}
+}
+
+func TestFakeCollection_FindOptions(t *testing.T) {
+	coll := seed(t)
+
+	var res []user
+	opts := options.Find().SetSort(bson.D{{Key: "age", Value: -1}}).SetLimit(2)
+	if err := coll.FindMany(context.Background(), bson.D{}, &res, opts); err != nil {
+		t.Fatalf("FindMany failed: %v", err)
+	}
+	if len(res) != 2 || res[0].Name != "cid" || res[1].Name != "ann" {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+}
+
+func TestFakeCollection_Update(t *testing.T) {
+	coll := seed(t)
+	ctx := context.Background()
+
+	res, err := coll.UpdateMany(ctx, bson.D{{Key: "tags", Value: "admin"}}, bson.D{
+		{Key: "$inc", Value: bson.D{{Key: "age", Value: 1}}},
+		{Key: "$set", Value: bson.D{{Key: "score", Value: 1.5}}},
+	})
+	if err != nil {
+		t.Fatalf("UpdateMany failed: %v", err)
+	}
+	if res.MatchedCount != 2 || res.ModifiedCount != 2 {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+
+	var ann user
+	if err := coll.FindOne(ctx, bson.D{{Key: "_id", Value: "1"}}, &ann); err != nil {
+		t.Fatalf("FindOne failed: %v", err)
+	}
+	if ann.Age != 32 || ann.Score != 1.5 {
+		t.Fatalf("update not applied: %+v", ann)
+	}
+
+	res, err = coll.UpdateOne(ctx, bson.D{{Key: "_id", Value: "4"}},
+		bson.D{{Key: "$set", Value: bson.D{{Key: "name", Value: "dan"}}}},
+		options.Update().SetUpsert(true))
+	if err != nil {
+		t.Fatalf("upsert failed: %v", err)
+	}
+	if res.UpsertedID != "4" || coll.Len() != 4 {
+		t.Fatalf("expected upserted document 4, got %+v", res)
+	}
+	if res.Outcome() != mongoboiler.OutcomeCreated || res.DocumentID != "4" {
+		t.Fatalf("expected document 4 created, got %q %v", res.Outcome(), res.DocumentID)
+	}
+
+	res, err = coll.UpdateOne(ctx, bson.D{{Key: "name", Value: "dan"}},
+		bson.D{{Key: "$set", Value: bson.D{{Key: "name", Value: "dan"}}}},
+		options.Update().SetUpsert(true))
+	if err != nil {
+		t.Fatalf("upsert failed: %v", err)
+	}
+	if res.Outcome() != mongoboiler.OutcomeUnchanged || res.DocumentID != "4" {
+		t.Fatalf("expected document 4 unchanged, got %q %v", res.Outcome(), res.DocumentID)
+	}
+}
+
+func TestFakeCollection_InvalidFilters(t *testing.T) {
+	coll := seed(t)
+	ctx := context.Background()
+
+	for name, filter := range map[string]bson.D{
+		"in":  {{Key: "name", Value: bson.D{{Key: "$in", Value: "ann"}}}},
+		"nin": {{Key: "name", Value: bson.D{{Key: "$nin", Value: 5}}}},
+		"and": {{Key: "$and", Value: bson.A{5}}},
+		"nor": {{Key: "$nor", Value: bson.A{"ann"}}},
+	} {
+		var res []user
+		if err := coll.FindMany(ctx, filter, &res); err == nil {
+			t.Errorf("%s: expected an error for the malformed filter", name)
+		}
+	}
+
+	// ann is deleted and bob kept before the filter fails on cid.
+	failing := bson.D{{Key: "$or", Value: bson.A{
+		bson.D{{Key: "name", Value: "ann"}},
+		bson.D{{Key: "$and", Value: bson.A{bson.D{{Key: "name", Value: "cid"}}, 5}}},
+	}}}
+	if _, err := coll.DeleteMany(ctx, failing); err == nil {
+		t.Fatalf("expected the delete to fail")
+	}
+	var res []user
+	if err := coll.FindMany(ctx, bson.D{}, &res, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})); err != nil {
+		t.Fatalf("FindMany failed: %v", err)
+	}
+	if len(res) != 3 || res[0].Name != "ann" || res[1].Name != "bob" || res[2].Name != "cid" {
+		t.Fatalf("expected the failed delete to leave the documents intact, got %+v", res)
+	}
+}
+
+func TestFakeCollection_InsertAndDelete(t *testing.T) {
+	coll := seed(t)
+	ctx := context.Background()
+
+	_, err := coll.InsertOne(ctx, user{ID: "1", Name: "dup"})
+	var dup *mongoboiler.DuplicateKeyError
+	if !mongo.IsDuplicateKeyError(err) || !errors.As(err, &dup) || dup.Index != "_id_" {
+		t.Fatalf("expected duplicate key error on _id_, got %v", err)
+	}
+
+	res, err := coll.DeleteMany(ctx, bson.D{{Key: "age", Value: bson.D{{Key: "$gt", Value: 30}}}})
+	if err != nil {
+		t.Fatalf("DeleteMany failed: %v", err)
+	}
+	if res.DeletedCount != 2 || coll.Len() != 1 {
+		t.Fatalf("unexpected delete result: %+v, %d left", res, coll.Len())
+	}
+
+	var u user
+	if err := coll.FindOne(ctx, bson.D{{Key: "name", Value: "ann"}}, &u); !errors.Is(err, mongoboiler.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,347 @@
+package mongoboilertest
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anurag925/mongoboiler"
+	"go.mongodb.org/mongo-driver/bson/bsoncodec"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Rules customize the documents made by Generate.
+type Rules struct {
+	// Seed makes the output reproducible, a random seed is used if zero.
+	Seed int64
+	// Fields generates the fields at the given dotted document paths, taking precedence over
+	// fake tags, e.g. {"tenant": func(r *rand.Rand, i int) any { return "acme" }}.
+	Fields map[string]FieldRule
+	// Now is the time dates without a range and ObjectIDs are generated before, DefaultNow if
+	// zero. It is fixed so that seeded output does not change between runs.
+	Now time.Time
+}
+
+// DefaultNow is the default of Rules.Now.
+var DefaultNow = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// FieldRule returns the value of a field for the i-th generated document. The value must be
+// assignable or convertible to the field's type.
+type FieldRule func(r *rand.Rand, i int) any
+
+// Generate returns n values of struct type T filled with fake but plausible data. Fields are
+// generated according to their fake tag, or randomly by type without one:
+//
+//	Email   string     `bson:"email" fake:"email"`
+//	Name    string     `bson:"name" fake:"name"`
+//	Status  string     `bson:"status" fake:"enum=active|suspended"`
+//	Age     int        `bson:"age" fake:"int=18..90"`
+//	Joined  time.Time  `bson:"joined" fake:"date=2020-01-01..2024-12-31"`
+//	Deleted *time.Time `bson:"deleted" fake:"-"`
+//
+// Supported are email, name, firstName, lastName, company, city, country, phone, url, word,
+// sentence, uuid, enum=a|b, int=min..max, float=min..max, date (within the year before
+// Rules.Now), date=from..to and - to leave the field zero. Emails include the document's index,
+// so they are unique within one call.
+func Generate[T any](n int, rules Rules) ([]T, error) {
+	if n < 0 {
+		return nil, fmt.Errorf("mongoboilertest: cannot generate %d documents", n)
+	}
+	if rules.Seed == 0 {
+		rules.Seed = time.Now().UnixNano()
+	}
+	if rules.Now.IsZero() {
+		rules.Now = DefaultNow
+	}
+	g := &generator{rnd: rand.New(rand.NewSource(rules.Seed)), rules: rules}
+	out := make([]T, n)
+	for i := range out {
+		g.index = i
+		v := reflect.ValueOf(&out[i]).Elem()
+		if v.Kind() != reflect.Struct {
+			return nil, fmt.Errorf("mongoboilertest: Generate needs a struct type, got %s", v.Type())
+		}
+		if err := g.fillStruct(v, "", 0); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// GenerateInto generates n documents like Generate and inserts them into coll.
+func GenerateInto[T any](ctx context.Context, coll mongoboiler.CollectionAPI, n int, rules Rules) ([]T, error) {
+	docs, err := Generate[T](n, rules)
+	if err != nil || n == 0 {
+		return docs, err
+	}
+	insert := make([]any, len(docs))
+	for i := range docs {
+		insert[i] = docs[i]
+	}
+	if _, err := coll.InsertMany(ctx, insert); err != nil {
+		return nil, err
+	}
+	return docs, nil
+}
+
+// maxGenerateDepth limits the nesting of generated pointers, which may be recursive.
+const maxGenerateDepth = 3
+
+type generator struct {
+	rnd   *rand.Rand
+	rules Rules
+	index int
+}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	dateTimeType = reflect.TypeOf(primitive.DateTime(0))
+	objectIDType = reflect.TypeOf(primitive.ObjectID{})
+)
+
+func (g *generator) fillStruct(v reflect.Value, prefix string, depth int) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		tags, err := bsoncodec.DefaultStructTagParser(sf)
+		if err != nil {
+			return err
+		}
+		if tags.Skip {
+			continue
+		}
+		field := v.Field(i)
+		if tags.Inline && field.Kind() == reflect.Struct {
+			if err := g.fillStruct(field, prefix, depth); err != nil {
+				return err
+			}
+			continue
+		}
+
+		path := prefix + tags.Name
+		if rule, ok := g.rules.Fields[path]; ok {
+			if err := assign(field, rule(g.rnd, g.index)); err != nil {
+				return fmt.Errorf("mongoboilertest: rule for %s: %w", path, err)
+			}
+			continue
+		}
+		if tag, ok := sf.Tag.Lookup("fake"); ok {
+			if err := g.fromTag(field, tag); err != nil {
+				return fmt.Errorf("mongoboilertest: field %s: %w", sf.Name, err)
+			}
+			continue
+		}
+		if err := g.random(field, path, depth); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// random fills v with a random value of its type.
+func (g *generator) random(v reflect.Value, path string, depth int) error {
+	switch v.Type() {
+	case timeType:
+		v.Set(reflect.ValueOf(g.pastDate()))
+		return nil
+	case dateTimeType:
+		v.Set(reflect.ValueOf(primitive.NewDateTimeFromTime(g.pastDate())))
+		return nil
+	case objectIDType:
+		v.Set(reflect.ValueOf(g.objectID()))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(g.pick(words))
+	case reflect.Bool:
+		v.SetBool(g.rnd.Intn(2) == 1)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(int64(g.rnd.Intn(100)))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v.SetUint(uint64(g.rnd.Intn(100)))
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(float64(g.rnd.Intn(100000)) / 100)
+	case reflect.Struct:
+		return g.fillStruct(v, path+".", depth)
+	case reflect.Ptr:
+		if depth >= maxGenerateDepth {
+			return nil
+		}
+		elem := reflect.New(v.Type().Elem())
+		if err := g.random(elem.Elem(), path, depth+1); err != nil {
+			return err
+		}
+		v.Set(elem)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, 16)
+			g.rnd.Read(b)
+			v.SetBytes(b)
+			return nil
+		}
+		if depth >= maxGenerateDepth {
+			return nil
+		}
+		n := 1 + g.rnd.Intn(3)
+		s := reflect.MakeSlice(v.Type(), n, n)
+		for i := 0; i < s.Len(); i++ {
+			if err := g.random(s.Index(i), path+"."+strconv.Itoa(i), depth+1); err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+	}
+	// Maps, interfaces and other kinds stay zero.
+	return nil
+}
+
+func (g *generator) fromTag(v reflect.Value, tag string) error {
+	kind, arg, _ := strings.Cut(tag, "=")
+	switch kind {
+	case "-":
+		return nil
+	case "int", "float":
+		from, to, err := parseRange(arg)
+		if err != nil {
+			return err
+		}
+		n := from + g.rnd.Float64()*(to-from)
+		if kind == "int" {
+			n = float64(int64(from) + g.rnd.Int63n(int64(to-from)+1))
+		}
+		return assign(v, n)
+	case "date":
+		if arg == "" {
+			return assignDate(v, g.pastDate())
+		}
+		fromS, toS, ok := strings.Cut(arg, "..")
+		from, err1 := time.Parse("2006-01-02", fromS)
+		to, err2 := time.Parse("2006-01-02", toS)
+		if !ok || err1 != nil || err2 != nil || !to.After(from) {
+			return fmt.Errorf("fake tag date=%s is not from..to in YYYY-MM-DD", arg)
+		}
+		return assignDate(v, from.Add(time.Duration(g.rnd.Int63n(int64(to.Sub(from))))))
+	}
+
+	if v.Kind() != reflect.String {
+		return fmt.Errorf("fake tag %q needs a string field", kind)
+	}
+	var s string
+	switch kind {
+	case "email":
+		s = fmt.Sprintf("%s.%s%d@example.com", strings.ToLower(g.pick(firstNames)), strings.ToLower(g.pick(lastNames)), g.index)
+	case "name":
+		s = g.pick(firstNames) + " " + g.pick(lastNames)
+	case "firstName":
+		s = g.pick(firstNames)
+	case "lastName":
+		s = g.pick(lastNames)
+	case "company":
+		s = g.pick(lastNames) + " " + g.pick(companySuffixes)
+	case "city":
+		s = g.pick(cities)
+	case "country":
+		s = g.pick(countries)
+	case "phone":
+		s = fmt.Sprintf("+1-555-%03d-%04d", g.rnd.Intn(1000), g.rnd.Intn(10000))
+	case "url":
+		s = "https://www." + g.pick(words) + ".example/" + g.pick(words)
+	case "word":
+		s = g.pick(words)
+	case "sentence":
+		n := 6 + g.rnd.Intn(7)
+		parts := make([]string, n)
+		for i := range parts {
+			parts[i] = g.pick(words)
+		}
+		s = strings.ToUpper(parts[0][:1]) + strings.Join(parts, " ")[1:] + "."
+	case "uuid":
+		b := make([]byte, 16)
+		g.rnd.Read(b)
+		b[6], b[8] = b[6]&0x0f|0x40, b[8]&0x3f|0x80
+		s = fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+	case "enum":
+		if arg == "" {
+			return fmt.Errorf("fake tag enum needs values")
+		}
+		s = g.pick(strings.Split(arg, "|"))
+	default:
+		return fmt.Errorf("unknown fake tag %q", kind)
+	}
+	v.SetString(s)
+	return nil
+}
+
+func (g *generator) pick(from []string) string {
+	return from[g.rnd.Intn(len(from))]
+}
+
+func (g *generator) pastDate() time.Time {
+	const year = 365 * 24 * time.Hour
+	return g.rules.Now.Add(-time.Duration(g.rnd.Int63n(int64(year)))).UTC().Truncate(time.Millisecond)
+}
+
+// objectID returns an ObjectID created at a past date, with random bytes in place of the
+// machine, process and counter parts.
+func (g *generator) objectID() primitive.ObjectID {
+	id := primitive.NewObjectIDFromTimestamp(g.pastDate())
+	g.rnd.Read(id[4:])
+	return id
+}
+
+func parseRange(arg string) (float64, float64, error) {
+	fromS, toS, ok := strings.Cut(arg, "..")
+	from, err1 := strconv.ParseFloat(fromS, 64)
+	to, err2 := strconv.ParseFloat(toS, 64)
+	if !ok || err1 != nil || err2 != nil || to < from {
+		return 0, 0, fmt.Errorf("fake range %q is not min..max", arg)
+	}
+	return from, to, nil
+}
+
+func assignDate(v reflect.Value, t time.Time) error {
+	if v.Type() == dateTimeType {
+		return assign(v, primitive.NewDateTimeFromTime(t))
+	}
+	return assign(v, t)
+}
+
+// assign sets v to value, converting between compatible types such as float64 and int.
+func assign(v reflect.Value, value any) error {
+	rv := reflect.ValueOf(value)
+	switch {
+	case !rv.IsValid():
+		v.Set(reflect.Zero(v.Type()))
+	case rv.Type().AssignableTo(v.Type()):
+		v.Set(rv)
+	case rv.Type().ConvertibleTo(v.Type()) && rv.Kind() != reflect.String:
+		v.Set(rv.Convert(v.Type()))
+	default:
+		return fmt.Errorf("cannot assign %T to %s", value, v.Type())
+	}
+	return nil
+}
+
+var (
+	firstNames = []string{"Ada", "Alan", "Grace", "Linus", "Margaret", "Dennis", "Barbara", "Ken",
+		"Frances", "John", "Radia", "Edsger", "Katherine", "Donald", "Hedy", "Tim"}
+	lastNames = []string{"Lovelace", "Turing", "Hopper", "Torvalds", "Hamilton", "Ritchie", "Liskov",
+		"Thompson", "Allen", "McCarthy", "Perlman", "Dijkstra", "Johnson", "Knuth", "Lamarr", "Berners"}
+	companySuffixes = []string{"Inc", "LLC", "Group", "Labs", "Systems", "GmbH"}
+	cities          = []string{"Amsterdam", "Bengaluru", "Berlin", "Chicago", "Lagos", "Lisbon",
+		"London", "Melbourne", "Mumbai", "Paris", "São Paulo", "Seoul", "Tokyo", "Toronto"}
+	countries = []string{"Australia", "Brazil", "Canada", "France", "Germany", "India", "Japan",
+		"Netherlands", "Nigeria", "Portugal", "South Korea", "United Kingdom", "United States"}
+	words = []string{"alpha", "amber", "atlas", "beacon", "cedar", "cobalt", "delta", "ember",
+		"falcon", "harbor", "indigo", "juniper", "lumen", "maple", "nimbus", "orbit", "pixel",
+		"quartz", "raven", "sierra", "tundra", "vertex", "willow", "zephyr"}
+)
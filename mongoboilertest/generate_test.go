@@ -0,0 +1,106 @@
+package mongoboilertest
+
+import (
+	"context"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type generatedUser struct {
+	Email   string     `bson:"email" fake:"email"`
+	Name    string     `bson:"name" fake:"name"`
+	Status  string     `bson:"status" fake:"enum=active|suspended"`
+	Age     int        `bson:"age" fake:"int=18..90"`
+	Joined  time.Time  `bson:"joined" fake:"date=2020-01-01..2021-01-01"`
+	Deleted *time.Time `bson:"deleted" fake:"-"`
+	Tenant  string     `bson:"tenant"`
+	Address struct {
+		City string `bson:"city" fake:"city"`
+	} `bson:"address"`
+	Tags []string `bson:"tags"`
+}
+
+func TestGenerate(t *testing.T) {
+	rules := Rules{Seed: 42, Fields: map[string]FieldRule{
+		"tenant": func(r *rand.Rand, i int) any { return "acme" },
+	}}
+	users, err := Generate[generatedUser](50, rules)
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	emails := map[string]bool{}
+	for _, u := range users {
+		if !strings.HasSuffix(u.Email, "@example.com") || emails[u.Email] {
+			t.Fatalf("bad or duplicate email %q", u.Email)
+		}
+		emails[u.Email] = true
+		if u.Status != "active" && u.Status != "suspended" {
+			t.Fatalf("status %q not in enum", u.Status)
+		}
+		if u.Age < 18 || u.Age > 90 {
+			t.Fatalf("age %d out of range", u.Age)
+		}
+		if u.Joined.Year() != 2020 {
+			t.Fatalf("joined %v out of range", u.Joined)
+		}
+		if u.Deleted != nil || u.Tenant != "acme" || u.Address.City == "" || len(u.Tags) == 0 {
+			t.Fatalf("unexpected user %+v", u)
+		}
+	}
+
+	again, err := Generate[generatedUser](50, rules)
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if !reflect.DeepEqual(again, users) {
+		t.Fatalf("same seed generated different documents")
+	}
+}
+
+func TestGenerate_Reproducible(t *testing.T) {
+	type event struct {
+		ID primitive.ObjectID `bson:"_id"`
+		At time.Time          `bson:"at"`
+	}
+	rules := Rules{Seed: 7}
+	events, err := Generate[event](20, rules)
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	again, _ := Generate[event](20, rules)
+	if !reflect.DeepEqual(again, events) {
+		t.Fatalf("same seed generated different IDs or dates")
+	}
+	for _, e := range events {
+		if !e.At.Before(DefaultNow) || e.At.Before(DefaultNow.AddDate(-1, 0, 0)) || e.ID.Timestamp().After(DefaultNow) {
+			t.Fatalf("expected dates in the year before DefaultNow, got %+v", e)
+		}
+	}
+	if _, err := Generate[event](-1, rules); err == nil {
+		t.Fatalf("expected an error for a negative count")
+	}
+}
+
+func TestGenerate_BadTag(t *testing.T) {
+	type bad struct {
+		N int `fake:"email"`
+	}
+	if _, err := Generate[bad](1, Rules{}); err == nil {
+		t.Fatalf("expected error for email tag on int field")
+	}
+}
+
+func TestGenerateInto(t *testing.T) {
+	coll := NewFakeCollection()
+	if _, err := GenerateInto[generatedUser](context.Background(), coll, 20, Rules{Seed: 1}); err != nil {
+		t.Fatalf("GenerateInto failed: %v", err)
+	}
+	if coll.Len() != 20 {
+		t.Fatalf("collection has %d documents, want 20", coll.Len())
+	}
+}
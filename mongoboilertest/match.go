@@ -0,0 +1,192 @@
+package mongoboilertest
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/anurag925/mongoboiler/internal/bsonutil"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// matches reports whether doc satisfies filter. Supported are implicit equality, $eq, $ne, $in,
+// $nin, $gt, $gte, $lt, $lte, $exists and the $and, $or and $nor combinators.
+func matches(doc, filter bson.Raw) (bool, error) {
+	elems, err := filter.Elements()
+	if err != nil {
+		return false, err
+	}
+	for _, e := range elems {
+		var ok bool
+		switch key := e.Key(); key {
+		case "$and", "$or", "$nor":
+			ok, err = matchCombinator(doc, key, e.Value())
+		default:
+			ok, err = matchField(doc, key, e.Value())
+		}
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func matchCombinator(doc bson.Raw, op string, clauses bson.RawValue) (bool, error) {
+	arr, ok := clauses.ArrayOK()
+	if !ok {
+		return false, fmt.Errorf("mongoboilertest: %s needs an array", op)
+	}
+	values, err := arr.Values()
+	if err != nil {
+		return false, err
+	}
+	for _, v := range values {
+		clause, isDoc := v.DocumentOK()
+		if !isDoc {
+			return false, fmt.Errorf("mongoboilertest: %s needs an array of documents", op)
+		}
+		ok, err := matches(doc, clause)
+		if err != nil {
+			return false, err
+		}
+		switch {
+		case op == "$and" && !ok:
+			return false, nil
+		case op == "$or" && ok:
+			return true, nil
+		case op == "$nor" && ok:
+			return false, nil
+		}
+	}
+	return op != "$or", nil
+}
+
+func matchField(doc bson.Raw, path string, cond bson.RawValue) (bool, error) {
+	val, found := lookup(doc, path)
+
+	ops, isOps := operatorDocument(cond)
+	if !isOps {
+		return equals(val, found, cond), nil
+	}
+	for _, e := range ops {
+		arg := e.Value()
+		var ok bool
+		switch op := e.Key(); op {
+		case "$eq":
+			ok = equals(val, found, arg)
+		case "$ne":
+			ok = !equals(val, found, arg)
+		case "$in", "$nin":
+			arr, isArr := arg.ArrayOK()
+			if !isArr {
+				return false, fmt.Errorf("mongoboilertest: %s needs an array", op)
+			}
+			values, err := arr.Values()
+			if err != nil {
+				return false, err
+			}
+			for _, v := range values {
+				if equals(val, found, v) {
+					ok = true
+					break
+				}
+			}
+			if op == "$nin" {
+				ok = !ok
+			}
+		case "$gt", "$gte", "$lt", "$lte":
+			ok = found && compares(val, arg, op)
+		case "$exists":
+			ok = found == truthy(arg)
+		default:
+			return false, fmt.Errorf("mongoboilertest: unsupported query operator %s", op)
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// truthy reports whether v counts as true where the server expects a boolean, as for
+// {$exists: 1}: false, zero, null and undefined are false, everything else true.
+func truthy(v bson.RawValue) bool {
+	if b, ok := v.BooleanOK(); ok {
+		return b
+	}
+	if bsonutil.IsNumber(v) {
+		return bsonutil.Float(v) != 0
+	}
+	return v.Type != bson.TypeNull && v.Type != bson.TypeUndefined
+}
+
+// operatorDocument returns the elements of v if it is a document of query operators.
+func operatorDocument(v bson.RawValue) ([]bson.RawElement, bool) {
+	doc, ok := v.DocumentOK()
+	if !ok {
+		return nil, false
+	}
+	elems, err := doc.Elements()
+	if err != nil || len(elems) == 0 || !strings.HasPrefix(elems[0].Key(), "$") {
+		return nil, false
+	}
+	return elems, true
+}
+
+// equals implements MongoDB equality: a missing field equals null and an array matches when any
+// of its elements (or the array itself) equals want.
+func equals(val bson.RawValue, found bool, want bson.RawValue) bool {
+	if !found {
+		return want.Type == bson.TypeNull
+	}
+	if bsonutil.Equal(val, want) {
+		return true
+	}
+	if arr, ok := val.ArrayOK(); ok {
+		values, _ := arr.Values()
+		for _, v := range values {
+			if bsonutil.Equal(v, want) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// compares applies a range operator. Like MongoDB only values of comparable types match.
+func compares(val, arg bson.RawValue, op string) bool {
+	if arr, ok := val.ArrayOK(); ok && arg.Type != bson.TypeArray {
+		values, _ := arr.Values()
+		for _, v := range values {
+			if compares(v, arg, op) {
+				return true
+			}
+		}
+		return false
+	}
+	if !sameKind(val, arg) {
+		return false
+	}
+	c := bsonutil.Compare(val, arg)
+	switch op {
+	case "$gt":
+		return c > 0
+	case "$gte":
+		return c >= 0
+	case "$lt":
+		return c < 0
+	}
+	return c <= 0
+}
+
+func sameKind(a, b bson.RawValue) bool {
+	if bsonutil.IsNumber(a) || bsonutil.IsNumber(b) {
+		return bsonutil.IsNumber(a) && bsonutil.IsNumber(b)
+	}
+	return a.Type == b.Type
+}
+
+// lookup resolves a dotted path in doc.
+func lookup(doc bson.Raw, path string) (bson.RawValue, bool) {
+	val, err := doc.LookupErr(strings.Split(path, ".")...)
+	return val, err == nil
+}
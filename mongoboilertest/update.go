@@ -0,0 +1,152 @@
+package mongoboilertest
+
+import (
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// applyUpdate applies the update operators $set, $unset, $inc and $push to doc.
+// $setOnInsert is applied only when inserting is true.
+func applyUpdate(doc bson.D, update bson.D, inserting bool) (bson.D, error) {
+	for _, e := range update {
+		fields, ok := e.Value.(bson.D)
+		if !ok {
+			return nil, fmt.Errorf("mongoboilertest: %s needs a document", e.Key)
+		}
+		for _, f := range fields {
+			path := strings.Split(f.Key, ".")
+			switch e.Key {
+			case "$set":
+				doc = setPath(doc, path, f.Value)
+			case "$setOnInsert":
+				if inserting {
+					doc = setPath(doc, path, f.Value)
+				}
+			case "$unset":
+				doc = unsetPath(doc, path)
+			case "$inc":
+				cur, _ := getPath(doc, path)
+				sum, err := add(cur, f.Value)
+				if err != nil {
+					return nil, fmt.Errorf("mongoboilertest: $inc %s: %w", f.Key, err)
+				}
+				doc = setPath(doc, path, sum)
+			case "$push":
+				cur, _ := getPath(doc, path)
+				arr, _ := cur.(bson.A)
+				arr = append(bson.A(nil), arr...)
+				if each, ok := f.Value.(bson.D); ok && len(each) > 0 && each[0].Key == "$each" {
+					values, _ := each[0].Value.(bson.A)
+					arr = append(arr, values...)
+				} else {
+					arr = append(arr, f.Value)
+				}
+				doc = setPath(doc, path, arr)
+			default:
+				return nil, fmt.Errorf("mongoboilertest: unsupported update operator %s", e.Key)
+			}
+		}
+	}
+	return doc, nil
+}
+
+func getPath(doc bson.D, path []string) (any, bool) {
+	for _, e := range doc {
+		if e.Key != path[0] {
+			continue
+		}
+		if len(path) == 1 {
+			return e.Value, true
+		}
+		sub, ok := e.Value.(bson.D)
+		if !ok {
+			return nil, false
+		}
+		return getPath(sub, path[1:])
+	}
+	return nil, false
+}
+
+func setPath(doc bson.D, path []string, value any) bson.D {
+	out := append(bson.D(nil), doc...)
+	for i, e := range out {
+		if e.Key != path[0] {
+			continue
+		}
+		if len(path) == 1 {
+			out[i].Value = value
+		} else {
+			sub, _ := e.Value.(bson.D)
+			out[i].Value = setPath(sub, path[1:], value)
+		}
+		return out
+	}
+	if len(path) == 1 {
+		return append(out, bson.E{Key: path[0], Value: value})
+	}
+	return append(out, bson.E{Key: path[0], Value: setPath(nil, path[1:], value)})
+}
+
+func unsetPath(doc bson.D, path []string) bson.D {
+	out := make(bson.D, 0, len(doc))
+	for _, e := range doc {
+		if e.Key == path[0] {
+			if len(path) == 1 {
+				continue
+			}
+			if sub, ok := e.Value.(bson.D); ok {
+				e.Value = unsetPath(sub, path[1:])
+			}
+		}
+		out = append(out, e)
+	}
+	return out
+}
+
+// add adds two numbers the way $inc does: integers stay integers unless a double is involved.
+func add(cur, delta any) (any, error) {
+	if cur == nil {
+		return delta, nil
+	}
+	if _, ok := cur.(float64); ok {
+		return toFloat(cur) + toFloat(delta), nil
+	}
+	if _, ok := delta.(float64); ok {
+		return toFloat(cur) + toFloat(delta), nil
+	}
+	a, okA := toInt(cur)
+	b, okB := toInt(delta)
+	if !okA || !okB {
+		return nil, fmt.Errorf("cannot increment %T by %T", cur, delta)
+	}
+	_, curInt32 := cur.(int32)
+	_, deltaInt32 := delta.(int32)
+	if sum := a + b; curInt32 && deltaInt32 && sum == int64(int32(sum)) {
+		return int32(sum), nil
+	}
+	return a + b, nil
+}
+
+func toInt(v any) (int64, bool) {
+	switch n := v.(type) {
+	case int32:
+		return int64(n), true
+	case int64:
+		return n, true
+	}
+	return 0, false
+}
+
+func toFloat(v any) float64 {
+	switch n := v.(type) {
+	case int32:
+		return float64(n)
+	case int64:
+		return float64(n)
+	case float64:
+		return n
+	}
+	return 0
+}
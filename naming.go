@@ -0,0 +1,117 @@
+package mongoboiler
+
+import (
+	"strings"
+	"unicode"
+)
+
+// NameFunc maps the name used in code to the name used on the server.
+type NameFunc func(name string) string
+
+// WithDatabaseNaming resolves database names through fn, e.g. to keep environments sharing
+// a cluster apart. Given to NewCollection it has no effect, the DB's naming is used.
+func WithDatabaseNaming(fn NameFunc) Option {
+	return func(s *settings) {
+		s.databaseNaming = fn
+	}
+}
+
+// WithCollectionNaming resolves collection names through fn.
+func WithCollectionNaming(fn NameFunc) Option {
+	return func(s *settings) {
+		s.collectionNaming = fn
+	}
+}
+
+// WithDatabaseSuffix appends _<env> to database names, so orders becomes orders_staging.
+// An empty env leaves names unchanged.
+func WithDatabaseSuffix(env string) Option {
+	return WithDatabaseNaming(func(name string) string {
+		if env == "" {
+			return name
+		}
+		return name + "_" + env
+	})
+}
+
+// WithModelNaming derives the collection names of models registered without one from their
+// type names through fn, SnakeCasePlural by default. See Register.
+func WithModelNaming(fn NameFunc) Option {
+	return func(s *settings) {
+		s.modelNaming = fn
+	}
+}
+
+// SnakeCase returns name in snake_case, e.g. HTTPRequest becomes http_request.
+func SnakeCase(name string) string {
+	return strings.Join(nameWords(name), "_")
+}
+
+// SnakeCasePlural returns name in snake_case with the last word pluralized, e.g. OrderItem
+// becomes order_items and Category categories.
+func SnakeCasePlural(name string) string {
+	return pluralize(SnakeCase(name))
+}
+
+// CamelCasePlural returns name in lowerCamelCase with the last word pluralized, e.g. OrderItem
+// becomes orderItems.
+func CamelCasePlural(name string) string {
+	words := nameWords(name)
+	for i := 1; i < len(words); i++ {
+		words[i] = strings.ToUpper(words[i][:1]) + words[i][1:]
+	}
+	return pluralize(strings.Join(words, ""))
+}
+
+// nameWords splits a Go identifier into its lower cased words, keeping initialisms together.
+func nameWords(name string) []string {
+	var words []string
+	runes := []rune(name)
+	start := 0
+	for i := 1; i <= len(runes); i++ {
+		boundary := i == len(runes) || runes[i] == '_'
+		if !boundary && unicode.IsUpper(runes[i]) {
+			// A lower case letter or the end of an initialism before the next word starts one.
+			boundary = !unicode.IsUpper(runes[i-1]) ||
+				(i+1 < len(runes) && unicode.IsLower(runes[i+1]))
+		}
+		if !boundary {
+			continue
+		}
+		if word := strings.Trim(string(runes[start:i]), "_"); word != "" {
+			words = append(words, strings.ToLower(word))
+		}
+		start = i
+	}
+	return words
+}
+
+// pluralize applies the regular English plural rules to the end of name.
+func pluralize(name string) string {
+	switch {
+	case name == "":
+		return name
+	case strings.HasSuffix(name, "s") || strings.HasSuffix(name, "x") || strings.HasSuffix(name, "z") ||
+		strings.HasSuffix(name, "ch") || strings.HasSuffix(name, "sh"):
+		return name + "es"
+	case strings.HasSuffix(name, "y") && len(name) > 1 && !strings.ContainsRune("aeiou", rune(name[len(name)-2])):
+		return name[:len(name)-1] + "ies"
+	}
+	return name + "s"
+}
+
+// databaseName returns the server side name of the database.
+func (db *DB) databaseName() string {
+	if db.settings == nil || db.settings.databaseNaming == nil {
+		return db.name
+	}
+	return db.settings.databaseNaming(db.name)
+}
+
+// collectionName returns the server side name of the collection.
+func (c Collection) collectionName() string {
+	if c.settings == nil || c.settings.collectionNaming == nil {
+		return c.name
+	}
+	return c.settings.collectionNaming(c.name)
+}
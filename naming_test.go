@@ -0,0 +1,43 @@
+package mongoboiler
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNaming(t *testing.T) {
+	coll := newTestCollection(t, "orders", WithDatabaseSuffix("staging"), WithCollectionNaming(strings.ToUpper))
+
+	op := coll.newOp(OpFind)
+	if op.Database != "testdb_staging" || op.Collection != "ORDERS" {
+		t.Fatalf("unexpected names %s.%s", op.Database, op.Collection)
+	}
+	if got := op.Target.Database().Name() + "." + op.Target.Name(); got != "testdb_staging.ORDERS" {
+		t.Fatalf("unexpected target %s", got)
+	}
+}
+
+func TestModelNamingStrategies(t *testing.T) {
+	for _, tc := range []struct {
+		name, snake, snakePlural, camelPlural string
+	}{
+		{"User", "user", "users", "users"},
+		{"OrderItem", "order_item", "order_items", "orderItems"},
+		{"HTTPRequest", "http_request", "http_requests", "httpRequests"},
+		{"UserID", "user_id", "user_ids", "userIds"},
+		{"Category", "category", "categories", "categories"},
+		{"Address", "address", "addresses", "addresses"},
+		{"Day", "day", "days", "days"},
+		{"Batch_Job", "batch_job", "batch_jobs", "batchJobs"},
+	} {
+		if got := SnakeCase(tc.name); got != tc.snake {
+			t.Fatalf("SnakeCase(%s) = %s, want %s", tc.name, got, tc.snake)
+		}
+		if got := SnakeCasePlural(tc.name); got != tc.snakePlural {
+			t.Fatalf("SnakeCasePlural(%s) = %s, want %s", tc.name, got, tc.snakePlural)
+		}
+		if got := CamelCasePlural(tc.name); got != tc.camelPlural {
+			t.Fatalf("CamelCasePlural(%s) = %s, want %s", tc.name, got, tc.camelPlural)
+		}
+	}
+}
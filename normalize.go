@@ -0,0 +1,243 @@
+package mongoboiler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Normalizer canonicalizes a string value before it is written, such as a phone number.
+type Normalizer func(s string) (string, error)
+
+// fieldNormalizers are the normalizers of one field, see WithNormalizer.
+type fieldNormalizers struct {
+	path        string
+	normalizers []Normalizer
+}
+
+// WithNormalizer applies normalizers in order to the string values of the field at the dotted
+// path, or of all fields for "*", before every insert, replace and update: the inserted documents
+// and the values of $set, $setOnInsert, $push and $addToSet. Strings nested in the field's
+// documents and arrays are normalized too. Filters and pipeline updates are left unchanged.
+// Normalizers run before other middleware and WithEncryption, so audit logs and hooks see the
+// normalized values and encrypted fields are normalized before they are encrypted.
+func WithNormalizer(path string, normalizers ...Normalizer) Option {
+	return func(s *settings) {
+		s.normalizers = append(append([]fieldNormalizers(nil), s.normalizers...), fieldNormalizers{path, normalizers})
+	}
+}
+
+// NormalizeTrim removes leading and trailing white space.
+func NormalizeTrim() Normalizer {
+	return func(s string) (string, error) {
+		return strings.TrimSpace(s), nil
+	}
+}
+
+// NormalizeLower lowercases, for email addresses and other case-insensitive identifiers.
+func NormalizeLower() Normalizer {
+	return func(s string) (string, error) {
+		return strings.ToLower(s), nil
+	}
+}
+
+// NormalizeStripControl removes control characters other than newlines and tabs.
+func NormalizeStripControl() Normalizer {
+	return func(s string) (string, error) {
+		return strings.Map(func(r rune) rune {
+			if unicode.IsControl(r) && r != '\n' && r != '\t' {
+				return -1
+			}
+			return r
+		}, s), nil
+	}
+}
+
+func normalizeMiddleware(rules []fieldNormalizers) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, op *Operation) error {
+			if !op.Kind.IsWrite() {
+				return next(ctx, op)
+			}
+			if len(op.Documents) > 0 {
+				// Never modify the caller's slice.
+				docs := make([]any, len(op.Documents))
+				for i, doc := range op.Documents {
+					d, err := toDocument(doc)
+					if err != nil {
+						return err
+					}
+					for _, rule := range rules {
+						if d, err = rule.document(d, strings.Split(rule.path, ".")); err != nil {
+							return err
+						}
+					}
+					docs[i] = d
+				}
+				op.Documents = docs
+			}
+			if op.Update != nil {
+				update, err := normalizeUpdate(op.Update, rules)
+				if err != nil {
+					return err
+				}
+				op.Update = update
+			}
+			return next(ctx, op)
+		}
+	}
+}
+
+// document returns d with the values at path normalized.
+func (f fieldNormalizers) document(d bson.D, path []string) (bson.D, error) {
+	if f.path == "*" {
+		v, err := f.all(d)
+		if err != nil {
+			return nil, err
+		}
+		return v.(bson.D), nil
+	}
+	out := make(bson.D, len(d))
+	copy(out, d)
+	for i, e := range out {
+		if e.Key != path[0] {
+			continue
+		}
+		var err error
+		if len(path) == 1 {
+			out[i].Value, err = f.all(e.Value)
+		} else {
+			out[i].Value, err = f.nested(e.Value, path[1:])
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// nested normalizes path below v, descending into arrays like MongoDB's dotted paths do.
+func (f fieldNormalizers) nested(v any, path []string) (any, error) {
+	switch v := v.(type) {
+	case bson.D:
+		return f.document(v, path)
+	case bson.A:
+		out := make(bson.A, len(v))
+		for i, item := range v {
+			var err error
+			if out[i], err = f.nested(item, path); err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	}
+	if d, ok := convertibleDocument(v); ok {
+		return f.document(d, path)
+	}
+	return v, nil
+}
+
+// all normalizes every string in v.
+func (f fieldNormalizers) all(v any) (any, error) {
+	switch v := v.(type) {
+	case string:
+		for _, n := range f.normalizers {
+			var err error
+			if v, err = n(v); err != nil {
+				return nil, fmt.Errorf("mongoboiler: normalizing %s: %w", f.path, err)
+			}
+		}
+		return v, nil
+	case bson.D:
+		out := make(bson.D, len(v))
+		for i, e := range v {
+			out[i].Key = e.Key
+			var err error
+			if out[i].Value, err = f.all(e.Value); err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	case bson.A:
+		return f.all([]any(v))
+	case []any:
+		out := make(bson.A, len(v))
+		for i, item := range v {
+			var err error
+			if out[i], err = f.all(item); err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	case []string:
+		out := make([]string, len(v))
+		for i, s := range v {
+			n, err := f.all(s)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = n.(string)
+		}
+		return out, nil
+	case bson.M:
+		out := make(bson.M, len(v))
+		for k, item := range v {
+			var err error
+			if out[k], err = f.all(item); err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	}
+	if d, ok := convertibleDocument(v); ok {
+		return f.all(d)
+	}
+	return v, nil
+}
+
+// convertibleDocument converts structs and maps nested in a hand-built document to a bson.D.
+func convertibleDocument(v any) (bson.D, bool) {
+	t, _, err := bson.MarshalValue(v)
+	if err != nil || t != bson.TypeEmbeddedDocument {
+		return nil, false
+	}
+	d, err := toDocument(v)
+	return d, err == nil
+}
+
+// normalizeUpdate normalizes the values assigned or added by update.
+func normalizeUpdate(update bson.D, rules []fieldNormalizers) (bson.D, error) {
+	out := make(bson.D, len(update))
+	copy(out, update)
+	for i, op := range out {
+		switch op.Key {
+		case "$set", "$setOnInsert", "$push", "$addToSet":
+		default:
+			continue
+		}
+		fields, err := toDocument(op.Value)
+		if err != nil {
+			return nil, err
+		}
+		fields = copyDocument(fields)
+		for j, field := range fields {
+			for _, rule := range rules {
+				switch {
+				case rule.path == "*" || rule.path == field.Key || strings.HasPrefix(field.Key, rule.path+"."):
+					field.Value, err = rule.all(field.Value)
+				case strings.HasPrefix(rule.path, field.Key+"."):
+					field.Value, err = rule.nested(field.Value, strings.Split(strings.TrimPrefix(rule.path, field.Key+"."), "."))
+				}
+				if err != nil {
+					return nil, err
+				}
+			}
+			fields[j] = field
+		}
+		out[i].Value = fields
+	}
+	return out, nil
+}
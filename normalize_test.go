@@ -0,0 +1,133 @@
+package mongoboiler
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type normalizeContact struct {
+	Email  string   `bson:"email"`
+	Phones []string `bson:"phones"`
+}
+
+type normalizeUser struct {
+	Name     string             `bson:"name"`
+	Contact  normalizeContact   `bson:"contact"`
+	Previous []normalizeContact `bson:"previous"`
+}
+
+func normalizeTestCollection(t *testing.T, seen **Operation, opts ...Option) *Collection {
+	opts = append(opts, WithMiddleware(func(next Handler) Handler {
+		return func(ctx context.Context, op *Operation) error {
+			*seen = op
+			return nil
+		}
+	}))
+	return newTestCollection(t, "users", opts...)
+}
+
+func digitsOnly(s string) (string, error) {
+	out := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, s)
+	if out == "" {
+		return "", errors.New("no digits")
+	}
+	return out, nil
+}
+
+func TestNormalizer_Documents(t *testing.T) {
+	var seen *Operation
+	coll := normalizeTestCollection(t, &seen,
+		WithNormalizer("*", NormalizeTrim(), NormalizeStripControl()),
+		WithNormalizer("contact.email", NormalizeLower()),
+		WithNormalizer("previous.email", NormalizeLower()),
+		WithNormalizer("contact.phones", digitsOnly),
+	)
+	user := normalizeUser{
+		Name:     " Ann\x00 ",
+		Contact:  normalizeContact{Email: " Ann@Example.COM", Phones: []string{"+1 (555) 010"}},
+		Previous: []normalizeContact{{Email: "OLD@example.com"}},
+	}
+	if _, err := coll.InsertOne(context.Background(), user); err != nil {
+		t.Fatalf("InsertOne failed: %v", err)
+	}
+
+	var got normalizeUser
+	data, _ := bson.Marshal(seen.Documents[0])
+	if err := bson.Unmarshal(data, &got); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if got.Name != "Ann" || got.Contact.Email != "ann@example.com" || got.Contact.Phones[0] != "1555010" || got.Previous[0].Email != "old@example.com" {
+		t.Fatalf("unexpected normalized document: %+v", got)
+	}
+	if user.Name != " Ann\x00 " {
+		t.Fatalf("caller's document was modified")
+	}
+}
+
+func TestNormalizer_Update(t *testing.T) {
+	var seen *Operation
+	coll := normalizeTestCollection(t, &seen, WithNormalizer("contact.email", NormalizeTrim(), NormalizeLower()))
+	update := bson.D{
+		{Key: "$set", Value: bson.D{{Key: "contact.email", Value: " A@B.C "}, {Key: "name", Value: " Ann "}}},
+		{Key: "$setOnInsert", Value: bson.D{{Key: "contact", Value: bson.D{{Key: "email", Value: "X@Y.Z"}}}}},
+		{Key: "$inc", Value: bson.D{{Key: "visits", Value: 1}}},
+	}
+	if _, err := coll.UpdateOne(context.Background(), bson.D{{Key: "contact.email", Value: " A@B.C "}}, update); err != nil {
+		t.Fatalf("UpdateOne failed: %v", err)
+	}
+
+	set := seen.Update[0].Value.(bson.D)
+	if set[0].Value != "a@b.c" || set[1].Value != " Ann " {
+		t.Fatalf("unexpected $set: %v", set)
+	}
+	if email := seen.Update[1].Value.(bson.D)[0].Value.(bson.D)[0].Value; email != "x@y.z" {
+		t.Fatalf("unexpected $setOnInsert email %v", email)
+	}
+	if seen.Filter[0].Value != " A@B.C " || update[0].Value.(bson.D)[0].Value != " A@B.C " {
+		t.Fatalf("filter or caller's update was modified")
+	}
+}
+
+func TestNormalizer_Error(t *testing.T) {
+	var seen *Operation
+	coll := normalizeTestCollection(t, &seen, WithNormalizer("contact.phones", digitsOnly))
+	_, err := coll.InsertOne(context.Background(), normalizeUser{Contact: normalizeContact{Phones: []string{"none"}}})
+	if err == nil || !strings.Contains(err.Error(), "normalizing contact.phones") || seen != nil {
+		t.Fatalf("expected the write to fail before middleware, got %v", err)
+	}
+}
+
+func TestNormalizer_BeforeEncryption(t *testing.T) {
+	enc := &Encryption{cipher: reversibleCipher{}, keyAltName: "test"}
+	coll := newTestCollection(t, "patients", WithEncryption(enc), WithEncryptedModel(patient{}),
+		WithNormalizer("ssn", digitsOnly))
+
+	var sent bson.D
+	op := coll.newOp(OpInsertOne)
+	op.Documents = []any{patient{Name: "Ada", SSN: "123-45-6789"}}
+	err := coll.run(context.Background(), op, func(ctx context.Context, op *Operation) error {
+		sent = op.Documents[0].(bson.D)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	ssn, ok := sent.Map()["ssn"].(primitive.Binary)
+	if !ok || !isEncrypted(ssn) {
+		t.Fatalf("expected the ssn to be encrypted, got %v", sent)
+	}
+	plain, err := reversibleCipher{}.Decrypt(context.Background(), ssn)
+	if err != nil || plain.StringValue() != "123456789" {
+		t.Fatalf("expected the ssn to be normalized before encryption, got %v, %v", plain, err)
+	}
+}
@@ -0,0 +1,62 @@
+package mongoboiler
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+// Optional wraps a struct field whose presence in the document matters.
+// The decoder sets Present when the field exists in the document (even when it is null),
+// so an absent field can be told apart from one holding the zero value.
+// Tag Optional fields with omitempty so absent values are left out when marshaling.
+type Optional[T any] struct {
+	Value   T
+	Present bool
+}
+
+// Some returns an Optional holding v.
+func Some[T any](v T) Optional[T] {
+	return Optional[T]{Value: v, Present: true}
+}
+
+// Get returns the value and whether it was present.
+func (o Optional[T]) Get() (T, bool) {
+	return o.Value, o.Present
+}
+
+// IsZero reports whether the field is absent. It is used by the bson omitempty handling.
+func (o Optional[T]) IsZero() bool {
+	return !o.Present
+}
+
+// MarshalBSONValue marshals the wrapped value, or null when absent.
+func (o Optional[T]) MarshalBSONValue() (bsontype.Type, []byte, error) {
+	if !o.Present {
+		return bson.TypeNull, nil, nil
+	}
+	return bson.MarshalValue(o.Value)
+}
+
+// UnmarshalBSONValue is only called for fields that exist in the document, so it marks the value present.
+func (o *Optional[T]) UnmarshalBSONValue(t bsontype.Type, data []byte) error {
+	var zero T
+	o.Value, o.Present = zero, true
+	if t == bson.TypeNull {
+		return nil
+	}
+	return bson.RawValue{Type: t, Value: data}.Unmarshal(&o.Value)
+}
+
+func (o Optional[T]) present() bool {
+	return o.Present
+}
+
+func (o Optional[T]) value() any {
+	return o.Value
+}
+
+// optionalField is implemented by every Optional instantiation.
+type optionalField interface {
+	present() bool
+	value() any
+}
@@ -0,0 +1,67 @@
+package mongoboiler
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type optionalTestDoc struct {
+	Name     string           `bson:"name"`
+	Nickname Optional[string] `bson:"nickname,omitempty"`
+	Age      Optional[int]    `bson:"age,omitempty"`
+}
+
+func TestOptional_Decode(t *testing.T) {
+	raw, err := bson.Marshal(bson.D{{Key: "name", Value: "a"}, {Key: "age", Value: 0}})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+
+	var doc optionalTestDoc
+	if err := bson.Unmarshal(raw, &doc); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	if doc.Nickname.Present {
+		t.Fatalf("nickname should be absent")
+	}
+	if age, ok := doc.Age.Get(); !ok || age != 0 {
+		t.Fatalf("age should be present with zero value, got %v %v", age, ok)
+	}
+}
+
+func TestOptional_MarshalOmitsAbsent(t *testing.T) {
+	data, err := bson.Marshal(optionalTestDoc{Name: "a", Age: Some(3)})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+
+	raw := bson.Raw(data)
+	if _, err := raw.LookupErr("nickname"); err == nil {
+		t.Fatalf("absent nickname should be omitted: %v", raw)
+	}
+	if v := raw.Lookup("age").Int32(); v != 3 {
+		t.Fatalf("age should be 3, got %v", v)
+	}
+}
+
+func TestBuildUpdate(t *testing.T) {
+	update, err := BuildUpdate(&optionalTestDoc{Name: "a", Age: Some(3)})
+	if err != nil {
+		t.Fatalf("BuildUpdate failed: %v", err)
+	}
+
+	want := bson.D{
+		{Key: "$set", Value: bson.D{{Key: "name", Value: "a"}, {Key: "age", Value: 3}}},
+		{Key: "$unset", Value: bson.D{{Key: "nickname", Value: ""}}},
+	}
+	if !reflect.DeepEqual(update, want) {
+		t.Fatalf("unexpected update:\n got %v\nwant %v", update, want)
+	}
+
+	if _, err := BuildUpdate(42); err != ErrNotStruct {
+		t.Fatalf("expected ErrNotStruct, got %v", err)
+	}
+}
@@ -0,0 +1,113 @@
+package mongoboiler
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+	"go.mongodb.org/mongo-driver/tag"
+)
+
+// Option configures the wrapper. Options cascade: those given to New apply to every Collection of
+// the DB, those set with Configure and given to NewCollection apply on top of them for that
+// collection only, and those given to Collection.With on top of those for the calls made through
+// the returned Collection. A later option overrides an earlier one; options adding middleware,
+// hooks and the like add to those of the levels above. See ResolveOptions.
+type Option func(*settings)
+
+// OptionSource is the level an option was given at.
+type OptionSource string
+
+// Option sources, from the outermost level to the innermost.
+const (
+	SourceDefault    OptionSource = "default"
+	SourceDB         OptionSource = "db"
+	SourceConfigure  OptionSource = "configure"
+	SourceCollection OptionSource = "collection"
+	SourceCall       OptionSource = "call"
+)
+
+// optionLayer holds the options given at one level.
+type optionLayer struct {
+	source OptionSource
+	opts   []Option
+}
+
+// settings holds the configuration shared by a DB and its collections.
+type settings struct {
+	middleware []Middleware
+	schema     bson.D
+
+	dropProtection              bool
+	countersCollection          string
+	outboxCollection            string
+	locksCollection             string
+	uniqueValuesCollection      string
+	pseudonymsCollection        string
+	materializedViewsCollection string
+	deletePreviewsCollection    string
+	statsSamplesCollection      string
+	quarantine                  *quarantine
+	decodeMode                  DecodeMode
+	decodeHooks                 []DecodeHook
+	mapDecoding                 MapDecoding
+	escapeKeys                  bool
+	unacknowledged              bool
+	serverCancellation          bool
+	normalizers                 []fieldNormalizers
+	populate                    []string
+	cursorKeepalive             time.Duration
+	resultLimits                *ResultLimits
+	contentHashField            string
+	dryRun                      *dryRun
+	contextComment              *contextComment
+	retry                       *retrier
+	session                     mongo.Session
+	cache                       *queryCache
+	cacheDocuments              bool
+	encryption                  *Encryption
+	encryptedFields             map[string]string
+	readConcern                 *readconcern.ReadConcern
+	writeConcern                *writeconcern.WriteConcern
+	readPreference              *readpref.ReadPref
+	readTags                    []tag.Set
+	zoneTag                     string
+	shardKey                    []string
+
+	databaseNaming   NameFunc
+	collectionNaming NameFunc
+	modelNaming      NameFunc
+
+	// collectionOptions holds the options set by Configure by database and collection name.
+	collectionOptions map[string][]Option
+
+	// layers records the options applied, for ResolveOptions.
+	layers []optionLayer
+}
+
+// newSettings returns the settings of parent with opts given at source applied on top.
+func newSettings(parent *settings, source OptionSource, opts []Option) *settings {
+	s := &settings{}
+	if parent != nil {
+		*s = *parent
+		s.middleware = append([]Middleware(nil), parent.middleware...)
+	}
+	if len(opts) > 0 {
+		s.layers = append(append([]optionLayer(nil), s.layers...), optionLayer{source, opts})
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// WithMiddleware appends middleware to the chain every operation runs through.
+// Middleware registered first runs outermost.
+func WithMiddleware(mw ...Middleware) Option {
+	return func(s *settings) {
+		s.middleware = append(s.middleware, mw...)
+	}
+}
@@ -0,0 +1,285 @@
+package mongoboiler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DefaultOutboxCollection is the collection InsertWithOutbox records events in.
+const DefaultOutboxCollection = "outbox"
+
+// WithOutboxCollection changes the collection InsertWithOutbox and OutboxRelay use.
+func WithOutboxCollection(name string) Option {
+	return func(s *settings) {
+		s.outboxCollection = name
+	}
+}
+
+// OutboxEvent is an event recorded in the outbox, to be published by an OutboxRelay.
+type OutboxEvent struct {
+	ID primitive.ObjectID `bson:"_id,omitempty"`
+	// Topic and Key tell the publisher where to send the event, e.g. a Kafka topic and
+	// partition key.
+	Topic   string            `bson:"topic"`
+	Key     string            `bson:"key,omitempty"`
+	Payload any               `bson:"payload"`
+	Headers map[string]string `bson:"headers,omitempty"`
+
+	// The fields below are maintained by InsertWithOutbox and the relay.
+
+	// Collection and DocumentID identify the document written with the event.
+	Collection    string     `bson:"collection"`
+	DocumentID    any        `bson:"documentId,omitempty"`
+	CreatedAt     time.Time  `bson:"createdAt"`
+	PublishedAt   *time.Time `bson:"publishedAt"`
+	Attempts      int        `bson:"attempts"`
+	NextAttemptAt time.Time  `bson:"nextAttemptAt"`
+	LastError     string     `bson:"lastError,omitempty"`
+	// Failed is set once MaxAttempts were used up, the event is no longer retried.
+	Failed      bool      `bson:"failed,omitempty"`
+	LockedUntil time.Time `bson:"lockedUntil"`
+}
+
+// outbox returns the outbox collection of db.
+func (db *DB) outbox() *Collection {
+	name := DefaultOutboxCollection
+	if db.settings != nil && db.settings.outboxCollection != "" {
+		name = db.settings.outboxCollection
+	}
+	return db.NewCollection(name)
+}
+
+// InsertWithOutbox inserts doc and records event in the outbox in one transaction, so the event
+// is published if and only if the document was written. It needs a deployment supporting
+// transactions; when ctx already carries one, both writes join it.
+func (c Collection) InsertWithOutbox(ctx context.Context, doc any, event OutboxEvent) (InsertResult, error) {
+	outbox := c.db.outbox()
+	if c.settings != nil && c.settings.outboxCollection != "" {
+		outbox = c.db.NewCollection(c.settings.outboxCollection)
+	}
+
+	var res InsertResult
+	write := func(ctx context.Context) error {
+		var err error
+		if res, err = c.InsertOne(ctx, doc); err != nil {
+			return err
+		}
+		record := newOutboxRecord(event, c.name, res.InsertedID, time.Now())
+		_, err = outbox.InsertOne(ctx, record)
+		return err
+	}
+	if mongo.SessionFromContext(ctx) != nil {
+		return res, write(ctx)
+	}
+	return res, c.db.WithTransaction(ctx, write)
+}
+
+func newOutboxRecord(event OutboxEvent, collection string, documentID any, now time.Time) OutboxEvent {
+	now = now.UTC().Truncate(time.Millisecond)
+	if event.ID.IsZero() {
+		event.ID = primitive.NewObjectID()
+	}
+	event.Collection, event.DocumentID = collection, documentID
+	event.CreatedAt, event.NextAttemptAt = now, now
+	event.PublishedAt, event.Attempts, event.LastError, event.Failed = nil, 0, "", false
+	event.LockedUntil = time.Time{}
+	return event
+}
+
+// Publisher delivers an outbox event, e.g. to a message broker. Events are delivered at least
+// once, so consumers should be idempotent, keyed by OutboxEvent.ID.
+type Publisher func(ctx context.Context, event OutboxEvent) error
+
+// OutboxRelay publishes the events recorded by InsertWithOutbox. Several relays may run against
+// the same outbox: each event is leased to one of them while it publishes. Events are claimed
+// oldest first, but ordering is not guaranteed across retries or relays.
+type OutboxRelay struct {
+	// PollInterval is the wait between polls when the outbox is empty, one second by default.
+	PollInterval time.Duration
+	// Lease is how long a claimed event is reserved for this relay, 30 seconds by default. An
+	// event whose relay died before publishing is retried once the lease expired.
+	Lease time.Duration
+	// MinBackoff and MaxBackoff bound the exponential delay between attempts of a failing event,
+	// one second and five minutes by default.
+	MinBackoff, MaxBackoff time.Duration
+	// MaxAttempts marks an event Failed after that many failed attempts, zero retries forever.
+	MaxAttempts int
+	// Retention deletes published events after this long using a TTL index, zero keeps them.
+	Retention time.Duration
+	// Logger receives publish failures, the standard logger if nil.
+	Logger Logger
+
+	outbox  *Collection
+	publish Publisher
+	// modify claims events, a seam for tests.
+	modify func(ctx context.Context, op *Operation, opts *options.FindOneAndUpdateOptions, res any) error
+}
+
+// NewOutboxRelay returns a relay that hands the events of the outbox to publish.
+func (db *DB) NewOutboxRelay(publish Publisher) *OutboxRelay {
+	return &OutboxRelay{outbox: db.outbox(), publish: publish, modify: findOneAndUpdate}
+}
+
+// Run publishes events until ctx is canceled or the DB is closed, returning nil then. Errors
+// reaching the outbox are logged and retried after the poll interval.
+func (r *OutboxRelay) Run(ctx context.Context) error {
+	ctx, done, err := r.outbox.db.conn.workers.start(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+	if err := r.ensureIndexes(ctx); err != nil {
+		return err
+	}
+	for {
+		n, err := r.RelayOnce(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			loggerOrDefault(r.Logger).Printf("mongoboiler: outbox relay: %v", err)
+		}
+		if n > 0 && err == nil {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(r.pollInterval()):
+		}
+	}
+}
+
+// RelayOnce publishes the events that are due and returns how many were attempted.
+func (r *OutboxRelay) RelayOnce(ctx context.Context) (int, error) {
+	n := 0
+	for ctx.Err() == nil {
+		event, ok, err := r.claim(ctx)
+		if err != nil || !ok {
+			return n, err
+		}
+		n++
+		if err := r.deliver(ctx, event); err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+func (r *OutboxRelay) ensureIndexes(ctx context.Context) error {
+	_, err := r.outbox.collection().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "publishedAt", Value: 1}, {Key: "failed", Value: 1}, {Key: "nextAttemptAt", Value: 1}},
+	})
+	if err != nil || r.Retention <= 0 {
+		return err
+	}
+	return r.outbox.EnableTTL(ctx, "publishedAt", r.Retention)
+}
+
+// claim leases the oldest due event to this relay, ok is false if there is none.
+func (r *OutboxRelay) claim(ctx context.Context) (OutboxEvent, bool, error) {
+	now := time.Now().UTC()
+	var event OutboxEvent
+	op := r.outbox.newOp(OpUpdateOne)
+	op.needsEffect = true
+	op.Filter = bson.D{
+		{Key: "publishedAt", Value: nil},
+		{Key: "failed", Value: bson.D{{Key: "$ne", Value: true}}},
+		{Key: "nextAttemptAt", Value: bson.D{{Key: "$lte", Value: now}}},
+		{Key: "lockedUntil", Value: bson.D{{Key: "$lte", Value: now}}},
+	}
+	op.Update = bson.D{{Key: "$set", Value: bson.D{{Key: "lockedUntil", Value: now.Add(r.lease())}}}}
+	err := r.outbox.run(ctx, op, func(ctx context.Context, op *Operation) error {
+		opts := options.FindOneAndUpdate().
+			SetSort(bson.D{{Key: "nextAttemptAt", Value: 1}, {Key: "_id", Value: 1}}).
+			SetReturnDocument(options.After)
+		if op.Comment != "" {
+			opts.SetComment(op.Comment)
+		}
+		if err := r.modify(ctx, op, opts, &event); err != nil {
+			return err
+		}
+		// The sort picked the event, journal and audit the update of that one.
+		op.Filter = bson.D{{Key: "_id", Value: event.ID}}
+		return nil
+	})
+	if errors.Is(err, ErrNotFound) {
+		return event, false, nil
+	}
+	return event, err == nil, err
+}
+
+// deliver publishes a claimed event and records the outcome. The returned error is about
+// recording it, publish failures are retried later.
+func (r *OutboxRelay) deliver(ctx context.Context, event OutboxEvent) error {
+	filter := bson.D{{Key: "_id", Value: event.ID}, {Key: "lockedUntil", Value: event.LockedUntil}}
+	if pubErr := r.publish(ctx, event); pubErr != nil {
+		if ctx.Err() != nil {
+			// Shutting down, the lease expires and another attempt follows.
+			return nil
+		}
+		event.Attempts++
+		loggerOrDefault(r.Logger).Printf("mongoboiler: publishing outbox event %s (attempt %d): %v", event.ID.Hex(), event.Attempts, pubErr)
+		set := bson.D{
+			{Key: "attempts", Value: event.Attempts},
+			{Key: "lastError", Value: pubErr.Error()},
+			{Key: "nextAttemptAt", Value: time.Now().UTC().Add(r.backoff(event.Attempts))},
+			{Key: "lockedUntil", Value: time.Time{}},
+		}
+		if r.MaxAttempts > 0 && event.Attempts >= r.MaxAttempts {
+			set = append(set, bson.E{Key: "failed", Value: true})
+		}
+		_, err := r.outbox.UpdateOne(ctx, filter, bson.D{{Key: "$set", Value: set}})
+		return err
+	}
+
+	_, err := r.outbox.UpdateOne(ctx, filter, bson.D{{Key: "$set", Value: bson.D{
+		{Key: "publishedAt", Value: time.Now().UTC()},
+		{Key: "attempts", Value: event.Attempts + 1},
+		{Key: "lockedUntil", Value: time.Time{}},
+	}}})
+	if err != nil {
+		return fmt.Errorf("recording publication of %s: %w", event.ID.Hex(), err)
+	}
+	return nil
+}
+
+// backoff returns the delay before attempt number attempts+1.
+func (r *OutboxRelay) backoff(attempts int) time.Duration {
+	min, max := r.MinBackoff, r.MaxBackoff
+	if min <= 0 {
+		min = time.Second
+	}
+	if max <= 0 {
+		max = 5 * time.Minute
+	}
+	d := min
+	for i := 1; i < attempts && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	return d
+}
+
+func (r *OutboxRelay) pollInterval() time.Duration {
+	if r.PollInterval <= 0 {
+		return time.Second
+	}
+	return r.PollInterval
+}
+
+func (r *OutboxRelay) lease() time.Duration {
+	if r.Lease <= 0 {
+		return 30 * time.Second
+	}
+	return r.Lease
+}
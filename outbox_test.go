@@ -0,0 +1,166 @@
+package mongoboiler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestOutboxRelay_Backoff(t *testing.T) {
+	r := &OutboxRelay{MinBackoff: time.Second, MaxBackoff: 10 * time.Second}
+	for attempts, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 4: 8 * time.Second, 5: 10 * time.Second, 50: 10 * time.Second} {
+		if got := r.backoff(attempts); got != want {
+			t.Fatalf("backoff(%d) = %s, want %s", attempts, got, want)
+		}
+	}
+}
+
+func TestNewOutboxRecord(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 123456789, time.UTC)
+	published := now
+	event := OutboxEvent{Topic: "orders", Payload: map[string]any{"total": 10}, PublishedAt: &published, Attempts: 3}
+
+	record := newOutboxRecord(event, "orders", primitive.NewObjectID(), now)
+	if record.ID.IsZero() || record.Collection != "orders" || record.DocumentID == nil {
+		t.Fatalf("record not linked to its document: %+v", record)
+	}
+	if record.PublishedAt != nil || record.Attempts != 0 || !record.NextAttemptAt.Equal(now.Truncate(time.Millisecond)) {
+		t.Fatalf("record not reset for delivery: %+v", record)
+	}
+}
+
+// setFields applies the $set of update to the document v points to.
+func setFields(t *testing.T, v any, update bson.D) {
+	t.Helper()
+	doc, err := toDocument(v)
+	if err != nil {
+		t.Fatalf("encoding %T failed: %v", v, err)
+	}
+	for _, set := range update.Map()["$set"].(bson.D) {
+		i := 0
+		for i < len(doc) && doc[i].Key != set.Key {
+			i++
+		}
+		if i < len(doc) {
+			doc[i] = set
+		} else {
+			doc = append(doc, set)
+		}
+	}
+	if err := bson.Unmarshal(mustRaw(t, doc), v); err != nil {
+		t.Fatalf("decoding %T failed: %v", v, err)
+	}
+}
+
+// fakeOutbox keeps the events of an outbox in memory, serving the claims and updates of a relay.
+type fakeOutbox struct {
+	t      *testing.T
+	events []*OutboxEvent
+}
+
+// claim picks the oldest due event that is neither published, failed nor leased.
+func (f *fakeOutbox) claim(ctx context.Context, op *Operation, opts *options.FindOneAndUpdateOptions, res any) error {
+	now := time.Now()
+	var due *OutboxEvent
+	for _, e := range f.events {
+		if e.PublishedAt == nil && !e.Failed && !e.NextAttemptAt.After(now) && !e.LockedUntil.After(now) &&
+			(due == nil || e.NextAttemptAt.Before(due.NextAttemptAt)) {
+			due = e
+		}
+	}
+	if due == nil {
+		return mongo.ErrNoDocuments
+	}
+	setFields(f.t, due, op.Update)
+	*res.(*OutboxEvent) = *due
+	return nil
+}
+
+// middleware applies the updates recording the outcome of deliveries.
+func (f *fakeOutbox) middleware(next Handler) Handler {
+	return func(ctx context.Context, op *Operation) error {
+		if op.Kind != OpUpdateOne || op.needsEffect {
+			return next(ctx, op)
+		}
+		filter := op.Filter.Map()
+		for _, e := range f.events {
+			if e.ID == filter["_id"] && e.LockedUntil.Equal(filter["lockedUntil"].(time.Time)) {
+				setFields(f.t, e, op.Update)
+				op.Result = UpdateResult{MatchedCount: 1, ModifiedCount: 1}
+				return nil
+			}
+		}
+		op.Result = UpdateResult{}
+		return nil
+	}
+}
+
+func TestOutboxRelay_RelayOnce(t *testing.T) {
+	outbox := &fakeOutbox{t: t}
+	coll := newTestCollection(t, "orders", WithMiddleware(outbox.middleware))
+	start := time.Now().Add(-time.Minute)
+	for i, topic := range []string{"created", "failing", "paid"} {
+		event := newOutboxRecord(OutboxEvent{Topic: topic}, "orders", i, start.Add(time.Duration(i)*time.Second))
+		outbox.events = append(outbox.events, &event)
+	}
+	leased := newOutboxRecord(OutboxEvent{Topic: "leased"}, "orders", 3, start)
+	leased.LockedUntil = time.Now().Add(time.Minute)
+	outbox.events = append(outbox.events, &leased)
+
+	var published []string
+	r := coll.db.NewOutboxRelay(func(ctx context.Context, event OutboxEvent) error {
+		published = append(published, event.Topic)
+		if event.Topic == "failing" {
+			return errors.New("broker down")
+		}
+		return nil
+	})
+	r.modify = outbox.claim
+	r.MaxAttempts = 2
+	r.Logger = &recordingLogger{}
+	ctx := context.Background()
+
+	n, err := r.RelayOnce(ctx)
+	if err != nil || n != 3 {
+		t.Fatalf("expected 3 events attempted, got %d, %v", n, err)
+	}
+	if want := "[created failing paid]"; fmt.Sprint(published) != want {
+		t.Fatalf("expected the due events oldest first, got %v", published)
+	}
+	created, failing, paid := outbox.events[0], outbox.events[1], outbox.events[2]
+	for _, e := range []*OutboxEvent{created, paid} {
+		if e.PublishedAt == nil || e.Attempts != 1 || !e.LockedUntil.IsZero() {
+			t.Fatalf("expected %s to be acknowledged, got %+v", e.Topic, e)
+		}
+	}
+	if failing.PublishedAt != nil || failing.Attempts != 1 || failing.LastError != "broker down" || failing.Failed ||
+		!failing.NextAttemptAt.After(time.Now()) || !failing.LockedUntil.IsZero() {
+		t.Fatalf("expected the failed event to be retried later, got %+v", failing)
+	}
+	if leased.PublishedAt != nil || leased.Attempts != 0 {
+		t.Fatalf("expected the event leased to another relay to be left alone, got %+v", leased)
+	}
+
+	published = nil
+	if n, err := r.RelayOnce(ctx); err != nil || n != 0 {
+		t.Fatalf("expected nothing due, got %d, %v", n, err)
+	}
+	failing.NextAttemptAt = time.Now().Add(-time.Second)
+	if n, err := r.RelayOnce(ctx); err != nil || n != 1 {
+		t.Fatalf("expected the retry to be attempted, got %d, %v", n, err)
+	}
+	if failing.Attempts != 2 || !failing.Failed {
+		t.Fatalf("expected the event to fail after MaxAttempts, got %+v", failing)
+	}
+	failing.NextAttemptAt = time.Now().Add(-time.Second)
+	if n, err := r.RelayOnce(ctx); err != nil || n != 0 {
+		t.Fatalf("expected the failed event not to be retried, got %d, %v", n, err)
+	}
+}
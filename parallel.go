@@ -0,0 +1,251 @@
+package mongoboiler
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/anurag925/mongoboiler/internal/bsonutil"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Defaults of FindAllParallel.
+const (
+	DefaultParallelBatchSize = 500
+	// parallelRangesPerWorker is the number of ranges per worker, so that workers finishing
+	// early pick up more work when ranges are uneven.
+	parallelRangesPerWorker = 4
+	// parallelSamplesPerRange is the number of sampled keys the boundaries of a range come from.
+	parallelSamplesPerRange = 20
+)
+
+// ParallelOption configures FindAllParallel.
+type ParallelOption func(*parallelScan)
+
+type parallelScan struct {
+	batchSize       int
+	key             string
+	continueOnError bool
+}
+
+// ParallelBatchSize sets the number of documents passed to fn at once, DefaultParallelBatchSize
+// by default. At most workers batches are held in memory.
+func ParallelBatchSize(n int) ParallelOption {
+	return func(s *parallelScan) {
+		if n > 0 {
+			s.batchSize = n
+		}
+	}
+}
+
+// ParallelSplitKey splits the scan into ranges of field instead of _id, e.g. the shard key. The
+// field should be indexed, every range is a range query on it, and must not hold arrays, whose
+// documents could fall into several ranges.
+func ParallelSplitKey(field string) ParallelOption {
+	return func(s *parallelScan) {
+		s.key = field
+	}
+}
+
+// ParallelContinueOnError scans the remaining ranges when one fails instead of stopping.
+func ParallelContinueOnError() ParallelOption {
+	return func(s *parallelScan) {
+		s.continueOnError = true
+	}
+}
+
+// ParallelError holds the errors of the ranges of FindAllParallel that failed.
+type ParallelError struct {
+	Errors []error
+}
+
+func (e *ParallelError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+	return "mongoboiler: parallel scan: " + strings.Join(msgs, "; ")
+}
+
+// Is reports whether any of the errors matches target.
+func (e *ParallelError) Is(target error) bool {
+	for _, err := range e.Errors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// Code returns the code of the first error of a known class.
+func (e *ParallelError) Code() ErrorCode {
+	for _, err := range e.Errors {
+		if code := ErrorCodeOf(err); code != CodeUnknown {
+			return code
+		}
+	}
+	return CodeUnknown
+}
+
+// FindAllParallel scans the documents of c matching filter with workers concurrent queries,
+// passing them decoded as T to fn in batches. fn is called concurrently, and documents arrive
+// in no particular order. The scan is split into ranges of _id, or the ParallelSplitKey, whose
+// boundaries come from a $sample of the collection; every document is in exactly one range,
+// also when the key is missing or of another type than the sampled ones.
+//
+// Unless ParallelContinueOnError is given the scan stops at the first error. The errors of all
+// failed ranges are returned as a *ParallelError.
+func FindAllParallel[T any](ctx context.Context, c *Collection, filter bson.D, workers int, fn func([]T) error, opts ...ParallelOption) error {
+	scan := parallelScan{batchSize: DefaultParallelBatchSize, key: "_id"}
+	for _, opt := range opts {
+		opt(&scan)
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	var boundaries []bson.RawValue
+	if workers > 1 {
+		var err error
+		if boundaries, err = sampleBoundaries(ctx, c, scan.key, workers*parallelRangesPerWorker); err != nil {
+			return err
+		}
+	}
+	ranges := rangeFilters(filter, scan.key, boundaries)
+	progress, err := c.trackCount(ctx, "scan", filter)
+	if err != nil {
+		return err
+	}
+	defer progress.Finish()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	work := make(chan bson.D, len(ranges))
+	for _, r := range ranges {
+		work <- r
+	}
+	close(work)
+
+	var mu sync.Mutex
+	var errs []error
+	var wg sync.WaitGroup
+	for i := 0; i < workers && i < len(ranges); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for r := range work {
+				if ctx.Err() != nil {
+					return
+				}
+				if err := scanRange(ctx, c, r, scan.batchSize, progress, fn); err != nil {
+					mu.Lock()
+					// Ranges stopped by the cancellation below are not failures of their own.
+					if !(errors.Is(err, context.Canceled) && len(errs) > 0) {
+						errs = append(errs, err)
+					}
+					mu.Unlock()
+					if !scan.continueOnError {
+						cancel()
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if len(errs) > 0 {
+		return &ParallelError{Errors: errs}
+	}
+	return nil
+}
+
+// scanRange passes the documents matching filter to fn in batches.
+func scanRange[T any](ctx context.Context, c *Collection, filter bson.D, batchSize int, progress *ProgressTracker, fn func([]T) error) error {
+	batch := make([]T, 0, batchSize)
+	err := c.FindEach(ctx, filter, func(dec Decoder) error {
+		var doc T
+		if err := dec.Decode(&doc); err != nil {
+			return err
+		}
+		if batch = append(batch, doc); len(batch) < batchSize {
+			return nil
+		}
+		err := fn(batch)
+		progress.Add(int64(len(batch)))
+		// fn may keep the batch.
+		batch = make([]T, 0, batchSize)
+		return err
+	})
+	if err == nil && len(batch) > 0 {
+		err = fn(batch)
+		progress.Add(int64(len(batch)))
+	}
+	return err
+}
+
+// sampleBoundaries returns up to n-1 sorted, distinct values of key splitting a $sample of the
+// collection into n ranges of about equal size. Only values of the most common type are used.
+func sampleBoundaries(ctx context.Context, c *Collection, key string, n int) ([]bson.RawValue, error) {
+	var docs []bson.Raw
+	err := c.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$sample", Value: bson.D{{Key: "size", Value: n * parallelSamplesPerRange}}}},
+		{{Key: "$project", Value: bson.D{{Key: key, Value: 1}}}},
+	}, &docs)
+	if err != nil {
+		return nil, err
+	}
+	return boundariesOf(docs, key, n), nil
+}
+
+func boundariesOf(docs []bson.Raw, key string, n int) []bson.RawValue {
+	path := strings.Split(key, ".")
+	byType := map[bsontype.Type][]bson.RawValue{}
+	var common bsontype.Type
+	for _, doc := range docs {
+		v, err := doc.LookupErr(path...)
+		if err != nil || v.Type == bson.TypeNull || v.Type == bson.TypeArray {
+			continue
+		}
+		byType[v.Type] = append(byType[v.Type], v)
+		if len(byType[v.Type]) > len(byType[common]) {
+			common = v.Type
+		}
+	}
+	values := byType[common]
+	sort.Slice(values, func(i, j int) bool { return bsonutil.Compare(values[i], values[j]) < 0 })
+
+	var out []bson.RawValue
+	for i := 1; i < n && len(values) > 0; i++ {
+		v := values[i*len(values)/n]
+		if len(out) == 0 || bsonutil.Compare(out[len(out)-1], v) < 0 {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// rangeFilters returns filters covering the documents matching filter in the ranges between
+// boundaries. The first range takes everything not in the others, whatever the type of key.
+func rangeFilters(filter bson.D, key string, boundaries []bson.RawValue) []bson.D {
+	if len(boundaries) == 0 {
+		return []bson.D{nonNilFilter(filter)}
+	}
+	and := func(cond bson.D) bson.D {
+		if len(filter) == 0 {
+			return bson.D{{Key: key, Value: cond}}
+		}
+		return bson.D{{Key: "$and", Value: bson.A{filter, bson.D{{Key: key, Value: cond}}}}}
+	}
+	out := []bson.D{and(bson.D{{Key: "$not", Value: bson.D{{Key: "$gte", Value: boundaries[0]}}}})}
+	for i, lo := range boundaries {
+		cond := bson.D{{Key: "$gte", Value: lo}}
+		if i+1 < len(boundaries) {
+			cond = append(cond, bson.E{Key: "$lt", Value: boundaries[i+1]})
+		}
+		out = append(out, and(cond))
+	}
+	return out
+}
@@ -0,0 +1,98 @@
+package mongoboiler
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestBoundariesOf(t *testing.T) {
+	var docs []bson.Raw
+	for i := 99; i >= 0; i-- {
+		docs = append(docs, mustRaw(t, bson.D{{Key: "_id", Value: i}}))
+	}
+	docs = append(docs, mustRaw(t, bson.D{{Key: "_id", Value: "odd"}}), mustRaw(t, bson.D{{Key: "other", Value: 1}}))
+
+	got := boundariesOf(docs, "_id", 4)
+	var ints []int32
+	for _, v := range got {
+		ints = append(ints, v.Int32())
+	}
+	if !reflect.DeepEqual(ints, []int32{25, 50, 75}) {
+		t.Fatalf("got boundaries %v, want [25 50 75]", ints)
+	}
+	if got := boundariesOf(nil, "_id", 4); len(got) != 0 {
+		t.Fatalf("expected no boundaries without samples, got %v", got)
+	}
+}
+
+func TestRangeFilters(t *testing.T) {
+	b := boundariesOf([]bson.Raw{mustRaw(t, bson.D{{Key: "k", Value: 1}}), mustRaw(t, bson.D{{Key: "k", Value: 2}})}, "k", 2)
+	filter := bson.D{{Key: "active", Value: true}}
+	got := rangeFilters(filter, "k", b)
+	want := []bson.D{
+		{{Key: "$and", Value: bson.A{filter, bson.D{{Key: "k", Value: bson.D{{Key: "$not", Value: bson.D{{Key: "$gte", Value: b[0]}}}}}}}}},
+		{{Key: "$and", Value: bson.A{filter, bson.D{{Key: "k", Value: bson.D{{Key: "$gte", Value: b[0]}}}}}}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got := rangeFilters(nil, "k", nil); len(got) != 1 || got[0] == nil {
+		t.Fatalf("expected one range matching everything, got %v", got)
+	}
+}
+
+func parallelTestCollection(t *testing.T, n int) *Collection {
+	coll := newTestCollection(t, "items", WithCache(NewLRUCache(100), 0), WithMiddleware(func(next Handler) Handler {
+		return func(ctx context.Context, op *Operation) error {
+			if op.Kind == OpAggregate {
+				return nil // no samples, so a single range
+			}
+			return next(ctx, op)
+		}
+	}))
+	docs := make([]bson.D, n)
+	for i := range docs {
+		docs[i] = bson.D{{Key: "name", Value: "item"}, {Key: "qty", Value: i}}
+	}
+	primeFind(t, coll, bson.D{}, docs...)
+	return coll
+}
+
+func TestFindAllParallel_Batches(t *testing.T) {
+	coll := parallelTestCollection(t, 5)
+	var mu sync.Mutex
+	var sizes []int
+	total := 0
+	err := FindAllParallel(context.Background(), coll, nil, 3, func(batch []decodeItem) error {
+		mu.Lock()
+		defer mu.Unlock()
+		sizes = append(sizes, len(batch))
+		for _, item := range batch {
+			total += item.Qty
+		}
+		return nil
+	}, ParallelBatchSize(2))
+	if err != nil {
+		t.Fatalf("FindAllParallel failed: %v", err)
+	}
+	if !reflect.DeepEqual(sizes, []int{2, 2, 1}) || total != 10 {
+		t.Fatalf("got batches %v with total %d", sizes, total)
+	}
+}
+
+func TestFindAllParallel_Error(t *testing.T) {
+	coll := parallelTestCollection(t, 3)
+	boom := errors.New("boom")
+	err := FindAllParallel(context.Background(), coll, nil, 2, func(batch []decodeItem) error {
+		return boom
+	}, ParallelBatchSize(1))
+	var perr *ParallelError
+	if !errors.As(err, &perr) || !errors.Is(err, boom) || len(perr.Errors) != 1 {
+		t.Fatalf("expected a ParallelError wrapping boom, got %v", err)
+	}
+}
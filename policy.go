@@ -0,0 +1,103 @@
+package mongoboiler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrOperationDenied matches every PolicyError with errors.Is.
+var ErrOperationDenied = errors.New("mongoboiler: operation denied by policy")
+
+// PolicyError is returned when a Policy forbids an operation.
+type PolicyError struct {
+	Policy     string
+	Op         OpKind
+	Database   string
+	Collection string
+}
+
+func (e *PolicyError) Error() string {
+	return fmt.Sprintf("mongoboiler: %s on %s.%s denied by policy %q", e.Op, e.Database, e.Collection, e.Policy)
+}
+
+// Is makes errors.Is(err, ErrOperationDenied) true.
+func (e *PolicyError) Is(target error) bool {
+	return target == ErrOperationDenied
+}
+
+// Code returns CodePreconditionFailed.
+func (e *PolicyError) Code() ErrorCode {
+	return CodePreconditionFailed
+}
+
+// Policy restricts the operations allowed on a DB or Collection, typically chosen per
+// environment:
+//
+//	policies := map[string]mongoboiler.Policy{
+//		"production": {Name: "production", Deny: []mongoboiler.OpKind{mongoboiler.OpDrop, mongoboiler.OpDeleteMany}},
+//		"dr-replica": {Name: "dr-replica", ReadOnly: true},
+//	}
+//	db := mongoboiler.New(client, "orders", mongoboiler.WithPolicy(policies[env]))
+type Policy struct {
+	// Name identifies the policy in errors, e.g. the environment.
+	Name string
+	// Allow, when not empty, lists the only operations permitted.
+	Allow []OpKind
+	// Deny lists forbidden operations, it wins over Allow.
+	Deny []OpKind
+	// ReadOnly forbids every write, including aggregations ending in $out or $merge.
+	ReadOnly bool
+}
+
+// Allows reports whether the policy permits operations of kind.
+func (p Policy) Allows(kind OpKind) bool {
+	if p.ReadOnly && kind.IsWrite() {
+		return false
+	}
+	for _, k := range p.Deny {
+		if k == kind {
+			return false
+		}
+	}
+	if len(p.Allow) == 0 {
+		return true
+	}
+	for _, k := range p.Allow {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// WithPolicy enforces p on every operation. Denied operations fail with a *PolicyError
+// before reaching the server.
+//
+// Only the operations of Collection go through the policy. Writes the package sends straight to
+// the driver do not: CreateCollection, ApplyValidator, the index creation of the Ensure methods,
+// the updates of TwoPhaseCommitter.Apply, quarantined documents of WithQuarantine, the writes
+// of JournalReplayer.Replay and the samples stored by StatsSampler.Sample.
+func WithPolicy(p Policy) Option {
+	return WithMiddleware(p.middleware)
+}
+
+func (p Policy) middleware(next Handler) Handler {
+	return func(ctx context.Context, op *Operation) error {
+		if !p.Allows(op.Kind) || p.ReadOnly && op.Kind == OpAggregate && pipelineWrites(op.Pipeline) {
+			return &PolicyError{Policy: p.Name, Op: op.Kind, Database: op.Database, Collection: op.Collection}
+		}
+		return next(ctx, op)
+	}
+}
+
+// pipelineWrites reports whether pipeline ends in an $out or $merge stage.
+func pipelineWrites(pipeline mongo.Pipeline) bool {
+	if len(pipeline) == 0 {
+		return false
+	}
+	last := pipeline[len(pipeline)-1]
+	return len(last) > 0 && (last[0].Key == "$out" || last[0].Key == "$merge")
+}
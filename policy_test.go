@@ -0,0 +1,66 @@
+package mongoboiler
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestPolicy_Allows(t *testing.T) {
+	tests := []struct {
+		policy Policy
+		kind   OpKind
+		want   bool
+	}{
+		{Policy{}, OpDrop, true},
+		{Policy{Deny: []OpKind{OpDrop}}, OpDrop, false},
+		{Policy{Deny: []OpKind{OpDrop}}, OpDeleteOne, true},
+		{Policy{ReadOnly: true}, OpFind, true},
+		{Policy{ReadOnly: true}, OpInsertOne, false},
+		{Policy{Allow: []OpKind{OpFind, OpFindOne}}, OpUpdateOne, false},
+		{Policy{Allow: []OpKind{OpFind}, Deny: []OpKind{OpFind}}, OpFind, false},
+	}
+	for _, tt := range tests {
+		if got := tt.policy.Allows(tt.kind); got != tt.want {
+			t.Fatalf("%+v.Allows(%s) = %v, want %v", tt.policy, tt.kind, got, tt.want)
+		}
+	}
+}
+
+func TestWithPolicy_DeniesBeforeServer(t *testing.T) {
+	coll := newTestCollection(t, "orders", WithPolicy(Policy{Name: "production", Deny: []OpKind{OpDeleteMany}}))
+
+	_, err := coll.DeleteMany(context.Background(), bson.D{})
+	var policyErr *PolicyError
+	if !errors.As(err, &policyErr) || !errors.Is(err, ErrOperationDenied) {
+		t.Fatalf("expected a policy error, got %v", err)
+	}
+	if policyErr.Op != OpDeleteMany || policyErr.Collection != "orders" || policyErr.Policy != "production" {
+		t.Fatalf("unexpected policy error: %+v", policyErr)
+	}
+}
+
+func TestWithPolicy_ReadOnlyDeniesWritingPipelines(t *testing.T) {
+	coll := newTestCollection(t, "orders", WithPolicy(Policy{Name: "dr-replica", ReadOnly: true}))
+	reached := 0
+	handler := func(context.Context, *Operation) error {
+		reached++
+		return nil
+	}
+
+	for _, stage := range []string{"$out", "$merge"} {
+		op := coll.newOp(OpAggregate)
+		op.Pipeline = mongo.Pipeline{{{Key: "$match", Value: bson.D{}}}, {{Key: stage, Value: "archive"}}}
+		if err := coll.run(context.Background(), op, handler); !errors.Is(err, ErrOperationDenied) {
+			t.Fatalf("expected a pipeline ending in %s to be denied, got %v", stage, err)
+		}
+	}
+	op := coll.newOp(OpAggregate)
+	op.Pipeline = mongo.Pipeline{{{Key: "$match", Value: bson.D{}}}}
+	if err := coll.run(context.Background(), op, handler); err != nil || reached != 1 {
+		t.Fatalf("expected reading pipelines to run, got %v", err)
+	}
+}
@@ -0,0 +1,267 @@
+package mongoboiler
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsoncodec"
+)
+
+// populateBatchSize is the number of IDs fetched per query by populate.
+const populateBatchSize = 1000
+
+// WithPopulate makes FindOne and FindMany resolve the references at the dotted BSON paths after
+// decoding. A reference is a field tagged with the collection it refers to, holding the _id of
+// a document there or a slice of them; the referenced documents are decoded into the field of
+// the same struct tagged `populate:"<bson name of the reference>"`, a struct, pointer or slice:
+//
+//	type Comment struct {
+//		UserID primitive.ObjectID `bson:"user" ref:"users"`
+//		User   *User              `bson:"-" populate:"user"`
+//	}
+//
+//	type Post struct {
+//		AuthorID primitive.ObjectID `bson:"author" ref:"users"`
+//		Author   User               `bson:"-" populate:"author"`
+//		Comments []Comment          `bson:"comments"`
+//	}
+//
+//	db.NewCollection("posts", WithPopulate("author", "comments.user"))
+//
+// Every path is resolved with batched $in queries on the referenced collection, one per batch of
+// IDs for all results of FindMany. The segments before the last one must be embedded documents
+// or arrays of them. Referenced documents that do not exist leave the field zero, or are left out
+// of slices.
+func WithPopulate(paths ...string) Option {
+	return func(s *settings) {
+		s.populate = append(append([]string(nil), s.populate...), paths...)
+	}
+}
+
+// populate resolves the configured references of v, a struct or slice of structs.
+func (c Collection) populate(ctx context.Context, v reflect.Value) error {
+	if c.settings == nil || len(c.settings.populate) == 0 {
+		return nil
+	}
+	for _, path := range c.settings.populate {
+		if err := c.populatePath(ctx, v, strings.Split(path, ".")); err != nil {
+			return fmt.Errorf("mongoboiler: populate %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// populateRef is a reference found in a result.
+type populateRef struct {
+	ids    []any
+	target reflect.Value
+}
+
+func (c Collection) populatePath(ctx context.Context, v reflect.Value, path []string) error {
+	holders := structsIn(v, nil)
+	for _, name := range path[:len(path)-1] {
+		var next []reflect.Value
+		for _, h := range holders {
+			f, _, ok, err := fieldByBSONName(h, name)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return fmt.Errorf("%s has no field %s", h.Type(), name)
+			}
+			next = structsIn(f, next)
+		}
+		holders = next
+	}
+
+	name := path[len(path)-1]
+	var collection string
+	var refs []populateRef
+	for _, h := range holders {
+		f, sf, ok, err := fieldByBSONName(h, name)
+		if err != nil {
+			return err
+		}
+		if !ok || sf.Tag.Get("ref") == "" {
+			return fmt.Errorf("%s has no field %s tagged ref", h.Type(), name)
+		}
+		collection = sf.Tag.Get("ref")
+		target, ok := populateTarget(h, name)
+		if !ok {
+			return fmt.Errorf("%s has no field tagged populate:%q", h.Type(), name)
+		}
+		ref := populateRef{target: target}
+		if f.Kind() == reflect.Slice && f.Type().Elem().Kind() != reflect.Uint8 {
+			for i := 0; i < f.Len(); i++ {
+				ref.ids = append(ref.ids, f.Index(i).Interface())
+			}
+		} else if !f.IsZero() {
+			ref.ids = []any{f.Interface()}
+		}
+		refs = append(refs, ref)
+	}
+	if len(refs) == 0 {
+		return nil
+	}
+
+	elemType := refs[0].target.Type()
+	for elemType.Kind() == reflect.Ptr || elemType.Kind() == reflect.Slice {
+		elemType = elemType.Elem()
+	}
+	docs, err := c.fetchByID(ctx, c.db.NewCollection(collection), refs, elemType)
+	if err != nil {
+		return err
+	}
+	for _, ref := range refs {
+		setPopulated(ref, docs)
+	}
+	return nil
+}
+
+// fetchByID fetches the documents with the IDs of refs, decoded into t and keyed by idKey.
+func (c Collection) fetchByID(ctx context.Context, coll *Collection, refs []populateRef, t reflect.Type) (map[string]reflect.Value, error) {
+	seen := map[string]bool{}
+	var ids bson.A
+	for _, ref := range refs {
+		for _, id := range ref.ids {
+			key, err := idKey(id)
+			if err != nil {
+				return nil, err
+			}
+			if !seen[key] {
+				seen[key] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+
+	docs := map[string]reflect.Value{}
+	for start := 0; start < len(ids); start += populateBatchSize {
+		end := start + populateBatchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		filter := bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: ids[start:end]}}}}
+		err := coll.FindEach(ctx, filter, func(dec Decoder) error {
+			var raw bson.Raw
+			if err := dec.Decode(&raw); err != nil {
+				return err
+			}
+			doc := reflect.New(t)
+			if err := dec.Decode(doc.Interface()); err != nil {
+				return err
+			}
+			id := raw.Lookup("_id")
+			docs[string(id.Type)+string(id.Value)] = doc.Elem()
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return docs, nil
+}
+
+// idKey returns the key of id in the result of fetchByID.
+func idKey(id any) (string, error) {
+	t, data, err := bson.MarshalValue(id)
+	if err != nil {
+		return "", err
+	}
+	return string(t) + string(data), nil
+}
+
+// setPopulated fills the target of ref with the fetched docs.
+func setPopulated(ref populateRef, docs map[string]reflect.Value) {
+	target := ref.target
+	var found []reflect.Value
+	for _, id := range ref.ids {
+		key, _ := idKey(id)
+		if doc, ok := docs[key]; ok {
+			found = append(found, doc)
+		}
+	}
+
+	if target.Kind() == reflect.Slice {
+		out := reflect.MakeSlice(target.Type(), 0, len(found))
+		for _, doc := range found {
+			out = reflect.Append(out, asType(doc, target.Type().Elem()))
+		}
+		target.Set(out)
+		return
+	}
+	if len(found) == 0 {
+		target.Set(reflect.Zero(target.Type()))
+		return
+	}
+	target.Set(asType(found[0], target.Type()))
+}
+
+// asType returns the struct doc as t, the struct type or a pointer to it.
+func asType(doc reflect.Value, t reflect.Type) reflect.Value {
+	if t.Kind() != reflect.Ptr {
+		return doc
+	}
+	p := reflect.New(doc.Type())
+	p.Elem().Set(doc)
+	return p
+}
+
+// structsIn appends the structs v holds, directly, through pointers or as slice elements.
+func structsIn(v reflect.Value, out []reflect.Value) []reflect.Value {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			out = structsIn(v.Elem(), out)
+		}
+	case reflect.Struct:
+		out = append(out, v)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			out = structsIn(v.Index(i), out)
+		}
+	}
+	return out
+}
+
+// fieldByBSONName returns the field of struct v stored under name, also in inline structs.
+func fieldByBSONName(v reflect.Value, name string) (reflect.Value, reflect.StructField, bool, error) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		tags, err := bsoncodec.DefaultStructTagParser(sf)
+		if err != nil {
+			return reflect.Value{}, sf, false, err
+		}
+		if tags.Skip {
+			continue
+		}
+		if tags.Inline && sf.Type.Kind() == reflect.Struct {
+			if f, fsf, ok, err := fieldByBSONName(v.Field(i), name); ok || err != nil {
+				return f, fsf, ok, err
+			}
+			continue
+		}
+		if tags.Name == name {
+			return v.Field(i), sf, true, nil
+		}
+	}
+	return reflect.Value{}, reflect.StructField{}, false, nil
+}
+
+// populateTarget returns the field of struct v tagged populate:name.
+func populateTarget(v reflect.Value, name string) (reflect.Value, bool) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if sf := t.Field(i); sf.IsExported() && sf.Tag.Get("populate") == name {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
@@ -0,0 +1,89 @@
+package mongoboiler
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type populateUser struct {
+	ID   primitive.ObjectID `bson:"_id"`
+	Name string             `bson:"name"`
+}
+
+type populateComment struct {
+	UserID primitive.ObjectID `bson:"user" ref:"users"`
+	User   *populateUser      `bson:"-" populate:"user"`
+}
+
+type populatePost struct {
+	Title     string               `bson:"title"`
+	AuthorID  primitive.ObjectID   `bson:"author" ref:"users"`
+	Author    populateUser         `bson:"-" populate:"author"`
+	EditorIDs []primitive.ObjectID `bson:"editors" ref:"users"`
+	Editors   []*populateUser      `bson:"-" populate:"editors"`
+	Comments  []populateComment    `bson:"comments"`
+}
+
+// primeFind caches docs as the result of FindEach on coll with filter.
+func primeFind(t *testing.T, coll *Collection, filter bson.D, docs ...bson.D) {
+	t.Helper()
+	op := coll.newOp(OpFind)
+	op.Filter = filter
+	key, ok := coll.readCache().key(context.Background(), op, []*options.FindOptions(nil))
+	if !ok {
+		t.Fatalf("expected the read to be cacheable")
+	}
+	raws := make([]bson.Raw, len(docs))
+	for i, doc := range docs {
+		raws[i] = mustRaw(t, doc)
+	}
+	coll.readCache().set(context.Background(), key, raws)
+}
+
+func TestPopulate_FindMany(t *testing.T) {
+	ann, bob, gone := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	posts := newTestCollection(t, "posts", WithCache(NewLRUCache(100), 0))
+	posts = posts.db.NewCollection("posts", WithPopulate("author", "editors", "comments.user"))
+	users := posts.db.NewCollection("users")
+
+	primeFind(t, posts, bson.D{},
+		bson.D{{Key: "title", Value: "a"}, {Key: "author", Value: ann}, {Key: "editors", Value: bson.A{bob, gone, ann}},
+			{Key: "comments", Value: bson.A{bson.D{{Key: "user", Value: bob}}, bson.D{{Key: "user", Value: gone}}}}},
+		bson.D{{Key: "title", Value: "b"}, {Key: "author", Value: bob}},
+	)
+	annDoc := bson.D{{Key: "_id", Value: ann}, {Key: "name", Value: "Ann"}}
+	bobDoc := bson.D{{Key: "_id", Value: bob}, {Key: "name", Value: "Bob"}}
+	primeFind(t, users, bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: bson.A{ann, bob}}}}}, annDoc, bobDoc)
+	primeFind(t, users, bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: bson.A{bob, gone, ann}}}}}, annDoc, bobDoc)
+	primeFind(t, users, bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: bson.A{bob, gone}}}}}, bobDoc)
+
+	var got []populatePost
+	if err := posts.FindMany(context.Background(), bson.D{}, &got); err != nil {
+		t.Fatalf("FindMany failed: %v", err)
+	}
+	if len(got) != 2 || got[0].Author.Name != "Ann" || got[1].Author.Name != "Bob" {
+		t.Fatalf("authors not populated: %+v", got)
+	}
+	if e := got[0].Editors; len(e) != 2 || e[0].Name != "Bob" || e[1].Name != "Ann" {
+		t.Fatalf("editors not populated in order: %+v", e)
+	}
+	if c := got[0].Comments; c[0].User == nil || c[0].User.Name != "Bob" || c[1].User != nil {
+		t.Fatalf("comment users not populated: %+v", c)
+	}
+}
+
+func TestPopulate_InvalidPath(t *testing.T) {
+	posts := newTestCollection(t, "posts", WithCache(NewLRUCache(100), 0), WithPopulate("title"))
+	primeFind(t, posts, bson.D{}, bson.D{{Key: "title", Value: "a"}})
+
+	var got []populatePost
+	err := posts.FindMany(context.Background(), bson.D{}, &got)
+	if err == nil || !strings.Contains(err.Error(), "populate title") {
+		t.Fatalf("expected an error for a path without ref, got %v", err)
+	}
+}
@@ -0,0 +1,181 @@
+package mongoboiler
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DefaultProgressInterval is the minimum time between two reports of a ProgressTracker.
+const DefaultProgressInterval = time.Second
+
+// ProgressUpdate is the state of a long-running operation.
+type ProgressUpdate struct {
+	// Operation names the operation, e.g. "export users".
+	Operation string
+	// Done counts the documents processed so far.
+	Done int64
+	// Total is the number of documents to process, zero when unknown.
+	Total int64
+	// Rate is the number of documents processed per second so far.
+	Rate    float64
+	Elapsed time.Duration
+	// ETA estimates the time remaining, zero when Total is unknown.
+	ETA time.Duration
+	// Finished is set on the last update, also when the operation failed.
+	Finished bool
+}
+
+// Percent returns Done as a percentage of Total, zero when Total is unknown.
+func (u ProgressUpdate) Percent() float64 {
+	if u.Total <= 0 {
+		return 0
+	}
+	return 100 * float64(u.Done) / float64(u.Total)
+}
+
+// Progress receives the updates of long-running operations, see ContextWithProgress. Updates
+// of concurrent workers are serialized.
+type Progress interface {
+	Report(u ProgressUpdate)
+}
+
+// ProgressFunc adapts a function to Progress.
+type ProgressFunc func(u ProgressUpdate)
+
+// Report calls f(u).
+func (f ProgressFunc) Report(u ProgressUpdate) {
+	f(u)
+}
+
+type progressKey struct{}
+
+// ContextWithProgress returns a context making long-running operations report to p: Export,
+// ExportCSV, Import, ImportCSV and FindAllParallel, and custom jobs using TrackProgress. Updates
+// are sent at most every DefaultProgressInterval and once when the operation finishes.
+func ContextWithProgress(ctx context.Context, p Progress) context.Context {
+	return context.WithValue(ctx, progressKey{}, p)
+}
+
+// ProgressFromContext returns the Progress stored by ContextWithProgress.
+func ProgressFromContext(ctx context.Context) (Progress, bool) {
+	p, ok := ctx.Value(progressKey{}).(Progress)
+	return p, ok
+}
+
+// ProgressTracker computes and reports the progress of an operation. A nil *ProgressTracker,
+// returned when ctx has no Progress, ignores all calls.
+type ProgressTracker struct {
+	progress  Progress
+	operation string
+	interval  time.Duration
+	now       func() time.Time
+
+	mu       sync.Mutex
+	total    int64
+	done     int64
+	start    time.Time
+	reported time.Time
+	finished bool
+}
+
+// TrackProgress starts tracking operation for the Progress of ctx, total is the number of
+// documents to process or zero if unknown. It returns nil without a Progress.
+func TrackProgress(ctx context.Context, operation string, total int64) *ProgressTracker {
+	p, ok := ProgressFromContext(ctx)
+	if !ok {
+		return nil
+	}
+	return newProgressTracker(p, operation, total, time.Now)
+}
+
+func newProgressTracker(p Progress, operation string, total int64, now func() time.Time) *ProgressTracker {
+	start := now()
+	return &ProgressTracker{progress: p, operation: operation, interval: DefaultProgressInterval, now: now, total: total, start: start, reported: start}
+}
+
+// Add records n more processed documents, reporting if the interval passed.
+func (t *ProgressTracker) Add(n int64) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.done += n
+	if now := t.now(); !t.finished && now.Sub(t.reported) >= t.interval {
+		t.reported = now
+		t.progress.Report(t.update(now))
+	}
+}
+
+// SetTotal changes the number of documents to process, e.g. once it was counted.
+func (t *ProgressTracker) SetTotal(total int64) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.total = total
+	t.mu.Unlock()
+}
+
+// Finish sends the final update, later calls do nothing.
+func (t *ProgressTracker) Finish() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.finished {
+		return
+	}
+	t.finished = true
+	u := t.update(t.now())
+	u.Finished, u.ETA = true, 0
+	t.progress.Report(u)
+}
+
+func (t *ProgressTracker) update(now time.Time) ProgressUpdate {
+	u := ProgressUpdate{Operation: t.operation, Done: t.done, Total: t.total, Elapsed: now.Sub(t.start)}
+	if secs := u.Elapsed.Seconds(); secs > 0 {
+		u.Rate = float64(t.done) / secs
+	}
+	if u.Rate > 0 && t.total > t.done {
+		u.ETA = time.Duration(float64(t.total-t.done) / u.Rate * float64(time.Second))
+	}
+	return u
+}
+
+// trackCount starts tracking operation on c, counting the documents matching filter as total
+// when ctx has a Progress.
+func (c Collection) trackCount(ctx context.Context, operation string, filter bson.D) (*ProgressTracker, error) {
+	t := TrackProgress(ctx, operation+" "+c.name, 0)
+	if t == nil {
+		return nil, nil
+	}
+	total, err := c.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	t.SetTotal(total)
+	return t, nil
+}
+
+// CountDocuments counts the documents matching filter.
+func (c Collection) CountDocuments(ctx context.Context, filter bson.D, opts ...*options.CountOptions) (int64, error) {
+	var n int64
+	op := c.newOp(OpCount)
+	op.Filter = nonNilFilter(filter)
+	foldCallComment(op, opts)
+	err := c.run(ctx, op, func(ctx context.Context, op *Operation) error {
+		opts, err := sendComment(op, opts, options.Count().SetComment(op.Comment))
+		if err != nil {
+			return err
+		}
+		n, err = op.Target.CountDocuments(ctx, op.Filter, opts...)
+		return err
+	})
+	return n, err
+}
@@ -0,0 +1,59 @@
+package mongoboiler
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProgressTracker(t *testing.T) {
+	var updates []ProgressUpdate
+	now := time.Unix(0, 0)
+	tracker := newProgressTracker(ProgressFunc(func(u ProgressUpdate) { updates = append(updates, u) }), "export users", 100, func() time.Time { return now })
+
+	now = now.Add(500 * time.Millisecond)
+	tracker.Add(10)
+	if len(updates) != 0 {
+		t.Fatalf("reported before the interval passed")
+	}
+	now = now.Add(1500 * time.Millisecond)
+	tracker.Add(15)
+	if len(updates) != 1 {
+		t.Fatalf("expected one update, got %d", len(updates))
+	}
+	u := updates[0]
+	if u.Done != 25 || u.Total != 100 || u.Rate != 12.5 || u.ETA != 6*time.Second || u.Percent() != 25 || u.Finished {
+		t.Fatalf("unexpected update %+v", u)
+	}
+
+	tracker.Finish()
+	tracker.Finish()
+	if len(updates) != 2 || !updates[1].Finished || updates[1].ETA != 0 {
+		t.Fatalf("expected a single final update, got %+v", updates)
+	}
+}
+
+func TestTrackProgress_WithoutProgress(t *testing.T) {
+	tracker := TrackProgress(context.Background(), "backfill", 10)
+	if tracker != nil {
+		t.Fatalf("expected no tracker without a Progress in the context")
+	}
+	tracker.Add(1)
+	tracker.Finish()
+}
+
+func TestImport_ReportsProgress(t *testing.T) {
+	var ops []*Operation
+	var last ProgressUpdate
+	coll := exportTestCollection(t, nil, &ops)
+	ctx := ContextWithProgress(context.Background(), ProgressFunc(func(u ProgressUpdate) { last = u }))
+
+	in := "{\"_id\": 1}\n{\"_id\": 2}\n{\"_id\": 3}\n"
+	if _, err := coll.Import(ctx, strings.NewReader(in), FormatExtJSON, ImportOptions{Upsert: true}); err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if !last.Finished || last.Done != 3 || last.Operation != "import items" {
+		t.Fatalf("unexpected final update %+v", last)
+	}
+}
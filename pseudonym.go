@@ -0,0 +1,129 @@
+package mongoboiler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DefaultPseudonymsCollection is the collection Pseudonyms keeps the token mappings in.
+const DefaultPseudonymsCollection = "pseudonyms"
+
+// WithPseudonymsCollection changes the collection Pseudonyms keeps the token mappings in.
+func WithPseudonymsCollection(name string) Option {
+	return func(s *settings) {
+		s.pseudonymsCollection = name
+	}
+}
+
+// Pseudonyms maps internal IDs, such as the _id of documents, to random external tokens that
+// reveal nothing about them, so only tokens need to be exposed. See DB.Pseudonyms.
+type Pseudonyms struct {
+	mappings *Collection
+	scope    string
+}
+
+// pseudonym is a token mapping. The ID is encrypted when the mappings collection has
+// WithEncryption, deterministically so Tokenize can look it up.
+type pseudonym struct {
+	Token     string    `bson:"_id"`
+	Scope     string    `bson:"scope"`
+	ID        any       `bson:"id" encrypt:"deterministic"`
+	CreatedAt time.Time `bson:"createdAt"`
+}
+
+// Pseudonyms returns the token mappings of the named scope, e.g. the collection whose IDs are
+// tokenized; the same ID gets different tokens in different scopes. opts apply to the mappings
+// collection, WithEncryption encrypts the internal IDs stored there:
+//
+//	users := db.Pseudonyms("users", mongoboiler.WithEncryption(enc))
+//	token, err := users.Tokenize(ctx, user.ID)
+//
+// EnsureIndexes must have run, or concurrent Tokenize calls may map an ID to several tokens.
+func (db *DB) Pseudonyms(scope string, opts ...Option) *Pseudonyms {
+	name := DefaultPseudonymsCollection
+	if db.settings != nil && db.settings.pseudonymsCollection != "" {
+		name = db.settings.pseudonymsCollection
+	}
+	opts = append([]Option{WithEncryptedModel(pseudonym{})}, opts...)
+	return &Pseudonyms{mappings: db.NewCollection(name, opts...), scope: scope}
+}
+
+// EnsureIndexes creates the unique index giving every ID a single token per scope.
+func (p *Pseudonyms) EnsureIndexes(ctx context.Context) error {
+	_, err := p.mappings.collection().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "scope", Value: 1}, {Key: "id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+func (p *Pseudonyms) idFilter(id any) bson.D {
+	return bson.D{{Key: "scope", Value: p.scope}, {Key: "id", Value: id}}
+}
+
+// Tokenize returns the token of id, creating one on first use. IDs compare by BSON type, so
+// 1 and "1" get different tokens.
+func (p *Pseudonyms) Tokenize(ctx context.Context, id any) (string, error) {
+	for attempt := 1; ; attempt++ {
+		var existing pseudonym
+		err := p.mappings.FindOne(ctx, p.idFilter(id), &existing)
+		if err == nil {
+			return existing.Token, nil
+		}
+		if !errors.Is(err, ErrNotFound) {
+			return "", err
+		}
+
+		token, err := newPseudonymToken()
+		if err != nil {
+			return "", err
+		}
+		_, err = p.mappings.InsertOne(ctx, pseudonym{
+			Token:     token,
+			Scope:     p.scope,
+			ID:        id,
+			CreatedAt: time.Now().UTC().Truncate(time.Millisecond),
+		})
+		if err == nil {
+			return token, nil
+		}
+		if !errors.Is(err, ErrDuplicateKey) || attempt == 3 {
+			return "", err
+		}
+		// Tokenized concurrently, read the winner's token. A token collision is retried with
+		// a new one.
+	}
+}
+
+// Resolve returns the internal ID of token, ErrNotFound if the token is unknown in the scope.
+// The ID is decoded as by bson into an interface, e.g. an ObjectID stays one.
+func (p *Pseudonyms) Resolve(ctx context.Context, token string) (any, error) {
+	var m pseudonym
+	if err := p.mappings.FindOne(ctx, bson.D{{Key: "_id", Value: token}, {Key: "scope", Value: p.scope}}, &m); err != nil {
+		return nil, err
+	}
+	return m.ID, nil
+}
+
+// Forget removes the token of id, after which its token resolves to nothing and Tokenize
+// creates a new one, e.g. to unlink data exported under the old token.
+func (p *Pseudonyms) Forget(ctx context.Context, id any) error {
+	_, err := p.mappings.DeleteOne(ctx, p.idFilter(id))
+	return err
+}
+
+// newPseudonymToken returns a random URL safe token of 128 bits.
+func newPseudonymToken() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b[:]), nil
+}
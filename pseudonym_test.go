@@ -0,0 +1,74 @@
+package mongoboiler
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestPseudonyms_Tokenize(t *testing.T) {
+	var writes []*Operation
+	db := newTestCollection(t, "unused", WithCache(NewLRUCache(10), 0), WithPseudonymsCollection("tokens")).db
+	p := db.Pseudonyms("users", WithMiddleware(func(next Handler) Handler {
+		return func(ctx context.Context, op *Operation) error {
+			if !op.Kind.IsWrite() {
+				return next(ctx, op)
+			}
+			writes = append(writes, op)
+			return nil
+		}
+	}))
+	ctx := context.Background()
+
+	primeFindOne(t, p.mappings, p.idFilter(7), bson.D{{Key: "_id", Value: "tok"}, {Key: "scope", Value: "users"}, {Key: "id", Value: 7}})
+	token, err := p.Tokenize(ctx, 7)
+	if err != nil || token != "tok" || len(writes) != 0 {
+		t.Fatalf("expected the existing token, got %q, %v and %d writes", token, err, len(writes))
+	}
+
+	setCachedMiss(t, p.mappings, p.idFilter(8))
+	token, err = p.Tokenize(ctx, 8)
+	if err != nil {
+		t.Fatalf("Tokenize failed: %v", err)
+	}
+	if len(token) != 22 || len(writes) != 1 || writes[0].Collection != "tokens" {
+		t.Fatalf("expected a new token to be inserted, got %q and %+v", token, writes)
+	}
+	if m, ok := writes[0].Documents[0].(pseudonym); !ok || m.Token != token || m.Scope != "users" || m.ID != 8 {
+		t.Fatalf("unexpected mapping %+v", writes[0].Documents[0])
+	}
+}
+
+func TestPseudonyms_Resolve(t *testing.T) {
+	db := newTestCollection(t, "unused", WithCache(NewLRUCache(10), 0)).db
+	p := db.Pseudonyms("users")
+	ctx := context.Background()
+
+	primeFindOne(t, p.mappings, bson.D{{Key: "_id", Value: "tok"}, {Key: "scope", Value: "users"}},
+		bson.D{{Key: "_id", Value: "tok"}, {Key: "scope", Value: "users"}, {Key: "id", Value: "u1"}})
+	if id, err := p.Resolve(ctx, "tok"); err != nil || id != "u1" {
+		t.Fatalf("expected u1, got %v, %v", id, err)
+	}
+	setCachedMiss(t, p.mappings, bson.D{{Key: "_id", Value: "other"}, {Key: "scope", Value: "users"}})
+	if _, err := p.Resolve(ctx, "other"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if p.mappings.name != DefaultPseudonymsCollection {
+		t.Fatalf("unexpected collection %s", p.mappings.name)
+	}
+}
+
+// setCachedMiss caches that FindOne for filter finds nothing.
+func setCachedMiss(t *testing.T, coll *Collection, filter bson.D) {
+	t.Helper()
+	op := coll.newOp(OpFindOne)
+	op.Filter = filter
+	key, ok := coll.readCache().key(context.Background(), op, []*options.FindOneOptions(nil))
+	if !ok {
+		t.Fatalf("expected the read to be cacheable")
+	}
+	coll.readCache().set(context.Background(), key, nil)
+}
@@ -0,0 +1,111 @@
+package mongoboiler
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// QuarantinedDocument is the record stored in the quarantine collection for a document that
+// failed to decode.
+type QuarantinedDocument struct {
+	Database   string   `bson:"database"`
+	Collection string   `bson:"collection"`
+	DocumentID any      `bson:"documentId"`
+	Document   bson.Raw `bson:"document"`
+	Error      string   `bson:"error"`
+	// QuarantinedAt is when the document was first read, LastSeenAt when it was last read.
+	QuarantinedAt time.Time `bson:"quarantinedAt"`
+	LastSeenAt    time.Time `bson:"lastSeenAt"`
+	// Count is the number of reads that skipped the document.
+	Count int64 `bson:"count"`
+}
+
+// WithQuarantine makes FindMany skip documents it cannot decode instead of failing: they are
+// logged to logger (the standard logger if nil) and copied to the named collection as a
+// QuarantinedDocument, to be repaired later. The rest of the result is returned as usual.
+// Every document has one record, keyed on its database, collection and _id, that later reads
+// update with the document as read, the error and when it was seen.
+func WithQuarantine(collection string, logger Logger) Option {
+	return func(s *settings) {
+		s.quarantine = &quarantine{collection: collection, logger: loggerOrDefault(logger)}
+	}
+}
+
+type quarantine struct {
+	collection string
+	logger     Logger
+	// store is a seam for tests, the quarantine collection when nil.
+	store func(ctx context.Context, rec QuarantinedDocument) error
+}
+
+// rawDecoder is implemented by decoders that expose the undecoded document.
+type rawDecoder interface {
+	Raw() bson.Raw
+}
+
+// quarantine records the document of dec that failed with decodeErr. It reports whether the
+// document was handled, i.e. the iteration should go on.
+func (c Collection) quarantine(ctx context.Context, dec Decoder, decodeErr error) bool {
+	q := c.settings.quarantine
+	raw, ok := dec.(rawDecoder)
+	if q == nil || !ok {
+		return false
+	}
+	doc := append(bson.Raw(nil), raw.Raw()...)
+	now := time.Now()
+	rec := QuarantinedDocument{
+		Database:      c.db.databaseName(),
+		Collection:    c.collectionName(),
+		Document:      doc,
+		Error:         decodeErr.Error(),
+		QuarantinedAt: now,
+		LastSeenAt:    now,
+		Count:         1,
+	}
+	if id, err := doc.LookupErr("_id"); err == nil {
+		rec.DocumentID = id
+	}
+	q.logger.Printf("mongoboiler: quarantining undecodable document %v of %s.%s: %v", rec.DocumentID, rec.Database, rec.Collection, decodeErr)
+
+	store := q.store
+	if store == nil {
+		store = func(ctx context.Context, rec QuarantinedDocument) error {
+			target := c.db.NewCollection(q.collection).collection()
+			if rec.DocumentID == nil {
+				// Without an _id there is nothing to tell the documents apart by.
+				_, err := target.InsertOne(ctx, rec)
+				return err
+			}
+			filter, update := quarantineUpsert(rec)
+			_, err := target.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+			return err
+		}
+	}
+	if err := store(ctx, rec); err != nil {
+		q.logger.Printf("mongoboiler: storing quarantined document %v failed: %v", rec.DocumentID, err)
+	}
+	return true
+}
+
+// quarantineUpsert returns the filter and update recording another sighting of the document of
+// rec in its record, creating it on the first.
+func quarantineUpsert(rec QuarantinedDocument) (filter, update bson.D) {
+	filter = bson.D{
+		{Key: "database", Value: rec.Database},
+		{Key: "collection", Value: rec.Collection},
+		{Key: "documentId", Value: rec.DocumentID},
+	}
+	update = bson.D{
+		{Key: "$setOnInsert", Value: bson.D{{Key: "quarantinedAt", Value: rec.QuarantinedAt}}},
+		{Key: "$set", Value: bson.D{
+			{Key: "document", Value: rec.Document},
+			{Key: "error", Value: rec.Error},
+			{Key: "lastSeenAt", Value: rec.LastSeenAt},
+		}},
+		{Key: "$inc", Value: bson.D{{Key: "count", Value: rec.Count}}},
+	}
+	return filter, update
+}
@@ -0,0 +1,74 @@
+package mongoboiler
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestFindMany_Quarantine(t *testing.T) {
+	logger := &recordingLogger{}
+	coll := newTestCollection(t, "orders", WithCache(NewLRUCache(10), 0), WithQuarantine("quarantine", logger))
+	var stored []QuarantinedDocument
+	coll.settings.quarantine.store = func(ctx context.Context, rec QuarantinedDocument) error {
+		stored = append(stored, rec)
+		return nil
+	}
+	filter := bson.D{{Key: "status", Value: "open"}}
+	primeFind(t, coll, filter,
+		bson.D{{Key: "_id", Value: 1}, {Key: "n", Value: 1}},
+		bson.D{{Key: "_id", Value: 2}, {Key: "n", Value: "two"}},
+		bson.D{{Key: "_id", Value: 3}, {Key: "n", Value: 3}})
+
+	var docs []streamDoc
+	if err := coll.FindMany(context.Background(), filter, &docs); err != nil {
+		t.Fatalf("FindMany failed: %v", err)
+	}
+	if len(docs) != 2 || docs[0].N != 1 || docs[1].N != 3 {
+		t.Fatalf("expected the decodable documents, got %+v", docs)
+	}
+	if len(stored) != 1 {
+		t.Fatalf("expected 1 quarantined document, got %d", len(stored))
+	}
+	rec := stored[0]
+	if id, ok := rec.DocumentID.(bson.RawValue); !ok || id.Int32() != 2 || rec.Collection != "orders" || rec.Database != "testdb" || rec.Error == "" {
+		t.Fatalf("unexpected record %+v", rec)
+	}
+	if got := rec.Document.Lookup("n").StringValue(); got != "two" {
+		t.Fatalf("expected the document to be kept as is, got %v", rec.Document)
+	}
+	if len(logger.lines) != 1 {
+		t.Fatalf("expected the quarantined document to be logged, got %v", logger.lines)
+	}
+
+	plain := newTestCollection(t, "orders", WithCache(NewLRUCache(10), 0))
+	primeFind(t, plain, filter, bson.D{{Key: "_id", Value: 2}, {Key: "n", Value: "two"}})
+	if err := plain.FindMany(context.Background(), filter, &docs); err == nil {
+		t.Fatalf("expected FindMany to fail without a quarantine")
+	}
+}
+
+func TestQuarantineUpsert(t *testing.T) {
+	at := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	filter, update := quarantineUpsert(QuarantinedDocument{
+		Database: "testdb", Collection: "orders", DocumentID: 2, Error: "bad",
+		QuarantinedAt: at, LastSeenAt: at, Count: 1,
+	})
+	wantFilter := bson.D{{Key: "database", Value: "testdb"}, {Key: "collection", Value: "orders"}, {Key: "documentId", Value: 2}}
+	if !reflect.DeepEqual(filter, wantFilter) {
+		t.Fatalf("unexpected filter %v", filter)
+	}
+	ops := update.Map()
+	if first := ops["$setOnInsert"].(bson.D).Map(); len(first) != 1 || first["quarantinedAt"] != at {
+		t.Fatalf("expected only the first sighting to set quarantinedAt, got %v", first)
+	}
+	if set := ops["$set"].(bson.D).Map(); set["lastSeenAt"] != at || set["error"] != "bad" {
+		t.Fatalf("unexpected $set %v", set)
+	}
+	if inc := ops["$inc"].(bson.D).Map(); inc["count"] != int64(1) {
+		t.Fatalf("unexpected $inc %v", inc)
+	}
+}
@@ -0,0 +1,231 @@
+package mongoboiler
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	// ErrQueueEmpty is returned by Dequeue when no job is due.
+	ErrQueueEmpty = errors.New("mongoboiler: no job is due")
+	// ErrLeaseLost is returned by Ack, Nack and Extend when the job's visibility timeout expired
+	// and it may have been handed to another worker.
+	ErrLeaseLost = errors.New("mongoboiler: job lease expired")
+)
+
+// Queue is a job queue stored in a collection. Jobs are leased to one worker at a time by
+// Dequeue and become visible again when the worker neither acknowledges them nor extends the
+// lease within the visibility timeout, so delivery is at least once.
+type Queue struct {
+	// Visibility is how long a dequeued job is hidden from other workers, 30 seconds by default.
+	Visibility time.Duration
+	// MaxAttempts moves a job to the dead letter collection once it was dequeued that many times
+	// without being acknowledged, zero retries forever.
+	MaxAttempts int
+	// RetryDelay is the delay of Nack without an explicit one, ten seconds by default.
+	RetryDelay time.Duration
+
+	jobs, dead *Collection
+	// modify leases jobs, a seam for tests.
+	modify func(ctx context.Context, op *Operation, opts *options.FindOneAndUpdateOptions, res any) error
+}
+
+// Job is a dequeued job.
+type Job struct {
+	ID       primitive.ObjectID `bson:"_id"`
+	Payload  bson.RawValue      `bson:"payload"`
+	RunAt    time.Time          `bson:"runAt"`
+	Attempts int                `bson:"attempts"`
+	// LastError is the reason given to the last Nack.
+	LastError   string             `bson:"lastError,omitempty"`
+	CreatedAt   time.Time          `bson:"createdAt"`
+	LeasedUntil time.Time          `bson:"leasedUntil"`
+	Lease       primitive.ObjectID `bson:"lease"`
+	// DeadAt is set on jobs in the dead letter collection.
+	DeadAt *time.Time `bson:"deadAt,omitempty"`
+}
+
+// Decode decodes the job's payload into v.
+func (j *Job) Decode(v any) error {
+	return j.Payload.Unmarshal(v)
+}
+
+// NewQueue returns the queue stored in the named collection. Jobs exceeding MaxAttempts are
+// moved to the collection name + "_dead".
+func (db *DB) NewQueue(name string, opts ...Option) *Queue {
+	return &Queue{jobs: db.NewCollection(name, opts...), dead: db.NewCollection(name+"_dead", opts...), modify: findOneAndUpdate}
+}
+
+// EnsureIndexes creates the index Dequeue relies on.
+func (q *Queue) EnsureIndexes(ctx context.Context) error {
+	_, err := q.jobs.collection().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "runAt", Value: 1}, {Key: "leasedUntil", Value: 1}},
+	})
+	return err
+}
+
+// Enqueue adds a job that becomes due at runAt, immediately if runAt is zero.
+func (q *Queue) Enqueue(ctx context.Context, payload any, runAt time.Time) (primitive.ObjectID, error) {
+	now := time.Now().UTC()
+	if runAt.IsZero() {
+		runAt = now
+	}
+	id := primitive.NewObjectID()
+	_, err := q.jobs.InsertOne(ctx, bson.D{
+		{Key: "_id", Value: id},
+		{Key: "payload", Value: payload},
+		{Key: "runAt", Value: runAt.UTC()},
+		{Key: "attempts", Value: 0},
+		{Key: "createdAt", Value: now},
+		{Key: "leasedUntil", Value: time.Time{}},
+	})
+	return id, err
+}
+
+// Dequeue leases the job that has been due longest, returning ErrQueueEmpty if none is.
+// The job must be passed to Ack once processed, or to Nack to retry it.
+func (q *Queue) Dequeue(ctx context.Context) (*Job, error) {
+	for {
+		job, err := q.lease(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if q.MaxAttempts <= 0 || job.Attempts <= q.MaxAttempts {
+			return job, nil
+		}
+		// Leased too often without acknowledgment, e.g. because it crashes its workers.
+		if err := q.bury(ctx, job, job.LastError); err != nil && !errors.Is(err, ErrLeaseLost) {
+			return nil, err
+		}
+	}
+}
+
+func (q *Queue) lease(ctx context.Context) (*Job, error) {
+	now := time.Now().UTC()
+	var job Job
+	op := q.jobs.newOp(OpUpdateOne)
+	op.needsEffect = true
+	op.Filter = bson.D{
+		{Key: "runAt", Value: bson.D{{Key: "$lte", Value: now}}},
+		{Key: "leasedUntil", Value: bson.D{{Key: "$lte", Value: now}}},
+	}
+	op.Update = bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "leasedUntil", Value: now.Add(q.visibility())},
+			{Key: "lease", Value: primitive.NewObjectID()},
+		}},
+		{Key: "$inc", Value: bson.D{{Key: "attempts", Value: 1}}},
+	}
+	err := q.jobs.run(ctx, op, func(ctx context.Context, op *Operation) error {
+		opts := options.FindOneAndUpdate().
+			SetSort(bson.D{{Key: "runAt", Value: 1}}).
+			SetReturnDocument(options.After)
+		if op.Comment != "" {
+			opts.SetComment(op.Comment)
+		}
+		if err := q.modify(ctx, op, opts, &job); err != nil {
+			return err
+		}
+		// The sort picked the job, journal and audit the update of that one.
+		op.Filter = bson.D{{Key: "_id", Value: job.ID}}
+		return nil
+	})
+	if errors.Is(err, ErrNotFound) {
+		return nil, ErrQueueEmpty
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// Ack removes a processed job from the queue.
+func (q *Queue) Ack(ctx context.Context, job *Job) error {
+	res, err := q.jobs.DeleteOne(ctx, leaseFilter(job))
+	if err == nil && res.DeletedCount == 0 {
+		return ErrLeaseLost
+	}
+	return err
+}
+
+// Nack releases a job for another attempt after delay, RetryDelay if zero. reason, if not nil,
+// is recorded as the job's LastError. Jobs that used up MaxAttempts are dead-lettered instead.
+func (q *Queue) Nack(ctx context.Context, job *Job, delay time.Duration, reason error) error {
+	lastError := job.LastError
+	if reason != nil {
+		lastError = reason.Error()
+	}
+	if q.MaxAttempts > 0 && job.Attempts >= q.MaxAttempts {
+		return q.bury(ctx, job, lastError)
+	}
+	if delay <= 0 {
+		delay = q.retryDelay()
+	}
+	res, err := q.jobs.UpdateOne(ctx, leaseFilter(job), bson.D{{Key: "$set", Value: bson.D{
+		{Key: "runAt", Value: time.Now().UTC().Add(delay)},
+		{Key: "leasedUntil", Value: time.Time{}},
+		{Key: "lastError", Value: lastError},
+	}}})
+	if err == nil && res.MatchedCount == 0 {
+		return ErrLeaseLost
+	}
+	return err
+}
+
+// Extend keeps a job hidden from other workers for another d, for jobs taking longer than the
+// visibility timeout.
+func (q *Queue) Extend(ctx context.Context, job *Job, d time.Duration) error {
+	until := time.Now().UTC().Add(d).Truncate(time.Millisecond)
+	res, err := q.jobs.UpdateOne(ctx, leaseFilter(job), bson.D{{Key: "$set", Value: bson.D{{Key: "leasedUntil", Value: until}}}})
+	if err == nil && res.MatchedCount == 0 {
+		return ErrLeaseLost
+	}
+	if err == nil {
+		job.LeasedUntil = until
+	}
+	return err
+}
+
+// DeadLetters returns the collection dead-lettered jobs are moved to, e.g. to inspect or
+// re-enqueue them.
+func (q *Queue) DeadLetters() *Collection {
+	return q.dead
+}
+
+// bury moves job to the dead letter collection. The copy is written first, so a crash in
+// between leaves the job in both rather than losing it.
+func (q *Queue) bury(ctx context.Context, job *Job, lastError string) error {
+	now := time.Now().UTC()
+	dead := *job
+	dead.LastError, dead.DeadAt = lastError, &now
+	_, err := q.dead.ReplaceOne(ctx, bson.D{{Key: "_id", Value: job.ID}}, dead, options.Replace().SetUpsert(true))
+	if err != nil {
+		return err
+	}
+	return q.Ack(ctx, job)
+}
+
+// leaseFilter matches job only while the caller still holds its lease.
+func leaseFilter(job *Job) bson.D {
+	return bson.D{{Key: "_id", Value: job.ID}, {Key: "lease", Value: job.Lease}}
+}
+
+func (q *Queue) visibility() time.Duration {
+	if q.Visibility <= 0 {
+		return 30 * time.Second
+	}
+	return q.Visibility
+}
+
+func (q *Queue) retryDelay() time.Duration {
+	if q.RetryDelay <= 0 {
+		return 10 * time.Second
+	}
+	return q.RetryDelay
+}
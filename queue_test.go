@@ -0,0 +1,224 @@
+package mongoboiler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestJob_Decode(t *testing.T) {
+	raw := mustRaw(t, bson.D{{Key: "payload", Value: bson.D{{Key: "name", Value: "pen"}, {Key: "qty", Value: 2}}}})
+	var job Job
+	if err := bson.Unmarshal(raw, &job); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	var item decodeItem
+	if err := job.Decode(&item); err != nil || item != (decodeItem{"pen", 2}) {
+		t.Fatalf("unexpected payload %+v, %v", item, err)
+	}
+}
+
+func TestNewQueue(t *testing.T) {
+	q := newTestCollection(t, "unused").db.NewQueue("emails")
+	if q.jobs.name != "emails" || q.DeadLetters().name != "emails_dead" {
+		t.Fatalf("unexpected collections %s and %s", q.jobs.name, q.dead.name)
+	}
+	if q.visibility() != 30*time.Second || q.retryDelay() != 10*time.Second {
+		t.Fatalf("unexpected defaults %s and %s", q.visibility(), q.retryDelay())
+	}
+	if leaseFilter(&Job{})[1].Key != "lease" {
+		t.Fatalf("acknowledgments must be bound to the lease")
+	}
+}
+
+// fakeQueue keeps the jobs and dead letters of a queue in memory.
+type fakeQueue struct {
+	t          *testing.T
+	jobs, dead []*Job
+}
+
+func (f *fakeQueue) job(doc any) *Job {
+	t := f.t
+	t.Helper()
+	d, err := toDocument(doc)
+	if err != nil {
+		t.Fatalf("encoding the job failed: %v", err)
+	}
+	var job Job
+	if err := bson.Unmarshal(mustRaw(t, d), &job); err != nil {
+		t.Fatalf("decoding the job failed: %v", err)
+	}
+	return &job
+}
+
+// lease picks the job due longest that is not leased.
+func (f *fakeQueue) lease(ctx context.Context, op *Operation, opts *options.FindOneAndUpdateOptions, res any) error {
+	now := time.Now()
+	var due *Job
+	for _, j := range f.jobs {
+		if !j.RunAt.After(now) && !j.LeasedUntil.After(now) && (due == nil || j.RunAt.Before(due.RunAt)) {
+			due = j
+		}
+	}
+	if due == nil {
+		return mongo.ErrNoDocuments
+	}
+	setFields(f.t, due, op.Update)
+	due.Attempts++
+	*res.(*Job) = *due
+	return nil
+}
+
+// leased returns the index of the job matching the lease filter, -1 if none does.
+func (f *fakeQueue) leased(filter bson.D) int {
+	m := filter.Map()
+	for i, j := range f.jobs {
+		if j.ID == m["_id"] && j.Lease == m["lease"] {
+			return i
+		}
+	}
+	return -1
+}
+
+func (f *fakeQueue) middleware(next Handler) Handler {
+	return func(ctx context.Context, op *Operation) error {
+		switch {
+		case op.needsEffect:
+			return next(ctx, op)
+		case op.Kind == OpInsertOne:
+			f.jobs = append(f.jobs, f.job(op.Documents[0]))
+			op.Result = InsertResult{}
+		case op.Kind == OpReplaceOne:
+			f.dead = append(f.dead, f.job(op.Documents[0]))
+			op.Result = UpdateResult{UpsertedCount: 1}
+		case op.Kind == OpDeleteOne:
+			var res DeleteResult
+			if i := f.leased(op.Filter); i >= 0 {
+				f.jobs = append(f.jobs[:i], f.jobs[i+1:]...)
+				res.DeletedCount = 1
+			}
+			op.Result = res
+		case op.Kind == OpUpdateOne:
+			var res UpdateResult
+			if i := f.leased(op.Filter); i >= 0 {
+				setFields(f.t, f.jobs[i], op.Update)
+				res.MatchedCount = 1
+			}
+			op.Result = res
+		default:
+			f.t.Fatalf("unexpected %s", op.Kind)
+		}
+		return nil
+	}
+}
+
+func TestQueue_Lifecycle(t *testing.T) {
+	fake := &fakeQueue{t: t}
+	coll := newTestCollection(t, "unused", WithMiddleware(fake.middleware))
+	q := coll.db.NewQueue("emails")
+	q.modify = fake.lease
+	q.Visibility = time.Minute
+	ctx := context.Background()
+
+	first, err := q.Enqueue(ctx, bson.D{{Key: "to", Value: "ann"}}, time.Time{})
+	if err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+	if _, err := q.Enqueue(ctx, bson.D{{Key: "to", Value: "bob"}}, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+
+	job, err := q.Dequeue(ctx)
+	if err != nil || job.ID != first || job.Attempts != 1 || !job.LeasedUntil.After(time.Now().Add(50*time.Second)) {
+		t.Fatalf("expected the due job to be leased, got %+v, %v", job, err)
+	}
+	if _, err := q.Dequeue(ctx); !errors.Is(err, ErrQueueEmpty) {
+		t.Fatalf("expected leased and future jobs to be hidden, got %v", err)
+	}
+	if err := q.Extend(ctx, job, 2*time.Minute); err != nil || !job.LeasedUntil.After(time.Now().Add(110*time.Second)) {
+		t.Fatalf("expected the lease to be extended, got %+v, %v", job, err)
+	}
+
+	// The visibility timeout expires, another worker leases the job.
+	fake.jobs[0].LeasedUntil = time.Now().Add(-time.Second)
+	again, err := q.Dequeue(ctx)
+	if err != nil || again.ID != first || again.Attempts != 2 || again.Lease == job.Lease {
+		t.Fatalf("expected the expired job to be leased again, got %+v, %v", again, err)
+	}
+	if err := q.Ack(ctx, job); !errors.Is(err, ErrLeaseLost) {
+		t.Fatalf("expected the stale lease to be rejected, got %v", err)
+	}
+
+	if err := q.Nack(ctx, again, 0, errors.New("smtp down")); err != nil {
+		t.Fatalf("Nack failed: %v", err)
+	}
+	if j := fake.jobs[0]; j.LastError != "smtp down" || !j.LeasedUntil.IsZero() || !j.RunAt.After(time.Now().Add(5*time.Second)) {
+		t.Fatalf("expected the job to be released for a later attempt, got %+v", j)
+	}
+	if _, err := q.Dequeue(ctx); !errors.Is(err, ErrQueueEmpty) {
+		t.Fatalf("expected the nacked job to wait for its retry delay, got %v", err)
+	}
+
+	fake.jobs[0].RunAt = time.Now().Add(-time.Second)
+	job, err = q.Dequeue(ctx)
+	if err != nil || job.Attempts != 3 {
+		t.Fatalf("expected the retry to be leased, got %+v, %v", job, err)
+	}
+	if err := q.Ack(ctx, job); err != nil {
+		t.Fatalf("Ack failed: %v", err)
+	}
+	if len(fake.jobs) != 1 || fake.jobs[0].ID == first {
+		t.Fatalf("expected the acknowledged job to be removed, got %+v", fake.jobs)
+	}
+}
+
+func TestQueue_DeadLetters(t *testing.T) {
+	fake := &fakeQueue{t: t}
+	coll := newTestCollection(t, "unused", WithMiddleware(fake.middleware))
+	q := coll.db.NewQueue("emails")
+	q.modify = fake.lease
+	q.MaxAttempts = 2
+	ctx := context.Background()
+
+	id, _ := q.Enqueue(ctx, "payload", time.Time{})
+	for attempt := 1; attempt <= 2; attempt++ {
+		job, err := q.Dequeue(ctx)
+		if err != nil || job.Attempts != attempt {
+			t.Fatalf("attempt %d: got %+v, %v", attempt, job, err)
+		}
+		if attempt == 1 {
+			// The worker crashes, the lease expires.
+			fake.jobs[0].LeasedUntil = time.Now().Add(-time.Second)
+			continue
+		}
+		if err := q.Nack(ctx, job, 0, errors.New("bounced")); err != nil {
+			t.Fatalf("Nack failed: %v", err)
+		}
+	}
+	if len(fake.jobs) != 0 || len(fake.dead) != 1 {
+		t.Fatalf("expected the job to be dead-lettered, got %d jobs and %d dead", len(fake.jobs), len(fake.dead))
+	}
+	if dead := fake.dead[0]; dead.ID != id || dead.LastError != "bounced" || dead.DeadAt == nil {
+		t.Fatalf("unexpected dead letter %+v", dead)
+	}
+
+	// Jobs crashing their workers are buried by Dequeue once over MaxAttempts.
+	id, _ = q.Enqueue(ctx, "payload", time.Time{})
+	for attempt := 1; attempt <= 2; attempt++ {
+		if _, err := q.Dequeue(ctx); err != nil {
+			t.Fatalf("attempt %d failed: %v", attempt, err)
+		}
+		fake.jobs[0].LeasedUntil = time.Now().Add(-time.Second)
+	}
+	if _, err := q.Dequeue(ctx); !errors.Is(err, ErrQueueEmpty) {
+		t.Fatalf("expected the job over MaxAttempts to be buried, got %v", err)
+	}
+	if len(fake.jobs) != 0 || len(fake.dead) != 2 || fake.dead[1].ID != id {
+		t.Fatalf("expected the crashing job to be dead-lettered, got %d jobs and %+v", len(fake.jobs), fake.dead)
+	}
+}
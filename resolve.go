@@ -0,0 +1,174 @@
+package mongoboiler
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ResolvedOption is the effective value of an option, see ResolveOptions.
+type ResolvedOption struct {
+	Name  string
+	Value string
+	// Sources lists the levels whose options changed the value, in order; it is empty when the
+	// value is the default.
+	Sources []OptionSource
+}
+
+func (o ResolvedOption) String() string {
+	sources := []string{string(SourceDefault)}
+	if len(o.Sources) > 0 {
+		sources = sources[:0]
+		for _, s := range o.Sources {
+			sources = append(sources, string(s))
+		}
+	}
+	return fmt.Sprintf("%s=%s (%s)", o.Name, o.Value, strings.Join(sources, ", "))
+}
+
+// ResolveOptions returns the effective options of the DB, for debugging a cascade of options.
+func (db *DB) ResolveOptions() []ResolvedOption {
+	return resolveOptions(db.settings)
+}
+
+// ResolveOptions returns the effective options of the operations of the collection and the
+// levels they were set at:
+//
+//	for _, o := range coll.With(opts...).ResolveOptions() {
+//		fmt.Println(o) // e.g. decodeMode=strict (db, call)
+//	}
+func (c Collection) ResolveOptions() []ResolvedOption {
+	return resolveOptions(c.settings)
+}
+
+// resolveOptions replays the option layers of s, attributing every change to its layer.
+func resolveOptions(s *settings) []ResolvedOption {
+	replay := &settings{}
+	resolved := describeSettings(replay)
+	if s == nil {
+		return resolved
+	}
+	for _, layer := range s.layers {
+		for _, opt := range layer.opts {
+			opt(replay)
+		}
+		for i, o := range describeSettings(replay) {
+			if o.Value != resolved[i].Value {
+				resolved[i].Value = o.Value
+				resolved[i].Sources = append(resolved[i].Sources, layer.source)
+			}
+		}
+	}
+	return resolved
+}
+
+// describeSettings returns the options of s in a fixed order.
+func describeSettings(s *settings) []ResolvedOption {
+	collectionOr := func(name, def string) string {
+		if name == "" {
+			return def
+		}
+		return name
+	}
+	quarantine, cache, dryRun := "off", "off", "off"
+	if s.quarantine != nil {
+		quarantine = s.quarantine.collection
+	}
+	if s.dryRun != nil {
+		dryRun = "error"
+		if s.dryRun.log {
+			dryRun = "log"
+		}
+	}
+	retry, contextComment, resultLimits := "off", "off", "off"
+	if l := s.resultLimits; l != nil {
+		resultLimits = l.String()
+		if l.LogOnly {
+			resultLimits += " (log)"
+		}
+	}
+	if cc := s.contextComment; cc != nil {
+		contextComment = "on"
+		if cc.inFilter {
+			contextComment = "filter"
+		}
+	}
+	if s.retry != nil {
+		retry = strconv.Itoa(s.retry.policy.MaxAttempts) + " attempts"
+	}
+	if s.cache != nil {
+		cache = "ttl " + s.cache.ttl.String()
+		if s.cacheDocuments {
+			cache += " by document"
+		}
+	}
+	encryptedFields := make([]string, 0, len(s.encryptedFields))
+	for field, algorithm := range s.encryptedFields {
+		encryptedFields = append(encryptedFields, field+":"+algorithm)
+	}
+	sort.Strings(encryptedFields)
+	readConcern, writeConcern, readPreference := describeConcerns(s)
+	normalized := make([]string, len(s.normalizers))
+	for i, n := range s.normalizers {
+		normalized[i] = n.path
+	}
+
+	return []ResolvedOption{
+		{Name: "middleware", Value: strconv.Itoa(len(s.middleware))},
+		{Name: "schema", Value: onOff(s.schema != nil)},
+		{Name: "dropProtection", Value: strconv.FormatBool(s.dropProtection)},
+		{Name: "decodeMode", Value: decodeModeName(s.decodeMode)},
+		{Name: "decodeHooks", Value: strconv.Itoa(len(s.decodeHooks))},
+		{Name: "mapDecoding", Value: s.mapDecoding.String()},
+		{Name: "keyEscaping", Value: onOff(s.escapeKeys)},
+		{Name: "normalizers", Value: "[" + strings.Join(normalized, " ") + "]"},
+		{Name: "populate", Value: "[" + strings.Join(s.populate, " ") + "]"},
+		{Name: "cursorKeepalive", Value: s.cursorKeepalive.String()},
+		{Name: "resultLimits", Value: resultLimits},
+		{Name: "skipUnchanged", Value: collectionOr(s.contentHashField, "off")},
+		{Name: "dryRun", Value: dryRun},
+		{Name: "retry", Value: retry},
+		{Name: "contextComment", Value: contextComment},
+		{Name: "session", Value: onOff(s.session != nil)},
+		{Name: "quarantine", Value: quarantine},
+		{Name: "cache", Value: cache},
+		{Name: "encryption", Value: onOff(s.encryption != nil)},
+		{Name: "encryptedFields", Value: "[" + strings.Join(encryptedFields, " ") + "]"},
+		{Name: "readConcern", Value: readConcern},
+		{Name: "writeConcern", Value: writeConcern},
+		{Name: "unacknowledgedWrites", Value: onOff(s.unacknowledged)},
+		{Name: "serverCancellation", Value: onOff(s.serverCancellation)},
+		{Name: "readPreference", Value: readPreference},
+		{Name: "zoneRouting", Value: collectionOr(s.zoneTag, "off")},
+		{Name: "shardKey", Value: "[" + strings.Join(s.shardKey, " ") + "]"},
+		{Name: "databaseNaming", Value: onOff(s.databaseNaming != nil)},
+		{Name: "collectionNaming", Value: onOff(s.collectionNaming != nil)},
+		{Name: "modelNaming", Value: onOff(s.modelNaming != nil)},
+		{Name: "countersCollection", Value: collectionOr(s.countersCollection, DefaultCountersCollection)},
+		{Name: "outboxCollection", Value: collectionOr(s.outboxCollection, DefaultOutboxCollection)},
+		{Name: "locksCollection", Value: collectionOr(s.locksCollection, DefaultLocksCollection)},
+		{Name: "uniqueValuesCollection", Value: collectionOr(s.uniqueValuesCollection, DefaultUniqueValuesCollection)},
+		{Name: "pseudonymsCollection", Value: collectionOr(s.pseudonymsCollection, DefaultPseudonymsCollection)},
+		{Name: "materializedViewsCollection", Value: collectionOr(s.materializedViewsCollection, DefaultMaterializedViewsCollection)},
+		{Name: "deletePreviewsCollection", Value: collectionOr(s.deletePreviewsCollection, DefaultDeletePreviewsCollection)},
+		{Name: "statsSamplesCollection", Value: collectionOr(s.statsSamplesCollection, DefaultStatsSamplesCollection)},
+	}
+}
+
+func onOff(on bool) string {
+	if on {
+		return "on"
+	}
+	return "off"
+}
+
+func decodeModeName(mode DecodeMode) string {
+	switch mode {
+	case DecodeStrict:
+		return "strict"
+	case DecodeLenient:
+		return "lenient"
+	}
+	return "default"
+}
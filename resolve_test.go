@@ -0,0 +1,62 @@
+package mongoboiler
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func resolvedOption(t *testing.T, opts []ResolvedOption, name string) ResolvedOption {
+	t.Helper()
+	for _, o := range opts {
+		if o.Name == name {
+			return o
+		}
+	}
+	t.Fatalf("option %s not resolved", name)
+	return ResolvedOption{}
+}
+
+func TestResolveOptions_Cascade(t *testing.T) {
+	db := newTestCollection(t, "unused", WithDecodeMode(DecodeStrict), WithPopulate("author")).db
+	if err := db.ApplyConfig(context.Background(), DBConfig{Collections: []CollectionConfig{
+		{Name: "posts", Options: CollectionOptions{DropProtection: true}},
+	}}); err != nil {
+		t.Fatalf("ApplyConfig failed: %v", err)
+	}
+	coll := db.NewCollection("posts", WithPopulate("comments.user")).With(WithDecodeMode(DecodeLenient))
+	resolved := coll.ResolveOptions()
+
+	for _, tc := range []struct {
+		name    string
+		value   string
+		sources []OptionSource
+	}{
+		{"decodeMode", "lenient", []OptionSource{SourceDB, SourceCall}},
+		{"dropProtection", "true", []OptionSource{SourceConfigure}},
+		{"populate", "[author comments.user]", []OptionSource{SourceDB, SourceCollection}},
+		{"cache", "off", nil},
+	} {
+		o := resolvedOption(t, resolved, tc.name)
+		if o.Value != tc.value || !reflect.DeepEqual(o.Sources, tc.sources) {
+			t.Fatalf("%s resolved to %s, want %s=%s %v", tc.name, o, tc.name, tc.value, tc.sources)
+		}
+	}
+	if s := resolvedOption(t, resolved, "cache").String(); s != "cache=off (default)" {
+		t.Fatalf("unexpected String %q", s)
+	}
+	if o := resolvedOption(t, db.ResolveOptions(), "decodeMode"); o.Value != "strict" {
+		t.Fatalf("DB decodeMode resolved to %s", o)
+	}
+}
+
+func TestWith_DoesNotChangeCollection(t *testing.T) {
+	coll := newTestCollection(t, "posts")
+	strict := coll.With(WithDecodeMode(DecodeStrict), WithDropProtection())
+	if coll.settings.decodeMode != DecodeDefault || coll.settings.dropProtection {
+		t.Fatalf("With changed the collection's options")
+	}
+	if strict.settings.decodeMode != DecodeStrict || !strict.settings.dropProtection || strict.name != "posts" {
+		t.Fatalf("With did not apply its options: %+v", strict.settings)
+	}
+}
@@ -0,0 +1,112 @@
+package mongoboiler
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// InsertResult is returned from insert operations.
+type InsertResult struct {
+	// InsertedID is the ID of the first inserted document, for single inserts the only one.
+	InsertedID any
+	// InsertedIDs holds the IDs of all inserted documents.
+	InsertedIDs []any
+}
+
+// UpdateResult is returned from update operations.
+type UpdateResult struct {
+	MatchedCount  int64
+	ModifiedCount int64
+	UpsertedCount int64
+	// UpsertedID is the ID of the upserted document, nil when no upsert happened.
+	UpsertedID any
+	// DocumentID is the ID of the document written: UpsertedID when one was created, otherwise
+	// the _id the filter matched on, or that of the returned document for FindOrCreate. It is
+	// nil when not known, e.g. when several documents matched.
+	DocumentID any
+}
+
+// Outcome is what a write did to the document it targeted, see UpdateResult.Outcome.
+type Outcome string
+
+// Outcomes, the zero value means nothing matched and nothing was upserted.
+const (
+	OutcomeNone      Outcome = ""
+	OutcomeCreated   Outcome = "created"
+	OutcomeUpdated   Outcome = "updated"
+	OutcomeUnchanged Outcome = "unchanged"
+)
+
+// Outcome reports whether the write created a document by upserting, changed at least one
+// existing document, or only matched documents it left as they were.
+func (r UpdateResult) Outcome() Outcome {
+	switch {
+	case r.UpsertedCount > 0:
+		return OutcomeCreated
+	case r.ModifiedCount > 0:
+		return OutcomeUpdated
+	case r.MatchedCount > 0:
+		return OutcomeUnchanged
+	}
+	return OutcomeNone
+}
+
+// DeleteResult is returned from delete operations.
+type DeleteResult struct {
+	DeletedCount int64
+}
+
+// newUpdateResult converts the driver result of a write with filter.
+func newUpdateResult(res *mongo.UpdateResult, filter bson.D) UpdateResult {
+	r := UpdateResult{
+		MatchedCount:  res.MatchedCount,
+		ModifiedCount: res.ModifiedCount,
+		UpsertedCount: res.UpsertedCount,
+		UpsertedID:    res.UpsertedID,
+		DocumentID:    res.UpsertedID,
+	}
+	if r.DocumentID == nil && r.MatchedCount == 1 {
+		r.DocumentID = filterID(filter)
+	}
+	return r
+}
+
+// filterID returns the value filter requires _id to equal, nil if it does not, looking into
+// $and clauses.
+func filterID(filter bson.D) any {
+	for _, e := range filter {
+		switch e.Key {
+		case "_id":
+			if d, ok := e.Value.(bson.D); ok {
+				if len(d) == 1 && d[0].Key == "$eq" {
+					return d[0].Value
+				}
+				if len(d) > 0 && len(d[0].Key) > 0 && d[0].Key[0] == '$' {
+					return nil
+				}
+			}
+			return e.Value
+		case "$and":
+			clauses, ok := e.Value.(bson.A)
+			if !ok {
+				continue
+			}
+			for _, clause := range clauses {
+				if d, ok := clause.(bson.D); ok {
+					if id := filterID(d); id != nil {
+						return id
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func newInsertManyResult(ids []any) InsertResult {
+	res := InsertResult{InsertedIDs: ids}
+	if len(ids) > 0 {
+		res.InsertedID = ids[0]
+	}
+	return res
+}
@@ -0,0 +1,52 @@
+package mongoboiler
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestUpdateResult_Outcome(t *testing.T) {
+	tests := []struct {
+		res  UpdateResult
+		want Outcome
+	}{
+		{UpdateResult{}, OutcomeNone},
+		{UpdateResult{UpsertedCount: 1, UpsertedID: 1}, OutcomeCreated},
+		{UpdateResult{MatchedCount: 1, ModifiedCount: 1}, OutcomeUpdated},
+		{UpdateResult{MatchedCount: 3, ModifiedCount: 1}, OutcomeUpdated},
+		{UpdateResult{MatchedCount: 1}, OutcomeUnchanged},
+	}
+	for _, tt := range tests {
+		if got := tt.res.Outcome(); got != tt.want {
+			t.Fatalf("Outcome of %+v = %q, want %q", tt.res, got, tt.want)
+		}
+	}
+}
+
+func TestNewUpdateResult_DocumentID(t *testing.T) {
+	tests := []struct {
+		name   string
+		res    mongo.UpdateResult
+		filter bson.D
+		want   any
+	}{
+		{"upserted", mongo.UpdateResult{UpsertedCount: 1, UpsertedID: "new"}, bson.D{{Key: "_id", Value: "new"}}, "new"},
+		{"equality", mongo.UpdateResult{MatchedCount: 1}, bson.D{{Key: "_id", Value: 7}}, 7},
+		{"$eq", mongo.UpdateResult{MatchedCount: 1}, bson.D{{Key: "_id", Value: bson.D{{Key: "$eq", Value: 7}}}}, 7},
+		{"$and", mongo.UpdateResult{MatchedCount: 1}, bson.D{{Key: "$and", Value: bson.A{
+			bson.D{{Key: "tenant", Value: "a"}},
+			bson.D{{Key: "_id", Value: 7}},
+		}}}, 7},
+		{"operator", mongo.UpdateResult{MatchedCount: 1}, bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: bson.A{7}}}}}, nil},
+		{"other field", mongo.UpdateResult{MatchedCount: 1}, bson.D{{Key: "email", Value: "a@b.c"}}, nil},
+		{"not matched", mongo.UpdateResult{}, bson.D{{Key: "_id", Value: 7}}, nil},
+		{"several matched", mongo.UpdateResult{MatchedCount: 2}, bson.D{{Key: "_id", Value: 7}}, nil},
+	}
+	for _, tt := range tests {
+		if got := newUpdateResult(&tt.res, tt.filter).DocumentID; got != tt.want {
+			t.Fatalf("%s: DocumentID = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
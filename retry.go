@@ -0,0 +1,243 @@
+package mongoboiler
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// RetryPolicy configures the retries enabled with WithRetry. Zero fields take the defaults
+// documented on them.
+type RetryPolicy struct {
+	// MaxAttempts is the most times an operation is executed, including the first. Defaults to 3.
+	MaxAttempts int
+	// BaseDelay and MaxDelay bound the exponential backoff between attempts, which is jittered
+	// to spread out retries of concurrent operations. Default to 50ms and 1s.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// AttemptTimeout limits each attempt, capped by the time left before the caller's deadline.
+	// For FindEach it covers the whole iteration. Zero leaves only the caller's deadline.
+	AttemptTimeout time.Duration
+	// MinAttemptTime is the least time that must be left before the caller's deadline, after
+	// the backoff, to start another attempt. Defaults to 10ms.
+	MinAttemptTime time.Duration
+	// RetryWrites also retries writes failing with network errors, timeouts or a node failing
+	// to reach another member, which may have been applied. Only enable it for idempotent
+	// writes. Writes failing because the node is not primary or is shutting down, like those to
+	// a stepped down primary, are always retried.
+	RetryWrites bool
+	// Budget limits the retries of all operations sharing it. When nil, WithRetry creates one
+	// with NewRetryBudget(0.1, 10) shared by every level the option is given to.
+	Budget *RetryBudget
+}
+
+// RetryBudget limits retries to a fraction of the operations executed, so that retries do not
+// multiply the load on a cluster that is already failing. It is a token bucket: every operation
+// adds ratio tokens, up to reserve, and every retry takes one. Safe for concurrent use.
+type RetryBudget struct {
+	mu      sync.Mutex
+	ratio   float64
+	reserve float64
+	tokens  float64
+}
+
+// NewRetryBudget returns a budget allowing retries of up to ratio of the operations, e.g. 0.1 for
+// one retry per ten operations, on top of a reserve of retries available after a quiet period.
+func NewRetryBudget(ratio float64, reserve int) *RetryBudget {
+	return &RetryBudget{ratio: ratio, reserve: float64(reserve), tokens: float64(reserve)}
+}
+
+func (b *RetryBudget) deposit() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.tokens += b.ratio; b.tokens > b.reserve {
+		b.tokens = b.reserve
+	}
+}
+
+// withdraw takes a token for a retry, false if the budget is exhausted.
+func (b *RetryBudget) withdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// WithRetry retries operations failing with transient errors such as network errors, primary
+// step downs and write conflicts. Retries never outlast the caller's deadline: an attempt is only
+// started when more than MinAttemptTime is left after the backoff, and each attempt's timeout is
+// capped by the time left. Retries stop once the policy's budget is exhausted and the last error
+// is returned.
+//
+// Only the operation itself is retried, the middleware chain sees a single call. Errors labeled
+// TransientTransactionError are not retried, as the whole transaction has to be, and FindEach is
+// not retried once documents were passed to its callback.
+func WithRetry(policy RetryPolicy) Option {
+	if policy.MaxAttempts <= 0 {
+		policy.MaxAttempts = 3
+	}
+	if policy.BaseDelay <= 0 {
+		policy.BaseDelay = 50 * time.Millisecond
+	}
+	if policy.MaxDelay <= 0 {
+		policy.MaxDelay = time.Second
+	}
+	if policy.MinAttemptTime <= 0 {
+		policy.MinAttemptTime = 10 * time.Millisecond
+	}
+	if policy.Budget == nil {
+		policy.Budget = NewRetryBudget(0.1, 10)
+	}
+	r := &retrier{policy: policy, now: time.Now, sleep: sleepContext, jitter: jitter}
+	return func(s *settings) {
+		s.retry = r
+	}
+}
+
+type retrier struct {
+	policy RetryPolicy
+	now    func() time.Time
+	sleep  func(ctx context.Context, d time.Duration) error
+	jitter func(d time.Duration) time.Duration
+}
+
+// retryAttempt tracks an attempt for the handler it runs, see markDelivered.
+type retryAttempt struct {
+	delivered bool
+}
+
+type retryAttemptKey struct{}
+
+// markDelivered records that the attempt running with ctx passed results to the caller, so that
+// retrying it would pass them twice.
+func markDelivered(ctx context.Context) {
+	if a, ok := ctx.Value(retryAttemptKey{}).(*retryAttempt); ok {
+		a.delivered = true
+	}
+}
+
+func (r *retrier) handler(next Handler) Handler {
+	return func(ctx context.Context, op *Operation) error {
+		r.policy.Budget.deposit()
+		for attempt := 1; ; attempt++ {
+			a := &retryAttempt{}
+			err := r.attempt(context.WithValue(ctx, retryAttemptKey{}, a), op, next)
+			if err == nil || a.delivered || attempt >= r.policy.MaxAttempts ||
+				ctx.Err() != nil || !r.retryable(op, err) {
+				return err
+			}
+			delay := r.jitter(r.backoff(attempt))
+			if deadline, ok := ctx.Deadline(); ok && deadline.Sub(r.now())-delay <= r.policy.MinAttemptTime {
+				return err
+			}
+			if !r.policy.Budget.withdraw() {
+				return err
+			}
+			if r.sleep(ctx, delay) != nil {
+				return err
+			}
+		}
+	}
+}
+
+// attempt runs next once, within AttemptTimeout. The derived context also ends at the caller's
+// deadline, capping the attempt by the time left.
+func (r *retrier) attempt(ctx context.Context, op *Operation, next Handler) error {
+	if r.policy.AttemptTimeout <= 0 {
+		return next(ctx, op)
+	}
+	ctx, cancel := context.WithTimeout(ctx, r.policy.AttemptTimeout)
+	defer cancel()
+	return next(ctx, op)
+}
+
+// backoff returns the largest delay before the retry following attempt.
+func (r *retrier) backoff(attempt int) time.Duration {
+	d := r.policy.BaseDelay
+	for i := 1; i < attempt && d < r.policy.MaxDelay; i++ {
+		d *= 2
+	}
+	if d > r.policy.MaxDelay {
+		d = r.policy.MaxDelay
+	}
+	return d
+}
+
+// retryableCodes are server errors of nodes that are not, or no longer, primary or are shutting
+// down, which the driver's retryable writes retry as well. A multi-document write interrupted
+// by them may have been applied in part.
+var retryableCodes = []int{
+	91,    // ShutdownInProgress
+	134,   // ReadConcernMajorityNotAvailableYet
+	189,   // PrimarySteppedDown
+	10107, // NotWritablePrimary
+	11600, // InterruptedAtShutdown
+	11602, // InterruptedDueToReplStateChange
+	13435, // NotPrimaryNoSecondaryOk
+	13436, // NotPrimaryOrSecondary
+}
+
+// unreachableCodes are server errors of a node failing to reach another member, after which
+// the operation may have been applied.
+var unreachableCodes = []int{
+	6,    // HostUnreachable
+	7,    // HostNotFound
+	89,   // NetworkTimeout
+	9001, // SocketException
+}
+
+// retryable reports whether op may be retried after failing with err. The caller's context is
+// known to be live, so deadline errors come from AttemptTimeout.
+func (r *retrier) retryable(op *Operation, err error) bool {
+	var server mongo.ServerError
+	isServer := errors.As(err, &server)
+	switch {
+	case isServer && server.HasErrorLabel("TransientTransactionError"):
+		return false
+	case isServer && server.HasErrorCode(writeConflict):
+		return true
+	case isServer && hasAnyErrorCode(server, retryableCodes):
+		return true
+	case isServer && hasAnyErrorCode(server, unreachableCodes),
+		mongo.IsNetworkError(err), mongo.IsTimeout(err), errors.Is(err, context.DeadlineExceeded):
+		return !op.Kind.IsWrite() || r.policy.RetryWrites
+	}
+	return false
+}
+
+func hasAnyErrorCode(err mongo.ServerError, codes []int) bool {
+	for _, code := range codes {
+		if err.HasErrorCode(code) {
+			return true
+		}
+	}
+	return false
+}
+
+// jitter returns a random duration up to d, so that operations failing together do not retry
+// together.
+func jitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(d) + 1))
+}
+
+// sleepContext waits for d, returning early with the context's error when it ends.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,156 @@
+package mongoboiler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var errStepDown = mongo.CommandError{Code: 189, Name: "PrimarySteppedDown", Message: "primary stepped down"}
+
+func newRetryCollection(t *testing.T, policy RetryPolicy) (*Collection, *[]time.Duration) {
+	coll := newTestCollection(t, "orders", WithRetry(policy))
+	var sleeps []time.Duration
+	r := coll.settings.retry
+	r.jitter = func(d time.Duration) time.Duration { return d }
+	r.sleep = func(ctx context.Context, d time.Duration) error {
+		sleeps = append(sleeps, d)
+		return nil
+	}
+	return coll, &sleeps
+}
+
+func isStepDown(err error) bool {
+	var cmd mongo.CommandError
+	return errors.As(err, &cmd) && cmd.Code == errStepDown.Code
+}
+
+// failing returns a handler failing with errs in turn, then succeeding.
+func failing(calls *int, errs ...error) Handler {
+	return func(ctx context.Context, op *Operation) error {
+		*calls++
+		if *calls <= len(errs) {
+			return errs[*calls-1]
+		}
+		return nil
+	}
+}
+
+func TestRetry_TransientErrors(t *testing.T) {
+	coll, sleeps := newRetryCollection(t, RetryPolicy{MaxAttempts: 4, BaseDelay: 10 * time.Millisecond})
+	calls := 0
+	err := coll.run(context.Background(), coll.newOp(OpUpdateOne), failing(&calls, errStepDown, errStepDown))
+	if err != nil || calls != 3 {
+		t.Fatalf("run = %v after %d calls, want success after 3", err, calls)
+	}
+	if len(*sleeps) != 2 || (*sleeps)[0] != 10*time.Millisecond || (*sleeps)[1] != 20*time.Millisecond {
+		t.Fatalf("slept %v, want [10ms 20ms]", *sleeps)
+	}
+
+	calls = 0
+	err = coll.run(context.Background(), coll.newOp(OpFind), failing(&calls, errStepDown, errStepDown, errStepDown, errStepDown))
+	if !isStepDown(err) || calls != 4 {
+		t.Fatalf("run = %v after %d calls, want the last error after 4", err, calls)
+	}
+}
+
+func TestRetry_NotRetried(t *testing.T) {
+	timeout := context.DeadlineExceeded
+	transient := mongo.CommandError{Code: 189, Labels: []string{"TransientTransactionError"}}
+	unreachable := mongo.CommandError{Code: 6, Name: "HostUnreachable"}
+	for _, tc := range []struct {
+		name string
+		kind OpKind
+		err  error
+	}{
+		{"permanent", OpFind, ErrNotFound},
+		{"write timeout", OpUpdateOne, timeout},
+		{"write to unreachable host", OpUpdateOne, unreachable},
+		{"transaction", OpUpdateOne, transient},
+	} {
+		coll, _ := newRetryCollection(t, RetryPolicy{})
+		calls := 0
+		if err := coll.run(context.Background(), coll.newOp(tc.kind), failing(&calls, tc.err)); err == nil || calls != 1 {
+			t.Fatalf("%s: run = %v after %d calls, want an error after 1", tc.name, err, calls)
+		}
+	}
+
+	coll, _ := newRetryCollection(t, RetryPolicy{})
+	calls := 0
+	if err := coll.run(context.Background(), coll.newOp(OpFind), failing(&calls, timeout)); err != nil || calls != 2 {
+		t.Fatalf("read timeout: run = %v after %d calls, want success after 2", err, calls)
+	}
+	calls = 0
+	if err := coll.run(context.Background(), coll.newOp(OpFind), failing(&calls, unreachable)); err != nil || calls != 2 {
+		t.Fatalf("read of unreachable host: run = %v after %d calls, want success after 2", err, calls)
+	}
+	coll, _ = newRetryCollection(t, RetryPolicy{RetryWrites: true})
+	calls = 0
+	if err := coll.run(context.Background(), coll.newOp(OpUpdateOne), failing(&calls, timeout, unreachable)); err != nil || calls != 3 {
+		t.Fatalf("RetryWrites: run = %v after %d calls, want success after 3", err, calls)
+	}
+}
+
+func TestRetry_Deadline(t *testing.T) {
+	coll, sleeps := newRetryCollection(t, RetryPolicy{BaseDelay: 50 * time.Millisecond, MinAttemptTime: 10 * time.Millisecond})
+	ctx, cancel := context.WithTimeout(context.Background(), 40*time.Millisecond)
+	defer cancel()
+	calls := 0
+	err := coll.run(ctx, coll.newOp(OpFind), failing(&calls, errStepDown))
+	if !isStepDown(err) || calls != 1 || len(*sleeps) != 0 {
+		t.Fatalf("run = %v after %d calls and sleeps %v, want no retry past the deadline", err, calls, *sleeps)
+	}
+
+	coll, _ = newRetryCollection(t, RetryPolicy{AttemptTimeout: time.Hour})
+	ctx, cancel = context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	want, _ := ctx.Deadline()
+	var got time.Time
+	err = coll.run(ctx, coll.newOp(OpFind), func(ctx context.Context, op *Operation) error {
+		got, _ = ctx.Deadline()
+		return nil
+	})
+	if err != nil || !got.Equal(want) {
+		t.Fatalf("attempt deadline %v, want the caller's %v", got, want)
+	}
+}
+
+func TestRetry_Budget(t *testing.T) {
+	budget := NewRetryBudget(0.5, 2)
+	db := newTestCollection(t, "orders", WithRetry(RetryPolicy{MaxAttempts: 10, Budget: budget})).db
+	coll := db.NewCollection("orders")
+	coll.settings.retry.sleep = func(context.Context, time.Duration) error { return nil }
+
+	calls := 0
+	always := func(ctx context.Context, op *Operation) error {
+		calls++
+		return errStepDown
+	}
+	// Two retries from the reserve, which the deposit cannot exceed.
+	if err := coll.run(context.Background(), coll.newOp(OpFind), always); err == nil || calls != 3 {
+		t.Fatalf("run = %v after %d calls, want 3 within the budget", err, calls)
+	}
+	other := db.NewCollection("items")
+	calls = 0
+	other.run(context.Background(), other.newOp(OpFind), always)
+	other.run(context.Background(), other.newOp(OpFind), always)
+	if calls != 3 {
+		t.Fatalf("%d calls, want 3 once the shared budget refilled one token", calls)
+	}
+}
+
+func TestRetry_FindEachDelivered(t *testing.T) {
+	coll, _ := newRetryCollection(t, RetryPolicy{})
+	calls := 0
+	err := coll.run(context.Background(), coll.newOp(OpFind), func(ctx context.Context, op *Operation) error {
+		calls++
+		markDelivered(ctx)
+		return errStepDown
+	})
+	if err == nil || calls != 1 {
+		t.Fatalf("run = %v after %d calls, want no retry once documents were delivered", err, calls)
+	}
+}
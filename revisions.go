@@ -0,0 +1,200 @@
+package mongoboiler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// RevisionsSuffix is appended to a collection's name to get the collection its revisions are
+// stored in.
+const RevisionsSuffix = "_revisions"
+
+// ErrNoRevision is returned by RevertTo when the document has no revision with that version.
+var ErrNoRevision = errors.New("mongoboiler: no such revision")
+
+// Revision is a stored version of a document, as it was before an update, replacement or
+// delete.
+type Revision struct {
+	ID         primitive.ObjectID `bson:"_id"`
+	DocumentID any                `bson:"documentId"`
+	// Version numbers the revisions of a document from 1.
+	Version   int64     `bson:"version"`
+	At        time.Time `bson:"at"`
+	Operation OpKind    `bson:"operation"`
+	// OperationID is the ID of the operation that replaced this version.
+	OperationID string   `bson:"operationId"`
+	Document    bson.Raw `bson:"document"`
+}
+
+// Decode decodes the stored document into v.
+func (r Revision) Decode(v any) error {
+	return bson.Unmarshal(r.Document, v)
+}
+
+// WithRevisions keeps the previous version of every document changed by an update, replace or
+// delete in the collection named after it with RevisionsSuffix, for History and RevertTo.
+// Revisions are stored once the write succeeded; documents changed by a multi-document write
+// are held in memory until then. Register it after middleware that rewrites operations, such
+// as WithTenancy.
+func WithRevisions() Option {
+	r := &revisioner{
+		snapshot: snapshotDocuments,
+		store: func(ctx context.Context, revisions *mongo.Collection, docs []any) error {
+			_, err := revisions.InsertMany(ctx, docs)
+			return err
+		},
+		nextVersion: nextRevisionVersion,
+	}
+	return WithMiddleware(r.middleware)
+}
+
+type revisioner struct {
+	snapshot    func(ctx context.Context, target *mongo.Collection, filter bson.D, limit int64) ([]bson.Raw, error)
+	store       func(ctx context.Context, revisions *mongo.Collection, docs []any) error
+	nextVersion func(ctx context.Context, revisions *mongo.Collection, id any) (int64, error)
+}
+
+func (r *revisioner) middleware(next Handler) Handler {
+	return func(ctx context.Context, op *Operation) error {
+		var limit int64
+		switch op.Kind {
+		case OpUpdateOne, OpReplaceOne, OpDeleteOne:
+			limit = 1
+		case OpUpdateMany, OpDeleteMany:
+		default:
+			return next(ctx, op)
+		}
+		if op.dryRun {
+			return next(ctx, op)
+		}
+		before, err := r.snapshot(ctx, op.Target, nonNilFilter(op.Filter), limit)
+		if err != nil {
+			return err
+		}
+		if err := next(ctx, op); err != nil {
+			return err
+		}
+		if len(before) == 0 {
+			return nil
+		}
+
+		revisions := revisionsOf(op)
+		now := time.Now().UTC()
+		docs := make([]any, 0, len(before))
+		for _, doc := range before {
+			id := doc.Lookup("_id")
+			version, err := r.nextVersion(ctx, revisions, id)
+			if err != nil {
+				return fmt.Errorf("mongoboiler: storing revision: %w", err)
+			}
+			docs = append(docs, Revision{
+				ID:          primitive.NewObjectID(),
+				DocumentID:  id,
+				Version:     version,
+				At:          now,
+				Operation:   op.Kind,
+				OperationID: op.ID,
+				Document:    doc,
+			})
+		}
+		if err := r.store(ctx, revisions, docs); err != nil {
+			return fmt.Errorf("mongoboiler: storing revision: %w", err)
+		}
+		return nil
+	}
+}
+
+// nextRevisionVersion returns the version the next revision of document id gets. Concurrent
+// writes of one document may get the same version; History orders them by time as well.
+func nextRevisionVersion(ctx context.Context, revisions *mongo.Collection, id any) (int64, error) {
+	var last struct {
+		Version int64 `bson:"version"`
+	}
+	err := revisions.FindOne(ctx, bson.D{{Key: "documentId", Value: id}},
+		options.FindOne().SetSort(bson.D{{Key: "version", Value: -1}}).SetProjection(bson.D{{Key: "version", Value: 1}})).Decode(&last)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return 1, nil
+	}
+	return last.Version + 1, err
+}
+
+// revisions returns the collection revisions of c are stored in.
+func (c Collection) revisions() *mongo.Collection {
+	return c.db.database().Collection(c.collectionName() + RevisionsSuffix)
+}
+
+// EnsureRevisionIndexes creates the index History and revisioning rely on.
+func (c Collection) EnsureRevisionIndexes(ctx context.Context) error {
+	_, err := c.revisions().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "documentId", Value: 1}, {Key: "version", Value: 1}},
+	})
+	return err
+}
+
+// revisionsOf returns the collection the revisions of documents op targets are stored in.
+func revisionsOf(op *Operation) *mongo.Collection {
+	return op.Target.Database().Collection(op.Collection + RevisionsSuffix)
+}
+
+// withRevisions runs fn through the middleware chain as a find of the document with the given
+// _id, so it gets the revisions collection and filter of the target middleware such as
+// WithTenancy resolve. Conditions middleware adds to the filter are matched against the stored
+// documents.
+func (c Collection) withRevisions(ctx context.Context, id any, fn func(context.Context, *mongo.Collection, bson.D) error) error {
+	op := c.newOp(OpFind)
+	op.Filter = bson.D{{Key: "_id", Value: id}}
+	return c.run(ctx, op, func(ctx context.Context, op *Operation) error {
+		filter := make(bson.D, 0, len(op.Filter))
+		for _, e := range op.Filter {
+			if e.Key == "_id" {
+				filter = append(filter, bson.E{Key: "documentId", Value: e.Value})
+			} else {
+				filter = append(filter, bson.E{Key: "document." + e.Key, Value: e.Value})
+			}
+		}
+		return fn(ctx, revisionsOf(op), filter)
+	})
+}
+
+// History returns the stored revisions of the document with the given _id, oldest first.
+func (c Collection) History(ctx context.Context, id any) ([]Revision, error) {
+	var revisions []Revision
+	err := c.withRevisions(ctx, id, func(ctx context.Context, coll *mongo.Collection, filter bson.D) error {
+		cursor, err := coll.Find(ctx, filter,
+			options.Find().SetSort(bson.D{{Key: "version", Value: 1}, {Key: "at", Value: 1}}))
+		if err != nil {
+			return err
+		}
+		return cursor.All(ctx, &revisions)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+// RevertTo replaces the document with the given _id by its revision with version, recreating
+// it if it was deleted. With WithRevisions the current version is kept as a new revision.
+func (c Collection) RevertTo(ctx context.Context, id any, version int64) error {
+	var rev Revision
+	err := c.withRevisions(ctx, id, func(ctx context.Context, coll *mongo.Collection, filter bson.D) error {
+		filter = append(filter, bson.E{Key: "version", Value: version})
+		err := coll.FindOne(ctx, filter, options.FindOne().SetSort(bson.D{{Key: "at", Value: -1}})).Decode(&rev)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return ErrNoRevision
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	_, err = c.ReplaceOne(ctx, bson.D{{Key: "_id", Value: id}}, rev.Document, options.Replace().SetUpsert(true))
+	return err
+}
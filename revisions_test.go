@@ -0,0 +1,91 @@
+package mongoboiler
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestRevisions_StoresPreviousVersion(t *testing.T) {
+	current := mustRaw(t, bson.D{{Key: "_id", Value: 7}, {Key: "title", Value: "draft"}})
+	var stored []any
+	var storedIn string
+	r := &revisioner{
+		snapshot: func(ctx context.Context, target *mongo.Collection, filter bson.D, limit int64) ([]bson.Raw, error) {
+			if limit != 1 {
+				t.Fatalf("single document writes must snapshot one document, got limit %d", limit)
+			}
+			return []bson.Raw{current}, nil
+		},
+		store: func(ctx context.Context, revisions *mongo.Collection, docs []any) error {
+			stored, storedIn = append(stored, docs...), revisions.Name()
+			return nil
+		},
+		nextVersion: func(ctx context.Context, revisions *mongo.Collection, id any) (int64, error) { return 3, nil },
+	}
+	coll := newTestCollection(t, "posts", WithMiddleware(r.middleware))
+
+	op := coll.newOp(OpUpdateOne)
+	op.Filter = bson.D{{Key: "_id", Value: 7}}
+	if err := coll.run(context.Background(), op, func(context.Context, *Operation) error { return nil }); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	if len(stored) != 1 || storedIn != "posts_revisions" {
+		t.Fatalf("expected one revision in posts_revisions, got %d in %s", len(stored), storedIn)
+	}
+	rev := stored[0].(Revision)
+	var post struct {
+		Title string `bson:"title"`
+	}
+	if rev.Version != 3 || rev.Operation != OpUpdateOne || rev.Decode(&post) != nil || post.Title != "draft" {
+		t.Fatalf("unexpected revision %+v", rev)
+	}
+
+	stored = nil
+	op = coll.newOp(OpDeleteOne)
+	failed := errors.New("boom")
+	if err := coll.run(context.Background(), op, func(context.Context, *Operation) error { return failed }); !errors.Is(err, failed) {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(stored) != 0 {
+		t.Fatalf("failed writes must not store revisions")
+	}
+}
+
+func TestRevisions_HistoryFollowsTenancy(t *testing.T) {
+	ctx := ContextWithTenant(context.Background(), "acme")
+	prefixed := newTestCollection(t, "posts", WithTenancy(Tenancy{Strategy: TenantByCollectionPrefix}))
+	var name string
+	var filter bson.D
+	err := prefixed.withRevisions(ctx, 7, func(ctx context.Context, revisions *mongo.Collection, f bson.D) error {
+		name, filter = revisions.Name(), f
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("withRevisions failed: %v", err)
+	}
+	if name != "acme_posts_revisions" || !reflect.DeepEqual(filter, bson.D{{Key: "documentId", Value: 7}}) {
+		t.Fatalf("unexpected revisions %s with filter %v", name, filter)
+	}
+
+	shared := newTestCollection(t, "posts", WithTenancy(Tenancy{}))
+	err = shared.withRevisions(ctx, 7, func(ctx context.Context, revisions *mongo.Collection, f bson.D) error {
+		name, filter = revisions.Name(), f
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("withRevisions failed: %v", err)
+	}
+	want := bson.D{{Key: "documentId", Value: 7}, {Key: "document." + DefaultTenantField, Value: "acme"}}
+	if name != "posts_revisions" || !reflect.DeepEqual(filter, want) {
+		t.Fatalf("unexpected revisions %s with filter %v", name, filter)
+	}
+
+	if err := shared.withRevisions(context.Background(), 7, nil); !errors.Is(err, ErrNoTenant) {
+		t.Fatalf("expected ErrNoTenant, got %v", err)
+	}
+}
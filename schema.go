@@ -0,0 +1,340 @@
+package mongoboiler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsoncodec"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ValidationLevel controls which writes the server validates.
+type ValidationLevel string
+
+// Validation levels, see the MongoDB validationLevel collection option.
+const (
+	ValidationOff      ValidationLevel = "off"
+	ValidationStrict   ValidationLevel = "strict"
+	ValidationModerate ValidationLevel = "moderate"
+)
+
+// ValidationAction controls what the server does with invalid documents.
+type ValidationAction string
+
+// Validation actions, see the MongoDB validationAction collection option.
+const (
+	ValidationError ValidationAction = "error"
+	ValidationWarn  ValidationAction = "warn"
+)
+
+// ErrNoSchema is returned by ApplyValidator when the collection has no schema configured.
+var ErrNoSchema = errors.New("mongoboiler: collection has no schema, use WithSchema")
+
+// WithSchema sets the $jsonSchema the collection's documents must satisfy, applied to the server
+// with ApplyValidator.
+func WithSchema(schema bson.D) Option {
+	return func(s *settings) {
+		s.schema = schema
+	}
+}
+
+// SchemaOf derives a $jsonSchema document from struct v, mapping Go types to BSON types through
+// the bson tags. Fields are required unless they are pointers, Optional or tagged omitempty.
+// The schema tag adds constraints, separated by commas:
+//
+//	Name  string `bson:"name" schema:"minLength=1,maxLength=64"`
+//	Role  string `bson:"role" schema:"enum=admin|user"`
+//	Age   int    `bson:"age,omitempty" schema:"minimum=0,description=age in years"`
+//	Notes string `bson:"notes" schema:"optional"`
+//
+// Supported are required, optional, enum, minimum, maximum, minLength, maxLength, minItems,
+// maxItems, pattern and description. Values cannot contain commas. Fields of recursive types
+// are only checked to be objects.
+func SchemaOf(v any) (bson.D, error) {
+	rt := reflect.TypeOf(v)
+	for rt != nil && rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+	if rt == nil || rt.Kind() != reflect.Struct {
+		return nil, ErrNotStruct
+	}
+	return objectSchema(rt, map[reflect.Type]bool{})
+}
+
+// MustSchemaOf is like SchemaOf but panics on error, for use in package level variables.
+func MustSchemaOf(v any) bson.D {
+	schema, err := SchemaOf(v)
+	if err != nil {
+		panic(err)
+	}
+	return schema
+}
+
+// ApplyValidator sets the collection's schema (see WithSchema) as its server side validator,
+// creating the collection if it does not exist yet.
+func (c Collection) ApplyValidator(ctx context.Context, level ValidationLevel, action ValidationAction) error {
+	if c.settings == nil || c.settings.schema == nil {
+		return ErrNoSchema
+	}
+	validator := bson.D{{Key: "$jsonSchema", Value: c.settings.schema}}
+
+	db := c.db.database()
+	cmd := bson.D{
+		{Key: "collMod", Value: c.collectionName()},
+		{Key: "validator", Value: validator},
+		{Key: "validationLevel", Value: string(level)},
+		{Key: "validationAction", Value: string(action)},
+	}
+	err := db.RunCommand(ctx, cmd).Err()
+	var cmdErr mongo.CommandError
+	if !errors.As(err, &cmdErr) || cmdErr.Code != namespaceNotFound {
+		return err
+	}
+	return db.CreateCollection(ctx, c.collectionName(), options.CreateCollection().
+		SetValidator(validator).
+		SetValidationLevel(string(level)).
+		SetValidationAction(string(action)))
+}
+
+// namespaceNotFound is the server error code for a missing collection.
+const namespaceNotFound = 26
+
+// SchemaModel describes the validator of one collection for SyncValidators.
+type SchemaModel struct {
+	Collection string
+	// Model is a struct the schema is derived from with SchemaOf, unless Schema is set.
+	Model  any
+	Schema bson.D
+	// Level and Action default to strict and error.
+	Level  ValidationLevel
+	Action ValidationAction
+}
+
+// SyncValidators applies the validator of every model, e.g. at startup.
+func (db *DB) SyncValidators(ctx context.Context, models ...SchemaModel) error {
+	for _, m := range models {
+		schema := m.Schema
+		if schema == nil {
+			var err error
+			if schema, err = SchemaOf(m.Model); err != nil {
+				return fmt.Errorf("mongoboiler: schema for %s: %w", m.Collection, err)
+			}
+		}
+		level, action := m.Level, m.Action
+		if level == "" {
+			level = ValidationStrict
+		}
+		if action == "" {
+			action = ValidationError
+		}
+		if err := db.NewCollection(m.Collection, WithSchema(schema)).ApplyValidator(ctx, level, action); err != nil {
+			return fmt.Errorf("mongoboiler: validator for %s: %w", m.Collection, err)
+		}
+	}
+	return nil
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	objectIDType   = reflect.TypeOf(primitive.ObjectID{})
+	decimalType    = reflect.TypeOf(primitive.Decimal128{})
+	dateTimeType   = reflect.TypeOf(primitive.DateTime(0))
+	bsonDType      = reflect.TypeOf(bson.D{})
+	geoPointType   = reflect.TypeOf(GeoPoint{})
+	optionalIfType = reflect.TypeOf((*optionalField)(nil)).Elem()
+)
+
+// objectSchema returns the schema of struct type rt. Types on the path from the root are in
+// seen: $jsonSchema has no references, so recursive fields are described as a plain object.
+func objectSchema(rt reflect.Type, seen map[reflect.Type]bool) (bson.D, error) {
+	if seen[rt] {
+		return bson.D{{Key: "bsonType", Value: "object"}}, nil
+	}
+	seen[rt] = true
+	defer delete(seen, rt)
+	props, required := bson.D{}, bson.A{}
+	if err := collectSchemaFields(rt, &props, &required, seen); err != nil {
+		return nil, err
+	}
+	schema := bson.D{{Key: "bsonType", Value: "object"}}
+	if len(required) > 0 {
+		schema = append(schema, bson.E{Key: "required", Value: required})
+	}
+	return append(schema, bson.E{Key: "properties", Value: props}), nil
+}
+
+func collectSchemaFields(rt reflect.Type, props *bson.D, required *bson.A, seen map[reflect.Type]bool) error {
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		tags, err := bsoncodec.DefaultStructTagParser(sf)
+		if err != nil {
+			return err
+		}
+		if tags.Skip {
+			continue
+		}
+		ft := sf.Type
+		if tags.Inline {
+			for ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct && !seen[ft] {
+				seen[ft] = true
+				err := collectSchemaFields(ft, props, required, seen)
+				delete(seen, ft)
+				if err != nil {
+					return err
+				}
+			}
+			// Inline maps hold arbitrary fields, nothing to describe.
+			continue
+		}
+
+		prop, nullable, err := typeSchema(ft, seen)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", sf.Name, err)
+		}
+		isRequired := !nullable && !tags.OmitEmpty
+		if tag, ok := sf.Tag.Lookup("schema"); ok {
+			if prop, isRequired, err = applySchemaTag(prop, isRequired, tag); err != nil {
+				return fmt.Errorf("field %s: %w", sf.Name, err)
+			}
+		}
+		if isRequired {
+			*required = append(*required, tags.Name)
+		}
+		*props = append(*props, bson.E{Key: tags.Name, Value: prop})
+	}
+	return nil
+}
+
+// typeSchema returns the schema of values of type t and whether they may be null.
+func typeSchema(t reflect.Type, seen map[reflect.Type]bool) (bson.D, bool, error) {
+	if t.Implements(optionalIfType) {
+		inner, _, err := typeSchema(t.Field(0).Type, seen)
+		return withNull(inner), true, err
+	}
+	switch t {
+	case timeType, dateTimeType:
+		return bson.D{{Key: "bsonType", Value: "date"}}, false, nil
+	case objectIDType:
+		return bson.D{{Key: "bsonType", Value: "objectId"}}, false, nil
+	case decimalType:
+		return bson.D{{Key: "bsonType", Value: "decimal"}}, false, nil
+	case geoPointType:
+		return bson.D{
+			{Key: "bsonType", Value: "object"},
+			{Key: "required", Value: bson.A{"type", "coordinates"}},
+		}, false, nil
+	case bsonDType:
+		return bson.D{{Key: "bsonType", Value: "object"}}, true, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		inner, _, err := typeSchema(t.Elem(), seen)
+		return withNull(inner), true, err
+	case reflect.Interface:
+		// Any BSON type is allowed.
+		return bson.D{}, true, nil
+	case reflect.String:
+		return bson.D{{Key: "bsonType", Value: "string"}}, false, nil
+	case reflect.Bool:
+		return bson.D{{Key: "bsonType", Value: "bool"}}, false, nil
+	case reflect.Float32, reflect.Float64:
+		return bson.D{{Key: "bsonType", Value: "double"}}, false, nil
+	case reflect.Int64:
+		return bson.D{{Key: "bsonType", Value: "long"}}, false, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		// The default encoder picks int or long depending on the value.
+		return bson.D{{Key: "bsonType", Value: bson.A{"int", "long"}}}, false, nil
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return bson.D{{Key: "bsonType", Value: "binData"}}, t.Kind() == reflect.Slice, nil
+		}
+		items, _, err := typeSchema(t.Elem(), seen)
+		if err != nil {
+			return nil, false, err
+		}
+		return bson.D{{Key: "bsonType", Value: "array"}, {Key: "items", Value: items}}, t.Kind() == reflect.Slice, nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, false, fmt.Errorf("unsupported map key type %s", t.Key())
+		}
+		values, _, err := typeSchema(t.Elem(), seen)
+		if err != nil {
+			return nil, false, err
+		}
+		return bson.D{{Key: "bsonType", Value: "object"}, {Key: "additionalProperties", Value: values}}, true, nil
+	case reflect.Struct:
+		schema, err := objectSchema(t, seen)
+		return schema, false, err
+	}
+	return nil, false, fmt.Errorf("unsupported type %s", t)
+}
+
+// withNull allows null in addition to the types of schema.
+func withNull(schema bson.D) bson.D {
+	out := append(bson.D(nil), schema...)
+	for i, e := range out {
+		if e.Key != "bsonType" {
+			continue
+		}
+		switch v := e.Value.(type) {
+		case string:
+			out[i].Value = bson.A{v, "null"}
+		case bson.A:
+			out[i].Value = append(append(bson.A{}, v...), "null")
+		}
+	}
+	return out
+}
+
+func applySchemaTag(prop bson.D, required bool, tag string) (bson.D, bool, error) {
+	prop = append(bson.D(nil), prop...)
+	for _, part := range strings.Split(tag, ",") {
+		key, value, hasValue := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "":
+		case "required":
+			required = true
+		case "optional":
+			required = false
+		case "enum":
+			enum := bson.A{}
+			for _, v := range strings.Split(value, "|") {
+				enum = append(enum, v)
+			}
+			prop = append(prop, bson.E{Key: "enum", Value: enum})
+		case "pattern", "description":
+			prop = append(prop, bson.E{Key: key, Value: value})
+		case "minimum", "maximum":
+			n, err := strconv.ParseFloat(value, 64)
+			if err != nil || !hasValue {
+				return nil, false, fmt.Errorf("schema tag %s needs a number", key)
+			}
+			prop = append(prop, bson.E{Key: key, Value: n})
+		case "minLength", "maxLength", "minItems", "maxItems":
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || !hasValue {
+				return nil, false, fmt.Errorf("schema tag %s needs an integer", key)
+			}
+			prop = append(prop, bson.E{Key: key, Value: n})
+		default:
+			return nil, false, fmt.Errorf("unknown schema tag %q", key)
+		}
+	}
+	return prop, required, nil
+}
@@ -0,0 +1,103 @@
+package mongoboiler
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type schemaAddress struct {
+	City string `bson:"city"`
+}
+
+type schemaUser struct {
+	ID        primitive.ObjectID `bson:"_id"`
+	Versioned `bson:",inline"`
+	Name      string           `bson:"name" schema:"minLength=1"`
+	Role      string           `bson:"role" schema:"enum=admin|user"`
+	Age       int              `bson:"age,omitempty"`
+	Tags      []string         `bson:"tags"`
+	Address   *schemaAddress   `bson:"address"`
+	Nickname  Optional[string] `bson:"nickname,omitempty"`
+	CreatedAt time.Time        `bson:"createdAt"`
+}
+
+func TestSchemaOf(t *testing.T) {
+	schema, err := SchemaOf(schemaUser{})
+	if err != nil {
+		t.Fatalf("SchemaOf failed: %v", err)
+	}
+	m := schema.Map()
+	wantRequired := bson.A{"_id", "version", "name", "role", "createdAt"}
+	if !reflect.DeepEqual(m["required"], wantRequired) {
+		t.Fatalf("unexpected required: %v", m["required"])
+	}
+	props := m["properties"].(bson.D).Map()
+	want := map[string]bson.D{
+		"name":     {{Key: "bsonType", Value: "string"}, {Key: "minLength", Value: int64(1)}},
+		"role":     {{Key: "bsonType", Value: "string"}, {Key: "enum", Value: bson.A{"admin", "user"}}},
+		"tags":     {{Key: "bsonType", Value: "array"}, {Key: "items", Value: bson.D{{Key: "bsonType", Value: "string"}}}},
+		"nickname": {{Key: "bsonType", Value: bson.A{"string", "null"}}},
+		"address": {
+			{Key: "bsonType", Value: bson.A{"object", "null"}},
+			{Key: "required", Value: bson.A{"city"}},
+			{Key: "properties", Value: bson.D{{Key: "city", Value: bson.D{{Key: "bsonType", Value: "string"}}}}},
+		},
+	}
+	for name, w := range want {
+		if !reflect.DeepEqual(props[name], w) {
+			t.Fatalf("unexpected schema for %s: %v", name, props[name])
+		}
+	}
+}
+
+type schemaCategory struct {
+	Name     string                    `bson:"name"`
+	Parent   *schemaCategory           `bson:"parent"`
+	Children []schemaCategory          `bson:"children"`
+	Address  schemaAddress             `bson:"address"`
+	Related  map[string]schemaCategory `bson:"related"`
+}
+
+func TestSchemaOf_Recursive(t *testing.T) {
+	schema, err := SchemaOf(schemaCategory{})
+	if err != nil {
+		t.Fatalf("SchemaOf failed: %v", err)
+	}
+	props := schema.Map()["properties"].(bson.D).Map()
+	object := bson.D{{Key: "bsonType", Value: "object"}}
+	want := map[string]bson.D{
+		"parent":   {{Key: "bsonType", Value: bson.A{"object", "null"}}},
+		"children": {{Key: "bsonType", Value: "array"}, {Key: "items", Value: object}},
+		"related":  {{Key: "bsonType", Value: "object"}, {Key: "additionalProperties", Value: object}},
+	}
+	for name, w := range want {
+		if !reflect.DeepEqual(props[name], w) {
+			t.Fatalf("unexpected schema for %s: %v", name, props[name])
+		}
+	}
+	if address := props["address"].(bson.D).Map(); address["required"] == nil {
+		t.Fatalf("expected other struct fields to be described in full, got %v", address)
+	}
+}
+
+func TestSchemaOf_InvalidTag(t *testing.T) {
+	type bad struct {
+		Name string `bson:"name" schema:"minLength=x"`
+	}
+	if _, err := SchemaOf(bad{}); err == nil {
+		t.Fatalf("expected an error for a malformed schema tag")
+	}
+}
+
+func TestApplyValidator_NoSchema(t *testing.T) {
+	coll := newTestCollection(t, "users")
+	if err := coll.ApplyValidator(context.Background(), ValidationStrict, ValidationError); !errors.Is(err, ErrNoSchema) {
+		t.Fatalf("expected ErrNoSchema, got %v", err)
+	}
+}
@@ -0,0 +1,134 @@
+package mongoboiler
+
+import (
+	"context"
+	"reflect"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// TextScoreField is the field TextSearch and SearchBuilder store the relevance score in;
+// add it to result structs to read it, e.g. Score float64 `bson:"score"`.
+const TextScoreField = "score"
+
+// Aggregate runs pipeline and fills res, a pointer to a slice, with the resulting documents.
+func (c Collection) Aggregate(ctx context.Context, pipeline mongo.Pipeline, res any, opts ...*options.AggregateOptions) error {
+	if v := reflect.ValueOf(res); v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Slice {
+		return ErrNotSlicePointer
+	}
+	op := c.newOp(OpAggregate)
+	op.Pipeline = pipeline
+	foldCallComment(op, opts)
+	return c.run(ctx, op, func(ctx context.Context, op *Operation) error {
+		opts, err := sendComment(op, opts, options.Aggregate().SetComment(op.Comment))
+		if err != nil {
+			return err
+		}
+		cursor, err := op.Target.Aggregate(ctx, op.Pipeline, opts...)
+		if err != nil {
+			return err
+		}
+		defer cursor.Close(context.Background())
+
+		sliceVal := reflect.ValueOf(res).Elem()
+		sliceVal.Set(sliceVal.Slice(0, 0))
+		for cursor.Next(ctx) {
+			elem := reflect.New(sliceVal.Type().Elem())
+			if err := c.decode(ctx, cursor.Current, elem.Interface()); err != nil {
+				return err
+			}
+			sliceVal.Set(reflect.Append(sliceVal, elem.Elem()))
+		}
+		return cursor.Err()
+	})
+}
+
+// TextSearch finds documents matching the $text query and fills res, a pointer to a slice, with
+// them ordered by relevance. The collection needs a text index, see EnsureTextIndex.
+// opts are applied after the score projection and sort, so they can override them.
+func (c Collection) TextSearch(ctx context.Context, query string, res any, opts ...*options.FindOptions) error {
+	score := bson.D{{Key: TextScoreField, Value: bson.D{{Key: "$meta", Value: "textScore"}}}}
+	byScore := options.Find().SetProjection(score).SetSort(score)
+	filter := bson.D{{Key: "$text", Value: bson.D{{Key: "$search", Value: query}}}}
+	return c.FindMany(ctx, filter, res, append([]*options.FindOptions{byScore}, opts...)...)
+}
+
+// EnsureTextIndex creates the text index over fields used by TextSearch. A collection can have
+// only one text index.
+func (c Collection) EnsureTextIndex(ctx context.Context, fields ...string) error {
+	keys := bson.D{}
+	for _, f := range fields {
+		keys = append(keys, bson.E{Key: f, Value: "text"})
+	}
+	_, err := c.collection().Indexes().CreateOne(ctx, mongo.IndexModel{Keys: keys})
+	return err
+}
+
+// SearchBuilder builds an Atlas Search $search stage:
+//
+//	pipeline := mongoboiler.AtlasSearch("default").Text("pizza", "name", "description").Fuzzy(1).
+//		Highlight("description").Pipeline()
+//	err := coll.Aggregate(ctx, append(pipeline, bson.D{{Key: "$limit", Value: 10}}), &results)
+type SearchBuilder struct {
+	index     string
+	operator  string
+	body      bson.D
+	fuzzy     bson.D
+	highlight []string
+}
+
+// AtlasSearch starts a $search stage on the named Atlas Search index.
+func AtlasSearch(index string) *SearchBuilder {
+	return &SearchBuilder{index: index}
+}
+
+// Text searches query in the given paths.
+func (b *SearchBuilder) Text(query string, paths ...string) *SearchBuilder {
+	b.operator = "text"
+	b.body = bson.D{{Key: "query", Value: query}, {Key: "path", Value: paths}}
+	return b
+}
+
+// Autocomplete matches query as a prefix of path, which needs an autocomplete mapping.
+func (b *SearchBuilder) Autocomplete(query, path string) *SearchBuilder {
+	b.operator = "autocomplete"
+	b.body = bson.D{{Key: "query", Value: query}, {Key: "path", Value: path}}
+	return b
+}
+
+// Fuzzy tolerates up to maxEdits (1 or 2) typos per term.
+func (b *SearchBuilder) Fuzzy(maxEdits int) *SearchBuilder {
+	b.fuzzy = bson.D{{Key: "maxEdits", Value: maxEdits}}
+	return b
+}
+
+// Highlight returns highlighted snippets of the matches in paths, in a highlights field.
+func (b *SearchBuilder) Highlight(paths ...string) *SearchBuilder {
+	b.highlight = paths
+	return b
+}
+
+// Stage returns the $search stage.
+func (b *SearchBuilder) Stage() bson.D {
+	body := append(bson.D(nil), b.body...)
+	if b.fuzzy != nil {
+		body = append(body, bson.E{Key: "fuzzy", Value: b.fuzzy})
+	}
+	search := bson.D{{Key: "index", Value: b.index}, {Key: b.operator, Value: body}}
+	if len(b.highlight) > 0 {
+		search = append(search, bson.E{Key: "highlight", Value: bson.D{{Key: "path", Value: b.highlight}}})
+	}
+	return bson.D{{Key: "$search", Value: search}}
+}
+
+// Pipeline returns the $search stage followed by one adding the score, and the highlights if
+// requested, to the documents.
+func (b *SearchBuilder) Pipeline() mongo.Pipeline {
+	fields := bson.D{{Key: TextScoreField, Value: bson.D{{Key: "$meta", Value: "searchScore"}}}}
+	if len(b.highlight) > 0 {
+		fields = append(fields, bson.E{Key: "highlights", Value: bson.D{{Key: "$meta", Value: "searchHighlights"}}})
+	}
+	return mongo.Pipeline{b.Stage(), {{Key: "$addFields", Value: fields}}}
+}
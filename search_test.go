@@ -0,0 +1,38 @@
+package mongoboiler
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestSearchBuilder_Stage(t *testing.T) {
+	stage := AtlasSearch("default").Text("piza", "name").Fuzzy(1).Highlight("name").Stage()
+	want := bson.D{{Key: "$search", Value: bson.D{
+		{Key: "index", Value: "default"},
+		{Key: "text", Value: bson.D{
+			{Key: "query", Value: "piza"},
+			{Key: "path", Value: []string{"name"}},
+			{Key: "fuzzy", Value: bson.D{{Key: "maxEdits", Value: 1}}},
+		}},
+		{Key: "highlight", Value: bson.D{{Key: "path", Value: []string{"name"}}}},
+	}}}
+	if !reflect.DeepEqual(stage, want) {
+		t.Fatalf("unexpected stage:\n%v\nwant\n%v", stage, want)
+	}
+}
+
+func TestPrependMatch_KeepsSearchFirst(t *testing.T) {
+	pipeline := AtlasSearch("default").Autocomplete("pi", "name").Pipeline()
+	scoped := prependMatch(pipeline, bson.D{{Key: "tenantId", Value: "acme"}})
+	if len(scoped) != 3 || scoped[0][0].Key != "$search" || scoped[1][0].Key != "$match" {
+		t.Fatalf("unexpected pipeline %v", scoped)
+	}
+
+	scoped = prependMatch(mongo.Pipeline{{{Key: "$limit", Value: 1}}}, bson.D{{Key: "tenantId", Value: "acme"}})
+	if scoped[0][0].Key != "$match" {
+		t.Fatalf("expected $match first, got %v", scoped)
+	}
+}
@@ -0,0 +1,180 @@
+package mongoboiler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// SelfCheckConfig lists what SelfCheck verifies. Zero fields are skipped.
+type SelfCheckConfig struct {
+	// RequiredCollections must exist in the database.
+	RequiredCollections []string
+	// RequiredIndexes maps collection names to index names that must exist on them.
+	RequiredIndexes map[string][]string
+	// MinServerVersion is the lowest acceptable server version, e.g. "6.0".
+	MinServerVersion string
+	// MinFreeStorageBytes is the minimum free space on the server's data volume as reported by dbStats.
+	MinFreeStorageBytes int64
+}
+
+// CheckResult is the outcome of a single self check.
+type CheckResult struct {
+	Name   string
+	OK     bool
+	Detail string
+}
+
+// SelfCheckReport is returned by SelfCheck.
+type SelfCheckReport struct {
+	Checks []CheckResult
+}
+
+// OK reports whether all checks passed.
+func (r SelfCheckReport) OK() bool {
+	for _, c := range r.Checks {
+		if !c.OK {
+			return false
+		}
+	}
+	return true
+}
+
+// Err returns an error describing the failed checks, or nil if all passed.
+func (r SelfCheckReport) Err() error {
+	var failed []string
+	for _, c := range r.Checks {
+		if !c.OK {
+			failed = append(failed, fmt.Sprintf("%s: %s", c.Name, c.Detail))
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	return errors.New("mongoboiler: self check failed: " + strings.Join(failed, "; "))
+}
+
+func (r *SelfCheckReport) add(name string, ok bool, format string, args ...any) {
+	r.Checks = append(r.Checks, CheckResult{Name: name, OK: ok, Detail: fmt.Sprintf(format, args...)})
+}
+
+// SelfCheck runs a battery of checks against the database, meant to be run at service startup to
+// fail fast on misconfiguration. Connectivity and authentication are always checked; if they fail
+// the remaining checks are skipped.
+func (db *DB) SelfCheck(ctx context.Context, cfg SelfCheckConfig) SelfCheckReport {
+	return db.newSelfChecker().run(ctx, cfg)
+}
+
+// selfChecker runs the checks of SelfCheck.
+type selfChecker struct {
+	db *DB
+	// Seams for tests.
+	ping        func(ctx context.Context) error
+	collections func(ctx context.Context) ([]string, error)
+	version     func(ctx context.Context) (string, error)
+	indexes     func(ctx context.Context, coll string) ([]string, error)
+	storage     func(ctx context.Context) (used, total float64, err error)
+}
+
+func (db *DB) newSelfChecker() *selfChecker {
+	return &selfChecker{
+		db: db,
+		ping: func(ctx context.Context) error {
+			return db.client().Ping(ctx, readpref.Primary())
+		},
+		collections: func(ctx context.Context) ([]string, error) {
+			return db.database().ListCollectionNames(ctx, bson.D{})
+		},
+		version: db.ServerVersion,
+		indexes: func(ctx context.Context, coll string) ([]string, error) {
+			specs, err := db.NewCollection(coll).collection().Indexes().ListSpecifications(ctx)
+			if err != nil {
+				return nil, err
+			}
+			names := make([]string, len(specs))
+			for i, spec := range specs {
+				names[i] = spec.Name
+			}
+			return names, nil
+		},
+		storage: func(ctx context.Context) (used, total float64, err error) {
+			var stats struct {
+				FsUsedSize  float64 `bson:"fsUsedSize"`
+				FsTotalSize float64 `bson:"fsTotalSize"`
+			}
+			err = db.database().RunCommand(ctx, bson.D{{Key: "dbStats", Value: 1}}).Decode(&stats)
+			return stats.FsUsedSize, stats.FsTotalSize, err
+		},
+	}
+}
+
+func (s *selfChecker) run(ctx context.Context, cfg SelfCheckConfig) SelfCheckReport {
+	var report SelfCheckReport
+
+	if err := s.ping(ctx); err != nil {
+		report.add("connection", false, "%v", err)
+		return report
+	}
+	// listCollections requires an authenticated user with access to the database.
+	names, err := s.collections(ctx)
+	if err != nil {
+		report.add("auth", false, "%v", err)
+		return report
+	}
+	report.add("auth", true, "authenticated access to %s", s.db.databaseName())
+
+	if cfg.MinServerVersion != "" {
+		version, err := s.version(ctx)
+		switch {
+		case err != nil:
+			report.add("server version", false, "%v", err)
+		case compareVersions(version, cfg.MinServerVersion) < 0:
+			report.add("server version", false, "server is %s, need at least %s", version, cfg.MinServerVersion)
+		default:
+			report.add("server version", true, "server is %s", version)
+		}
+	}
+
+	existing := make(map[string]bool, len(names))
+	for _, name := range names {
+		existing[name] = true
+	}
+	for _, name := range cfg.RequiredCollections {
+		physical := s.db.NewCollection(name).collectionName()
+		report.add("collection "+name, existing[physical], "exists: %v", existing[physical])
+	}
+
+	for coll, indexes := range cfg.RequiredIndexes {
+		names, err := s.indexes(ctx, coll)
+		if err != nil {
+			report.add("indexes "+coll, false, "%v", err)
+			continue
+		}
+		have := make(map[string]bool, len(names))
+		for _, name := range names {
+			have[name] = true
+		}
+		for _, index := range indexes {
+			report.add("index "+coll+"."+index, have[index], "exists: %v", have[index])
+		}
+	}
+
+	if cfg.MinFreeStorageBytes > 0 {
+		used, total, err := s.storage(ctx)
+		switch {
+		case err != nil:
+			report.add("storage", false, "%v", err)
+		case total == 0:
+			report.add("storage", false, "dbStats did not report filesystem sizes")
+		default:
+			free := int64(total - used)
+			report.add("storage", free >= cfg.MinFreeStorageBytes, "%d bytes free, need %d", free, cfg.MinFreeStorageBytes)
+		}
+	}
+
+	return report
+}
@@ -0,0 +1,105 @@
+package mongoboiler
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSelfCheck_Outcomes(t *testing.T) {
+	boom := errors.New("boom")
+	cfg := SelfCheckConfig{
+		RequiredCollections: []string{"orders", "users"},
+		RequiredIndexes:     map[string][]string{"orders": {"status_1"}},
+		MinServerVersion:    "6.0",
+		MinFreeStorageBytes: 100,
+	}
+	tests := []struct {
+		name  string
+		setup func(s *selfChecker)
+		cfg   SelfCheckConfig
+		want  map[string]bool
+	}{
+		{
+			name: "healthy",
+			cfg:  cfg,
+			want: map[string]bool{
+				"auth": true, "server version": true, "collection orders": true, "collection users": true,
+				"index orders.status_1": true, "storage": true,
+			},
+		},
+		{
+			name:  "unreachable",
+			setup: func(s *selfChecker) { s.ping = func(context.Context) error { return boom } },
+			cfg:   cfg,
+			want:  map[string]bool{"connection": false},
+		},
+		{
+			name: "unauthorized",
+			setup: func(s *selfChecker) {
+				s.collections = func(context.Context) ([]string, error) { return nil, boom }
+			},
+			cfg:  cfg,
+			want: map[string]bool{"auth": false},
+		},
+		{
+			name: "misconfigured",
+			setup: func(s *selfChecker) {
+				s.collections = func(context.Context) ([]string, error) { return []string{"orders"}, nil }
+				s.version = func(context.Context) (string, error) { return "5.0.14", nil }
+				s.indexes = func(context.Context, string) ([]string, error) { return []string{"_id_"}, nil }
+				s.storage = func(context.Context) (float64, float64, error) { return 950, 1000, nil }
+			},
+			cfg: cfg,
+			want: map[string]bool{
+				"auth": true, "server version": false, "collection orders": true, "collection users": false,
+				"index orders.status_1": false, "storage": false,
+			},
+		},
+		{
+			name: "failing checks",
+			setup: func(s *selfChecker) {
+				s.version = func(context.Context) (string, error) { return "", boom }
+				s.indexes = func(context.Context, string) ([]string, error) { return nil, boom }
+				s.storage = func(context.Context) (float64, float64, error) { return 0, 0, nil }
+			},
+			cfg: cfg,
+			want: map[string]bool{
+				"auth": true, "server version": false, "collection orders": true, "collection users": true,
+				"indexes orders": false, "storage": false,
+			},
+		},
+		{
+			name: "nothing configured",
+			want: map[string]bool{"auth": true},
+		},
+	}
+	for _, tt := range tests {
+		s := newTestCollection(t, "orders").db.newSelfChecker()
+		s.ping = func(context.Context) error { return nil }
+		s.collections = func(context.Context) ([]string, error) { return []string{"orders", "users"}, nil }
+		s.version = func(context.Context) (string, error) { return "7.0.2", nil }
+		s.indexes = func(context.Context, string) ([]string, error) { return []string{"_id_", "status_1"}, nil }
+		s.storage = func(context.Context) (float64, float64, error) { return 100, 1000, nil }
+		if tt.setup != nil {
+			tt.setup(s)
+		}
+
+		report := s.run(context.Background(), tt.cfg)
+		got := make(map[string]bool, len(report.Checks))
+		for _, c := range report.Checks {
+			got[c.Name] = c.OK
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Fatalf("%s: checks %v, want %v", tt.name, got, tt.want)
+		}
+		healthy := true
+		for _, ok := range tt.want {
+			healthy = healthy && ok
+		}
+		if report.OK() != healthy || (report.Err() == nil) != healthy {
+			t.Fatalf("%s: OK() = %v, Err() = %v, want healthy %v", tt.name, report.OK(), report.Err(), healthy)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package mongoboiler
+
+import "testing"
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"7.0.2", "6.0", 1},
+		{"6.0", "6.0.0", 0},
+		{"5.0.14", "5.0.9", 1},
+		{"4.4.0-rc1", "4.4", 0},
+		{"4.2", "4.4", -1},
+	}
+	for _, tt := range tests {
+		if got := compareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
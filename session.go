@@ -0,0 +1,55 @@
+package mongoboiler
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// WithPinnedSession runs every operation in session, unless its context carries another one.
+// A session must not be used concurrently, so neither must the DB or collections pinned to it.
+func WithPinnedSession(session mongo.Session) Option {
+	return func(s *settings) {
+		s.session = session
+	}
+}
+
+// WithSession runs fn with a DB pinned to a new causally consistent session: within fn every
+// read through sdb, its collections and databases sees the writes made before it through them,
+// also when reading from secondaries. fn must not use sdb concurrently. The session is ended when
+// fn returns. opts override the session options, e.g. to enable snapshot reads.
+//
+// Operations whose context already carries a session, such as one passed on by WithTransaction,
+// run in that one instead.
+func (db *DB) WithSession(ctx context.Context, fn func(sdb *DB) error, opts ...*options.SessionOptions) error {
+	opts = append([]*options.SessionOptions{options.Session().SetCausalConsistency(true)}, opts...)
+	session, err := db.client().StartSession(opts...)
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(context.Background())
+	return fn(db.pinned(session))
+}
+
+// pinned returns db with its operations pinned to session.
+func (db *DB) pinned(session mongo.Session) *DB {
+	return &DB{db.name, db.conn, newSettings(db.settings, SourceCall, []Option{WithPinnedSession(session)}), db.models}
+}
+
+// Session returns the session the DB is pinned to, nil if none.
+func (db *DB) Session() mongo.Session {
+	if db.settings == nil {
+		return nil
+	}
+	return db.settings.session
+}
+
+// sessionContext returns ctx carrying the pinned session of the collection if it has one and
+// ctx none.
+func (c Collection) sessionContext(ctx context.Context) context.Context {
+	if c.settings == nil || c.settings.session == nil || mongo.SessionFromContext(ctx) != nil {
+		return ctx
+	}
+	return mongo.NewSessionContext(ctx, c.settings.session)
+}
@@ -0,0 +1,70 @@
+package mongoboiler
+
+import (
+	"context"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// testSession stands in for a driver session, which needs a connected client.
+type testSession struct {
+	mongo.Session
+	name string
+}
+
+func TestWithSession_PinsOperations(t *testing.T) {
+	var sessions []mongo.Session
+	db := newTestCollection(t, "unused", WithMiddleware(func(next Handler) Handler {
+		return func(ctx context.Context, op *Operation) error {
+			sessions = append(sessions, mongo.SessionFromContext(ctx))
+			return nil
+		}
+	})).db
+
+	// WithSession needs a connected client, so pin a stand-in session the same way.
+	var pinned mongo.Session = &testSession{name: "pinned"}
+	sdb := db.pinned(pinned)
+	if sdb.Session() != pinned {
+		t.Fatalf("expected sdb to be pinned to the session")
+	}
+	if _, err := sdb.NewCollection("orders").InsertOne(context.Background(), decodeItem{Name: "pen"}); err != nil {
+		t.Fatalf("InsertOne failed: %v", err)
+	}
+	if _, err := sdb.Database("other").NewCollection("orders").DeleteMany(context.Background(), nil); err != nil {
+		t.Fatalf("DeleteMany failed: %v", err)
+	}
+	if len(sessions) != 2 || sessions[0] != pinned || sessions[1] != pinned {
+		t.Fatalf("expected both operations in the pinned session, got %v", sessions)
+	}
+	if db.Session() != nil {
+		t.Fatalf("WithSession pinned the original DB")
+	}
+
+	sessions = nil
+	if _, err := db.NewCollection("orders").DeleteMany(context.Background(), nil); err != nil {
+		t.Fatalf("DeleteMany failed: %v", err)
+	}
+	if sessions[0] != nil {
+		t.Fatalf("expected no session outside WithSession")
+	}
+}
+
+func TestWithSession_ContextSessionWins(t *testing.T) {
+	var got mongo.Session
+	db := newTestCollection(t, "unused", WithMiddleware(func(next Handler) Handler {
+		return func(ctx context.Context, op *Operation) error {
+			got = mongo.SessionFromContext(ctx)
+			return nil
+		}
+	})).db
+	inner := &testSession{name: "inner"}
+	ctx := mongo.NewSessionContext(context.Background(), inner)
+
+	if _, err := db.pinned(&testSession{name: "pinned"}).NewCollection("orders").DeleteMany(ctx, nil); err != nil {
+		t.Fatalf("DeleteMany failed: %v", err)
+	}
+	if got != inner {
+		t.Fatalf("expected the session of the context to be used")
+	}
+}
@@ -0,0 +1,151 @@
+package mongoboiler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ErrShardKeyMissing matches every ShardKeyError with errors.Is.
+var ErrShardKeyMissing = errors.New("mongoboiler: filter lacks the shard key")
+
+// ShardKeyError is returned for single document writes whose filter does not include the shard
+// key set with WithShardKey.
+type ShardKeyError struct {
+	Op         OpKind
+	Database   string
+	Collection string
+	// Missing are the shard key fields the filter does not constrain.
+	Missing []string
+}
+
+func (e *ShardKeyError) Error() string {
+	return fmt.Sprintf("mongoboiler: %s on %s.%s must filter on the shard key, missing %s",
+		e.Op, e.Database, e.Collection, strings.Join(e.Missing, ", "))
+}
+
+// Is makes errors.Is(err, ErrShardKeyMissing) true.
+func (e *ShardKeyError) Is(target error) bool {
+	return target == ErrShardKeyMissing
+}
+
+// Code returns CodeInvalidArgument.
+func (e *ShardKeyError) Code() ErrorCode {
+	return CodeInvalidArgument
+}
+
+// EnableSharding allows the collections of the database to be sharded. Servers from 6.0 on do
+// not need it, they accept ShardCollection right away.
+func (db *DB) EnableSharding(ctx context.Context) error {
+	return db.client().Database("admin").RunCommand(ctx, bson.D{{Key: "enableSharding", Value: db.databaseName()}}).Err()
+}
+
+// ShardCollection shards the collection by key, e.g. bson.D{{Key: "region", Value: 1},
+// {Key: "customerId", Value: 1}} for ranged sharding. With hashed the first field of key is
+// hashed instead, spreading monotonically increasing values like ObjectIDs over the shards. The
+// collection is created when missing; if it has documents, an index on key must exist.
+func (c Collection) ShardCollection(ctx context.Context, key bson.D, hashed bool) error {
+	if len(key) == 0 {
+		return errors.New("mongoboiler: shard key must have a field")
+	}
+	key = append(bson.D(nil), key...)
+	for i := range key {
+		if hashed && i == 0 {
+			key[i].Value = "hashed"
+		} else if key[i].Value == nil {
+			key[i].Value = 1
+		}
+	}
+	return c.db.client().Database("admin").RunCommand(ctx, bson.D{
+		{Key: "shardCollection", Value: c.db.databaseName() + "." + c.collectionName()},
+		{Key: "key", Value: key},
+	}).Err()
+}
+
+// WithShardKey declares the shard key fields of the collection. Single document writes
+// (UpdateOne, ReplaceOne, DeleteOne and FindOrCreate) then fail with a *ShardKeyError before
+// reaching the server unless their filter includes every field, as servers before 7.0 reject or
+// broadcast them otherwise. Multi document writes and reads are not checked.
+//
+// Filters of calls made with a context from ContextWithShardKey get the shard key values it
+// carries added, routing them to a single shard.
+func WithShardKey(fields ...string) Option {
+	return func(s *settings) {
+		s.shardKey = append([]string(nil), fields...)
+	}
+}
+
+type shardKeyValuesKey struct{}
+
+// ContextWithShardKey makes the wrapper calls made with ctx add the equality conditions of values
+// to their filters for the fields that are part of the collection's shard key (see WithShardKey)
+// and not constrained by the filter yet. This targets the shard holding the documents, instead of
+// querying all of them, when the shard key is known for a request, e.g. the tenant's region:
+//
+//	ctx = mongoboiler.ContextWithShardKey(ctx, bson.D{{Key: "region", Value: tenant.Region}})
+func ContextWithShardKey(ctx context.Context, values bson.D) context.Context {
+	return context.WithValue(ctx, shardKeyValuesKey{}, values)
+}
+
+// shardKeyHandler adds the shard key values of ctx to the filter of op and checks single
+// document writes include the shard key before next executes them.
+func shardKeyHandler(fields []string) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, op *Operation) error {
+			switch op.Kind {
+			case OpInsertOne, OpInsertMany, OpAggregate, OpDrop, OpExplain:
+				return next(ctx, op)
+			}
+			values, _ := ctx.Value(shardKeyValuesKey{}).(bson.D)
+			for _, v := range values {
+				if containsString(fields, v.Key) && !filtersOn(op.Filter, v.Key) {
+					op.Filter = append(append(bson.D(nil), op.Filter...), v)
+				}
+			}
+			switch op.Kind {
+			case OpUpdateOne, OpReplaceOne, OpDeleteOne, OpFindOrCreate:
+				var missing []string
+				for _, field := range fields {
+					if !filtersOn(op.Filter, field) {
+						missing = append(missing, field)
+					}
+				}
+				if len(missing) > 0 {
+					return &ShardKeyError{Op: op.Kind, Database: op.Database, Collection: op.Collection, Missing: missing}
+				}
+			}
+			return next(ctx, op)
+		}
+	}
+}
+
+// filtersOn reports whether filter constrains field at its top level or in a clause of $and.
+func filtersOn(filter bson.D, field string) bool {
+	for _, e := range filter {
+		if e.Key == field {
+			return true
+		}
+		if e.Key != "$and" {
+			continue
+		}
+		clauses, _ := e.Value.(bson.A)
+		for _, clause := range clauses {
+			if d, ok := clause.(bson.D); ok && filtersOn(d, field) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,64 @@
+package mongoboiler
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// runFiltered runs an operation of kind with filter on coll and returns the filter executed, nil
+// if it was not.
+func runFiltered(ctx context.Context, coll *Collection, kind OpKind, filter bson.D) (bson.D, error) {
+	op := coll.newOp(kind)
+	op.Filter = filter
+	var executed bson.D
+	err := coll.run(ctx, op, func(ctx context.Context, op *Operation) error {
+		executed = op.Filter
+		return nil
+	})
+	return executed, err
+}
+
+func TestWithShardKey_SingleDocumentWrites(t *testing.T) {
+	coll := newTestCollection(t, "orders", WithShardKey("region", "customerId"))
+	ctx := context.Background()
+
+	executed, err := runFiltered(ctx, coll, OpUpdateOne, bson.D{{Key: "customerId", Value: 7}})
+	var keyErr *ShardKeyError
+	if !errors.As(err, &keyErr) || !errors.Is(err, ErrShardKeyMissing) {
+		t.Fatalf("got %v, want a ShardKeyError", err)
+	}
+	if len(keyErr.Missing) != 1 || keyErr.Missing[0] != "region" || executed != nil {
+		t.Fatalf("missing %v, executed %v", keyErr.Missing, executed)
+	}
+
+	filter := bson.D{{Key: "$and", Value: bson.A{bson.D{{Key: "region", Value: "eu"}}}}, {Key: "customerId", Value: 7}}
+	if _, err := runFiltered(ctx, coll, OpDeleteOne, filter); err != nil {
+		t.Fatalf("DeleteOne with the shard key failed: %v", err)
+	}
+	if _, err := runFiltered(ctx, coll, OpDeleteMany, bson.D{{Key: "status", Value: "closed"}}); err != nil {
+		t.Fatalf("multi document writes are not checked: %v", err)
+	}
+}
+
+func TestContextWithShardKey(t *testing.T) {
+	coll := newTestCollection(t, "orders", WithShardKey("region"))
+	ctx := ContextWithShardKey(context.Background(), bson.D{{Key: "region", Value: "eu"}, {Key: "tier", Value: "gold"}})
+
+	executed, err := runFiltered(ctx, coll, OpFind, bson.D{{Key: "_id", Value: 1}})
+	if err != nil || len(executed) != 2 || executed[1] != (bson.E{Key: "region", Value: "eu"}) {
+		t.Fatalf("executed %v, %v; want the region added", executed, err)
+	}
+	executed, _ = runFiltered(ctx, coll, OpDeleteOne, bson.D{{Key: "region", Value: "us"}})
+	if len(executed) != 1 || executed[0].Value != "us" {
+		t.Fatalf("executed %v, want the filter's region kept", executed)
+	}
+}
+
+func TestShardCollection_Key(t *testing.T) {
+	if err := newTestCollection(t, "orders").ShardCollection(context.Background(), nil, false); err == nil {
+		t.Fatalf("expected an empty shard key to be rejected")
+	}
+}
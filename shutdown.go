@@ -0,0 +1,222 @@
+package mongoboiler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrClosed is returned by operations started once Close was called.
+var ErrClosed = errors.New("mongoboiler: database closed")
+
+// ShutdownReport describes how Close stopped the workers and drained the operations in flight.
+type ShutdownReport struct {
+	// Workers is the number of watchers and workers, such as a CacheWatcher or OutboxRelay, whose
+	// Run was stopped.
+	Workers int
+	// InFlight is the number of operations running when Close was called. Each of them was
+	// either Drained, completing on its own, or Aborted by canceling it once the context of
+	// Close ended.
+	InFlight int
+	Drained  int
+	Aborted  int
+	// Rejected is the number of operations started during Close, they failed with ErrClosed.
+	Rejected int
+	// Duration is how long draining took, until the last operation returned.
+	Duration time.Duration
+}
+
+func (r ShutdownReport) String() string {
+	return fmt.Sprintf("%d workers stopped, %d in flight: %d drained, %d aborted, %d rejected in %s",
+		r.Workers, r.InFlight, r.Drained, r.Aborted, r.Rejected, r.Duration)
+}
+
+// Close stops accepting operations, stops the watchers and workers running on the DB, waits for
+// the operations in flight to complete and disconnects. Once ctx ends the remaining operations
+// are canceled, failing with their context's error, and Close waits for them to return. The
+// report tells how many operations were drained or aborted and how long that took, e.g. to tune
+// the termination grace period of a Kubernetes pod.
+//
+// The Run methods of CacheWatcher, ViewRefresher and OutboxRelay return nil once stopped, closing
+// their change streams and cursors, and fail with ErrClosed when called afterwards. They are
+// stopped first, so they do not log the rejections of their own operations.
+//
+// It covers the operations of the DB, of the DBs returned by its Database method and of all
+// their collections. Commands sent to the driver directly, such as those of RunCommand, are not
+// tracked. Use Disconnect to close without waiting.
+func (db *DB) Close(ctx context.Context) (ShutdownReport, error) {
+	start := time.Now()
+	workers, stopped := db.conn.workers.stop()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+	}
+	ops := &db.conn.ops
+	idle := ops.close()
+	select {
+	case <-idle:
+	case <-ctx.Done():
+		ops.abort()
+		<-idle
+	}
+	// The kills of WithServerCancellation outlive their operations, they have deadlines of their own.
+	db.conn.cancellations.pending.Wait()
+	report := ops.report()
+	report.Workers = workers
+	report.Duration = time.Since(start)
+	// Nothing is left in flight, so disconnecting only closes the connection pools.
+	return report, db.conn.disconnect(context.Background())
+}
+
+// Shutdown is Close for callers that do not need the report, e.g. in an http.Server's shutdown
+// hook.
+func (db *DB) Shutdown(ctx context.Context) error {
+	_, err := db.Close(ctx)
+	return err
+}
+
+// workerGroup tracks the Run loops of the watchers and workers of a connection for Close.
+type workerGroup struct {
+	mu      sync.Mutex
+	stopped bool
+	next    uint64
+	cancels map[uint64]context.CancelFunc
+	running sync.WaitGroup
+}
+
+// start registers a worker running with ctx, returning the context to run it with, canceled once
+// the workers are stopped, and the function to call once it returned.
+func (g *workerGroup) start(ctx context.Context) (context.Context, func(), error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.stopped {
+		return ctx, nil, ErrClosed
+	}
+	if g.cancels == nil {
+		g.cancels = map[uint64]context.CancelFunc{}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	id := g.next
+	g.next++
+	g.cancels[id] = cancel
+	g.running.Add(1)
+	return ctx, func() {
+		cancel()
+		g.mu.Lock()
+		delete(g.cancels, id)
+		g.mu.Unlock()
+		g.running.Done()
+	}, nil
+}
+
+// stop cancels the running workers and keeps new ones from starting. It returns their number and
+// a channel closed once all of them returned.
+func (g *workerGroup) stop() (int, <-chan struct{}) {
+	g.mu.Lock()
+	g.stopped = true
+	n := len(g.cancels)
+	for _, cancel := range g.cancels {
+		cancel()
+	}
+	g.mu.Unlock()
+	done := make(chan struct{})
+	go func() {
+		g.running.Wait()
+		close(done)
+	}()
+	return n, done
+}
+
+// inflightOps tracks the operations running on a connection for Close.
+type inflightOps struct {
+	mu      sync.Mutex
+	closing bool
+	next    uint64
+	ops     map[uint64]*inflightOp
+	idle    chan struct{}
+	stats   ShutdownReport
+}
+
+type inflightOp struct {
+	cancel context.CancelFunc
+	// draining is set for operations in flight when Close was called, aborted once they were
+	// canceled.
+	draining, aborted bool
+}
+
+type inflightKey struct{}
+
+// start registers an operation running with ctx, returning the context to run it with and the
+// function to call once it returned. Operations started from within a tracked one, like the
+// reads some writes make first, are part of it and not tracked again.
+func (t *inflightOps) start(ctx context.Context) (context.Context, func(), error) {
+	if ctx.Value(inflightKey{}) != nil {
+		return ctx, func() {}, nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closing {
+		t.stats.Rejected++
+		return ctx, nil, ErrClosed
+	}
+	if t.ops == nil {
+		t.ops = map[uint64]*inflightOp{}
+	}
+	ctx, cancel := context.WithCancel(context.WithValue(ctx, inflightKey{}, true))
+	id := t.next
+	t.next++
+	op := &inflightOp{cancel: cancel}
+	t.ops[id] = op
+	return ctx, func() {
+		cancel()
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		delete(t.ops, id)
+		switch {
+		case op.aborted:
+			t.stats.Aborted++
+		case op.draining:
+			t.stats.Drained++
+		}
+		if t.closing && len(t.ops) == 0 {
+			close(t.idle)
+		}
+	}, nil
+}
+
+// close stops accepting operations and returns a channel closed once none are in flight.
+func (t *inflightOps) close() <-chan struct{} {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closing {
+		return t.idle
+	}
+	t.closing = true
+	t.idle = make(chan struct{})
+	t.stats.InFlight = len(t.ops)
+	for _, op := range t.ops {
+		op.draining = true
+	}
+	if len(t.ops) == 0 {
+		close(t.idle)
+	}
+	return t.idle
+}
+
+// abort cancels the operations in flight.
+func (t *inflightOps) abort() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, op := range t.ops {
+		op.aborted = true
+		op.cancel()
+	}
+}
+
+func (t *inflightOps) report() ShutdownReport {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.stats
+}
@@ -0,0 +1,116 @@
+package mongoboiler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// blockingCollection returns a collection whose operations signal started and then block until
+// release is closed or their context ends.
+func blockingCollection(t *testing.T, started chan<- struct{}, release <-chan struct{}) *Collection {
+	return newTestCollection(t, "orders", WithMiddleware(func(next Handler) Handler {
+		return func(ctx context.Context, op *Operation) error {
+			started <- struct{}{}
+			select {
+			case <-release:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}))
+}
+
+func TestClose_Drains(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	coll := blockingCollection(t, started, release)
+	errc := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err := coll.DeleteOne(context.Background(), bson.D{})
+			errc <- err
+		}()
+		<-started
+	}
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		if _, err := coll.DeleteOne(context.Background(), bson.D{}); !errors.Is(err, ErrClosed) {
+			t.Errorf("operation during Close: got %v, want ErrClosed", err)
+		}
+		close(release)
+	}()
+	report, _ := coll.db.Close(context.Background())
+	for i := 0; i < 2; i++ {
+		if err := <-errc; err != nil {
+			t.Fatalf("drained operation failed: %v", err)
+		}
+	}
+	if report.InFlight != 2 || report.Drained != 2 || report.Aborted != 0 || report.Rejected != 1 || report.Duration <= 0 {
+		t.Fatalf("report %s", report)
+	}
+}
+
+func TestClose_Aborts(t *testing.T) {
+	started := make(chan struct{})
+	coll := blockingCollection(t, started, nil)
+	errc := make(chan error, 1)
+	go func() {
+		_, err := coll.DeleteOne(context.Background(), bson.D{})
+		errc <- err
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	report, _ := coll.db.Close(ctx)
+	if err := <-errc; !errors.Is(err, context.Canceled) {
+		t.Fatalf("aborted operation: got %v, want context.Canceled", err)
+	}
+	if report.InFlight != 1 || report.Drained != 0 || report.Aborted != 1 {
+		t.Fatalf("report %s", report)
+	}
+}
+
+func TestClose_NestedOperations(t *testing.T) {
+	coll := newTestCollection(t, "orders")
+	ctx, done, err := coll.db.conn.ops.start(context.Background())
+	if err != nil {
+		t.Fatalf("start failed: %v", err)
+	}
+	coll.db.conn.ops.close()
+	if _, nestedDone, err := coll.db.conn.ops.start(ctx); err != nil {
+		t.Fatalf("operation within a draining one: %v", err)
+	} else {
+		nestedDone()
+	}
+	done()
+	if r := coll.db.conn.ops.report(); r.InFlight != 1 || r.Drained != 1 {
+		t.Fatalf("report %s", r)
+	}
+}
+
+func TestShutdown_StopsWorkers(t *testing.T) {
+	started := make(chan struct{}, 1)
+	coll := blockingCollection(t, started, nil)
+	refresher := coll.db.NewViewRefresher()
+	errc := make(chan error, 1)
+	go func() { errc <- refresher.Run(context.Background()) }()
+	<-started
+
+	report, _ := coll.db.Close(context.Background())
+	if err := <-errc; err != nil {
+		t.Fatalf("stopped worker returned %v, want nil", err)
+	}
+	// The worker's claim was canceled with it, before draining began.
+	if report.Workers != 1 || report.InFlight != 0 {
+		t.Fatalf("report %s", report)
+	}
+	if err := refresher.Run(context.Background()); !errors.Is(err, ErrClosed) {
+		t.Fatalf("Run after Close: got %v, want ErrClosed", err)
+	}
+}
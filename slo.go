@@ -0,0 +1,207 @@
+package mongoboiler
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// SLO is a latency and availability objective for the operations of a collection: an operation
+// is good when it succeeds within Latency, and Target is the fraction of good operations aimed
+// for over Window.
+type SLO struct {
+	// Collection the objective applies to, every collection if empty. Collection specific
+	// objectives take precedence.
+	Collection string
+	// Latency above which an operation counts as bad, zero to only count errors.
+	Latency time.Duration
+	// Target is the objective, e.g. 0.999.
+	Target float64
+	// Window is the period compliance is measured over, one hour if zero.
+	Window time.Duration
+	// BurnRate is the rate of error budget consumption that triggers the breach callback, e.g.
+	// 14.4 for the classic "2% of a 30 day budget in one hour" alert. Defaults to 1.
+	BurnRate float64
+	// MinOperations is the number of operations in the window below which no breach is
+	// reported, avoiding alerts on a handful of requests. Defaults to 10.
+	MinOperations int64
+}
+
+// SLOStatus is the compliance of a collection with its SLO over the current window.
+type SLOStatus struct {
+	SLO        SLO
+	Collection string
+	Total      int64
+	Bad        int64
+	// BurnRate is the observed rate of error budget consumption, 1 meaning the budget is used up
+	// exactly at the end of the window.
+	BurnRate float64
+}
+
+// SLOMonitor measures operations against SLOs and calls a function when a collection burns its
+// error budget too fast. Install it with WithSLOMonitor; Status exposes the numbers for metrics.
+type SLOMonitor struct {
+	onBreach func(SLOStatus)
+	slos     []SLO
+	now      func() time.Time
+
+	mu      sync.Mutex
+	windows map[string]*sloWindow
+}
+
+// sloBuckets is the resolution of the sliding window.
+const sloBuckets = 60
+
+type sloWindow struct {
+	slo         SLO
+	collection  string
+	buckets     [sloBuckets]struct{ total, bad int64 }
+	current     int64 // index of the newest bucket in bucket intervals since the epoch
+	lastBreach  int64
+	everCounted bool
+}
+
+// NewSLOMonitor returns a monitor for slos. onBreach, unless nil, is called at most once per
+// sixtieth of the window and collection while the burn rate exceeds the SLO's threshold; it runs
+// on the goroutine of the operation, so it should return quickly.
+func NewSLOMonitor(onBreach func(SLOStatus), slos ...SLO) *SLOMonitor {
+	slos = append([]SLO(nil), slos...)
+	for i := range slos {
+		if slos[i].Window <= 0 {
+			slos[i].Window = time.Hour
+		}
+		if slos[i].BurnRate <= 0 {
+			slos[i].BurnRate = 1
+		}
+		if slos[i].MinOperations <= 0 {
+			slos[i].MinOperations = 10
+		}
+	}
+	return &SLOMonitor{onBreach: onBreach, slos: slos, now: time.Now, windows: map[string]*sloWindow{}}
+}
+
+// WithSLOMonitor measures every operation with m.
+func WithSLOMonitor(m *SLOMonitor) Option {
+	return WithMiddleware(m.middleware)
+}
+
+func (m *SLOMonitor) middleware(next Handler) Handler {
+	return func(ctx context.Context, op *Operation) error {
+		start := m.now()
+		err := next(ctx, op)
+		m.record(op.Database+"."+op.Collection, op.Collection, m.now().Sub(start), err)
+		return err
+	}
+}
+
+func (m *SLOMonitor) sloFor(collection string) (SLO, bool) {
+	var fallback *SLO
+	for i, s := range m.slos {
+		if s.Collection == collection {
+			return s, true
+		}
+		if s.Collection == "" && fallback == nil {
+			fallback = &m.slos[i]
+		}
+	}
+	if fallback == nil {
+		return SLO{}, false
+	}
+	return *fallback, true
+}
+
+func (m *SLOMonitor) record(key, collection string, took time.Duration, err error) {
+	slo, ok := m.sloFor(collection)
+	if !ok {
+		return
+	}
+	bad := isSLOFailure(err) || (slo.Latency > 0 && took > slo.Latency)
+
+	m.mu.Lock()
+	w := m.windows[key]
+	if w == nil {
+		w = &sloWindow{slo: slo, collection: collection}
+		m.windows[key] = w
+	}
+	idx := w.advance(m.now())
+	b := &w.buckets[idx%sloBuckets]
+	b.total++
+	if bad {
+		b.bad++
+	}
+	status := w.status()
+	breach := m.onBreach != nil && status.Total >= slo.MinOperations && status.BurnRate >= slo.BurnRate && w.lastBreach != idx
+	if breach {
+		w.lastBreach = idx
+	}
+	m.mu.Unlock()
+
+	if breach {
+		m.onBreach(status)
+	}
+}
+
+// Status returns the current compliance of every collection that ran operations, sorted by
+// database and collection.
+func (m *SLOMonitor) Status() []SLOStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	keys := make([]string, 0, len(m.windows))
+	for k := range m.windows {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	out := make([]SLOStatus, 0, len(keys))
+	for _, k := range keys {
+		w := m.windows[k]
+		w.advance(m.now())
+		out = append(out, w.status())
+	}
+	return out
+}
+
+// advance moves the window to now, clearing buckets that fell out of it, and returns the index
+// of the current bucket.
+func (w *sloWindow) advance(now time.Time) int64 {
+	interval := w.slo.Window / sloBuckets
+	if interval <= 0 {
+		interval = 1
+	}
+	idx := now.UnixNano() / int64(interval)
+	if !w.everCounted {
+		w.current, w.everCounted = idx, true
+		return idx
+	}
+	for i := w.current + 1; i <= idx && i <= w.current+sloBuckets; i++ {
+		w.buckets[i%sloBuckets] = struct{ total, bad int64 }{}
+	}
+	if idx > w.current {
+		w.current = idx
+	}
+	return w.current
+}
+
+func (w *sloWindow) status() SLOStatus {
+	s := SLOStatus{SLO: w.slo, Collection: w.collection}
+	for _, b := range w.buckets {
+		s.Total += b.total
+		s.Bad += b.bad
+	}
+	if budget := 1 - w.slo.Target; s.Total > 0 && budget > 0 {
+		s.BurnRate = float64(s.Bad) / float64(s.Total) / budget
+	}
+	return s
+}
+
+// isSLOFailure reports whether err counts against availability. Missing documents and
+// callers giving up are not the database's fault.
+func isSLOFailure(err error) bool {
+	return err != nil &&
+		!errors.Is(err, mongo.ErrNoDocuments) &&
+		!errors.Is(err, context.Canceled)
+}
@@ -0,0 +1,49 @@
+package mongoboiler
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestSLOMonitor_Breach(t *testing.T) {
+	var breaches []SLOStatus
+	m := NewSLOMonitor(func(s SLOStatus) { breaches = append(breaches, s) },
+		SLO{Collection: "orders", Latency: 100 * time.Millisecond, Target: 0.9, BurnRate: 2, MinOperations: 10})
+	now := time.Unix(1000, 0)
+	m.now = func() time.Time { return now }
+
+	for i := 0; i < 10; i++ {
+		m.record("app.orders", "orders", time.Millisecond, mongo.ErrNoDocuments)
+	}
+	if len(breaches) != 0 {
+		t.Fatalf("expected no breach for healthy operations")
+	}
+	for i := 0; i < 4; i++ {
+		m.record("app.orders", "orders", time.Second, nil)
+	}
+	m.record("app.orders", "orders", time.Millisecond, errors.New("timeout"))
+	if len(breaches) != 1 {
+		t.Fatalf("expected one breach per bucket, got %d", len(breaches))
+	}
+	if s := breaches[0]; s.Collection != "orders" || s.Total != 13 || s.Bad != 3 {
+		t.Fatalf("unexpected status at breach %+v", s)
+	}
+
+	// Once the window passed the old measurements no longer count.
+	now = now.Add(2 * time.Hour)
+	m.record("app.orders", "orders", time.Millisecond, nil)
+	if st := m.Status(); len(st) != 1 || st[0].Total != 1 || st[0].BurnRate != 0 {
+		t.Fatalf("unexpected status after the window %+v", st)
+	}
+}
+
+func TestSLOMonitor_NoMatchingSLO(t *testing.T) {
+	m := NewSLOMonitor(nil, SLO{Collection: "orders", Target: 0.99})
+	m.record("app.users", "users", time.Second, errors.New("boom"))
+	if len(m.Status()) != 0 {
+		t.Fatalf("expected collections without SLO to be ignored")
+	}
+}
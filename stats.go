@@ -0,0 +1,179 @@
+package mongoboiler
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// DBStats is the result of the dbStats command, sizes are in bytes.
+type DBStats struct {
+	DB          string  `bson:"db"`
+	Collections int64   `bson:"collections"`
+	Views       int64   `bson:"views"`
+	Objects     int64   `bson:"objects"`
+	AvgObjSize  float64 `bson:"avgObjSize"`
+	DataSize    int64   `bson:"dataSize"`
+	StorageSize int64   `bson:"storageSize"`
+	Indexes     int64   `bson:"indexes"`
+	IndexSize   int64   `bson:"indexSize"`
+	// TotalSize is StorageSize plus IndexSize, reported since MongoDB 4.4.
+	TotalSize   int64 `bson:"totalSize"`
+	FsUsedSize  int64 `bson:"fsUsedSize"`
+	FsTotalSize int64 `bson:"fsTotalSize"`
+}
+
+// Stats returns the statistics of the database.
+func (db *DB) Stats(ctx context.Context) (DBStats, error) {
+	var stats DBStats
+	err := db.database().RunCommand(ctx, bson.D{{Key: "dbStats", Value: 1}, {Key: "scale", Value: 1}}).Decode(&stats)
+	return stats, TranslateError(err)
+}
+
+// CollectionStats are the storage statistics of a collection, sizes are in bytes. On sharded
+// clusters they are summed over the shards.
+type CollectionStats struct {
+	Namespace      string
+	Count          int64
+	Size           int64
+	AvgObjSize     float64
+	StorageSize    int64
+	TotalIndexSize int64
+	IndexSizes     map[string]int64
+	Indexes        int
+	Capped         bool
+	Shards         int
+}
+
+// Stats returns the storage statistics of the collection from $collStats, which replaced the
+// deprecated collStats command.
+func (c Collection) Stats(ctx context.Context) (CollectionStats, error) {
+	// Straight to the driver: $collStats must be the first stage, so middleware prepending a
+	// $match would break it.
+	cursor, err := c.collection().Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$collStats", Value: bson.D{{Key: "storageStats", Value: bson.D{{Key: "scale", Value: 1}}}}}},
+	})
+	if err != nil {
+		return CollectionStats{}, TranslateError(err)
+	}
+	defer cursor.Close(context.Background())
+	var docs []bson.Raw
+	if err := cursor.All(ctx, &docs); err != nil {
+		return CollectionStats{}, TranslateError(err)
+	}
+	return sumCollStats(docs)
+}
+
+// sumCollStats sums the $collStats documents of the shards.
+func sumCollStats(docs []bson.Raw) (CollectionStats, error) {
+	stats := CollectionStats{IndexSizes: map[string]int64{}}
+	for _, doc := range docs {
+		var s struct {
+			NS           string `bson:"ns"`
+			StorageStats struct {
+				Count          int64            `bson:"count"`
+				Size           int64            `bson:"size"`
+				StorageSize    int64            `bson:"storageSize"`
+				TotalIndexSize int64            `bson:"totalIndexSize"`
+				IndexSizes     map[string]int64 `bson:"indexSizes"`
+				NIndexes       int              `bson:"nindexes"`
+				Capped         bool             `bson:"capped"`
+			} `bson:"storageStats"`
+		}
+		if err := bson.Unmarshal(doc, &s); err != nil {
+			return CollectionStats{}, err
+		}
+		st := s.StorageStats
+		stats.Namespace = s.NS
+		stats.Count += st.Count
+		stats.Size += st.Size
+		stats.StorageSize += st.StorageSize
+		stats.TotalIndexSize += st.TotalIndexSize
+		for name, size := range st.IndexSizes {
+			stats.IndexSizes[name] += size
+		}
+		if st.NIndexes > stats.Indexes {
+			stats.Indexes = st.NIndexes
+		}
+		stats.Capped = stats.Capped || st.Capped
+		stats.Shards++
+	}
+	if stats.Count > 0 {
+		stats.AvgObjSize = float64(stats.Size) / float64(stats.Count)
+	}
+	return stats, nil
+}
+
+// ServerStatus is a subset of the serverStatus command's output, for health checks and
+// autoscaling signals. Raw holds the full output.
+type ServerStatus struct {
+	Host    string        `bson:"host"`
+	Version string        `bson:"version"`
+	Process string        `bson:"process"`
+	Uptime  time.Duration `bson:"-"`
+
+	Connections struct {
+		Current      int64 `bson:"current"`
+		Available    int64 `bson:"available"`
+		TotalCreated int64 `bson:"totalCreated"`
+		Active       int64 `bson:"active"`
+	} `bson:"connections"`
+
+	// Opcounters count the operations since the server started.
+	Opcounters struct {
+		Insert  int64 `bson:"insert"`
+		Query   int64 `bson:"query"`
+		Update  int64 `bson:"update"`
+		Delete  int64 `bson:"delete"`
+		GetMore int64 `bson:"getmore"`
+		Command int64 `bson:"command"`
+	} `bson:"opcounters"`
+
+	// Repl is nil unless the server is a replica set member.
+	Repl *ReplStatus `bson:"repl"`
+
+	// Mem reports the memory use in MiB.
+	Mem struct {
+		Resident int64 `bson:"resident"`
+		Virtual  int64 `bson:"virtual"`
+	} `bson:"mem"`
+
+	Raw bson.Raw `bson:"-"`
+}
+
+// ReplStatus is the replication state of a replica set member.
+type ReplStatus struct {
+	SetName           string   `bson:"setName"`
+	IsWritablePrimary bool     `bson:"isWritablePrimary"`
+	Secondary         bool     `bson:"secondary"`
+	Primary           string   `bson:"primary"`
+	Me                string   `bson:"me"`
+	Hosts             []string `bson:"hosts"`
+}
+
+// ServerStatus returns the status of the server the command is sent to.
+func (db *DB) ServerStatus(ctx context.Context) (ServerStatus, error) {
+	raw, err := db.client().Database("admin").RunCommand(ctx, bson.D{{Key: "serverStatus", Value: 1}}).DecodeBytes()
+	if err != nil {
+		return ServerStatus{}, TranslateError(err)
+	}
+	return parseServerStatus(raw)
+}
+
+func parseServerStatus(raw bson.Raw) (ServerStatus, error) {
+	var status ServerStatus
+	if err := bson.Unmarshal(raw, &status); err != nil {
+		return ServerStatus{}, err
+	}
+	status.Raw = raw
+	if ms, ok := raw.Lookup("uptimeMillis").AsInt64OK(); ok {
+		status.Uptime = time.Duration(ms) * time.Millisecond
+	}
+	if status.Repl != nil && !status.Repl.IsWritablePrimary {
+		// Servers before 4.4.2 only report the legacy field.
+		status.Repl.IsWritablePrimary, _ = raw.Lookup("repl", "ismaster").BooleanOK()
+	}
+	return status, nil
+}
@@ -0,0 +1,61 @@
+package mongoboiler
+
+import (
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestSumCollStats(t *testing.T) {
+	shard := func(count, size int64) bson.Raw {
+		return mustRaw(t, bson.D{
+			{Key: "ns", Value: "app.orders"},
+			{Key: "storageStats", Value: bson.D{
+				{Key: "count", Value: count},
+				{Key: "size", Value: size},
+				{Key: "storageSize", Value: int32(4096)},
+				{Key: "totalIndexSize", Value: int32(100)},
+				{Key: "indexSizes", Value: bson.D{{Key: "_id_", Value: int32(60)}, {Key: "sku_1", Value: int32(40)}}},
+				{Key: "nindexes", Value: int32(2)},
+			}},
+		})
+	}
+	stats, err := sumCollStats([]bson.Raw{shard(10, 1000), shard(30, 1000)})
+	if err != nil {
+		t.Fatalf("sumCollStats failed: %v", err)
+	}
+	if stats.Namespace != "app.orders" || stats.Count != 40 || stats.Size != 2000 || stats.AvgObjSize != 50 ||
+		stats.StorageSize != 8192 || stats.TotalIndexSize != 200 || stats.IndexSizes["sku_1"] != 80 ||
+		stats.Indexes != 2 || stats.Shards != 2 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestParseServerStatus(t *testing.T) {
+	raw := mustRaw(t, bson.D{
+		{Key: "host", Value: "db1:27017"},
+		{Key: "version", Value: "4.2.0"},
+		{Key: "uptimeMillis", Value: int64(90000)},
+		{Key: "connections", Value: bson.D{{Key: "current", Value: int32(12)}, {Key: "available", Value: int32(800)}}},
+		{Key: "opcounters", Value: bson.D{{Key: "insert", Value: int64(5)}, {Key: "getmore", Value: int64(2)}}},
+		{Key: "repl", Value: bson.D{{Key: "setName", Value: "rs0"}, {Key: "ismaster", Value: true}, {Key: "hosts", Value: bson.A{"db1:27017"}}}},
+		{Key: "wiredTiger", Value: bson.D{{Key: "cache", Value: bson.D{}}}},
+	})
+	status, err := parseServerStatus(raw)
+	if err != nil {
+		t.Fatalf("parseServerStatus failed: %v", err)
+	}
+	if status.Host != "db1:27017" || status.Uptime != 90*time.Second || status.Connections.Current != 12 ||
+		status.Opcounters.GetMore != 2 || status.Repl == nil || !status.Repl.IsWritablePrimary || status.Repl.SetName != "rs0" {
+		t.Fatalf("unexpected status %+v", status)
+	}
+	if _, err := status.Raw.LookupErr("wiredTiger"); err != nil {
+		t.Fatalf("expected the raw output to be kept")
+	}
+
+	standalone, err := parseServerStatus(mustRaw(t, bson.D{{Key: "host", Value: "db1"}}))
+	if err != nil || standalone.Repl != nil {
+		t.Fatalf("expected no repl status for a standalone server, got %+v, %v", standalone.Repl, err)
+	}
+}
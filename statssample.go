@@ -0,0 +1,270 @@
+package mongoboiler
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DefaultStatsSamplesCollection is the collection a StatsSampler records its samples in.
+const DefaultStatsSamplesCollection = "collectionStats"
+
+// WithStatsSamplesCollection changes the collection StatsSampler records its samples in.
+func WithStatsSamplesCollection(name string) Option {
+	return func(s *settings) {
+		s.statsSamplesCollection = name
+	}
+}
+
+// StatsSample is the size of a collection at a point in time, sizes are in bytes.
+type StatsSample struct {
+	Collection  string    `bson:"collection"`
+	At          time.Time `bson:"at"`
+	Count       int64     `bson:"count"`
+	Size        int64     `bson:"size"`
+	StorageSize int64     `bson:"storageSize"`
+	IndexSize   int64     `bson:"indexSize"`
+}
+
+func (db *DB) statsSamples() *Collection {
+	name := DefaultStatsSamplesCollection
+	if db.settings != nil && db.settings.statsSamplesCollection != "" {
+		name = db.settings.statsSamplesCollection
+	}
+	return db.NewCollection(name)
+}
+
+// EnsureStatsSampleIndexes creates the unique index on collection and time of the samples
+// collection, which keeps concurrent samplers from recording the same sample twice, and a TTL
+// index removing samples older than retention unless zero.
+func (db *DB) EnsureStatsSampleIndexes(ctx context.Context, retention time.Duration) error {
+	samples := db.statsSamples()
+	_, err := samples.collection().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "collection", Value: 1}, {Key: "at", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil || retention <= 0 {
+		return TranslateError(err)
+	}
+	return samples.EnableTTL(ctx, "at", retention)
+}
+
+// StatsSampler periodically records the document count, data, storage and index size of the
+// collections of a database, for StatsHistory and CollectionGrowth to forecast their growth.
+// Samples are taken at multiples of Interval, so several samplers may run against the same
+// database: the first one records each sample and the others skip it.
+type StatsSampler struct {
+	// Interval is the time between samples, an hour by default.
+	Interval time.Duration
+	// Collections are the collections sampled, all collections except system collections and the
+	// samples collection if empty.
+	Collections []string
+	// Logger receives sampling failures, the standard logger if nil.
+	Logger Logger
+
+	db *DB
+	// Seams for tests.
+	names func(ctx context.Context) ([]string, error)
+	stats func(ctx context.Context, name string) (CollectionStats, error)
+	store func(ctx context.Context, sample StatsSample) error
+}
+
+// NewStatsSampler returns a sampler of the collections of db.
+func (db *DB) NewStatsSampler() *StatsSampler {
+	s := &StatsSampler{db: db}
+	s.names = s.collectionNames
+	s.stats = func(ctx context.Context, name string) (CollectionStats, error) {
+		return db.NewCollection(name).Stats(ctx)
+	}
+	s.store = s.storeSample
+	return s
+}
+
+func (s *StatsSampler) interval() time.Duration {
+	if s.Interval > 0 {
+		return s.Interval
+	}
+	return time.Hour
+}
+
+// Run samples the collections every interval until ctx is canceled or the DB is closed,
+// returning nil then. Errors are logged and the next sample follows after the interval.
+func (s *StatsSampler) Run(ctx context.Context) error {
+	ctx, done, err := s.db.conn.workers.start(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+	for {
+		if _, err := s.Sample(ctx); err != nil && ctx.Err() == nil {
+			loggerOrDefault(s.Logger).Printf("mongoboiler: stats sampler: %v", err)
+		}
+		next := time.Now().Truncate(s.interval()).Add(s.interval())
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(time.Until(next)):
+		}
+	}
+}
+
+// Sample records a sample of every collection for the current interval and returns how many
+// were recorded. Collections failing to report their statistics, e.g. because they were just
+// dropped, are logged and skipped.
+func (s *StatsSampler) Sample(ctx context.Context) (int, error) {
+	names := s.Collections
+	if len(names) == 0 {
+		var err error
+		if names, err = s.names(ctx); err != nil {
+			return 0, err
+		}
+	}
+	at := time.Now().UTC().Truncate(s.interval())
+	n := 0
+	for _, name := range names {
+		stats, err := s.stats(ctx, name)
+		if err != nil {
+			if ctx.Err() != nil {
+				return n, ctx.Err()
+			}
+			loggerOrDefault(s.Logger).Printf("mongoboiler: stats of %s: %v", name, err)
+			continue
+		}
+		sample := StatsSample{
+			Collection:  name,
+			At:          at,
+			Count:       stats.Count,
+			Size:        stats.Size,
+			StorageSize: stats.StorageSize,
+			IndexSize:   stats.TotalIndexSize,
+		}
+		if err := s.store(ctx, sample); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// collectionNames lists the collections sampled by default.
+func (s *StatsSampler) collectionNames(ctx context.Context) ([]string, error) {
+	names, err := s.db.database().ListCollectionNames(ctx, bson.D{{Key: "type", Value: "collection"}})
+	if err != nil {
+		return nil, TranslateError(err)
+	}
+	samples := s.db.statsSamples().collectionName()
+	out := names[:0]
+	for _, name := range names {
+		if name != samples && !strings.HasPrefix(name, "system.") {
+			out = append(out, name)
+		}
+	}
+	return out, nil
+}
+
+// storeSample records sample unless another sampler recorded it already.
+func (s *StatsSampler) storeSample(ctx context.Context, sample StatsSample) error {
+	filter := bson.D{{Key: "collection", Value: sample.Collection}, {Key: "at", Value: sample.At}}
+	update := bson.D{{Key: "$setOnInsert", Value: sample}}
+	_, err := s.db.statsSamples().collection().UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if err = TranslateError(err); errors.Is(err, ErrDuplicateKey) {
+		// A concurrent sampler inserted it.
+		return nil
+	}
+	return err
+}
+
+// StatsHistory returns the samples of collection recorded at or after since, oldest first.
+func (db *DB) StatsHistory(ctx context.Context, collection string, since time.Time) ([]StatsSample, error) {
+	filter := bson.D{{Key: "collection", Value: collection}, {Key: "at", Value: bson.D{{Key: "$gte", Value: since}}}}
+	var samples []StatsSample
+	err := db.statsSamples().FindMany(ctx, filter, &samples, options.Find().SetSort(bson.D{{Key: "at", Value: 1}}))
+	return samples, err
+}
+
+// CollectionGrowth is the growth of a collection over a period, fitted to all its samples by
+// least squares rather than taken from the first and last. Outliers, such as a compaction, still
+// pull the fit towards them, the more so the fewer samples there are.
+type CollectionGrowth struct {
+	Collection string
+	// Samples is the number of samples, First and Last the oldest and newest of them.
+	Samples int
+	First   StatsSample
+	Last    StatsSample
+	// The growth per day, negative for shrinking collections. Zero with fewer than two samples.
+	CountPerDay       float64
+	SizePerDay        float64
+	StorageSizePerDay float64
+	IndexSizePerDay   float64
+}
+
+// Forecast extrapolates the size of the collection at a future time from the last sample.
+func (g CollectionGrowth) Forecast(at time.Time) StatsSample {
+	days := at.Sub(g.Last.At).Hours() / 24
+	return StatsSample{
+		Collection:  g.Collection,
+		At:          at,
+		Count:       g.Last.Count + int64(g.CountPerDay*days),
+		Size:        g.Last.Size + int64(g.SizePerDay*days),
+		StorageSize: g.Last.StorageSize + int64(g.StorageSizePerDay*days),
+		IndexSize:   g.Last.IndexSize + int64(g.IndexSizePerDay*days),
+	}
+}
+
+// CollectionGrowth returns the growth of collection from the samples recorded at or after since:
+//
+//	growth, err := db.CollectionGrowth(ctx, "orders", time.Now().AddDate(0, -3, 0))
+//	inAYear := growth.Forecast(time.Now().AddDate(1, 0, 0)).StorageSize
+func (db *DB) CollectionGrowth(ctx context.Context, collection string, since time.Time) (CollectionGrowth, error) {
+	samples, err := db.StatsHistory(ctx, collection, since)
+	if err != nil {
+		return CollectionGrowth{}, err
+	}
+	return growthOf(collection, samples), nil
+}
+
+func growthOf(collection string, samples []StatsSample) CollectionGrowth {
+	g := CollectionGrowth{Collection: collection, Samples: len(samples)}
+	if len(samples) == 0 {
+		return g
+	}
+	g.First, g.Last = samples[0], samples[len(samples)-1]
+	slope := func(value func(StatsSample) int64) float64 {
+		return perDaySlope(samples, value)
+	}
+	g.CountPerDay = slope(func(s StatsSample) int64 { return s.Count })
+	g.SizePerDay = slope(func(s StatsSample) int64 { return s.Size })
+	g.StorageSizePerDay = slope(func(s StatsSample) int64 { return s.StorageSize })
+	g.IndexSizePerDay = slope(func(s StatsSample) int64 { return s.IndexSize })
+	return g
+}
+
+// perDaySlope returns the least squares slope of value over the sample times, per day.
+func perDaySlope(samples []StatsSample, value func(StatsSample) int64) float64 {
+	if len(samples) < 2 {
+		return 0
+	}
+	origin := samples[0].At
+	var sumX, sumY float64
+	for _, s := range samples {
+		sumX += s.At.Sub(origin).Hours() / 24
+		sumY += float64(value(s))
+	}
+	n := float64(len(samples))
+	meanX, meanY := sumX/n, sumY/n
+	var cov, variance float64
+	for _, s := range samples {
+		dx := s.At.Sub(origin).Hours()/24 - meanX
+		cov += dx * (float64(value(s)) - meanY)
+		variance += dx * dx
+	}
+	if variance == 0 {
+		return 0
+	}
+	return cov / variance
+}
@@ -0,0 +1,65 @@
+package mongoboiler
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestStatsSampler_Sample(t *testing.T) {
+	coll := newTestCollection(t, "orders")
+	logger := &recordingLogger{}
+	s := coll.db.NewStatsSampler()
+	s.Logger = logger
+	s.Interval = time.Minute
+	s.names = func(ctx context.Context) ([]string, error) { return []string{"orders", "dropped", "users"}, nil }
+	s.stats = func(ctx context.Context, name string) (CollectionStats, error) {
+		if name == "dropped" {
+			return CollectionStats{}, errors.New("ns not found")
+		}
+		return CollectionStats{Count: 10, Size: 1000, StorageSize: 4096, TotalIndexSize: 512}, nil
+	}
+	var stored []StatsSample
+	s.store = func(ctx context.Context, sample StatsSample) error {
+		stored = append(stored, sample)
+		return nil
+	}
+
+	n, err := s.Sample(context.Background())
+	if err != nil || n != 2 {
+		t.Fatalf("expected 2 samples, got %d, %v", n, err)
+	}
+	if len(stored) != 2 || stored[0].Collection != "orders" || stored[1].Collection != "users" {
+		t.Fatalf("unexpected samples %+v", stored)
+	}
+	if got := stored[0]; got.IndexSize != 512 || got.StorageSize != 4096 || !got.At.Equal(got.At.Truncate(time.Minute)) {
+		t.Fatalf("unexpected sample %+v", got)
+	}
+	if len(logger.lines) != 1 {
+		t.Fatalf("expected the failing collection to be logged, got %v", logger.lines)
+	}
+}
+
+func TestGrowthOf(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var samples []StatsSample
+	for day := 0; day < 5; day++ {
+		samples = append(samples, StatsSample{
+			At:          start.AddDate(0, 0, day),
+			Count:       int64(100 + 10*day),
+			StorageSize: int64(1000 + 200*day),
+		})
+	}
+	g := growthOf("orders", samples)
+	if g.Samples != 5 || math.Abs(g.CountPerDay-10) > 1e-9 || math.Abs(g.StorageSizePerDay-200) > 1e-9 || g.IndexSizePerDay != 0 {
+		t.Fatalf("unexpected growth %+v", g)
+	}
+	if got := g.Forecast(g.Last.At.AddDate(0, 0, 10)); got.Count != 240 || got.StorageSize != 3800 {
+		t.Fatalf("unexpected forecast %+v", got)
+	}
+	if g := growthOf("orders", samples[:1]); g.CountPerDay != 0 {
+		t.Fatalf("expected no growth from a single sample, got %+v", g)
+	}
+}
@@ -0,0 +1,114 @@
+package mongoboiler
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Decoder decodes the current document of an iteration into v.
+type Decoder interface {
+	Decode(v any) error
+}
+
+// FindEach streams all docs matching filter to fn one at a time instead of materializing them.
+// Documents are fetched in batches (see options.FindOptions.SetBatchSize). Iteration stops at the
+// first error returned by fn or when ctx is canceled, and that error is returned.
+func (c Collection) FindEach(ctx context.Context, filter bson.D, fn func(dec Decoder) error, opts ...*options.FindOptions) error {
+	op := c.newOp(OpFind)
+	op.Filter = filter
+	foldCallComment(op, opts)
+	return c.run(ctx, op, func(ctx context.Context, op *Operation) error {
+		cache := c.readCache()
+		var key string
+		if cache != nil {
+			var ok bool
+			if key, ok = cache.key(ctx, op, opts); !ok {
+				cache = nil
+			} else if docs, hit := cache.get(ctx, key); hit {
+				markDelivered(ctx)
+				for _, doc := range docs {
+					if err := fn(rawDocDecoder{ctx, doc, c}); err != nil {
+						return err
+					}
+				}
+				return nil
+			}
+		}
+
+		opts, err := sendComment(op, opts, options.Find().SetComment(op.Comment))
+		if err != nil {
+			return err
+		}
+		ctx, opts, release, err := c.keepCursorAlive(ctx, op.Target, opts)
+		if err != nil {
+			return err
+		}
+		defer release()
+		cursor, err := op.Target.Find(ctx, op.Filter, opts...)
+		if err != nil {
+			return err
+		}
+		return c.each(ctx, cursor, fn, cache, key)
+	})
+}
+
+// each passes the documents of cursor to fn and closes it, caching them under key unless cache
+// is nil or they exceed its entry size.
+func (c Collection) each(ctx context.Context, cursor *mongo.Cursor, fn func(dec Decoder) error, cache *queryCache, key string) error {
+	// Close with a fresh context so the server cursor is killed even when ctx was canceled.
+	defer cursor.Close(context.Background())
+
+	var docs []bson.Raw
+	size := 0
+	for cursor.Next(ctx) {
+		if cache != nil {
+			if size += len(cursor.Current); size > cache.maxEntryBytes {
+				cache, docs = nil, nil
+			} else {
+				docs = append(docs, append(bson.Raw(nil), cursor.Current...))
+			}
+		}
+		markDelivered(ctx)
+		if err := fn(cursorDecoder{ctx, cursor, c}); err != nil {
+			return err
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return err
+	}
+	if cache != nil {
+		cache.set(ctx, key, docs)
+	}
+	return nil
+}
+
+// FindChan streams docs matching filter decoded as T over the returned channel.
+// The channel is closed when iteration ends; the error channel then yields at most one error.
+// Stop early by canceling ctx.
+func FindChan[T any](ctx context.Context, c *Collection, filter bson.D, opts ...*options.FindOptions) (<-chan T, <-chan error) {
+	out := make(chan T)
+	errc := make(chan error, 1)
+	go func() {
+		defer close(errc)
+		defer close(out)
+		err := c.FindEach(ctx, filter, func(dec Decoder) error {
+			var doc T
+			if err := dec.Decode(&doc); err != nil {
+				return err
+			}
+			select {
+			case out <- doc:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}, opts...)
+		if err != nil {
+			errc <- err
+		}
+	}()
+	return out, errc
+}
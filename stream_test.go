@@ -0,0 +1,110 @@
+package mongoboiler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type streamDoc struct {
+	N int `bson:"n"`
+}
+
+func streamCursor(t *testing.T, err error, docs ...bson.D) *mongo.Cursor {
+	t.Helper()
+	raws := make([]any, len(docs))
+	for i, doc := range docs {
+		raws[i] = doc
+	}
+	cursor, cerr := mongo.NewCursorFromDocuments(raws, err, nil)
+	if cerr != nil {
+		t.Fatalf("NewCursorFromDocuments failed: %v", cerr)
+	}
+	return cursor
+}
+
+func TestFindEach_StopsAndClosesCursor(t *testing.T) {
+	coll := newTestCollection(t, "orders")
+	ctx := context.Background()
+	docs := []bson.D{{{Key: "n", Value: 1}}, {{Key: "n", Value: "two"}}, {{Key: "n", Value: 3}}}
+
+	cursor := streamCursor(t, nil, docs...)
+	var got []int
+	err := coll.each(ctx, cursor, func(dec Decoder) error {
+		var doc streamDoc
+		if err := dec.Decode(&doc); err != nil {
+			return err
+		}
+		got = append(got, doc.N)
+		return nil
+	}, nil, "")
+	if err == nil || len(got) != 1 {
+		t.Fatalf("expected iteration to stop at the document failing to decode, got %v, %v", got, err)
+	}
+	if cursor.Next(ctx) {
+		t.Fatalf("expected the cursor to be closed after the decode error")
+	}
+
+	stop := errors.New("stop")
+	cursor = streamCursor(t, nil, docs...)
+	calls := 0
+	err = coll.each(ctx, cursor, func(dec Decoder) error {
+		calls++
+		return stop
+	}, nil, "")
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Fatalf("expected the callback error after 1 call, got %v after %d", err, calls)
+	}
+	if cursor.Next(ctx) {
+		t.Fatalf("expected the cursor to be closed after an early return")
+	}
+
+	failed := errors.New("cursor killed")
+	err = coll.each(ctx, streamCursor(t, failed, docs...), func(dec Decoder) error { return nil }, nil, "")
+	if !errors.Is(err, failed) {
+		t.Fatalf("expected the cursor error, got %v", err)
+	}
+}
+
+func TestFindChan_DecodeError(t *testing.T) {
+	coll := newTestCollection(t, "orders", WithCache(NewLRUCache(10), 0))
+	filter := bson.D{{Key: "status", Value: "open"}}
+	primeFind(t, coll, filter, bson.D{{Key: "n", Value: 1}}, bson.D{{Key: "n", Value: "two"}}, bson.D{{Key: "n", Value: 3}})
+
+	out, errc := FindChan[streamDoc](context.Background(), coll, filter)
+	var got []int
+	for doc := range out {
+		got = append(got, doc.N)
+	}
+	if err := <-errc; err == nil || len(got) != 1 || got[0] != 1 {
+		t.Fatalf("expected the documents before the decode error and the error, got %v, %v", got, err)
+	}
+}
+
+func TestFindChan_StopsOnCancel(t *testing.T) {
+	coll := newTestCollection(t, "orders", WithCache(NewLRUCache(10), 0))
+	filter := bson.D{{Key: "status", Value: "open"}}
+	primeFind(t, coll, filter, bson.D{{Key: "n", Value: 1}}, bson.D{{Key: "n", Value: 2}}, bson.D{{Key: "n", Value: 3}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	out, errc := FindChan[streamDoc](ctx, coll, filter)
+	if doc := <-out; doc.N != 1 {
+		t.Fatalf("expected the first document, got %+v", doc)
+	}
+	cancel()
+	select {
+	case err := <-errc:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context.Canceled, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expected the goroutine to exit once ctx was canceled")
+	}
+	if _, ok := <-out; ok {
+		t.Fatalf("expected the channel to be closed")
+	}
+}
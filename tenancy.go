@@ -0,0 +1,138 @@
+package mongoboiler
+
+import (
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrNoTenant is returned when a tenant scoped operation runs without a tenant in its context.
+var ErrNoTenant = errors.New("mongoboiler: no tenant in context")
+
+// ErrTenantSharedCollection is returned by Drop on collections shared by tenants through field scoping.
+var ErrTenantSharedCollection = errors.New("mongoboiler: collection is shared by all tenants")
+
+// TenancyStrategy selects how tenant data is separated.
+type TenancyStrategy int
+
+const (
+	// TenantByField stores all tenants in the same collection and scopes every filter and
+	// inserted document with the tenant field.
+	TenantByField TenancyStrategy = iota
+	// TenantByDatabase uses one database per tenant named <database>_<tenant>.
+	TenantByDatabase
+	// TenantByCollectionPrefix uses one collection per tenant named <tenant>_<collection>.
+	TenantByCollectionPrefix
+)
+
+// DefaultTenantField is the document field used by TenantByField when Tenancy.Field is empty.
+const DefaultTenantField = "tenantId"
+
+// Tenancy configures multi-tenant scoping, see WithTenancy.
+type Tenancy struct {
+	Strategy TenancyStrategy
+	// Field is the tenant field for TenantByField, DefaultTenantField if empty.
+	Field string
+	// Extractor returns the tenant of an operation from its context, TenantFromContext if nil.
+	Extractor func(ctx context.Context) (string, bool)
+	// AllowMissing runs operations without a tenant unscoped instead of failing with ErrNoTenant.
+	AllowMissing bool
+}
+
+type tenantKey struct{}
+
+// ContextWithTenant returns a context carrying tenant for the default Tenancy extractor.
+func ContextWithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// TenantFromContext returns the tenant stored by ContextWithTenant.
+func TenantFromContext(ctx context.Context) (string, bool) {
+	tenant, ok := ctx.Value(tenantKey{}).(string)
+	return tenant, ok && tenant != ""
+}
+
+// WithTenancy scopes every operation to the tenant taken from its context, so callers no longer
+// have to add the tenant to each filter and document themselves.
+func WithTenancy(t Tenancy) Option {
+	if t.Field == "" {
+		t.Field = DefaultTenantField
+	}
+	if t.Extractor == nil {
+		t.Extractor = TenantFromContext
+	}
+	return WithMiddleware(t.middleware)
+}
+
+func (t Tenancy) middleware(next Handler) Handler {
+	return func(ctx context.Context, op *Operation) error {
+		tenant, ok := t.Extractor(ctx)
+		if !ok {
+			if t.AllowMissing {
+				return next(ctx, op)
+			}
+			return ErrNoTenant
+		}
+
+		switch t.Strategy {
+		case TenantByDatabase:
+			op.Database = op.Database + "_" + tenant
+			db := op.Target.Database().Client().Database(op.Database)
+			op.Target = db.Collection(op.Collection, op.targetOptions...)
+		case TenantByCollectionPrefix:
+			op.Collection = tenant + "_" + op.Collection
+			op.Target = op.Target.Database().Collection(op.Collection, op.targetOptions...)
+		default:
+			if op.Kind == OpDrop {
+				return ErrTenantSharedCollection
+			}
+			op.Filter = append(append(bson.D{}, op.Filter...), bson.E{Key: t.Field, Value: tenant})
+			if op.Kind == OpAggregate {
+				op.Pipeline = prependMatch(op.Pipeline, bson.D{{Key: t.Field, Value: tenant}})
+			}
+			docs := make([]any, len(op.Documents))
+			for i, doc := range op.Documents {
+				scoped, err := setField(doc, t.Field, tenant)
+				if err != nil {
+					return err
+				}
+				docs[i] = scoped
+			}
+			op.Documents = docs
+		}
+		return next(ctx, op)
+	}
+}
+
+// setField returns doc as a bson.D with key set to value, replacing an existing key.
+func setField(doc any, key string, value any) (bson.D, error) {
+	d, err := toDocument(doc)
+	if err != nil {
+		return nil, err
+	}
+	out := make(bson.D, 0, len(d)+1)
+	for _, e := range d {
+		if e.Key != key {
+			out = append(out, e)
+		}
+	}
+	return append(out, bson.E{Key: key, Value: value}), nil
+}
+
+// prependMatch returns pipeline filtered by a leading $match stage. Stages that must come first,
+// like $search and $geoNear, stay in front.
+func prependMatch(pipeline mongo.Pipeline, filter bson.D) mongo.Pipeline {
+	at := 0
+	if len(pipeline) > 0 && len(pipeline[0]) > 0 {
+		switch pipeline[0][0].Key {
+		case "$search", "$searchMeta", "$geoNear", "$vectorSearch":
+			at = 1
+		}
+	}
+	out := make(mongo.Pipeline, 0, len(pipeline)+1)
+	out = append(out, pipeline[:at]...)
+	out = append(out, bson.D{{Key: "$match", Value: filter}})
+	return append(out, pipeline[at:]...)
+}
@@ -0,0 +1,106 @@
+package mongoboiler
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+)
+
+// newTestCollection returns a Collection on a client that is never connected, for tests that
+// only exercise the middleware chain.
+func newTestCollection(t *testing.T, name string, opts ...Option) *Collection {
+	client, err := mongo.NewClient(options.Client().ApplyURI("mongodb://localhost:27017"))
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	return New(client, "testdb", opts...).NewCollection(name)
+}
+
+func TestTenancy_ByField(t *testing.T) {
+	coll := newTestCollection(t, "orders", WithTenancy(Tenancy{}))
+	ctx := ContextWithTenant(context.Background(), "acme")
+
+	op := coll.newOp(OpInsertOne)
+	op.Filter = bson.D{{Key: "status", Value: "open"}}
+	op.Documents = []any{map[string]any{"status": "open"}}
+	err := coll.run(ctx, op, func(ctx context.Context, op *Operation) error {
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+
+	wantFilter := bson.D{{Key: "status", Value: "open"}, {Key: DefaultTenantField, Value: "acme"}}
+	if !reflect.DeepEqual(op.Filter, wantFilter) {
+		t.Fatalf("unexpected filter: %v", op.Filter)
+	}
+	doc := op.Documents[0].(bson.D)
+	if doc[len(doc)-1] != (bson.E{Key: DefaultTenantField, Value: "acme"}) {
+		t.Fatalf("tenant not injected into document: %v", doc)
+	}
+}
+
+func TestTenancy_ByCollectionPrefix(t *testing.T) {
+	coll := newTestCollection(t, "orders", WithTenancy(Tenancy{Strategy: TenantByCollectionPrefix}))
+	ctx := ContextWithTenant(context.Background(), "acme")
+
+	var target string
+	err := coll.run(ctx, coll.newOp(OpFind), func(ctx context.Context, op *Operation) error {
+		target = op.Target.Name()
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	if target != "acme_orders" {
+		t.Fatalf("expected acme_orders, got %s", target)
+	}
+}
+
+func TestTenancy_MissingTenant(t *testing.T) {
+	coll := newTestCollection(t, "orders", WithTenancy(Tenancy{}))
+
+	err := coll.run(context.Background(), coll.newOp(OpFind), func(ctx context.Context, op *Operation) error {
+		t.Fatalf("handler should not run without a tenant")
+		return nil
+	})
+	if err != ErrNoTenant {
+		t.Fatalf("expected ErrNoTenant, got %v", err)
+	}
+}
+
+func TestTenancy_KeepsCollectionOptions(t *testing.T) {
+	wc := writeconcern.New(writeconcern.WMajority())
+	rp := readpref.SecondaryPreferred()
+	for _, strategy := range []TenancyStrategy{TenantByDatabase, TenantByCollectionPrefix} {
+		coll := newTestCollection(t, "orders",
+			WithTenancy(Tenancy{Strategy: strategy}), WithWriteConcern(wc), WithReadPreference(rp))
+		ctx := ContextWithTenant(context.Background(), "acme")
+
+		var target *mongo.Collection
+		err := coll.run(ctx, coll.newOp(OpFind), func(ctx context.Context, op *Operation) error {
+			target = op.Target
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("run failed: %v", err)
+		}
+		if target.Name() == "orders" && target.Database().Name() == "testdb" {
+			t.Fatalf("strategy %v: expected the target to be rewritten", strategy)
+		}
+		// The driver has no getters for them, so compare the pointers it keeps.
+		fields := reflect.ValueOf(target).Elem()
+		if fields.FieldByName("writeConcern").Pointer() != reflect.ValueOf(wc).Pointer() {
+			t.Fatalf("strategy %v: write concern lost", strategy)
+		}
+		if fields.FieldByName("readPreference").Pointer() != reflect.ValueOf(rp).Pointer() {
+			t.Fatalf("strategy %v: read preference lost", strategy)
+		}
+	}
+}
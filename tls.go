@@ -0,0 +1,58 @@
+package mongoboiler
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// TLSConfig describes the TLS settings of a connection. The zero value leaves TLS to the URI.
+type TLSConfig struct {
+	// CAFile is a PEM bundle of certificate authorities trusted instead of the system pool.
+	CAFile string
+	// CertFile and KeyFile hold the PEM client certificate and key for mutual TLS.
+	CertFile string
+	KeyFile  string
+	// ServerName overrides the name used for SNI and certificate verification.
+	ServerName string
+	// InsecureSkipVerify disables server certificate verification. Never use it in production,
+	// Connect logs a warning when it is enabled.
+	InsecureSkipVerify bool
+}
+
+// Enabled reports whether any TLS setting is configured.
+func (t TLSConfig) Enabled() bool {
+	return t != TLSConfig{}
+}
+
+// Build returns the tls.Config described by t.
+func (t TLSConfig) Build() (*tls.Config, error) {
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		return nil, fmt.Errorf("mongoboiler: TLS certificate and key files must be set together")
+	}
+
+	cfg := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         t.ServerName,
+		InsecureSkipVerify: t.InsecureSkipVerify,
+	}
+	if t.CAFile != "" {
+		pem, err := os.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("mongoboiler: no certificates found in %s", t.CAFile)
+		}
+	}
+	if t.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
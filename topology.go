@@ -0,0 +1,54 @@
+package mongoboiler
+
+import (
+	"sort"
+
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo/description"
+)
+
+// SeedlistChange describes hosts joining or leaving the deployment, e.g. after the SRV record of
+// a mongodb+srv:// URI was changed to point to a migrated cluster.
+type SeedlistChange struct {
+	Added   []string
+	Removed []string
+	// Hosts is the complete host list after the change.
+	Hosts []string
+}
+
+// seedlistMonitor returns a server monitor calling fn whenever the set of known hosts changes.
+// The driver rescans SRV records of sharded clusters every 60 seconds, retrying failed DNS lookups
+// on the heartbeat interval; replica set membership is followed through the heartbeats.
+func seedlistMonitor(fn func(SeedlistChange)) *event.ServerMonitor {
+	return &event.ServerMonitor{
+		TopologyDescriptionChanged: func(e *event.TopologyDescriptionChangedEvent) {
+			if change, ok := diffSeedlist(e.PreviousDescription, e.NewDescription); ok {
+				fn(change)
+			}
+		},
+	}
+}
+
+func diffSeedlist(prev, next description.Topology) (SeedlistChange, bool) {
+	before := map[string]bool{}
+	for _, s := range prev.Servers {
+		before[s.Addr.String()] = true
+	}
+
+	var change SeedlistChange
+	for _, s := range next.Servers {
+		addr := s.Addr.String()
+		change.Hosts = append(change.Hosts, addr)
+		if !before[addr] {
+			change.Added = append(change.Added, addr)
+		}
+		delete(before, addr)
+	}
+	for addr := range before {
+		change.Removed = append(change.Removed, addr)
+	}
+	sort.Strings(change.Hosts)
+	sort.Strings(change.Added)
+	sort.Strings(change.Removed)
+	return change, len(change.Added) > 0 || len(change.Removed) > 0
+}
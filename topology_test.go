@@ -0,0 +1,43 @@
+package mongoboiler
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo/address"
+	"go.mongodb.org/mongo-driver/mongo/description"
+)
+
+func topologyOf(hosts ...string) description.Topology {
+	var t description.Topology
+	for _, h := range hosts {
+		t.Servers = append(t.Servers, description.Server{Addr: address.Address(h)})
+	}
+	return t
+}
+
+func TestDiffSeedlist(t *testing.T) {
+	change, ok := diffSeedlist(topologyOf("a:27017", "b:27017"), topologyOf("b:27017", "c:27017"))
+	if !ok {
+		t.Fatalf("expected a change")
+	}
+	want := SeedlistChange{Added: []string{"c:27017"}, Removed: []string{"a:27017"}, Hosts: []string{"b:27017", "c:27017"}}
+	if !reflect.DeepEqual(change, want) {
+		t.Fatalf("unexpected change: %+v", change)
+	}
+
+	if _, ok := diffSeedlist(topologyOf("a:27017"), topologyOf("a:27017")); ok {
+		t.Fatalf("expected no change for the same hosts")
+	}
+}
+
+func TestConfig_ValidateSRVOptions(t *testing.T) {
+	cfg := Config{URI: "mongodb://localhost:27017/app", SRVMaxHosts: 2}
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("expected SRV options to be rejected for a mongodb:// URI")
+	}
+	cfg.URI = "mongodb+srv://cluster.example.com/app"
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,52 @@
+package mongoboiler
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// WithTransaction runs fn inside a transaction on a new session, or the one the DB is pinned to
+// (see WithSession), committing when fn returns nil and aborting otherwise. Transient errors are
+// retried by the driver, so fn may run more than once.
+//
+// The session travels in the ctx passed to fn: every wrapper method called with it joins the
+// transaction, whichever Collection it is called on, including collections of other databases
+// obtained through Database as long as they share the client.
+func (db *DB) WithTransaction(ctx context.Context, fn func(ctx context.Context) error, opts ...*options.TransactionOptions) error {
+	session := db.Session()
+	if session == nil {
+		var err error
+		if session, err = db.client().StartSession(); err != nil {
+			return err
+		}
+		defer session.EndSession(context.Background())
+	}
+
+	_, err := session.WithTransaction(ctx, func(sc mongo.SessionContext) (any, error) {
+		return nil, fn(sc)
+	}, opts...)
+	return err
+}
+
+// Database returns a DB for another database on the same client, with the same options.
+// Models are registered by database, the returned DB has none.
+func (db *DB) Database(name string) *DB {
+	return &DB{name, db.conn, db.settings, newModelRegistry()}
+}
+
+// SupportsTransactions reports whether the deployment is a replica set or sharded cluster.
+// Standalone servers (and some compatible products such as DocumentDB elastic clusters) are not.
+func (db *DB) SupportsTransactions(ctx context.Context) (bool, error) {
+	var hello struct {
+		SetName string `bson:"setName"`
+		Msg     string `bson:"msg"`
+	}
+	err := db.client().Database("admin").RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello)
+	if err != nil {
+		return false, err
+	}
+	return hello.SetName != "" || hello.Msg == "isdbgrid", nil
+}
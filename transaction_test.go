@@ -0,0 +1,70 @@
+package mongoboiler
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// txnSession runs transactions like the driver does, without the retries, counting their
+// outcomes.
+type txnSession struct {
+	fakeSession
+	committed, aborted int
+}
+
+func (s *txnSession) WithTransaction(ctx context.Context, fn func(mongo.SessionContext) (any, error), opts ...*options.TransactionOptions) (any, error) {
+	res, err := fn(mongo.NewSessionContext(ctx, s))
+	if err != nil {
+		s.aborted++
+		return nil, err
+	}
+	s.committed++
+	return res, nil
+}
+
+func TestWithTransaction(t *testing.T) {
+	var sessions []mongo.Session
+	coll := newTestCollection(t, "orders", WithMiddleware(func(next Handler) Handler {
+		return func(ctx context.Context, op *Operation) error {
+			sessions = append(sessions, mongo.SessionFromContext(ctx))
+			return nil
+		}
+	}))
+	sess := &txnSession{}
+	db := coll.db.pinned(sess)
+
+	err := db.WithTransaction(context.Background(), func(ctx context.Context) error {
+		if _, err := db.NewCollection("orders").InsertOne(ctx, streamDoc{N: 1}); err != nil {
+			return err
+		}
+		_, err := db.Database("billing").NewCollection("invoices").InsertOne(ctx, streamDoc{N: 1})
+		return err
+	})
+	if err != nil {
+		t.Fatalf("WithTransaction failed: %v", err)
+	}
+	if sess.committed != 1 || sess.aborted != 0 {
+		t.Fatalf("expected the transaction to commit, got %+v", sess)
+	}
+	if len(sessions) != 2 || sessions[0] != mongo.Session(sess) || sessions[1] != mongo.Session(sess) {
+		t.Fatalf("expected the writes to both collections to join the transaction, got %v", sessions)
+	}
+
+	failed := errors.New("out of stock")
+	err = db.WithTransaction(context.Background(), func(ctx context.Context) error {
+		if _, err := db.NewCollection("orders").InsertOne(ctx, streamDoc{N: 2}); err != nil {
+			return err
+		}
+		return failed
+	})
+	if !errors.Is(err, failed) {
+		t.Fatalf("expected the error of fn, got %v", err)
+	}
+	if sess.committed != 1 || sess.aborted != 1 {
+		t.Fatalf("expected the transaction to abort, got %+v", sess)
+	}
+}
@@ -0,0 +1,243 @@
+package mongoboiler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Two phase commit transaction states, as stored in the transactions collection.
+const (
+	TwoPhaseInitial   = "initial"
+	TwoPhasePending   = "pending"
+	TwoPhaseApplied   = "applied"
+	TwoPhaseDone      = "done"
+	TwoPhaseCanceling = "canceling"
+	TwoPhaseCancelled = "cancelled"
+)
+
+// pendingField is the array on target documents holding the IDs of transactions touching them.
+const pendingField = "pendingTransactions"
+
+// ErrTwoPhaseNoMatch is returned when a two phase commit operation matched no document.
+// The transaction is rolled back.
+var ErrTwoPhaseNoMatch = errors.New("mongoboiler: two phase commit operation matched no document")
+
+// TwoPhaseOp is a single document update applied as part of a two phase commit.
+type TwoPhaseOp struct {
+	Collection *Collection
+	// Filter must match exactly one document, usually by _id.
+	Filter bson.D
+	// Update is applied to the document, it must be made of update operators.
+	Update bson.D
+	// Undo reverses Update and is applied when the transaction is rolled back.
+	Undo bson.D
+}
+
+// TwoPhaseCommitter implements the classic MongoDB two phase commit pattern for deployments
+// without multi-document transactions, recording progress in a transactions collection so that
+// interrupted transactions can be finished or rolled back by Recover.
+type TwoPhaseCommitter struct {
+	db   *DB
+	txns CollectionAPI
+	// target returns the collection op applies to, a seam for tests.
+	target func(op twoPhaseOpRecord) twoPhaseTarget
+}
+
+// twoPhaseTarget is the part of *mongo.Collection the ops of a transaction are applied with.
+type twoPhaseTarget interface {
+	UpdateOne(ctx context.Context, filter, update any, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error)
+	CountDocuments(ctx context.Context, filter any, opts ...*options.CountOptions) (int64, error)
+}
+
+type twoPhaseOpRecord struct {
+	Database   string `bson:"database"`
+	Collection string `bson:"collection"`
+	Filter     bson.D `bson:"filter"`
+	Update     bson.D `bson:"update"`
+	Undo       bson.D `bson:"undo"`
+}
+
+type twoPhaseRecord struct {
+	ID           primitive.ObjectID `bson:"_id"`
+	State        string             `bson:"state"`
+	Ops          []twoPhaseOpRecord `bson:"ops"`
+	LastModified time.Time          `bson:"lastModified"`
+}
+
+// NewTwoPhaseCommitter returns a committer storing its state in the named collection.
+func (db *DB) NewTwoPhaseCommitter(collectionName string) *TwoPhaseCommitter {
+	t := &TwoPhaseCommitter{db: db, txns: db.NewCollection(collectionName)}
+	t.target = t.collection
+	return t
+}
+
+// Apply applies ops atomically: inside a multi-document transaction when the deployment supports
+// them and with a two phase commit otherwise, so the same code runs against both.
+func (t *TwoPhaseCommitter) Apply(ctx context.Context, ops ...TwoPhaseOp) error {
+	native, err := t.db.SupportsTransactions(ctx)
+	if err != nil {
+		return err
+	}
+	if !native {
+		return t.Run(ctx, ops...)
+	}
+	return t.db.WithTransaction(ctx, func(ctx context.Context) error {
+		for _, op := range ops {
+			res, err := op.Collection.UpdateOne(ctx, op.Filter, op.Update)
+			if err != nil {
+				return err
+			}
+			if res.MatchedCount == 0 {
+				return ErrTwoPhaseNoMatch
+			}
+		}
+		return nil
+	})
+}
+
+// Run applies ops with a two phase commit. When applying fails the transaction is rolled back and
+// the error returned. If the process dies midway Recover finishes the job.
+func (t *TwoPhaseCommitter) Run(ctx context.Context, ops ...TwoPhaseOp) error {
+	rec := twoPhaseRecord{ID: primitive.NewObjectID(), State: TwoPhaseInitial, LastModified: time.Now()}
+	for _, op := range ops {
+		rec.Ops = append(rec.Ops, twoPhaseOpRecord{
+			Database:   op.Collection.db.databaseName(),
+			Collection: op.Collection.collectionName(),
+			Filter:     op.Filter,
+			Update:     op.Update,
+			Undo:       op.Undo,
+		})
+	}
+	if _, err := t.txns.InsertOne(ctx, rec); err != nil {
+		return err
+	}
+
+	if err := t.transition(ctx, rec.ID, TwoPhaseInitial, TwoPhasePending); err != nil {
+		return err
+	}
+	if err := t.apply(ctx, rec); err != nil {
+		if rbErr := t.rollback(ctx, rec); rbErr != nil {
+			return fmt.Errorf("%w (rollback failed: %v)", err, rbErr)
+		}
+		return err
+	}
+	return t.commit(ctx, rec)
+}
+
+// Recover finishes transactions left pending or applied for longer than olderThan and completes
+// interrupted rollbacks. Run it periodically or at startup.
+func (t *TwoPhaseCommitter) Recover(ctx context.Context, olderThan time.Duration) error {
+	var stale []twoPhaseRecord
+	filter := bson.D{
+		{Key: "state", Value: bson.D{{Key: "$in", Value: bson.A{TwoPhasePending, TwoPhaseApplied, TwoPhaseCanceling}}}},
+		{Key: "lastModified", Value: bson.D{{Key: "$lt", Value: time.Now().Add(-olderThan)}}},
+	}
+	if err := t.txns.FindMany(ctx, filter, &stale); err != nil {
+		return err
+	}
+
+	for _, rec := range stale {
+		var err error
+		switch rec.State {
+		case TwoPhasePending:
+			// Applying is idempotent thanks to the pendingTransactions guard.
+			if err = t.apply(ctx, rec); err != nil {
+				err = t.rollback(ctx, rec)
+				break
+			}
+			err = t.commit(ctx, rec)
+		case TwoPhaseApplied:
+			err = t.commit(ctx, rec)
+		case TwoPhaseCanceling:
+			err = t.rollback(ctx, rec)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *TwoPhaseCommitter) apply(ctx context.Context, rec twoPhaseRecord) error {
+	for _, op := range rec.Ops {
+		filter := append(append(bson.D{}, op.Filter...), bson.E{Key: pendingField, Value: bson.D{{Key: "$ne", Value: rec.ID}}})
+		update := mergeUpdate(op.Update, "$push", bson.E{Key: pendingField, Value: rec.ID})
+		res, err := t.target(op).UpdateOne(ctx, filter, update)
+		if err != nil {
+			return err
+		}
+		if res.MatchedCount == 0 {
+			// Either already applied by an earlier attempt or the filter matches nothing.
+			applied, err := t.target(op).CountDocuments(ctx, append(append(bson.D{}, op.Filter...), bson.E{Key: pendingField, Value: rec.ID}))
+			if err != nil {
+				return err
+			}
+			if applied == 0 {
+				return ErrTwoPhaseNoMatch
+			}
+		}
+	}
+	return t.transition(ctx, rec.ID, TwoPhasePending, TwoPhaseApplied)
+}
+
+func (t *TwoPhaseCommitter) commit(ctx context.Context, rec twoPhaseRecord) error {
+	if err := t.pull(ctx, rec); err != nil {
+		return err
+	}
+	return t.transition(ctx, rec.ID, TwoPhaseApplied, TwoPhaseDone)
+}
+
+func (t *TwoPhaseCommitter) rollback(ctx context.Context, rec twoPhaseRecord) error {
+	if err := t.transition(ctx, rec.ID, TwoPhasePending, TwoPhaseCanceling); err != nil && !errors.Is(err, errTwoPhaseState) {
+		return err
+	}
+	for _, op := range rec.Ops {
+		// Only documents the transaction actually touched are undone.
+		filter := append(append(bson.D{}, op.Filter...), bson.E{Key: pendingField, Value: rec.ID})
+		update := mergeUpdate(op.Undo, "$pull", bson.E{Key: pendingField, Value: rec.ID})
+		if _, err := t.target(op).UpdateOne(ctx, filter, update); err != nil {
+			return err
+		}
+	}
+	return t.transition(ctx, rec.ID, TwoPhaseCanceling, TwoPhaseCancelled)
+}
+
+func (t *TwoPhaseCommitter) pull(ctx context.Context, rec twoPhaseRecord) error {
+	for _, op := range rec.Ops {
+		filter := append(append(bson.D{}, op.Filter...), bson.E{Key: pendingField, Value: rec.ID})
+		update := bson.D{{Key: "$pull", Value: bson.D{{Key: pendingField, Value: rec.ID}}}}
+		if _, err := t.target(op).UpdateOne(ctx, filter, update); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var errTwoPhaseState = errors.New("mongoboiler: two phase commit transaction is not in the expected state")
+
+func (t *TwoPhaseCommitter) transition(ctx context.Context, id primitive.ObjectID, from, to string) error {
+	res, err := t.txns.UpdateOne(ctx,
+		bson.D{{Key: "_id", Value: id}, {Key: "state", Value: from}},
+		bson.D{
+			{Key: "$set", Value: bson.D{{Key: "state", Value: to}}},
+			{Key: "$currentDate", Value: bson.D{{Key: "lastModified", Value: true}}},
+		})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return errTwoPhaseState
+	}
+	return nil
+}
+
+func (t *TwoPhaseCommitter) collection(op twoPhaseOpRecord) twoPhaseTarget {
+	return t.db.client().Database(op.Database).Collection(op.Collection)
+}
@@ -0,0 +1,224 @@
+package mongoboiler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// twoPhaseTxns keeps transaction records in memory, recording their state transitions.
+type twoPhaseTxns struct {
+	CollectionAPI
+	recs        []*twoPhaseRecord
+	transitions []string
+}
+
+func (f *twoPhaseTxns) InsertOne(ctx context.Context, new any, opts ...*options.InsertOneOptions) (InsertResult, error) {
+	rec := new.(twoPhaseRecord)
+	f.recs = append(f.recs, &rec)
+	return InsertResult{InsertedID: rec.ID, InsertedIDs: []any{rec.ID}}, nil
+}
+
+// UpdateOne applies the transitions of TwoPhaseCommitter.transition.
+func (f *twoPhaseTxns) UpdateOne(ctx context.Context, filter, update bson.D, opts ...*options.UpdateOptions) (UpdateResult, error) {
+	id, from := filter.Map()["_id"], filter.Map()["state"]
+	to := update.Map()["$set"].(bson.D).Map()["state"].(string)
+	for _, rec := range f.recs {
+		if rec.ID == id && rec.State == from {
+			rec.State = to
+			f.transitions = append(f.transitions, from.(string)+">"+to)
+			return UpdateResult{MatchedCount: 1, ModifiedCount: 1}, nil
+		}
+	}
+	return UpdateResult{}, nil
+}
+
+// FindMany returns the records Recover looks for, whatever their age.
+func (f *twoPhaseTxns) FindMany(ctx context.Context, filter bson.D, res any, opts ...*options.FindOptions) error {
+	out := res.(*[]twoPhaseRecord)
+	for _, rec := range f.recs {
+		switch rec.State {
+		case TwoPhasePending, TwoPhaseApplied, TwoPhaseCanceling:
+			*out = append(*out, *rec)
+		}
+	}
+	return nil
+}
+
+func (f *twoPhaseTxns) state(id primitive.ObjectID) string {
+	for _, rec := range f.recs {
+		if rec.ID == id {
+			return rec.State
+		}
+	}
+	return ""
+}
+
+// twoPhaseAccount is a target document, by _id.
+type twoPhaseAccount struct {
+	balance int
+	pending []any
+}
+
+// twoPhaseAccounts applies $inc balance updates to accounts, with the pendingTransactions
+// guards of TwoPhaseCommitter.
+type twoPhaseAccounts map[any]*twoPhaseAccount
+
+func (a twoPhaseAccounts) match(filter any) (*twoPhaseAccount, bool) {
+	f := filter.(bson.D).Map()
+	acc, ok := a[f["_id"]]
+	if !ok {
+		return nil, false
+	}
+	guard, ok := f[pendingField]
+	if !ok {
+		return acc, true
+	}
+	ne, notPending := guard.(bson.D)
+	for _, id := range acc.pending {
+		if notPending && id == ne.Map()["$ne"] {
+			return nil, false
+		}
+		if !notPending && id == guard {
+			return acc, true
+		}
+	}
+	return acc, notPending
+}
+
+func (a twoPhaseAccounts) UpdateOne(ctx context.Context, filter, update any, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	acc, ok := a.match(filter)
+	if !ok {
+		return &mongo.UpdateResult{}, nil
+	}
+	for _, e := range update.(bson.D) {
+		for _, f := range e.Value.(bson.D) {
+			switch {
+			case e.Key == "$inc" && f.Key == "balance":
+				acc.balance += f.Value.(int)
+			case e.Key == "$push" && f.Key == pendingField:
+				acc.pending = append(acc.pending, f.Value)
+			case e.Key == "$pull" && f.Key == pendingField:
+				for i, id := range acc.pending {
+					if id == f.Value {
+						acc.pending = append(acc.pending[:i], acc.pending[i+1:]...)
+						break
+					}
+				}
+			default:
+				return nil, fmt.Errorf("unexpected update %v", update)
+			}
+		}
+	}
+	return &mongo.UpdateResult{MatchedCount: 1, ModifiedCount: 1}, nil
+}
+
+func (a twoPhaseAccounts) CountDocuments(ctx context.Context, filter any, opts ...*options.CountOptions) (int64, error) {
+	if _, ok := a.match(filter); ok {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+func twoPhaseTest(t *testing.T, accounts twoPhaseAccounts) (*TwoPhaseCommitter, *twoPhaseTxns) {
+	coll := newTestCollection(t, "accounts")
+	txns := &twoPhaseTxns{}
+	c := coll.db.NewTwoPhaseCommitter("transactions")
+	c.txns = txns
+	c.target = func(op twoPhaseOpRecord) twoPhaseTarget { return accounts }
+	return c, txns
+}
+
+func transfer(coll *Collection, from, to any, amount int) []TwoPhaseOp {
+	move := func(id any, by int) TwoPhaseOp {
+		return TwoPhaseOp{
+			Collection: coll,
+			Filter:     bson.D{{Key: "_id", Value: id}},
+			Update:     bson.D{{Key: "$inc", Value: bson.D{{Key: "balance", Value: by}}}},
+			Undo:       bson.D{{Key: "$inc", Value: bson.D{{Key: "balance", Value: -by}}}},
+		}
+	}
+	return []TwoPhaseOp{move(from, -amount), move(to, amount)}
+}
+
+func TestTwoPhaseCommitter_Run(t *testing.T) {
+	accounts := twoPhaseAccounts{"a": {balance: 100}, "b": {balance: 0}}
+	c, txns := twoPhaseTest(t, accounts)
+	coll := newTestCollection(t, "accounts")
+
+	if err := c.Run(context.Background(), transfer(coll, "a", "b", 30)...); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if want := "[initial>pending pending>applied applied>done]"; fmt.Sprint(txns.transitions) != want {
+		t.Fatalf("expected transitions %s, got %v", want, txns.transitions)
+	}
+	if accounts["a"].balance != 70 || accounts["b"].balance != 30 || len(accounts["a"].pending)+len(accounts["b"].pending) != 0 {
+		t.Fatalf("expected the transfer applied and the guards pulled, got %+v %+v", accounts["a"], accounts["b"])
+	}
+
+	txns.transitions = nil
+	err := c.Run(context.Background(), transfer(coll, "a", "missing", 30)...)
+	if !errors.Is(err, ErrTwoPhaseNoMatch) {
+		t.Fatalf("expected ErrTwoPhaseNoMatch, got %v", err)
+	}
+	if want := "[initial>pending pending>canceling canceling>cancelled]"; fmt.Sprint(txns.transitions) != want {
+		t.Fatalf("expected transitions %s, got %v", want, txns.transitions)
+	}
+	if accounts["a"].balance != 70 || len(accounts["a"].pending) != 0 {
+		t.Fatalf("expected the applied op to be undone, got %+v", accounts["a"])
+	}
+}
+
+func TestTwoPhaseCommitter_Recover(t *testing.T) {
+	id := func() primitive.ObjectID { return primitive.NewObjectID() }
+	pending, partly, applied, canceling := id(), id(), id(), id()
+	accounts := twoPhaseAccounts{
+		"a": {balance: 100},
+		// partly died after applying its first op, applied and canceling after applying both.
+		"b": {balance: 90, pending: []any{partly}},
+		"c": {balance: 80, pending: []any{applied}},
+		"d": {balance: 70, pending: []any{canceling}},
+		"e": {balance: 10, pending: []any{applied, canceling}},
+	}
+	c, txns := twoPhaseTest(t, accounts)
+	coll := newTestCollection(t, "accounts")
+	record := func(id primitive.ObjectID, state string, ops []TwoPhaseOp) *twoPhaseRecord {
+		rec := &twoPhaseRecord{ID: id, State: state, LastModified: time.Now().Add(-time.Hour)}
+		for _, op := range ops {
+			rec.Ops = append(rec.Ops, twoPhaseOpRecord{Filter: op.Filter, Update: op.Update, Undo: op.Undo})
+		}
+		return rec
+	}
+	txns.recs = []*twoPhaseRecord{
+		record(pending, TwoPhasePending, transfer(coll, "a", "missing", 5)),
+		record(partly, TwoPhasePending, transfer(coll, "b", "a", 10)),
+		record(applied, TwoPhaseApplied, transfer(coll, "c", "e", 20)),
+		record(canceling, TwoPhaseCanceling, transfer(coll, "d", "e", 30)),
+		record(id(), TwoPhaseDone, nil),
+	}
+
+	if err := c.Recover(context.Background(), time.Minute); err != nil {
+		t.Fatalf("Recover failed: %v", err)
+	}
+	for rec, want := range map[primitive.ObjectID]string{
+		pending: TwoPhaseCancelled, partly: TwoPhaseDone, applied: TwoPhaseDone, canceling: TwoPhaseCancelled,
+	} {
+		if got := txns.state(rec); got != want {
+			t.Errorf("expected %s to end %s, got %s", rec.Hex(), want, got)
+		}
+	}
+	// partly applies its second op once, canceling undoes both of its ops.
+	want := map[string]int{"a": 110, "b": 90, "c": 80, "d": 100, "e": -20}
+	for name, balance := range want {
+		if acc := accounts[name]; acc.balance != balance || len(acc.pending) != 0 {
+			t.Errorf("expected %s to end with %d and no pending transactions, got %+v", name, balance, acc)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package mongoboiler
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+)
+
+// WithUnacknowledgedWrites sends the writes of the collection with write concern w:0, for
+// high-volume telemetry and logs whose loss is acceptable: the server does not reply, so writes
+// cost a single network send, but failures such as duplicate keys or validation errors go
+// unnoticed. The driver sends them without a session, outside transactions and causal
+// consistency; writes from one goroutine usually arrive in order, yet with a connection pool
+// nothing guarantees it.
+//
+// The writes succeed with zero results, no inserted IDs or counts, and are counted per
+// collection for DB.UnacknowledgedWrites. Writes returning documents, like FindOrCreate, and
+// index or collection management need replies, so use it for collections of their own that are
+// only inserted into, updated or pruned:
+//
+//	events := db.NewCollection("clickEvents", mongoboiler.WithUnacknowledgedWrites())
+func WithUnacknowledgedWrites() Option {
+	return func(s *settings) {
+		s.writeConcern = writeconcern.Unacknowledged()
+		s.unacknowledged = true
+	}
+}
+
+// UnacknowledgedCount is the number of writes a collection sent unacknowledged.
+type UnacknowledgedCount struct {
+	Database   string
+	Collection string
+	// Writes counts the write operations, Documents the documents they inserted.
+	Writes    int64
+	Documents int64
+}
+
+// UnacknowledgedWrites returns the number of writes sent unacknowledged since the connection was
+// created, per collection sorted by database and collection, e.g. to export them as metrics.
+// Writes that failed before being sent, such as for a lost connection, are not counted.
+func (db *DB) UnacknowledgedWrites() []UnacknowledgedCount {
+	return db.conn.unacknowledged.counts()
+}
+
+// unacknowledgedWrites counts the unacknowledged writes of a connection.
+type unacknowledgedWrites struct {
+	mu         sync.Mutex
+	namespaces map[string]*UnacknowledgedCount
+}
+
+// handler counts the writes next sends unacknowledged and makes them succeed.
+func (u *unacknowledgedWrites) handler(next Handler) Handler {
+	return func(ctx context.Context, op *Operation) error {
+		err := next(ctx, op)
+		if !errors.Is(err, mongo.ErrUnacknowledgedWrite) {
+			return err
+		}
+		var documents int64
+		if op.Kind == OpInsertOne || op.Kind == OpInsertMany {
+			documents = int64(len(op.Documents))
+		}
+		u.add(op.Database, op.Collection, documents)
+		return nil
+	}
+}
+
+func (u *unacknowledgedWrites) add(database, collection string, documents int64) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.namespaces == nil {
+		u.namespaces = map[string]*UnacknowledgedCount{}
+	}
+	key := database + "." + collection
+	count := u.namespaces[key]
+	if count == nil {
+		count = &UnacknowledgedCount{Database: database, Collection: collection}
+		u.namespaces[key] = count
+	}
+	count.Writes++
+	count.Documents += documents
+}
+
+func (u *unacknowledgedWrites) counts() []UnacknowledgedCount {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	keys := make([]string, 0, len(u.namespaces))
+	for k := range u.namespaces {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	out := make([]UnacknowledgedCount, len(keys))
+	for i, k := range keys {
+		out[i] = *u.namespaces[k]
+	}
+	return out
+}
@@ -0,0 +1,37 @@
+package mongoboiler
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestWithUnacknowledgedWrites(t *testing.T) {
+	coll := newTestCollection(t, "clickEvents", WithUnacknowledgedWrites())
+	if wc := coll.settings.writeConcern; wc == nil || wc.Acknowledged() {
+		t.Fatalf("write concern %v, want w:0", wc)
+	}
+	unacknowledged := func(context.Context, *Operation) error { return mongo.ErrUnacknowledgedWrite }
+
+	op := coll.newOp(OpInsertMany)
+	op.Documents = []any{bson.D{}, bson.D{}}
+	if err := coll.run(context.Background(), op, unacknowledged); err != nil {
+		t.Fatalf("unacknowledged insert failed: %v", err)
+	}
+	if err := coll.run(context.Background(), coll.newOp(OpUpdateMany), unacknowledged); err != nil {
+		t.Fatalf("unacknowledged update failed: %v", err)
+	}
+	failed := errors.New("connection reset")
+	if err := coll.run(context.Background(), coll.newOp(OpDeleteMany), func(context.Context, *Operation) error { return failed }); !errors.Is(err, failed) {
+		t.Fatalf("got %v, want the send error", err)
+	}
+
+	want := []UnacknowledgedCount{{Database: "testdb", Collection: "clickEvents", Writes: 2, Documents: 2}}
+	if got := coll.db.UnacknowledgedWrites(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
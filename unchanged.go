@@ -0,0 +1,177 @@
+package mongoboiler
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/anurag925/mongoboiler/internal/bsonutil"
+)
+
+// DefaultContentHashField is the field WithSkipUnchanged keeps the content hash of replaced
+// documents in.
+const DefaultContentHashField = "_contentHash"
+
+// WithSkipUnchanged skips ReplaceOne and UpdateFields calls that would not change the stored
+// document, so sync jobs rewriting unchanged documents do not churn the oplog, bump versions or
+// trigger change streams. Skipped calls return an UpdateResult with MatchedCount 1 and
+// ModifiedCount 0, i.e. OutcomeUnchanged.
+//
+// ReplaceOne stores a hash of the replacement, without _id and the version of Versioned models,
+// in hashField (DefaultContentHashField when empty) and only reads that field back to compare.
+// UpdateFields reads the fields it would set and compares them. Both read before writing, so
+// a changing write costs an extra round trip. Updates through the wrapper remove the stored
+// hash, as they may change the content without updating it.
+func WithSkipUnchanged(hashField string) Option {
+	if hashField == "" {
+		hashField = DefaultContentHashField
+	}
+	return func(s *settings) {
+		s.contentHashField = hashField
+	}
+}
+
+// contentHashField returns the field of the content hash, empty when unchanged writes are not
+// skipped.
+func (c Collection) contentHashField() string {
+	if c.settings == nil {
+		return ""
+	}
+	return c.settings.contentHashField
+}
+
+// contentHash returns the hash of doc without the fields in skip, independent of field order.
+func contentHash(doc bson.D, skip ...string) (string, error) {
+	content := make(bson.D, 0, len(doc))
+	for _, e := range doc {
+		skipped := false
+		for _, key := range skip {
+			skipped = skipped || e.Key == key
+		}
+		if !skipped {
+			content = append(content, e)
+		}
+	}
+	raw, err := bson.Marshal(content)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	hashValue(h, bson.RawValue{Type: bson.TypeEmbeddedDocument, Value: raw})
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// unchangedReplace returns the content hash of doc and reports whether the document matching
+// filter already has it.
+func (c Collection) unchangedReplace(ctx context.Context, filter bson.D, doc any) (sum string, res UpdateResult, unchanged bool, err error) {
+	field := c.contentHashField()
+	content, err := toDocument(doc)
+	if err != nil {
+		return "", res, false, err
+	}
+	skip := []string{"_id", field}
+	if _, ok := doc.(versionedModel); ok {
+		skip = append(skip, versionField)
+	}
+	if sum, err = contentHash(content, skip...); err != nil {
+		return "", res, false, err
+	}
+
+	var stored bson.Raw
+	err = c.FindOne(ctx, filter, &stored, options.FindOne().SetProjection(bson.D{{Key: field, Value: 1}}))
+	if errors.Is(err, ErrNotFound) {
+		return sum, res, false, nil
+	}
+	if err != nil {
+		return "", res, false, err
+	}
+	if stored, ok := stored.Lookup(field).StringValueOK(); !ok || stored != sum {
+		return sum, res, false, nil
+	}
+	return sum, unchangedResult(stored), true, nil
+}
+
+// unchangedUpdate reports whether the $set and $unset of update leave the document matching
+// filter as it is.
+func (c Collection) unchangedUpdate(ctx context.Context, filter, update bson.D) (UpdateResult, bool, error) {
+	var set, unset bson.D
+	for _, e := range update {
+		d, ok := e.Value.(bson.D)
+		switch {
+		case !ok:
+			return UpdateResult{}, false, nil
+		case e.Key == "$set":
+			set = d
+		case e.Key == "$unset":
+			unset = d
+		default:
+			// Other operators, such as $inc, always change the document.
+			return UpdateResult{}, false, nil
+		}
+	}
+	if len(set) == 0 && len(unset) == 0 {
+		return UpdateResult{}, false, nil
+	}
+
+	projection := bson.D{}
+	for _, e := range append(append(bson.D{}, set...), unset...) {
+		projection = append(projection, bson.E{Key: e.Key, Value: 1})
+	}
+	var stored bson.Raw
+	err := c.FindOne(ctx, filter, &stored, options.FindOne().SetProjection(projection))
+	if errors.Is(err, ErrNotFound) {
+		return UpdateResult{}, false, nil
+	}
+	if err != nil {
+		return UpdateResult{}, false, err
+	}
+
+	values, err := bson.Marshal(set)
+	if err != nil {
+		return UpdateResult{}, false, err
+	}
+	for _, e := range set {
+		cur, err := stored.LookupErr(strings.Split(e.Key, ".")...)
+		if err != nil {
+			return UpdateResult{}, false, nil
+		}
+		next := bson.Raw(values).Lookup(e.Key)
+		if cur.Type != next.Type || !bsonutil.Equal(cur, next) {
+			return UpdateResult{}, false, nil
+		}
+	}
+	for _, e := range unset {
+		if _, err := stored.LookupErr(strings.Split(e.Key, ".")...); err == nil {
+			return UpdateResult{}, false, nil
+		}
+	}
+	return unchangedResult(stored), true, nil
+}
+
+// withContentHash returns doc with field set to sum.
+func withContentHash(doc any, field, sum string) (bson.D, error) {
+	d, err := toDocument(doc)
+	if err != nil {
+		return nil, err
+	}
+	hashed := make(bson.D, 0, len(d)+1)
+	for _, e := range d {
+		if e.Key != field {
+			hashed = append(hashed, e)
+		}
+	}
+	return append(hashed, bson.E{Key: field, Value: sum}), nil
+}
+
+func unchangedResult(stored bson.Raw) UpdateResult {
+	res := UpdateResult{MatchedCount: 1}
+	if id, err := stored.LookupErr("_id"); err == nil {
+		_ = id.Unmarshal(&res.DocumentID)
+	}
+	return res
+}
@@ -0,0 +1,115 @@
+package mongoboiler
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// primeFindOne caches doc as the result of FindOne for filter with opts.
+func primeFindOne(t *testing.T, coll *Collection, filter bson.D, doc bson.D, opts ...*options.FindOneOptions) {
+	t.Helper()
+	op := coll.newOp(OpFindOne)
+	op.Filter = filter
+	key, ok := coll.readCache().key(context.Background(), op, opts)
+	if !ok {
+		t.Fatalf("expected the read to be cacheable")
+	}
+	coll.readCache().set(context.Background(), key, []bson.Raw{mustRaw(t, doc)})
+}
+
+func skipUnchangedCollection(t *testing.T, writes *[]*Operation) *Collection {
+	return newTestCollection(t, "items", WithCache(NewLRUCache(10), 0), WithSkipUnchanged(""),
+		WithMiddleware(func(next Handler) Handler {
+			return func(ctx context.Context, op *Operation) error {
+				if !op.Kind.IsWrite() {
+					return next(ctx, op)
+				}
+				*writes = append(*writes, op)
+				return nil
+			}
+		}))
+}
+
+func TestSkipUnchanged_ReplaceOne(t *testing.T) {
+	var writes []*Operation
+	coll := skipUnchangedCollection(t, &writes)
+	ctx := context.Background()
+	filter := bson.D{{Key: "_id", Value: 1}}
+	projection := options.FindOne().SetProjection(bson.D{{Key: DefaultContentHashField, Value: 1}})
+
+	sum, err := contentHash(bson.D{{Key: "name", Value: "a"}, {Key: "qty", Value: 1}})
+	if err != nil {
+		t.Fatalf("contentHash failed: %v", err)
+	}
+	primeFindOne(t, coll, filter, bson.D{{Key: "_id", Value: 1}, {Key: DefaultContentHashField, Value: sum}}, projection)
+	res, err := coll.ReplaceOne(ctx, filter, decodeItem{Name: "a", Qty: 1})
+	if err != nil {
+		t.Fatalf("ReplaceOne failed: %v", err)
+	}
+	if len(writes) != 0 || res.Outcome() != OutcomeUnchanged || res.DocumentID != int32(1) {
+		t.Fatalf("expected the replace to be skipped, got %d writes and %+v", len(writes), res)
+	}
+
+	if _, err := coll.ReplaceOne(ctx, filter, decodeItem{Name: "a", Qty: 2}); err != nil {
+		t.Fatalf("ReplaceOne failed: %v", err)
+	}
+	if len(writes) != 1 || writes[0].Kind != OpReplaceOne {
+		t.Fatalf("expected a changed document to be written, got %+v", writes)
+	}
+}
+
+func TestSkipUnchanged_UpdateFields(t *testing.T) {
+	var writes []*Operation
+	coll := skipUnchangedCollection(t, &writes)
+	ctx := context.Background()
+	filter := bson.D{{Key: "_id", Value: 1}}
+	projection := options.FindOne().SetProjection(bson.D{{Key: "name", Value: 1}, {Key: "qty", Value: 1}})
+
+	primeFindOne(t, coll, filter, bson.D{{Key: "_id", Value: 1}, {Key: "name", Value: "a"}, {Key: "qty", Value: 1}}, projection)
+	res, err := coll.UpdateFields(ctx, filter, decodeItem{Name: "a", Qty: 1})
+	if err != nil {
+		t.Fatalf("UpdateFields failed: %v", err)
+	}
+	if len(writes) != 0 || res.Outcome() != OutcomeUnchanged {
+		t.Fatalf("expected the update to be skipped, got %d writes and %+v", len(writes), res)
+	}
+
+	primeFindOne(t, coll, filter, bson.D{{Key: "_id", Value: 1}, {Key: "name", Value: "a"}, {Key: "qty", Value: 2}}, projection)
+	if _, err := coll.UpdateFields(ctx, filter, decodeItem{Name: "a", Qty: 1}); err != nil {
+		t.Fatalf("UpdateFields failed: %v", err)
+	}
+	if len(writes) != 1 {
+		t.Fatalf("expected a changed document to be written, got %d writes", len(writes))
+	}
+	want := bson.D{
+		{Key: "$set", Value: bson.D{{Key: "name", Value: "a"}, {Key: "qty", Value: 1}}},
+		{Key: "$unset", Value: bson.D{{Key: DefaultContentHashField, Value: ""}}},
+	}
+	if !reflect.DeepEqual(writes[0].Update, want) {
+		t.Fatalf("got update %v, want %v", writes[0].Update, want)
+	}
+}
+
+func TestContentHash(t *testing.T) {
+	a, _ := contentHash(bson.D{{Key: "_id", Value: 1}, {Key: "a", Value: 1}, {Key: "b", Value: "x"}}, "_id")
+	b, _ := contentHash(bson.D{{Key: "b", Value: "x"}, {Key: "a", Value: 1}, {Key: "_id", Value: 2}}, "_id")
+	c, _ := contentHash(bson.D{{Key: "a", Value: int64(1)}, {Key: "b", Value: "x"}})
+	if a != b {
+		t.Fatalf("expected the hash not to depend on field order or skipped fields")
+	}
+	if a == c {
+		t.Fatalf("expected the hash to depend on value types")
+	}
+
+	hashed, err := withContentHash(bson.D{{Key: "a", Value: 1}, {Key: DefaultContentHashField, Value: "old"}}, DefaultContentHashField, a)
+	if err != nil {
+		t.Fatalf("withContentHash failed: %v", err)
+	}
+	if want := (bson.D{{Key: "a", Value: 1}, {Key: DefaultContentHashField, Value: a}}); !reflect.DeepEqual(hashed, want) {
+		t.Fatalf("got %v, want %v", hashed, want)
+	}
+}
@@ -0,0 +1,160 @@
+package mongoboiler
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DefaultUniqueValuesCollection is the registry collection UniqueValues claims values in.
+const DefaultUniqueValuesCollection = "unique_values"
+
+// ErrValueTaken is returned when a value of a UniqueValues scope is claimed by another document.
+var ErrValueTaken = errors.New("mongoboiler: value is already taken")
+
+// WithUniqueValuesCollection changes the registry collection UniqueValues claims values in.
+func WithUniqueValuesCollection(name string) Option {
+	return func(s *settings) {
+		s.uniqueValuesCollection = name
+	}
+}
+
+// UniqueValues enforces that a value, such as a handle, is unique across collections: every
+// document holding one claims it in a registry collection whose _id is the scope and the value,
+// in the same transaction as the document's own write. Writes of the value that bypass UniqueValues
+// are not checked.
+//
+// The methods taking a Collection need a deployment supporting transactions; when ctx already
+// carries one, they join it. Claim and Release can be used directly inside own transactions.
+type UniqueValues struct {
+	db    *DB
+	scope string
+}
+
+// UniqueClaim records which document claimed a value.
+type UniqueClaim struct {
+	Collection string    `bson:"collection"`
+	DocumentID any       `bson:"documentId"`
+	ClaimedAt  time.Time `bson:"claimedAt"`
+}
+
+// UniqueValues returns the values of the named scope, e.g. "handle" for handles shared by users
+// and organizations.
+func (db *DB) UniqueValues(scope string) *UniqueValues {
+	return &UniqueValues{db: db, scope: scope}
+}
+
+func (u *UniqueValues) registry() *Collection {
+	name := DefaultUniqueValuesCollection
+	if u.db.settings != nil && u.db.settings.uniqueValuesCollection != "" {
+		name = u.db.settings.uniqueValuesCollection
+	}
+	return u.db.NewCollection(name)
+}
+
+// key returns the registry _id of value. Values compare by BSON type, so 1 and "1" differ.
+func (u *UniqueValues) key(value any) bson.D {
+	return bson.D{{Key: "scope", Value: u.scope}, {Key: "value", Value: value}}
+}
+
+// Claim claims value for the document documentID of the named collection, returning
+// ErrValueTaken if another document has it. Claiming a value again for its owner succeeds.
+func (u *UniqueValues) Claim(ctx context.Context, value any, collection string, documentID any) error {
+	// Upserting on the owner matches an existing claim of it and otherwise inserts, which
+	// violates the unique _id if someone else has the value.
+	_, err := u.registry().UpdateOne(ctx, u.ownerFilter(value, collection, documentID),
+		bson.D{{Key: "$setOnInsert", Value: bson.D{{Key: "claimedAt", Value: time.Now().UTC().Truncate(time.Millisecond)}}}},
+		options.Update().SetUpsert(true))
+	if errors.Is(err, ErrDuplicateKey) {
+		return ErrValueTaken
+	}
+	return err
+}
+
+// Release releases value, whoever claimed it. Releasing a value not claimed is no error.
+func (u *UniqueValues) Release(ctx context.Context, value any) error {
+	_, err := u.registry().DeleteOne(ctx, bson.D{{Key: "_id", Value: u.key(value)}})
+	return err
+}
+
+// release releases value if the document documentID of collection claimed it.
+func (u *UniqueValues) release(ctx context.Context, value any, collection string, documentID any) error {
+	_, err := u.registry().DeleteOne(ctx, u.ownerFilter(value, collection, documentID))
+	return err
+}
+
+func (u *UniqueValues) ownerFilter(value any, collection string, documentID any) bson.D {
+	return bson.D{
+		{Key: "_id", Value: u.key(value)},
+		{Key: "collection", Value: collection},
+		{Key: "documentId", Value: documentID},
+	}
+}
+
+// Owner returns the claim of value, ErrNotFound if it is free.
+func (u *UniqueValues) Owner(ctx context.Context, value any) (UniqueClaim, error) {
+	var claim UniqueClaim
+	err := u.registry().FindOne(ctx, bson.D{{Key: "_id", Value: u.key(value)}}, &claim,
+		options.FindOne().SetProjection(bson.D{{Key: "_id", Value: 0}}))
+	return claim, err
+}
+
+// InsertOne inserts doc into c and claims value for it, failing with ErrValueTaken without
+// inserting if another document has the value.
+func (u *UniqueValues) InsertOne(ctx context.Context, c *Collection, doc any, value any) (InsertResult, error) {
+	var res InsertResult
+	err := u.transaction(ctx, func(ctx context.Context) error {
+		var err error
+		if res, err = c.InsertOne(ctx, doc); err != nil {
+			return err
+		}
+		return u.Claim(ctx, value, c.name, res.InsertedID)
+	})
+	return res, err
+}
+
+// Change moves the document documentID of c from oldValue to newValue and applies update to it,
+// which should set the field holding the value. It fails with ErrValueTaken without changes if
+// another document has newValue.
+func (u *UniqueValues) Change(ctx context.Context, c *Collection, documentID, oldValue, newValue any, update bson.D) (UpdateResult, error) {
+	var res UpdateResult
+	err := u.transaction(ctx, func(ctx context.Context) error {
+		if err := u.release(ctx, oldValue, c.name, documentID); err != nil {
+			return err
+		}
+		if err := u.Claim(ctx, newValue, c.name, documentID); err != nil {
+			return err
+		}
+		var err error
+		res, err = c.UpdateOne(ctx, bson.D{{Key: "_id", Value: documentID}}, update)
+		if err == nil && res.MatchedCount == 0 {
+			err = ErrNotFound
+		}
+		return err
+	})
+	return res, err
+}
+
+// DeleteOne deletes the document documentID of c and releases its value.
+func (u *UniqueValues) DeleteOne(ctx context.Context, c *Collection, documentID, value any) (DeleteResult, error) {
+	var res DeleteResult
+	err := u.transaction(ctx, func(ctx context.Context) error {
+		var err error
+		if res, err = c.DeleteOne(ctx, bson.D{{Key: "_id", Value: documentID}}); err != nil {
+			return err
+		}
+		return u.release(ctx, value, c.name, documentID)
+	})
+	return res, err
+}
+
+func (u *UniqueValues) transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if mongo.SessionFromContext(ctx) != nil {
+		return fn(ctx)
+	}
+	return u.db.WithTransaction(ctx, fn)
+}
@@ -0,0 +1,64 @@
+package mongoboiler
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var errDuplicateHandle = mongo.WriteException{WriteErrors: []mongo.WriteError{{
+	Code:    11000,
+	Message: `E11000 duplicate key error collection: app.unique_values index: _id_ dup key: { _id: { scope: "handle", value: "ann" } }`,
+}}}
+
+// uniqueTestValues returns values whose upserts fail with a duplicate key error.
+func uniqueTestValues(t *testing.T, ops *[]*Operation) *UniqueValues {
+	db := newTestCollection(t, "unused",
+		WithUniqueValuesCollection("handles"),
+		WithMiddleware(func(next Handler) Handler {
+			return func(ctx context.Context, op *Operation) error {
+				*ops = append(*ops, op)
+				if op.Kind == OpUpdateOne {
+					return errDuplicateHandle
+				}
+				return nil
+			}
+		})).db
+	return db.UniqueValues("handle")
+}
+
+func TestUniqueValues_ClaimTaken(t *testing.T) {
+	var ops []*Operation
+	u := uniqueTestValues(t, &ops)
+
+	err := u.Claim(context.Background(), "ann", "users", primitive.NewObjectID())
+	if !errors.Is(err, ErrValueTaken) {
+		t.Fatalf("expected ErrValueTaken, got %v", err)
+	}
+	if len(ops) != 1 || ops[0].Collection != "handles" {
+		t.Fatalf("expected an upsert into the registry, got %d ops", len(ops))
+	}
+	filter := ops[0].Filter
+	if key := filter[0].Value.(bson.D); filter[0].Key != "_id" || key[0].Value != "handle" || key[1].Value != "ann" {
+		t.Fatalf("unexpected registry filter %v", filter)
+	}
+	if len(filter) != 3 || filter[1].Value != "users" {
+		t.Fatalf("expected the filter to match the owner, got %v", filter)
+	}
+}
+
+func TestUniqueValues_Release(t *testing.T) {
+	var ops []*Operation
+	u := uniqueTestValues(t, &ops)
+
+	if err := u.release(context.Background(), "ann", "users", 7); err != nil {
+		t.Fatalf("release failed: %v", err)
+	}
+	if len(ops) != 1 || ops[0].Kind != OpDeleteOne || len(ops[0].Filter) != 3 {
+		t.Fatalf("expected a delete restricted to the owner, got %+v", ops)
+	}
+}
@@ -0,0 +1,129 @@
+package mongoboiler
+
+import (
+	"errors"
+	"reflect"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsoncodec"
+)
+
+// ErrNotStruct is returned when a struct (or pointer to one) was expected.
+var ErrNotStruct = errors.New("mongoboiler: expected a struct or pointer to struct")
+
+// BuildUpdate builds an update document from the fields of struct v.
+// Present Optional fields are $set and absent ones are $unset, so a struct decoded from a
+// partial document round trips correctly. Other fields are $set unless tagged omitempty and zero.
+// The _id field is never included.
+func BuildUpdate(v any) (bson.D, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil, ErrNotStruct
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, ErrNotStruct
+	}
+
+	set, unset := bson.D{}, bson.D{}
+	if err := collectUpdateFields(rv, &set, &unset); err != nil {
+		return nil, err
+	}
+
+	update := bson.D{}
+	if len(set) > 0 {
+		update = append(update, bson.E{Key: "$set", Value: set})
+	}
+	if len(unset) > 0 {
+		update = append(update, bson.E{Key: "$unset", Value: unset})
+	}
+	return update, nil
+}
+
+func collectUpdateFields(rv reflect.Value, set, unset *bson.D) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		tags, err := bsoncodec.DefaultStructTagParser(sf)
+		if err != nil {
+			return err
+		}
+		if tags.Skip || tags.Name == "_id" {
+			continue
+		}
+
+		fv := rv.Field(i)
+		if sf.Type == versionedType {
+			// The version is maintained by the versioned write methods.
+			continue
+		}
+		if tags.Inline {
+			for fv.Kind() == reflect.Ptr {
+				if fv.IsNil() {
+					break
+				}
+				fv = fv.Elem()
+			}
+			switch fv.Kind() {
+			case reflect.Struct:
+				if err := collectUpdateFields(fv, set, unset); err != nil {
+					return err
+				}
+				continue
+			case reflect.Map:
+				iter := fv.MapRange()
+				for iter.Next() {
+					*set = append(*set, bson.E{Key: iter.Key().String(), Value: iter.Value().Interface()})
+				}
+				continue
+			}
+		}
+
+		if opt, ok := fv.Interface().(optionalField); ok {
+			if opt.present() {
+				*set = append(*set, bson.E{Key: tags.Name, Value: opt.value()})
+			} else {
+				*unset = append(*unset, bson.E{Key: tags.Name, Value: ""})
+			}
+			continue
+		}
+		if tags.OmitEmpty && fv.IsZero() {
+			continue
+		}
+		*set = append(*set, bson.E{Key: tags.Name, Value: fv.Interface()})
+	}
+	return nil
+}
+
+// mergeUpdate adds e under the update operator op (e.g. $inc), creating the operator if needed.
+// update is not modified.
+func mergeUpdate(update bson.D, op string, e ...bson.E) bson.D {
+	merged := make(bson.D, 0, len(update)+1)
+	found := false
+	for _, elem := range update {
+		if elem.Key == op {
+			found = true
+			var fields bson.D
+			switch v := elem.Value.(type) {
+			case bson.D:
+				fields = append(append(fields, v...), e...)
+			default:
+				if d, err := toDocument(v); err == nil {
+					fields = d
+				}
+				fields = append(fields, e...)
+			}
+			elem = bson.E{Key: op, Value: fields}
+		}
+		merged = append(merged, elem)
+	}
+	if !found {
+		merged = append(merged, bson.E{Key: op, Value: bson.D(e)})
+	}
+	return merged
+}
@@ -0,0 +1,212 @@
+package mongoboiler
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/anurag925/mongoboiler/internal/bsonutil"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// maxProjectionDivergences is the number of divergences a ProjectionReport lists; all of them
+// are counted.
+const maxProjectionDivergences = 100
+
+// verifyBatchSize is the number of source documents whose targets VerifyProjection reads at once.
+const verifyBatchSize = 100
+
+// ProjectionMapper maps a source document to the document the read model should hold for it. The
+// _id of the returned document identifies the target document; a nil document means the source
+// document should have none, so a target document with the _id of the source is unexpected.
+type ProjectionMapper func(source bson.Raw) (bson.D, error)
+
+// ProjectionReport is the result of VerifyProjection.
+type ProjectionReport struct {
+	// Sampled is the number of source documents checked, Expected how many of them should have a
+	// target document.
+	Sampled  int
+	Expected int
+	// Missing counts the target documents that do not exist, Diverged those differing from the
+	// mapping and Unexpected those existing for sources that should have none.
+	Missing    int
+	Diverged   int
+	Unexpected int
+	// Divergences lists the first hundred missing, diverged or unexpected target documents.
+	Divergences []ProjectionDivergence
+}
+
+// Consistent reports whether every checked target document matched its source.
+func (r ProjectionReport) Consistent() bool {
+	return r.Missing == 0 && r.Diverged == 0 && r.Unexpected == 0
+}
+
+func (r ProjectionReport) String() string {
+	return fmt.Sprintf("%d sampled, %d expected: %d missing, %d diverged, %d unexpected",
+		r.Sampled, r.Expected, r.Missing, r.Diverged, r.Unexpected)
+}
+
+// ProjectionDivergence describes a target document not matching its source.
+type ProjectionDivergence struct {
+	SourceID any
+	TargetID any
+	// Missing is set when the target document does not exist, Unexpected when it exists for a
+	// source mapped to none, otherwise Fields are the dotted paths of the first difference in
+	// each top level field differing from the mapping.
+	Missing    bool
+	Unexpected bool
+	Fields     []string
+}
+
+// VerifyProjection checks that target, a read model denormalized from source by a materialized
+// view, a change stream consumer or any other job, is consistent with it. It reads a $sample of
+// sample source documents, every document if sample is zero, maps them with mapFn and compares
+// the results to the target documents with their _id, reporting missing and diverging ones as
+// well as target documents of sources mapped to none.
+// Only the fields of the mapped documents are compared, so target documents may hold more;
+// numbers of different types compare by value.
+//
+// Run it regularly, e.g. from a nightly job alerting when the report is not Consistent, as a
+// safety net for the code keeping read models up to date. Sources written during the check may
+// be reported as diverged until their read model caught up.
+func VerifyProjection(ctx context.Context, source, target *Collection, mapFn ProjectionMapper, sample int) (ProjectionReport, error) {
+	v := projectionVerifier{target: target, mapFn: mapFn}
+	if sample > 0 {
+		var docs []bson.Raw
+		pipeline := mongo.Pipeline{{{Key: "$sample", Value: bson.D{{Key: "size", Value: sample}}}}}
+		if err := source.Aggregate(ctx, pipeline, &docs); err != nil {
+			return ProjectionReport{}, err
+		}
+		for start := 0; start < len(docs); start += verifyBatchSize {
+			end := start + verifyBatchSize
+			if end > len(docs) {
+				end = len(docs)
+			}
+			if err := v.check(ctx, docs[start:end]); err != nil {
+				return v.report, err
+			}
+		}
+		return v.report, nil
+	}
+
+	var batch []bson.Raw
+	err := source.FindEach(ctx, bson.D{}, func(dec Decoder) error {
+		var doc bson.Raw
+		if err := dec.Decode(&doc); err != nil {
+			return err
+		}
+		if batch = append(batch, append(bson.Raw(nil), doc...)); len(batch) < verifyBatchSize {
+			return nil
+		}
+		err := v.check(ctx, batch)
+		batch = batch[:0]
+		return err
+	})
+	if err == nil {
+		err = v.check(ctx, batch)
+	}
+	return v.report, err
+}
+
+type projectionVerifier struct {
+	target *Collection
+	mapFn  ProjectionMapper
+	report ProjectionReport
+}
+
+// check compares the target documents of sources with their mapping.
+func (v *projectionVerifier) check(ctx context.Context, sources []bson.Raw) error {
+	v.report.Sampled += len(sources)
+	type expectation struct {
+		sourceID any
+		id       bson.RawValue
+		doc      bson.Raw
+	}
+	var expected []expectation
+	// The sources mapped to none, whose _id no target document should have.
+	var unexpected []bson.RawValue
+	ids := bson.A{}
+	for _, source := range sources {
+		mapped, err := v.mapFn(source)
+		if err != nil {
+			return fmt.Errorf("mongoboiler: mapping %v: %w", source.Lookup("_id"), err)
+		}
+		if mapped == nil {
+			id := source.Lookup("_id")
+			unexpected = append(unexpected, id)
+			ids = append(ids, id)
+			continue
+		}
+		doc, err := bson.Marshal(mapped)
+		if err != nil {
+			return err
+		}
+		id, err := bson.Raw(doc).LookupErr("_id")
+		if err != nil {
+			return fmt.Errorf("mongoboiler: mapping of %v has no _id", source.Lookup("_id"))
+		}
+		expected = append(expected, expectation{source.Lookup("_id"), id, doc})
+		ids = append(ids, id)
+	}
+	v.report.Expected += len(expected)
+	if len(ids) == 0 {
+		return nil
+	}
+
+	var targets []bson.Raw
+	if err := v.target.FindMany(ctx, bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: ids}}}}, &targets); err != nil {
+		return err
+	}
+	lookup := func(id bson.RawValue) bson.Raw {
+		for _, t := range targets {
+			if bsonutil.Equal(t.Lookup("_id"), id) {
+				return t
+			}
+		}
+		return nil
+	}
+	for _, e := range expected {
+		found := lookup(e.id)
+		if found == nil {
+			v.report.Missing++
+			v.add(ProjectionDivergence{SourceID: e.sourceID, TargetID: e.id, Missing: true})
+			continue
+		}
+		if fields := divergingFields(e.doc, found); len(fields) > 0 {
+			v.report.Diverged++
+			v.add(ProjectionDivergence{SourceID: e.sourceID, TargetID: e.id, Fields: fields})
+		}
+	}
+	for _, id := range unexpected {
+		if lookup(id) != nil {
+			v.report.Unexpected++
+			v.add(ProjectionDivergence{SourceID: id, TargetID: id, Unexpected: true})
+		}
+	}
+	return nil
+}
+
+func (v *projectionVerifier) add(d ProjectionDivergence) {
+	if len(v.report.Divergences) < maxProjectionDivergences {
+		v.report.Divergences = append(v.report.Divergences, d)
+	}
+}
+
+// divergingFields returns the paths at which the top level fields of expected differ in actual.
+func divergingFields(expected, actual bson.Raw) []string {
+	elems, _ := expected.Elements()
+	var fields []string
+	for _, e := range elems {
+		got, err := actual.LookupErr(e.Key())
+		if err != nil {
+			fields = append(fields, e.Key())
+			continue
+		}
+		want, _ := bson.Marshal(bson.D{{Key: e.Key(), Value: e.Value()}})
+		have, _ := bson.Marshal(bson.D{{Key: e.Key(), Value: got}})
+		if path, ok := bsonutil.Diff(want, have); ok {
+			fields = append(fields, path)
+		}
+	}
+	return fields
+}
@@ -0,0 +1,61 @@
+package mongoboiler
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestVerifyProjection_Check(t *testing.T) {
+	target := newTestCollection(t, "orderSummaries", WithCache(NewLRUCache(100), 0))
+	sources := []bson.Raw{
+		mustRaw(t, bson.D{{Key: "_id", Value: 1}, {Key: "total", Value: 30}, {Key: "items", Value: bson.A{"a", "b"}}}),
+		mustRaw(t, bson.D{{Key: "_id", Value: 2}, {Key: "total", Value: 10}, {Key: "items", Value: bson.A{"c"}}}),
+		mustRaw(t, bson.D{{Key: "_id", Value: 3}, {Key: "total", Value: 5}, {Key: "items", Value: bson.A{}}}),
+		mustRaw(t, bson.D{{Key: "_id", Value: 4}, {Key: "draft", Value: true}}),
+	}
+	summary := func(source bson.Raw) (bson.D, error) {
+		if _, err := source.LookupErr("draft"); err == nil {
+			return nil, nil
+		}
+		items, _ := source.Lookup("items").Array().Values()
+		return bson.D{{Key: "_id", Value: source.Lookup("_id")}, {Key: "total", Value: source.Lookup("total")}, {Key: "count", Value: len(items)}}, nil
+	}
+	ids := bson.A{}
+	for _, s := range sources {
+		ids = append(ids, s.Lookup("_id"))
+	}
+	primeFind(t, target, bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: ids}}}},
+		// Numbers of other types match, fields the mapping does not produce are ignored.
+		bson.D{{Key: "_id", Value: int64(1)}, {Key: "total", Value: 30.0}, {Key: "count", Value: 2}, {Key: "updatedAt", Value: 7}},
+		bson.D{{Key: "_id", Value: 2}, {Key: "total", Value: 12}, {Key: "count", Value: 1}},
+		// Drafts have no summary.
+		bson.D{{Key: "_id", Value: 4}, {Key: "total", Value: 0}})
+
+	v := projectionVerifier{target: target, mapFn: summary}
+	if err := v.check(context.Background(), sources); err != nil {
+		t.Fatalf("check failed: %v", err)
+	}
+	r := v.report
+	if r.Sampled != 4 || r.Expected != 3 || r.Missing != 1 || r.Diverged != 1 || r.Unexpected != 1 || r.Consistent() {
+		t.Fatalf("report %s", r)
+	}
+	want := []ProjectionDivergence{
+		{SourceID: sources[1].Lookup("_id"), TargetID: sources[1].Lookup("_id"), Fields: []string{"total"}},
+		{SourceID: sources[2].Lookup("_id"), TargetID: sources[2].Lookup("_id"), Missing: true},
+		{SourceID: sources[3].Lookup("_id"), TargetID: sources[3].Lookup("_id"), Unexpected: true},
+	}
+	if !reflect.DeepEqual(r.Divergences, want) {
+		t.Fatalf("divergences %+v, want %+v", r.Divergences, want)
+	}
+}
+
+func TestDivergingFields(t *testing.T) {
+	expected := mustRaw(t, bson.D{{Key: "name", Value: "ann"}, {Key: "address", Value: bson.D{{Key: "city", Value: "Oslo"}}}, {Key: "vip", Value: true}})
+	actual := mustRaw(t, bson.D{{Key: "name", Value: "ann"}, {Key: "address", Value: bson.D{{Key: "city", Value: "Bergen"}}}})
+	if got := divergingFields(expected, actual); !reflect.DeepEqual(got, []string{"address.city", "vip"}) {
+		t.Fatalf("diverging fields %v", got)
+	}
+}
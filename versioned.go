@@ -0,0 +1,43 @@
+package mongoboiler
+
+import (
+	"errors"
+	"reflect"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ErrStaleDocument is returned by versioned writes when the stored document is no longer at the
+// version the caller read, i.e. somebody else updated it in between.
+var ErrStaleDocument = errors.New("mongoboiler: document version does not match, it was modified concurrently")
+
+// versionField is the document field holding the version of Versioned models.
+const versionField = "version"
+
+// Versioned opts a model in to optimistic locking when embedded inline:
+//
+//	type User struct {
+//		mongoboiler.Versioned `bson:",inline"`
+//		Name string `bson:"name"`
+//	}
+//
+// UpdateFields and ReplaceOne then only write when the stored version matches and increment it.
+type Versioned struct {
+	Version int64 `bson:"version"`
+}
+
+func (v *Versioned) versioned() *Versioned {
+	return v
+}
+
+// versionedModel is implemented by pointers to structs embedding Versioned.
+type versionedModel interface {
+	versioned() *Versioned
+}
+
+var versionedType = reflect.TypeOf(Versioned{})
+
+// versionFilter returns filter restricted to the current version of doc.
+func versionFilter(filter bson.D, v *Versioned) bson.D {
+	return append(append(bson.D{}, filter...), bson.E{Key: versionField, Value: v.Version})
+}
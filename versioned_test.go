@@ -0,0 +1,43 @@
+package mongoboiler
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type versionedTestDoc struct {
+	Versioned `bson:",inline"`
+	Name      string `bson:"name"`
+}
+
+func TestBuildUpdate_SkipsVersion(t *testing.T) {
+	update, err := BuildUpdate(&versionedTestDoc{Versioned: Versioned{Version: 4}, Name: "a"})
+	if err != nil {
+		t.Fatalf("BuildUpdate failed: %v", err)
+	}
+
+	want := bson.D{{Key: "$set", Value: bson.D{{Key: "name", Value: "a"}}}}
+	if !reflect.DeepEqual(update, want) {
+		t.Fatalf("unexpected update:\n got %v\nwant %v", update, want)
+	}
+}
+
+func TestMergeUpdate(t *testing.T) {
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "name", Value: "a"}}}}
+
+	got := mergeUpdate(update, "$inc", bson.E{Key: versionField, Value: 1})
+	want := bson.D{
+		{Key: "$set", Value: bson.D{{Key: "name", Value: "a"}}},
+		{Key: "$inc", Value: bson.D{{Key: versionField, Value: 1}}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected update:\n got %v\nwant %v", got, want)
+	}
+
+	got = mergeUpdate(got, "$inc", bson.E{Key: "count", Value: 2})
+	if inc := got[1].Value.(bson.D); len(inc) != 2 {
+		t.Fatalf("expected $inc to hold both fields, got %v", inc)
+	}
+}
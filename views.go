@@ -0,0 +1,295 @@
+package mongoboiler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DefaultMaterializedViewsCollection is the collection materialized view definitions and their
+// refresh metadata are stored in.
+const DefaultMaterializedViewsCollection = "materializedViews"
+
+// WithMaterializedViewsCollection changes the collection DefineMaterializedView and
+// RefreshMaterializedView use.
+func WithMaterializedViewsCollection(name string) Option {
+	return func(s *settings) {
+		s.materializedViewsCollection = name
+	}
+}
+
+// ErrInvalidView is returned for materialized views that cannot be defined.
+var ErrInvalidView = errors.New("mongoboiler: invalid materialized view")
+
+// CreateView creates name as a read-only view of the source collection through pipeline and
+// returns it. The server runs pipeline on every read, see DefineMaterializedView for views
+// stored as collections instead.
+func (db *DB) CreateView(ctx context.Context, name, source string, pipeline mongo.Pipeline) (*Collection, error) {
+	view := db.NewCollection(name)
+	if pipeline == nil {
+		pipeline = mongo.Pipeline{}
+	}
+	if err := db.database().CreateView(ctx, view.collectionName(), db.NewCollection(source).collectionName(), pipeline); err != nil {
+		return nil, err
+	}
+	return view, nil
+}
+
+// MaterializedView is a collection filled with the results of an aggregation of another one,
+// refreshed on demand with RefreshMaterializedView or on a schedule by a ViewRefresher.
+type MaterializedView struct {
+	// Name is the collection the results are written to.
+	Name     string         `bson:"_id"`
+	Source   string         `bson:"source"`
+	Pipeline mongo.Pipeline `bson:"pipeline"`
+	// Replace rebuilds the collection from scratch on every refresh with $out. Otherwise the
+	// results are merged into it with $merge, replacing documents matching on MergeOn (_id by
+	// default, other fields need a unique index) and keeping documents no longer produced.
+	Replace bool     `bson:"replace,omitempty"`
+	MergeOn []string `bson:"mergeOn,omitempty"`
+	// Interval is how often a ViewRefresher refreshes the view, zero only refreshes on demand.
+	Interval time.Duration `bson:"interval,omitempty"`
+
+	// The fields below are maintained by the refreshes.
+
+	LastRefreshAt   time.Time     `bson:"lastRefreshAt,omitempty"`
+	LastDuration    time.Duration `bson:"lastDuration,omitempty"`
+	LastError       string        `bson:"lastError,omitempty"`
+	LastSucceededAt time.Time     `bson:"lastSucceededAt,omitempty"`
+	Refreshes       int           `bson:"refreshes,omitempty"`
+	LockedUntil     time.Time     `bson:"lockedUntil,omitempty"`
+}
+
+// materializedViews returns the collection of materialized view definitions of db.
+func (db *DB) materializedViews() *Collection {
+	name := DefaultMaterializedViewsCollection
+	if db.settings != nil && db.settings.materializedViewsCollection != "" {
+		name = db.settings.materializedViewsCollection
+	}
+	return db.NewCollection(name)
+}
+
+// DefineMaterializedView stores the definition of view, replacing an earlier one of the same name
+// but keeping its refresh metadata. The view is not refreshed.
+func (db *DB) DefineMaterializedView(ctx context.Context, view MaterializedView) error {
+	if view.Name == "" || view.Source == "" {
+		return fmt.Errorf("%w: name and source are required", ErrInvalidView)
+	}
+	for _, stage := range view.Pipeline {
+		if len(stage) > 0 && (stage[0].Key == "$out" || stage[0].Key == "$merge") {
+			return fmt.Errorf("%w: the pipeline of %s must not write, the refresh adds %s", ErrInvalidView, view.Name, stage[0].Key)
+		}
+	}
+	if view.Pipeline == nil {
+		view.Pipeline = mongo.Pipeline{}
+	}
+	set := bson.D{
+		{Key: "source", Value: view.Source},
+		{Key: "pipeline", Value: view.Pipeline},
+		{Key: "replace", Value: view.Replace},
+		{Key: "mergeOn", Value: view.MergeOn},
+		{Key: "interval", Value: view.Interval},
+	}
+	_, err := db.materializedViews().UpdateOne(ctx, bson.D{{Key: "_id", Value: view.Name}},
+		bson.D{{Key: "$set", Value: set}}, options.Update().SetUpsert(true))
+	return err
+}
+
+// MaterializedView returns the definition and refresh metadata of the named view, ErrNotFound if
+// it was not defined.
+func (db *DB) MaterializedView(ctx context.Context, name string) (MaterializedView, error) {
+	var view MaterializedView
+	err := db.materializedViews().FindOne(ctx, bson.D{{Key: "_id", Value: name}}, &view)
+	return view, err
+}
+
+// RefreshMaterializedView runs the aggregation of the named view now and records the outcome in
+// its metadata, also when it failed. It returns the view as recorded.
+func (db *DB) RefreshMaterializedView(ctx context.Context, name string) (MaterializedView, error) {
+	view, err := db.MaterializedView(ctx, name)
+	if err != nil {
+		return view, err
+	}
+	return db.refreshMaterializedView(ctx, view)
+}
+
+func (db *DB) refreshMaterializedView(ctx context.Context, view MaterializedView) (MaterializedView, error) {
+	start := time.Now()
+	var discard []bson.Raw
+	refreshErr := db.NewCollection(view.Source).Aggregate(ctx, db.materializePipeline(view), &discard)
+	// The aggregation is a read of the source, so the writes to the view bypass invalidation.
+	if target := db.NewCollection(view.Name); target.readCache() != nil {
+		target.readCache().invalidate(target.newOp(OpAggregate))
+	}
+
+	view.LastRefreshAt = start.UTC().Truncate(time.Millisecond)
+	view.LastDuration = time.Since(start)
+	view.Refreshes++
+	view.LastError = ""
+	set := bson.D{
+		{Key: "lastRefreshAt", Value: view.LastRefreshAt},
+		{Key: "lastDuration", Value: view.LastDuration},
+		{Key: "lockedUntil", Value: time.Time{}},
+	}
+	if refreshErr != nil {
+		view.LastError = refreshErr.Error()
+		set = append(set, bson.E{Key: "lastError", Value: view.LastError})
+	} else {
+		view.LastSucceededAt = view.LastRefreshAt
+		set = append(set, bson.E{Key: "lastError", Value: ""}, bson.E{Key: "lastSucceededAt", Value: view.LastSucceededAt})
+	}
+	view.LockedUntil = time.Time{}
+	update := bson.D{{Key: "$set", Value: set}, {Key: "$inc", Value: bson.D{{Key: "refreshes", Value: 1}}}}
+	// Record the outcome even when ctx ended during the aggregation.
+	recordCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := db.materializedViews().UpdateOne(recordCtx, bson.D{{Key: "_id", Value: view.Name}}, update); err != nil {
+		if refreshErr != nil {
+			return view, refreshErr
+		}
+		return view, fmt.Errorf("recording refresh of %s: %w", view.Name, err)
+	}
+	return view, refreshErr
+}
+
+// materializePipeline returns the pipeline of view followed by the stage writing its results.
+func (db *DB) materializePipeline(view MaterializedView) mongo.Pipeline {
+	into := db.NewCollection(view.Name).collectionName()
+	pipeline := append(mongo.Pipeline(nil), view.Pipeline...)
+	if view.Replace {
+		return append(pipeline, bson.D{{Key: "$out", Value: into}})
+	}
+	on := view.MergeOn
+	if len(on) == 0 {
+		on = []string{"_id"}
+	}
+	return append(pipeline, bson.D{{Key: "$merge", Value: bson.D{
+		{Key: "into", Value: into},
+		{Key: "on", Value: on},
+		{Key: "whenMatched", Value: "replace"},
+		{Key: "whenNotMatched", Value: "insert"},
+	}}})
+}
+
+// ViewRefresher refreshes the materialized views that have an Interval when they are due.
+// Several refreshers may run against the same database: each refresh is leased to one of them.
+type ViewRefresher struct {
+	// PollInterval is the wait between checks for due views, ten seconds by default.
+	PollInterval time.Duration
+	// Lease is how long a view is reserved for a refresh, ten minutes by default. It should be
+	// longer than the slowest refresh, or another refresher may start the same one.
+	Lease time.Duration
+	// Logger receives refresh failures, the standard logger if nil.
+	Logger Logger
+
+	db *DB
+}
+
+// NewViewRefresher returns a refresher for the materialized views of db.
+func (db *DB) NewViewRefresher() *ViewRefresher {
+	return &ViewRefresher{db: db}
+}
+
+// Run refreshes due views until ctx is canceled or the DB is closed, returning nil then. Errors
+// are logged and retried after the poll interval.
+func (r *ViewRefresher) Run(ctx context.Context) error {
+	ctx, done, err := r.db.conn.workers.start(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+	for {
+		n, err := r.RefreshDue(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			loggerOrDefault(r.Logger).Printf("mongoboiler: view refresher: %v", err)
+		}
+		if n > 0 && err == nil {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(r.pollInterval()):
+		}
+	}
+}
+
+// RefreshDue refreshes the views that are due and returns how many were refreshed. Failed
+// refreshes are logged and recorded in the view's metadata, the next follows after its interval.
+func (r *ViewRefresher) RefreshDue(ctx context.Context) (int, error) {
+	n := 0
+	for ctx.Err() == nil {
+		view, ok, err := r.claim(ctx)
+		if err != nil || !ok {
+			return n, err
+		}
+		n++
+		if _, err := r.db.refreshMaterializedView(ctx, view); err != nil {
+			loggerOrDefault(r.Logger).Printf("mongoboiler: refreshing materialized view %s: %v", view.Name, err)
+		}
+	}
+	return n, nil
+}
+
+// claim leases a due view to this refresher, ok is false if there is none. A view is due when it
+// was never refreshed or its interval passed since the last refresh.
+func (r *ViewRefresher) claim(ctx context.Context) (MaterializedView, bool, error) {
+	now := time.Now().UTC()
+	views := r.db.materializedViews()
+	var view MaterializedView
+	op := views.newOp(OpUpdateOne)
+	op.needsEffect = true
+	op.Filter = bson.D{
+		{Key: "interval", Value: bson.D{{Key: "$gt", Value: 0}}},
+		{Key: "$and", Value: bson.A{
+			bson.D{{Key: "$or", Value: bson.A{
+				bson.D{{Key: "lockedUntil", Value: bson.D{{Key: "$exists", Value: false}}}},
+				bson.D{{Key: "lockedUntil", Value: bson.D{{Key: "$lte", Value: now}}}},
+			}}},
+			bson.D{{Key: "$or", Value: bson.A{
+				bson.D{{Key: "lastRefreshAt", Value: bson.D{{Key: "$exists", Value: false}}}},
+				// interval is stored in nanoseconds.
+				bson.D{{Key: "$expr", Value: bson.D{{Key: "$lte", Value: bson.A{
+					bson.D{{Key: "$add", Value: bson.A{"$lastRefreshAt", bson.D{{Key: "$divide", Value: bson.A{"$interval", int64(time.Millisecond)}}}}}},
+					now,
+				}}}}},
+			}}},
+		}},
+	}
+	op.Update = bson.D{{Key: "$set", Value: bson.D{{Key: "lockedUntil", Value: now.Add(r.lease())}}}}
+	err := views.run(ctx, op, func(ctx context.Context, op *Operation) error {
+		opts := options.FindOneAndUpdate().
+			SetSort(bson.D{{Key: "lastRefreshAt", Value: 1}, {Key: "_id", Value: 1}}).
+			SetReturnDocument(options.After)
+		if op.Comment != "" {
+			opts.SetComment(op.Comment)
+		}
+		return op.Target.FindOneAndUpdate(ctx, op.Filter, op.Update, opts).Decode(&view)
+	})
+	if errors.Is(err, ErrNotFound) {
+		return view, false, nil
+	}
+	return view, err == nil, err
+}
+
+func (r *ViewRefresher) pollInterval() time.Duration {
+	if r.PollInterval > 0 {
+		return r.PollInterval
+	}
+	return 10 * time.Second
+}
+
+func (r *ViewRefresher) lease() time.Duration {
+	if r.Lease > 0 {
+		return r.Lease
+	}
+	return 10 * time.Minute
+}
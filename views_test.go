@@ -0,0 +1,100 @@
+package mongoboiler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// viewsDB returns a DB serving FindOne from its cache and recording every other operation.
+func viewsDB(t *testing.T, ops *[]*Operation) *DB {
+	return newTestCollection(t, "orders", WithCache(NewLRUCache(10), 0),
+		WithMiddleware(func(next Handler) Handler {
+			return func(ctx context.Context, op *Operation) error {
+				if op.Kind == OpFindOne {
+					return next(ctx, op)
+				}
+				*ops = append(*ops, op)
+				return nil
+			}
+		})).db
+}
+
+func TestMaterializedView_Define(t *testing.T) {
+	var ops []*Operation
+	db := viewsDB(t, &ops)
+	err := db.DefineMaterializedView(context.Background(), MaterializedView{
+		Name:     "dailySales",
+		Source:   "orders",
+		Pipeline: mongo.Pipeline{{{Key: "$out", Value: "elsewhere"}}},
+	})
+	if !errors.Is(err, ErrInvalidView) {
+		t.Fatalf("writing pipeline: got %v, want ErrInvalidView", err)
+	}
+
+	err = db.DefineMaterializedView(context.Background(), MaterializedView{
+		Name:     "dailySales",
+		Source:   "orders",
+		Interval: time.Hour,
+	})
+	if err != nil || len(ops) != 1 {
+		t.Fatalf("DefineMaterializedView = %v with %d ops", err, len(ops))
+	}
+	if ops[0].Collection != DefaultMaterializedViewsCollection || ops[0].Kind != OpUpdateOne {
+		t.Fatalf("defined with %s on %s", ops[0].Kind, ops[0].Collection)
+	}
+	if set := mustRaw(t, ops[0].Update).Lookup("$set", "interval"); set.Int64() != int64(time.Hour) {
+		t.Fatalf("interval stored as %v", set)
+	}
+}
+
+func TestMaterializedView_Refresh(t *testing.T) {
+	var ops []*Operation
+	db := viewsDB(t, &ops)
+	views := db.materializedViews()
+	primeFindOne(t, views, bson.D{{Key: "_id", Value: "dailySales"}}, bson.D{
+		{Key: "_id", Value: "dailySales"},
+		{Key: "source", Value: "orders"},
+		{Key: "pipeline", Value: bson.A{bson.D{{Key: "$match", Value: bson.D{{Key: "status", Value: "paid"}}}}}},
+		{Key: "refreshes", Value: 2},
+	})
+
+	view, err := db.RefreshMaterializedView(context.Background(), "dailySales")
+	if err != nil {
+		t.Fatalf("RefreshMaterializedView failed: %v", err)
+	}
+	if view.Refreshes != 3 || view.LastRefreshAt.IsZero() || !view.LastSucceededAt.Equal(view.LastRefreshAt) {
+		t.Fatalf("refresh recorded as %+v", view)
+	}
+	if len(ops) != 2 || ops[0].Kind != OpAggregate || ops[0].Collection != "orders" || ops[1].Kind != OpUpdateOne {
+		t.Fatalf("unexpected operations %v", ops)
+	}
+	pipeline := ops[0].Pipeline
+	if len(pipeline) != 2 || pipeline[0][0].Key != "$match" {
+		t.Fatalf("pipeline %v does not start with the view's stages", pipeline)
+	}
+	merge := mustRaw(t, pipeline[1]).Lookup("$merge")
+	if into := merge.Document().Lookup("into").StringValue(); into != "dailySales" {
+		t.Fatalf("merged into %s", into)
+	}
+	if on := merge.Document().Lookup("on").Array().Index(0).Value().StringValue(); on != "_id" {
+		t.Fatalf("merged on %s", on)
+	}
+
+	setCachedMiss(t, views, bson.D{{Key: "_id", Value: "missing"}})
+	if _, err := db.RefreshMaterializedView(context.Background(), "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("undefined view: got %v, want ErrNotFound", err)
+	}
+}
+
+func TestMaterializedView_Replace(t *testing.T) {
+	db := newTestCollection(t, "orders").db
+	pipeline := db.materializePipeline(MaterializedView{Name: "snapshot", Source: "orders", Replace: true})
+	if len(pipeline) != 1 || pipeline[0][0].Key != "$out" || pipeline[0][0].Value != "snapshot" {
+		t.Fatalf("pipeline %v, want only $out", pipeline)
+	}
+}
@@ -0,0 +1,75 @@
+package mongoboiler
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/tag"
+)
+
+// DefaultZoneTag is the replica set member tag WithZoneRouting matches regions against.
+const DefaultZoneTag = "region"
+
+type regionKey struct{}
+
+// ContextWithRegion returns a context carrying the region the caller runs in, for
+// WithZoneRouting.
+func ContextWithRegion(ctx context.Context, region string) context.Context {
+	return context.WithValue(ctx, regionKey{}, region)
+}
+
+// RegionFromContext returns the region set with ContextWithRegion.
+func RegionFromContext(ctx context.Context) (string, bool) {
+	region, ok := ctx.Value(regionKey{}).(string)
+	return region, ok && region != ""
+}
+
+// WithZoneRouting routes reads whose context carries a region (see ContextWithRegion) to the
+// members of the replica set tagged with it in tagName (DefaultZoneTag when empty), falling back
+// to the other members when none of them is available. Reads without a region use the
+// collection's read preference; tag sets of WithReadTags are tried after the region. Like
+// WithReadTags this uses the mode of WithReadPreference, nearest if it is not set, and does
+// nothing in primary mode. Writes and reads in transactions always go to the primary.
+func WithZoneRouting(tagName string) Option {
+	if tagName == "" {
+		tagName = DefaultZoneTag
+	}
+	return func(s *settings) {
+		s.zoneTag = tagName
+	}
+}
+
+// routeRead points reads at the members in the caller's region if zone routing is on.
+func (c Collection) routeRead(ctx context.Context, op *Operation) {
+	if op.Kind.IsWrite() {
+		return
+	}
+	if rp := c.zoneReadPreference(ctx); rp != nil {
+		opts := options.Collection().SetReadPreference(rp)
+		if target, err := op.Target.Clone(opts); err == nil {
+			op.Target = target
+			op.targetOptions = append(op.targetOptions, opts)
+		}
+	}
+}
+
+// zoneReadPreference returns the read preference for the region of ctx, nil if zone routing is
+// off, ctx has no region or the mode takes no tags.
+func (c Collection) zoneReadPreference(ctx context.Context) *readpref.ReadPref {
+	if c.settings == nil || c.settings.zoneTag == "" {
+		return nil
+	}
+	region, ok := RegionFromContext(ctx)
+	if !ok {
+		return nil
+	}
+	sets := append([]tag.Set{{{Name: c.settings.zoneTag, Value: region}}}, c.settings.readTags...)
+	if len(c.settings.readTags) == 0 {
+		sets = append(sets, tag.Set{})
+	}
+	if rp := c.settings.readPreferenceWith(sets); rp != c.settings.readPreference {
+		return rp
+	}
+	return nil
+}
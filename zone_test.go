@@ -0,0 +1,65 @@
+package mongoboiler
+
+import (
+	"context"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+func TestWithReadTags(t *testing.T) {
+	coll := newTestCollection(t, "orders", WithReadTags(map[string]string{"region": "eu-west", "disk": "ssd"}, nil))
+	opts := coll.driverOptions()
+	if len(opts) != 1 || opts[0].ReadPreference == nil {
+		t.Fatalf("expected a read preference, got %+v", opts)
+	}
+	rp := opts[0].ReadPreference
+	if rp.Mode() != readpref.NearestMode || len(rp.TagSets()) != 2 || rp.TagSets()[0].String() != "disk=ssd,region=eu-west" {
+		t.Fatalf("unexpected read preference %v %v", rp.Mode(), rp.TagSets())
+	}
+	if o := resolvedOption(t, coll.ResolveOptions(), "readPreference"); o.Value != "nearest [disk=ssd,region=eu-west] []" {
+		t.Fatalf("readPreference resolved to %s", o)
+	}
+
+	primary := coll.With(WithReadPreference(readpref.Primary()))
+	if rp := primary.driverOptions()[0].ReadPreference; rp.Mode() != readpref.PrimaryMode || len(rp.TagSets()) != 0 {
+		t.Fatalf("expected tags to be ignored in primary mode, got %v", rp.TagSets())
+	}
+}
+
+func TestWithZoneRouting(t *testing.T) {
+	coll := newTestCollection(t, "orders", WithZoneRouting(""), WithReadPreference(readpref.SecondaryPreferred()))
+	ctx := ContextWithRegion(context.Background(), "ap-south")
+
+	rp := coll.zoneReadPreference(ctx)
+	if rp == nil || rp.Mode() != readpref.SecondaryPreferredMode {
+		t.Fatalf("expected a secondaryPreferred read preference, got %v", rp)
+	}
+	if sets := rp.TagSets(); len(sets) != 2 || sets[0].String() != "region=ap-south" || len(sets[1]) != 0 {
+		t.Fatalf("expected the region before any member, got %v", sets)
+	}
+	if coll.zoneReadPreference(context.Background()) != nil {
+		t.Fatalf("expected no routing without a region")
+	}
+
+	tagged := coll.With(WithReadTags(map[string]string{"disk": "ssd"}))
+	if sets := tagged.zoneReadPreference(ctx).TagSets(); len(sets) != 2 || sets[1].String() != "disk=ssd" {
+		t.Fatalf("expected the read tags after the region, got %v", sets)
+	}
+
+	op := coll.newOp(OpInsertOne)
+	target := op.Target
+	coll.routeRead(ctx, op)
+	if op.Target != target {
+		t.Fatalf("expected writes not to be routed")
+	}
+	op = coll.newOp(OpFind)
+	target = op.Target
+	coll.routeRead(ctx, op)
+	if op.Target == target {
+		t.Fatalf("expected the read to be routed")
+	}
+	if opts := op.targetOptions; len(opts) == 0 || opts[len(opts)-1].ReadPreference == nil {
+		t.Fatalf("expected the routed read preference to be kept for rebuilt targets")
+	}
+}